@@ -2,12 +2,16 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
+//go:build !windows
 // +build !windows
 
 package cgotest
 
 import "testing"
 
-func TestSigaltstack(t *testing.T) { testSigaltstack(t) }
-func TestSigprocmask(t *testing.T) { testSigprocmask(t) }
-func Test18146(t *testing.T)       { test18146(t) }
+func TestSigaltstack(t *testing.T)          { testSigaltstack(t) }
+func TestSigprocmask(t *testing.T)          { testSigprocmask(t) }
+func Test18146(t *testing.T)                { test18146(t) }
+func TestChanHandleSend(t *testing.T)       { testChanHandleSend(t) }
+func TestChanHandleRecv(t *testing.T)       { testChanHandleRecv(t) }
+func TestChanHandleSendClosed(t *testing.T) { testChanHandleSendClosed(t) }
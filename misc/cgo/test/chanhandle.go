@@ -0,0 +1,205 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !windows
+// +build !windows
+
+package cgotest
+
+/*
+#cgo CFLAGS: -pthread
+#cgo LDFLAGS: -pthread
+
+#include <pthread.h>
+#include <sched.h>
+#include <stddef.h>
+#include <stdint.h>
+
+int GoChanTrySend(uintptr_t handle, const void *value, size_t size);
+int GoChanTryRecv(uintptr_t handle, void *value, size_t size);
+
+struct chanEvent {
+	int32_t thread;
+	int32_t seq;
+};
+
+enum { chanSenders = 4, chanEvents = 1000 };
+
+struct chanSender {
+	uintptr_t handle;
+	int32_t thread;
+	int failed;
+};
+
+static void*
+chanSend(void *p)
+{
+	struct chanSender *s;
+	struct chanEvent ev;
+	int r;
+
+	s = p;
+	ev.thread = s->thread;
+	for (ev.seq = 0; ev.seq < chanEvents; ev.seq++) {
+		while ((r = GoChanTrySend(s->handle, &ev, sizeof ev)) == 0) {
+			sched_yield();
+		}
+		if (r != 1) {
+			s->failed = 1;
+			break;
+		}
+	}
+	return NULL;
+}
+
+// Sends chanEvents events from each of chanSenders new threads.
+static int
+chanSendThreads(uintptr_t handle)
+{
+	pthread_t threads[chanSenders];
+	struct chanSender senders[chanSenders];
+	int i, failed;
+
+	for (i = 0; i < chanSenders; i++) {
+		senders[i].handle = handle;
+		senders[i].thread = i;
+		senders[i].failed = 0;
+		if (pthread_create(&threads[i], NULL, chanSend, &senders[i]) != 0) {
+			return -1;
+		}
+	}
+	failed = 0;
+	for (i = 0; i < chanSenders; i++) {
+		pthread_join(threads[i], NULL);
+		failed |= senders[i].failed;
+	}
+	return failed;
+}
+
+struct chanReceiver {
+	uintptr_t handle;
+	int64_t sum;
+	int n;
+};
+
+static void*
+chanRecv(void *p)
+{
+	struct chanReceiver *r;
+	int64_t v;
+	int ret;
+
+	r = p;
+	for (;;) {
+		ret = GoChanTryRecv(r->handle, &v, sizeof v);
+		if (ret == -1) {
+			break;
+		}
+		if (ret == 0) {
+			sched_yield();
+			continue;
+		}
+		r->sum += v;
+		r->n++;
+	}
+	return NULL;
+}
+
+// Receives from the channel on a new thread until it is closed.
+static int
+chanRecvThread(uintptr_t handle, int64_t *sum)
+{
+	pthread_t thread;
+	struct chanReceiver r;
+
+	r.handle = handle;
+	r.sum = 0;
+	r.n = 0;
+	if (pthread_create(&thread, NULL, chanRecv, &r) != 0) {
+		return -1;
+	}
+	pthread_join(thread, NULL);
+	*sum = r.sum;
+	return r.n;
+}
+
+static int
+chanTrySendInt64(uintptr_t handle, int64_t v)
+{
+	return GoChanTrySend(handle, &v, sizeof v);
+}
+*/
+import "C"
+
+import (
+	"runtime/cgo"
+	"testing"
+)
+
+func testChanHandleSend(t *testing.T) {
+	c := make(chan C.struct_chanEvent, 10)
+	h := cgo.NewChanHandle(c)
+	defer h.Delete()
+
+	done := make(chan C.int)
+	go func() { done <- C.chanSendThreads(C.uintptr_t(h)) }()
+	var next [C.chanSenders]C.int32_t
+	for i := 0; i < C.chanSenders*C.chanEvents; i++ {
+		ev := <-c
+		if ev.seq != next[ev.thread] {
+			t.Fatalf("thread %d sent event %d, want %d", ev.thread, ev.seq, next[ev.thread])
+		}
+		next[ev.thread]++
+	}
+	if r := <-done; r != 0 {
+		t.Fatalf("chanSendThreads = %d", r)
+	}
+}
+
+func testChanHandleRecv(t *testing.T) {
+	const n = 1000
+	c := make(chan int64, 10)
+	h := cgo.NewChanHandle(c)
+	defer h.Delete()
+
+	type result struct {
+		n   C.int
+		sum C.int64_t
+	}
+	done := make(chan result)
+	go func() {
+		var r result
+		r.n = C.chanRecvThread(C.uintptr_t(h), &r.sum)
+		done <- r
+	}()
+	var want int64
+	for i := int64(1); i <= n; i++ {
+		c <- i
+		want += i
+	}
+	close(c)
+	if r := <-done; r.n != n || int64(r.sum) != want {
+		t.Fatalf("C thread received %d values with sum %d, want %d with sum %d", r.n, r.sum, n, want)
+	}
+}
+
+func testChanHandleSendClosed(t *testing.T) {
+	c := make(chan int64, 1)
+	h := cgo.NewChanHandle(c)
+	defer h.Delete()
+
+	if r := C.chanTrySendInt64(C.uintptr_t(h), 1); r != 1 {
+		t.Fatalf("GoChanTrySend on channel with room = %d, want 1", r)
+	}
+	if r := C.chanTrySendInt64(C.uintptr_t(h), 2); r != 0 {
+		t.Fatalf("GoChanTrySend on full channel = %d, want 0", r)
+	}
+	close(c)
+	if r := C.chanTrySendInt64(C.uintptr_t(h), 3); r != -1 {
+		t.Fatalf("GoChanTrySend on closed channel = %d, want -1", r)
+	}
+	if v := <-c; v != 1 {
+		t.Fatalf("received %d, want 1", v)
+	}
+}
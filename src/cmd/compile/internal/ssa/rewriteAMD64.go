@@ -950,6 +950,9 @@ func rewriteValueAMD64(v *Value) bool {
 		return true
 	case OpPopCount8:
 		return rewriteValueAMD64_OpPopCount8(v)
+	case OpPrefetchCache:
+		v.Op = OpAMD64PrefetchT0
+		return true
 	case OpRotateLeft16:
 		v.Op = OpAMD64ROLW
 		return true
@@ -227,6 +227,15 @@ func walkLenCap(n *ir.UnaryExpr, init *ir.Nodes) ir.Node {
 
 	n.X = walkExpr(n.X, init)
 
+	if n.Op() == ir.OLEN && n.X.Type().IsChan() && base.Flag.Race && !base.Compiling(base.NoRacePkgs) {
+		// Replace len(chan) with runtime.chanlen(chan), which can
+		// report races with channel operations (GODEBUG=racechanlen=1).
+		// cannot use chanfn - chanlen takes any, not chan any
+		fn := typecheck.LookupRuntime("chanlen")
+		fn = typecheck.SubstArgTypes(fn, n.X.Type())
+		return mkcall1(fn, n.Type(), init, n.X)
+	}
+
 	// replace len(*[10]int) with 10.
 	// delayed until now to preserve side effects.
 	t := n.X.Type()
@@ -245,6 +254,16 @@ func walkLenCap(n *ir.UnaryExpr, init *ir.Nodes) ir.Node {
 
 // walkMakeChan walks an OMAKECHAN node.
 func walkMakeChan(n *ir.MakeExpr, init *ir.Nodes) ir.Node {
+	// Unlike a map, a channel is allocated by the runtime even when it
+	// does not escape. The runtime ties much of a channel's state to
+	// its heap object: channel registry entries and, on channels
+	// without pointers, labels and statistics are special records on
+	// its span, and the sudogs of a goroutine parked on it point at it
+	// where stack copying would not adjust them. Channels are also
+	// mostly made to be shared with goroutines started by the same
+	// function, and escape analysis cannot see those goroutines finish
+	// before the frame returns, so such a channel escapes anyway.
+
 	// When size fits into int, use makechan instead of
 	// makechan64, which is faster and shorter on 32 bit platforms.
 	size := n.Len
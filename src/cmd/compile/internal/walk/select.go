@@ -5,6 +5,8 @@
 package walk
 
 import (
+	"internal/buildcfg"
+
 	"cmd/compile/internal/base"
 	"cmd/compile/internal/ir"
 	"cmd/compile/internal/typecheck"
@@ -151,8 +153,12 @@ func walkSelectCases(cases []*ir.CommClause) []ir.Node {
 	selv := typecheck.Temp(types.NewArray(scasetype(), int64(ncas)))
 	init = append(init, typecheck.Stmt(ir.NewAssignStmt(base.Pos, selv, nil)))
 
-	// No initialization for order; runtime.selectgo is responsible for that.
+	// No initialization for order; runtime.selectgo is responsible for that,
+	// except that with GOEXPERIMENT=orderedselect the first half holds the
+	// scase indexes in the order the cases are listed.
 	order := typecheck.Temp(types.NewArray(types.Types[types.TUINT16], 2*int64(ncas)))
+	ordered := buildcfg.Experiment.OrderedSelect
+	npoll := 0
 
 	var pc0, pcs ir.Node
 	if base.Flag.Race {
@@ -195,6 +201,12 @@ func walkSelectCases(cases []*ir.CommClause) []ir.Node {
 
 		casorder[i] = cas
 
+		if ordered {
+			r := ir.NewAssignStmt(base.Pos, ir.NewIndexExpr(base.Pos, order, ir.NewInt(int64(npoll))), ir.NewInt(int64(i)))
+			init = append(init, typecheck.Stmt(r))
+			npoll++
+		}
+
 		setField := func(f string, val ir.Node) {
 			r := ir.NewAssignStmt(base.Pos, ir.NewSelectorExpr(base.Pos, ir.ODOT, ir.NewIndexExpr(base.Pos, selv, ir.NewInt(int64(i))), typecheck.Lookup(f)), val)
 			init = append(init, typecheck.Stmt(r))
@@ -226,7 +238,7 @@ func walkSelectCases(cases []*ir.CommClause) []ir.Node {
 	r.Lhs = []ir.Node{chosen, recvOK}
 	fn := typecheck.LookupRuntime("selectgo")
 	var fnInit ir.Nodes
-	r.Rhs = []ir.Node{mkcall1(fn, fn.Type().Results(), &fnInit, bytePtrToIndex(selv, 0), bytePtrToIndex(order, 0), pc0, ir.NewInt(int64(nsends)), ir.NewInt(int64(nrecvs)), ir.NewBool(dflt == nil))}
+	r.Rhs = []ir.Node{mkcall1(fn, fn.Type().Results(), &fnInit, bytePtrToIndex(selv, 0), bytePtrToIndex(order, 0), pc0, ir.NewInt(int64(nsends)), ir.NewInt(int64(nrecvs)), ir.NewBool(dflt == nil), ir.NewBool(ordered))}
 	init = append(init, fnInit...)
 	init = append(init, typecheck.Stmt(r))
 
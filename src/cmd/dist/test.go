@@ -504,6 +504,20 @@ func (t *tester) registerTests() {
 		})
 	}
 
+	// Test select with GOEXPERIMENT=orderedselect, which changes the
+	// order in which select polls its cases.
+	if !t.compileOnly {
+		t.tests = append(t.tests, distTest{
+			name:    "orderedselect",
+			heading: "runtime and reflect select tests with GOEXPERIMENT=orderedselect",
+			fn: func(dt *distTest) error {
+				cmd := t.addCmd(dt, "src", t.goTest(), t.timeout(300), "-run=Select|Chan", "runtime", "reflect")
+				setEnv(cmd, "GOEXPERIMENT", "orderedselect")
+				return nil
+			},
+		})
+	}
+
 	if t.iOS() && !t.compileOnly {
 		t.tests = append(t.tests, distTest{
 			name:    "x509omitbundledroots",
@@ -14,6 +14,7 @@ var passFlagToTest = map[string]bool{
 	"benchtime":            true,
 	"blockprofile":         true,
 	"blockprofilerate":     true,
+	"chanleak":             true,
 	"count":                true,
 	"coverprofile":         true,
 	"cpu":                  true,
@@ -205,6 +205,13 @@ control the execution of any test:
 	    The special syntax Nx means to run the benchmark N times
 	    (for example, -benchtime 100x).
 
+	-chanleak
+	    Fail each test that leaves goroutines blocked on channels
+	    the test created, listing the goroutines and where the
+	    channels were created. Goroutines started by a test and the
+	    channels they create are attributed to that test, not to its
+	    parent or to tests running in parallel with it.
+
 	-count n
 	    Run each test and benchmark n times (default 1).
 	    If -cpu is set, run n times for each GOMAXPROCS value.
@@ -52,6 +52,7 @@ func init() {
 	cf.String("benchtime", "", "")
 	cf.StringVar(&testBlockProfile, "blockprofile", "", "")
 	cf.String("blockprofilerate", "", "")
+	cf.Bool("chanleak", false, "")
 	cf.Int("count", 0, "")
 	cf.Var(coverFlag{stringFlag{&testCoverProfile}}, "coverprofile", "")
 	cf.String("cpu", "", "")
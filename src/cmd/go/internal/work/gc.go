@@ -111,7 +111,7 @@ func (gcToolchain) gc(b *Builder, a *Action, archive string, importcfg, embedcfg
 			fallthrough
 		case "runtime/metrics", "runtime/pprof", "runtime/trace":
 			fallthrough
-		case "sync", "syscall", "time":
+		case "sync", "syscall", "testing", "time":
 			extFiles++
 		}
 	}
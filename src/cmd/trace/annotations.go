@@ -190,6 +190,7 @@ func httpUserTask(w http.ResponseWriter, r *http.Request) {
 		Events     []event
 		Start, End time.Duration // Time since the beginning of the trace
 		GCTime     time.Duration
+		ChanTime   time.Duration
 	}
 
 	base := time.Duration(firstTimestamp()) * time.Nanosecond // trace start
@@ -200,13 +201,15 @@ func httpUserTask(w http.ResponseWriter, r *http.Request) {
 		if !filter.match(task) {
 			continue
 		}
-		// merge events in the task.events and task.regions.Start
+		// merge events in the task.events, task.regions.Start
+		// and task.chanBlocks
 		rawEvents := append([]*trace.Event{}, task.events...)
 		for _, s := range task.regions {
 			if s.Start != nil {
 				rawEvents = append(rawEvents, s.Start)
 			}
 		}
+		rawEvents = append(rawEvents, task.chanBlocks...)
 		sort.SliceStable(rawEvents, func(i, j int) bool { return rawEvents[i].Ts < rawEvents[j].Ts })
 
 		var events []event
@@ -239,6 +242,7 @@ func httpUserTask(w http.ResponseWriter, r *http.Request) {
 			Start:      time.Duration(task.firstTimestamp()) * time.Nanosecond,
 			End:        time.Duration(task.endTimestamp()) * time.Nanosecond,
 			GCTime:     task.overlappingGCDuration(res.gcEvents),
+			ChanTime:   task.chanBlockDuration(),
 		})
 	}
 	sort.Slice(data, func(i, j int) bool {
@@ -306,6 +310,12 @@ func analyzeAnnotations() (annotationAnalysisResult, error) {
 				}
 			}
 
+		case trace.EvGoBlockSend, trace.EvGoBlockRecv, trace.EvGoBlockSelect:
+			if task := tasks.task(chanBlockTask(ev)); task != nil {
+				task.chanBlocks = append(task.chanBlocks, ev)
+				task.goroutines[ev.G] = struct{}{}
+			}
+
 		case trace.EvGCStart:
 			gcEvents = append(gcEvents, ev)
 		}
@@ -351,6 +361,7 @@ type taskDesc struct {
 	events     []*trace.Event      // sorted based on timestamp.
 	regions    []regionDesc        // associated regions, sorted based on the start timestamp and then the last timestamp.
 	goroutines map[uint64]struct{} // involved goroutines
+	chanBlocks []*trace.Event      // channel blocking events in the task's regions, sorted based on timestamp.
 
 	create *trace.Event // Task create event
 	end    *trace.Event // Task end event
@@ -510,6 +521,33 @@ func (task *taskDesc) overlappingGCDuration(evs []*trace.Event) (overlapping tim
 	return overlapping
 }
 
+// chanBlockDuration returns the time the goroutines of the task spent
+// blocked on channels in the task's regions. A goroutine still blocked
+// at the end of the trace is counted until then.
+func (task *taskDesc) chanBlockDuration() (d time.Duration) {
+	for _, ev := range task.chanBlocks {
+		end := lastTimestamp()
+		if ev.Link != nil {
+			end = ev.Link.Ts
+		}
+		d += time.Duration(end-ev.Ts) * time.Nanosecond
+	}
+	return d
+}
+
+// chanBlockTask returns the task the goroutine was working for when it
+// blocked in the channel operation ev, which is the task of the
+// innermost region the goroutine was in, or 0 if there is none.
+func chanBlockTask(ev *trace.Event) uint64 {
+	switch ev.Type {
+	case trace.EvGoBlockSend, trace.EvGoBlockRecv:
+		return ev.Args[1]
+	case trace.EvGoBlockSelect:
+		return ev.Args[0]
+	}
+	return 0
+}
+
 // overlappingInstant reports whether the instantaneous event, ev, occurred during
 // any of the task's region if ev is a goroutine-local event, or overlaps with the
 // task's lifetime if ev is a global event.
@@ -1093,7 +1131,7 @@ Search log text: <form onsubmit="window.location.search+='&logtext='+window.logt
 		<td></td>
 		<td></td>
 		<td></td>
-		<td>GC:{{$el.GCTime}}</td>
+		<td>GC:{{$el.GCTime}} Chan block:{{$el.ChanTime}}</td>
     {{end}}
 </body>
 </html>
@@ -1159,6 +1197,21 @@ func describeEvent(ev *trace.Event) string {
 		// TODO: add child task creation events into the parent task events
 	case trace.EvUserTaskEnd:
 		return "task end"
+	case trace.EvGoBlockSend, trace.EvGoBlockRecv, trace.EvGoBlockSelect:
+		what := "blocked in select"
+		switch ev.Type {
+		case trace.EvGoBlockSend:
+			what = "blocked on chan send"
+		case trace.EvGoBlockRecv:
+			what = "blocked on chan receive"
+		}
+		if ev.Type != trace.EvGoBlockSelect && ev.SArgs[0] != "" {
+			what += " (" + ev.SArgs[0] + ")"
+		}
+		if ev.Link == nil {
+			return what + " until the end of the trace"
+		}
+		return fmt.Sprintf("%s for %v", what, time.Duration(ev.Link.Ts-ev.Ts)*time.Nanosecond)
 	}
 	return ""
 }
@@ -1266,6 +1319,7 @@ function reloadTable(key, value) {
 <table class="summary">
 	<tr><td>Network Wait Time:</td><td> <a href="/regionio?{{$p}}">graph</a><a href="/regionio?{{$p}}&raw=1" download="io.profile">(download)</a></td></tr>
 	<tr><td>Sync Block Time:</td><td> <a href="/regionblock?{{$p}}">graph</a><a href="/regionblock?{{$p}}&raw=1" download="block.profile">(download)</a></td></tr>
+	<tr><td>Channel Block Time:</td><td> <a href="/regionchanblock?{{$p}}">graph</a><a href="/regionchanblock?{{$p}}&raw=1" download="chanblock.profile">(download)</a></td></tr>
 	<tr><td>Blocking Syscall Time:</td><td> <a href="/regionsyscall?{{$p}}">graph</a><a href="/regionsyscall?{{$p}}&raw=1" download="syscall.profile">(download)</a></td></tr>
 	<tr><td>Scheduler Wait Time:</td><td> <a href="/regionsched?{{$p}}">graph</a><a href="/regionsched?{{$p}}&raw=1" download="sched.profile">(download)</a></td></tr>
 </table>
@@ -1280,6 +1334,10 @@ function reloadTable(key, value) {
 <th onclick="reloadTable('sortby', 'ExecTime')" class="exec-time"> Execution</th>
 <th onclick="reloadTable('sortby', 'IOTime')" class="io-time"> Network wait</th>
 <th onclick="reloadTable('sortby', 'BlockTime')" class="block-time"> Sync block </th>
+<th onclick="reloadTable('sortby', 'ChanSendTime')" class="block-time"> Chan send</th>
+<th onclick="reloadTable('sortby', 'ChanRecvTime')" class="block-time"> Chan recv</th>
+<th onclick="reloadTable('sortby', 'SelectTime')" class="block-time"> Select</th>
+<th onclick="reloadTable('sortby', 'MutexTime')" class="block-time"> Mutex</th>
 <th onclick="reloadTable('sortby', 'SyscallTime')" class="syscall-time"> Blocking syscall</th>
 <th onclick="reloadTable('sortby', 'SchedWaitTime')" class="sched-time"> Scheduler wait</th>
 <th onclick="reloadTable('sortby', 'SweepTime')" class="sweep-time"> GC sweeping</th>
@@ -1303,6 +1361,10 @@ function reloadTable(key, value) {
     <td> {{prettyDuration .ExecTime}}</td>
     <td> {{prettyDuration .IOTime}}</td>
     <td> {{prettyDuration .BlockTime}}</td>
+    <td> {{prettyDuration .ChanSendTime}}</td>
+    <td> {{prettyDuration .ChanRecvTime}}</td>
+    <td> {{prettyDuration .SelectTime}}</td>
+    <td> {{prettyDuration .MutexTime}}</td>
     <td> {{prettyDuration .SyscallTime}}</td>
     <td> {{prettyDuration .SchedWaitTime}}</td>
     <td> {{prettyDuration .SweepTime}} {{percent .SweepTime .TotalTime}}</td>
@@ -324,6 +324,86 @@ func TestAnalyzeAnnotationGC(t *testing.T) {
 	}
 }
 
+// prog3 starts a task whose goroutine blocks on a channel receive, and
+// in a select in a region of a nested task, and a goroutine that blocks
+// outside of any region.
+func prog3() {
+	ch, never := make(chan int), make(chan int)
+	ctx, task := trace.NewTask(context.Background(), "chanTask")
+	trace.WithRegion(ctx, "chanTask.region", func() {
+		go func() {
+			time.Sleep(time.Millisecond)
+			ch <- 1
+		}()
+		<-ch
+		ctx2, task2 := trace.NewTask(ctx, "selectTask")
+		trace.WithRegion(ctx2, "selectTask.region", func() {
+			go func() {
+				time.Sleep(time.Millisecond)
+				ch <- 2
+			}()
+			select {
+			case <-ch:
+			case <-never:
+			}
+		})
+		task2.End()
+		// Back in a region of chanTask.
+		go func() {
+			time.Sleep(time.Millisecond)
+			ch <- 3
+		}()
+		<-ch
+	})
+	task.End()
+
+	go func() {
+		time.Sleep(time.Millisecond)
+		ch <- 4
+	}()
+	<-ch
+}
+
+func TestAnalyzeAnnotationChanBlocks(t *testing.T) {
+	if err := traceProgram(t, prog3, "TestAnalyzeAnnotationChanBlocks"); err != nil {
+		t.Fatalf("failed to trace the program: %v", err)
+	}
+
+	res, err := analyzeAnnotations()
+	if err != nil {
+		t.Fatalf("failed to analyzeAnnotations: %v", err)
+	}
+
+	want := map[string][]byte{
+		"chanTask":   {traceparser.EvGoBlockRecv, traceparser.EvGoBlockRecv},
+		"selectTask": {traceparser.EvGoBlockSelect},
+	}
+	for _, task := range res.tasks {
+		wantEvs, ok := want[task.name]
+		if !ok {
+			continue // left over from another test
+		}
+		delete(want, task.name)
+		var got []byte
+		for _, ev := range task.chanBlocks {
+			got = append(got, ev.Type)
+		}
+		if !reflect.DeepEqual(got, wantEvs) {
+			t.Errorf("task %s has channel blocking events %v, want %v", task.name, got, wantEvs)
+			continue
+		}
+		if d := task.chanBlockDuration(); d <= 0 || d > task.duration() {
+			t.Errorf("task %s was blocked on channels for %v, want (0, %v]", task.name, d, task.duration())
+		}
+		if ev := task.chanBlocks[0]; ev.Link == nil || describeEvent(ev) == "" {
+			t.Errorf("task %s has channel blocking event %v that is not unblocked or not described", task.name, ev)
+		}
+	}
+	if len(want) > 0 {
+		t.Errorf("no more tasks; want %v", want)
+	}
+}
+
 // traceProgram runs the provided function while tracing is enabled,
 // parses the captured trace, and sets the global trace loader to
 // point to the parsed trace.
@@ -253,6 +253,7 @@ function reloadTable(key, value) {
 	<tr><td>Execution Time:</td><td>{{.ExecTimePercent}} of total program execution time </td> </tr>
 	<tr><td>Network Wait Time:</td><td> <a href="/io?id={{.PC}}">graph</a><a href="/io?id={{.PC}}&raw=1" download="io.profile">(download)</a></td></tr>
 	<tr><td>Sync Block Time:</td><td> <a href="/block?id={{.PC}}">graph</a><a href="/block?id={{.PC}}&raw=1" download="block.profile">(download)</a></td></tr>
+	<tr><td>Channel Block Time:</td><td> <a href="/chanblock?id={{.PC}}">graph</a><a href="/chanblock?id={{.PC}}&raw=1" download="chanblock.profile">(download)</a></td></tr>
 	<tr><td>Blocking Syscall Time:</td><td> <a href="/syscall?id={{.PC}}">graph</a><a href="/syscall?id={{.PC}}&raw=1" download="syscall.profile">(download)</a></td></tr>
 	<tr><td>Scheduler Wait Time:</td><td> <a href="/sched?id={{.PC}}">graph</a><a href="/sched?id={{.PC}}&raw=1" download="sched.profile">(download)</a></td></tr>
 </table>
@@ -265,6 +266,10 @@ function reloadTable(key, value) {
 <th onclick="reloadTable('sortby', 'ExecTime')" class="exec-time"> Execution</th>
 <th onclick="reloadTable('sortby', 'IOTime')" class="io-time"> Network wait</th>
 <th onclick="reloadTable('sortby', 'BlockTime')" class="block-time"> Sync block </th>
+<th onclick="reloadTable('sortby', 'ChanSendTime')" class="block-time"> Chan send</th>
+<th onclick="reloadTable('sortby', 'ChanRecvTime')" class="block-time"> Chan recv</th>
+<th onclick="reloadTable('sortby', 'SelectTime')" class="block-time"> Select</th>
+<th onclick="reloadTable('sortby', 'MutexTime')" class="block-time"> Mutex</th>
 <th onclick="reloadTable('sortby', 'SyscallTime')" class="syscall-time"> Blocking syscall</th>
 <th onclick="reloadTable('sortby', 'SchedWaitTime')" class="sched-time"> Scheduler wait</th>
 <th onclick="reloadTable('sortby', 'SweepTime')" class="sweep-time"> GC sweeping</th>
@@ -287,6 +292,10 @@ function reloadTable(key, value) {
     <td> {{prettyDuration .ExecTime}}</td>
     <td> {{prettyDuration .IOTime}}</td>
     <td> {{prettyDuration .BlockTime}}</td>
+    <td> {{prettyDuration .ChanSendTime}}</td>
+    <td> {{prettyDuration .ChanRecvTime}}</td>
+    <td> {{prettyDuration .SelectTime}}</td>
+    <td> {{prettyDuration .MutexTime}}</td>
     <td> {{prettyDuration .SyscallTime}}</td>
     <td> {{prettyDuration .SchedWaitTime}}</td>
     <td> {{prettyDuration .SweepTime}} {{percent .SweepTime .TotalTime}}</td>
@@ -38,11 +38,13 @@ func goCmd() string {
 func init() {
 	http.HandleFunc("/io", serveSVGProfile(pprofByGoroutine(computePprofIO)))
 	http.HandleFunc("/block", serveSVGProfile(pprofByGoroutine(computePprofBlock)))
+	http.HandleFunc("/chanblock", serveSVGProfile(pprofByGoroutine(computePprofChanBlock)))
 	http.HandleFunc("/syscall", serveSVGProfile(pprofByGoroutine(computePprofSyscall)))
 	http.HandleFunc("/sched", serveSVGProfile(pprofByGoroutine(computePprofSched)))
 
 	http.HandleFunc("/regionio", serveSVGProfile(pprofByRegion(computePprofIO)))
 	http.HandleFunc("/regionblock", serveSVGProfile(pprofByRegion(computePprofBlock)))
+	http.HandleFunc("/regionchanblock", serveSVGProfile(pprofByRegion(computePprofChanBlock)))
 	http.HandleFunc("/regionsyscall", serveSVGProfile(pprofByRegion(computePprofSyscall)))
 	http.HandleFunc("/regionsched", serveSVGProfile(pprofByRegion(computePprofSched)))
 }
@@ -191,15 +193,33 @@ func computePprofIO(w io.Writer, gToIntervals map[uint64][]interval, events []*t
 
 // computePprofBlock generates blocking pprof-like profile (time spent blocked on synchronization primitives).
 func computePprofBlock(w io.Writer, gToIntervals map[uint64][]interval, events []*trace.Event) error {
-	prof := make(map[uint64]Record)
-	for _, ev := range events {
-		switch ev.Type {
+	return computePprofBlocked(w, gToIntervals, events, func(typ byte) bool {
+		switch typ {
 		case trace.EvGoBlockSend, trace.EvGoBlockRecv, trace.EvGoBlockSelect,
 			trace.EvGoBlockSync, trace.EvGoBlockCond, trace.EvGoBlockGC:
 			// TODO(hyangah): figure out why EvGoBlockGC should be here.
 			// EvGoBlockGC indicates the goroutine blocks on GC assist, not
 			// on synchronization primitives.
-		default:
+			return true
+		}
+		return false
+	})
+}
+
+// computePprofChanBlock generates blocking pprof-like profile restricted to
+// channel operations (time spent blocked on channel sends, receives and selects).
+func computePprofChanBlock(w io.Writer, gToIntervals map[uint64][]interval, events []*trace.Event) error {
+	return computePprofBlocked(w, gToIntervals, events, func(typ byte) bool {
+		return typ == trace.EvGoBlockSend || typ == trace.EvGoBlockRecv || typ == trace.EvGoBlockSelect
+	})
+}
+
+// computePprofBlocked generates pprof-like profile of the time spent blocked
+// in the blocking events whose type is accepted by match.
+func computePprofBlocked(w io.Writer, gToIntervals map[uint64][]interval, events []*trace.Event, match func(typ byte) bool) error {
+	prof := make(map[uint64]Record)
+	for _, ev := range events {
+		if !match(ev.Type) {
 			continue
 		}
 		if ev.Link == nil || ev.StkID == 0 || len(ev.Stk) == 0 {
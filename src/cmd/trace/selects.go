@@ -0,0 +1,157 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Select statistics.
+
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"internal/trace"
+	"log"
+	"net/http"
+	"sort"
+)
+
+func init() {
+	http.HandleFunc("/selects", httpSelects)
+}
+
+// selectSite describes the outcomes of a select statement.
+type selectSite struct {
+	PC    uint64
+	Fn    string
+	File  string
+	Line  int
+	N     int          // number of times the select completed
+	Cases []selectCase // sends, receives, then default if it was taken
+}
+
+// selectCase is the number of times a case of a select was chosen.
+type selectCase struct {
+	Name string
+	N    int
+}
+
+// Percent returns the share of the executions of s that chose c.
+func (s *selectSite) Percent(c selectCase) string {
+	return fmt.Sprintf("%.1f%%", float64(c.N)/float64(s.N)*100)
+}
+
+// analyzeSelects returns the outcomes of the select statements in
+// events, ordered by the number of times they were executed. Selects
+// are identified by their PC and number of cases; all the calls to
+// reflect.Select with the same number of cases count as one select.
+//
+// The runtime numbers the cases of a select with the sends first, in
+// the order they are written, followed by the receives in reverse
+// order. The cases of a selectSite are the sends and then the receives,
+// each in the order they are written, so the first receive of a select
+// is "receive 0".
+func analyzeSelects(events []*trace.Event) []*selectSite {
+	type key struct {
+		pc             uint64
+		nsends, nrecvs int
+	}
+	type counts struct {
+		site  *selectSite
+		dflt  int
+		cases []int
+	}
+	sites := make(map[key]*counts)
+	for _, ev := range events {
+		if ev.Type != trace.EvSelect || len(ev.Stk) == 0 {
+			continue
+		}
+		f := ev.Stk[0]
+		k := key{f.PC, int(ev.Args[1]), int(ev.Args[2])}
+		c := sites[k]
+		if c == nil {
+			c = &counts{
+				site:  &selectSite{PC: f.PC, Fn: f.Fn, File: f.File, Line: f.Line},
+				cases: make([]int, k.nsends+k.nrecvs),
+			}
+			sites[k] = c
+		}
+		c.site.N++
+		casi := int(ev.Args[0]) - 1
+		switch {
+		case casi < 0:
+			c.dflt++
+		case casi < k.nsends:
+			c.cases[casi]++
+		case casi < k.nsends+k.nrecvs:
+			c.cases[k.nsends+(k.nsends+k.nrecvs-1-casi)]++
+		}
+	}
+
+	list := make([]*selectSite, 0, len(sites))
+	for k, c := range sites {
+		s := c.site
+		for i, n := range c.cases {
+			name := fmt.Sprintf("send %d", i)
+			if i >= k.nsends {
+				name = fmt.Sprintf("receive %d", i-k.nsends)
+			}
+			s.Cases = append(s.Cases, selectCase{name, n})
+		}
+		if c.dflt > 0 {
+			s.Cases = append(s.Cases, selectCase{"default", c.dflt})
+		}
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].N != list[j].N {
+			return list[i].N > list[j].N
+		}
+		if list[i].PC != list[j].PC {
+			return list[i].PC < list[j].PC
+		}
+		return len(list[i].Cases) < len(list[j].Cases)
+	})
+	return list
+}
+
+// httpSelects serves the outcomes of the select statements in the trace.
+func httpSelects(w http.ResponseWriter, r *http.Request) {
+	events, err := parseEvents()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html;charset=utf-8")
+	if err := templSelects.Execute(w, analyzeSelects(events)); err != nil {
+		log.Printf("failed to execute template: %v", err)
+		return
+	}
+}
+
+var templSelects = template.Must(template.New("").Parse(`
+<html>
+<style>
+table { border-collapse: collapse; }
+td, th { border: 1px solid black; padding: 2px 8px; text-align: left; vertical-align: top; }
+</style>
+<body>
+Select statements and how often each of their cases was chosen.
+Sends and receives are numbered separately, in the order they are written.<br>
+<br>
+<table>
+<tr><th>Select</th><th>Executions</th><th>Case</th><th>Chosen</th></tr>
+{{range $s := $}}
+{{range $i, $c := $s.Cases}}
+<tr>
+{{if not $i}}
+  <td rowspan="{{len $s.Cases}}">{{$s.Fn}}<br>{{$s.File}}:{{$s.Line}}</td>
+  <td rowspan="{{len $s.Cases}}">{{$s.N}}</td>
+{{end}}
+  <td>{{$c.Name}}</td><td>{{$c.N}} ({{$s.Percent $c}})</td>
+</tr>
+{{end}}
+{{end}}
+</table>
+</body>
+</html>
+`))
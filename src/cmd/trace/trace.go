@@ -508,6 +508,7 @@ const (
 	procsSection = 0 // where Goroutines or per-P timelines are presented.
 	statsSection = 1 // where counters are presented.
 	tasksSection = 2 // where Task hierarchy & timeline is presented.
+	chansSection = 3 // where channel buffer states are presented.
 )
 
 // generateTrace generates json trace for trace-viewer:
@@ -727,6 +728,8 @@ func generateTrace(params *traceParams, consumer traceConsumer) error {
 			ctx.emitInstant(ev, "task start", "user event")
 		case trace.EvUserTaskEnd:
 			ctx.emitInstant(ev, "task end", "user event")
+		case trace.EvChanClose:
+			ctx.emitInstant(ev, "chan close", "")
 		}
 		// Emit any counter updates.
 		ctx.emitThreadCounters(ev)
@@ -734,6 +737,11 @@ func generateTrace(params *traceParams, consumer traceConsumer) error {
 		ctx.emitGoroutineCounters(ev)
 	}
 
+	// Display channel buffers if we are in the default trace view mode.
+	if ctx.mode&(modeGoroutineOriented|modeTaskOriented) == 0 {
+		ctx.emitChanBufRanges()
+	}
+
 	ctx.emitSectionFooter(statsSection, "STATS", 0)
 
 	if ctx.mode&modeTaskOriented != 0 {
@@ -869,14 +877,27 @@ func (ctx *traceContext) makeSlice(ev *trace.Event, name string) *traceviewer.Ev
 		EndStack: ctx.stack(ev.Link.Stk),
 	}
 
+	// include the channel the goroutine blocked on, if any.
+	var blockedOn string
+	if t := ev.Link.Type; t == trace.EvGoBlockSend || t == trace.EvGoBlockRecv {
+		blockedOn = ev.Link.SArgs[0]
+	}
+	if blockedOn != "" {
+		type Arg struct {
+			BlockedOn string `json:"Blocked on"`
+		}
+		sl.Arg = &Arg{BlockedOn: blockedOn}
+	}
+
 	// grey out non-overlapping events if the event is not a global event (ev.G == 0)
 	if ctx.mode&modeTaskOriented != 0 && ev.G != 0 {
 		// include P information.
 		if t := ev.Type; t == trace.EvGoStart || t == trace.EvGoStartLabel {
 			type Arg struct {
-				P int
+				P         int
+				BlockedOn string `json:"Blocked on,omitempty"`
 			}
-			sl.Arg = &Arg{P: ev.P}
+			sl.Arg = &Arg{P: ev.P, BlockedOn: blockedOn}
 		}
 		// grey out non-overlapping events.
 		overlapping := false
@@ -978,6 +999,39 @@ func (ctx *traceContext) emitRegion(s regionDesc) {
 	ctx.emit(sl1)
 }
 
+// emitChanBufRanges emits a row for each channel whose buffer was full
+// or empty during the trace, with a slice for each period it was so.
+func (ctx *traceContext) emitChanBufRanges() {
+	ranges := trace.ChanBufRanges(ctx.parsed.Events)
+	if len(ranges) == 0 {
+		return
+	}
+	ctx.emitSectionFooter(chansSection, "CHANNELS", 3)
+	for i, r := range ranges {
+		if i == 0 || r.Chan != ranges[i-1].Chan {
+			name := fmt.Sprintf("C%d %s", r.Chan, r.Desc)
+			ctx.emitFooter(&traceviewer.Event{Name: "thread_name", Phase: "M", PID: chansSection, TID: r.Chan, Arg: &NameArg{name}})
+			ctx.emitFooter(&traceviewer.Event{Name: "thread_sort_index", Phase: "M", PID: chansSection, TID: r.Chan, Arg: &SortIndexArg{int(r.Chan)}})
+		}
+		if r.EndTime < ctx.startTime || r.StartTime > ctx.endTime {
+			continue
+		}
+		name, color := "full", colorSunsetOrange
+		if r.State == trace.ChanBufEmpty {
+			name, color = "empty", colorCornflowerBlue
+		}
+		ctx.emit(&traceviewer.Event{
+			Name:  name,
+			Phase: "X",
+			Time:  float64(r.StartTime) / 1e3,
+			Dur:   float64(r.EndTime-r.StartTime) / 1e3,
+			PID:   chansSection,
+			TID:   r.Chan,
+			Cname: color,
+		})
+	}
+}
+
 type heapCountersArg struct {
 	Allocated uint64
 	NextGC    uint64
@@ -1055,11 +1109,18 @@ func (ctx *traceContext) emitInstant(ev *trace.Event, name, category string) {
 		}
 	}
 	var arg interface{}
-	if ev.Type == trace.EvProcStart {
+	switch ev.Type {
+	case trace.EvProcStart:
 		type Arg struct {
 			ThreadID uint64
 		}
 		arg = &Arg{ev.Args[0]}
+	case trace.EvChanClose:
+		type Arg struct {
+			Channel string
+			Waiters uint64 `json:"Woken goroutines"`
+		}
+		arg = &Arg{ev.SArgs[0], ev.Args[1]}
 	}
 	ctx.emit(&traceviewer.Event{
 		Name:     name,
@@ -1104,8 +1165,17 @@ func (ctx *traceContext) emitArrow(ev *trace.Event, name string) {
 		}
 	}
 
+	// include what woke a goroutine blocked on a channel.
+	var arg interface{}
+	if ev.Type == trace.EvGoUnblock && ev.SArgs[0] != "" {
+		type Arg struct {
+			Cause string `json:"Woken by"`
+		}
+		arg = &Arg{ev.SArgs[0]}
+	}
+
 	ctx.arrowSeq++
-	ctx.emit(&traceviewer.Event{Name: name, Phase: "s", TID: ctx.proc(ev), ID: ctx.arrowSeq, Time: ctx.time(ev), Stack: ctx.stack(ev.Stk), Cname: color})
+	ctx.emit(&traceviewer.Event{Name: name, Phase: "s", TID: ctx.proc(ev), ID: ctx.arrowSeq, Time: ctx.time(ev), Stack: ctx.stack(ev.Stk), Cname: color, Arg: arg})
 	ctx.emit(&traceviewer.Event{Name: name, Phase: "t", TID: ctx.proc(ev.Link), ID: ctx.arrowSeq, Time: ctx.time(ev.Link), Cname: color})
 }
 
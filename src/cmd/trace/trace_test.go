@@ -268,3 +268,97 @@ func TestDirectSemaphoreHandoff(t *testing.T) {
 		t.Fatalf("failed to parse the trace: %v", err)
 	}
 }
+
+func TestAnalyzeSelects(t *testing.T) {
+	// A select with a send, two receives, and a default, and the
+	// same select called through a different path.
+	site := []*trace.Frame{{PC: 10, Fn: "main.f", File: "f.go", Line: 3}}
+	other := []*trace.Frame{{PC: 20, Fn: "main.g", File: "g.go", Line: 7}}
+	sel := func(stk []*trace.Frame, casi int) *trace.Event {
+		return &trace.Event{Type: trace.EvSelect, Stk: stk, Args: [3]uint64{uint64(casi + 1), 1, 2}}
+	}
+	// selectgo numbers the receives in reverse, so case 2 is the
+	// first receive written and case 1 the second.
+	events := []*trace.Event{
+		sel(site, 2), sel(site, 2), sel(site, 2), sel(site, 1), sel(site, 0), sel(site, -1),
+		sel(other, 0),
+	}
+	got := analyzeSelects(events)
+	if len(got) != 2 {
+		t.Fatalf("got %d sites, want 2", len(got))
+	}
+	s := got[0]
+	if s.PC != 10 || s.N != 6 {
+		t.Errorf("first site is PC %d with %d executions, want PC 10 with 6", s.PC, s.N)
+	}
+	want := []selectCase{{"send 0", 1}, {"receive 0", 3}, {"receive 1", 1}, {"default", 1}}
+	if len(s.Cases) != len(want) {
+		t.Fatalf("got cases %v, want %v", s.Cases, want)
+	}
+	for i := range want {
+		if s.Cases[i] != want[i] {
+			t.Errorf("got cases %v, want %v", s.Cases, want)
+			break
+		}
+	}
+	if p := s.Percent(s.Cases[1]); p != "50.0%" {
+		t.Errorf("share of %s is %s, want 50.0%%", s.Cases[1].Name, p)
+	}
+	if s := got[1]; s.PC != 20 || s.N != 1 || len(s.Cases) != 3 || s.Cases[0].N != 1 {
+		t.Errorf("second site is %+v, want PC 20 with one send", s)
+	}
+}
+
+type chanBufElem int
+
+func TestChanBufRanges(t *testing.T) {
+	prog0 := func() {
+		c := make(chan chanBufElem, 2)
+		c <- 1
+		c <- 2
+		<-c
+		<-c
+	}
+	if err := traceProgram(t, prog0, "TestChanBufRanges"); err != nil {
+		t.Fatalf("failed to trace the program: %v", err)
+	}
+	res, err := parseTrace()
+	if err != nil {
+		t.Fatalf("failed to parse the trace: %v", err)
+	}
+
+	params := &traceParams{
+		parsed:  res,
+		endTime: int64(1<<63 - 1),
+	}
+	c := viewerDataTraceConsumer(io.Discard, 0, 1<<63-1)
+
+	// The channel's row, and the slices in it.
+	var row uint64
+	var slices []string
+	c.consumeViewerEvent = func(ev *traceviewer.Event, _ bool) {
+		if ev.PID != chansSection {
+			return
+		}
+		switch {
+		case ev.Name == "thread_name":
+			if strings.HasSuffix(ev.Arg.(*NameArg).Name, " chan main.chanBufElem (cap 2)") {
+				row = ev.TID
+			}
+		case ev.Phase == "X":
+			if row == ev.TID {
+				slices = append(slices, ev.Name+" "+ev.Cname)
+			}
+		}
+	}
+	if err := generateTrace(params, c); err != nil {
+		t.Fatalf("generateTrace failed: %v", err)
+	}
+	if row == 0 {
+		t.Fatal("no row for the channel")
+	}
+	want := []string{"empty " + colorCornflowerBlue, "full " + colorSunsetOrange, "empty " + colorCornflowerBlue}
+	if strings.Join(slices, ",") != strings.Join(want, ",") {
+		t.Errorf("got slices %q, want %q", slices, want)
+	}
+}
@@ -488,7 +488,7 @@ var depsRules = `
 	OS, compress/gzip, regexp
 	< internal/profile;
 
-	html, internal/profile, net/http, runtime/pprof, runtime/trace
+	html, internal/profile, net/http, runtime/debug, runtime/pprof, runtime/trace
 	< net/http/pprof;
 
 	# RPC
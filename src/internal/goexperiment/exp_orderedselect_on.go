@@ -0,0 +1,9 @@
+// Code generated by mkconsts.go. DO NOT EDIT.
+
+//go:build goexperiment.orderedselect
+// +build goexperiment.orderedselect
+
+package goexperiment
+
+const OrderedSelect = true
+const OrderedSelectInt = 1
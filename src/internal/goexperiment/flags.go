@@ -59,6 +59,13 @@ type Flags struct {
 	PreemptibleLoops  bool
 	StaticLockRanking bool
 
+	// OrderedSelect makes select statements with several ready
+	// cases proceed with the first ready case in the order they
+	// are listed, instead of choosing one at random.
+	// A select that blocks still proceeds with whichever case
+	// becomes ready first.
+	OrderedSelect bool
+
 	// Regabi is split into several sub-experiments that can be
 	// enabled individually. Not all combinations work.
 	// The "regabi" GOEXPERIMENT is an alias for all "working"
@@ -57,6 +57,8 @@ func (pd *pollDesc) waitCanceled(mode int) {}
 
 func (pd *pollDesc) pollable() bool { return true }
 
+func (pd *pollDesc) setNotify(mode int, c chan struct{}, isFile bool) error { return ErrNotPollable }
+
 // SetDeadline sets the read and write deadlines associated with fd.
 func (fd *FD) SetDeadline(t time.Time) error {
 	return setDeadlineImpl(fd, t, 'r'+'w')
@@ -28,6 +28,7 @@ func runtime_pollReset(ctx uintptr, mode int) int
 func runtime_pollSetDeadline(ctx uintptr, d int64, mode int)
 func runtime_pollUnblock(ctx uintptr)
 func runtime_isPollServerDescriptor(fd uintptr) bool
+func runtime_pollSetNotify(ctx uintptr, mode int, c chan struct{}) int
 
 type pollDesc struct {
 	runtimeCtx uintptr
@@ -104,6 +105,16 @@ func (pd *pollDesc) pollable() bool {
 	return pd.runtimeCtx != 0
 }
 
+// setNotify arranges for the runtime poller to send on c whenever the
+// descriptor becomes ready in mode. See runtime_pollSetNotify.
+func (pd *pollDesc) setNotify(mode int, c chan struct{}, isFile bool) error {
+	if pd.runtimeCtx == 0 {
+		return ErrNotPollable
+	}
+	res := runtime_pollSetNotify(pd.runtimeCtx, mode, c)
+	return convertErr(res, isFile)
+}
+
 // Error values returned by runtime_pollReset and runtime_pollWait.
 // These must match the values in runtime/netpoll.go.
 const (
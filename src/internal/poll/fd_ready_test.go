@@ -0,0 +1,84 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris
+
+package poll_test
+
+import (
+	. "internal/poll"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestReadReady(t *testing.T) {
+	var p [2]int
+	if err := syscall.Pipe(p[:]); err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(p[1])
+	if err := syscall.SetNonblock(p[0], true); err != nil {
+		t.Fatal(err)
+	}
+	fd := &FD{Sysfd: p[0], IsStream: true}
+	if err := fd.Init("file", true); err != nil {
+		t.Skipf("pipe not pollable: %v", err)
+	}
+	defer fd.Close()
+
+	ready, err := fd.ReadReady()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := fd.ReadReady(); again != ready {
+		t.Errorf("second ReadReady returned a different channel")
+	}
+
+	// The first value only says that fd may be ready.
+	select {
+	case <-ready:
+	case <-time.After(10 * time.Second):
+		t.Fatal("no initial notification")
+	}
+
+	other := make(chan int)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		if _, err := syscall.Write(p[1], []byte("x")); err != nil {
+			t.Error(err)
+		}
+	}()
+	select {
+	case <-ready:
+	case <-other:
+		t.Fatal("unreachable")
+	case <-time.After(10 * time.Second):
+		t.Fatal("no notification for readable pipe")
+	}
+	var buf [1]byte
+	if n, err := fd.Read(buf[:]); n != 1 || err != nil {
+		t.Fatalf("Read = %d, %v; want 1, nil", n, err)
+	}
+}
+
+func TestReadReadyClosed(t *testing.T) {
+	var p [2]int
+	if err := syscall.Pipe(p[:]); err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(p[1])
+	if err := syscall.SetNonblock(p[0], true); err != nil {
+		t.Fatal(err)
+	}
+	fd := &FD{Sysfd: p[0], IsStream: true}
+	if err := fd.Init("file", true); err != nil {
+		t.Skipf("pipe not pollable: %v", err)
+	}
+	fd.Close()
+	if _, err := fd.ReadReady(); err == nil {
+		t.Error("ReadReady on closed FD succeeded")
+	}
+}
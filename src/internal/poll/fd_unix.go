@@ -9,6 +9,7 @@ package poll
 
 import (
 	"io"
+	"sync"
 	"sync/atomic"
 	"syscall"
 )
@@ -44,6 +45,12 @@ type FD struct {
 
 	// Whether this is a file rather than a network socket.
 	isFile bool
+
+	// Readiness notification channels, created on first use by
+	// ReadReady and WriteReady. Guarded by readyMu.
+	readyMu    sync.Mutex
+	readReady  chan struct{}
+	writeReady chan struct{}
 }
 
 // Init initializes the FD. The Sysfd field should already be set.
@@ -585,3 +592,44 @@ func ignoringEINTRIO(fn func(fd int, p []byte) (int, error), fd int, p []byte) (
 		}
 	}
 }
+
+// ReadReady returns a channel that receives a value when fd may have
+// become ready for reading, so that fd can be waited for in a select
+// statement together with other channels. A value is sent when the
+// channel is created and after each readiness event reported by the
+// runtime poller; at most one value is pending at a time. A receive
+// is only a hint: the caller should read until the read would block
+// before waiting on the channel again.
+// The channel is never closed. No more values are sent after fd is closed.
+// This is an experimental interface for use by the net and os packages.
+func (fd *FD) ReadReady() (<-chan struct{}, error) {
+	return fd.readyChan('r')
+}
+
+// WriteReady is like ReadReady, but for readiness for writing.
+func (fd *FD) WriteReady() (<-chan struct{}, error) {
+	return fd.readyChan('w')
+}
+
+func (fd *FD) readyChan(mode int) (<-chan struct{}, error) {
+	if err := fd.incref(); err != nil {
+		return nil, err
+	}
+	defer fd.decref()
+	fd.readyMu.Lock()
+	defer fd.readyMu.Unlock()
+	cp := &fd.readReady
+	if mode == 'w' {
+		cp = &fd.writeReady
+	}
+	if *cp == nil {
+		// The runtime doesn't keep c alive; storing it in fd does,
+		// until the notification is cancelled when fd is closed.
+		c := make(chan struct{}, 1)
+		if err := fd.pd.setNotify(mode, c, fd.isFile); err != nil {
+			return nil, err
+		}
+		*cp = c
+	}
+	return *cp, nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace
+
+import "sort"
+
+// States of a channel's buffer, the arguments of EvChanBuf events.
+const (
+	ChanBufPartial = 0 // some values buffered, with room for more
+	ChanBufFull    = 1
+	ChanBufEmpty   = 2
+)
+
+// A ChanBufRange is a period during which the buffer of a channel was
+// full, so that senders would block, or empty, so that receivers would.
+type ChanBufRange struct {
+	Chan  uint64 // channel id
+	Desc  string // channel description, as in the string argument of its events
+	State int    // ChanBufFull or ChanBufEmpty
+
+	// Start is the EvChanBuf event that began the range, or nil if
+	// the range began before the trace did. End is the EvChanBuf
+	// event that ended it, or nil if it lasted to the end of the
+	// trace.
+	Start, End *Event
+
+	StartTime, EndTime int64
+}
+
+// ChanBufRanges returns the periods during which the buffers of
+// channels were full or empty, ordered by channel id and then by time.
+// A channel's buffer is known to have been full or empty at the start
+// of the trace only if its first EvChanBuf event says it stopped being
+// so. Events must be in time order, as returned by Parse.
+func ChanBufRanges(events []*Event) []*ChanBufRange {
+	if len(events) == 0 {
+		return nil
+	}
+	startTs, lastTs := events[0].Ts, events[len(events)-1].Ts
+	open := make(map[uint64]*ChanBufRange)
+	seen := make(map[uint64]bool)
+	var ranges []*ChanBufRange
+	for _, ev := range events {
+		if ev.Type != EvChanBuf {
+			continue
+		}
+		id, from, to := ev.Args[0], int(ev.Args[1]), int(ev.Args[2])
+		if r := open[id]; r != nil {
+			r.End, r.EndTime = ev, ev.Ts
+			delete(open, id)
+		} else if !seen[id] && from != ChanBufPartial {
+			ranges = append(ranges, &ChanBufRange{
+				Chan:      id,
+				Desc:      ev.SArgs[0],
+				State:     from,
+				End:       ev,
+				StartTime: startTs,
+				EndTime:   ev.Ts,
+			})
+		}
+		seen[id] = true
+		if to != ChanBufPartial {
+			r := &ChanBufRange{Chan: id, Desc: ev.SArgs[0], State: to, Start: ev, StartTime: ev.Ts}
+			open[id] = r
+			ranges = append(ranges, r)
+		}
+	}
+	for _, r := range open {
+		r.EndTime = lastTs
+	}
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].Chan < ranges[j].Chan
+	})
+	return ranges
+}
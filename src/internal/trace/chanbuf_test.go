@@ -0,0 +1,52 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace
+
+import "testing"
+
+func TestChanBufRanges(t *testing.T) {
+	buf := func(ts int64, id uint64, from, to int) *Event {
+		return &Event{Ts: ts, Type: EvChanBuf, Args: [3]uint64{id, uint64(from), uint64(to)}, SArgs: []string{"chan int (cap 2)"}}
+	}
+	events := []*Event{
+		{Ts: 0, Type: EvBatch},
+		// Channel 2 was full when the trace started.
+		buf(10, 2, ChanBufFull, ChanBufPartial),
+		buf(20, 1, ChanBufEmpty, ChanBufPartial),
+		buf(30, 1, ChanBufPartial, ChanBufFull),
+		buf(40, 2, ChanBufPartial, ChanBufEmpty),
+		buf(50, 1, ChanBufFull, ChanBufPartial),
+		// Channel 3 holds one value, so it goes straight from
+		// empty to full.
+		buf(60, 3, ChanBufEmpty, ChanBufFull),
+		{Ts: 100, Type: EvGoSched},
+	}
+	type want struct {
+		id         uint64
+		state      int
+		start, end int64
+		startEv    bool
+		endEv      bool
+	}
+	wants := []want{
+		{1, ChanBufEmpty, 0, 20, false, true},
+		{1, ChanBufFull, 30, 50, true, true},
+		{2, ChanBufFull, 0, 10, false, true},
+		{2, ChanBufEmpty, 40, 100, true, false},
+		{3, ChanBufEmpty, 0, 60, false, true},
+		{3, ChanBufFull, 60, 100, true, false},
+	}
+	got := ChanBufRanges(events)
+	if len(got) != len(wants) {
+		t.Fatalf("got %d ranges, want %d", len(got), len(wants))
+	}
+	for i, w := range wants {
+		r := got[i]
+		if r.Chan != w.id || r.State != w.state || r.StartTime != w.start || r.EndTime != w.end ||
+			(r.Start != nil) != w.startEv || (r.End != nil) != w.endEv || r.Desc != "chan int (cap 2)" {
+			t.Errorf("range %d is %+v, want %+v", i, r, w)
+		}
+	}
+}
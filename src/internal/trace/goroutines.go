@@ -54,6 +54,31 @@ type GExecutionStat struct {
 	GCTime        int64
 	SweepTime     int64
 	TotalTime     int64
+
+	// BlockTime broken down by the cause of the blocking: the time
+	// spent blocked sending on a channel, receiving from a channel,
+	// in a select statement, and on a sync.Mutex, sync.RWMutex or
+	// sync.Cond. They add up to BlockTime.
+	ChanSendTime int64
+	ChanRecvTime int64
+	SelectTime   int64
+	MutexTime    int64
+}
+
+// addBlockTime adds d to the time spent blocked on a synchronization
+// primitive, in the blocking event of type typ.
+func (s *GExecutionStat) addBlockTime(typ byte, d int64) {
+	s.BlockTime += d
+	switch typ {
+	case EvGoBlockSend:
+		s.ChanSendTime += d
+	case EvGoBlockRecv:
+		s.ChanRecvTime += d
+	case EvGoBlockSelect:
+		s.SelectTime += d
+	case EvGoBlockSync, EvGoBlockCond:
+		s.MutexTime += d
+	}
 }
 
 // sub returns the stats v-s.
@@ -67,6 +92,10 @@ func (s GExecutionStat) sub(v GExecutionStat) (r GExecutionStat) {
 	r.GCTime -= v.GCTime
 	r.SweepTime -= v.SweepTime
 	r.TotalTime -= v.TotalTime
+	r.ChanSendTime -= v.ChanSendTime
+	r.ChanRecvTime -= v.ChanRecvTime
+	r.SelectTime -= v.SelectTime
+	r.MutexTime -= v.MutexTime
 	return r
 }
 
@@ -102,7 +131,7 @@ func (g *GDesc) snapshotStat(lastTs, activeGCStartTime int64) (ret GExecutionSta
 		ret.IOTime += lastTs - g.blockNetTime
 	}
 	if g.blockSyncTime != 0 {
-		ret.BlockTime += lastTs - g.blockSyncTime
+		ret.addBlockTime(g.blockSyncType, lastTs-g.blockSyncTime)
 	}
 	if g.blockSyscallTime != 0 {
 		ret.SyscallTime += lastTs - g.blockSyscallTime
@@ -139,6 +168,7 @@ type gdesc struct {
 	lastStartTime    int64
 	blockNetTime     int64
 	blockSyncTime    int64
+	blockSyncType    byte // type of the event that started blockSyncTime
 	blockSyscallTime int64
 	blockSweepTime   int64
 	blockGCTime      int64
@@ -195,6 +225,7 @@ func GoroutineStats(events []*Event) map[uint64]*GDesc {
 			g.ExecTime += ev.Ts - g.lastStartTime
 			g.lastStartTime = 0
 			g.blockSyncTime = ev.Ts
+			g.blockSyncType = ev.Type
 		case EvGoSched, EvGoPreempt:
 			g := gs[ev.G]
 			g.ExecTime += ev.Ts - g.lastStartTime
@@ -221,7 +252,7 @@ func GoroutineStats(events []*Event) map[uint64]*GDesc {
 				g.blockNetTime = 0
 			}
 			if g.blockSyncTime != 0 {
-				g.BlockTime += ev.Ts - g.blockSyncTime
+				g.addBlockTime(g.blockSyncType, ev.Ts-g.blockSyncTime)
 				g.blockSyncTime = 0
 			}
 			g.blockSchedTime = ev.Ts
@@ -0,0 +1,74 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package trace
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// blockTimes is the total time the goroutines of a trace spent blocked,
+// by cause.
+type blockTimes struct {
+	block, chanSend, chanRecv, sel, mutex int64
+}
+
+func goroutineBlockTimes(t *testing.T, name string) blockTimes {
+	data, err := os.ReadFile(filepath.Join("./testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, res, err := parse(bytes.NewReader(data), "")
+	if err != nil {
+		t.Fatalf("failed to parse %s: %v", name, err)
+	}
+	var bt blockTimes
+	check := func(what string, s GExecutionStat) {
+		if sum := s.ChanSendTime + s.ChanRecvTime + s.SelectTime + s.MutexTime; sum != s.BlockTime {
+			t.Errorf("%s: %s has BlockTime %d, but its causes add up to %d: %+v", name, what, s.BlockTime, sum, s)
+		}
+	}
+	for _, g := range GoroutineStats(res.Events) {
+		check("goroutine "+g.Name, g.GExecutionStat)
+		for _, r := range g.Regions {
+			check("region "+r.Name, r.GExecutionStat)
+		}
+		bt.block += g.BlockTime
+		bt.chanSend += g.ChanSendTime
+		bt.chanRecv += g.ChanRecvTime
+		bt.sel += g.SelectTime
+		bt.mutex += g.MutexTime
+	}
+	return bt
+}
+
+func TestGoroutineStatsBlockCauses(t *testing.T) {
+	// Golden totals for canned traces. The select in the 1.5 HTTP
+	// trace and the channel sends and mutexes of the 1.5 stress
+	// traces exercise every cause.
+	golden := []struct {
+		name string
+		want blockTimes
+	}{
+		{"http_1_5_good", blockTimes{21022821, 0, 7756982, 11496942, 1768897}},
+		{"http_1_11_good", blockTimes{3444, 3444, 0, 0, 0}},
+		{"stress_1_5_good", blockTimes{8257058, 0, 8226563, 0, 30495}},
+		{"stress_1_11_good", blockTimes{27301457, 0, 24487986, 0, 2813471}},
+		{"stress_start_stop_1_5_good", blockTimes{15780496, 3779543, 11996907, 0, 4046}},
+		{"stress_start_stop_1_11_good", blockTimes{8798, 0, 0, 0, 8798}},
+		{"user_task_span_1_11_good", blockTimes{18051, 0, 0, 0, 18051}},
+	}
+	for _, tc := range golden {
+		if testing.Short() && strings.HasPrefix(tc.name, "stress_1_") {
+			continue
+		}
+		if got := goroutineBlockTimes(t, tc.name); got != tc.want {
+			t.Errorf("%s: got block times %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
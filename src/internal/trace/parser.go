@@ -150,7 +150,7 @@ func readTrace(r io.Reader) (ver int, events []rawEvent, strings map[uint64]stri
 		return
 	}
 	switch ver {
-	case 1005, 1007, 1008, 1009, 1010, 1011:
+	case 1005, 1007, 1008, 1009, 1010, 1011, 1017:
 		// Note: When adding a new version, add canned traces
 		// from the old version to the test suite using mkcanned.bash.
 		break
@@ -439,6 +439,18 @@ func parseEvents(ver int, rawEvents []rawEvent, strings map[uint64]string) (even
 				lastG = 0
 			case EvGoSysExit, EvGoWaiting, EvGoInSyscall:
 				e.G = e.Args[0]
+			case EvGoUnblock, EvGoUnblockLocal:
+				if raw.typ == EvGoUnblockLocal {
+					// Move the cause to where it is in
+					// EvGoUnblock, which this event
+					// becomes once ordered.
+					e.Args[1], e.Args[2] = 0, e.Args[1]
+				}
+				if e.Args[2] >= uint64(len(unblockCauses)) {
+					err = fmt.Errorf("unknown unblock cause %d", e.Args[2])
+					return
+				}
+				e.SArgs = []string{unblockCauses[e.Args[2]]}
 			case EvUserTaskCreate:
 				// e.Args 0: taskID, 1:parentID, 2:nameID
 				e.SArgs = []string{strings[e.Args[2]]}
@@ -448,6 +460,12 @@ func parseEvents(ver int, rawEvents []rawEvent, strings map[uint64]string) (even
 			case EvUserLog:
 				// e.Args 0: taskID, 1:keyID, 2: stackID
 				e.SArgs = []string{strings[e.Args[1]], raw.sargs[0]}
+			case EvChan:
+				// e.Args 0: chan id, 1: capacity, 2: elemID
+				e.SArgs = []string{strings[e.Args[2]]}
+			case EvChanLabel:
+				// e.Args 0: chan id, 1: labelID
+				e.SArgs = []string{strings[e.Args[1]]}
 			}
 			batches[lastP] = append(batches[lastP], e)
 		}
@@ -479,6 +497,8 @@ func parseEvents(ver int, rawEvents []rawEvent, strings map[uint64]string) (even
 		return
 	}
 
+	attachChans(events)
+
 	// Translate cpu ticks to real time.
 	minTs := events[0].Ts
 	// Use floating point to avoid integer overflows.
@@ -497,6 +517,58 @@ func parseEvents(ver int, rawEvents []rawEvent, strings map[uint64]string) (even
 	return
 }
 
+// unblockCauses are the string arguments of EvGoUnblock events, by
+// their cause argument. The cause is "" unless the goroutine was
+// woken by a channel operation.
+var unblockCauses = [...]string{"", "send", "receive", "close"}
+
+// attachChans sets the string argument of events that refer to a
+// channel to the channel's description, such as "chan int",
+// "chan *http.Request (cap 10)" or "chan int (label "jobs")", taken
+// from the channel's EvChan event and its latest EvChanLabel event.
+// Events must be in time order.
+func attachChans(events []*Event) {
+	type chanDesc struct {
+		elem  string
+		cap   uint64
+		label string
+		desc  string
+	}
+	chans := make(map[uint64]*chanDesc)
+	describe := func(c *chanDesc) {
+		c.desc = "chan " + c.elem
+		switch {
+		case c.cap != 0 && c.label != "":
+			c.desc += fmt.Sprintf(" (cap %d, label %q)", c.cap, c.label)
+		case c.cap != 0:
+			c.desc += fmt.Sprintf(" (cap %d)", c.cap)
+		case c.label != "":
+			c.desc += fmt.Sprintf(" (label %q)", c.label)
+		}
+	}
+	for _, ev := range events {
+		switch ev.Type {
+		case EvChan:
+			c := &chanDesc{elem: ev.SArgs[0], cap: ev.Args[1]}
+			describe(c)
+			chans[ev.Args[0]] = c
+		case EvChanLabel:
+			if c := chans[ev.Args[0]]; c != nil {
+				c.label = ev.SArgs[0]
+				describe(c)
+			}
+		case EvGoBlockSend, EvGoBlockRecv, EvChanClose, EvChanBuf:
+			// Traces before 1.17 have no channel ids, so
+			// this is "" for them.
+			desc := ""
+			if c := chans[ev.Args[0]]; c != nil {
+				desc = c.desc
+			}
+			ev.SArgs = []string{desc}
+		}
+	}
+}
+
 // removeFutile removes all constituents of futile wakeups (block, unblock, start).
 // For example, a goroutine was unblocked on a mutex, but another goroutine got
 // ahead and acquired the mutex before the first goroutine is scheduled,
@@ -991,14 +1063,33 @@ func argNum(raw rawEvent, ver int) int {
 		if ver < 1009 {
 			narg -= 2 // 1.9 added two arguments
 		}
-	case EvGCStart, EvGoStart, EvGoUnblock:
+	case EvGCStart, EvGoStart:
+		if ver < 1007 {
+			narg-- // 1.7 added an additional seq arg
+		}
+	case EvGoUnblock:
 		if ver < 1007 {
 			narg-- // 1.7 added an additional seq arg
 		}
+		if ver < 1017 {
+			narg-- // 1.17 added the cause
+		}
 	case EvGCSTWStart:
 		if ver < 1010 {
 			narg-- // 1.10 added an argument
 		}
+	case EvGoBlockSend, EvGoBlockRecv:
+		if ver < 1017 {
+			narg -= 2 // 1.17 added the channel id and the task id
+		}
+	case EvGoBlockSelect:
+		if ver < 1017 {
+			narg-- // 1.17 added the task id
+		}
+	case EvGoUnblockLocal:
+		if ver < 1017 {
+			narg-- // 1.17 added the cause
+		}
 	}
 	return narg
 }
@@ -1030,10 +1121,10 @@ const (
 	EvGoPreempt         = 18 // goroutine is preempted [timestamp, stack]
 	EvGoSleep           = 19 // goroutine calls Sleep [timestamp, stack]
 	EvGoBlock           = 20 // goroutine blocks [timestamp, stack]
-	EvGoUnblock         = 21 // goroutine is unblocked [timestamp, goroutine id, seq, stack]
-	EvGoBlockSend       = 22 // goroutine blocks on chan send [timestamp, stack]
-	EvGoBlockRecv       = 23 // goroutine blocks on chan recv [timestamp, stack]
-	EvGoBlockSelect     = 24 // goroutine blocks on select [timestamp, stack]
+	EvGoUnblock         = 21 // goroutine is unblocked [timestamp, goroutine id, seq, cause, stack]
+	EvGoBlockSend       = 22 // goroutine blocks on chan send [timestamp, chan id, task id, stack]
+	EvGoBlockRecv       = 23 // goroutine blocks on chan recv [timestamp, chan id, task id, stack]
+	EvGoBlockSelect     = 24 // goroutine blocks on select [timestamp, task id, stack]
 	EvGoBlockSync       = 25 // goroutine blocks on Mutex/RWMutex [timestamp, stack]
 	EvGoBlockCond       = 26 // goroutine blocks on Cond [timestamp, stack]
 	EvGoBlockNet        = 27 // goroutine blocks on network [timestamp, stack]
@@ -1048,7 +1139,7 @@ const (
 	EvFutileWakeup      = 36 // denotes that the previous wakeup of this goroutine was futile [timestamp]
 	EvString            = 37 // string dictionary entry [ID, length, string]
 	EvGoStartLocal      = 38 // goroutine starts running on the same P as the last event [timestamp, goroutine id]
-	EvGoUnblockLocal    = 39 // goroutine is unblocked on the same P as the last event [timestamp, goroutine id, stack]
+	EvGoUnblockLocal    = 39 // goroutine is unblocked on the same P as the last event [timestamp, goroutine id, cause, stack]
 	EvGoSysExitLocal    = 40 // syscall exit on the same P as the last event [timestamp, goroutine id, real timestamp]
 	EvGoStartLabel      = 41 // goroutine starts running with label [timestamp, goroutine id, seq, label string id]
 	EvGoBlockGC         = 42 // goroutine blocks on GC assist [timestamp, stack]
@@ -1058,7 +1149,12 @@ const (
 	EvUserTaskEnd       = 46 // end of task [timestamp, internal task id, stack]
 	EvUserRegion        = 47 // trace.WithRegion [timestamp, internal task id, mode(0:start, 1:end), stack, name string]
 	EvUserLog           = 48 // trace.Log [timestamp, internal id, key string id, stack, value string]
-	EvCount             = 49
+	EvChan              = 49 // channel description [timestamp, chan id, capacity, element type string id]
+	EvChanClose         = 50 // channel is closed [timestamp, chan id, waiters, stack]
+	EvSelect            = 51 // select statement completes [timestamp, case, number of sends, number of receives, stack]
+	EvChanLabel         = 52 // channel label is set [timestamp, chan id, label string id]
+	EvChanBuf           = 53 // channel buffer becomes full or empty, or stops being so [timestamp, chan id, old state, new state]
+	EvCount             = 54
 )
 
 var EventDescriptions = [EvCount]struct {
@@ -1089,10 +1185,10 @@ var EventDescriptions = [EvCount]struct {
 	EvGoPreempt:         {"GoPreempt", 1005, true, []string{}, nil},
 	EvGoSleep:           {"GoSleep", 1005, true, []string{}, nil},
 	EvGoBlock:           {"GoBlock", 1005, true, []string{}, nil},
-	EvGoUnblock:         {"GoUnblock", 1005, true, []string{"g", "seq"}, nil}, // in 1.5 format it was {"g"}
-	EvGoBlockSend:       {"GoBlockSend", 1005, true, []string{}, nil},
-	EvGoBlockRecv:       {"GoBlockRecv", 1005, true, []string{}, nil},
-	EvGoBlockSelect:     {"GoBlockSelect", 1005, true, []string{}, nil},
+	EvGoUnblock:         {"GoUnblock", 1005, true, []string{"g", "seq", "causeid"}, []string{"cause"}}, // in 1.5 format it was {"g"}, before 1.17 {"g", "seq"}
+	EvGoBlockSend:       {"GoBlockSend", 1005, true, []string{"chan", "taskid"}, []string{"chan"}},
+	EvGoBlockRecv:       {"GoBlockRecv", 1005, true, []string{"chan", "taskid"}, []string{"chan"}},
+	EvGoBlockSelect:     {"GoBlockSelect", 1005, true, []string{"taskid"}, nil},
 	EvGoBlockSync:       {"GoBlockSync", 1005, true, []string{}, nil},
 	EvGoBlockCond:       {"GoBlockCond", 1005, true, []string{}, nil},
 	EvGoBlockNet:        {"GoBlockNet", 1005, true, []string{}, nil},
@@ -1107,7 +1203,7 @@ var EventDescriptions = [EvCount]struct {
 	EvFutileWakeup:      {"FutileWakeup", 1005, false, []string{}, nil},
 	EvString:            {"String", 1007, false, []string{}, nil},
 	EvGoStartLocal:      {"GoStartLocal", 1007, false, []string{"g"}, nil},
-	EvGoUnblockLocal:    {"GoUnblockLocal", 1007, true, []string{"g"}, nil},
+	EvGoUnblockLocal:    {"GoUnblockLocal", 1007, true, []string{"g", "causeid"}, []string{"cause"}}, // before 1.17 it was {"g"}
 	EvGoSysExitLocal:    {"GoSysExitLocal", 1007, false, []string{"g", "ts"}, nil},
 	EvGoStartLabel:      {"GoStartLabel", 1008, false, []string{"g", "seq", "labelid"}, []string{"label"}},
 	EvGoBlockGC:         {"GoBlockGC", 1008, true, []string{}, nil},
@@ -1117,4 +1213,9 @@ var EventDescriptions = [EvCount]struct {
 	EvUserTaskEnd:       {"UserTaskEnd", 1011, true, []string{"taskid"}, nil},
 	EvUserRegion:        {"UserRegion", 1011, true, []string{"taskid", "mode", "typeid"}, []string{"name"}},
 	EvUserLog:           {"UserLog", 1011, true, []string{"id", "keyid"}, []string{"category", "message"}},
+	EvChan:              {"Chan", 1017, false, []string{"chan", "cap", "elemid"}, []string{"elem"}},
+	EvChanClose:         {"ChanClose", 1017, true, []string{"chan", "waiters"}, []string{"chan"}},
+	EvSelect:            {"Select", 1017, true, []string{"case", "sends", "recvs"}, nil},
+	EvChanLabel:         {"ChanLabel", 1017, false, []string{"chan", "labelid"}, []string{"label"}},
+	EvChanBuf:           {"ChanBuf", 1017, false, []string{"chan", "from", "to"}, []string{"chan"}},
 }
@@ -41,6 +41,21 @@
 //
 //	go tool pprof http://localhost:6060/debug/pprof/mutex
 //
+// Or to see which groups of goroutines are piled up on a single
+// channel rather than each waiting on their own:
+//
+//	curl http://localhost:6060/debug/pprof/goroutine?chans=1
+//
+// Or, in a program run with GODEBUG=chanregistry=1, to list its live
+// channels with the most waited-on first:
+//
+//	curl http://localhost:6060/debug/pprof/channels?sort=waiters
+//
+// Or to dump the stacks of all goroutines, printing each group of
+// goroutines with the same stack and wait reason only once:
+//
+//	curl http://localhost:6060/debug/pprof/goroutine?debug=4
+//
 // The package also exports a handler that serves execution trace data
 // for the "go tool trace" command. To collect a 5-second execution trace:
 //
@@ -69,16 +84,19 @@ import (
 	"net/url"
 	"os"
 	"runtime"
+	"runtime/debug"
 	"runtime/pprof"
 	"runtime/trace"
 	"sort"
 	"strconv"
 	"strings"
+	"text/tabwriter"
 	"time"
 )
 
 func init() {
 	http.HandleFunc("/debug/pprof/", Index)
+	http.HandleFunc("/debug/pprof/channels", Channels)
 	http.HandleFunc("/debug/pprof/cmdline", Cmdline)
 	http.HandleFunc("/debug/pprof/profile", Profile)
 	http.HandleFunc("/debug/pprof/symbol", Symbol)
@@ -94,6 +112,52 @@ func Cmdline(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprint(w, strings.Join(os.Args, "\x00"))
 }
 
+// Channels responds with a table of the program's live channels,
+// one row per channel, taken from the channel registry enabled by
+// running the program with GODEBUG=chanregistry=1.
+// Channels are listed oldest first, or by the number of goroutines
+// blocked on them if the sort GET parameter is "waiters".
+// The package initialization registers it as /debug/pprof/channels.
+func Channels(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	chans, ok := debug.DumpChannels()
+	if !ok {
+		serveError(w, http.StatusNotFound,
+			"Channel registry is disabled. Run the program with GODEBUG=chanregistry=1 to enable it.")
+		return
+	}
+	switch r.FormValue("sort") {
+	case "", "age":
+	case "waiters":
+		sort.SliceStable(chans, func(i, j int) bool {
+			return chans[i].Senders+chans[i].Receivers > chans[j].Senders+chans[j].Receivers
+		})
+	default:
+		serveError(w, http.StatusBadRequest, `Invalid sort: must be "age" or "waiters"`)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "channels: total %d\n", len(chans))
+	tw := tabwriter.NewWriter(w, 1, 8, 1, ' ', 0)
+	fmt.Fprint(tw, "type\tcap\tlen\tclosed\tsenders\treceivers\tage\tcreated at\n")
+	for _, c := range chans {
+		fmt.Fprintf(tw, "chan %s\t%d\t%d\t%v\t%d\t%d\t%v\t%s\n",
+			c.Elem, c.Cap, c.Len, c.Closed, c.Senders, c.Receivers,
+			c.Age.Round(time.Millisecond), creationSite(c.CreationPC))
+	}
+	tw.Flush()
+}
+
+// creationSite formats the location of the creation PC of a channel.
+func creationSite(pc uintptr) string {
+	if pc == 0 {
+		return "?"
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	return fmt.Sprintf("%s %s:%d", frame.Function, frame.File, frame.Line)
+}
+
 func sleep(r *http.Request, d time.Duration) {
 	select {
 	case <-time.After(d):
@@ -244,6 +308,9 @@ func (name handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		runtime.GC()
 	}
 	debug, _ := strconv.Atoi(r.FormValue("debug"))
+	if chans, _ := strconv.Atoi(r.FormValue("chans")); name == "goroutine" && chans > 0 {
+		debug = 3
+	}
 	if debug != 0 {
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	} else {
@@ -335,6 +402,7 @@ func collectProfile(p *pprof.Profile) (*profile.Profile, error) {
 var profileSupportsDelta = map[handler]bool{
 	"allocs":       true,
 	"block":        true,
+	"chanops":      true,
 	"goroutine":    true,
 	"heap":         true,
 	"mutex":        true,
@@ -344,8 +412,10 @@ var profileSupportsDelta = map[handler]bool{
 var profileDescriptions = map[string]string{
 	"allocs":       "A sampling of all past memory allocations",
 	"block":        "Stack traces that led to blocking on synchronization primitives",
+	"chanops":      "A sampling of channel operations, blocking or not, with the kind of each. Requires GODEBUG=chanprofrate=N to sample every Nth operation.",
+	"channels":     "A table of live channels with their waiters and creation sites. Requires GODEBUG=chanregistry=1. You can specify sort=waiters to list the most waited-on channels first.",
 	"cmdline":      "The command line invocation of the current program",
-	"goroutine":    "Stack traces of all current goroutines",
+	"goroutine":    "Stack traces of all current goroutines. You can specify the chans GET parameter to annotate each group of goroutines with the channels they are blocked on.",
 	"heap":         "A sampling of memory allocations of live objects. You can specify the gc GET parameter to run GC before taking the heap sample.",
 	"mutex":        "Stack traces of holders of contended mutexes",
 	"profile":      "CPU profile. You can specify the duration in the seconds GET parameter. After you get the profile file, use the go tool pprof command to investigate the profile.",
@@ -387,7 +457,7 @@ func Index(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Adding other profiles exposed from within this package
-	for _, p := range []string{"cmdline", "profile", "trace"} {
+	for _, p := range []string{"channels", "cmdline", "profile", "trace"} {
 		profiles = append(profiles, profileEntry{
 			Name: p,
 			Href: p,
@@ -432,6 +502,8 @@ Types of profiles available:
 	b.WriteString(`</table>
 <a href="goroutine?debug=2">full goroutine stack dump</a>
 <br>
+<a href="goroutine?debug=4">grouped goroutine stack dump</a>
+<br>
 <p>
 Profile Descriptions:
 <ul>
@@ -49,6 +49,9 @@ func TestHandlers(t *testing.T) {
 		{"/debug/pprof/trace", Trace, http.StatusOK, "application/octet-stream", `attachment; filename="trace"`, nil},
 		{"/debug/pprof/mutex", Index, http.StatusOK, "application/octet-stream", `attachment; filename="mutex"`, nil},
 		{"/debug/pprof/block?seconds=1", Index, http.StatusOK, "application/octet-stream", `attachment; filename="block-delta"`, nil},
+		{"/debug/pprof/goroutine?chans=1", Index, http.StatusOK, "text/plain; charset=utf-8", "", nil},
+		{"/debug/pprof/goroutine?debug=4", Index, http.StatusOK, "text/plain; charset=utf-8", "", nil},
+		{"/debug/pprof/channels", Channels, http.StatusNotFound, "text/plain; charset=utf-8", "", []byte("Channel registry is disabled. Run the program with GODEBUG=chanregistry=1 to enable it.\n")},
 		{"/debug/pprof/goroutine?seconds=1", Index, http.StatusOK, "application/octet-stream", `attachment; filename="goroutine-delta"`, nil},
 		{"/debug/pprof/", Index, http.StatusOK, "text/html; charset=utf-8", "", []byte("Types of profiles available:")},
 	}
@@ -0,0 +1,106 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package signal
+
+import "os"
+
+// A Set delivers incoming signals through a channel that can be used in
+// a select statement alongside other channels, without the goroutine
+// and the buffered channel that Notify requires:
+//
+//	sigs := signal.NewSet(syscall.SIGTERM, os.Interrupt)
+//	defer sigs.Stop()
+//	for {
+//		select {
+//		case req := <-work:
+//			handle(req)
+//		case <-sigs.C:
+//			log.Printf("shutting down on %v", sigs.Received())
+//			return
+//		}
+//	}
+//
+// The runtime makes C ready itself when one of the signals arrives,
+// rather than relaying the signal through package signal's goroutine.
+type Set struct {
+	// C is ready to receive when one of the set's signals has arrived
+	// since the last receive. It holds at most one value: signals
+	// that arrive before it is received do not make it ready again.
+	C <-chan struct{}
+
+	c chan struct{}
+	h handler
+	setState
+}
+
+// NewSet returns a Set that receives the provided signals.
+// If no signals are provided, all incoming signals will be received.
+//
+// Like Notify, NewSet changes the behavior of the signals: for example,
+// os.Interrupt no longer makes the program exit until the Set is
+// stopped. Sets and channels passed to Notify receive copies of
+// incoming signals independently. Reset and Ignore remove the signals
+// from sets as well as from channels.
+func NewSet(sig ...os.Signal) *Set {
+	c := make(chan struct{}, 1)
+	s := &Set{C: c, c: c}
+
+	handlers.Lock()
+	defer handlers.Unlock()
+
+	if handlers.sets == nil {
+		handlers.sets = make(map[*Set]bool)
+	}
+	handlers.sets[s] = true
+	setStart(s)
+
+	add := func(n int) {
+		if n < 0 || s.h.want(n) {
+			return
+		}
+		s.h.set(n)
+		setEnable(s, n)
+	}
+
+	if len(sig) == 0 {
+		for n := 0; n < numSig; n++ {
+			add(n)
+		}
+	} else {
+		for _, sg := range sig {
+			add(signum(sg))
+		}
+	}
+	return s
+}
+
+// Received returns the signals that have arrived since it was last
+// called, in increasing order of signal number. A signal that arrived
+// several times is returned once. It may return no signals after a
+// receive from C, if they were returned by a call that came before.
+func (s *Set) Received() []os.Signal {
+	var sigs []os.Signal
+	for {
+		sig := setRecv(s)
+		if sig == nil {
+			return sigs
+		}
+		sigs = append(sigs, sig)
+	}
+}
+
+// Stop causes the set to stop receiving signals, and undoes the effect
+// of NewSet on their behavior. When Stop returns, it is guaranteed that
+// C will become ready no more. Stop does not close C.
+func (s *Set) Stop() {
+	handlers.Lock()
+	defer handlers.Unlock()
+
+	if !handlers.sets[s] {
+		return
+	}
+	delete(handlers.sets, s)
+	setStop(s)
+}
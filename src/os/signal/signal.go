@@ -14,7 +14,8 @@ var handlers struct {
 	sync.Mutex
 	// Map a channel to the signals that should be sent to it.
 	m map[chan<- os.Signal]*handler
-	// Map a signal to the number of channels receiving it.
+	// Map a signal to the number of channels receiving it,
+	// and on Plan 9 also of sets receiving it.
 	ref [numSig]int64
 	// Map channels to signals while the channel is being stopped.
 	// Not a map because entries live here only very briefly.
@@ -22,6 +23,8 @@ var handlers struct {
 	// at all times, and we also need to keep track of the *handler
 	// value for a channel being stopped. See the Stop function.
 	stopping []stopping
+	// Sets created by NewSet and not yet stopped.
+	sets map[*Set]bool
 }
 
 type stopping struct {
@@ -64,6 +67,12 @@ func cancel(sigs []os.Signal, action func(int)) {
 				}
 			}
 		}
+		for s := range handlers.sets {
+			if s.h.want(n) {
+				s.h.clear(n)
+				setDisable(s, n)
+			}
+		}
 
 		action(n)
 	}
@@ -81,7 +90,8 @@ func cancel(sigs []os.Signal, action func(int)) {
 
 // Ignore causes the provided signals to be ignored. If they are received by
 // the program, nothing will happen. Ignore undoes the effect of any prior
-// calls to Notify for the provided signals.
+// calls to Notify for the provided signals, and removes them from any sets
+// created by NewSet.
 // If no signals are provided, all incoming signals will be ignored.
 func Ignore(sig ...os.Signal) {
 	cancel(sig, ignoreSignal)
@@ -168,7 +178,7 @@ func Notify(c chan<- os.Signal, sig ...os.Signal) {
 }
 
 // Reset undoes the effect of any prior calls to Notify for the provided
-// signals.
+// signals, and removes them from any sets created by NewSet.
 // If no signals are provided, all signal handlers will be reset.
 func Reset(sig ...os.Signal) {
 	cancel(sig, disableSignal)
@@ -257,6 +267,14 @@ func process(sig os.Signal) {
 			}
 		}
 	}
+
+	// Where the runtime delivers signals to sets itself, setNotify
+	// does nothing.
+	for s := range handlers.sets {
+		if s.h.want(n) {
+			setNotify(s, n)
+		}
+	}
 }
 
 // NotifyContext returns a copy of the parent context that is marked done
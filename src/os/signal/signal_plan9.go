@@ -62,3 +62,64 @@ func ignoreSignal(sig int) {
 func signalIgnored(sig int) bool {
 	return signal_ignored(uint32(sig))
 }
+
+// setState records the notes that have arrived for a Set. On Plan 9 the
+// runtime does not deliver notes to sets, so they are relayed by process,
+// like those for channels, and count in handlers.ref.
+type setState struct {
+	recv handler
+}
+
+func setStart(s *Set) {}
+
+func setEnable(s *Set, sig int) {
+	if handlers.ref[sig] == 0 {
+		enableSignal(sig)
+		watchSignalLoopOnce.Do(func() {
+			if watchSignalLoop != nil {
+				go watchSignalLoop()
+			}
+		})
+	}
+	handlers.ref[sig]++
+}
+
+func setDisable(s *Set, sig int) {
+	handlers.ref[sig]--
+	if handlers.ref[sig] == 0 {
+		disableSignal(sig)
+	}
+}
+
+func setStop(s *Set) {
+	for n := 0; n < numSig; n++ {
+		if s.h.want(n) {
+			setDisable(s, n)
+		}
+	}
+}
+
+func setNotify(s *Set, sig int) {
+	s.recv.set(sig)
+	select {
+	case s.c <- struct{}{}:
+	default:
+	}
+}
+
+func setRecv(s *Set) os.Signal {
+	handlers.Lock()
+	defer handlers.Unlock()
+
+	for n := 0; n < numSig; n++ {
+		if s.recv.want(n) {
+			s.recv.clear(n)
+			for sig, m := range sigtab {
+				if m == n {
+					return sig
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -8,14 +8,17 @@
 package signal
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"flag"
 	"fmt"
 	"internal/testenv"
+	"io"
 	"os"
 	"os/exec"
 	"runtime"
+	"runtime/debug"
 	"runtime/trace"
 	"strconv"
 	"strings"
@@ -909,3 +912,137 @@ func TestSignalTrace(t *testing.T) {
 	close(quit)
 	<-done
 }
+
+// waitSet waits for s.C to become ready.
+func waitSet(t *testing.T, s *Set) {
+	t.Helper()
+	select {
+	case <-s.C:
+	case <-time.After(fatalWaitingTime):
+		t.Fatalf("timeout after %v waiting for signal set", fatalWaitingTime)
+	}
+}
+
+func TestSet(t *testing.T) {
+	s := NewSet(syscall.SIGUSR1)
+	defer s.Stop()
+	c := make(chan os.Signal, 1)
+	Notify(c, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer Stop(c)
+
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	work := make(chan int)
+	select {
+	case <-s.C:
+	case <-work:
+		t.Fatal("received from work")
+	case <-time.After(fatalWaitingTime):
+		t.Fatalf("timeout after %v waiting for signal set", fatalWaitingTime)
+	}
+	if got := s.Received(); len(got) != 1 || got[0] != syscall.SIGUSR1 {
+		t.Errorf("Received() = %v, want [%v]", got, syscall.SIGUSR1)
+	}
+	if got := s.Received(); len(got) != 0 {
+		t.Errorf("Received() = %v after it returned the signal", got)
+	}
+	// Channels passed to Notify get a copy.
+	waitSig(t, c, syscall.SIGUSR1)
+
+	// Signals outside the set leave it alone.
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR2)
+	waitSig(t, c, syscall.SIGUSR2)
+	quiesce()
+	select {
+	case <-s.C:
+		t.Errorf("set became ready for %v", s.Received())
+	default:
+	}
+
+	// A stopped set gets no more signals.
+	s.Stop()
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	waitSig(t, c, syscall.SIGUSR1)
+	quiesce()
+	select {
+	case <-s.C:
+		t.Error("stopped set became ready")
+	default:
+	}
+}
+
+func TestSetReset(t *testing.T) {
+	s := NewSet(syscall.SIGUSR1, syscall.SIGUSR2)
+	defer s.Stop()
+	Reset(syscall.SIGUSR1)
+	c := make(chan os.Signal, 1)
+	Notify(c, syscall.SIGUSR1)
+	defer Stop(c)
+
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	waitSig(t, c, syscall.SIGUSR1)
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR2)
+	waitSet(t, s)
+	if got := s.Received(); len(got) != 1 || got[0] != syscall.SIGUSR2 {
+		t.Errorf("Received() = %v, want [%v]", got, syscall.SIGUSR2)
+	}
+}
+
+// Test that the value the runtime sends on a signal set's channel is
+// counted in the channel statistics.
+func TestSetChanStats(t *testing.T) {
+	s := NewSet(syscall.SIGUSR1)
+	defer s.Stop()
+
+	var before, after debug.ChanStats
+	debug.ReadChanStats(&before)
+	syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	waitSet(t, s)
+	debug.ReadChanStats(&after)
+	sends, recvs := after.Sends-before.Sends, after.Recvs-before.Recvs
+	if sends == 0 || sends < recvs {
+		t.Errorf("signal delivery counted %d sends and %d receives, want at least 1 send and no more receives than sends", sends, recvs)
+	}
+}
+
+// Test that a program whose only goroutine waits on a signal set is
+// not reported as deadlocked, and wakes up for the signal.
+func TestSetOnlyWaiter(t *testing.T) {
+	if os.Getenv("GO_TEST_SET_ONLY_WAITER") != "" {
+		s := NewSet(syscall.SIGUSR1)
+		fmt.Println("ready")
+		<-s.C
+		fmt.Println(s.Received())
+		os.Exit(0)
+	}
+
+	testenv.MustHaveExec(t)
+	cmd := exec.Command(os.Args[0], "-test.run=TestSetOnlyWaiter")
+	cmd.Env = append(os.Environ(), "GO_TEST_SET_ONLY_WAITER=1")
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(stdout)
+	if line, err := r.ReadString('\n'); line != "ready\n" {
+		cmd.Process.Kill()
+		cmd.Wait()
+		t.Fatalf("child printed %q, %v; want ready", line, err)
+	}
+	cmd.Process.Signal(syscall.SIGUSR1)
+	timer := time.AfterFunc(fatalWaitingTime, func() { cmd.Process.Kill() })
+	rest, _ := io.ReadAll(r)
+	err = cmd.Wait()
+	if !timer.Stop() {
+		t.Fatalf("child did not wake up within %v of the signal", fatalWaitingTime)
+	}
+	if err != nil {
+		t.Fatalf("child exited with %v; output: %s", err, rest)
+	}
+	if got, want := string(rest), fmt.Sprintln([]os.Signal{syscall.SIGUSR1}); got != want {
+		t.Errorf("child printed %q, want %q", got, want)
+	}
+}
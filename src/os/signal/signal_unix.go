@@ -10,6 +10,7 @@ package signal
 import (
 	"os"
 	"syscall"
+	"unsafe"
 )
 
 // Defined by the runtime package.
@@ -18,6 +19,11 @@ func signal_enable(uint32)
 func signal_ignore(uint32)
 func signal_ignored(uint32) bool
 func signal_recv() uint32
+func signal_setNew(c chan struct{}) unsafe.Pointer
+func signal_setEnable(s unsafe.Pointer, sig uint32)
+func signal_setDisable(s unsafe.Pointer, sig uint32)
+func signal_setStop(s unsafe.Pointer)
+func signal_setRecv(s unsafe.Pointer) (uint32, bool)
 
 func loop() {
 	for {
@@ -61,3 +67,34 @@ func ignoreSignal(sig int) {
 func signalIgnored(sig int) bool {
 	return signal_ignored(uint32(sig))
 }
+
+// setState is the runtime's record of a Set, which it sends on itself.
+type setState struct {
+	rt unsafe.Pointer
+}
+
+func setStart(s *Set) {
+	s.rt = signal_setNew(s.c)
+}
+
+func setEnable(s *Set, sig int) {
+	signal_setEnable(s.rt, uint32(sig))
+}
+
+func setDisable(s *Set, sig int) {
+	signal_setDisable(s.rt, uint32(sig))
+}
+
+func setStop(s *Set) {
+	signal_setStop(s.rt)
+}
+
+func setNotify(s *Set, sig int) {}
+
+func setRecv(s *Set) os.Signal {
+	sig, ok := signal_setRecv(s.rt)
+	if !ok {
+		return nil
+	}
+	return syscall.Signal(sig)
+}
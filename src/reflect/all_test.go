@@ -10,6 +10,7 @@ import (
 	"flag"
 	"fmt"
 	"go/token"
+	"internal/race"
 	"io"
 	"math"
 	"math/rand"
@@ -18,6 +19,7 @@ import (
 	"reflect/internal/example1"
 	"reflect/internal/example2"
 	"runtime"
+	"runtime/debug"
 	"sort"
 	"strconv"
 	"strings"
@@ -1427,6 +1429,1070 @@ func TestChan(t *testing.T) {
 	}
 }
 
+func TestRecvZeroCopy(t *testing.T) {
+	type big [1 << 10]int
+	c := make(chan big, 3)
+	cv := ValueOf(c)
+	for i := 1; i <= 3; i++ {
+		c <- big{i}
+	}
+
+	// Borrow two slots. They stay occupied until committed, in any
+	// order, even though their values count as received.
+	p1, commit1, ok := cv.RecvZeroCopy()
+	p2, commit2, _ := cv.RecvZeroCopy()
+	if !ok || p1.Interface().(*big)[0] != 1 || p2.Interface().(*big)[0] != 2 {
+		t.Fatalf("RecvZeroCopy received %v, %v, %v; want 1, 2, true", p1.Elem().Index(0), p2.Elem().Index(0), ok)
+	}
+	if len(c) != 1 {
+		t.Errorf("len with two slots borrowed = %d, want 1", len(c))
+	}
+	if cv.TrySend(ValueOf(big{4})) {
+		t.Fatalf("TrySend succeeded with all slots occupied")
+	}
+	commit2()
+	if cv.TrySend(ValueOf(big{4})) {
+		t.Fatalf("TrySend succeeded with a slot after a borrowed one committed")
+	}
+
+	// A sender blocked on the full buffer is let in by the commit
+	// that frees the first borrowed slot.
+	done := make(chan bool)
+	go func() {
+		c <- big{4}
+		c <- big{5}
+		done <- true
+	}()
+	time.Sleep(10 * time.Millisecond) // let the sender block
+	commit1()
+	<-done
+	for want := 3; want <= 5; want++ {
+		if v := <-c; v[0] != want {
+			t.Errorf("received %d after commits, want %d", v[0], want)
+		}
+	}
+
+	// A second commit panics.
+	c <- big{6}
+	_, commit, _ := cv.RecvZeroCopy()
+	commit()
+	shouldPanic("more than once", commit)
+}
+
+func TestRecvZeroCopyAllBorrowed(t *testing.T) {
+	// With its only slot borrowed, the channel hands values from
+	// blocked senders straight to receivers.
+	c := make(chan int, 1)
+	c <- 1
+	p, commit, _ := ValueOf(c).RecvZeroCopy()
+	go func() { c <- 2 }()
+	if v := <-c; v != 2 {
+		t.Errorf("received %d, want 2", v)
+	}
+	if v := *p.Interface().(*int); v != 1 {
+		t.Errorf("borrowed value is %d, want 1", v)
+	}
+	commit()
+	c <- 3
+	if v := <-c; v != 3 {
+		t.Errorf("received %d after commit, want 3", v)
+	}
+}
+
+func TestTrySendFront(t *testing.T) {
+	c := make(chan int, 4)
+	cv := ValueOf(c)
+	recvAll := func() []int {
+		var got []int
+		for len(c) > 0 {
+			got = append(got, <-c)
+		}
+		return got
+	}
+
+	// Starting at slot 0, the front send wraps around to the last slot.
+	c <- 1
+	c <- 2
+	if !cv.TrySendFront(ValueOf(0)) {
+		t.Fatal("TrySendFront with room failed")
+	}
+	c <- 3
+	if cv.TrySendFront(ValueOf(-1)) {
+		t.Error("TrySendFront on full channel succeeded")
+	}
+	if got, want := recvAll(), []int{0, 1, 2, 3}; !DeepEqual(got, want) {
+		t.Errorf("received %v, want %v", got, want)
+	}
+
+	// Front sends into a drained buffer, interleaved with sends.
+	for i := 0; i < 3; i++ {
+		c <- 10 + i
+		cv.TrySendFront(ValueOf(-10 - i))
+		<-c
+	}
+	if got, want := recvAll(), []int{10, 11, 12}; !DeepEqual(got, want) {
+		t.Errorf("received %v, want %v", got, want)
+	}
+
+	// A borrowed slot leaves no room at the head.
+	c <- 1
+	_, commit, _ := cv.RecvZeroCopy()
+	if cv.TrySendFront(ValueOf(0)) {
+		t.Error("TrySendFront with a borrowed slot succeeded")
+	}
+	commit()
+	if !cv.TrySendFront(ValueOf(0)) {
+		t.Error("TrySendFront after commit failed")
+	}
+	<-c
+
+	// A waiting receiver gets the value directly, on an unbuffered
+	// channel too.
+	u := make(chan int)
+	if ValueOf(u).TrySendFront(ValueOf(1)) {
+		t.Error("TrySendFront on unbuffered channel without a receiver succeeded")
+	}
+	for _, ch := range []chan int{c, u} {
+		done := make(chan int)
+		go func() { done <- <-ch }()
+		for !ValueOf(ch).TrySendFront(ValueOf(5)) {
+			runtime.Gosched()
+		}
+		if v := <-done; v != 5 {
+			t.Errorf("receiver got %d, want 5", v)
+		}
+	}
+
+	var nilc chan int
+	if ValueOf(nilc).TrySendFront(ValueOf(1)) {
+		t.Error("TrySendFront on nil channel succeeded")
+	}
+	close(c)
+	shouldPanic("", func() { cv.TrySendFront(ValueOf(1)) })
+	shouldPanic("recv-only", func() { ValueOf((<-chan int)(u)).TrySendFront(ValueOf(1)) })
+}
+
+func TestTrySendFrontPointers(t *testing.T) {
+	// Receives leave slots with pointers to be cleared in batches; a
+	// front send reuses the last of them.
+	c := make(chan *int, 100)
+	for i := 0; i < 100; i++ {
+		i := i
+		c <- &i
+	}
+	for i := 0; i < 50; i++ {
+		<-c
+	}
+	for i := 0; i < 10; i++ {
+		i := -1 - i
+		if !ValueOf(c).TrySendFront(ValueOf(&i)) {
+			t.Fatal("TrySendFront with room failed")
+		}
+	}
+	for i := 0; i < 60; i++ {
+		want := i - 10
+		if i >= 10 {
+			want = i + 40
+		}
+		if v := *<-c; v != want {
+			t.Fatalf("receive %d got %d, want %d", i, v, want)
+		}
+	}
+}
+
+// waitChanWaiters waits until n goroutines are blocked on c.
+func waitChanWaiters(t *testing.T, c interface{}, n int) {
+	t.Helper()
+	for i := 0; len(debug.ChanWaiters(c)) != n; i++ {
+		if i >= 1000 {
+			t.Fatalf("%d goroutines blocked on channel, want %d", len(debug.ChanWaiters(c)), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCloseReport(t *testing.T) {
+	// Buffered values stay for receivers to take.
+	c := make(chan int, 4)
+	c <- 1
+	c <- 2
+	c <- 3
+	<-c
+	if b, s, r := ValueOf(c).CloseReport(); b != 2 || s != 0 || r != 0 {
+		t.Errorf("CloseReport with 2 buffered values = %d, %d, %d; want 2, 0, 0", b, s, r)
+	}
+	for _, want := range []int{2, 3} {
+		if v, ok := <-c; v != want || !ok {
+			t.Errorf("received %d, %v after close; want %d, true", v, ok, want)
+		}
+	}
+	if _, ok := <-c; ok {
+		t.Error("receive from closed, drained channel succeeded")
+	}
+
+	// Blocked receivers get zero values.
+	u := make(chan int)
+	done := make(chan bool)
+	for i := 0; i < 3; i++ {
+		go func() {
+			_, ok := <-u
+			done <- ok
+		}()
+	}
+	waitChanWaiters(t, u, 3)
+	if b, s, r := ValueOf(u).CloseReport(); b != 0 || s != 0 || r != 3 {
+		t.Errorf("CloseReport with 3 blocked receivers = %d, %d, %d; want 0, 0, 3", b, s, r)
+	}
+	for i := 0; i < 3; i++ {
+		if <-done {
+			t.Error("blocked receiver got a value from a closed channel")
+		}
+	}
+
+	shouldPanic("", func() { ValueOf(u).CloseReport() })
+	shouldPanic("", func() { ValueOf((chan int)(nil)).CloseReport() })
+}
+
+func TestCloseReportBlockedSenders(t *testing.T) {
+	if race.Enabled {
+		t.Skip("closing a channel with blocked senders is a race")
+	}
+	f := make(chan int, 1)
+	f <- 0
+	panicked := make(chan interface{})
+	for i := 0; i < 2; i++ {
+		go func() {
+			defer func() { panicked <- recover() }()
+			f <- 1
+		}()
+	}
+	waitChanWaiters(t, f, 2)
+	if b, s, r := ValueOf(f).CloseReport(); b != 1 || s != 2 || r != 0 {
+		t.Errorf("CloseReport with 2 blocked senders = %d, %d, %d; want 1, 2, 0", b, s, r)
+	}
+	for i := 0; i < 2; i++ {
+		if e := <-panicked; e == nil || !strings.Contains(fmt.Sprint(e), "send on closed channel") {
+			t.Errorf("blocked sender recovered %v, want a send on closed channel panic", e)
+		}
+	}
+}
+
+func TestRecvZeroCopyCopies(t *testing.T) {
+	c := make(chan string)
+	go func() { c <- "hello" }()
+	p, commit, ok := ValueOf(c).RecvZeroCopy()
+	if v := *p.Interface().(*string); v != "hello" || !ok {
+		t.Errorf("RecvZeroCopy on unbuffered channel = %q, %v; want hello, true", v, ok)
+	}
+	commit()
+	shouldPanic("more than once", commit)
+
+	close(c)
+	p, commit, ok = ValueOf(c).RecvZeroCopy()
+	if v := *p.Interface().(*string); v != "" || ok {
+		t.Errorf("RecvZeroCopy on closed channel = %q, %v; want \"\", false", v, ok)
+	}
+	commit()
+}
+
+func TestMakeChanFilled(t *testing.T) {
+	initial := []string{"a", "b", "c"}
+	cv := MakeChanFilled(TypeOf((chan string)(nil)), 4, ValueOf(initial))
+	c := cv.Interface().(chan string)
+	if len(c) != 3 || cap(c) != 4 {
+		t.Fatalf("len, cap = %d, %d; want 3, 4", len(c), cap(c))
+	}
+	initial[0] = "changed"
+	c <- "d"
+	select {
+	case c <- "e":
+		t.Fatal("send on full channel succeeded")
+	default:
+	}
+	for _, want := range []string{"a", "b", "c", "d"} {
+		if got := <-c; got != want {
+			t.Errorf("received %q; want %q", got, want)
+		}
+	}
+	c <- "f"
+	if got := <-c; got != "f" {
+		t.Errorf("received %q after wrap; want \"f\"", got)
+	}
+
+	// A full buffer, and a receiver on another goroutine, which the
+	// race detector must see as ordered after the fill.
+	buf := []int{1, 2}
+	ci := MakeChanFilled(TypeOf((chan int)(nil)), 2, ValueOf(buf)).Interface().(chan int)
+	done := make(chan int)
+	go func() {
+		done <- <-ci + <-ci
+	}()
+	if sum := <-done; sum != 3 {
+		t.Errorf("sum of received values = %d; want 3", sum)
+	}
+	ci <- 3
+	ci <- 4
+	if len(ci) != 2 {
+		t.Errorf("len after refill = %d; want 2", len(ci))
+	}
+
+	empty := MakeChanFilled(TypeOf((chan struct{})(nil)), 0, ValueOf([]struct{}{}))
+	if empty.Len() != 0 || empty.Cap() != 0 {
+		t.Errorf("empty channel len, cap = %d, %d; want 0, 0", empty.Len(), empty.Cap())
+	}
+	zs := MakeChanFilled(TypeOf((chan struct{})(nil)), 3, ValueOf(make([]struct{}, 2)))
+	if zs.Len() != 2 {
+		t.Errorf("zero-size element channel len = %d; want 2", zs.Len())
+	}
+
+	ct := TypeOf((chan int)(nil))
+	shouldPanic("initial slice longer than buffer", func() { MakeChanFilled(ct, 1, ValueOf([]int{1, 2})) })
+	shouldPanic("int != string", func() { MakeChanFilled(ct, 1, ValueOf([]string{"x"})) })
+	shouldPanic("call of reflect.MakeChanFilled on int Value", func() { MakeChanFilled(ct, 1, ValueOf(1)) })
+	shouldPanic("negative buffer size", func() { MakeChanFilled(ct, -1, ValueOf([]int{})) })
+	shouldPanic("unidirectional channel type", func() { MakeChanFilled(TypeOf((<-chan int)(nil)), 1, ValueOf([]int{})) })
+}
+
+func TestMakeChanWithBuffer(t *testing.T) {
+	buf := make([]string, 3)
+	c := MakeChanWithBuffer(TypeOf((chan string)(nil)), ValueOf(buf)).Interface().(chan string)
+	if len(c) != 0 || cap(c) != 3 {
+		t.Fatalf("len, cap = %d, %d; want 0, 3", len(c), cap(c))
+	}
+	c <- "a"
+	c <- "b"
+	c <- "c"
+	select {
+	case c <- "d":
+		t.Fatal("send on full channel succeeded")
+	default:
+	}
+	if buf[0] != "a" || buf[1] != "b" || buf[2] != "c" {
+		t.Errorf("buffer holds %q; want the sent values", buf)
+	}
+	for _, want := range []string{"a", "b", "c"} {
+		if got := <-c; got != want {
+			t.Errorf("received %q; want %q", got, want)
+		}
+	}
+
+	// The contents of the slice are not received.
+	stale := []int{1, 2}
+	ci := MakeChanWithBuffer(TypeOf((chan int)(nil)), ValueOf(stale)).Interface().(chan int)
+	if len(ci) != 0 {
+		t.Errorf("len with stale buffer = %d; want 0", len(ci))
+	}
+	ci <- 3
+	if got := <-ci; got != 3 {
+		t.Errorf("received %d; want 3", got)
+	}
+
+	unbuf := MakeChanWithBuffer(TypeOf((chan int)(nil)), ValueOf([]int{}))
+	if unbuf.Cap() != 0 {
+		t.Errorf("cap with empty buffer = %d; want 0", unbuf.Cap())
+	}
+	zs := MakeChanWithBuffer(TypeOf((chan struct{})(nil)), ValueOf(make([]struct{}, 2)))
+	zs.Send(ValueOf(struct{}{}))
+	if zs.Len() != 1 || zs.Cap() != 2 {
+		t.Errorf("zero-size element channel len, cap = %d, %d; want 1, 2", zs.Len(), zs.Cap())
+	}
+
+	ct := TypeOf((chan int)(nil))
+	shouldPanic("int != string", func() { MakeChanWithBuffer(ct, ValueOf([]string{"x"})) })
+	shouldPanic("call of reflect.MakeChanWithBuffer on int Value", func() { MakeChanWithBuffer(ct, ValueOf(1)) })
+	shouldPanic("of non-chan type", func() { MakeChanWithBuffer(TypeOf(1), ValueOf([]int{})) })
+	shouldPanic("unidirectional channel type", func() { MakeChanWithBuffer(TypeOf((<-chan int)(nil)), ValueOf([]int{})) })
+}
+
+func TestMakeChanWithBufferGC(t *testing.T) {
+	type elem struct {
+		p *[64]int
+		s string
+	}
+	const n = 16
+	var finalized uint32
+	newChan := func(buf []elem, k int) chan elem {
+		c := MakeChanWithBuffer(TypeOf((chan elem)(nil)), ValueOf(buf)).Interface().(chan elem)
+		for i := 0; i < k; i++ {
+			p := new([64]int)
+			p[0] = i
+			runtime.SetFinalizer(p, func(*[64]int) { atomic.AddUint32(&finalized, 1) })
+			c <- elem{p, strconv.Itoa(i)}
+		}
+		return c
+	}
+	check := func(c chan elem, k int) {
+		t.Helper()
+		for i := 0; i < k; i++ {
+			e := <-c
+			if e.p[0] != i || e.s != strconv.Itoa(i) {
+				t.Fatalf("received %d, %q; want %d, %q", e.p[0], e.s, i, strconv.Itoa(i))
+			}
+		}
+	}
+	gc := func() {
+		for i := 0; i < 3; i++ {
+			runtime.GC()
+			// Reuse whatever was freed.
+			for j := 0; j < 1000; j++ {
+				sink = make([]byte, 64)
+			}
+		}
+	}
+
+	// The channel is the only reference to the buffer and to the
+	// values in it.
+	c := newChan(make([]elem, n), n)
+	gc()
+	if f := atomic.LoadUint32(&finalized); f != 0 {
+		t.Fatalf("%d buffered values were finalized", f)
+	}
+	check(c, n)
+	sink = nil
+
+	// A buffer kept by the caller and reused for a new channel does
+	// not keep the values of the old one alive, even those in slots
+	// the new one has not sent to.
+	buf := make([]elem, n)
+	newChan(buf, n)
+	c = newChan(buf, n/2)
+	deadline := time.Now().Add(10 * time.Second)
+	for atomic.LoadUint32(&finalized) < 2*n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d values finalized; want %d", atomic.LoadUint32(&finalized), 2*n)
+		}
+		gc()
+		time.Sleep(time.Millisecond)
+	}
+	if f := atomic.LoadUint32(&finalized); f != 2*n {
+		t.Errorf("%d values finalized; want %d", f, 2*n)
+	}
+	check(c, n/2)
+	runtime.KeepAlive(buf)
+}
+
+// chanSetWait calls s.Wait, failing t if it does not return in time.
+func chanSetWait(t *testing.T, s *ChanSet) (ch Value, closed bool) {
+	t.Helper()
+	type result struct {
+		ch     Value
+		closed bool
+	}
+	done := make(chan result, 1)
+	go func() {
+		ch, closed := s.Wait()
+		done <- result{ch, closed}
+	}()
+	select {
+	case r := <-done:
+		return r.ch, r.closed
+	case <-time.After(10 * time.Second):
+		t.Fatal("ChanSet.Wait did not return")
+		panic("unreachable")
+	}
+}
+
+func TestChanSet(t *testing.T) {
+	a := make(chan int, 2)
+	b := make(chan int)
+	c := make(chan struct{}, 1)
+	s := NewChanSet()
+	for _, ch := range []interface{}{a, b, c} {
+		if !s.Add(ValueOf(ch)) {
+			t.Fatalf("Add(%T) = false; want true", ch)
+		}
+	}
+	if s.Add(ValueOf(a)) {
+		t.Error("second Add of channel = true; want false")
+	}
+	if s.Len() != 3 {
+		t.Errorf("Len = %d; want 3", s.Len())
+	}
+
+	// A channel stays ready, and is reported again, until it is drained.
+	a <- 1
+	a <- 2
+	for i := 0; i < 2; i++ {
+		ch, closed := chanSetWait(t, s)
+		if ch.Interface() != interface{}(a) || closed {
+			t.Fatalf("Wait = %v, %v; want a, false", ch, closed)
+		}
+	}
+	<-a
+	<-a
+
+	// A blocked sender makes an unbuffered channel ready.
+	go func() { b <- 3 }()
+	ch, closed := chanSetWait(t, s)
+	if ch.Interface() != interface{}(b) || closed {
+		t.Fatalf("Wait = %v, %v; want b, false", ch, closed)
+	}
+	if v, ok := ch.TryRecv(); !ok || v.Int() != 3 {
+		t.Fatalf("TryRecv from b = %v, %v; want 3, true", v, ok)
+	}
+
+	// Ready channels take turns.
+	a <- 4
+	c <- struct{}{}
+	seen := map[interface{}]bool{}
+	for i := 0; i < 2; i++ {
+		ch, _ := chanSetWait(t, s)
+		seen[ch.Interface()] = true
+	}
+	if !seen[interface{}(a)] || !seen[interface{}(c)] {
+		t.Errorf("Wait with a and c ready returned %v; want both", seen)
+	}
+	<-a
+
+	// A removed channel is no longer reported, even if ready.
+	if !s.Remove(ValueOf(c)) {
+		t.Error("Remove(c) = false; want true")
+	}
+	if s.Remove(ValueOf(c)) {
+		t.Error("second Remove(c) = true; want false")
+	}
+	a <- 5
+	ch, _ = chanSetWait(t, s)
+	if ch.Interface() != interface{}(a) {
+		t.Errorf("Wait after Remove(c) = %v; want a", ch)
+	}
+
+	// A closed channel is reported once its buffer is drained, and
+	// leaves the set.
+	close(a)
+	ch, closed = chanSetWait(t, s)
+	if ch.Interface() != interface{}(a) || closed {
+		t.Fatalf("Wait on closed a with a value = %v, %v; want a, false", ch, closed)
+	}
+	<-a
+	ch, closed = chanSetWait(t, s)
+	if ch.Interface() != interface{}(a) || !closed {
+		t.Fatalf("Wait on closed empty a = %v, %v; want a, true", ch, closed)
+	}
+	if s.Len() != 1 {
+		t.Errorf("Len after close = %d; want 1", s.Len())
+	}
+	if s.Remove(ValueOf(a)) {
+		t.Error("Remove of closed channel = true; want false")
+	}
+
+	// A Wait blocked in an idle set is woken by an Add of a ready channel.
+	d := make(chan string, 1)
+	d <- "x"
+	done := make(chan Value)
+	go func() {
+		ch, _ := s.Wait()
+		done <- ch
+	}()
+	time.Sleep(10 * time.Millisecond)
+	s.Add(ValueOf(d))
+	if ch := <-done; ch.Interface() != interface{}(d) {
+		t.Errorf("Wait woken by Add = %v; want d", ch)
+	}
+
+	shouldPanic("send-only channel", func() { s.Add(ValueOf(make(chan<- int))) })
+	shouldPanic("nil channel", func() { s.Add(ValueOf((chan int)(nil))) })
+	shouldPanic("call of reflect.(*ChanSet).Add on int Value", func() { s.Add(ValueOf(1)) })
+}
+
+func TestChanSetSelectSend(t *testing.T) {
+	c := make(chan int)
+	s := NewChanSet()
+	s.Add(ValueOf(c))
+	go func() {
+		select {
+		case c <- 1:
+		case <-make(chan int):
+		}
+	}()
+	ch, _ := chanSetWait(t, s)
+	if v, ok := ch.TryRecv(); !ok || v.Int() != 1 {
+		t.Fatalf("TryRecv = %v, %v; want 1, true", v, ok)
+	}
+}
+
+func TestChanSetConcurrent(t *testing.T) {
+	const (
+		nchans = 100
+		nsends = 20
+	)
+	s := NewChanSet()
+	chans := make([]chan int, nchans)
+	for i := range chans {
+		chans[i] = make(chan int, i%3)
+		s.Add(ValueOf(chans[i]))
+	}
+	for i, c := range chans {
+		go func(i int, c chan int) {
+			for j := 0; j < nsends; j++ {
+				c <- i
+			}
+			close(c)
+		}(i, c)
+	}
+	received := make([]int, nchans)
+	for closed := 0; closed < nchans; {
+		ch, isClosed := chanSetWait(t, s)
+		if isClosed {
+			closed++
+			continue
+		}
+		if v, ok := ch.TryRecv(); ok {
+			received[v.Int()]++
+		}
+	}
+	for i, n := range received {
+		if n != nsends {
+			t.Errorf("received %d values from channel %d; want %d", n, i, nsends)
+		}
+	}
+	if s.Len() != 0 {
+		t.Errorf("Len = %d; want 0", s.Len())
+	}
+
+	// Removes racing with sends and the waiter.
+	s = NewChanSet()
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		c := make(chan int, 1)
+		s.Add(ValueOf(c))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case c <- 1:
+				case <-stop:
+					return
+				}
+				s.Remove(ValueOf(c))
+				s.Add(ValueOf(c))
+			}
+		}()
+	}
+	for i := 0; i < 1000; i++ {
+		ch, _ := chanSetWait(t, s)
+		ch.TryRecv()
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestChanMerge(t *testing.T) {
+	nsrcs, nsends := 10000, 5
+	if testing.Short() {
+		nsrcs = 1000
+	}
+	ngo := runtime.NumGoroutine()
+	dst := make(chan [2]int, 16)
+	srcs := make([]chan [2]int, nsrcs)
+	vals := make([]Value, nsrcs)
+	for i := range srcs {
+		srcs[i] = make(chan [2]int, i%2)
+		vals[i] = ValueOf(srcs[i])
+	}
+	ChanMerge(ValueOf(dst), vals)
+	if n := runtime.NumGoroutine() - ngo; n > 1 {
+		t.Errorf("ChanMerge of %d channels started %d goroutines; want 1", nsrcs, n)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for j := 0; j < nsends; j++ {
+				for i := g; i < nsrcs; i += 8 {
+					srcs[i] <- [2]int{i, j}
+				}
+			}
+			for i := g; i < nsrcs; i += 8 {
+				close(srcs[i])
+			}
+		}(g)
+	}
+
+	next := make([]int, nsrcs)
+	n := 0
+	timeout := time.After(60 * time.Second)
+	for done := false; !done; {
+		select {
+		case v, ok := <-dst:
+			if !ok {
+				done = true
+				break
+			}
+			i, j := v[0], v[1]
+			if j != next[i] {
+				t.Fatalf("received value %d of source %d; want value %d", j, i, next[i])
+			}
+			next[i]++
+			n++
+		case <-timeout:
+			t.Fatalf("dst not closed after receiving %d of %d values", n, nsrcs*nsends)
+		}
+	}
+	if n != nsrcs*nsends {
+		t.Errorf("received %d values before close; want %d", n, nsrcs*nsends)
+	}
+	wg.Wait()
+}
+
+func TestChanMergeEdgeCases(t *testing.T) {
+	// No sources: dst is closed at once.
+	dst := make(chan int)
+	ChanMerge(ValueOf(dst), nil)
+	if _, ok := <-dst; ok {
+		t.Error("received value from merge of no sources")
+	}
+
+	// A source passed twice, and one that is closed already.
+	dst = make(chan int)
+	a, b := make(chan int, 1), make(chan int)
+	a <- 1
+	close(a)
+	ChanMerge(ValueOf(dst), []Value{ValueOf(a), ValueOf(b), ValueOf((<-chan int)(b))})
+	go func() {
+		b <- 2
+		close(b)
+	}()
+	sum := 0
+	for v := range dst {
+		sum += v
+	}
+	if sum != 3 {
+		t.Errorf("sum of merged values = %d; want 3", sum)
+	}
+
+	ct := ValueOf(make(chan int))
+	shouldPanic("element type string is not destination element type int", func() { ChanMerge(ct, []Value{ValueOf(make(chan string))}) })
+	shouldPanic("nil source channel", func() { ChanMerge(ct, []Value{ValueOf((chan int)(nil))}) })
+	shouldPanic("nil destination channel", func() { ChanMerge(ValueOf((chan int)(nil)), nil) })
+	shouldPanic("destination channel is a source", func() { ChanMerge(ct, []Value{ct}) })
+	shouldPanic("send-only source channel", func() { ChanMerge(ct, []Value{ValueOf(make(chan<- int))}) })
+	shouldPanic("receive-only destination channel", func() { ChanMerge(ValueOf(make(<-chan int)), nil) })
+}
+
+// recvInt receives an int from r, failing t if it blocks for too long.
+func recvInt(t *testing.T, r *BroadcastReceiver) (int, bool) {
+	t.Helper()
+	type result struct {
+		v  Value
+		ok bool
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, ok := r.Recv()
+		done <- result{v, ok}
+	}()
+	select {
+	case res := <-done:
+		if !res.ok {
+			return 0, false
+		}
+		return int(res.v.Int()), true
+	case <-time.After(10 * time.Second):
+		t.Fatal("BroadcastReceiver.Recv did not return")
+		panic("unreachable")
+	}
+}
+
+func TestBroadcast(t *testing.T) {
+	b := MakeBroadcast(TypeOf(0), 4, BroadcastBlock)
+	if b.Elem() != TypeOf(0) {
+		t.Errorf("Elem = %v; want int", b.Elem())
+	}
+	b.Send(ValueOf(-1)) // no receivers: received by none
+	r1, r2 := b.Subscribe(), b.Subscribe()
+	for i := 0; i < 3; i++ {
+		b.Send(ValueOf(i))
+	}
+	r3 := b.Subscribe() // sees only later values
+	b.Send(ValueOf(3))
+	for _, r := range []*BroadcastReceiver{r1, r2} {
+		for want := 0; want < 4; want++ {
+			if v, ok := recvInt(t, r); !ok || v != want {
+				t.Fatalf("Recv = %d, %v; want %d, true", v, ok, want)
+			}
+		}
+		if v, ok := r.TryRecv(); v.IsValid() || ok {
+			t.Errorf("TryRecv with nothing sent = %v, %v; want invalid Value, false", v, ok)
+		}
+	}
+
+	// Close lets a receiver drain what it has not received.
+	b.Close()
+	if v, ok := recvInt(t, r3); !ok || v != 3 {
+		t.Errorf("Recv after Close = %d, %v; want 3, true", v, ok)
+	}
+	for _, r := range []*BroadcastReceiver{r1, r2, r3} {
+		if v, ok := r.Recv(); !ok && v.Int() == 0 {
+			continue
+		}
+		t.Error("Recv on drained closed Broadcast reported a value")
+	}
+	if v, ok := r1.TryRecv(); !v.IsValid() || ok {
+		t.Errorf("TryRecv on closed Broadcast = %v, %v; want 0, false", v, ok)
+	}
+
+	// A receiver blocked in Recv is woken by Close.
+	b = MakeBroadcast(TypeOf(""), 1, BroadcastDrop)
+	r := b.Subscribe()
+	done := make(chan bool)
+	go func() {
+		_, ok := r.Recv()
+		done <- ok
+	}()
+	time.Sleep(10 * time.Millisecond)
+	b.Close()
+	if <-done {
+		t.Error("Recv woken by Close reported a value")
+	}
+
+	mustPanic := func(name, want string, f func()) {
+		t.Helper()
+		defer func() {
+			if e := recover(); e == nil || !strings.Contains(fmt.Sprint(e), want) {
+				t.Errorf("%s: panic %v; want %q", name, e, want)
+			}
+		}()
+		f()
+	}
+	mustPanic("Send on closed", "send on closed broadcast channel", func() { b.Send(ValueOf("x")) })
+	mustPanic("Close of closed", "close of closed broadcast channel", b.Close)
+	mustPanic("Send of wrong type", "value of type int is not assignable to type string", func() { b.Send(ValueOf(1)) })
+	mustPanic("zero size", "size must be positive", func() { MakeBroadcast(TypeOf(0), 0, BroadcastBlock) })
+	mustPanic("bad policy", "invalid policy", func() { MakeBroadcast(TypeOf(0), 1, 2) })
+}
+
+func TestBroadcastBlock(t *testing.T) {
+	b := MakeBroadcast(TypeOf(0), 2, BroadcastBlock)
+	fast, slow := b.Subscribe(), b.Subscribe()
+	b.Send(ValueOf(0))
+	b.Send(ValueOf(1))
+	recvInt(t, fast)
+	recvInt(t, fast)
+	if b.TrySend(ValueOf(2)) {
+		t.Fatal("TrySend succeeded with slow receiver a whole Broadcast behind")
+	}
+
+	// A blocked sender waits for the slowest receiver, not the fast one.
+	sent := make(chan bool)
+	go func() {
+		b.Send(ValueOf(2))
+		sent <- true
+	}()
+	select {
+	case <-sent:
+		t.Fatal("Send did not block with slow receiver a whole Broadcast behind")
+	case <-time.After(10 * time.Millisecond):
+	}
+	if v, ok := recvInt(t, slow); !ok || v != 0 {
+		t.Fatalf("slow Recv = %d, %v; want 0, true", v, ok)
+	}
+	<-sent
+	if v, ok := recvInt(t, fast); !ok || v != 2 {
+		t.Fatalf("fast Recv = %d, %v; want 2, true", v, ok)
+	}
+	for want := 1; want <= 2; want++ {
+		if v, ok := recvInt(t, slow); !ok || v != want {
+			t.Fatalf("slow Recv = %d, %v; want %d, true", v, ok, want)
+		}
+	}
+
+	// Closing the slowest receiver releases the sender.
+	b.Send(ValueOf(3))
+	b.Send(ValueOf(4))
+	recvInt(t, fast)
+	recvInt(t, fast)
+	go func() {
+		b.Send(ValueOf(5))
+		sent <- true
+	}()
+	time.Sleep(10 * time.Millisecond)
+	slow.Close()
+	<-sent
+	if _, ok := slow.Recv(); ok {
+		t.Error("Recv on closed receiver reported a value")
+	}
+	if v, ok := recvInt(t, fast); !ok || v != 5 {
+		t.Errorf("fast Recv = %d, %v; want 5, true", v, ok)
+	}
+	if fast.Dropped() != 0 {
+		t.Errorf("Dropped = %d under BroadcastBlock; want 0", fast.Dropped())
+	}
+}
+
+func TestBroadcastDrop(t *testing.T) {
+	b := MakeBroadcast(TypeOf(0), 3, BroadcastDrop)
+	fast, slow := b.Subscribe(), b.Subscribe()
+	for i := 0; i < 10; i++ {
+		if !b.TrySend(ValueOf(i)) {
+			t.Fatalf("TrySend %d failed under BroadcastDrop", i)
+		}
+		if v, ok := recvInt(t, fast); !ok || v != i {
+			t.Fatalf("fast Recv = %d, %v; want %d, true", v, ok, i)
+		}
+	}
+	// The slow receiver gets the last 3 values and misses the rest.
+	for want := 7; want < 10; want++ {
+		if v, ok := recvInt(t, slow); !ok || v != want {
+			t.Fatalf("slow Recv = %d, %v; want %d, true", v, ok, want)
+		}
+	}
+	if n := slow.Dropped(); n != 7 {
+		t.Errorf("slow Dropped = %d; want 7", n)
+	}
+	if n := fast.Dropped(); n != 0 {
+		t.Errorf("fast Dropped = %d; want 0", n)
+	}
+	b.Close()
+	if _, ok := slow.Recv(); ok {
+		t.Error("Recv on drained closed Broadcast reported a value")
+	}
+}
+
+func TestBroadcastConcurrent(t *testing.T) {
+	const (
+		nsenders   = 4
+		nreceivers = 8
+		nsends     = 2000
+	)
+	for _, policy := range []BroadcastPolicy{BroadcastBlock, BroadcastDrop} {
+		b := MakeBroadcast(TypeOf([2]int{}), 16, policy)
+		var wg sync.WaitGroup
+		errs := make(chan error, nreceivers)
+		for i := 0; i < nreceivers; i++ {
+			r := b.Subscribe()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				next := make([]int, nsenders)
+				n := 0
+				for {
+					v, ok := r.Recv()
+					if !ok {
+						break
+					}
+					p := v.Interface().([2]int)
+					s, j := p[0], p[1]
+					// Values of each sender arrive in order,
+					// possibly with gaps when dropping.
+					if j < next[s] || policy == BroadcastBlock && j != next[s] {
+						errs <- fmt.Errorf("received value %d of sender %d; want %d", j, s, next[s])
+						return
+					}
+					next[s] = j + 1
+					n++
+				}
+				if got := uint64(n) + r.Dropped(); got != nsenders*nsends {
+					errs <- fmt.Errorf("received %d and dropped %d values; want %d in all", n, r.Dropped(), nsenders*nsends)
+				}
+			}()
+		}
+		var swg sync.WaitGroup
+		for s := 0; s < nsenders; s++ {
+			swg.Add(1)
+			go func(s int) {
+				defer swg.Done()
+				for j := 0; j < nsends; j++ {
+					b.Send(ValueOf([2]int{s, j}))
+				}
+			}(s)
+		}
+		swg.Wait()
+		b.Close()
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Errorf("policy %d: %v", policy, err)
+		}
+	}
+}
+
+func TestChanStats(t *testing.T) {
+	c := make(chan int, 4)
+	v := ValueOf(c)
+	if _, ok := v.ChanStats(); ok {
+		t.Fatal("ChanStats reported statistics before EnableChanStats")
+	}
+	c <- 0 // before EnableChanStats: not counted, but buffered
+	v.EnableChanStats()
+	v.EnableChanStats()
+	c <- 1
+	c <- 2
+	<-c
+	select {
+	case c <- 3:
+	default:
+		t.Fatal("select could not send to buffered channel")
+	}
+	select {
+	case <-c:
+	default:
+		t.Fatal("select could not receive from buffered channel")
+	}
+	want := ChanStats{MaxLen: 3, Sends: 3, Recvs: 2}
+	if s, ok := v.ChanStats(); !ok || s != want {
+		t.Errorf("buffered: ChanStats() = %+v, %v; want %+v, true", s, ok, want)
+	}
+
+	// Direct handoffs count the send and the receive once each, and
+	// record how long the receiver blocked.
+	u := make(chan int)
+	uv := ValueOf(u)
+	uv.EnableChanStats()
+	const delay = 10 * time.Millisecond
+	go func() {
+		time.Sleep(delay)
+		u <- 1
+		u <- 2
+	}()
+	<-u
+	select {
+	case <-u:
+	case <-time.After(time.Minute):
+		t.Fatal("select did not receive")
+	}
+	s, ok := uv.ChanStats()
+	if !ok || s.MaxLen != 0 || s.Sends != 2 || s.Recvs != 2 {
+		t.Errorf("unbuffered: ChanStats() = %+v, %v; want 2 sends, 2 receives", s, ok)
+	}
+	if s.MaxWait < int64(delay) {
+		t.Errorf("unbuffered: MaxWait = %v; want at least %v", time.Duration(s.MaxWait), delay)
+	}
+
+	// Receives from a closed channel are not counted.
+	close(u)
+	<-u
+	if s2, _ := uv.ChanStats(); s2.Recvs != s.Recvs {
+		t.Errorf("receive from closed channel counted: Recvs = %d; want %d", s2.Recvs, s.Recvs)
+	}
+
+	if _, ok := ValueOf((chan int)(nil)).ChanStats(); ok {
+		t.Error("ChanStats of nil channel reported statistics")
+	}
+	shouldPanic("EnableChanStats of nil channel", func() { ValueOf((chan int)(nil)).EnableChanStats() })
+}
+
+func TestChanStatsGC(t *testing.T) {
+	// A channel of pointer-free elements is allocated without pointers;
+	// its statistics must survive collections all the same.
+	c := make(chan int, 8)
+	v := ValueOf(c)
+	v.EnableChanStats()
+	for i := 0; i < 3; i++ {
+		runtime.GC()
+		c <- i
+	}
+	runtime.GC()
+	want := ChanStats{MaxLen: 3, Sends: 3}
+	if s, ok := v.ChanStats(); !ok || s != want {
+		t.Errorf("ChanStats() = %+v, %v; want %+v, true", s, ok, want)
+	}
+}
+
 // caseInfo describes a single case in a select test.
 type caseInfo struct {
 	desc      string
@@ -6907,6 +7973,182 @@ func TestChanAlloc(t *testing.T) {
 	// allocs < 0.5 condition will trigger and this test should be fixed.
 }
 
+func TestSelectAlloc(t *testing.T) {
+	// A wide select, executed repeatedly, should only allocate its
+	// runtimeSelect slice in package reflect. The runtime reuses the
+	// goroutine's scratch arrays.
+	c := make(chan struct{})
+	close(c)
+	var cases []SelectCase
+	for i := 0; i < 40; i++ {
+		cases = append(cases, SelectCase{Dir: SelectRecv, Chan: ValueOf(c)})
+	}
+	Select(cases) // warm up the scratch arrays
+	allocs := testing.AllocsPerRun(100, func() {
+		Select(cases)
+	})
+	if allocs > 1 {
+		t.Errorf("allocs per 40-case Select: want at most 1 got %f", allocs)
+	}
+}
+
+func TestCompileSelect(t *testing.T) {
+	in := make(chan int, 1)
+	out := make(chan string, 1)
+	s, err := CompileSelect([]SelectCase{
+		{Dir: SelectRecv, Chan: ValueOf(in)},
+		{Dir: SelectDefault},
+		{Dir: SelectSend, Chan: ValueOf(out), Send: ValueOf("a")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chosen, _, _ := s.Exec(); chosen != 2 || <-out != "a" {
+		t.Fatalf("Exec chose %d, want the send", chosen)
+	}
+
+	// Ignore the send case, so the receive or the default runs.
+	s.SetChan(2, Value{})
+	if chosen, recv, ok := s.Exec(); chosen != 1 || recv.IsValid() || ok {
+		t.Fatalf("Exec = %d, %v, %v, want the default case", chosen, recv, ok)
+	}
+	in <- 7
+	if chosen, recv, ok := s.Exec(); chosen != 0 || recv.Int() != 7 || !ok {
+		t.Fatalf("Exec = %d, %v, %v, want to receive 7", chosen, recv, ok)
+	}
+
+	// Change the value sent, then send it on a new channel.
+	out2 := make(chan string, 1)
+	s.SetSend(2, ValueOf("b"))
+	s.SetChan(2, ValueOf(out2))
+	if chosen, _, _ := s.Exec(); chosen != 2 || <-out2 != "b" {
+		t.Fatalf("Exec chose %d, want the send", chosen)
+	}
+	s.SetSend(2, ValueOf("c"))
+	if chosen, _, _ := s.Exec(); chosen != 2 || <-out2 != "c" {
+		t.Fatalf("Exec chose %d, want the send", chosen)
+	}
+
+	// A channel of another element type.
+	s.SetChan(2, Value{})
+	in2 := make(chan interface{}, 1)
+	s.SetChan(0, ValueOf(in2))
+	in2 <- "x"
+	if chosen, recv, ok := s.Exec(); chosen != 0 || recv.Interface() != "x" || !ok {
+		t.Fatalf("Exec = %d, %v, %v, want to receive x", chosen, recv, ok)
+	}
+	close(in2)
+	if chosen, recv, ok := s.Exec(); chosen != 0 || !recv.IsNil() || ok {
+		t.Fatalf("Exec = %d, %v, %v, want a receive from a closed channel", chosen, recv, ok)
+	}
+
+	shouldPanic("default case", func() { s.SetChan(1, ValueOf(in)) })
+	shouldPanic("not a SendDir case", func() { s.SetSend(0, ValueOf(1)) })
+	shouldPanic("recv-only channel", func() { s.SetChan(2, ValueOf((<-chan string)(out))) })
+	shouldPanic("send-only channel", func() { s.SetChan(0, ValueOf((chan<- int)(in))) })
+	shouldPanic("not assignable", func() { s.SetChan(2, ValueOf(in)) })
+	s.SetChan(2, ValueOf(out))
+	shouldPanic("not assignable", func() { s.SetSend(2, ValueOf(1)) })
+}
+
+func TestCompileSelectIgnoredCases(t *testing.T) {
+	// Cases compiled without a channel are checked once they get one.
+	c := make(chan *int, 1)
+	s, err := CompileSelect([]SelectCase{
+		{Dir: SelectSend},
+		{Dir: SelectRecv},
+		{Dir: SelectDefault},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chosen, _, _ := s.Exec(); chosen != 2 {
+		t.Fatalf("Exec chose %d, want the default case", chosen)
+	}
+	shouldPanic("missing Send value", func() { s.SetChan(0, ValueOf(c)) })
+	x := new(int)
+	s.SetSend(0, ValueOf(x))
+	s.SetChan(0, ValueOf(c))
+	if chosen, _, _ := s.Exec(); chosen != 0 {
+		t.Fatalf("Exec chose %d, want the send", chosen)
+	}
+	s.SetChan(0, Value{})
+	s.SetChan(1, ValueOf(c))
+	if chosen, recv, ok := s.Exec(); chosen != 1 || recv.Interface() != x || !ok {
+		t.Fatalf("Exec = %d, %v, %v, want to receive %p", chosen, recv, ok, x)
+	}
+}
+
+func TestCompileSelectErrors(t *testing.T) {
+	c := make(chan int)
+	for _, tt := range []struct {
+		cases []SelectCase
+		err   string
+	}{
+		{[]SelectCase{{Dir: 0}}, "case 0: invalid Dir"},
+		{[]SelectCase{{Dir: SelectDefault}, {Dir: SelectDefault}}, "case 1: multiple default cases"},
+		{[]SelectCase{{Dir: SelectDefault, Chan: ValueOf(c)}}, "default case has Chan value"},
+		{[]SelectCase{{Dir: SelectSend, Chan: ValueOf(c)}}, "missing Send value"},
+		{[]SelectCase{{Dir: SelectSend, Chan: ValueOf(c), Send: ValueOf("x")}}, "string is not assignable to type int"},
+		{[]SelectCase{{Dir: SelectSend, Chan: ValueOf((<-chan int)(c)), Send: ValueOf(1)}}, "recv-only channel"},
+		{[]SelectCase{{Dir: SelectRecv, Chan: ValueOf(c), Send: ValueOf(1)}}, "RecvDir case has Send value"},
+		{[]SelectCase{{Dir: SelectRecv, Chan: ValueOf(1)}}, "Chan is not a channel"},
+		{[]SelectCase{{Dir: SelectRecv, Chan: ValueOf((chan<- int)(c))}}, "send-only channel"},
+		{[]SelectCase{{Dir: SelectRecv, Chan: ValueOf(struct{ c chan int }{c}).Field(0)}}, "unexported field"},
+	} {
+		if _, err := CompileSelect(tt.cases); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("CompileSelect(%v) error = %v, want %q", tt.cases, err, tt.err)
+		}
+	}
+}
+
+func TestCompiledSelectAlloc(t *testing.T) {
+	// A send and 15 receives on one channel, so that each Exec
+	// either sends or receives.
+	c := make(chan int, 1)
+	cases := []SelectCase{{Dir: SelectSend, Chan: ValueOf(c), Send: ValueOf(1)}}
+	for i := 0; i < 15; i++ {
+		cases = append(cases, SelectCase{Dir: SelectRecv, Chan: ValueOf(c)})
+	}
+	s, err := CompileSelect(cases)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Exec() // warm up
+	allocs := testing.AllocsPerRun(100, func() {
+		if chosen, recv, _ := s.Exec(); chosen > 0 && recv.Int() != 1 {
+			t.Fatalf("received %v, want 1", recv)
+		}
+	})
+	if allocs > 0 {
+		t.Errorf("allocs per 16-case Exec: want 0 got %f", allocs)
+	}
+}
+
+func BenchmarkCompiledSelect(b *testing.B) {
+	c := make(chan int, 1)
+	cases := []SelectCase{{Dir: SelectSend, Chan: ValueOf(c), Send: ValueOf(1)}}
+	for i := 0; i < 15; i++ {
+		cases = append(cases, SelectCase{Dir: SelectRecv, Chan: ValueOf(c)})
+	}
+	b.Run("Select", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			Select(cases)
+		}
+	})
+	b.Run("Exec", func(b *testing.B) {
+		s, err := CompileSelect(cases)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			s.Exec()
+		}
+	})
+}
+
 type TheNameOfThisTypeIsExactly255BytesLongSoWhenTheCompilerPrependsTheReflectTestPackageNameAndExtraStarTheLinkerRuntimeAndReflectPackagesWillHaveToCorrectlyDecodeTheSecondLengthByte0123456789_0123456789_0123456789_0123456789_0123456789_012345678 int
 
 type nameTest struct {
@@ -5,11 +5,13 @@
 package reflect
 
 import (
+	"errors"
 	"internal/abi"
 	"internal/itoa"
 	"internal/unsafeheader"
 	"math"
 	"runtime"
+	"sync"
 	"unsafe"
 )
 
@@ -1139,6 +1141,19 @@ func (v Value) Close() {
 	chanclose(v.pointer())
 }
 
+// CloseReport closes the channel v, as Close does, and reports what
+// the close found: buffered is the number of values still in the
+// channel's buffer, which receivers may still take, and sendersWoken
+// and receiversWoken are the numbers of goroutines blocked sending on
+// and receiving from v that the close woke. The woken senders panic,
+// as with Close.
+// It panics if v's Kind is not Chan.
+func (v Value) CloseReport() (buffered, sendersWoken, receiversWoken int) {
+	v.mustBe(Chan)
+	v.mustBeExported()
+	return chanclosereport(v.pointer())
+}
+
 // Complex returns v's underlying value, as a complex128.
 // It panics if v's Kind is not Complex64 or Complex128
 func (v Value) Complex() complex128 {
@@ -1878,6 +1893,48 @@ func (v Value) recv(nb bool) (val Value, ok bool) {
 	return
 }
 
+// RecvZeroCopy receives a value from the channel v, like Recv, but
+// without copying it out of the channel's buffer. It returns ptr, a
+// pointer to the value, and commit, which releases the buffer slot
+// holding the value. Until commit is called, senders treat the slot
+// as occupied, so a receiver that does not call commit eventually
+// blocks the channel's senders.
+//
+// The caller must call commit exactly once, after it is done with the
+// value; a second call panics. The value must not be modified through
+// ptr, and neither ptr nor anything derived from it may be used after
+// commit returns, since the slot is then reused for a later send.
+//
+// If the value is not in the buffer, as when v is unbuffered or the
+// receive had to wait for a sender, RecvZeroCopy copies it to a new
+// variable and commit does nothing but record the call. The boolean
+// ok is true if the value was received from a send and false if it is
+// a zero value received because the channel is closed.
+//
+// It panics if v's Kind is not Chan.
+func (v Value) RecvZeroCopy() (ptr Value, commit func(), ok bool) {
+	v.mustBe(Chan)
+	v.mustBeExported()
+	tt := (*chanType)(unsafe.Pointer(v.typ))
+	if ChanDir(tt.dir)&RecvDir == 0 {
+		panic("reflect: recv on send-only channel")
+	}
+	ch := v.pointer()
+	p, i, ok := chanborrow(ch, unsafe_New(tt.elem))
+	committed := false
+	commit = func() {
+		if committed {
+			panic("reflect: RecvZeroCopy commit called more than once")
+		}
+		committed = true
+		if i >= 0 {
+			chancommit(ch, i)
+		}
+	}
+	pt := tt.elem.ptrTo()
+	return Value{pt, p, flag(Ptr)}, commit, ok
+}
+
 // Send sends x on the channel v.
 // It panics if v's kind is not Chan or if x's type is not the same type as v's element type.
 // As in Go, x's value must be assignable to the channel's element type.
@@ -2031,6 +2088,64 @@ func (v Value) SetCap(n int) {
 	s.Cap = n
 }
 
+// SetChanLabel attaches label to the channel v, replacing any label
+// it had; an empty label removes it. The runtime shows the label
+// wherever it describes the channel: in the tracebacks of goroutines
+// blocked on it, in execution traces, in runtime/debug.DumpChannels,
+// and in the reports of the -test.chanleak flag. Labels tell apart
+// channels that are otherwise alike, such as those made by a helper
+// called from many places.
+// It panics if v's Kind is not Chan or if v is a nil channel.
+func (v Value) SetChanLabel(label string) {
+	v.mustBe(Chan)
+	v.mustBeExported()
+	ch := v.pointer()
+	if ch == nil {
+		panic("reflect: SetChanLabel of nil channel")
+	}
+	chansetlabel(ch, label)
+}
+
+// ChanStats holds the statistics of a channel, recorded since
+// Value.EnableChanStats was first called on it. Each value that goes
+// through the channel counts once in Sends and once in Recvs, whether
+// or not the sender or receiver blocked; receives of the zero value
+// from a closed channel are not counted.
+type ChanStats struct {
+	MaxLen  int    // most values the channel's buffer held at once
+	Sends   uint64 // values sent
+	Recvs   uint64 // values received
+	MaxWait int64  // longest time in nanoseconds a send, receive or select blocked on the channel
+}
+
+// EnableChanStats starts recording statistics for the channel v, which
+// ChanStats returns. Until it is called, a channel records nothing, at
+// the cost of one check per operation. Calling it again does nothing.
+// It panics if v's Kind is not Chan or if v is a nil channel.
+func (v Value) EnableChanStats() {
+	v.mustBe(Chan)
+	v.mustBeExported()
+	ch := v.pointer()
+	if ch == nil {
+		panic("reflect: EnableChanStats of nil channel")
+	}
+	chanenablestats(ch)
+}
+
+// ChanStats returns the statistics recorded for the channel v. The
+// boolean ok is false if they are not enabled; see EnableChanStats.
+// It panics if v's Kind is not Chan.
+func (v Value) ChanStats() (s ChanStats, ok bool) {
+	v.mustBe(Chan)
+	v.mustBeExported()
+	ch := v.pointer()
+	if ch == nil {
+		return ChanStats{}, false
+	}
+	s.MaxLen, s.Sends, s.Recvs, s.MaxWait, ok = chanstats(ch)
+	return s, ok
+}
+
 // SetMapIndex sets the element associated with key in the map v to elem.
 // It panics if v's Kind is not Map.
 // If elem is the zero Value, SetMapIndex deletes the key from the map.
@@ -2255,6 +2370,34 @@ func (v Value) TrySend(x Value) bool {
 	return v.send(x, true)
 }
 
+// TrySendFront attempts to send x on the channel v ahead of the values
+// in its buffer, so that the next receive gets x, but will not block.
+// The values already buffered keep their order. It panics if v's Kind
+// is not Chan. It reports whether the value was sent.
+//
+// If a receiver is waiting, it gets x directly, as with TrySend.
+// Otherwise x is sent only if the buffer has room; a channel with
+// slots borrowed by RecvZeroCopy has none at its head, and
+// TrySendFront fails as if it were full.
+// As in Go, x's value must be assignable to the channel's element type.
+func (v Value) TrySendFront(x Value) bool {
+	v.mustBe(Chan)
+	v.mustBeExported()
+	tt := (*chanType)(unsafe.Pointer(v.typ))
+	if ChanDir(tt.dir)&SendDir == 0 {
+		panic("reflect: send on recv-only channel")
+	}
+	x.mustBeExported()
+	x = x.assignTo("reflect.Value.TrySendFront", tt.elem, nil)
+	var p unsafe.Pointer
+	if x.flag&flagIndir != 0 {
+		p = x.ptr
+	} else {
+		p = unsafe.Pointer(&x.ptr)
+	}
+	return chansendfront(v.pointer(), p)
+}
+
 // Type returns v's type.
 func (v Value) Type() Type {
 	f := v.flag
@@ -2630,6 +2773,612 @@ func Select(cases []SelectCase) (chosen int, recv Value, recvOK bool) {
 	return chosen, recv, recvOK
 }
 
+// A CompiledSelect is a select operation, prepared by CompileSelect,
+// that can be executed any number of times. Select checks its cases and
+// converts them for the runtime every time it runs; CompileSelect does
+// so once, and gives each case the memory it sends from or receives
+// into, so that Exec does not allocate. SetChan and SetSend change the
+// cases between executions.
+//
+// A CompiledSelect must not be used by more than one goroutine at a
+// time.
+type CompiledSelect struct {
+	cases  []compiledCase
+	sel    []runtimeCase // the cases as selectgo takes them
+	orig   []int         // index in cases of each entry of sel
+	order  []uint16      // selectgo's poll and lock orders
+	nsends int
+	nrecvs int
+	dflt   int // index of the default case, or -1
+}
+
+// A runtimeCase is a case of a compiled select in the form selectgo
+// takes. The sends come first, followed by the receives in reverse
+// order.
+// This must match ../runtime/select.go:/scase
+type runtimeCase struct {
+	ch  unsafe.Pointer // channel, or nil if the case is ignored
+	val unsafe.Pointer // ptr to data (SendDir) or ptr to receive buffer (RecvDir)
+}
+
+// A compiledCase is a case of a CompiledSelect.
+type compiledCase struct {
+	dir  SelectDir
+	sel  int            // index in CompiledSelect.sel
+	elem *rtype         // element type of the last channel of the case
+	buf  unsafe.Pointer // value to send, or receive buffer, of type elem
+	send Value          // value to send, as given
+}
+
+// CompileSelect prepares the select operation described by cases, as
+// Select would run it, for execution with Exec. It returns an error if
+// Select would panic on cases.
+func CompileSelect(cases []SelectCase) (*CompiledSelect, error) {
+	if len(cases) > 65536 {
+		return nil, errors.New("reflect.CompileSelect: too many cases (max 65536)")
+	}
+	s := &CompiledSelect{cases: make([]compiledCase, len(cases)), dflt: -1}
+	for i, c := range cases {
+		if err := checkSelectCase(c, s.dflt >= 0); err != "" {
+			return nil, errors.New("reflect.CompileSelect: case " + itoa.Itoa(i) + ": " + err)
+		}
+		s.cases[i].dir = c.Dir
+		switch c.Dir {
+		case SelectDefault:
+			s.dflt = i
+		case SelectSend:
+			s.nsends++
+		case SelectRecv:
+			s.nrecvs++
+		}
+	}
+	n := s.nsends + s.nrecvs
+	s.sel = make([]runtimeCase, n)
+	s.orig = make([]int, n)
+	s.order = make([]uint16, 2*n)
+	nsends, nrecvs := 0, 0
+	for i, c := range cases {
+		cc := &s.cases[i]
+		switch c.Dir {
+		case SelectSend:
+			cc.sel = nsends
+			nsends++
+			cc.send = c.Send
+		case SelectRecv:
+			nrecvs++
+			cc.sel = n - nrecvs
+		default:
+			continue
+		}
+		s.orig[cc.sel] = i
+		if c.Chan.IsValid() {
+			s.setChan(cc, c.Chan, "reflect.CompileSelect")
+		}
+	}
+	return s, nil
+}
+
+// checkSelectCase returns why c is not a valid select case, or "" if
+// it is. haveDefault reports whether an earlier case is a default case.
+func checkSelectCase(c SelectCase, haveDefault bool) string {
+	switch c.Dir {
+	default:
+		return "invalid Dir"
+
+	case SelectDefault:
+		if haveDefault {
+			return "multiple default cases"
+		}
+		if c.Chan.IsValid() {
+			return "default case has Chan value"
+		}
+		if c.Send.IsValid() {
+			return "default case has Send value"
+		}
+
+	case SelectSend:
+		ch := c.Chan
+		if !ch.IsValid() {
+			break
+		}
+		if ch.kind() != Chan {
+			return "Chan is not a channel"
+		}
+		if ch.flag&flagRO != 0 {
+			return "Chan obtained using unexported field"
+		}
+		tt := (*chanType)(unsafe.Pointer(ch.typ))
+		if ChanDir(tt.dir)&SendDir == 0 {
+			return "SendDir case using recv-only channel"
+		}
+		v := c.Send
+		if !v.IsValid() {
+			return "SendDir case missing Send value"
+		}
+		if v.flag&flagRO != 0 {
+			return "Send obtained using unexported field"
+		}
+		if vt := v.Type().common(); !directlyAssignable(tt.elem, vt) && !implements(tt.elem, vt) {
+			return "value of type " + vt.String() + " is not assignable to type " + tt.elem.String()
+		}
+
+	case SelectRecv:
+		if c.Send.IsValid() {
+			return "RecvDir case has Send value"
+		}
+		ch := c.Chan
+		if !ch.IsValid() {
+			break
+		}
+		if ch.kind() != Chan {
+			return "Chan is not a channel"
+		}
+		if ch.flag&flagRO != 0 {
+			return "Chan obtained using unexported field"
+		}
+		tt := (*chanType)(unsafe.Pointer(ch.typ))
+		if ChanDir(tt.dir)&RecvDir == 0 {
+			return "RecvDir case using send-only channel"
+		}
+	}
+	return ""
+}
+
+// Exec executes the select operation, as Select does with the cases s
+// was compiled from, as changed since by SetChan and SetSend.
+//
+// The value received by a receive case is kept in memory that belongs
+// to the case, unless the element type is one that a Value holds
+// directly, such as a pointer or a map. The Value that Exec returns
+// then refers to that memory, and holds the value received only until
+// the next call to Exec; use Value.Interface or a method such as
+// Value.Int to keep it.
+func (s *CompiledSelect) Exec() (chosen int, recv Value, recvOK bool) {
+	chosen, recvOK = rselectcompiled(s.sel, s.orig, s.order, s.nsends, s.nrecvs, s.dflt < 0)
+	if chosen < 0 {
+		return s.dflt, Value{}, false
+	}
+	chosen = s.orig[chosen]
+	if c := &s.cases[chosen]; c.dir == SelectRecv {
+		t := c.elem
+		fl := flag(t.Kind())
+		if ifaceIndir(t) {
+			recv = Value{t, c.buf, fl | flagIndir}
+		} else {
+			recv = Value{t, *(*unsafe.Pointer)(c.buf), fl}
+		}
+	}
+	return chosen, recv, recvOK
+}
+
+// SetChan sets the channel of case i to ch, as if ch had been the Chan
+// of the case when s was compiled. If ch is the zero Value, the case is
+// ignored. A send case sends its Send value, as last set by SetSend,
+// on the new channel.
+// It panics if case i is the default case, or if ch is not valid as
+// the Chan of the case.
+func (s *CompiledSelect) SetChan(i int, ch Value) {
+	c := &s.cases[i]
+	if c.dir == SelectDefault {
+		panic("reflect.CompiledSelect.SetChan: default case")
+	}
+	if !ch.IsValid() {
+		s.sel[c.sel] = runtimeCase{}
+		return
+	}
+	ch.mustBe(Chan)
+	ch.mustBeExported()
+	tt := (*chanType)(unsafe.Pointer(ch.typ))
+	if c.dir == SelectSend && ChanDir(tt.dir)&SendDir == 0 {
+		panic("reflect.CompiledSelect.SetChan: SendDir case using recv-only channel")
+	}
+	if c.dir == SelectRecv && ChanDir(tt.dir)&RecvDir == 0 {
+		panic("reflect.CompiledSelect.SetChan: RecvDir case using send-only channel")
+	}
+	s.setChan(c, ch, "reflect.CompiledSelect.SetChan")
+}
+
+// setChan sets the channel of c to ch, which has been checked. If the
+// element type changes, it gives c a new buffer, holding the value to
+// send for a send case.
+func (s *CompiledSelect) setChan(c *compiledCase, ch Value, context string) {
+	tt := (*chanType)(unsafe.Pointer(ch.typ))
+	if c.elem != tt.elem {
+		buf := unsafe_New(tt.elem)
+		if c.dir == SelectSend {
+			storeSend(c.send, tt.elem, buf, context)
+		}
+		c.elem, c.buf = tt.elem, buf
+	}
+	s.sel[c.sel] = runtimeCase{ch.pointer(), c.buf}
+}
+
+// SetSend sets the value that send case i sends to x. If the case has
+// never had a channel, x is not checked until SetChan gives it one.
+// It panics if case i is not a send case, or if x is not valid as the
+// Send value of the case.
+func (s *CompiledSelect) SetSend(i int, x Value) {
+	c := &s.cases[i]
+	if c.dir != SelectSend {
+		panic("reflect.CompiledSelect.SetSend: not a SendDir case")
+	}
+	if c.elem != nil {
+		storeSend(x, c.elem, c.buf, "reflect.CompiledSelect.SetSend")
+	}
+	c.send = x
+}
+
+// storeSend copies the value to send v, converted to elem, into buf.
+func storeSend(v Value, elem *rtype, buf unsafe.Pointer, context string) {
+	if !v.IsValid() {
+		panic(context + ": SendDir case missing Send value")
+	}
+	v.mustBeExported()
+	v = v.assignTo(context, elem, buf)
+	switch {
+	case v.flag&flagIndir != 0:
+		typedmemmove(elem, buf, v.ptr)
+	case elem.Kind() == Interface:
+		// assignTo returns nil interfaces without memory.
+		typedmemclr(elem, buf)
+	default:
+		*(*unsafe.Pointer)(buf) = v.ptr
+	}
+}
+
+// rselectcompiled runs a compiled select. It returns the index in sel
+// of the chosen case, or -1 if the default case was chosen.
+//go:noescape
+func rselectcompiled(sel []runtimeCase, orig []int, order []uint16, nsends, nrecvs int, block bool) (chosen int, recvOK bool)
+
+// A ChanSet is a set of channels to receive from, on which a goroutine
+// can wait again and again for one of them to become ready. Unlike a
+// Select over the same channels, which registers with each of them
+// every time it runs, a ChanSet registers with a channel once, when it
+// is added, so a Wait costs the same however many channels the set
+// holds. This suits a goroutine serving a large, slowly changing
+// collection of channels.
+//
+// The methods of a ChanSet may be called from several goroutines
+// simultaneously, except that only one goroutine may be in Wait at a
+// time.
+type ChanSet struct {
+	s unsafe.Pointer // runtime set
+
+	mu    sync.Mutex
+	ids   map[unsafe.Pointer]int // entry ids of the channels in the set
+	chans map[int]Value          // channels by entry id
+	next  int                    // next entry id
+}
+
+// NewChanSet returns a new, empty ChanSet.
+func NewChanSet() *ChanSet {
+	s := &ChanSet{
+		s:     chansetnew(),
+		ids:   make(map[unsafe.Pointer]int),
+		chans: make(map[int]Value),
+	}
+	// The channels refer to the runtime set, so it must be emptied
+	// before it can be freed.
+	runtime.SetFinalizer(s, func(s *ChanSet) { chansetclear(s.s) })
+	return s
+}
+
+// Add adds the channel ch to s and reports whether it did; it does
+// not if ch is in s already.
+// It panics if ch's Kind is not Chan, if ch is a nil channel, or if
+// ch's direction does not allow receives.
+func (s *ChanSet) Add(ch Value) bool {
+	ch.mustBe(Chan)
+	ch.mustBeExported()
+	tt := (*chanType)(unsafe.Pointer(ch.typ))
+	if ChanDir(tt.dir)&RecvDir == 0 {
+		panic("reflect: ChanSet.Add of send-only channel")
+	}
+	c := ch.pointer()
+	if c == nil {
+		panic("reflect: ChanSet.Add of nil channel")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.ids[c]; ok {
+		return false
+	}
+	id := s.next
+	s.next++
+	s.ids[c] = id
+	s.chans[id] = ch
+	chansetadd(s.s, c, id)
+	return true
+}
+
+// Remove removes the channel ch from s and reports whether it was in
+// s. Once Remove returns, Wait no longer returns ch, unless it is
+// added again.
+// It panics if ch's Kind is not Chan.
+func (s *ChanSet) Remove(ch Value) bool {
+	ch.mustBe(Chan)
+	c := ch.pointer()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id, ok := s.ids[c]
+	if !ok {
+		return false
+	}
+	delete(s.ids, c)
+	delete(s.chans, id)
+	// The runtime may have removed ch already, if it was closed.
+	chansetremove(s.s, c)
+	return true
+}
+
+// Len returns the number of channels in s.
+func (s *ChanSet) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.ids)
+}
+
+// Wait blocks until a receive from one of the channels in s would not
+// block, and returns that channel. If there are several, the channels
+// take turns. Wait does not receive from the channel, and another
+// goroutine may receive from it first, so the caller should use
+// TryRecv on it. A channel that is ready stays ready, and is returned
+// again by later Waits, until it is drained.
+//
+// Wait reports closed if the channel is closed and empty. It returns a
+// closed channel once, as it then removes the channel from s.
+//
+// Wait blocks forever if s is empty and no channel is added to it. It
+// panics if another goroutine is in Wait on s.
+func (s *ChanSet) Wait() (ch Value, closed bool) {
+	for {
+		id, closed := chansetwait(s.s)
+		s.mu.Lock()
+		ch, ok := s.chans[id]
+		if ok && closed {
+			delete(s.ids, ch.pointer())
+			delete(s.chans, id)
+		}
+		s.mu.Unlock()
+		// The channel may have been removed since it became ready.
+		if ok {
+			return ch, closed
+		}
+	}
+}
+
+// ChanMerge forwards the values received from the channels srcs to the
+// channel dst, as a goroutine per source copying its values to dst
+// would, but with a single goroutine, which waits on all the sources
+// with a ChanSet. The values of each source are sent on dst in the
+// order they were sent on the source; the values of different sources
+// are interleaved in no particular order. Once every source is closed
+// and all the values received from them have been sent, ChanMerge
+// closes dst.
+//
+// ChanMerge saves only the goroutines. It does not copy values
+// directly from a source to dst's receivers or buffer: the forwarding
+// goroutine receives each value into a variable of its own and sends
+// it on dst from there, with ordinary channel operations, so each
+// value is copied twice, as it is with a goroutine per source.
+//
+// ChanMerge returns at once. The forwarding goroutine holds at most one
+// value at a time, blocking until dst takes it, so a slow receiver on
+// dst holds the senders back, if with less slack than a goroutine per
+// source would give them. Values that other goroutines receive
+// from the sources are not forwarded. Closing dst before ChanMerge
+// does is an error, which panics like any send on a closed channel.
+//
+// It panics if dst's Kind is not Chan, if dst is a nil channel or does
+// not allow sends, or if a source is not a channel of dst's type, or
+// of a directional type with dst's element type, that allows receives.
+// Sources may not be nil or dst itself; a source passed twice is
+// forwarded once.
+func ChanMerge(dst Value, srcs []Value) {
+	dst.mustBe(Chan)
+	dst.mustBeExported()
+	dt := (*chanType)(unsafe.Pointer(dst.typ))
+	if ChanDir(dt.dir)&SendDir == 0 {
+		panic("reflect.ChanMerge: receive-only destination channel")
+	}
+	d := dst.pointer()
+	if d == nil {
+		panic("reflect.ChanMerge: nil destination channel")
+	}
+	set := NewChanSet()
+	for _, src := range srcs {
+		src.mustBe(Chan)
+		src.mustBeExported()
+		st := (*chanType)(unsafe.Pointer(src.typ))
+		if ChanDir(st.dir)&RecvDir == 0 {
+			panic("reflect.ChanMerge: send-only source channel")
+		}
+		if st.elem != dt.elem {
+			panic("reflect.ChanMerge: source element type " + st.elem.String() + " is not destination element type " + dt.elem.String())
+		}
+		switch src.pointer() {
+		case nil:
+			panic("reflect.ChanMerge: nil source channel")
+		case d:
+			panic("reflect.ChanMerge: destination channel is a source")
+		}
+		set.Add(src)
+	}
+	go chanMerge(d, dt.elem, set)
+}
+
+// chanMerge forwards the values of the channels in set to dst, whose
+// element type is elem, and closes dst once they are all closed.
+// Each value goes through val, with an ordinary receive and send.
+func chanMerge(dst unsafe.Pointer, elem *rtype, set *ChanSet) {
+	val := unsafe_New(elem)
+	for set.Len() > 0 {
+		src, closed := set.Wait()
+		if closed {
+			continue
+		}
+		// Another receiver may have drained src since it was ready.
+		if _, received := chanrecv(src.pointer(), true, val); !received {
+			continue
+		}
+		chansend(dst, val, false)
+		// Do not keep the value alive until the next one.
+		typedmemclr(elem, val)
+	}
+	chanclose(dst)
+}
+
+// A BroadcastPolicy tells a Broadcast what to do with a send when its
+// slowest receiver lags a whole Broadcast behind.
+type BroadcastPolicy int
+
+const (
+	// BroadcastBlock makes the sender wait for the slowest receiver.
+	BroadcastBlock BroadcastPolicy = iota
+	// BroadcastDrop overwrites the oldest value, which the slow
+	// receivers miss.
+	BroadcastDrop
+)
+
+// A Broadcast is a channel whose every value is received by every
+// receiver: the values sent on it are delivered to all the
+// BroadcastReceivers subscribed to it at the time of the send, each of
+// which receives them in the order they were sent, at its own pace.
+//
+// A Broadcast holds a fixed number of values, its size, for its
+// receivers to catch up with. A send when the slowest receiver has
+// received none of the values held either blocks or drops the oldest
+// value, as the Broadcast's policy says. A value sent with no receiver
+// subscribed is received by none.
+//
+// Closing a Broadcast lets each receiver receive the values it has not
+// yet received, after which its receives report the Broadcast closed,
+// as those on a closed channel do.
+//
+// A Broadcast is not a channel, and cannot be used with the channel
+// operators or in a select. Its methods may be called from several
+// goroutines simultaneously.
+type Broadcast struct {
+	b    unsafe.Pointer // runtime broadcast channel
+	elem *rtype
+}
+
+// MakeBroadcast creates a new Broadcast of values of type elem, which
+// holds size values and follows policy.
+// It panics if size is not positive or policy is not BroadcastBlock or
+// BroadcastDrop.
+func MakeBroadcast(elem Type, size int, policy BroadcastPolicy) *Broadcast {
+	if size <= 0 {
+		panic("reflect.MakeBroadcast: size must be positive")
+	}
+	if policy != BroadcastBlock && policy != BroadcastDrop {
+		panic("reflect.MakeBroadcast: invalid policy")
+	}
+	t := elem.(*rtype)
+	return &Broadcast{b: bcastmake(t, size, policy == BroadcastDrop), elem: t}
+}
+
+// Elem returns the type of the values of b.
+func (b *Broadcast) Elem() Type {
+	return toType(b.elem)
+}
+
+// Send sends x on b. Under the BroadcastBlock policy, it blocks while
+// b's slowest receiver has received none of the values b holds.
+// It panics if b is closed. As in Go, x's value must be assignable to
+// b's element type.
+func (b *Broadcast) Send(x Value) {
+	b.send(x, false)
+}
+
+// TrySend attempts to send x on b without blocking, and reports
+// whether it did. It panics if b is closed. As in Go, x's value must
+// be assignable to b's element type.
+func (b *Broadcast) TrySend(x Value) bool {
+	return b.send(x, true)
+}
+
+func (b *Broadcast) send(x Value, nb bool) bool {
+	x.mustBeExported()
+	x = x.assignTo("reflect.Broadcast.Send", b.elem, nil)
+	var p unsafe.Pointer
+	if x.flag&flagIndir != 0 {
+		p = x.ptr
+	} else {
+		p = unsafe.Pointer(&x.ptr)
+	}
+	return bcastsend(b.b, p, nb)
+}
+
+// Close closes b. It panics if b is closed already.
+func (b *Broadcast) Close() {
+	bcastclose(b.b)
+}
+
+// Subscribe returns a new receiver of the values sent on b from now on.
+func (b *Broadcast) Subscribe() *BroadcastReceiver {
+	r := &BroadcastReceiver{r: bcastsubscribe(b.b), elem: b.elem}
+	// A receiver that is no longer used must not hold back the
+	// senders.
+	runtime.SetFinalizer(r, (*BroadcastReceiver).Close)
+	return r
+}
+
+// A BroadcastReceiver receives the values sent on a Broadcast. Its
+// methods may be called from several goroutines simultaneously, each
+// value then being received by one of them.
+type BroadcastReceiver struct {
+	r    unsafe.Pointer // runtime broadcast subscriber
+	elem *rtype
+}
+
+// Recv receives the next value sent on the receiver's Broadcast,
+// blocking until there is one. The boolean ok is true if x is a value
+// sent, and false if it is a zero value received because the Broadcast
+// is closed and r has received all its values, or because r is closed.
+func (r *BroadcastReceiver) Recv() (x Value, ok bool) {
+	return r.recv(false)
+}
+
+// TryRecv attempts to receive the next value sent on the receiver's
+// Broadcast without blocking. If there is none yet, x is the zero
+// Value. The boolean ok is as for Recv.
+func (r *BroadcastReceiver) TryRecv() (x Value, ok bool) {
+	return r.recv(true)
+}
+
+func (r *BroadcastReceiver) recv(nb bool) (val Value, ok bool) {
+	t := r.elem
+	val = Value{t, nil, flag(t.Kind())}
+	var p unsafe.Pointer
+	if ifaceIndir(t) {
+		p = unsafe_New(t)
+		val.ptr = p
+		val.flag |= flagIndir
+	} else {
+		p = unsafe.Pointer(&val.ptr)
+	}
+	selected, ok := bcastrecv(r.r, nb, p)
+	if !selected {
+		val = Value{}
+	}
+	return
+}
+
+// Dropped returns the number of values that r missed because they were
+// overwritten, under the BroadcastDrop policy, before r received them.
+func (r *BroadcastReceiver) Dropped() uint64 {
+	return bcastdropped(r.r)
+}
+
+// Close unsubscribes r from its Broadcast, so that it no longer holds
+// back the senders. Receives with r, including those blocked, then
+// report the Broadcast closed. Closing r again does nothing.
+func (r *BroadcastReceiver) Close() {
+	bcastunsubscribe(r.r)
+}
+
 /*
  * constructors
  */
@@ -2674,6 +3423,61 @@ func MakeChan(typ Type, buffer int) Value {
 	return Value{t, ch, flag(Chan)}
 }
 
+// MakeChanFilled creates a new channel with the specified type and
+// buffer size, whose buffer holds the elements of the slice initial,
+// as if they had been sent on the channel in order.
+// It panics if initial is not a slice, if its element type is not
+// the channel's element type, or if it is longer than the buffer.
+func MakeChanFilled(typ Type, buffer int, initial Value) Value {
+	if typ.Kind() != Chan {
+		panic("reflect.MakeChanFilled of non-chan type")
+	}
+	if buffer < 0 {
+		panic("reflect.MakeChanFilled: negative buffer size")
+	}
+	if typ.ChanDir() != BothDir {
+		panic("reflect.MakeChanFilled: unidirectional channel type")
+	}
+	initial.mustBe(Slice)
+	initial.mustBeExported()
+	t := typ.(*rtype)
+	typesMustMatch("reflect.MakeChanFilled", t.Elem(), initial.typ.Elem())
+	s := (*unsafeheader.Slice)(initial.ptr)
+	if s.Len > buffer {
+		panic("reflect.MakeChanFilled: initial slice longer than buffer")
+	}
+	ch := makechanfilled(t, buffer, s.Data, s.Len)
+	return Value{t, ch, flag(Chan)}
+}
+
+// MakeChanWithBuffer creates a new channel with the specified type
+// whose buffer is the backing array of the slice buf, instead of newly
+// allocated memory. The channel's capacity is buf.Len(), and it starts
+// out empty, whatever the slice holds.
+// It panics if buf is not a slice or if its element type is not the
+// channel's element type.
+//
+// The backing array can be reused for a new channel once the old one
+// is no longer used, which avoids allocating and freeing a buffer for
+// each channel. Until then it belongs to the channel: reading or
+// writing the elements of buf, other than through channel operations,
+// is a data race, with the same undefined results as any other.
+func MakeChanWithBuffer(typ Type, buf Value) Value {
+	if typ.Kind() != Chan {
+		panic("reflect.MakeChanWithBuffer of non-chan type")
+	}
+	if typ.ChanDir() != BothDir {
+		panic("reflect.MakeChanWithBuffer: unidirectional channel type")
+	}
+	buf.mustBe(Slice)
+	buf.mustBeExported()
+	t := typ.(*rtype)
+	typesMustMatch("reflect.MakeChanWithBuffer", t.Elem(), buf.typ.Elem())
+	s := (*unsafeheader.Slice)(buf.ptr)
+	ch := makechanbuf(t, s.Len, s.Data)
+	return Value{t, ch, flag(Chan)}
+}
+
 // MakeMap creates a new map with the specified type.
 func MakeMap(typ Type) Value {
 	return MakeMapWithSize(typ, 0)
@@ -3151,6 +3955,7 @@ func cvtI2I(v Value, typ Type) Value {
 // implemented in ../runtime
 func chancap(ch unsafe.Pointer) int
 func chanclose(ch unsafe.Pointer)
+func chanclosereport(ch unsafe.Pointer) (buffered, senders, receivers int)
 func chanlen(ch unsafe.Pointer) int
 
 // Note: some of the noescape annotations below are technically a lie,
@@ -3167,7 +3972,38 @@ func chanrecv(ch unsafe.Pointer, nb bool, val unsafe.Pointer) (selected, receive
 //go:noescape
 func chansend(ch unsafe.Pointer, val unsafe.Pointer, nb bool) bool
 
+//go:noescape
+func chansendfront(ch unsafe.Pointer, val unsafe.Pointer) bool
+
+func chanborrow(ch unsafe.Pointer, val unsafe.Pointer) (p unsafe.Pointer, i int, received bool)
+func chancommit(ch unsafe.Pointer, i int)
+func chansetlabel(ch unsafe.Pointer, label string)
+func chanenablestats(ch unsafe.Pointer)
+func chanstats(ch unsafe.Pointer) (maxLen int, sends, recvs uint64, maxWait int64, ok bool)
+
+func chansetnew() unsafe.Pointer
+func chansetadd(s, ch unsafe.Pointer, id int) bool
+func chansetremove(s, ch unsafe.Pointer) bool
+func chansetclear(s unsafe.Pointer)
+func chansetwait(s unsafe.Pointer) (id int, closed bool)
+
+func bcastmake(elem *rtype, size int, drop bool) unsafe.Pointer
+
+//go:noescape
+func bcastsend(b unsafe.Pointer, val unsafe.Pointer, nb bool) bool
+
+func bcastclose(b unsafe.Pointer)
+func bcastsubscribe(b unsafe.Pointer) unsafe.Pointer
+
+//go:noescape
+func bcastrecv(r unsafe.Pointer, nb bool, val unsafe.Pointer) (selected, received bool)
+
+func bcastdropped(r unsafe.Pointer) uint64
+func bcastunsubscribe(r unsafe.Pointer)
+
 func makechan(typ *rtype, size int) (ch unsafe.Pointer)
+func makechanfilled(typ *rtype, size int, src unsafe.Pointer, n int) (ch unsafe.Pointer)
+func makechanbuf(typ *rtype, size int, buf unsafe.Pointer) (ch unsafe.Pointer)
 func makemap(t *rtype, cap int) (m unsafe.Pointer)
 
 //go:noescape
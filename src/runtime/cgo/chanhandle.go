@@ -0,0 +1,175 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cgo
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// ChanHandle lets C code send values on a Go channel, and receive
+// values from it, without calling back into Go code. A ChanHandle is
+// an integer value, like a Handle, that C code passes to these
+// functions, which this package provides to C:
+//
+//	#include <stddef.h> // for size_t
+//	#include <stdint.h> // for uintptr_t
+//
+//	int GoChanTrySend(uintptr_t handle, const void *value, size_t size);
+//	int GoChanTryRecv(uintptr_t handle, void *value, size_t size);
+//
+// GoChanTrySend sends the size bytes at value on the channel, and
+// GoChanTryRecv receives a value from the channel into the size bytes
+// at value, but only if they can do so without blocking. size must be
+// the size of the channel's element type. They return 1 if a value
+// was sent or received, 0 if the operation would block, and -1 if the
+// channel is closed; GoChanTryRecv returns -1 only once all buffered
+// values have been received, and then zeroes the value. The functions
+// may be called on any thread, including threads not created by Go.
+// They enter Go the same way calls of exported Go functions do, but
+// run no Go code other than the channel operation.
+//
+// The element type of the channel must not contain Go pointers, since
+// C code cannot hold Go pointers; NewChanHandle panics otherwise. As
+// with Handle, the program must call Delete once C code no longer uses
+// the handle. Until then the handle keeps the channel alive.
+//
+// For instance, on the Go side:
+//
+//	/*
+//	#include <stdint.h> // for uintptr_t
+//
+//	void start_events(uintptr_t handle);
+//	*/
+//	import "C"
+//	import "runtime/cgo"
+//
+//	func main() {
+//		events := make(chan C.struct_event, 100)
+//		h := cgo.NewChanHandle(events)
+//		defer h.Delete()
+//		C.start_events(C.uintptr_t(h))
+//		for ev := range events {
+//			...
+//		}
+//	}
+//
+// and on a C thread:
+//
+//	struct event ev = { ... };
+//	if (GoChanTrySend(handle, &ev, sizeof ev) == 0) {
+//		dropped++;
+//	}
+type ChanHandle uintptr
+
+// chanHandle is the channel that a ChanHandle refers to.
+type chanHandle struct {
+	ch       interface{} // keeps the channel alive
+	c        unsafe.Pointer
+	elemsize uintptr
+	dir      uintptr
+}
+
+// Channel directions, as in reflect.ChanDir.
+const (
+	recvDir = 1 << iota
+	sendDir
+)
+
+// NewChanHandle returns a handle for the channel ch. It panics if ch
+// is not a channel, if it is nil, or if its element type contains Go
+// pointers.
+//
+// The handle is valid until the program calls Delete on it.
+func NewChanHandle(ch interface{}) ChanHandle {
+	c, elemsize, dir := _runtime_cgo_chan_check(ch)
+	h := atomic.AddUintptr(&handleIdx, 1)
+	if h == 0 {
+		panic("runtime/cgo: ran out of handle space")
+	}
+	chanHandles.Store(h, &chanHandle{ch: ch, c: c, elemsize: elemsize, dir: dir})
+	return ChanHandle(h)
+}
+
+// Delete invalidates a channel handle. It does not close the
+// channel. This method should only be called once C code no longer
+// has a copy of the handle value.
+//
+// The method panics if the handle is invalid.
+func (h ChanHandle) Delete() {
+	_, ok := chanHandles.LoadAndDelete(uintptr(h))
+	if !ok {
+		panic("runtime/cgo: misuse of an invalid ChanHandle")
+	}
+}
+
+// lookup returns the channel that h refers to, checking that C code
+// may use it for an operation in direction dir on size-byte values.
+func (h ChanHandle) lookup(dir, size uintptr) *chanHandle {
+	v, ok := chanHandles.Load(uintptr(h))
+	if !ok {
+		panic("runtime/cgo: misuse of an invalid ChanHandle")
+	}
+	ch := v.(*chanHandle)
+	if ch.dir&dir == 0 {
+		if dir == sendDir {
+			panic("runtime/cgo: GoChanTrySend on receive-only channel")
+		}
+		panic("runtime/cgo: GoChanTryRecv on send-only channel")
+	}
+	if size != ch.elemsize {
+		panic("runtime/cgo: size passed to GoChanTrySend or GoChanTryRecv does not match channel element size")
+	}
+	return ch
+}
+
+var chanHandles = sync.Map{} // map[ChanHandle]*chanHandle
+
+//go:linkname _runtime_cgo_chan_check runtime._cgo_chan_check
+func _runtime_cgo_chan_check(ch interface{}) (c unsafe.Pointer, elemsize uintptr, dir uintptr)
+
+//go:linkname _runtime_cgo_chan_trysend runtime._cgo_chan_trysend
+func _runtime_cgo_chan_trysend(c unsafe.Pointer, ep unsafe.Pointer) (sent, closed bool)
+
+//go:linkname _runtime_cgo_chan_tryrecv runtime._cgo_chan_tryrecv
+func _runtime_cgo_chan_tryrecv(c unsafe.Pointer, ep unsafe.Pointer) (selected, received bool)
+
+// chanArgs are the arguments and result of GoChanTrySend and
+// GoChanTryRecv, passed through crosscall2. See gcc_chan.c.
+type chanArgs struct {
+	handle uintptr
+	value  unsafe.Pointer
+	size   uintptr
+	result int32
+}
+
+//go:linkname _cgo_chan_trysend _cgo_chan_trysend
+//go:cgo_export_static _cgo_chan_trysend
+//go:cgo_export_dynamic _cgo_chan_trysend
+func _cgo_chan_trysend(a *chanArgs) {
+	ch := ChanHandle(a.handle).lookup(sendDir, a.size)
+	sent, closed := _runtime_cgo_chan_trysend(ch.c, a.value)
+	switch {
+	case sent:
+		a.result = 1
+	case closed:
+		a.result = -1
+	}
+}
+
+//go:linkname _cgo_chan_tryrecv _cgo_chan_tryrecv
+//go:cgo_export_static _cgo_chan_tryrecv
+//go:cgo_export_dynamic _cgo_chan_tryrecv
+func _cgo_chan_tryrecv(a *chanArgs) {
+	ch := ChanHandle(a.handle).lookup(recvDir, a.size)
+	selected, received := _runtime_cgo_chan_tryrecv(ch.c, a.value)
+	switch {
+	case received:
+		a.result = 1
+	case selected:
+		a.result = -1
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cgo
+
+import (
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestNewChanHandleChecks(t *testing.T) {
+	var nilChan chan int
+	for _, tt := range []struct {
+		name string
+		ch   interface{}
+		want string
+	}{
+		{"non-channel", 42, "non-channel"},
+		{"nil interface", nil, "non-channel"},
+		{"nil channel", nilChan, "nil chan int"},
+		{"pointer elem", make(chan *int), "contains Go pointers"},
+		{"string elem", make(chan string), "contains Go pointers"},
+		{"struct elem with pointer", make(chan struct {
+			n int
+			p []byte
+		}), "contains Go pointers"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				e := recover()
+				if e == nil {
+					t.Fatalf("NewChanHandle did not panic")
+				}
+				if err, ok := e.(error); !ok || !strings.Contains(err.Error(), tt.want) {
+					t.Fatalf("NewChanHandle panicked with %v, want %q", e, tt.want)
+				}
+			}()
+			NewChanHandle(tt.ch)
+		})
+	}
+}
+
+func TestChanHandleOps(t *testing.T) {
+	type event struct {
+		id    int64
+		flags [3]byte
+	}
+	c := make(chan event, 1)
+	h := NewChanHandle(c)
+	defer h.Delete()
+
+	call := func(f func(*chanArgs), v *event) int32 {
+		a := chanArgs{handle: uintptr(h), value: unsafe.Pointer(v), size: unsafe.Sizeof(*v)}
+		f(&a)
+		return a.result
+	}
+	send := func(v event) int32 { return call(_cgo_chan_trysend, &v) }
+	recv := func() (event, int32) {
+		var v event
+		r := call(_cgo_chan_tryrecv, &v)
+		return v, r
+	}
+
+	if v, r := recv(); r != 0 {
+		t.Errorf("receive from empty channel = %v, %d; want 0", v, r)
+	}
+	if r := send(event{1, [3]byte{1, 2, 3}}); r != 1 {
+		t.Errorf("send = %d, want 1", r)
+	}
+	if r := send(event{2, [3]byte{}}); r != 0 {
+		t.Errorf("send to full channel = %d, want 0", r)
+	}
+	if v, r := recv(); r != 1 || v != (event{1, [3]byte{1, 2, 3}}) {
+		t.Errorf("receive = %v, %d; want {1 [1 2 3]}, 1", v, r)
+	}
+	c <- event{id: 3}
+	close(c)
+	if r := send(event{4, [3]byte{}}); r != -1 {
+		t.Errorf("send to closed channel = %d, want -1", r)
+	}
+	if v, r := recv(); r != 1 || v.id != 3 {
+		t.Errorf("receive from closed channel with buffered value = %v, %d; want 3, 1", v, r)
+	}
+	if v, r := recv(); r != -1 || v != (event{}) {
+		t.Errorf("receive from closed channel = %v, %d; want zero value, -1", v, r)
+	}
+}
+
+func TestChanHandleMisuse(t *testing.T) {
+	recvOnly := make(<-chan int)
+	h := NewChanHandle(recvOnly)
+	for _, tt := range []struct {
+		name string
+		f    func()
+		want string
+	}{
+		{"send on receive-only", func() {
+			var v int
+			_cgo_chan_trysend(&chanArgs{handle: uintptr(h), value: unsafe.Pointer(&v), size: unsafe.Sizeof(v)})
+		}, "receive-only"},
+		{"wrong size", func() {
+			var v int8
+			_cgo_chan_tryrecv(&chanArgs{handle: uintptr(h), value: unsafe.Pointer(&v), size: unsafe.Sizeof(v)})
+		}, "does not match"},
+		{"deleted handle", func() {
+			h.Delete()
+			var v int
+			_cgo_chan_tryrecv(&chanArgs{handle: uintptr(h), value: unsafe.Pointer(&v), size: unsafe.Sizeof(v)})
+		}, "invalid ChanHandle"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				e := recover()
+				if s, ok := e.(string); !ok || !strings.Contains(s, tt.want) {
+					t.Fatalf("panicked with %v, want %q", e, tt.want)
+				}
+			}()
+			tt.f()
+		})
+	}
+}
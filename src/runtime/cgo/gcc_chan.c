@@ -0,0 +1,55 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build cgo
+// +build aix darwin dragonfly freebsd linux netbsd openbsd solaris windows
+
+#include "libcgo.h"
+
+// Channel operations for C code. See ChanHandle in chanhandle.go.
+
+// Must match chanArgs in chanhandle.go.
+struct chan_args {
+	uintptr_t handle;
+	void *value;
+	uintptr_t size;
+	int32_t result;
+};
+
+extern void crosscall2(void (*fn)(void *), void *, int, uintptr_t);
+extern void _cgo_release_context(uintptr_t);
+
+// Go functions in chanhandle.go.
+extern void _cgo_chan_trysend(void *);
+extern void _cgo_chan_tryrecv(void *);
+
+static int
+chanop(void (*fn)(void *), uintptr_t handle, void *value, size_t size)
+{
+	uintptr_t ctxt;
+	struct chan_args a;
+
+	ctxt = _cgo_wait_runtime_init_done();
+	a.handle = handle;
+	a.value = value;
+	a.size = size;
+	a.result = 0;
+	_cgo_tsan_release();
+	crosscall2(fn, &a, sizeof a, ctxt);
+	_cgo_tsan_acquire();
+	_cgo_release_context(ctxt);
+	return a.result;
+}
+
+int
+GoChanTrySend(uintptr_t handle, const void *value, size_t size)
+{
+	return chanop(_cgo_chan_trysend, handle, (void*)value, size);
+}
+
+int
+GoChanTryRecv(uintptr_t handle, void *value, size_t size)
+{
+	return chanop(_cgo_chan_tryrecv, handle, value, size);
+}
@@ -4,6 +4,8 @@
 
 package runtime
 
+import "unsafe"
+
 // These functions are called from C code via cgo/callbacks.go.
 
 // Panic.
@@ -11,3 +13,37 @@ package runtime
 func _cgo_panic_internal(p *byte) {
 	panic(gostringnocopy(p))
 }
+
+// Channel handles. See runtime/cgo.ChanHandle.
+
+// _cgo_chan_check checks that C code may send and receive on ch and
+// returns the channel, its element size, and its direction.
+func _cgo_chan_check(ch interface{}) (c unsafe.Pointer, elemsize uintptr, dir uintptr) {
+	e := efaceOf(&ch)
+	t := e._type
+	if t == nil || t.kind&kindMask != kindChan {
+		panic(plainError("runtime/cgo: NewChanHandle of non-channel"))
+	}
+	ct := (*chantype)(unsafe.Pointer(t))
+	if e.data == nil {
+		panic(plainError("runtime/cgo: NewChanHandle of nil " + t.string()))
+	}
+	if ct.elem.ptrdata != 0 {
+		panic(plainError("runtime/cgo: NewChanHandle of " + t.string() + ", whose element type contains Go pointers"))
+	}
+	return e.data, ct.elem.size, ct.dir
+}
+
+// _cgo_chan_trysend sends the value at ep on c if it can do so
+// without blocking. If c is closed, it reports closed rather than
+// panicking.
+func _cgo_chan_trysend(c unsafe.Pointer, ep unsafe.Pointer) (sent, closed bool) {
+	sent = chansend((*hchan)(c), ep, false, getcallerpc(), &closed)
+	return sent, closed
+}
+
+// _cgo_chan_tryrecv receives a value from c into ep if it can do so
+// without blocking.
+func _cgo_chan_tryrecv(c unsafe.Pointer, ep unsafe.Pointer) (selected, received bool) {
+	return chanrecv((*hchan)(c), ep, false)
+}
@@ -15,11 +15,13 @@ package runtime
 //
 // For buffered channels, also:
 //  c.qcount > 0 implies that c.recvq is empty.
-//  c.qcount < c.dataqsiz implies that c.sendq is empty.
+//  c.qcount+c.borrows() < c.dataqsiz implies that c.sendq is empty.
 
 import (
+	"internal/cpu"
 	"runtime/internal/atomic"
 	"runtime/internal/math"
+	"runtime/internal/sys"
 	"unsafe"
 )
 
@@ -40,8 +42,21 @@ type hchan struct {
 	buf      unsafe.Pointer
 	// chan 中元素大小
 	elemsize uint16
+	// flags holds how elements are copied, in the chanCopyMask bits,
+	// and the chanNUMAPending and chanExtBuf bits.
+	flags uint8
+	// dirty is the number of slots before recvx that have been
+	// received from but not cleared yet, which is less than
+	// chanDirtyBatch. See chanclear.go.
+	dirty uint8
 	// chan 是否被关闭，非0表示关闭
 	closed   uint32
+	// closedBy is the ID of the goroutine that closed the channel. It
+	// is set before closed, and read by debuggers, crash dumps and the
+	// ClosedChannelError of a send on the closed channel. Where and
+	// when the channel was closed is only recorded in its side state,
+	// with GODEBUG=chanclosecheck=1.
+	closedBy int64
 	// chan 中元素类型
 	elemtype *_type
 	// 生产队列可发送的元素在数组中的索引，即从此处开始写入
@@ -53,6 +68,11 @@ type hchan struct {
 	// 等待发送数据的goroutine队列，生产队列
 	sendq    waitq
 
+	// side is the channel's side state, or nil if it has none. It is
+	// set at most once, by makechan or with c.lock held, and then
+	// kept until the channel is freed; see chanside.go.
+	side *specialChanSide
+
 	// lock protects all fields in hchan, as well as several
 	// fields in sudogs blocked on this channel.
 	//
@@ -64,6 +84,21 @@ type hchan struct {
 	// 在保持此锁时不要更改另一个 G 的状态（特别是不要准备 G），因为这可能会导致堆栈收缩而死锁。
 }
 
+// Bits in hchan.flags.
+const (
+	// chanCopyMask selects the bits that tell how elements are
+	// copied; see chancopy.go.
+	chanCopyMask = 1<<2 - 1
+
+	// chanNUMAPending is set if the buffer is to be moved to the NUMA
+	// node of the first consumer; see chan_numa.go.
+	chanNUMAPending = 1 << 2
+
+	// chanExtBuf is set if buf is storage provided by the caller of
+	// reflect.MakeChanWithBuffer; see chan_extbuf.go.
+	chanExtBuf = 1 << 3
+)
+
 // goroutine 的生产队列或消费队列
 type waitq struct {
 	first *sudog // 指向goroutine队列的第一个
@@ -75,6 +110,48 @@ func reflect_makechan(t *chantype, size int) *hchan {
 	return makechan(t, size)
 }
 
+// reflect_makechanfilled makes a channel whose buffer holds the n
+// elements at src, as if they had been sent on it in order.
+//
+//go:linkname reflect_makechanfilled reflect.makechanfilled
+func reflect_makechanfilled(t *chantype, size int, src unsafe.Pointer, n int) *hchan {
+	c := makechan(t, size)
+	if n == 0 {
+		return c
+	}
+	if uint(n) > c.dataqsiz {
+		throw("makechanfilled: too many elements")
+	}
+	// No other goroutine can see c yet, so there's no need to lock
+	// it, but the race detector is told about each slot as a send
+	// would.
+	if c.elemsize != 0 {
+		if c.elemtype.ptrdata == 0 {
+			slicecopy(c.buf, n, src, n, uintptr(c.elemsize))
+		} else {
+			typedslicecopy(c.elemtype, c.buf, n, src, n)
+		}
+	}
+	if raceenabled {
+		for i := uint(0); i < uint(n); i++ {
+			racenotify(c, i, nil)
+		}
+	}
+	c.qcount = uint(n)
+	c.sendx = uint(n)
+	if c.sendx == c.dataqsiz {
+		c.sendx = 0
+	}
+	if trace.enabled {
+		traceChanBuf(c, 0)
+	}
+	if raceenabled {
+		racechancount(c)
+	}
+	chanStatsOp(uint64(n), 0)
+	return c
+}
+
 func makechan64(t *chantype, size int64) *hchan {
 	if int64(int(size)) != size {
 		panic(plainError("makechan: size out of range"))
@@ -83,7 +160,16 @@ func makechan64(t *chantype, size int64) *hchan {
 	return makechan(t, int(size))
 }
 
+// makechan implements make(chan T, size). Channels are always
+// allocated on the heap, even those that do not escape; see
+// walkMakeChan in cmd/compile/internal/walk.
 func makechan(t *chantype, size int) *hchan {
+	return makechanbuf(t, size, nil)
+}
+
+// makechanbuf makes a channel whose buffer is the size elements at
+// buf, or a newly allocated buffer if buf is nil.
+func makechanbuf(t *chantype, size int, buf unsafe.Pointer) *hchan {
 	elem := t.elem
 
 	// compiler checks this but be safe.
@@ -111,11 +197,24 @@ func makechan(t *chantype, size int) *hchan {
 	var c *hchan
 	switch {
 	case mem == 0:
+		// Nothing is stored in a buffer with no bytes, so a
+		// caller-provided one is not used either.
+		//
 		// 当chan为无缓冲或元素为空结构体时，需要分配的内存为0，仅分配需要存储chan的内存
 		// Queue or element size is zero.
 		c = (*hchan)(mallocgc(hchanSize, nil, true))
 		// Race detector uses this location for synchronization.
 		c.buf = c.raceaddr()
+	case buf != nil:
+		// The buffer is caller-provided. It is reached only
+		// through c.buf, so hchan must be scanned even if the
+		// elements do not contain pointers.
+		c = new(hchan)
+		c.buf = buf
+		c.flags = chanExtBuf
+		if elem.ptrdata != 0 {
+			memclrHasPointers(buf, mem)
+		}
 	case elem.ptrdata == 0:
 		// Elements do not contain pointers.
 		// Allocate hchan and buf in one call.
@@ -134,7 +233,20 @@ func makechan(t *chantype, size int) *hchan {
 	c.elemsize = uint16(elem.size) // 元素大小
 	c.elemtype = elem // 元素类型
 	c.dataqsiz = uint(size) // chan 的容量
+	c.flags |= chanCopyKind(elem)
+	if debug.channuma != chanNUMAOff && c.flags&chanExtBuf == 0 {
+		chanNUMAPlace(c, mem)
+	}
+	if debug.chanhugepage != 0 && c.flags&chanExtBuf == 0 {
+		chanHugePage(c, mem)
+	}
 	lockInit(&c.lock, lockRankHchan) // todo ？
+	chanStatsCreated(size)
+	chanDebugInit(c)
+	chanNumber(c)
+	if debug.chanregistry != 0 || getg().chanLeakScope != 0 || debug.chandropcheck != 0 && size > 0 {
+		chanRegister(c)
+	}
 
 	if debugChan {
 		print("makechan: chan=", c, "; elemsize=", elem.size, "; dataqsiz=", size, "\n")
@@ -167,14 +279,27 @@ func full(c *hchan) bool {
 	}
 	// Assumes that a uint read is relaxed-atomic.
 	// 有缓冲，且缓冲区大小和chan中实际元素个数相等，即满了
-	return c.qcount == c.dataqsiz
+	// A channel with side state may have borrowed slots, so it is
+	// left to the locked path.
+	return c.side == nil && c.qcount == c.dataqsiz
 }
 
 // entry point for c <- x from compiled code
+//
+// Unlike map accesses, sends have no fast path inlined by the compiler,
+// even for buffered channels with room to spare: the buffer, the wait
+// queues and the closed flag are all protected by c.lock, and a send
+// that finds room updates the channel's statistics, last operation
+// site and trace state under it, so there is no lock-free sequence to
+// emit. Every send goes through here, which is also what the race
+// detector relies on to see every send.
 //go:nosplit
 // 编译代码中 C <- X 的入口点
 func chansend1(c *hchan, elem unsafe.Pointer) {
-	chansend(c, elem, true, getcallerpc())
+	chansend(c, elem, true, getcallerpc(), nil)
+	if debug.chanblockwarn > 0 {
+		chanBlockWarnOp(c, true, getcallerpc())
+	}
 }
 
 /*
@@ -184,15 +309,19 @@ func chansend1(c *hchan, elem unsafe.Pointer) {
  * sleep but return if it could
  * not complete.
  *
- * sleep can wake up with g.param == nil
+ * sleep can wake up with sudog.success false
  * when a channel involved in the sleep has
  * been closed.  it is easiest to loop and re-run
  * the operation; we'll see that it's now closed.
  */
 // 一般情况下，单向的接收或发送通道，，但如果无法完成则返回。
-// 当休眠中涉及的通道关闭时，休眠可以使用 g.param == nil 唤醒。循环并重新运行操作最容易;我们将看到它现在已经关闭。
+// 当休眠中涉及的通道关闭时，休眠可以使用 sudog.success == false 唤醒。循环并重新运行操作最容易;我们将看到它现在已经关闭。
 // 返回 false 表示写入失败
-func chansend(c *hchan, ep unsafe.Pointer, block bool, callerpc uintptr) bool {
+//
+// If closedp is not nil, a send on a closed channel sets *closedp and
+// returns false instead of panicking.
+func chansend(c *hchan, ep unsafe.Pointer, block bool, callerpc uintptr, closedp *bool) bool {
+	chancheckctx("send", block)
 	// 如果 block 为 false，协议将不允许被阻塞，不等于非缓冲
 	if c == nil {
 		// chan 为 nil
@@ -237,10 +366,28 @@ func chansend(c *hchan, ep unsafe.Pointer, block bool, callerpc uintptr) bool {
 	// 我们的行为就好像我们当时观察了通道，并报告发送无法继续。如果读取在这里重新排序是可以的：如果我们观察到通道尚未准备好发送，然后观察到它没有关闭，这意味着在第一次观察期间通道没有关闭。
 	// 然而，这里没有任何东西能保证向前推进。我们依靠 chanrecv（） 和 closechan（） 中锁释放的副作用来更新这个线程对 c.closed 和 full（） 的看法。
 	// 非阻塞模式，且chan没有关闭，但已经满了
-	if !block && c.closed == 0 && full(c) {
+	// Some sends fail here anyway in the runtime's tests; see channbfail.go.
+	if !block && c.closed == 0 && (full(c) || chanNBFail(c)) {
 		return false
 	}
 
+	// A send on a closed channel always panics, and a channel can't be
+	// reopened, so there's no need to take the lock to find out. This
+	// keeps a sender spinning on a closed channel from contending with
+	// receivers that are still draining its buffer.
+	if atomic.Load(&c.closed) != 0 {
+		if closedp != nil {
+			*closedp = true
+			return false
+		}
+		chanMisuseSendOnClosed()
+		panic(closedChannelError(c))
+	}
+
+	if debug.chanclosecheck != 0 {
+		chanCloseCheckSend(c, callerpc)
+	}
+
 	// 执行到此处说明是以下3种情况中的某一种或两种
 	// 1，阻塞模式，block==true；
 	// 2，chan 已经关闭；
@@ -248,15 +395,21 @@ func chansend(c *hchan, ep unsafe.Pointer, block bool, callerpc uintptr) bool {
 	// 3.1 无缓冲管道，且接收队列不为空；
 	// 3.2 缓冲管道，但缓冲管道未满
 	var t0 int64
-	if blockprofilerate > 0 {
-		t0 = cputicks()
+	if chanBlockProfileRate() > 0 {
+		t0 = nanotime()
 	}
 
-	lock(&c.lock)
+	chanprefetch(c, c.sendx)
+	lockchan(c)
 	// 2，chan 已经关闭；
 	if c.closed != 0 { // todo 向一个关闭的通道写入数据会panic
-		unlock(&c.lock)
-		panic(plainError("send on closed channel"))
+		unlockchan(c)
+		if closedp != nil {
+			*closedp = true
+			return false
+		}
+		chanMisuseSendOnClosed()
+		panic(closedChannelError(c))
 	}
 
 	// 执行到此处，说明管道是未关闭的，阻塞模式或管道非满
@@ -266,12 +419,13 @@ func chansend(c *hchan, ep unsafe.Pointer, block bool, callerpc uintptr) bool {
 		// Found a waiting receiver. We pass the value we want to send
 		// directly to the receiver, bypassing the channel buffer (if any).
 		// todo 非常细节，找到一个等待的接收器。我们将要发送的值直接传递给接收器，绕过通道缓冲区（如果有的话）。
-		send(c, sg, ep, func() { unlock(&c.lock) }, 3)
+		send(c, sg, ep, func() { unlockchan(c) }, 3)
+		chanprofop(chanProfSend, 1)
 		return true
 	}
 
 	// 3.2 缓冲管道，但没有接收者，且缓冲管道未满；即使非阻塞能写则写
-	if c.qcount < c.dataqsiz {
+	if c.qcount+c.borrows() < c.dataqsiz {
 		// Space is available in the channel buffer. Enqueue the element to send.
 		// 找到最新能够写入元素的位置
 		qp := chanbuf(c, c.sendx)
@@ -279,19 +433,33 @@ func chansend(c *hchan, ep unsafe.Pointer, block bool, callerpc uintptr) bool {
 			racenotify(c, c.sendx, nil)
 		}
 		// 将要写入的元素的值拷贝到该处
-		typedmemmove(c.elemtype, qp, ep)
+		chanmove(c, qp, ep)
 		c.sendx++ // 写入的位置往后移
 		if c.sendx == c.dataqsiz { // 如果等于数组长度，则跳转到首位（循环队列）
 			c.sendx = 0
 		}
 		c.qcount++ // chan 中的元素个数加一
-		unlock(&c.lock)
+		if trace.enabled {
+			traceChanBuf(c, c.qcount-1)
+		}
+		if raceenabled {
+			racechancount(c)
+		}
+		chanStatsRecordOp(c, 1, 0)
+		var wake gList
+		if c.sets() != nil {
+			chanSetNotify(c, &wake)
+		}
+		unlockchan(c)
+		chanSetWakeAll(&wake)
+		chanStatsImmediate(1, 0)
+		chanprofop(chanProfSend, 1)
 		return true
 	}
 
 	// todo 执行到此处，说明如果是无缓冲管道则没有接收者，是缓冲管道则已经满了，下方 block 为 true 下方 if 无法执行？
-	if !block {
-		unlock(&c.lock)
+	if !block || chanCancelled(getg()) {
+		unlockchan(c)
 		return false
 	}
 
@@ -299,7 +467,10 @@ func chansend(c *hchan, ep unsafe.Pointer, block bool, callerpc uintptr) bool {
 	// channel 满了，发送方会被阻塞。接下来会构造一个 sudog
 	// 获取当前发送数据的 goroutine
 	// 然后绑定到一个 sudog 结构体 (包装为运行时表示)
+	reason := chanSendWaitReason(c)
+	parkTime := chanStatsPark(reason, t0)
 	gp := getg()// 获取当前 goroutine 的指针
+	chanWaitStart(gp, parkTime)
 	mysg := acquireSudog() // 返回一个sudog
 	// 获取 sudog 结构体
 	// 并且设置相关字段 (包括当前的 channel，是否是 select 等)
@@ -312,19 +483,37 @@ func chansend(c *hchan, ep unsafe.Pointer, block bool, callerpc uintptr) bool {
 	mysg.elem = ep
 	mysg.waitlink = nil
 	mysg.g = gp
-	mysg.isSelect = false
+	mysg.flags = sudogSend
+	if gp.chanCancel != nil {
+		mysg.flags |= sudogCancelable
+	}
 	mysg.c = c
 	gp.waiting = mysg
-	gp.param = nil
 	// 当前 goroutine 进入发送等待队列
+	if debug.chaninvariants != 0 {
+		chancheckenqueue(c, &c.sendq, mysg)
+	}
 	c.sendq.enqueue(mysg)
+	if debug.chaninvariants != 0 {
+		chancheck(c)
+	}
+	if c.sets() != nil {
+		chanSetNotify(c, &gp.m.chanSetWake)
+	}
+	if trace.enabled {
+		traceChanDescribe(c)
+	}
 	// Signal to anyone trying to shrink our stack that we're about
 	// to park on a channel. The window between when this G's status
 	// changes and when we set gp.activeStackChans is not safe for
 	// stack shrinking.
 	atomic.Store8(&gp.parkingOnChan, 1)
+	commit := chanparkcommit
+	if mysg.cancelable() {
+		commit = chancancelparkcommit
+	}
 	// 挂起当前 goroutine, 进入休眠 (等待接收)
-	gopark(chanparkcommit, unsafe.Pointer(&c.lock), waitReasonChanSend, traceEvGoBlockSend, 2)
+	gopark(commit, unsafe.Pointer(&c.lock), reason, traceEvGoBlockSend, 2)
 	// Ensure the value being sent is kept alive until the
 	// receiver copies it out. The sudog has a pointer to the
 	// stack object, but sudogs aren't considered as roots of the
@@ -334,31 +523,68 @@ func chansend(c *hchan, ep unsafe.Pointer, block bool, callerpc uintptr) bool {
 	KeepAlive(ep)
 
 	// someone woke us up.
+	chanStatsUnpark(reason, parkTime)
+	chanStatsRecordWait(c, parkTime)
 
 	// 从这里开始被唤醒了（channel 有机会可以发送了）
 	if mysg != gp.waiting {
+		printwaitingcorrupt(c, mysg, gp)
 		throw("G waiting list is corrupted")
 	}
+	if gp.param != nil {
+		throw("chansend: wakeup with non-nil gp.param")
+	}
+	if mysg.cancelable() && chanCancelWoken(gp, c, &c.sendq, mysg) {
+		gp.waiting = nil
+		gp.activeStackChans = false
+		mysg.c = nil
+		releaseSudog(mysg)
+		return false
+	}
+	closed := !mysg.success
+	if !mysg.woken || closed && c.closed == 0 {
+		// Woken as if c were closed, but it isn't, or not
+		// woken by anyone completing the send.
+		printchanwakeup(c, mysg)
+		throw("chansend: spurious wakeup")
+	}
+	mysg.woken = false
 	gp.waiting = nil
 	gp.activeStackChans = false
-	closed := !mysg.success
-	gp.param = nil
 	if mysg.releasetime > 0 {
-		blockevent(mysg.releasetime-t0, 2)
+		chanblockevent(mysg.releasetime-t0, 2)
 	}
 	// 取消 sudog 和 channel 绑定关系
 	mysg.c = nil
 	releaseSudog(mysg) // 去掉 mysg 上绑定的 channel
 	if closed {
-		if c.closed == 0 {
-			throw("chansend: spurious wakeup")
-		}
 		// 被唤醒后，管道关闭了，todo 向一个关闭的管道发送数据会panic
-		panic(plainError("send on closed channel"))
+		if closedp != nil {
+			*closedp = true
+			return false
+		}
+		chanMisuseSendOnClosed()
+		panic(closedChannelError(c))
 	}
+	chanprofop(chanProfSend|chanProfBlocked, 1)
 	return true
 }
 
+// closedChannelError returns the panic value of a send on c, which is
+// closed.
+func closedChannelError(c *hchan) *ClosedChannelError {
+	e := &ClosedChannelError{
+		elem:     c.elemtype,
+		closedBy: c.closedBy,
+	}
+	if s := c.side; s != nil && s.closedAt != 0 {
+		sec, nsec, mono := time_now()
+		e.closePC = s.closedPC
+		e.closeTime = sec*1e9 + int64(nsec) - (mono - s.closedAt)
+	}
+	return e
+}
+
 // send processes a send operation on an empty channel c.
 // The value ep sent by the sender is copied to the receiver sg.
 // The receiver is then woken up to go on its merry way.
@@ -370,7 +596,9 @@ func chansend(c *hchan, ep unsafe.Pointer, block bool, callerpc uintptr) bool {
 // SG 必须已从 C 中取消排队，EP 必须为非 nil 并指向堆或调用方的堆栈。
 func send(c *hchan, sg *sudog, ep unsafe.Pointer, unlockf func(), skip int) {
 	if raceenabled {
-		if c.dataqsiz == 0 {
+		if c.dataqsiz == 0 || c.borrows() != 0 {
+			// With slots borrowed, sendx and recvx differ even
+			// though the buffer is empty; see chan_borrow.go.
 			racesync(c, sg)
 		} else {
 			// Pretend we go through the buffer, even though
@@ -388,21 +616,24 @@ func send(c *hchan, sg *sudog, ep unsafe.Pointer, unlockf func(), skip int) {
 	// sg.elem 指向接收到的值存放的位置，如 val <- ch，指的就是 &val
 	if sg.elem != nil {
 		// 直接拷贝内存（从发送者到接收者）
-		sendDirect(c.elemtype, sg, ep)
+		sendDirect(c, sg, ep)
 		sg.elem = nil
 	}
 	gp := sg.g
+	global := debug.chanwakeglobal > 0 && chanWakeGlobal(c)
+	chanStatsRecordOp(c, 1, 1)
 	unlockf()
-	gp.param = unsafe.Pointer(sg)
+	chanStatsImmediate(1, 1)
+	sg.woken = true
 	sg.success = true
 	if sg.releasetime != 0 {
-		sg.releasetime = cputicks()
+		sg.releasetime = nanotime()
 	}
 	// 唤醒接收的 goroutine. skip 和打印栈相关
 	// 调用 goready 函数将接收方 goroutine 唤醒并标记为可运行状态
 	// 并把其放入发送方所在处理器 P 的 runnext 字段等待执行
 	// runnext 字段表示最高优先级的 goroutine
-	goready(gp, skip+1)
+	chanready(gp, global, traceUnblockSend, skip+1)
 }
 
 // Sends and receives on unbuffered or empty-buffered channels are the
@@ -413,12 +644,14 @@ func send(c *hchan, sg *sudog, ep unsafe.Pointer, unlockf func(), skip int) {
 // violating that assumption, but the write barrier has to work.
 // typedmemmove will call bulkBarrierPreWrite, but the target bytes
 // are not in the heap, so that will not help. We arrange to call
-// memmove and typeBitsBulkBarrier instead.
+// memmove and typeBitsBulkBarrier instead, unless the element is of
+// a kind that chanmove copies with a typed assignment, whose write
+// barrier works anywhere.
 // 向一个非缓冲型的 channel 发送数据、从一个无元素的（非缓冲型或缓冲型但空）的 channel
 // 接收数据，都会导致一个 goroutine 直接操作另一个 goroutine 的栈
 // 由于 GC 假设对栈的写操作只能发生在 goroutine 正在运行中并且由当前 goroutine 来写
 // 所以这里实际上违反了这个假设。可能会造成一些问题，所以需要用到写屏障来规避
-func sendDirect(t *_type, sg *sudog, src unsafe.Pointer) {
+func sendDirect(c *hchan, sg *sudog, src unsafe.Pointer) {
 	// src is on our stack, dst is a slot on another stack.
 
 	// Once we read sg.elem out of sg, it will no longer
@@ -431,18 +664,28 @@ func sendDirect(t *_type, sg *sudog, src unsafe.Pointer) {
 	// 如果目标地址的栈发生了栈收缩，当我们读出了 sg.elem 后
 	// 就不能修改真正的 dst 位置的值了
 	dst := sg.elem
+	if c.flags&chanCopyMask != chanCopyGeneric {
+		chanmove(c, dst, src)
+		return
+	}
 	// 因此需要在读和写之前加上一个屏障
+	t := c.elemtype
 	typeBitsBulkBarrier(t, uintptr(dst), uintptr(src), t.size)
 	// No need for cgo write barrier checks because dst is always
 	// Go memory.
 	memmove(dst, src, t.size)
 }
 
-func recvDirect(t *_type, sg *sudog, dst unsafe.Pointer) {
+func recvDirect(c *hchan, sg *sudog, dst unsafe.Pointer) {
 	// dst is on our stack or the heap, src is on another stack.
 	// The channel is locked, so src will not move during this
 	// operation.
 	src := sg.elem
+	if c.flags&chanCopyMask != chanCopyGeneric {
+		chanmove(c, dst, src)
+		return
+	}
+	t := c.elemtype
 	typeBitsBulkBarrier(t, uintptr(dst), uintptr(src), t.size)
 	memmove(dst, src, t.size)
 }
@@ -451,27 +694,57 @@ func recvDirect(t *_type, sg *sudog, dst unsafe.Pointer) {
 // 所以，在不了解 channel 还有没有接收者的情况下，不能贸然关闭 channel。
 // close 函数先上一把大锁，接着把所有挂在这个 channel 上的 sender 和 receiver 全都连成一个 sudog 链表，再解锁。最后，再将所有的 sudog 全都唤醒。
 // 唤醒之后，该干嘛干嘛。sender 会继续执行 chansend 函数里 goparkunlock 函数之后的代码，很不幸，检测到 channel 已经关闭了，panic。receiver 则比较幸运，进行一些扫尾工作后，
+//
+// closechan wakes the goroutines blocked on c in a fixed order: the
+// receivers first, in the order they blocked, then the senders, in the
+// order they blocked. They are readied in that order, so that the
+// closing goroutine's P runs them in that order (see chanreadyq), and
+// after them any goroutines waiting on channel sets that c is in.
 func closechan(c *hchan) {
+	closechanReport(c, getcallerpc())
+}
+
+// closechanReport closes c, as closechan does for a close called at
+// callerpc. It returns the number of values left in c's buffer, and
+// the numbers of blocked senders and receivers it woke.
+func closechanReport(c *hchan, callerpc uintptr) (buffered, senders, receivers int) {
+	chancheckctx("close", false)
 	if c == nil { // todo 关闭一个空的 chan 会 panic
 		panic(plainError("close of nil channel"))
 	}
 	// 加锁，这个锁的粒度比较大
 	// 会持续到释放完所有的 sudog 才解锁
-	lock(&c.lock)
+	lockchan(c)
 	if c.closed != 0 { // todo 关闭一个已经关闭的 chan 会 panic
-		unlock(&c.lock)
+		unlockchan(c)
 		panic(plainError("close of closed channel"))
 	}
 
 	if raceenabled {
-		callerpc := getcallerpc()
 		racewritepc(c.raceaddr(), callerpc, funcPC(closechan))
 		racerelease(c.raceaddr())
 	}
+	c.closedBy = getg().goid
+	if s := c.side; s != nil && debug.chanclosecheck != 0 {
+		s.closedPC = callerpc
+		s.closedAt = nanotime()
+	}
 	// 设置 channel 状态为已关闭
-	c.closed = 1
+	// The store releases the sends that completed before the close,
+	// for the unlocked closed check in chanrecv.
+	atomic.Store(&c.closed, 1)
+	if trace.enabled {
+		traceChanClose(c)
+	}
+	chanStatsClosed(c.qcount)
+	var closeWarning chanCloseWarning
+	warn := debug.chanclosecheck != 0 && chanCloseCheck(c, &closeWarning)
 	// 用于存放发送+接收队列中的所有 goroutine
-	var glist gList
+	var glist gQueue
+	var wake gList
+	if c.sets() != nil {
+		chanSetNotify(c, &wake)
+	}
 
 	// 将接收队列中所有 goroutine 加入 gList 列表
 	for {
@@ -489,21 +762,25 @@ func closechan(c *hchan) {
 			sg.elem = nil
 		}
 		if sg.releasetime != 0 {
-			sg.releasetime = cputicks()
+			sg.releasetime = nanotime()
 		}
 		// 取出 goroutine
 		gp := sg.g
-		gp.param = unsafe.Pointer(sg)
+		sg.woken = true
 		sg.success = false // todo import 因为关闭而唤醒，设为false
 		if raceenabled {
 			raceacquireg(gp, c.raceaddr())
 		}
 		// 将 sg 对应的 goroutine 添加到 glist 列表
-		glist.push(gp)
+		glist.pushBack(gp)
+		receivers++
 	}
 
 	// 将发送队列中所有 goroutine 加入 gList 列表
 	// todo 如果存在，这些 goroutine 将会 panic，在写入处引发panic
+	if c.sendq.first != nil {
+		atomic.Xadd64(&chanMisuse.closeBlockedSenders, 1)
+	}
 	for {
 		// 如果此通道的发送数据协程队列不为空，此队列中的所有协程将被依个弹出，并且每个协程中都将产生一个恐慌（因为向已关闭的通道发送数据）。
 		sg := c.sendq.dequeue()
@@ -514,32 +791,39 @@ func closechan(c *hchan) {
 		// 忽略发送协程的值
 		sg.elem = nil
 		if sg.releasetime != 0 {
-			sg.releasetime = cputicks()
+			sg.releasetime = nanotime()
 		}
 		gp := sg.g
-		gp.param = unsafe.Pointer(sg)
+		sg.woken = true
 		sg.success = false // todo import 因为关闭而唤醒，设为false
 		if raceenabled {
 			raceacquireg(gp, c.raceaddr())
 		}
 		// 将 sg 对应的 goroutine 添加到 glist 列表
-		glist.push(gp)
+		glist.pushBack(gp)
+		senders++
 	}
+	buffered = int(c.qcount)
 	// 解锁
-	unlock(&c.lock)
+	unlockchan(c)
+	if warn {
+		// Before the blocked senders are woken to panic.
+		chanCloseWarn(c, &closeWarning)
+	}
 
 	// 准备好所有 G，现在我们已经删除了通道锁。
-	for !glist.empty() {
-		gp := glist.pop()
-		gp.schedlink = 0
-		// 唤醒所有线程
-		// 接收队列里的协程获取零值，继续后续执行
-		// todo 发送队列里的协程，触发panic
-		goready(gp, 3)
-		// 	唤醒发送和接收协程，发送协程从 chansend 中的 gopark 后开始执行；接收协程从 chanrecv 中的 gopark 后开始执行
-	}
+	// 唤醒所有线程
+	// 接收队列里的协程获取零值，继续后续执行
+	// todo 发送队列里的协程，触发panic
+	// 	唤醒发送和接收协程，发送协程从 chansend 中的 gopark 后开始执行；接收协程从 chanrecv 中的 gopark 后开始执行
+	chanreadyq(&glist, traceUnblockClose, 4)
+	chanSetWakeAll(&wake)
+	return buffered, senders, receivers
 }
 
+// empty reports whether a read from c would block (that is, the channel is
+// empty). Its loads are atomic, so that they are ordered after the load of
+// c.closed that precedes the second empty check in chanrecv.
 // 无缓冲区且没有发送方
 // 有缓冲区但没有数据
 func empty(c *hchan) bool {
@@ -549,19 +833,117 @@ func empty(c *hchan) bool {
 		return atomic.Loadp(unsafe.Pointer(&c.sendq.first)) == nil
 	}
 	// 有缓冲 channel 并且缓冲区没有数据
-	return atomic.Loaduint(&c.qcount) == 0
+	// A sender may be parked on a channel whose slots are all
+	// borrowed, so a channel with side state, which may have borrowed
+	// slots, is left to the locked path.
+	return atomic.Loadp(unsafe.Pointer(&c.side)) == nil && atomic.Loaduint(&c.qcount) == 0
+}
+
+// chanprefetch prefetches buffer slot i of c, which the caller is
+// about to access while holding c.lock, so that the cache miss on the
+// slot isn't taken inside the critical section.
+// i is read without holding c.lock and may be stale by the time the
+// lock is acquired. The caller recomputes the slot under the lock, and
+// prefetching the wrong slot is harmless.
+func chanprefetch(c *hchan, i uint) {
+	if c.dataqsiz == 0 || c.elemsize == 0 {
+		return
+	}
+	p := uintptr(chanbuf(c, i))
+	sys.Prefetch(p)
+	if uintptr(c.elemsize) > cpu.CacheLineSize {
+		sys.Prefetch(p + cpu.CacheLineSize)
+	}
+}
+
+// lockchan acquires c.lock. If the mutex profile is enabled and the
+// lock is already held, the time spent waiting for it is recorded in
+// the mutex profile with the stack of lockchan's caller, the same way
+// contention on sync.Mutex is.
+//
+// Contention is detected by looking at the lock word before calling
+// lock, so an acquisition that races with another locker and loses
+// inside lock2 is not recorded. The profile is sampled anyway.
+//
+// Since the event is recorded while c.lock is held, lockRankHchan must
+// remain a predecessor of lockRankProf.
+func lockchan(c *hchan) {
+	if atomic.Load64(&mutexprofilerate) == 0 || atomic.Loaduintptr(&c.lock.key) == 0 {
+		lock(&c.lock)
+		return
+	}
+	t0 := cputicks()
+	lock(&c.lock)
+	mutexevent(cputicks()-t0, 2)
+}
+
+// unlockchan unlocks c at the end of a channel operation. With
+// GODEBUG=chaninvariants=1, it first checks c's invariants, so that an
+// operation that breaks them is caught before anyone else sees c.
+//
+//go:nosplit
+func unlockchan(c *hchan) {
+	if debug.chaninvariants != 0 {
+		chancheck(c)
+	}
+	unlock(&c.lock)
+}
+
+// chandrain discards all values buffered in c.
+// It is used when resetting a channel timer (see timerchandrain).
+func chandrain(c *hchan) {
+	lock(&c.lock)
+	n := uint64(0)
+	for c.qcount > 0 {
+		if raceenabled {
+			racenotify(c, c.recvx, nil)
+		}
+		chanRecvClear(c, chanbuf(c, c.recvx))
+		c.recvx++
+		if c.recvx == c.dataqsiz {
+			c.recvx = 0
+		}
+		c.qcount--
+		if s := c.side; s != nil && s.borrows != 0 {
+			s.borrows++
+		}
+		if raceenabled {
+			racechancount(c)
+		}
+		n++
+	}
+	chanStatsRecordOp(c, 0, n)
+	if trace.enabled && n > 0 {
+		traceChanBuf(c, uint(n))
+	}
+	unlock(&c.lock)
+	if n > 0 {
+		chanStatsOp(0, n)
+	}
 }
 
 // entry points for <- c from compiled code
 //go:nosplit
 // <- c 代码的编译入口
 func chanrecv1(c *hchan, elem unsafe.Pointer) {
-	chanrecv(c, elem, true)
+	_, received := chanrecv(c, elem, true)
+	if debug.chanblockwarn > 0 && received {
+		chanBlockWarnOp(c, false, getcallerpc())
+	}
+	if debug.closedrecvwarn > 0 && !received {
+		closedRecv(getcallerpc())
+	}
 }
 
 //go:nosplit
 func chanrecv2(c *hchan, elem unsafe.Pointer) (received bool) {
 	_, received = chanrecv(c, elem, true)
+	if debug.chanblockwarn > 0 && received {
+		chanBlockWarnOp(c, false, getcallerpc())
+	}
+	if debug.closedrecvwarn > 0 && !received {
+		closedRecv(getcallerpc())
+	}
 	return
 }
 
@@ -583,6 +965,7 @@ func chanrecv(c *hchan, ep unsafe.Pointer, block bool) (selected, received bool)
 	if debugChan {
 		print("chanrecv: chan=", c, "\n")
 	}
+	chancheckctx("receive", block)
 
 	if c == nil {
 		// 非阻塞模式下直接返回
@@ -594,38 +977,88 @@ func chanrecv(c *hchan, ep unsafe.Pointer, block bool) (selected, received bool)
 		throw("unreachable")
 	}
 
+	if c.flags&chanNUMAPending != 0 {
+		chanNUMAConsume(c)
+	}
+
+	// Some receives fail here anyway in the runtime's tests; see
+	// channbfail.go.
+	if !block && chanNBFail(c) && atomic.Load(&c.closed) == 0 {
+		return
+	}
+
 	// 非阻塞模式并且接收数据操作会阻塞
 	// empty 函数返回 true 的情况:
 	//    1. 无缓冲 channel 并且没有发送方正在阻塞
 	//    2. 有缓冲 channel 并且缓冲区没有数据
-	if !block && empty(c) {
+	//
+	// The closed-and-drained check below is also done for blocking
+	// receives, so that receiving from a closed done-channel never
+	// takes c.lock. closechan sets c.closed while holding c.lock, after
+	// every send that filled the buffer has released it, and no send
+	// can complete after that. So once closed is observed, a second
+	// empty check observing no buffered data (and no parked sender)
+	// is final.
+	//
+	// This is what guarantees that a receive, including a select with
+	// a default case, never reports a channel closed while a value
+	// sent before the close is still buffered: the atomic load of
+	// c.closed that observes closechan's atomic store also observes
+	// every send that preceded the close, so the second empty check
+	// sees their values. That takes the loads of c.closed and of the
+	// state empty reads to be atomic, and in that order: a plain load
+	// could be satisfied early, from before the close, and see a value
+	// still buffered as received. Sends update that state with c.lock
+	// held, before closechan takes c.lock, so the store of c.closed
+	// comes after them. The first empty check may be stale; it only
+	// decides whether to look at c.closed. A select with several cases
+	// takes the channel locks before looking at any channel (see
+	// selectgo), so it needs no such argument.
+	if empty(c) {
 		// 判断是否关闭
 		if atomic.Load(&c.closed) == 0 {
 			// 非阻塞、无数据、且未关闭，直接返回
 			// 因为 channel 关闭后就无法再打开，所以只要 channel 未关闭，上述方法都是原子操作 (看到的结果都是一样的)
-			return
-		}
-
-		// channel 已经关闭，重新检查 channel 是否存在等待接收的数据
-		if empty(c) {
+			//
+			// There is no race annotation here, on purpose. The
+			// memory model orders a send before the receive that
+			// completes it, and a close before a receive that
+			// returns because the channel is closed. A receive
+			// that returns nothing completed no communication and
+			// observed no close, so nothing happens before it, and
+			// an acquire here would hide races in code that takes
+			// "nothing to receive yet" as a signal. The same goes
+			// for the !block return below and for a select that
+			// takes its default case.
+			if !block {
+				return
+			}
+		} else if empty(c) {
+			// channel 已经关闭，重新检查 channel 是否存在等待接收的数据
 			// 通道不可逆地关闭和为空
+			if debug.chaninvariants != 0 {
+				chancheckdrained(c)
+			}
 			if raceenabled {
 				raceacquire(c.raceaddr())
 			}
 			// 没有任何等待接收的数据，清理 ep 指针中的数据
 			if ep != nil {
-				typedmemclr(c.elemtype, ep)
+				chanclr(c, ep)
 			}
+			chanStatsFast()
+			chanprofop(chanProfRecv, 1)
 			return true, false
 		}
 	}
 
 	var t0 int64
-	if blockprofilerate > 0 {
-		t0 = cputicks()
+	if chanBlockProfileRate() > 0 {
+		t0 = nanotime()
 	}
 
-	lock(&c.lock)
+	chanprefetch(c, c.recvx)
+	lockchan(c)
 
 	// channel 已经关闭，且没有数据
 	if c.closed != 0 && c.qcount == 0 {
@@ -633,23 +1066,30 @@ func chanrecv(c *hchan, ep unsafe.Pointer, block bool) (selected, received bool)
 			raceacquire(c.raceaddr())
 		}
 		// 解锁
-		unlock(&c.lock)
+		unlockchan(c)
 		if ep != nil {
 			// 清理 ep 指针中的数据
-			typedmemclr(c.elemtype, ep)
+			chanclr(c, ep)
 		}
+		chanStatsImmediate(0, 0)
+		chanprofop(chanProfRecv, 1)
 		return true, false
 	}
 
 	// channel 未关闭，或关闭了但是还有数据
 
 	// 还有阻塞的发送者协程，说明没有缓冲区或是缓冲区已满
-	if sg := c.sendq.dequeue(); sg != nil {
-		// 从发送队列获取第一个发送者协程
-		// 如果是无缓冲区，直接从发送 goroutine 拷贝数据到接收数据的地址
-		// 否则，缓冲区已满，从接收队列头部的 goroutine 开始接收数据，并将数据添加到发送队列尾部的 goroutine
-		recv(c, sg, ep, func() { unlock(&c.lock) }, 3)
-		return true, true
+	// While slots are borrowed, a blocked sender must wait for a
+	// commit to free one, unless the buffer holds nothing to receive.
+	if c.borrows() == 0 || c.qcount == 0 {
+		if sg := c.sendq.dequeue(); sg != nil {
+			// 从发送队列获取第一个发送者协程
+			// 如果是无缓冲区，直接从发送 goroutine 拷贝数据到接收数据的地址
+			// 否则，缓冲区已满，从接收队列头部的 goroutine 开始接收数据，并将数据添加到发送队列尾部的 goroutine
+			recv(c, sg, ep, func() { unlockchan(c) }, 3)
+			chanprofop(chanProfRecv, 1)
+			return true, true
+		}
 	}
 
 	// 没有阻塞的发送者协程，但是channel里面还有数据
@@ -661,10 +1101,10 @@ func chanrecv(c *hchan, ep unsafe.Pointer, block bool) (selected, received bool)
 		}
 		if ep != nil {
 			// 直接从缓冲区的地址上拷贝数据到接收数据的地址
-			typedmemmove(c.elemtype, ep, qp)
+			chanmove(c, ep, qp)
 		}
 		// 清除已经消费的数据
-		typedmemclr(c.elemtype, qp)
+		chanRecvClear(c, qp)
 		// 消费索引往后移
 		c.recvx++
 		if c.recvx == c.dataqsiz {
@@ -672,20 +1112,36 @@ func chanrecv(c *hchan, ep unsafe.Pointer, block bool) (selected, received bool)
 		}
 		// 元素数量减一
 		c.qcount--
-		unlock(&c.lock)
+		if s := c.side; s != nil && s.borrows != 0 {
+			// The slot is freed along with the borrowed one before it.
+			s.borrows++
+		}
+		if trace.enabled {
+			traceChanBuf(c, c.qcount+1)
+		}
+		if raceenabled {
+			racechancount(c)
+		}
+		chanStatsRecordOp(c, 0, 1)
+		unlockchan(c)
+		chanStatsImmediate(0, 1)
+		chanprofop(chanProfRecv, 1)
 		return true, true
 	}
 
 	// 没有等待的发送者协程，缓冲区没有数据，且非阻塞的，直接返回
-	if !block {
-		unlock(&c.lock)
+	if !block || chanCancelled(getg()) {
+		unlockchan(c)
 		return false, false
 	}
 
 	// 没有等待的发送者协程，缓冲区没有数据，且阻塞的
 	// 获取当前接收的协程 goroutine
 	// 然后绑定到一个 sudog 结构体 (包装为运行时表示)
+	reason := chanRecvWaitReason(c)
+	parkTime := chanStatsPark(reason, t0)
 	gp := getg()
+	chanWaitStart(gp, parkTime)
 	// 获取 sudog 结构体，并设置相关参数
 	mysg := acquireSudog()
 	mysg.releasetime = 0
@@ -696,35 +1152,70 @@ func chanrecv(c *hchan, ep unsafe.Pointer, block bool) (selected, received bool)
 	mysg.waitlink = nil
 	gp.waiting = mysg
 	mysg.g = gp // 设置 goroutine
-	mysg.isSelect = false // 设置是否 select
+	mysg.flags = 0 // 设置是否 select
+	if gp.chanCancel != nil {
+		mysg.flags |= sudogCancelable
+	}
 	mysg.c = c // 设置当前的 channel
-	gp.param = nil
+	if debug.chaninvariants != 0 {
+		chancheckenqueue(c, &c.recvq, mysg)
+	}
 	c.recvq.enqueue(mysg) // 进入接收队列等待
+	if debug.chaninvariants != 0 {
+		chancheck(c)
+	}
+	if trace.enabled {
+		traceChanDescribe(c)
+	}
 	// Signal to anyone trying to shrink our stack that we're about
 	// to park on a channel. The window between when this G's status
 	// changes and when we set gp.activeStackChans is not safe for
 	// stack shrinking.
 	atomic.Store8(&gp.parkingOnChan, 1)
+	commit := chanparkcommit
+	if mysg.cancelable() {
+		commit = chancancelparkcommit
+	}
 	// 挂起当前 goroutine, 进入休眠 (等待发送方发送数据)，阻塞中
-	gopark(chanparkcommit, unsafe.Pointer(&c.lock), waitReasonChanReceive, traceEvGoBlockRecv, 2)
+	gopark(commit, unsafe.Pointer(&c.lock), reason, traceEvGoBlockRecv, 2)
 
 	// someone woke us up
+	chanStatsUnpark(reason, parkTime)
+	chanStatsRecordWait(c, parkTime)
 	// 因为某种原因而被唤醒，重新获取gp
 	if mysg != gp.waiting {
+		printwaitingcorrupt(c, mysg, gp)
 		throw("G waiting list is corrupted")
 	}
+	if gp.param != nil {
+		throw("chanrecv: wakeup with non-nil gp.param")
+	}
+	if mysg.cancelable() && chanCancelWoken(gp, c, &c.recvq, mysg) {
+		gp.waiting = nil
+		gp.activeStackChans = false
+		mysg.c = nil
+		releaseSudog(mysg)
+		return false, false
+	}
+	if !mysg.woken || !mysg.success && c.closed == 0 {
+		// Woken as if c were closed, but it isn't, or not
+		// woken by anyone completing the receive.
+		printchanwakeup(c, mysg)
+		throw("chanrecv: spurious wakeup")
+	}
+	mysg.woken = false
 	gp.waiting = nil
 	gp.activeStackChans = false
 	if mysg.releasetime > 0 {
-		blockevent(mysg.releasetime-t0, 2)
+		chanblockevent(mysg.releasetime-t0, 2)
 	}
 	// todo 被唤醒的原因，true，因为写入了数据，false，因为关闭了管道
 	success := mysg.success
-	gp.param = nil
 	// 取消 sudog 和 channel 绑定关系
 	mysg.c = nil
 	// 释放 sudog
 	releaseSudog(mysg)
+	chanprofop(chanProfRecv|chanProfBlocked, 1)
 	return true, success
 }
 
@@ -738,19 +1229,21 @@ func chanrecv(c *hchan, ep unsafe.Pointer, block bool) (selected, received bool)
 // For asynchronous channels, the receiver gets its data from
 // the channel buffer and the sender's data is put in the
 // channel buffer.
+// A buffered channel whose slots are all borrowed (see chan_borrow.go)
+// is handled like a synchronous one.
 // Channel c must be full and locked. recv unlocks c with unlockf.
 // sg must already be dequeued from c.
 // A non-nil ep must point to the heap or the caller's stack.
 func recv(c *hchan, sg *sudog, ep unsafe.Pointer, unlockf func(), skip int) {
 	// 还有阻塞的发送者协程，说明没有缓冲区或是缓冲区已满
-	if c.dataqsiz == 0 {
+	if c.dataqsiz == 0 || c.qcount == 0 {
 		// 无缓冲区
 		if raceenabled {
 			racesync(c, sg)
 		}
 		if ep != nil {
 			// 直接从发送者接收数据
-			recvDirect(c.elemtype, sg, ep)
+			recvDirect(c, sg, ep)
 		}
 	} else {
 		// 缓冲区已满
@@ -763,12 +1256,12 @@ func recv(c *hchan, sg *sudog, ep unsafe.Pointer, unlockf func(), skip int) {
 		// copy data from queue to receiver
 		if ep != nil {
 			// 将消费索引处的数据拷贝到接收数据的指针
-			typedmemmove(c.elemtype, ep, qp)
+			chanmove(c, ep, qp)
 		}
 
 		// 因为缓冲区已经满了，所以生产索引和消费索引是同一个位置
 		// 直接将发送者协程的数据拷贝到消费索引处
-		typedmemmove(c.elemtype, qp, sg.elem)
+		chanmove(c, qp, sg.elem)
 		// 消费索引加一
 		c.recvx++
 		if c.recvx == c.dataqsiz {
@@ -780,20 +1273,34 @@ func recv(c *hchan, sg *sudog, ep unsafe.Pointer, unlockf func(), skip int) {
 	// 发送者协程的数据指针置空
 	sg.elem = nil
 	gp := sg.g
+	global := debug.chanwakeglobal > 0 && chanWakeGlobal(c)
+	chanStatsRecordOp(c, 1, 1)
 	// 解锁
 	unlockf()
-	gp.param = unsafe.Pointer(sg)
+	chanStatsImmediate(1, 1)
+	sg.woken = true
 	// 因为写入值成功而被唤醒
 	sg.success = true
 	if sg.releasetime != 0 {
-		sg.releasetime = cputicks()
+		sg.releasetime = nanotime()
 	}
 	// 调用 goready 函数将接收方 goroutine 唤醒并标记为可运行状态
 	// 并把其放入发送方所在处理器 P 的 runnext 字段等待执行
-	goready(gp, skip+1)
+	chanready(gp, global, traceUnblockRecv, skip+1)
 }
 
 func chanparkcommit(gp *g, chanLock unsafe.Pointer) bool {
+	chanparkunlock(gp, chanLock)
+	chanSetWakeParked()
+	return true
+}
+
+// chanparkunlock is chanparkcommit without readying the channel set
+// waiters woken by gp's send.
+func chanparkunlock(gp *g, chanLock unsafe.Pointer) {
+	if chanParkStress {
+		chanparkstress(gp)
+	}
 	// There are unlocked sudogs that point into gp's stack. Stack
 	// copying must lock the channels of those sudogs.
 	// Set activeStackChans here instead of before we try parking
@@ -810,7 +1317,36 @@ func chanparkcommit(gp *g, chanLock unsafe.Pointer) bool {
 	// so gp could continue running before everything before
 	// the unlock is visible (even to gp itself).
 	unlock((*mutex)(chanLock))
-	return true
+}
+
+// chanParkStress is set by tests to widen the window between a
+// goroutine parking on a channel and chanparkcommit or selparkcommit
+// telling stack copying that the goroutine's stack is referenced by
+// sudogs. See chanparkstress.
+var chanParkStress bool
+
+// chanParkStressBlocked counts the stack shrinks that chanparkstress
+// found parkingOnChan to prevent.
+var chanParkStressBlocked uint64
+
+// chanparkstress is called by chanparkcommit and selparkcommit when
+// chanParkStress is set, while gp is _Gwaiting but activeStackChans is
+// not set yet. It yields the thread, so that a GC worker scanning
+// stacks on another thread may get to gp, and then makes the check
+// the GC makes before shrinking gp's stack. parkingOnChan must make
+// it fail: a shrink now would not lock the channels of gp's sudogs
+// and could race with channel operations on them.
+func chanparkstress(gp *g) {
+	osyield()
+	if !castogscanstatus(gp, _Gwaiting, _Gscanwaiting) {
+		// Someone else is scanning gp.
+		return
+	}
+	if isShrinkStackSafe(gp) {
+		throw("stack shrink allowed while parking on a channel")
+	}
+	atomic.Xadd64(&chanParkStressBlocked, 1)
+	casfrom_Gscanstatus(gp, _Gscanwaiting, _Gwaiting)
 }
 
 // compiler implements
@@ -833,7 +1369,21 @@ func chanparkcommit(gp *g, chanLock unsafe.Pointer) bool {
 // select case 编译时，发送数据为非阻塞，即非阻塞型
 // todo must import, 没有default时，select case 编译成 chansend1(c *hchan, elem unsafe.Pointer)，即阻塞型
 func selectnbsend(c *hchan, elem unsafe.Pointer) (selected bool) {
-	return chansend(c, elem, false, getcallerpc())
+	selected = chansend(c, elem, false, getcallerpc(), nil)
+	if debug.chanblockwarn > 0 && selected {
+		chanBlockWarnOp(c, true, getcallerpc())
+	}
+	if debug.selectspindetect != 0 {
+		selectSpin(getcallerpc(), !selected)
+	}
+	if trace.enabled {
+		if selected {
+			traceSelect(0, 1, 0)
+		} else {
+			traceSelect(-1, 1, 0)
+		}
+	}
+	return selected
 }
 
 // compiler implements
@@ -858,17 +1408,57 @@ func selectnbsend(c *hchan, elem unsafe.Pointer) (selected bool) {
 //   3.1 将当前协程加入到所有channel的等待队列
 //   3.2 当将协程转入阻塞，等待被唤醒
 func selectnbrecv(elem unsafe.Pointer, c *hchan) (selected, received bool) {
-	return chanrecv(c, elem, false)
+	selected, received = chanrecv(c, elem, false)
+	if debug.chanblockwarn > 0 && received {
+		chanBlockWarnOp(c, false, getcallerpc())
+	}
+	if debug.closedrecvwarn > 0 && selected && !received {
+		closedRecv(getcallerpc())
+	}
+	if debug.selectspindetect != 0 {
+		selectSpin(getcallerpc(), !selected)
+	}
+	if trace.enabled {
+		if selected {
+			traceSelect(0, 0, 1)
+		} else {
+			traceSelect(-1, 0, 1)
+		}
+	}
+	return selected, received
 }
 
 //go:linkname reflect_chansend reflect.chansend
 func reflect_chansend(c *hchan, elem unsafe.Pointer, nb bool) (selected bool) {
-	return chansend(c, elem, !nb, getcallerpc())
+	selected = chansend(c, elem, !nb, getcallerpc(), nil)
+	if debug.chanblockwarn > 0 && selected {
+		chanBlockWarnOp(c, true, getcallerpc())
+	}
+	return selected
 }
 
 //go:linkname reflect_chanrecv reflect.chanrecv
 func reflect_chanrecv(c *hchan, nb bool, elem unsafe.Pointer) (selected bool, received bool) {
-	return chanrecv(c, elem, !nb)
+	selected, received = chanrecv(c, elem, !nb)
+	if debug.chanblockwarn > 0 && received {
+		chanBlockWarnOp(c, false, getcallerpc())
+	}
+	if debug.closedrecvwarn > 0 && selected && !received {
+		closedRecv(getcallerpc())
+	}
+	return selected, received
+}
+
+// entry point for len(c) from compiled code built with -race.
+// Without -race, the compiler loads c.qcount directly.
+func chanlen(c *hchan) int {
+	if c == nil {
+		return 0
+	}
+	if raceenabled && debug.racechanlen != 0 {
+		racechanlen(c, getcallerpc())
+	}
+	return int(c.qcount)
 }
 
 //go:linkname reflect_chanlen reflect.chanlen
@@ -876,6 +1466,9 @@ func reflect_chanlen(c *hchan) int {
 	if c == nil {
 		return 0
 	}
+	if raceenabled && debug.racechanlen != 0 {
+		racechanlen(c, getcallerpc())
+	}
 	return int(c.qcount)
 }
 
@@ -884,6 +1477,9 @@ func reflectlite_chanlen(c *hchan) int {
 	if c == nil {
 		return 0
 	}
+	if raceenabled && debug.racechanlen != 0 {
+		racechanlen(c, getcallerpc())
+	}
 	return int(c.qcount)
 }
 
@@ -900,7 +1496,16 @@ func reflect_chanclose(c *hchan) {
 	closechan(c)
 }
 
+//go:linkname reflect_chanclosereport reflect.chanclosereport
+func reflect_chanclosereport(c *hchan) (buffered, senders, receivers int) {
+	return closechanReport(c, getcallerpc())
+}
+
 func (q *waitq) enqueue(sgp *sudog) {
+	if sgp.queued {
+		badenqueue(q, sgp)
+	}
+	sgp.queued = true
 	sgp.next = nil
 	x := q.last
 	if x == nil {
@@ -914,8 +1519,39 @@ func (q *waitq) enqueue(sgp *sudog) {
 	q.last = sgp
 }
 
+// badenqueue reports that sgp, which is being added to q, is already
+// on a wait queue, and throws.
+//
+//go:noinline
+func badenqueue(q *waitq, sgp *sudog) {
+	c := sgp.c
+	qname := "wait queue"
+	if c != nil {
+		if q == &c.sendq {
+			qname = "sendq"
+		} else if q == &c.recvq {
+			qname = "recvq"
+		}
+	}
+	var goid int64
+	if sgp.g != nil {
+		goid = sgp.g.goid
+	}
+	print("runtime: sudog ", sgp, " of goroutine ", goid, " added to ", qname, " of chan ", c, " while already queued\n")
+	printsudog(sgp)
+	if c != nil {
+		printhchan(c)
+	}
+	throw("sudog enqueued twice")
+}
+
 // 从协程的等待队列中出列
 func (q *waitq) dequeue() *sudog {
+	if atomic.Load(&chanDecisions.enabled) != 0 {
+		if sgp := q.dequeueReplayed(); sgp != nil {
+			return sgp
+		}
+	}
 	for {
 		// 获取队列中的首个协程
 		sgp := q.first
@@ -936,6 +1572,7 @@ func (q *waitq) dequeue() *sudog {
 			// 将要出队的协程的后置指针置空，切断与其他协程的联系
 			sgp.next = nil // mark as removed (see dequeueSudog)
 		}
+		sgp.queued = false
 
 		// if a goroutine was put on this queue because of a
 		// select, there is a small window between the goroutine
@@ -948,14 +1585,106 @@ func (q *waitq) dequeue() *sudog {
 		// 如果一个 goroutine 因为选择而被放到这个队列上，那么在被不同情况唤醒的 goroutine 和它抓取通道锁之间有一个小窗口。
 		// 一旦它有了锁，它就会将自己从队列中删除，所以之后我们不会看到它。
 		// 我们在 G 结构中使用一个标志来告诉我们其他人何时赢得了比赛来发出这个 goroutine 的信号，但 goroutine 还没有将自己从队列中删除。
-		if sgp.isSelect && !atomic.Cas(&sgp.g.selectDone, 0, 1) {
+		// The same goes for a goroutine in a cancelable send or
+		// receive, which races with its canceller (see chancancel.go).
+		if (sgp.isSelect() || sgp.cancelable()) && !atomic.Cas(&sgp.g.selectDone, 0, 1) {
 			continue
 		}
 
+		if atomic.Load(&chanDecisions.enabled) != 0 {
+			chanWakeDecided(sgp)
+		}
 		return sgp
 	}
 }
 
+// setSudogNoWB performs *p = new without a write barrier.
+//
+// This is only safe for the links of wait queues: every sudog on a
+// wait queue is also reachable from the g that owns it (see
+// gp.waiting), so the garbage collector can't miss it.
+//go:nosplit
+//go:nowritebarrier
+func setSudogNoWB(p **sudog, new *sudog) {
+	*(*uintptr)(unsafe.Pointer(p)) = uintptr(unsafe.Pointer(new))
+}
+
+// dequeueNoWB is like dequeue, but doesn't use write barriers.
+//go:nowritebarrierrec
+func (q *waitq) dequeueNoWB() *sudog {
+	for {
+		sgp := q.first
+		if sgp == nil {
+			return nil
+		}
+		y := sgp.next
+		if y == nil {
+			setSudogNoWB(&q.first, nil)
+			setSudogNoWB(&q.last, nil)
+		} else {
+			setSudogNoWB(&y.prev, nil)
+			setSudogNoWB(&q.first, y)
+			setSudogNoWB(&sgp.next, nil) // mark as removed (see dequeueSudog)
+		}
+		sgp.queued = false
+
+		// See dequeue.
+		if (sgp.isSelect() || sgp.cancelable()) && !atomic.Cas(&sgp.g.selectDone, 0, 1) {
+			continue
+		}
+
+		return sgp
+	}
+}
+
+// chansendready performs a non-blocking send on c, which must have a
+// zero-sized element type, on behalf of the network poller.
+// A receiver woken by the send is added to toRun instead of being
+// readied, as netpollready does with goroutines blocked on I/O.
+// The send is dropped if c is full or closed.
+//
+// This may run without a P, so it must not allocate or use write
+// barriers. For the same reason it does not record race annotations
+// or trace events, and it records chanStats with chanStatsReady.
+//go:nowritebarrierrec
+func chansendready(c *hchan, toRun *gList) {
+	lock(&c.lock)
+	if c.closed != 0 {
+		unlock(&c.lock)
+		return
+	}
+	if sg := c.recvq.dequeueNoWB(); sg != nil {
+		// There's nothing to copy: the element is zero-sized.
+		*(*uintptr)(unsafe.Pointer(&sg.elem)) = 0
+		if sg.releasetime != 0 {
+			sg.releasetime = nanotime()
+		}
+		gp := sg.g
+		sg.woken = true
+		sg.success = true
+		chanStatsRecordOp(c, 1, 1)
+		unlock(&c.lock)
+		chanStatsReady(1, 1)
+		toRun.push(gp)
+		return
+	}
+	if c.qcount+c.borrows() < c.dataqsiz {
+		c.sendx++
+		if c.sendx == c.dataqsiz {
+			c.sendx = 0
+		}
+		c.qcount++
+		chanStatsRecordOp(c, 1, 0)
+		if c.sets() != nil {
+			chanSetNotify(c, toRun)
+		}
+		unlock(&c.lock)
+		chanStatsReady(1, 0)
+		return
+	}
+	unlock(&c.lock)
+}
+
 func (c *hchan) raceaddr() unsafe.Pointer {
 	// Treat read-like and write-like operations on the channel to
 	// happen at this address. Avoid using the address of qcount
@@ -974,6 +1703,31 @@ func racesync(c *hchan, sg *sudog) {
 	raceacquire(chanbuf(c, 0))
 }
 
+// racechanlen records len(c) as a read of c for the race detector.
+// It is reported as a race with a close of c, or with a send or receive
+// that changed the number of buffered elements (see racechancount),
+// that it is not synchronized with.
+// It is only used with GODEBUG=racechanlen=1, since unsynchronized
+// uses of len(c), such as for monitoring, are common and harmless.
+func racechanlen(c *hchan, callerpc uintptr) {
+	pc := funcPC(chanlen)
+	racereadpc(c.raceaddr(), callerpc, pc)
+	racereadpc(unsafe.Pointer(&c.qcount), callerpc, pc)
+}
+
+// racechancount records a change of c.qcount for the race detector.
+// c.lock must be held.
+// The change is recorded as an atomic store, so that it is reported
+// as a race with an unsynchronized len(c) but not with the changes
+// made by other operations on c, which the race detector doesn't know
+// are ordered by c.lock. Unlike racereleaseacquire, it does not
+// synchronize those operations with each other either.
+func racechancount(c *hchan) {
+	if debug.racechanlen != 0 {
+		raceatomicstore64((*uint64)(unsafe.Pointer(&c.qcount)), uint64(c.qcount))
+	}
+}
+
 // Notify the race detector of a send or receive involving buffer entry idx
 // and a channel c or its communicating partner sg.
 // This function handles the special case of c.elemsize==0.
@@ -987,12 +1741,17 @@ func racenotify(c *hchan, idx uint, sg *sudog) {
 	// sync objects underneath the hood (one sync object per idx)
 	qp := chanbuf(c, idx)
 	// When elemsize==0, we don't allocate a full buffer for the channel.
-	// Instead of individual buffer entries, the race detector uses the
-	// c.buf as the only buffer entry.  This simplification prevents us from
+	// Instead of individual buffer entries, the race detector uses
+	// c.sendx as the only buffer entry.  This simplification prevents us from
 	// following the memory model's happens-before rules (rules that are
 	// implemented in racereleaseacquire).  Instead, we accumulate happens-before
-	// information in the synchronization object associated with c.buf.
+	// information in the synchronization object associated with c.sendx.
+	// c.buf can't be used here even though it is what chanbuf returns:
+	// it is c.raceaddr(), where closechan publishes its happens-before
+	// edge, and receiving a buffered value must not synchronize with a
+	// later close.
 	if c.elemsize == 0 {
+		qp = unsafe.Pointer(&c.sendx)
 		if sg == nil {
 			raceacquire(qp)
 			racerelease(qp)
@@ -0,0 +1,166 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Zero-copy receives for reflect.Value.RecvZeroCopy.
+//
+// A receive normally copies the value out of the channel buffer and
+// frees its slot at once. A zero-copy receive instead borrows the
+// slot: it advances c.recvx and decrements c.qcount as a receive
+// does, so the value counts as received, but the slot is not reused
+// until the receiver commits it. Until then senders treat it as
+// occupied.
+//
+// Borrowed slots may be committed in any order, so the slots that
+// cannot be reused yet are tracked as a run of borrows slots starting
+// at borrowx, which ends at c.recvx. Slots received from normally
+// while the run is not empty join it, already committed. Bit i of
+// borrowMask is set if slot borrowx+i is borrowed and not yet
+// committed; bit 0 is always set while the run is not empty. A commit
+// clears its bit and frees the committed slots at the start of the
+// run. The free slots thus always run from c.sendx to borrowx, and a
+// send has room if c.qcount+c.borrows() < c.dataqsiz.
+//
+// Few channels are ever borrowed from, so borrows, borrowx and
+// borrowMask are kept in the channel's side state (see chanside.go),
+// which the first zero-copy receive from a channel attaches to it.
+//
+// A slot can only be borrowed if it is among the first 64 slots of
+// the run. Otherwise, and whenever the value is not in the buffer,
+// the receive copies the value as usual.
+//
+// If the buffer is full and its first slot is borrowed, a blocked
+// sender cannot make room by handing its value to a receiver, as recv
+// does. It waits instead for a commit, which moves the values of
+// blocked senders into the freed slots. If all slots are borrowed or
+// waiting for a borrowed slot, a receiver takes its value directly
+// from a blocked sender, as on an unbuffered channel.
+
+import (
+	"runtime/internal/sys"
+	"unsafe"
+)
+
+// reflect_chanborrow receives a value from c, blocking if necessary.
+// If the value was in c's buffer, p points to its slot, which stays
+// borrowed until reflect_chancommit is called with i. Otherwise the
+// value is copied to elem, p is elem, and i is -1. received is false
+// if c is closed and drained.
+//
+//go:linkname reflect_chanborrow reflect.chanborrow
+func reflect_chanborrow(c *hchan, elem unsafe.Pointer) (p unsafe.Pointer, i int, received bool) {
+	if c != nil && c.dataqsiz != 0 {
+		if c.flags&chanNUMAPending != 0 {
+			chanNUMAConsume(c)
+		}
+		lockchan(c)
+		if c.qcount > 0 && c.borrows() < 64 {
+			s := chanSide(c)
+			slot := c.recvx
+			if raceenabled {
+				racenotify(c, slot, nil)
+			}
+			if s.borrows == 0 {
+				chanClearDirty(c)
+				s.borrowx = slot
+			}
+			s.borrowMask |= 1 << s.borrows
+			s.borrows++
+			c.recvx++
+			if c.recvx == c.dataqsiz {
+				c.recvx = 0
+			}
+			c.qcount--
+			if trace.enabled {
+				traceChanBuf(c, c.qcount+1)
+			}
+			if raceenabled {
+				racechancount(c)
+			}
+			chanStatsRecordOp(c, 0, 1)
+			unlock(&c.lock)
+			chanStatsImmediate(0, 1)
+			return chanbuf(c, slot), int(slot), true
+		}
+		unlock(&c.lock)
+	}
+	_, received = chanrecv(c, elem, true)
+	return elem, -1, received
+}
+
+// reflect_chancommit ends the borrow of slot i of c's buffer. If that
+// frees slots, it moves the values of blocked senders into them.
+//
+//go:linkname reflect_chancommit reflect.chancommit
+func reflect_chancommit(c *hchan, i int) {
+	lock(&c.lock)
+	s := c.side
+	if s == nil {
+		unlock(&c.lock)
+		throw("chancommit: slot not borrowed")
+	}
+	k := (uint(i) + c.dataqsiz - s.borrowx) % c.dataqsiz
+	if k >= s.borrows || k >= 64 || s.borrowMask&(1<<k) == 0 {
+		unlock(&c.lock)
+		throw("chancommit: slot not borrowed")
+	}
+	if raceenabled {
+		racenotify(c, uint(i), nil)
+	}
+	typedmemclr(c.elemtype, chanbuf(c, uint(i)))
+	s.borrowMask &^= 1 << k
+
+	n := s.borrows
+	if s.borrowMask != 0 {
+		n = uint(sys.TrailingZeros64(s.borrowMask))
+		s.borrowMask >>= n
+	}
+	s.borrows -= n
+	s.borrowx = (s.borrowx + n) % c.dataqsiz
+
+	var glist gList
+	sends := uint64(0)
+	for c.qcount+s.borrows < c.dataqsiz {
+		sg := c.sendq.dequeue()
+		if sg == nil {
+			break
+		}
+		if raceenabled {
+			racenotify(c, c.sendx, sg)
+		}
+		typedmemmove(c.elemtype, chanbuf(c, c.sendx), sg.elem)
+		c.sendx++
+		if c.sendx == c.dataqsiz {
+			c.sendx = 0
+		}
+		c.qcount++
+		sg.elem = nil
+		if sg.releasetime != 0 {
+			sg.releasetime = nanotime()
+		}
+		gp := sg.g
+		sg.woken = true
+		sg.success = true
+		glist.push(gp)
+		sends++
+	}
+	if raceenabled {
+		racechancount(c)
+	}
+	chanStatsRecordOp(c, sends, 0)
+	if trace.enabled && sends > 0 {
+		traceChanBuf(c, c.qcount-uint(sends))
+	}
+	unlock(&c.lock)
+	if sends > 0 {
+		chanStatsOp(sends, 0)
+	}
+
+	for !glist.empty() {
+		gp := glist.pop()
+		gp.schedlink = 0
+		chanready(gp, false, traceUnblockRecv, 3)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime_test
+
+import (
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// TestChanBorrowStress mixes zero-copy receives, which commit their
+// slots in varying order, with plain receives and selects, and checks
+// that every value sent is received exactly once and is not
+// overwritten while borrowed.
+func TestChanBorrowStress(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	const (
+		senders = 4
+		perSend = 2000
+	)
+	type elem [16]int
+	for _, capacity := range []int{1, 2, 7, 100} {
+		c := make(chan elem, capacity)
+		var wg sync.WaitGroup
+		for s := 0; s < senders; s++ {
+			s := s
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < perSend; i++ {
+					var v elem
+					for j := range v {
+						v[j] = s*perSend + i
+					}
+					c <- v
+				}
+			}()
+		}
+		go func() {
+			wg.Wait()
+			close(c)
+		}()
+
+		seen := make([]int32, senders*perSend)
+		var mu sync.Mutex
+		got := func(v *elem) {
+			for j := range v {
+				if v[j] != v[0] {
+					t.Errorf("cap %d: value %v changed while received", capacity, *v)
+					return
+				}
+			}
+			mu.Lock()
+			seen[v[0]]++
+			mu.Unlock()
+		}
+		var rwg sync.WaitGroup
+		for r := 0; r < 4; r++ {
+			r := r
+			rwg.Add(1)
+			go func() {
+				defer rwg.Done()
+				cv := reflect.ValueOf(c)
+				var never chan elem
+				var pending []func()
+				for n := 0; ; n++ {
+					switch {
+					case r == 0:
+						v, ok := <-c
+						if !ok {
+							return
+						}
+						got(&v)
+					case r == 1:
+						select {
+						case v, ok := <-c:
+							if !ok {
+								return
+							}
+							got(&v)
+						case <-never:
+						}
+					default:
+						p, commit, ok := cv.RecvZeroCopy()
+						if !ok {
+							commit()
+							for _, f := range pending {
+								f()
+							}
+							return
+						}
+						got(p.Interface().(*elem))
+						// Hold up to r borrows, committing
+						// the newest first.
+						pending = append(pending, commit)
+						if len(pending) >= r || n%3 == 0 {
+							for i := len(pending) - 1; i >= 0; i-- {
+								pending[i]()
+							}
+							pending = pending[:0]
+						}
+					}
+				}
+			}()
+		}
+		rwg.Wait()
+		for v, n := range seen {
+			if n != 1 {
+				t.Fatalf("cap %d: value %d received %d times", capacity, v, n)
+			}
+		}
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Caller-provided channel buffers, for reflect.MakeChanWithBuffer.
+//
+// A program that makes and discards many channels with large buffers
+// can keep the buffers and give them to the channels of the next
+// generation, instead of having makechan allocate, and the garbage
+// collector free, one per channel. The buffer is the backing array of
+// a slice of the element type, so it is already typed for the garbage
+// collector, which keeps it alive while the channel, through c.buf,
+// or the caller, through the slice, can reach it.
+//
+// makechanbuf clears a buffer whose elements contain pointers, so
+// that the values of the channel that last used it are not kept alive.
+// Otherwise the buffer is used as is: its contents are not received,
+// and are overwritten by sends.
+//
+// The memory belongs to the caller, not to the channel, so makechan
+// marks the channel with chanExtBuf and does not apply the NUMA
+// placement (see chan_numa.go) or huge page advice (see
+// chan_hugepage.go) that it gives buffers of its own. Neither must
+// be applied to memory that may share pages with other objects and
+// outlive the channel.
+
+import "unsafe"
+
+//go:linkname reflect_makechanbuf reflect.makechanbuf
+func reflect_makechanbuf(t *chantype, size int, buf unsafe.Pointer) *hchan {
+	return makechanbuf(t, size, buf)
+}
@@ -0,0 +1,113 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime_test
+
+import (
+	"bufio"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestChanHugePage(t *testing.T) {
+	hugePage := runtime.PhysHugePageSize
+	if hugePage == 0 {
+		t.Skip("transparent huge pages are not available")
+	}
+	type elem [64]byte
+	const n = 16 << 20 / 64
+
+	old := runtime.SetChanHugePage(true)
+	on := make(chan elem, n)
+	runtime.SetChanHugePage(old)
+
+	// The first huge page entirely inside the buffer must be in a
+	// mapping with the MADV_HUGEPAGE flag.
+	addr := (uintptr(runtime.ChanBuf(on)) + hugePage - 1) &^ (hugePage - 1)
+	flags, err := vmFlags(addr)
+	if err != nil {
+		t.Skip(err)
+	}
+	if !strings.Contains(" "+flags+" ", " hg ") {
+		t.Errorf("buffer mapping has VmFlags %q, want hg", flags)
+	}
+	runtime.KeepAlive(on)
+}
+
+// vmFlags returns the VmFlags of the mapping in /proc/self/smaps that
+// contains addr.
+func vmFlags(addr uintptr) (string, error) {
+	f, err := os.Open("/proc/self/smaps")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	in := false
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if strings.HasPrefix(line, "VmFlags:") {
+			if in {
+				return strings.TrimSpace(strings.TrimPrefix(line, "VmFlags:")), nil
+			}
+			continue
+		}
+		// A mapping starts with a line like "c000000000-c004000000 rw-p ...".
+		r := strings.Fields(line)
+		if len(r) == 0 || !strings.Contains(r[0], "-") || strings.HasSuffix(r[0], ":") {
+			continue
+		}
+		bounds := strings.SplitN(r[0], "-", 2)
+		lo, err1 := strconv.ParseUint(bounds[0], 16, 64)
+		hi, err2 := strconv.ParseUint(bounds[1], 16, 64)
+		if err1 == nil && err2 == nil {
+			in = uint64(lo) <= uint64(addr) && uint64(addr) < uint64(hi)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+	return "", os.ErrNotExist
+}
+
+// BenchmarkChanHugePage streams values through a channel with a 512 MB
+// buffer, kept half full so that the sender and the receiver work on
+// pages 256 MB apart, with and without huge pages. Run it under
+// "perf stat -e dTLB-load-misses,dTLB-store-misses" to compare the
+// TLB misses of the two.
+func BenchmarkChanHugePage(b *testing.B) {
+	if runtime.PhysHugePageSize == 0 {
+		b.Skip("transparent huge pages are not available")
+	}
+	type elem [64]byte
+	const n = 512 << 20 / 64
+	for _, bc := range []struct {
+		name string
+		on   bool
+	}{{"off", false}, {"on", true}} {
+		b.Run(bc.name, func(b *testing.B) {
+			old := runtime.SetChanHugePage(bc.on)
+			c := make(chan elem, n)
+			runtime.SetChanHugePage(old)
+
+			var v elem
+			for i := 0; i < n/2; i++ {
+				c <- v
+			}
+			b.SetBytes(int64(unsafe.Sizeof(v)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c <- v
+				v = <-c
+			}
+			b.StopTimer()
+			c = nil
+			runtime.GC()
+		})
+	}
+}
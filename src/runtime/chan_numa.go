@@ -0,0 +1,60 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// NUMA placement of large channel buffers.
+//
+// The pages of a channel's buffer normally end up on the NUMA node of
+// the thread that first touches them, which is usually the thread
+// that created the channel. If the channel is mostly drained by
+// goroutines running on another node, every receive then copies
+// values across nodes. With GODEBUG=channuma=1, makechan interleaves
+// the pages of buffers of at least chanNUMAMinBytes across all nodes,
+// which spreads the cross-node traffic evenly. With
+// GODEBUG=channuma=2, the first receive from such a channel, or the
+// first select with a receive case on it, moves the buffer to the
+// node of the receiving thread.
+//
+// Buffers that share an allocation with their hchan are placed too;
+// only the pages entirely inside the buffer are affected.
+
+// chanNUMAMinBytes is the smallest channel buffer that is placed.
+// Each placed buffer adds mappings to the kernel's bookkeeping, so
+// small buffers, which are also cheap to access remotely, are left
+// alone.
+const chanNUMAMinBytes = 1 << 20
+
+// Values of GODEBUG=channuma.
+const (
+	chanNUMAOff        = 0
+	chanNUMAInterleave = 1
+	chanNUMAConsumer   = 2
+)
+
+// chanNUMAPlace applies the placement policy to the mem-byte buffer of
+// the newly created channel c.
+func chanNUMAPlace(c *hchan, mem uintptr) {
+	if mem < chanNUMAMinBytes || numaNodes < 2 {
+		return
+	}
+	switch debug.channuma {
+	case chanNUMAInterleave:
+		sysPlaceHeap(c.buf, mem, placeInterleave)
+	case chanNUMAConsumer:
+		c.flags |= chanNUMAPending
+	}
+}
+
+// chanNUMAConsume moves the buffer of c, whose placement was left to
+// its first consumer, to the node of the calling thread.
+func chanNUMAConsume(c *hchan) {
+	lock(&c.lock)
+	pending := c.flags&chanNUMAPending != 0
+	c.flags &^= chanNUMAPending
+	unlock(&c.lock)
+	if pending {
+		sysPlaceHeap(c.buf, uintptr(c.dataqsiz)*uintptr(c.elemsize), placeLocal)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime_test
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+func TestParseNodeList(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		mask uint64
+		ok   bool
+	}{
+		{"0\n", 1, true},
+		{"0-1\n", 3, true},
+		{"0,2-3,5", 0x2d, true},
+		{"63", 1 << 63, true},
+		{"", 0, false},
+		{"0-", 0, false},
+		{"2-1", 0, false},
+		{"0-64", 0, false},
+		{"x", 0, false},
+	} {
+		mask, ok := runtime.ParseNodeList(tt.in)
+		if mask != tt.mask || ok != tt.ok {
+			t.Errorf("ParseNodeList(%q) = %#x, %v, want %#x, %v", tt.in, mask, ok, tt.mask, tt.ok)
+		}
+	}
+}
+
+func TestChanNUMAPlacement(t *testing.T) {
+	// Pretend node 0 is one of two nodes, so that placing pages on
+	// node 0 works on any machine.
+	defer runtime.SetNUMANodes(runtime.SetNUMANodes(2, 1))
+
+	type elem [64]byte
+	const n = 1 << 20 / 64
+	for _, policy := range []int{1, 2} {
+		old := runtime.SetChanNUMAPolicy(policy)
+		c := make(chan elem, n)
+		small := make(chan elem, 10)
+		runtime.SetChanNUMAPolicy(old)
+
+		if runtime.ChanBufPlaced(small) {
+			t.Errorf("channuma=%d: small buffer placed", policy)
+		}
+		if got, want := runtime.ChanBufPlaced(c), policy == 1; got != want {
+			t.Errorf("channuma=%d: buffer placed after make = %v, want %v", policy, got, want)
+		}
+		c <- elem{1}
+		if v := <-c; v[0] != 1 {
+			t.Errorf("channuma=%d: received %v, want 1", policy, v[0])
+		}
+		if !runtime.ChanBufPlaced(c) {
+			t.Errorf("channuma=%d: buffer not placed after receive", policy)
+		}
+	}
+	// Free the buffers, resetting their placement.
+	runtime.GC()
+	runtime.GC()
+}
+
+// BenchmarkChanNUMA streams values through a channel with a 4 MB
+// buffer from a producer thread pinned to a CPU of NUMA node 0 to a
+// consumer thread pinned to a CPU of node 1, under each placement
+// policy.
+func BenchmarkChanNUMA(b *testing.B) {
+	if runtime.NUMANodes() < 2 {
+		b.Skip("needs a machine with at least two NUMA nodes")
+	}
+	cpu0, err := firstNodeCPU(0)
+	if err != nil {
+		b.Skip(err)
+	}
+	cpu1, err := firstNodeCPU(1)
+	if err != nil {
+		b.Skip(err)
+	}
+
+	type elem [64]byte
+	const n = 4 << 20 / 64
+	for _, bc := range []struct {
+		name   string
+		policy int
+	}{{"default", 0}, {"interleave", 1}, {"consumer", 2}} {
+		b.Run(bc.name, func(b *testing.B) {
+			old := runtime.SetChanNUMAPolicy(bc.policy)
+			c := make(chan elem, n)
+			runtime.SetChanNUMAPolicy(old)
+
+			// The pinned threads exit along with their goroutines,
+			// which never unlock them.
+			done := make(chan error)
+			go func() {
+				runtime.LockOSThread()
+				if err := pinThread(cpu1); err != nil {
+					done <- err
+					return
+				}
+				for i := 0; i < b.N; i++ {
+					<-c
+				}
+				done <- nil
+			}()
+			b.SetBytes(int64(unsafe.Sizeof(elem{})))
+			b.ResetTimer()
+			go func() {
+				runtime.LockOSThread()
+				if err := pinThread(cpu0); err != nil {
+					done <- err
+					return
+				}
+				var v elem
+				for i := 0; i < b.N; i++ {
+					c <- v
+				}
+				done <- nil
+			}()
+			for i := 0; i < 2; i++ {
+				if err := <-done; err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// firstNodeCPU returns the lowest numbered CPU of NUMA node node.
+func firstNodeCPU(node int) (int, error) {
+	b, err := os.ReadFile("/sys/devices/system/node/node" + strconv.Itoa(node) + "/cpulist")
+	if err != nil {
+		return 0, err
+	}
+	first := strings.FieldsFunc(strings.TrimSpace(string(b)), func(r rune) bool { return r == ',' || r == '-' })
+	if len(first) == 0 {
+		return 0, os.ErrNotExist
+	}
+	return strconv.Atoi(first[0])
+}
+
+// pinThread restricts the calling thread to CPU cpu.
+func pinThread(cpu int) error {
+	mask := make([]uint64, cpu/64+1)
+	mask[cpu/64] = 1 << (cpu % 64)
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY, 0, uintptr(len(mask)*8), uintptr(unsafe.Pointer(&mask[0])))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
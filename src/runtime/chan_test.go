@@ -5,13 +5,21 @@
 package runtime_test
 
 import (
+	"fmt"
+	"internal/goexperiment"
 	"internal/testenv"
 	"math"
+	"math/rand"
+	"reflect"
 	"runtime"
+	"runtime/debug"
+	"sort"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+	"unsafe"
 )
 
 func TestChan(t *testing.T) {
@@ -223,6 +231,56 @@ func TestNonblockRecvRace(t *testing.T) {
 	}
 }
 
+// Test that a sender repeatedly panicking on a closed channel doesn't
+// interfere with draining that channel's buffer.
+func TestChanDrainWithClosedSender(t *testing.T) {
+	n := 100000
+	if testing.Short() {
+		n = 10000
+	}
+	c := make(chan int, n)
+	for i := 0; i < n; i++ {
+		c <- i
+	}
+	close(c)
+
+	stop := make(chan bool)
+	done := make(chan bool)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				done <- true
+				return
+			default:
+			}
+			func() {
+				defer func() {
+					if e := recover(); e != nil {
+						if err, ok := e.(error); !ok || err.Error() != "send on closed channel" {
+							t.Errorf("send panicked with %v, want send on closed channel", e)
+						}
+					}
+				}()
+				c <- -1
+				t.Errorf("send on closed channel succeeded")
+			}()
+		}
+	}()
+
+	for i := 0; i < n; i++ {
+		v, ok := <-c
+		if !ok || v != i {
+			t.Fatalf("received %v, %v; want %v, true", v, ok, i)
+		}
+	}
+	if v, ok := <-c; ok {
+		t.Fatalf("received %v from drained channel", v)
+	}
+	close(stop)
+	<-done
+}
+
 // This test checks that select acts on the state of the channels at one
 // moment in the execution, not over a smeared time window.
 // In the test, one goroutine does:
@@ -437,6 +495,10 @@ func TestSelectFairness(t *testing.T) {
 	if runtime.GOOS == "linux" && runtime.GOARCH == "ppc64le" {
 		testenv.SkipFlaky(t, 22047)
 	}
+	if goexperiment.OrderedSelect {
+		t.Skip("select is not randomized with GOEXPERIMENT=orderedselect")
+	}
+	defer runtime.SetSelectAdaptive(runtime.SetSelectAdaptive(false))
 	c1 := make(chan byte, trials+1)
 	c2 := make(chan byte, trials+1)
 	for i := 0; i < trials+1; i++ {
@@ -508,6 +570,9 @@ func TestChanSendInterface(t *testing.T) {
 }
 
 func TestPseudoRandomSend(t *testing.T) {
+	if goexperiment.OrderedSelect {
+		t.Skip("select is not randomized with GOEXPERIMENT=orderedselect")
+	}
 	n := 100
 	for _, chanCap := range []int{0, n} {
 		c := make(chan int, chanCap)
@@ -591,6 +656,76 @@ func TestMultiConsumer(t *testing.T) {
 	}
 }
 
+func TestSelectAdaptive(t *testing.T) {
+	if goexperiment.OrderedSelect {
+		t.Skip("select is not randomized with GOEXPERIMENT=orderedselect")
+	}
+	defer runtime.SetSelectAdaptive(runtime.SetSelectAdaptive(true))
+	// Keep the state of the select on a single P.
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+
+	var cs [64]chan int
+	for i := range cs {
+		cs[i] = make(chan int, 1)
+	}
+	const hot, other, trials = 5, 40, 4000
+
+	// Make a case hot by choosing it repeatedly.
+	for i := 0; i < 100; i++ {
+		cs[hot] <- 0
+		if k := select64(&cs); k != hot {
+			t.Fatalf("select64 chose case %d, want %d", k, hot)
+		}
+	}
+
+	// A hot case that is ready is usually polled first, but a case
+	// that is ready along with it must still be chosen sometimes.
+	var n [64]int
+	for i := 0; i < trials; i++ {
+		for _, k := range []int{hot, other} {
+			select {
+			case cs[k] <- 0:
+			default:
+			}
+		}
+		n[select64(&cs)]++
+	}
+	if n[hot]+n[other] != trials {
+		t.Fatalf("chose cases other than %d and %d: %v", hot, other, n)
+	}
+	if n[other] == 0 || n[hot] <= n[other] {
+		t.Errorf("chose case %d %d times and case %d %d times, want both, the first more often", hot, n[hot], other, n[other])
+	}
+
+	// Every case is still polled, and a select with no ready case
+	// blocks until one is.
+	for _, k := range []int{hot, other} {
+		select {
+		case <-cs[k]:
+		default:
+		}
+	}
+	for i := range cs {
+		cs[i] <- 0
+		if k := select64(&cs); k != i {
+			t.Fatalf("select64 chose case %d, want %d", k, i)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		k := hot
+		if i%10 == 0 {
+			k = other
+		}
+		go func() {
+			time.Sleep(time.Millisecond)
+			cs[k] <- 0
+		}()
+		if got := select64(&cs); got != k {
+			t.Fatalf("select64 chose case %d, want %d", got, k)
+		}
+	}
+}
+
 func TestShrinkStackDuringBlockedSend(t *testing.T) {
 	// make sure that channel operations still work when we are
 	// blocked on a channel send and we shrink the stack.
@@ -623,6 +758,65 @@ func TestShrinkStackDuringBlockedSend(t *testing.T) {
 	<-done
 }
 
+// TestShrinkStackWhileParked checks that the GC shrinks the stacks of
+// goroutines that grew them and then parked on a channel, both in
+// plain receives and in selects, and that the values they are sent
+// while parked land in the moved stack slots.
+func TestShrinkStackWhileParked(t *testing.T) {
+	if runtime.Compiler == "gccgo" {
+		t.Skip("gccgo does not copy stacks")
+	}
+	const n = 8
+	type result struct {
+		v    int
+		size uintptr
+	}
+	c, other := make(chan int), make(chan int)
+	res := make(chan result, 2*n)
+	grown := make(chan uintptr, 2*n)
+	for i := 0; i < 2*n; i++ {
+		sel := i%2 == 1
+		go func() {
+			stackGrowthRecursive(64)
+			grown <- runtime.StackSize()
+			var v int
+			if sel {
+				select {
+				case v = <-c:
+				case v = <-other:
+				}
+			} else {
+				v = <-c
+			}
+			res <- result{v, runtime.StackSize()}
+		}()
+	}
+	var max uintptr
+	for i := 0; i < 2*n; i++ {
+		if s := <-grown; s > max {
+			max = s
+		}
+	}
+	// Each GC cycle halves the stack of a parked goroutine.
+	for i := 0; i < 8; i++ {
+		runtime.GC()
+	}
+	for i := 0; i < 2*n; i++ {
+		c <- i
+	}
+	seen := make(map[int]bool)
+	for i := 0; i < 2*n; i++ {
+		r := <-res
+		if r.v < 0 || r.v >= 2*n || seen[r.v] {
+			t.Errorf("received bad value %d", r.v)
+		}
+		seen[r.v] = true
+		if r.size >= max {
+			t.Errorf("stack of parked goroutine not shrunk: %d bytes, grew to %d", r.size, max)
+		}
+	}
+}
+
 func TestNoShrinkStackWhileParking(t *testing.T) {
 	// The goal of this test is to trigger a "racy sudog adjustment"
 	// throw. Basically, there's a window between when a goroutine
@@ -708,6 +902,284 @@ func TestSelectDuplicateChannel(t *testing.T) {
 	c <- 8 // wake up B.  This operation used to fail because c.recvq was corrupted (it tries to wake up an already running G instead of B)
 }
 
+// select64 receives from the first ready channel in cs and returns its index.
+func select64(cs *[64]chan int) int {
+	select {
+	case <-cs[0]:
+		return 0
+	case <-cs[1]:
+		return 1
+	case <-cs[2]:
+		return 2
+	case <-cs[3]:
+		return 3
+	case <-cs[4]:
+		return 4
+	case <-cs[5]:
+		return 5
+	case <-cs[6]:
+		return 6
+	case <-cs[7]:
+		return 7
+	case <-cs[8]:
+		return 8
+	case <-cs[9]:
+		return 9
+	case <-cs[10]:
+		return 10
+	case <-cs[11]:
+		return 11
+	case <-cs[12]:
+		return 12
+	case <-cs[13]:
+		return 13
+	case <-cs[14]:
+		return 14
+	case <-cs[15]:
+		return 15
+	case <-cs[16]:
+		return 16
+	case <-cs[17]:
+		return 17
+	case <-cs[18]:
+		return 18
+	case <-cs[19]:
+		return 19
+	case <-cs[20]:
+		return 20
+	case <-cs[21]:
+		return 21
+	case <-cs[22]:
+		return 22
+	case <-cs[23]:
+		return 23
+	case <-cs[24]:
+		return 24
+	case <-cs[25]:
+		return 25
+	case <-cs[26]:
+		return 26
+	case <-cs[27]:
+		return 27
+	case <-cs[28]:
+		return 28
+	case <-cs[29]:
+		return 29
+	case <-cs[30]:
+		return 30
+	case <-cs[31]:
+		return 31
+	case <-cs[32]:
+		return 32
+	case <-cs[33]:
+		return 33
+	case <-cs[34]:
+		return 34
+	case <-cs[35]:
+		return 35
+	case <-cs[36]:
+		return 36
+	case <-cs[37]:
+		return 37
+	case <-cs[38]:
+		return 38
+	case <-cs[39]:
+		return 39
+	case <-cs[40]:
+		return 40
+	case <-cs[41]:
+		return 41
+	case <-cs[42]:
+		return 42
+	case <-cs[43]:
+		return 43
+	case <-cs[44]:
+		return 44
+	case <-cs[45]:
+		return 45
+	case <-cs[46]:
+		return 46
+	case <-cs[47]:
+		return 47
+	case <-cs[48]:
+		return 48
+	case <-cs[49]:
+		return 49
+	case <-cs[50]:
+		return 50
+	case <-cs[51]:
+		return 51
+	case <-cs[52]:
+		return 52
+	case <-cs[53]:
+		return 53
+	case <-cs[54]:
+		return 54
+	case <-cs[55]:
+		return 55
+	case <-cs[56]:
+		return 56
+	case <-cs[57]:
+		return 57
+	case <-cs[58]:
+		return 58
+	case <-cs[59]:
+		return 59
+	case <-cs[60]:
+		return 60
+	case <-cs[61]:
+		return 61
+	case <-cs[62]:
+		return 62
+	case <-cs[63]:
+		return 63
+	}
+}
+
+func TestSelectWide(t *testing.T) {
+	var cs [64]chan int
+	for i := range cs {
+		cs[i] = make(chan int, 1)
+	}
+	check := func(want ...int) {
+		t.Helper()
+		got := select64(&cs)
+		for _, w := range want {
+			if got == w {
+				return
+			}
+		}
+		t.Fatalf("select64 chose case %d, want one of %v", got, want)
+	}
+	// Same channels on every iteration, as in a loop.
+	for i := 0; i < 1000; i++ {
+		k := i * 7 % len(cs)
+		cs[k] <- i
+		check(k)
+	}
+	// Replace a channel between iterations.
+	for i := 0; i < 100; i++ {
+		cs[3] = make(chan int, 1)
+		cs[3] <- i
+		check(3)
+	}
+	// Nil out cases. Nil channels must never be chosen.
+	saved := cs[10]
+	cs[10] = nil
+	for i := 0; i < 100; i++ {
+		cs[11] <- i
+		check(11)
+	}
+	cs[10] = saved
+	// Duplicate channels.
+	cs[20] = cs[21]
+	for i := 0; i < 100; i++ {
+		cs[21] <- i
+		check(20, 21)
+	}
+}
+
+// selectOne runs a select in which the cases on nil channels are
+// disabled, and returns the index of the case chosen, or -1 for the
+// default case if hasDefault is set.
+func selectOne(send, recv1, recv2 chan int, v *int, ok *bool, hasDefault bool) int {
+	if hasDefault {
+		select {
+		case send <- *v:
+			return 0
+		case *v, *ok = <-recv1:
+			return 1
+		case *v, *ok = <-recv2:
+			return 2
+		default:
+			return -1
+		}
+	}
+	select {
+	case send <- *v:
+		return 0
+	case *v, *ok = <-recv1:
+		return 1
+	case *v, *ok = <-recv2:
+		return 2
+	}
+}
+
+// TestSelectOneChannel checks selects with a single non-nil channel,
+// which are done as plain sends and receives.
+func TestSelectOneChannel(t *testing.T) {
+	check := func(name string, got, want int) {
+		t.Helper()
+		if got != want {
+			t.Errorf("%s: chose case %d, want %d", name, got, want)
+		}
+	}
+	var v int
+	var ok bool
+
+	c := make(chan int, 1)
+	v = 7
+	check("send to buffer", selectOne(c, nil, nil, &v, &ok, true), 0)
+	check("send to full buffer", selectOne(c, nil, nil, &v, &ok, true), -1)
+	v = 0
+	check("receive from buffer", selectOne(nil, nil, c, &v, &ok, true), 2)
+	if v != 7 || !ok {
+		t.Errorf("receive from buffer: got %d, %v, want 7, true", v, ok)
+	}
+	check("receive from empty buffer", selectOne(nil, c, nil, &v, &ok, true), -1)
+
+	// Blocking cases.
+	u := make(chan int)
+	go func() { u <- 42 }()
+	check("blocking receive", selectOne(nil, u, nil, &v, &ok, false), 1)
+	if v != 42 || !ok {
+		t.Errorf("blocking receive: got %d, %v, want 42, true", v, ok)
+	}
+	done := make(chan int)
+	go func() { done <- <-u }()
+	v = 43
+	check("blocking send", selectOne(u, nil, nil, &v, &ok, false), 0)
+	if got := <-done; got != 43 {
+		t.Errorf("blocking send: receiver got %d, want 43", got)
+	}
+
+	// Closed channels.
+	close(c)
+	check("receive from closed", selectOne(nil, c, nil, &v, &ok, true), 1)
+	if v != 0 || ok {
+		t.Errorf("receive from closed: got %d, %v, want 0, false", v, ok)
+	}
+	check("blocking receive from closed", selectOne(nil, nil, c, &v, &ok, false), 2)
+	for _, hasDefault := range []bool{true, false} {
+		func() {
+			defer func() {
+				e := recover()
+				if err, _ := e.(error); err == nil || err.Error() != "send on closed channel" {
+					t.Errorf("send on closed channel (default %v): recovered %v", hasDefault, e)
+				}
+			}()
+			selectOne(c, nil, nil, &v, &ok, hasDefault)
+		}()
+	}
+
+	// Through reflect.Select.
+	r := make(chan int, 1)
+	r <- 5
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf((chan int)(nil))},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(r)},
+		{Dir: reflect.SelectDefault},
+	}
+	i, rv, rok := reflect.Select(cases)
+	if i != 1 || rv.Int() != 5 || !rok {
+		t.Errorf("reflect.Select: got %d, %v, %v, want 1, 5, true", i, rv, rok)
+	}
+	i, _, _ = reflect.Select(cases)
+	if i != 2 {
+		t.Errorf("reflect.Select on empty channel: got %d, want 2", i)
+	}
+}
+
 func TestSelectStackAdjust(t *testing.T) {
 	// Test that channel receive slots that contain local stack
 	// pointers are adjusted correctly by stack shrinking.
@@ -775,6 +1247,41 @@ func TestSelectStackAdjust(t *testing.T) {
 
 type struct0 struct{}
 
+// BenchmarkMakeChanJoin measures a channel made to collect the result
+// of a goroutine that is joined before the function returns. The
+// channel escapes through the go statement, so it is allocated on the
+// heap along with the goroutine's closure.
+// BenchmarkChanDrainPointers fills a large buffered channel of
+// pointers and drains it, so that receives clear long runs of slots.
+// Each op is one value sent and received.
+func BenchmarkChanDrainPointers(b *testing.B) {
+	type elem struct {
+		p    *int
+		s    string
+		x, y int
+	}
+	const size = 4096
+	c := make(chan elem, size)
+	v := elem{p: new(int), s: "x"}
+	for i := 0; i < b.N; i += size {
+		for j := 0; j < size; j++ {
+			c <- v
+		}
+		for j := 0; j < size; j++ {
+			<-c
+		}
+	}
+}
+
+func BenchmarkMakeChanJoin(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		c := make(chan int, 1)
+		go func() { c <- 1 }()
+		<-c
+	}
+}
+
 func BenchmarkMakeChan(b *testing.B) {
 	b.Run("Byte", func(b *testing.B) {
 		var x chan byte
@@ -850,6 +1357,57 @@ func BenchmarkSelectUncontended(b *testing.B) {
 	})
 }
 
+func BenchmarkSelectWide64(b *testing.B) {
+	var cs [64]chan int
+	for i := range cs {
+		cs[i] = make(chan int, 1)
+	}
+	for i := 0; i < b.N; i++ {
+		k := i % len(cs)
+		cs[k] <- 0
+		if select64(&cs) != k {
+			b.Fatal("wrong case")
+		}
+	}
+}
+
+// BenchmarkSelectSkewed runs a 64-case select where one channel is
+// ready 99% of the time, with and without GODEBUG=selectadaptive, and
+// a 2-case select for comparison.
+func BenchmarkSelectSkewed(b *testing.B) {
+	var cs [64]chan int
+	for i := range cs {
+		cs[i] = make(chan int, 1)
+	}
+	const hot = 17
+	for _, adaptive := range []bool{false, true} {
+		b.Run(fmt.Sprintf("adaptive=%v", adaptive), func(b *testing.B) {
+			defer runtime.SetSelectAdaptive(runtime.SetSelectAdaptive(adaptive))
+			for i := 0; i < b.N; i++ {
+				k := hot
+				if i%100 == 0 {
+					k = i / 100 % len(cs)
+				}
+				cs[k] <- 0
+				if select64(&cs) != k {
+					b.Fatal("wrong case")
+				}
+			}
+		})
+	}
+	b.Run("cases=2", func(b *testing.B) {
+		c, other := cs[0], cs[1]
+		for i := 0; i < b.N; i++ {
+			c <- 0
+			select {
+			case <-c:
+			case <-other:
+				b.Fatal("wrong case")
+			}
+		}
+	})
+}
+
 func BenchmarkSelectSyncContended(b *testing.B) {
 	myc1 := make(chan int)
 	myc2 := make(chan int)
@@ -895,6 +1453,40 @@ func BenchmarkSelectAsyncContended(b *testing.B) {
 	})
 }
 
+// BenchmarkSelectLatency measures how long a value offered by a
+// producer waits before a request/response style two-case select
+// receives it, and reports the percentiles of that latency. The
+// producer offers the next value as soon as the previous one is taken,
+// so it races with the consumer entering the select.
+func BenchmarkSelectLatency(b *testing.B) {
+	data := make(chan int64)
+	done := make(chan bool)
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case data <- time.Now().UnixNano():
+			case <-done:
+				return
+			}
+		}
+	}()
+	lat := make([]int64, b.N)
+	b.ResetTimer()
+	for i := range lat {
+		select {
+		case t := <-data:
+			lat[i] = time.Now().UnixNano() - t
+		case <-done:
+		}
+	}
+	b.StopTimer()
+	sort.Slice(lat, func(i, j int) bool { return lat[i] < lat[j] })
+	for _, p := range []int{50, 90, 99} {
+		b.ReportMetric(float64(lat[(len(lat)-1)*p/100]), "p"+strconv.Itoa(p)+"-ns")
+	}
+}
+
 func BenchmarkSelectNonblock(b *testing.B) {
 	myc1 := make(chan int)
 	myc2 := make(chan int)
@@ -922,6 +1514,16 @@ func BenchmarkSelectNonblock(b *testing.B) {
 	})
 }
 
+func BenchmarkSelectNilCases(b *testing.B) {
+	var v int
+	var ok bool
+	c := make(chan int, 1)
+	for i := 0; i < b.N; i++ {
+		selectOne(c, nil, nil, &v, &ok, true)
+		selectOne(nil, c, nil, &v, &ok, true)
+	}
+}
+
 func BenchmarkChanUncontended(b *testing.B) {
 	const C = 100
 	b.RunParallel(func(pb *testing.PB) {
@@ -952,6 +1554,11 @@ func BenchmarkChanContended(b *testing.B) {
 	})
 }
 
+func BenchmarkChanContendedMutexProfile(b *testing.B) {
+	defer runtime.SetMutexProfileFraction(runtime.SetMutexProfileFraction(1))
+	BenchmarkChanContended(b)
+}
+
 func benchmarkChanSync(b *testing.B, work int) {
 	const CallsPerSched = 1000
 	procs := 2
@@ -995,6 +1602,72 @@ func BenchmarkChanSyncWork(b *testing.B) {
 	benchmarkChanSync(b, 1000)
 }
 
+// BenchmarkChanPingPong measures the rendezvous latency of two
+// goroutines passing a value back and forth over unbuffered channels.
+// Each send finds exactly one receiver parked.
+func BenchmarkChanPingPong(b *testing.B) {
+	ping := make(chan int)
+	pong := make(chan int)
+	go func() {
+		for v := range ping {
+			pong <- v
+		}
+		close(pong)
+	}()
+	for i := 0; i < b.N; i++ {
+		ping <- i
+		<-pong
+	}
+	close(ping)
+	<-pong
+}
+
+func BenchmarkChanPingPongDirectSwitch(b *testing.B) {
+	defer runtime.SetChanDirectSwitch(runtime.SetChanDirectSwitch(true))
+	BenchmarkChanPingPong(b)
+}
+
+func BenchmarkChanElemKind(b *testing.B) {
+	s := "hello"
+	bs := []byte(s)
+	var e interface{} = &s
+	b.Run("string", func(b *testing.B) {
+		c := make(chan string, 1)
+		for i := 0; i < b.N; i++ {
+			c <- s
+			<-c
+		}
+	})
+	b.Run("slice", func(b *testing.B) {
+		c := make(chan []byte, 1)
+		for i := 0; i < b.N; i++ {
+			c <- bs
+			<-c
+		}
+	})
+	b.Run("iface", func(b *testing.B) {
+		c := make(chan interface{}, 1)
+		for i := 0; i < b.N; i++ {
+			c <- e
+			<-c
+		}
+	})
+	b.Run("string-sync", func(b *testing.B) {
+		c := make(chan string)
+		done := make(chan bool)
+		go func() {
+			for range c {
+			}
+			done <- true
+		}()
+		for i := 0; i < b.N; i++ {
+			c <- s
+		}
+		close(c)
+		<-done
+	})
+}
+
 func benchmarkChanProdCons(b *testing.B, chanSize, localWork int) {
 	const CallsPerSched = 1000
 	procs := runtime.GOMAXPROCS(-1)
@@ -1183,6 +1856,36 @@ func BenchmarkChanClosed(b *testing.B) {
 	})
 }
 
+func BenchmarkChanContendedBig(b *testing.B) {
+	type big [256]byte
+	c := make(chan big, 1024)
+	b.RunParallel(func(pb *testing.PB) {
+		var v big
+		for pb.Next() {
+			c <- v
+			v = <-c
+		}
+	})
+}
+
+func BenchmarkChanClosedRecv(b *testing.B) {
+	const n = 1000
+	c := make(chan struct{})
+	close(c)
+	var wg sync.WaitGroup
+	wg.Add(n)
+	b.ResetTimer()
+	for j := 0; j < n; j++ {
+		go func() {
+			for i := 0; i < b.N; i += n {
+				<-c
+			}
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+}
+
 var (
 	alwaysFalse = false
 	workSink    = 0
@@ -1197,3 +1900,1264 @@ func localWork(w int) {
 		workSink += foo
 	}
 }
+
+// TestChanParkStackShrinkStress plays channel ping-pong between
+// goroutines whose stacks grow before every operation and so are
+// worth shrinking whenever they park, while the GC runs continuously.
+// In the window before a parking goroutine tells stack copying that
+// its stack is referenced by sudogs, the runtime yields, to let the
+// GC at the stack, and checks that the parkingOnChan handshake keeps
+// the GC from shrinking it, crashing if not.
+func TestChanParkStackShrinkStress(t *testing.T) {
+	n := 20000
+	if testing.Short() {
+		n = 2000
+	}
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	before := runtime.SetChanParkStress(true)
+	defer runtime.SetChanParkStress(false)
+
+	stop := make(chan bool)
+	gcDone := make(chan bool)
+	go func() {
+		defer close(gcDone)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				runtime.GC()
+			}
+		}
+	}()
+
+	type val [4]int
+	var wg sync.WaitGroup
+	errs := make(chan string, 8)
+	for p := 0; p < 4; p++ {
+		ping, pong := make(chan val), make(chan val)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				useStack(i%32 + 1)
+				v := val{i, i, i, i}
+				ping <- v
+				useStack(i%32 + 1)
+				var w val
+				w = <-pong
+				if w != (val{i + 1, i + 1, i + 1, i + 1}) {
+					errs <- "ping got bad reply"
+					return
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				useStack(i%32 + 1)
+				var v val
+				select {
+				case v = <-ping:
+				case <-stop:
+					return
+				}
+				if v != (val{i, i, i, i}) {
+					errs <- "pong got bad value"
+					return
+				}
+				useStack(i%32 + 1)
+				w := val{i + 1, i + 1, i + 1, i + 1}
+				select {
+				case pong <- w:
+				case <-stop:
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-gcDone
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if blocked := runtime.SetChanParkStress(false); blocked == before {
+		t.Errorf("no stack shrink was checked while parking on a channel")
+	}
+}
+
+// TestCheckStackChans runs goroutines that grow their stacks and then
+// park sending values from their stacks, while the GC runs
+// continuously and moves the stacks of the parked senders, with
+// GODEBUG=checkstackchans on to check each move.
+func TestCheckStackChans(t *testing.T) {
+	n := 5000
+	if testing.Short() {
+		n = 500
+	}
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	before := runtime.SetCheckStackChans(true)
+	defer runtime.SetCheckStackChans(false)
+
+	stop := make(chan bool)
+	gcDone := make(chan bool)
+	go func() {
+		defer close(gcDone)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				runtime.GC()
+			}
+		}
+	}()
+
+	type val [8]int
+	var wg sync.WaitGroup
+	errs := make(chan string, 8)
+	for p := 0; p < 4; p++ {
+		c, other := make(chan val), make(chan val)
+		sel := p%2 == 1
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				stackGrowthRecursive(i%16 + 1)
+				v := val{i, i, i, i, i, i, i, i}
+				if sel {
+					select {
+					case c <- v:
+					case other <- v:
+					}
+				} else {
+					c <- v
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < n; i++ {
+				if i%64 == 0 {
+					time.Sleep(time.Millisecond)
+				}
+				if v := <-c; v != (val{i, i, i, i, i, i, i, i}) {
+					errs <- "received bad value"
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-gcDone
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if checked := runtime.SetCheckStackChans(false); checked == before {
+		t.Errorf("no sudog was checked when moving a stack")
+	}
+}
+
+// TestChanElemCopyGC sends freshly allocated strings, slices, and
+// interfaces through channels, buffered and unbuffered and by select,
+// while the garbage collector runs continually. The values are
+// reachable only from the channel or the goroutines' stacks while they
+// are in flight, so a missing write barrier on any of the copies lets
+// the collector free them, which the receivers detect when they check
+// the values they received.
+func TestChanElemCopyGC(t *testing.T) {
+	n := 20000
+	if testing.Short() {
+		n = 2000
+	}
+	defer debug.SetGCPercent(debug.SetGCPercent(1))
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	stop := make(chan bool)
+	churnDone := make(chan bool)
+	go func() {
+		defer close(churnDone)
+		var keep [16][]byte
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			keep[i%len(keep)] = make([]byte, 64+i%1024)
+			if i%1000 == 0 {
+				runtime.GC()
+			}
+		}
+	}()
+
+	type box struct{ s string }
+	str := func(i int) string { return "value " + strconv.Itoa(i) }
+	check := func(kind string, i int, got string) error {
+		if want := str(i); got != want {
+			return fmt.Errorf("%s: received %q, want %q", kind, got, want)
+		}
+		return nil
+	}
+	// run sends n values with send and checks the ones recv returns,
+	// rechecking each batch of the last few after more have been
+	// received.
+	run := func(kind string, send func(i int), recv func() string) error {
+		errc := make(chan error, 1)
+		go func() {
+			for i := 0; i < n; i++ {
+				send(i)
+			}
+		}()
+		go func() {
+			var last [8]string
+			for i := 0; i < n; i++ {
+				got := recv()
+				if err := check(kind, i, got); err != nil {
+					errc <- err
+					return
+				}
+				last[i%len(last)] = got
+				if i%len(last) == len(last)-1 {
+					for j, s := range last {
+						if err := check(kind, i-len(last)+1+j, s); err != nil {
+							errc <- err
+							return
+						}
+					}
+				}
+			}
+			errc <- nil
+		}()
+		return <-errc
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 12)
+	for _, size := range []int{0, 4} {
+		for _, sel := range []bool{false, true} {
+			size, sel := size, sel
+			other := make(chan bool)
+			cs := make(chan string, size)
+			cb := make(chan []byte, size)
+			ci := make(chan interface{}, size)
+			tests := []struct {
+				kind string
+				send func(int)
+				recv func() string
+			}{
+				{"string", func(i int) {
+					v := str(i)
+					if sel {
+						select {
+						case cs <- v:
+						case <-other:
+						}
+					} else {
+						cs <- v
+					}
+				}, func() string {
+					if sel {
+						select {
+						case v := <-cs:
+							return v
+						case <-other:
+						}
+					}
+					return <-cs
+				}},
+				{"slice", func(i int) {
+					v := []byte(str(i))
+					if sel {
+						select {
+						case cb <- v:
+						case <-other:
+						}
+					} else {
+						cb <- v
+					}
+				}, func() string {
+					if sel {
+						select {
+						case v := <-cb:
+							return string(v)
+						case <-other:
+						}
+					}
+					return string(<-cb)
+				}},
+				{"interface", func(i int) {
+					var v interface{} = &box{str(i)}
+					if sel {
+						select {
+						case ci <- v:
+						case <-other:
+						}
+					} else {
+						ci <- v
+					}
+				}, func() string {
+					if sel {
+						select {
+						case v := <-ci:
+							return v.(*box).s
+						case <-other:
+						}
+					}
+					return (<-ci).(*box).s
+				}},
+			}
+			for _, tt := range tests {
+				tt := tt
+				kind := fmt.Sprintf("%s (cap %d, select %v)", tt.kind, size, sel)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					errs <- run(kind, tt.send, tt.recv)
+				}()
+			}
+		}
+	}
+	wg.Wait()
+	close(stop)
+	<-churnDone
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// TestChanCloseAfterBufferedSends checks that a receiver never sees a
+// channel closed before it has received every value sent before the
+// close, whether it polls with a select with a default case, which
+// does not lock the channel to find it closed, or blocks in a select
+// with several cases.
+func TestChanCloseAfterBufferedSends(t *testing.T) {
+	n := 20000
+	if testing.Short() {
+		n = 2000
+	}
+	const nvals = 3
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	other := make(chan int)
+	for _, mode := range []string{"poll", "select"} {
+		for i := 0; i < n; i++ {
+			c := make(chan int, nvals)
+			go func() {
+				for v := 1; v <= nvals; v++ {
+					c <- v
+				}
+				close(c)
+			}()
+			for want := 1; ; {
+				var v int
+				var ok bool
+				if mode == "poll" {
+					select {
+					case v, ok = <-c:
+					default:
+						continue
+					}
+				} else {
+					select {
+					case v, ok = <-c:
+					case <-other:
+					}
+				}
+				if !ok {
+					if want <= nvals {
+						t.Fatalf("%s: channel closed before value %d was received", mode, want)
+					}
+					break
+				}
+				if v != want {
+					t.Fatalf("%s: received %d, want %d", mode, v, want)
+				}
+				want++
+			}
+		}
+	}
+}
+
+// TestChanTryRecvAfterClose checks that a receive that does not lock
+// the channel, a select with a default case or a plain receive, never
+// reports a channel closed while a value sent before the close is still
+// buffered, when the values are sent, the channel closed and the
+// values received by three different goroutines. The close is ordered
+// after the sends only through the closer's synchronization with the
+// sender, which the receiver does not take part in.
+func TestChanTryRecvAfterClose(t *testing.T) {
+	n := 10000
+	if testing.Short() {
+		n = 1000
+	}
+	const nvals = 4
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	for _, order := range []string{"chan", "atomic"} {
+		for _, recv := range []string{"poll", "block"} {
+			for i := 0; i < n; i++ {
+				c := make(chan int, nvals)
+				sent := make(chan bool)
+				var flag uint32
+				go func() {
+					for v := 1; v <= nvals; v++ {
+						c <- v
+					}
+					if order == "chan" {
+						sent <- true
+					} else {
+						atomic.StoreUint32(&flag, 1)
+					}
+				}()
+				go func() {
+					if order == "chan" {
+						<-sent
+					} else {
+						for atomic.LoadUint32(&flag) == 0 {
+							runtime.Gosched()
+						}
+					}
+					close(c)
+				}()
+				for want := 1; ; {
+					var v int
+					var ok bool
+					if recv == "poll" {
+						select {
+						case v, ok = <-c:
+						default:
+							continue
+						}
+					} else {
+						v, ok = <-c
+					}
+					if !ok {
+						if want <= nvals {
+							t.Fatalf("%s, %s: channel closed before value %d was received", order, recv, want)
+						}
+						break
+					}
+					if v != want {
+						t.Fatalf("%s, %s: received %d, want %d", order, recv, v, want)
+					}
+					want++
+				}
+			}
+		}
+	}
+}
+
+func TestChanWakeGlobal(t *testing.T) {
+	defer runtime.SetChanWakeGlobal(runtime.SetChanWakeGlobal(10))
+	c := make(chan int)
+	if got := runtime.ChanWakeGlobal(c, 25); got != 15 {
+		t.Errorf("%d of 25 wakeups routed to the global run queue with threshold 10, want 15", got)
+	}
+	time.Sleep(2 * time.Millisecond)
+	if got := runtime.ChanWakeGlobal(c, 10); got != 0 {
+		t.Errorf("%d of 10 wakeups in a new window routed to the global run queue, want 0", got)
+	}
+}
+
+// TestChanWakeGlobalFanIn checks that values sent by many producers to
+// one consumer are all delivered when the consumer's wakeups go to the
+// global run queue.
+func TestChanWakeGlobalFanIn(t *testing.T) {
+	const producers, n = 64, 200
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	defer runtime.SetChanWakeGlobal(runtime.SetChanWakeGlobal(1))
+	for _, size := range []int{0, 4} {
+		c := make(chan int, size)
+		for p := 0; p < producers; p++ {
+			go func() {
+				for i := 1; i <= n; i++ {
+					c <- i
+				}
+			}()
+		}
+		sum := 0
+		for i := 0; i < producers*n; i++ {
+			sum += <-c
+		}
+		if want := producers * n * (n + 1) / 2; sum != want {
+			t.Errorf("cap %d: received values summing to %d, want %d", size, sum, want)
+		}
+	}
+}
+
+// TestChanDirectSwitch checks that goroutines exchanging values over
+// unbuffered channels with direct switches enabled still let timers,
+// the garbage collector and other goroutines on their P run.
+func TestChanDirectSwitch(t *testing.T) {
+	defer runtime.SetChanDirectSwitch(runtime.SetChanDirectSwitch(true))
+	for _, procs := range []int{1, 4} {
+		for _, mode := range []string{"park", "gosched", "locked"} {
+			t.Run(fmt.Sprintf("%s/procs=%d", mode, procs), func(t *testing.T) {
+				defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+				ping, pong := make(chan int), make(chan int)
+				defer close(ping)
+				go func() {
+					if mode == "locked" {
+						runtime.LockOSThread()
+						defer runtime.UnlockOSThread()
+					}
+					for v := range ping {
+						pong <- v + 1
+						if mode == "gosched" {
+							runtime.Gosched()
+						}
+					}
+				}()
+				exchange := func(v int) {
+					ping <- v
+					if w := <-pong; w != v+1 {
+						t.Fatalf("received %d after sending %d, want %d", w, v, v+1)
+					}
+				}
+				if mode == "locked" {
+					// Goroutines locked to their thread are never
+					// switched to directly, so there is nothing more
+					// to check than that the exchanges go through.
+					for v := 0; v < 10000; v++ {
+						exchange(v)
+					}
+					return
+				}
+
+				var other uint32
+				stop := make(chan bool)
+				defer close(stop)
+				go func() {
+					for {
+						select {
+						case <-stop:
+							return
+						default:
+						}
+						atomic.AddUint32(&other, 1)
+						runtime.Gosched()
+					}
+				}()
+				fired := make(chan bool)
+				time.AfterFunc(time.Millisecond, func() { close(fired) })
+				gcDone := make(chan bool)
+				go func() {
+					runtime.GC()
+					close(gcDone)
+				}()
+
+				deadline := time.Now().Add(10 * time.Second)
+				for v := 0; fired != nil || gcDone != nil || atomic.LoadUint32(&other) == 0; v++ {
+					exchange(v)
+					select {
+					case <-fired:
+						fired = nil
+					case <-gcDone:
+						gcDone = nil
+					default:
+					}
+					if time.Now().After(deadline) {
+						t.Fatalf("after %d exchanges: timer fired %v, GC done %v, other goroutine ran %d times",
+							v, fired == nil, gcDone == nil, atomic.LoadUint32(&other))
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestChanNBFail(t *testing.T) {
+	c := make(chan int, 1)
+	runtime.SetChanNBFail(c, 100)
+	cv := reflect.ValueOf(c)
+	select {
+	case c <- 1:
+		t.Error("non-blocking send succeeded")
+	default:
+	}
+	if cv.TrySend(reflect.ValueOf(1)) || cv.TrySendFront(reflect.ValueOf(1)) {
+		t.Error("TrySend succeeded")
+	}
+	c <- 1
+	select {
+	case <-c:
+		t.Error("non-blocking receive succeeded")
+	default:
+	}
+	if _, ok := cv.TryRecv(); ok {
+		t.Error("TryRecv succeeded")
+	}
+	if v := <-c; v != 1 {
+		t.Errorf("blocking receive got %d, want 1", v)
+	}
+
+	// Operations on closed channels are not affected.
+	close(c)
+	select {
+	case _, ok := <-c:
+		if ok {
+			t.Error("received a value from a closed, empty channel")
+		}
+	default:
+		t.Error("non-blocking receive from a closed channel failed")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("non-blocking send on a closed channel did not panic")
+			}
+		}()
+		select {
+		case c <- 1:
+		default:
+		}
+	}()
+
+	// Other channels are not affected.
+	other := make(chan int, 1)
+	select {
+	case other <- 1:
+	default:
+		t.Error("non-blocking send on an unmarked channel failed")
+	}
+	select {
+	case <-other:
+	default:
+		t.Error("non-blocking receive on an unmarked channel failed")
+	}
+
+	const n = 1000
+	d := make(chan int, 1)
+	runtime.SetChanNBFail(d, 50)
+	fails := 0
+	for i := 0; i < n; i++ {
+		select {
+		case d <- i:
+			<-d
+		default:
+			fails++
+		}
+	}
+	if fails < n/4 || fails > 3*n/4 {
+		t.Errorf("%d of %d non-blocking sends failed, want about half", fails, n)
+	}
+}
+
+// TestChanRecvClear checks that receives leaving their slots to be
+// cleared in batches never clear a slot holding a value, however
+// sends and receives interleave around the buffer, with or without the
+// garbage collector running.
+func TestChanRecvClear(t *testing.T) {
+	const size, n = 150, 20000
+	c := make(chan *int, size)
+	stop := make(chan bool)
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				runtime.GC()
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+	sent, recvd := 0, 0
+	for sent < n {
+		// Runs long enough to cross chanDirtyBatch and wrap
+		// around the buffer.
+		for k := rand.Intn(size); k > 0 && len(c) < size && sent < n; k-- {
+			v := sent
+			c <- &v
+			sent++
+		}
+		for k := rand.Intn(size); k > 0 && len(c) > 0; k-- {
+			var p *int
+			select {
+			case p = <-c:
+			case <-stop:
+			}
+			if p == nil || *p != recvd {
+				t.Fatalf("received %v, want %d", p, recvd)
+			}
+			recvd++
+		}
+		if d := runtime.ChanDirty(c); d >= 64 || len(c) == 0 && d != 0 {
+			t.Fatalf("%d slots left to clear with %d values buffered", d, len(c))
+		}
+	}
+}
+
+// TestChanRecvClearFrees checks that the values received from a buffer
+// can be collected once the buffer is drained, and all but a batch of
+// them while it is not.
+func TestChanRecvClearFrees(t *testing.T) {
+	const size = 200
+	c := make(chan *[16]byte, size)
+	var freed uint32
+	for i := 0; i < size; i++ {
+		p := new([16]byte)
+		runtime.SetFinalizer(p, func(*[16]byte) { atomic.AddUint32(&freed, 1) })
+		c <- p
+	}
+	waitFreed := func(want uint32) {
+		t.Helper()
+		for start := time.Now(); atomic.LoadUint32(&freed) < want; {
+			if time.Since(start) > 10*time.Second {
+				t.Fatalf("%d received values freed, want at least %d", atomic.LoadUint32(&freed), want)
+			}
+			runtime.GC()
+			time.Sleep(time.Millisecond)
+		}
+	}
+	for i := 0; i < size/2; i++ {
+		<-c
+	}
+	waitFreed(size/2 - 63)
+	for len(c) > 0 {
+		<-c
+	}
+	waitFreed(size)
+}
+
+// TestChanCloseWakeOrder checks that closing a channel runs the
+// goroutines blocked on it in the order they blocked, on a single P.
+func TestChanCloseWakeOrder(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+	const n = 16
+	for _, send := range []bool{false, true} {
+		c := make(chan int, 1)
+		if send {
+			c <- 0
+		}
+		other := make(chan int)
+		var (
+			mu    sync.Mutex
+			order []int
+			wg    sync.WaitGroup
+		)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer func() {
+					if send && recover() == nil {
+						t.Errorf("send %d on closed channel did not panic", i)
+					}
+					mu.Lock()
+					order = append(order, i)
+					mu.Unlock()
+					wg.Done()
+				}()
+				switch {
+				case send && i%2 == 0:
+					c <- i
+				case send:
+					select {
+					case c <- i:
+					case <-other:
+					}
+				case i%2 == 0:
+					<-c
+				default:
+					select {
+					case <-c:
+					case <-other:
+					}
+				}
+			}(i)
+			for runtime.ChanWaiters(c) != i+1 {
+				runtime.Gosched()
+			}
+		}
+		close(c)
+		wg.Wait()
+		for i, g := range order {
+			if g != i {
+				t.Errorf("send=%v: goroutines ran in order %v after close, want the order they blocked in", send, order)
+				break
+			}
+		}
+	}
+}
+
+// TestChanClosedBy checks that the ID of the goroutine that closes a
+// channel is recorded before any goroutine blocked on the channel is
+// woken by the close, or a goroutine polling it sees it closed.
+func TestChanClosedBy(t *testing.T) {
+	const waiters = 8
+	c, other := make(chan int), make(chan int)
+	got := make(chan int64, 2*waiters+1)
+	go func() {
+		for {
+			select {
+			case <-c:
+				got <- runtime.ChanClosedBy(c)
+				return
+			default:
+				runtime.Gosched()
+			}
+		}
+	}()
+	for i := 0; i < waiters; i++ {
+		go func() {
+			<-c
+			got <- runtime.ChanClosedBy(c)
+		}()
+		go func() {
+			select {
+			case <-c:
+			case <-other:
+			}
+			got <- runtime.ChanClosedBy(c)
+		}()
+	}
+	for runtime.ChanWaiters(c) < 2*waiters {
+		runtime.Gosched()
+	}
+	closer := make(chan int64)
+	go func() {
+		id := runtime.Goid()
+		close(c)
+		closer <- id
+	}()
+	want := <-closer
+	for i := 0; i < 2*waiters+1; i++ {
+		if id := <-got; id != want {
+			t.Fatalf("receiver saw closedBy %d, want %d", id, want)
+		}
+	}
+}
+
+// closeIntChan and closeStringChan close c and return the ID of the
+// goroutine that closed it.
+
+//go:noinline
+func closeIntChan(c chan int) int64 {
+	close(c)
+	return runtime.Goid()
+}
+
+//go:noinline
+func closeStringChan(c chan string) int64 {
+	close(c)
+	return runtime.Goid()
+}
+
+func TestChanSendOnClosedError(t *testing.T) {
+	// check runs send, which must panic for a send on a channel of
+	// elem closed by the goroutine closer.
+	check := func(name string, send func(), elem string, closer int64) {
+		t.Helper()
+		defer func() {
+			t.Helper()
+			e, ok := recover().(*runtime.ClosedChannelError)
+			if !ok {
+				t.Fatalf("%s: panic value is not a *runtime.ClosedChannelError", name)
+			}
+			if e.Error() != "send on closed channel" {
+				t.Errorf("%s: Error() = %q", name, e.Error())
+			}
+			if e.ElemType() != elem {
+				t.Errorf("%s: ElemType() = %q, want %q", name, e.ElemType(), elem)
+			}
+			if e.ClosedBy() != closer {
+				t.Errorf("%s: ClosedBy() = %d, want %d", name, e.ClosedBy(), closer)
+			}
+			// Where and when the channel was closed is only
+			// recorded with GODEBUG=chanclosecheck=1; see
+			// TestSendOnClosedChannel.
+			if f := e.CloseFrame(); f.Function != "" {
+				t.Errorf("%s: CloseFrame().Function = %q, want none", name, f.Function)
+			}
+			if ct := e.CloseTime(); ct != 0 {
+				t.Errorf("%s: CloseTime() = %d, want 0", name, ct)
+			}
+		}()
+		send()
+		t.Fatalf("%s: send on closed channel did not panic", name)
+	}
+
+	// A send on a channel closed before it starts.
+	c := make(chan int, 1)
+	closer := make(chan int64)
+	go func() { closer <- closeIntChan(c) }()
+	check("closed", func() { c <- 1 }, "int", <-closer)
+
+	// A send woken by the close, and a select likewise.
+	for _, sel := range []bool{false, true} {
+		s := make(chan string)
+		errs := make(chan func())
+		go func() {
+			defer func() {
+				e := recover()
+				errs <- func() { panic(e) }
+			}()
+			if sel {
+				select {
+				case s <- "x":
+				case <-make(chan int):
+				}
+			} else {
+				s <- "x"
+			}
+		}()
+		for runtime.ChanWaiters(s) == 0 {
+			runtime.Gosched()
+		}
+		id := closeStringChan(s)
+		check(fmt.Sprintf("woken, select %v", sel), <-errs, "string", id)
+	}
+}
+
+func TestChanCancel(t *testing.T) {
+	// start runs op in a new goroutine with a cancel handle of that
+	// goroutine, and returns the handle and a channel that is closed
+	// when op returns.
+	start := func(op func(h runtime.ChanCancel)) (runtime.ChanCancel, chan bool) {
+		hc := make(chan runtime.ChanCancel)
+		done := make(chan bool)
+		go func() {
+			h := runtime.NewChanCancel()
+			hc <- h
+			op(h)
+			close(done)
+		}()
+		return <-hc, done
+	}
+
+	t.Run("Recv", func(t *testing.T) {
+		c := make(chan int)
+		h, done := start(func(h runtime.ChanCancel) {
+			if _, received, cancelled := h.Recv(c); received || !cancelled {
+				t.Errorf("Recv = received %v, cancelled %v; want false, true", received, cancelled)
+			}
+		})
+		for runtime.ChanWaiters(c) < 1 {
+			runtime.Gosched()
+		}
+		h.Cancel()
+		<-done
+		if n := runtime.ChanWaiters(c); n != 0 {
+			t.Errorf("%d waiters left on channel", n)
+		}
+	})
+
+	t.Run("Send", func(t *testing.T) {
+		c := make(chan int, 1)
+		c <- 0
+		h, done := start(func(h runtime.ChanCancel) {
+			if !h.Send(c, 1) {
+				t.Errorf("Send was not cancelled")
+			}
+		})
+		for runtime.ChanWaiters(c) < 1 {
+			runtime.Gosched()
+		}
+		h.Cancel()
+		<-done
+		if n := runtime.ChanWaiters(c); n != 0 {
+			t.Errorf("%d waiters left on channel", n)
+		}
+		if v := <-c; v != 0 || len(c) != 0 {
+			t.Errorf("channel holds %d and %d more; want only 0", v, len(c))
+		}
+	})
+
+	t.Run("Select", func(t *testing.T) {
+		cs := []chan int{make(chan int), make(chan int), make(chan int)}
+		h, done := start(func(h runtime.ChanCancel) {
+			if i, _, cancelled := h.SelectRecv(cs); i != -1 || !cancelled {
+				t.Errorf("SelectRecv = %d, cancelled %v; want -1, true", i, cancelled)
+			}
+		})
+		for runtime.ChanWaiters(cs[2]) < 1 {
+			runtime.Gosched()
+		}
+		h.Cancel()
+		<-done
+		for i, c := range cs {
+			if n := runtime.ChanWaiters(c); n != 0 {
+				t.Errorf("%d waiters left on channel %d", n, i)
+			}
+		}
+	})
+
+	t.Run("Before", func(t *testing.T) {
+		c := make(chan int, 1)
+		h := runtime.NewChanCancel()
+		h.Cancel()
+		if _, received, cancelled := h.Recv(c); received || !cancelled {
+			t.Errorf("Recv = received %v, cancelled %v; want false, true", received, cancelled)
+		}
+		// Operations that need not block are not cancelled.
+		if h.Send(c, 1) {
+			t.Errorf("Send on channel with room was cancelled")
+		}
+		if !h.Send(c, 2) {
+			t.Errorf("Send on full channel was not cancelled")
+		}
+		if v, received, cancelled := h.Recv(c); v != 1 || !received || cancelled {
+			t.Errorf("Recv = %d, received %v, cancelled %v; want 1, true, false", v, received, cancelled)
+		}
+		if i, _, cancelled := h.SelectRecv([]chan int{c}); i != -1 || !cancelled {
+			t.Errorf("SelectRecv = %d, cancelled %v; want -1, true", i, cancelled)
+		}
+		// Other channel operations of the goroutine are not affected.
+		c <- 3
+		if v := <-c; v != 3 {
+			t.Errorf("received %d, want 3", v)
+		}
+	})
+}
+
+// TestChanCancelRace checks that an operation that races with its
+// cancellation either completes or is cancelled, but never both.
+func TestChanCancelRace(t *testing.T) {
+	n := 2000
+	if testing.Short() {
+		n = 200
+	}
+	for i := 0; i < n; i++ {
+		c := make(chan int)
+		hc := make(chan runtime.ChanCancel, 2)
+		got, sent := make(chan int, 1), make(chan bool, 1)
+		go func() {
+			h := runtime.NewChanCancel()
+			hc <- h
+			if i%2 == 0 {
+				v, received, cancelled := h.Recv(c)
+				if received == cancelled {
+					t.Errorf("Recv = received %v, cancelled %v", received, cancelled)
+				}
+				if !received {
+					v = -1
+				}
+				got <- v
+				return
+			}
+			j, v, _ := h.SelectRecv([]chan int{make(chan int), c})
+			switch j {
+			case -1:
+				v = -1
+			case 0:
+				t.Errorf("SelectRecv received from a channel nobody sends on")
+			}
+			got <- v
+		}()
+		go func() {
+			h := runtime.NewChanCancel()
+			hc <- h
+			sent <- !h.Send(c, i)
+		}()
+		h1, h2 := <-hc, <-hc
+		if i%3 != 0 {
+			runtime.Gosched()
+		}
+		h1.Cancel()
+		h2.Cancel()
+		v, ok := <-got, <-sent
+		if ok != (v == i) || (!ok && v != -1) {
+			t.Fatalf("iteration %d: send completed %v, but receiver got %d", i, ok, v)
+		}
+	}
+}
+
+// TestChanBlockProfileDuration checks that the block profile records
+// how long a channel receive blocked.
+// chanOpsSamples returns the number of samples of each kind of channel
+// operation in the chanops profile whose stacks include function fn.
+func chanOpsSamples(fn string) map[uint8]int64 {
+	m := make(map[uint8]int64)
+	p, ops, counts := runtime.ChanOpsProfile()
+	for i := range p {
+		frames := runtime.CallersFrames(p[i].Stack())
+		for {
+			f, more := frames.Next()
+			if f.Function == fn {
+				m[ops[i]] += counts[i]
+				break
+			}
+			if !more {
+				break
+			}
+		}
+	}
+	return m
+}
+
+// chanProfOps performs n sends, n receives and 2n selects, none of
+// which blocks.
+//
+//go:noinline
+func chanProfOps(n int) {
+	c, other := make(chan int, 1), make(chan int)
+	for i := 0; i < n; i++ {
+		c <- i
+		<-c
+		select {
+		case c <- i:
+		case <-other:
+		}
+		select {
+		case <-c:
+		case <-other:
+		}
+	}
+}
+
+func TestChanProfRate(t *testing.T) {
+	defer runtime.SetChanProfRate(runtime.SetChanProfRate(0))
+	const n = 20000
+	// The rates are prime, so that sampling every rate-th operation
+	// doesn't keep hitting the same one of the four operations that
+	// chanProfOps repeats.
+	for _, rate := range []int{1, 7, 97} {
+		t.Run(fmt.Sprint(rate), func(t *testing.T) {
+			before := chanOpsSamples("runtime_test.chanProfOps")
+			runtime.SetChanProfRate(rate)
+			chanProfOps(n)
+			runtime.SetChanProfRate(0)
+			after := chanOpsSamples("runtime_test.chanProfOps")
+
+			for _, tc := range []struct {
+				name string
+				op   uint8
+				want int64
+			}{
+				{"send", runtime.ChanProfSend, n},
+				{"recv", runtime.ChanProfRecv, n},
+				{"select", runtime.ChanProfSelect, 2 * n},
+			} {
+				got := (after[tc.op] - before[tc.op]) * int64(rate)
+				// Each P's count of operations until its next
+				// sample starts anywhere in the period, and other
+				// goroutines' operations count too.
+				slack := tc.want/10 + int64(rate*(runtime.GOMAXPROCS(0)+1))
+				if got < tc.want-slack || got > tc.want+slack {
+					t.Errorf("%s: sampled %d operations, want %d±%d", tc.name, got, tc.want, slack)
+				}
+			}
+			for _, op := range []uint8{runtime.ChanProfSend, runtime.ChanProfRecv, runtime.ChanProfSelect} {
+				if after[op|runtime.ChanProfBlocked] != before[op|runtime.ChanProfBlocked] {
+					t.Errorf("operations of kind %d sampled as blocked", op)
+				}
+			}
+		})
+	}
+
+	t.Run("blocked", func(t *testing.T) {
+		runtime.SetChanProfRate(1)
+		c := make(chan int)
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			c <- 1
+		}()
+		blockedRecv(c)
+		runtime.SetChanProfRate(0)
+		if m := chanOpsSamples("runtime_test.blockedRecv"); m[runtime.ChanProfRecv|runtime.ChanProfBlocked] == 0 {
+			t.Errorf("blocked receive not sampled as blocked: %v", m)
+		}
+	})
+}
+
+func TestChanBlockProfileDuration(t *testing.T) {
+	debug.SetChanBlockProfileRate(1)
+	defer debug.SetChanBlockProfileRate(0)
+
+	const d = 50 * time.Millisecond
+	c := make(chan int)
+	go func() {
+		time.Sleep(d)
+		c <- 1
+	}()
+	start := time.Now()
+	blockedRecv(c)
+	elapsed := time.Since(start)
+
+	p := make([]runtime.BlockProfileRecord, 1000)
+	n, ok := runtime.BlockProfile(p)
+	if !ok {
+		t.Fatalf("BlockProfile needs %d records", n)
+	}
+	for _, r := range p[:n] {
+		frames := runtime.CallersFrames(r.Stack())
+		for {
+			f, more := frames.Next()
+			if f.Function == "runtime_test.blockedRecv" {
+				// The bucket accumulates over repeated runs.
+				got := time.Duration(float64(r.Cycles) / float64(r.Count) / float64(runtime.TicksPerSecond()) * 1e9)
+				if got < d/2 || got > 2*elapsed {
+					t.Errorf("block profile has receive blocked for %v, want about %v", got, elapsed)
+				}
+				return
+			}
+			if !more {
+				break
+			}
+		}
+	}
+	t.Fatalf("receive not found in block profile")
+}
+
+//go:noinline
+func blockedRecv(c chan int) {
+	<-c
+}
+
+func TestChanLabelGC(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		c    interface{}
+	}{
+		// The hchan of a channel whose elements have no pointers
+		// is allocated without pointers.
+		{"noscan", make(chan int, 1)},
+		{"scan", make(chan *int, 1)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// Label the channel with a string whose bytes are
+			// only reachable through the label.
+			var freed uint32
+			b := new([16]byte)
+			copy(b[:], tc.name+" label")
+			runtime.SetFinalizer(b, func(*[16]byte) { atomic.StoreUint32(&freed, 1) })
+			var label string
+			h := (*reflect.StringHeader)(unsafe.Pointer(&label))
+			h.Data, h.Len = uintptr(unsafe.Pointer(b)), len(tc.name+" label")
+			reflect.ValueOf(tc.c).SetChanLabel(label)
+			runtime.KeepAlive(b)
+			b, label = nil, ""
+
+			for i := 0; i < 5; i++ {
+				runtime.GC()
+			}
+			time.Sleep(10 * time.Millisecond)
+			if atomic.LoadUint32(&freed) != 0 {
+				t.Fatalf("label freed while the channel is alive")
+			}
+			if got, want := runtime.ChanLabel(tc.c), tc.name+" label"; got != want {
+				t.Fatalf("label is %q after GC, want %q", got, want)
+			}
+
+			// Once the label is removed, its bytes are freed.
+			reflect.ValueOf(tc.c).SetChanLabel("")
+			for i := 0; i < 100 && atomic.LoadUint32(&freed) == 0; i++ {
+				runtime.GC()
+				time.Sleep(time.Millisecond)
+			}
+			if atomic.LoadUint32(&freed) == 0 {
+				t.Errorf("label not freed after it was removed")
+			}
+			runtime.KeepAlive(tc.c)
+		})
+	}
+}
@@ -0,0 +1,304 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Broadcast channels.
+//
+// A broadcast channel, made with reflect.MakeBroadcast, delivers every
+// value sent on it to every receiver subscribed to it when the value
+// is sent. The values are kept in a ring of fixed size, which sends
+// fill at a single write position, b.sent, and each subscriber, a
+// bcastCursor, reads at a position of its own. When a subscriber falls
+// a whole ring behind, the policy chosen when the channel was made
+// decides: a blocking channel makes the next sender wait for the
+// slowest subscriber, and a dropping channel overwrites the oldest
+// value, which the slow subscriber then misses and counts as dropped.
+//
+// A broadcast channel is not a Go channel, and cannot be used with the
+// channel operators or in a select. Its goroutines wait on waitqs of
+// sudogs, as those of channel operations do, but there is no handoff
+// of values between goroutines: every value goes through the ring, a
+// send wakes all the waiting receivers, since each of them needs the
+// value, and a receive wakes all the waiting senders if it may have
+// been the slowest. Woken goroutines retry their operation.
+//
+// Finding the slowest subscriber takes a walk over all of them, so
+// b.tail caches a lower bound on their positions, and a blocking send
+// walks them only when the ring looks full from tail. A sender parks
+// only after such a walk, which leaves tail exact, so the receive that
+// moves the slowest subscriber on finds its position equal to tail.
+
+import "unsafe"
+
+// A bcast is a broadcast channel.
+type bcast struct {
+	lock     mutex
+	elemtype *_type
+	buf      unsafe.Pointer // ring of size elements
+	size     uint64
+	drop     bool // overwrite values that a subscriber has not received
+	closed   bool
+
+	sent    uint64       // number of values sent; the next goes in slot sent%size
+	tail    uint64       // lower bound on the positions of the subscribers
+	cursors *bcastCursor // subscribers, linked through next
+
+	sendq waitq // senders waiting for the slowest subscriber
+	recvq waitq // subscribers waiting for a value
+}
+
+// A bcastCursor is a subscriber to a broadcast channel.
+type bcastCursor struct {
+	b    *bcast
+	next *bcastCursor
+
+	// These are protected by b.lock.
+	pos          uint64 // position of the next value to receive
+	dropped      uint64 // values overwritten before they were received
+	unsubscribed bool
+}
+
+// newBcast returns a broadcast channel of values of type t, keeping
+// the last size values sent.
+func newBcast(t *_type, size int, drop bool) *bcast {
+	if size <= 0 {
+		panic(plainError("makebroadcast: size out of range"))
+	}
+	b := &bcast{
+		elemtype: t,
+		buf:      newarray(t, size),
+		size:     uint64(size),
+		drop:     drop,
+	}
+	lockInit(&b.lock, lockRankBcast)
+	return b
+}
+
+// slot returns a pointer to the slot of the value at position i.
+func (b *bcast) slot(i uint64) unsafe.Pointer {
+	return add(b.buf, uintptr(i%b.size)*b.elemtype.size)
+}
+
+// full reports whether a send must wait for the slowest subscriber.
+// b.lock must be held.
+func (b *bcast) full() bool {
+	if b.drop || b.sent-b.tail < b.size {
+		return false
+	}
+	b.tail = b.sent
+	for r := b.cursors; r != nil; r = r.next {
+		if r.pos < b.tail {
+			b.tail = r.pos
+		}
+	}
+	return b.sent-b.tail >= b.size
+}
+
+// send sends the value at ep on b, waiting for a slow subscriber if
+// block is set, and reports whether it did.
+func (b *bcast) send(ep unsafe.Pointer, block bool) bool {
+	if raceenabled {
+		raceReadObjectPC(b.elemtype, ep, getcallerpc(), funcPC(reflect_bcastsend))
+	}
+	if msanenabled {
+		msanread(ep, b.elemtype.size)
+	}
+	lock(&b.lock)
+	for {
+		if b.closed {
+			unlock(&b.lock)
+			panic(plainError("send on closed broadcast channel"))
+		}
+		if !b.full() {
+			break
+		}
+		if !block {
+			unlock(&b.lock)
+			return false
+		}
+		b.park(&b.sendq, waitReasonBcastSend)
+	}
+	if raceenabled {
+		racereleasemerge(unsafe.Pointer(b))
+	}
+	typedmemmove(b.elemtype, b.slot(b.sent), ep)
+	b.sent++
+	var wake gList
+	bcastWakeAll(&b.recvq, &wake)
+	unlock(&b.lock)
+	bcastReady(&wake)
+	return true
+}
+
+// close closes b, waking all its waiting goroutines.
+func (b *bcast) close() {
+	lock(&b.lock)
+	if b.closed {
+		unlock(&b.lock)
+		panic(plainError("close of closed broadcast channel"))
+	}
+	if raceenabled {
+		racereleasemerge(unsafe.Pointer(b))
+	}
+	b.closed = true
+	var wake gList
+	bcastWakeAll(&b.recvq, &wake)
+	bcastWakeAll(&b.sendq, &wake)
+	unlock(&b.lock)
+	bcastReady(&wake)
+}
+
+// subscribe returns a new subscriber to b, which receives the values
+// sent from now on.
+func (b *bcast) subscribe() *bcastCursor {
+	r := &bcastCursor{b: b}
+	lock(&b.lock)
+	r.pos = b.sent
+	r.next = b.cursors
+	b.cursors = r
+	unlock(&b.lock)
+	return r
+}
+
+// recv receives the next value of r into ep, like chanrecv.
+func (r *bcastCursor) recv(ep unsafe.Pointer, block bool) (selected, received bool) {
+	b := r.b
+	lock(&b.lock)
+	for {
+		if b.drop && b.sent-r.pos > b.size {
+			r.dropped += b.sent - b.size - r.pos
+			r.pos = b.sent - b.size
+		}
+		if r.pos < b.sent && !r.unsubscribed {
+			break
+		}
+		if b.closed || r.unsubscribed {
+			if raceenabled {
+				raceacquire(unsafe.Pointer(b))
+			}
+			unlock(&b.lock)
+			if ep != nil {
+				typedmemclr(b.elemtype, ep)
+			}
+			return true, false
+		}
+		if !block {
+			unlock(&b.lock)
+			return false, false
+		}
+		b.park(&b.recvq, waitReasonBcastReceive)
+	}
+	if raceenabled {
+		raceacquire(unsafe.Pointer(b))
+	}
+	if ep != nil {
+		typedmemmove(b.elemtype, ep, b.slot(r.pos))
+	}
+	slowest := r.pos == b.tail
+	r.pos++
+	var wake gList
+	if slowest && !b.drop {
+		bcastWakeAll(&b.sendq, &wake)
+	}
+	unlock(&b.lock)
+	bcastReady(&wake)
+	return true, true
+}
+
+// unsubscribe removes r from its channel. Later receives with r, and
+// those waiting, report the channel closed.
+func (r *bcastCursor) unsubscribe() {
+	b := r.b
+	lock(&b.lock)
+	if r.unsubscribed {
+		unlock(&b.lock)
+		return
+	}
+	r.unsubscribed = true
+	for p := &b.cursors; *p != nil; p = &(*p).next {
+		if *p == r {
+			*p = r.next
+			break
+		}
+	}
+	r.next = nil
+	var wake gList
+	bcastWakeAll(&b.recvq, &wake)
+	bcastWakeAll(&b.sendq, &wake)
+	unlock(&b.lock)
+	bcastReady(&wake)
+}
+
+// park queues the current goroutine on q, which is b.sendq or
+// b.recvq, and parks it until bcastWakeAll wakes it. b.lock must be
+// held; it is released while parked and held again on return.
+func (b *bcast) park(q *waitq, reason waitReason) {
+	sg := acquireSudog()
+	sg.g = getg()
+	sg.releasetime = 0
+	q.enqueue(sg)
+	goparkunlock(&b.lock, reason, traceEvGoBlock, 3)
+	releaseSudog(sg)
+	lock(&b.lock)
+}
+
+// bcastWakeAll empties q, adding its goroutines to wake, to be readied
+// once b.lock is released.
+func bcastWakeAll(q *waitq, wake *gList) {
+	for sg := q.first; sg != nil; {
+		next := sg.next
+		sg.next, sg.prev = nil, nil
+		sg.queued = false
+		wake.push(sg.g)
+		sg = next
+	}
+	q.first, q.last = nil, nil
+}
+
+// bcastReady readies the goroutines on wake.
+func bcastReady(wake *gList) {
+	for !wake.empty() {
+		goready(wake.pop(), 3)
+	}
+}
+
+//go:linkname reflect_bcastmake reflect.bcastmake
+func reflect_bcastmake(t *_type, size int, drop bool) unsafe.Pointer {
+	return unsafe.Pointer(newBcast(t, size, drop))
+}
+
+//go:linkname reflect_bcastsend reflect.bcastsend
+func reflect_bcastsend(b unsafe.Pointer, ep unsafe.Pointer, nb bool) bool {
+	return (*bcast)(b).send(ep, !nb)
+}
+
+//go:linkname reflect_bcastclose reflect.bcastclose
+func reflect_bcastclose(b unsafe.Pointer) {
+	(*bcast)(b).close()
+}
+
+//go:linkname reflect_bcastsubscribe reflect.bcastsubscribe
+func reflect_bcastsubscribe(b unsafe.Pointer) unsafe.Pointer {
+	return unsafe.Pointer((*bcast)(b).subscribe())
+}
+
+//go:linkname reflect_bcastrecv reflect.bcastrecv
+func reflect_bcastrecv(r unsafe.Pointer, nb bool, ep unsafe.Pointer) (selected, received bool) {
+	return (*bcastCursor)(r).recv(ep, !nb)
+}
+
+//go:linkname reflect_bcastdropped reflect.bcastdropped
+func reflect_bcastdropped(r unsafe.Pointer) uint64 {
+	c := (*bcastCursor)(r)
+	lock(&c.b.lock)
+	n := c.dropped
+	unlock(&c.b.lock)
+	return n
+}
+
+//go:linkname reflect_bcastunsubscribe reflect.bcastunsubscribe
+func reflect_bcastunsubscribe(r unsafe.Pointer) {
+	(*bcastCursor)(r).unsubscribe()
+}
@@ -0,0 +1,207 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Warnings about goroutines blocked on channels for a long time.
+//
+// With GODEBUG=chanblockwarn=N, sysmon looks once a second for
+// goroutines that have been parked in a channel operation for N
+// seconds or more. For each one it prints the channels the goroutine
+// is blocked on, which goroutine last completed the opposite operation
+// on each of them and where, and the goroutine's stack. If the channel
+// registry is enabled (GODEBUG=chanregistry=1), where each channel was
+// created is printed too.
+//
+// To keep a stuck program from flooding stderr, each wait is reported
+// at most once, and at most one goroutine is reported for a channel
+// every N seconds. Goroutines blocked only on channels reported more
+// recently than that are reported later, once per N seconds in turn.
+//
+// While the setting is on, a goroutine parking on a channel records the
+// time in gp.waitsince (see chanStatsPark), and the goroutines that
+// complete sends and receives record themselves in the lastSend and
+// lastRecv of the channel's side state (see chanside.go). These
+// are only diagnostics, so they are written without synchronization,
+// and a report may pair the goroutine ID of one operation with the PC
+// of another. The few channels created by the runtime before the
+// setting was read have no side state for it: their last operations are
+// not known, and they are reported with every goroutine blocked on
+// them.
+
+// chanOpSite records a goroutine that completed an operation on a
+// channel and the PC of the call that did so.
+type chanOpSite struct {
+	goid int64
+	pc   uintptr
+}
+
+// chanWaitStart records that gp is parking on a channel operation at
+// time t.
+func chanWaitStart(gp *g, t int64) {
+	gp.waitsince = t
+	gp.chanBlockWarned = false
+}
+
+// chanWaitSince returns the time gp started waiting, or 0 if it is not
+// known. If gp is blocked on a channel and parked before channel waits
+// were timed, the wait is counted from now on, as the garbage collector
+// does for other waits (see markroot).
+//
+// gp must be waiting, and kept so: the world must be stopped, or gp
+// held in _Gscanwaiting.
+func chanWaitSince(gp *g, now int64) int64 {
+	if gp.waitsince == 0 && isChanWait(gp.waitreason) {
+		gp.waitsince = now
+	}
+	return gp.waitsince
+}
+
+// chanBlockWarnOp records that the current goroutine completed a send
+// (if send is set) or a receive on c in the call at pc.
+func chanBlockWarnOp(c *hchan, send bool, pc uintptr) {
+	d := c.side
+	if d == nil {
+		return
+	}
+	s := &d.lastRecv
+	if send {
+		s = &d.lastSend
+	}
+	s.goid = getg().goid
+	s.pc = pc
+}
+
+// maxBlockWarnChans is the number of channels of a blocked select that
+// chanBlockWarnG describes.
+const maxBlockWarnChans = 8
+
+// chanBlockWarn reports the goroutines that have been blocked on
+// channels for longer than the GODEBUG=chanblockwarn threshold and
+// were not reported yet. It is called by sysmon.
+func chanBlockWarn(now int64) {
+	threshold := int64(debug.chanblockwarn) * 1e9
+	forEachGRace(func(gp *g) {
+		if readgstatus(gp) != _Gwaiting || gp.chanBlockWarned || !isChanWait(gp.waitreason) {
+			return
+		}
+		since := gp.waitsince
+		if since == 0 || now-since < threshold {
+			return
+		}
+		// Keep gp from being woken and its stack from moving
+		// while we look at its sudogs and print its stack. If gp
+		// is being scanned or woken, try again next time.
+		if !castogscanstatus(gp, _Gwaiting, _Gscanwaiting) {
+			return
+		}
+		var chans [maxBlockWarnChans]*hchan
+		n := 0
+		if !gp.chanBlockWarned && isChanWait(gp.waitreason) && gp.waitsince == since {
+			n = chanBlockWarnG(gp, now, threshold, &chans)
+		}
+		casfrom_Gscanstatus(gp, _Gscanwaiting, _Gwaiting)
+
+		if n > 0 && debug.chanregistry != 0 {
+			lock(&chanRegistry.lock)
+			for _, c := range chans[:n] {
+				if s := chanRegistryLookup(c); s != nil && s.pc != 0 {
+					print("chan ", c, " created at ")
+					printchanpc(s.pc)
+				}
+			}
+			unlock(&chanRegistry.lock)
+		}
+	})
+}
+
+// isChanWait reports whether reason is that of a goroutine parked on
+// a channel operation.
+func isChanWait(reason waitReason) bool {
+	return reason.isChanSend() || reason.isChanRecv() || reason == waitReasonSelect
+}
+
+// chanBlockWarnG reports gp, which has been blocked on channels since
+// gp.waitsince, unless every channel it is blocked on has been reported
+// within threshold of now. gp must be suspended in _Gscanwaiting. The
+// channels reported are stored in chans, and their number is returned.
+func chanBlockWarnG(gp *g, now, threshold int64, chans *[maxBlockWarnChans]*hchan) int {
+	n, recent := 0, 0
+	for sg := gp.waiting; sg != nil && n < len(chans); sg = sg.waitlink {
+		c := sg.c
+		if c == nil {
+			break
+		}
+		if d := c.side; d != nil && d.blockWarned != 0 && now-d.blockWarned < threshold {
+			recent++
+		}
+		chans[n] = c
+		n++
+	}
+	if n == 0 || recent == n {
+		return 0
+	}
+	gp.chanBlockWarned = true
+
+	print("runtime: goroutine ", gp.goid, " has been blocked ")
+	switch {
+	case gp.waitreason.isChanSend():
+		print("sending on")
+	case gp.waitreason.isChanRecv():
+		print("receiving from")
+	default:
+		print("in a select on")
+	}
+	print(" ", n, " channel")
+	if n > 1 {
+		print("s")
+	}
+	print(" for ", (now-gp.waitsince)/1e9, "s\n")
+	i := 0
+	for sg := gp.waiting; sg != nil && i < n; sg, i = sg.waitlink, i+1 {
+		c := chans[i]
+		dir := "recv"
+		if sg.isSend() {
+			dir = "send"
+		}
+		print("\t", dir, " on chan ", c, " (chan ", c.elemtype.string(), ", len ", c.qcount, ", cap ", c.dataqsiz)
+		if c.closed != 0 {
+			print(", closed by goroutine ", c.closedBy)
+		}
+		print(")\n")
+		d := c.side
+		if d == nil {
+			continue
+		}
+		d.blockWarned = now
+		peer, op := &d.lastSend, "sent on"
+		if sg.isSend() {
+			peer, op = &d.lastRecv, "received from"
+		}
+		goid, pc := peer.goid, peer.pc
+		if goid == 0 {
+			print("\t\tnever ", op, "\n")
+			continue
+		}
+		print("\t\tlast ", op, " by goroutine ", goid, " at ")
+		printchanpc(pc)
+	}
+	// Not goroutineheader, which would show gp as being scanned.
+	print("goroutine ", gp.goid, " [", gp.waitreason.String(), "]:\n")
+	traceback(^uintptr(0), ^uintptr(0), 0, gp)
+	print("\n")
+	return n
+}
+
+// printchanpc prints the function and line of the call whose return
+// PC is pc.
+func printchanpc(pc uintptr) {
+	f := findfunc(pc)
+	if !f.valid() {
+		print("pc=", hex(pc), "\n")
+		return
+	}
+	file, line := funcline(f, pc-1)
+	print(funcname(f), " (", file, ":", line, ")\n")
+}
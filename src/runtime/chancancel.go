@@ -0,0 +1,204 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Cancelable channel operations.
+//
+// A goroutine that wants its blocking channel operations to be
+// interruptible gets a handle from newChanCancel and performs them
+// with chansendCancelable, chanrecvCancelable or selectgoCancelable.
+// Any goroutine holding the handle may call cancel, after which the
+// operation the goroutine is blocked in, if any, and every later one
+// made with the handle return at once and report that they were
+// cancelled. Operations that do not block are not affected, and plain
+// channel operations of the same goroutine never are: they have no way
+// to report the cancellation.
+//
+// These are for the standard library, which turns a cancellation into
+// an error; user code has no access to them.
+//
+// A cancelled operation must not also complete: a value sent must not
+// be reported cancelled, and a receive that is reported cancelled must
+// not have consumed a value. The goroutine that completes an operation
+// and the canceller race for it the way the cases of a select do, with
+// a compare-and-swap of g.selectDone: the sudog of a cancelable send or
+// receive is marked cancelable, and a goroutine that dequeues it must
+// win g.selectDone to complete the operation (see waitq.dequeue). The
+// canceller must win g.selectDone to wake the goroutine, and does so
+// only while the goroutine is parked, that is, between the park commit
+// function and the goroutine running again. A goroutine woken by the
+// canceller has no sudog marked woken, and its sudogs may still be
+// queued.
+
+import (
+	"runtime/internal/atomic"
+	"unsafe"
+)
+
+// A chanCancel is the handle to the cancelable channel operations of a
+// goroutine.
+type chanCancel struct {
+	gp        *g     // goroutine that owns the handle
+	cancelled uint32 // set by cancel; accessed atomically
+
+	lock mutex
+	// parked is set while gp is parked in a cancelable operation and
+	// may be woken by cancel. It is protected by lock.
+	parked bool
+}
+
+// newChanCancel returns a handle that cancels the blocking channel
+// operations the current goroutine performs with it.
+func newChanCancel() *chanCancel {
+	h := &chanCancel{gp: getg()}
+	lockInit(&h.lock, lockRankChanCancel)
+	return h
+}
+
+// cancel cancels the current and future blocking channel operations
+// performed with h.
+func (h *chanCancel) cancel() {
+	lock(&h.lock)
+	atomic.Store(&h.cancelled, 1)
+	gp := h.gp
+	wake := h.parked && atomic.Cas(&gp.selectDone, 0, 1)
+	if wake {
+		h.parked = false
+	}
+	unlock(&h.lock)
+	if wake {
+		goready(gp, 3)
+	}
+}
+
+// chanCancelBegin starts a cancelable operation with h on the current
+// goroutine, which must own h.
+func chanCancelBegin(h *chanCancel) *g {
+	gp := getg()
+	if h.gp != gp {
+		throw("chanCancel used by another goroutine")
+	}
+	if gp.chanCancel != nil {
+		throw("nested cancelable channel operation")
+	}
+	gp.chanCancel = h
+	return gp
+}
+
+// chanCancelled reports whether gp is in a cancelable operation that
+// has been cancelled.
+func chanCancelled(gp *g) bool {
+	h := gp.chanCancel
+	return h != nil && atomic.Load(&h.cancelled) != 0
+}
+
+// chansendCancelable sends the value at ep on c, blocking until it can
+// be sent or the send is cancelled with h, and reports whether it was
+// cancelled.
+func chansendCancelable(h *chanCancel, c *hchan, ep unsafe.Pointer) (cancelled bool) {
+	gp := chanCancelBegin(h)
+	sent := chansend(c, ep, true, getcallerpc(), nil)
+	gp.chanCancel = nil
+	return !sent
+}
+
+// chanrecvCancelable receives from c into ep, like chanrecv, blocking
+// until a value can be received or the receive is cancelled with h.
+// ep is not written if the receive is cancelled.
+func chanrecvCancelable(h *chanCancel, c *hchan, ep unsafe.Pointer) (received, cancelled bool) {
+	gp := chanCancelBegin(h)
+	selected, received := chanrecv(c, ep, true)
+	gp.chanCancel = nil
+	return received, !selected
+}
+
+// selectgoCancelable is like a blocking selectgo, but returns
+// cancelled and a case index of -1 if it is cancelled with h.
+func selectgoCancelable(h *chanCancel, cas0 *scase, order0 *uint16, pc0 *uintptr, nsends, nrecvs int) (casi int, recvOK, cancelled bool) {
+	gp := chanCancelBegin(h)
+	casi, recvOK = selectgo(cas0, order0, pc0, nsends, nrecvs, true, false)
+	gp.chanCancel = nil
+	return casi, recvOK, casi < 0
+}
+
+// chanCancelPark records that gp, which is in a cancelable operation,
+// is parking, and reports whether it may. If the operation has been
+// cancelled, it claims gp.selectDone so that no other goroutine can
+// complete the operation, and reports false. The locks of the channels
+// gp is queued on must be held, so that no other goroutine can have
+// claimed gp.selectDone yet. On success, h.lock is left held, and the
+// caller must unlock it once gp may be woken, after the channel locks.
+func chanCancelPark(gp *g) bool {
+	h := gp.chanCancel
+	lock(&h.lock)
+	if atomic.Load(&h.cancelled) != 0 {
+		atomic.Store(&gp.selectDone, 1)
+		unlock(&h.lock)
+		return false
+	}
+	h.parked = true
+	return true
+}
+
+// chancancelparkcommit is chanparkcommit for a cancelable send or
+// receive.
+func chancancelparkcommit(gp *g, chanLock unsafe.Pointer) bool {
+	h := gp.chanCancel
+	if !chanCancelPark(gp) {
+		chanparkcommit(gp, chanLock)
+		return false
+	}
+	// Keep the canceller from waking gp until chanparkunlock is done
+	// with gp.
+	chanparkunlock(gp, chanLock)
+	unlock(&h.lock)
+	chanSetWakeParked()
+	return true
+}
+
+// selcancelparkcommit is selparkcommit for a cancelable select.
+func selcancelparkcommit(gp *g, _ unsafe.Pointer) bool {
+	h := gp.chanCancel
+	if !chanCancelPark(gp) {
+		selparkcommit(gp, nil)
+		return false
+	}
+	selparkunlock(gp)
+	unlock(&h.lock)
+	chanSetWakeParked()
+	return true
+}
+
+// chanCancelUnpark is called by gp once it runs again after parking in
+// a cancelable operation, before it resets gp.selectDone. After it,
+// the canceller no longer tries to wake gp.
+func chanCancelUnpark(gp *g) {
+	h := gp.chanCancel
+	lock(&h.lock)
+	h.parked = false
+	unlock(&h.lock)
+}
+
+// chanCancelWoken is called by gp once it runs again after parking in
+// a cancelable send or receive on c, whose sudog mysg was queued on q.
+// It reports whether the operation was cancelled, in which case it has
+// removed mysg from q and cleared mysg.elem, as the goroutine completing
+// the operation would have.
+func chanCancelWoken(gp *g, c *hchan, q *waitq, mysg *sudog) bool {
+	chanCancelUnpark(gp)
+	mysg.flags &^= sudogCancelable
+	cancelled := !mysg.woken
+	if cancelled {
+		// A goroutine that dequeued mysg since lost the race for
+		// gp.selectDone and skipped it, but mysg may still be on q.
+		lock(&c.lock)
+		q.dequeueSudoG(mysg)
+		mysg.elem = nil
+		unlock(&c.lock)
+	}
+	// mysg is off q, and the canceller is done with gp.
+	atomic.Store(&gp.selectDone, 0)
+	return cancelled
+}
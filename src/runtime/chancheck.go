@@ -0,0 +1,369 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Diagnostics for corrupted channel state.
+//
+// The throws for inconsistent channel state are rare and usually the
+// result of memory corruption elsewhere, so they print everything
+// they can about the channel and the sudogs involved. The state is
+// suspect: this code does not lock channels, follows only the links
+// between sudogs, bounding every walk, and prints type pointers
+// rather than type names.
+//
+// With GODEBUG=chaninvariants=1, a goroutine about to block on a
+// channel also checks the channel's wait queue and its own waiting
+// list, and every send, receive and close checks the invariants of
+// the channel before unlocking it, so that corruption is caught at
+// the operation that caused it. This costs time proportional to the
+// length of the channel's queues on every operation. A receive that
+// finds a channel closed and drained without locking it also locks it
+// to check that nothing was left to receive.
+//
+// With GODEBUG=checkstackchans=1, moving the stack of a goroutine
+// parked on channels checks that the sudogs' pointers into the old
+// stack were all moved, since a send or receive through a stale one
+// corrupts memory that has been freed or reused.
+
+import "runtime/internal/atomic"
+
+// maxCheckSudogs and maxPrintSudogs bound walks of sudog lists, so
+// that a list that has been corrupted into a cycle can't make them
+// loop forever.
+const (
+	maxCheckSudogs = 1 << 24
+	maxPrintSudogs = 1 << 10
+)
+
+// printhchan prints the fields of c.
+func printhchan(c *hchan) {
+	print("\thchan ", c, ": qcount=", c.qcount, " dataqsiz=", c.dataqsiz, " buf=", c.buf,
+		" elemsize=", c.elemsize, " elemtype=", c.elemtype, " closed=", c.closed, " closedBy=", c.closedBy,
+		" sendx=", c.sendx, " recvx=", c.recvx,
+		" recvq={", c.recvq.first, " ", c.recvq.last, "} sendq={", c.sendq.first, " ", c.sendq.last, "}",
+		" flags=", hex(c.flags), " dirty=", c.dirty, " side=", c.side, "\n")
+	if s := c.side; s != nil {
+		print("\tside ", s, ": borrows=", s.borrows, " borrowx=", s.borrowx, " borrowMask=", hex(s.borrowMask), "\n")
+	}
+}
+
+// printsudog prints the fields of s.
+func printsudog(s *sudog) {
+	print("\tsudog ", s, ": g=", s.g, " c=", s.c, " elem=", s.elem,
+		" success=", s.success, " woken=", s.woken, " isSelect=", s.isSelect(), " isSend=", s.isSend(),
+		" queued=", s.queued, " next=", s.next, " prev=", s.prev, " waitlink=", s.waitlink,
+		" releasetime=", s.releasetime, " ticket=", s.ticket, "\n")
+}
+
+// printwaitlist prints the sudogs on the waiting list that starts at
+// s, as linked by waitlink.
+func printwaitlist(s *sudog) {
+	for n := 0; s != nil; s, n = s.waitlink, n+1 {
+		if n == maxPrintSudogs {
+			print("\t...\n")
+			return
+		}
+		printsudog(s)
+	}
+}
+
+// printchanwakeup prints the state of channel c and of the sudog sg
+// of the current goroutine, which was woken from c in a way that c's
+// state does not explain, before the caller throws.
+func printchanwakeup(c *hchan, sg *sudog) {
+	print("runtime: spurious wakeup on chan ", c, " of goroutine ", getg().goid, "\n")
+	printhchan(c)
+	printwaitlist(sg)
+}
+
+// printwaitingcorrupt prints the state of gp, which was woken from
+// channel c with its sudog mysg no longer at the head of its waiting
+// list, before the caller throws.
+func printwaitingcorrupt(c *hchan, mysg *sudog, gp *g) {
+	sel := gp.waiting != nil && gp.waiting.isSelect()
+	print("runtime: goroutine ", gp.goid, " woken from chan ", c, ": mysg=", mysg,
+		" gp.waiting=", gp.waiting, " in select=", sel, "\n")
+	printhchan(c)
+	print("\tmysg:\n")
+	printsudog(mysg)
+	print("\tgp.waiting list:\n")
+	printwaitlist(gp.waiting)
+}
+
+// chancheckenqueue checks, for GODEBUG=chaninvariants=1, that the
+// current goroutine can block on c by adding sg to c's wait queue q:
+// that sg is on the goroutine's waiting list and not on any queue yet,
+// and that q is well formed. c must be locked.
+//
+// The callers have elem pointers into their stack in sudogs that
+// are not on a wait queue yet, so this must not grow the stack.
+//
+//go:nosplit
+func chancheckenqueue(c *hchan, q *waitq, sg *sudog) {
+	gp := getg()
+	systemstack(func() {
+		if msg := checkwaitlist(gp, c, sg); msg != "" {
+			print("runtime: goroutine ", gp.goid, " blocking on chan ", c, ": ", msg, "\n")
+			printhchan(c)
+			print("\tsg:\n")
+			printsudog(sg)
+			print("\tgp.waiting list:\n")
+			printwaitlist(gp.waiting)
+			throw("chan invariant violated")
+		}
+		if bad, msg := checkwaitq(c, q); bad != nil {
+			print("runtime: goroutine ", gp.goid, " blocking on chan ", c, ": wait queue ", msg, " at sudog ", bad, "\n")
+			printhchan(c)
+			printsudog(bad)
+			throw("chan wait queue is corrupted")
+		}
+	})
+}
+
+// chancheck checks, for GODEBUG=chaninvariants=1, the invariants of c
+// stated at the top of chan.go, that its buffer indices agree with
+// each other and with its borrowed slots, and that its wait queues are
+// well formed. It is called at the end of an operation on c, which
+// must be locked.
+//
+//go:nosplit
+func chancheck(c *hchan) {
+	gp := getg()
+	systemstack(func() {
+		q, bad, msg := &c.recvq, (*sudog)(nil), ""
+		if bad, msg = checkwaitq(c, q); bad == nil {
+			q = &c.sendq
+			if bad, msg = checkwaitq(c, q); bad == nil {
+				msg = checkhchan(c)
+			}
+		}
+		if msg == "" {
+			return
+		}
+		print("runtime: goroutine ", gp.goid, " leaving chan ", c, ": ")
+		if bad != nil {
+			name := "recvq"
+			if q == &c.sendq {
+				name = "sendq"
+			}
+			print(name, " ", msg, " at sudog ", bad, "\n")
+		} else {
+			print(msg, "\n")
+		}
+		printhchan(c)
+		print("\trecvq:\n")
+		printwaitq(&c.recvq)
+		print("\tsendq:\n")
+		printwaitq(&c.sendq)
+		throw("chan invariant violated")
+	})
+}
+
+// checkhchan checks the buffer and the wait queues of c for chancheck,
+// which has checked that the queues are well formed. It returns a
+// description of the first problem, or "".
+func checkhchan(c *hchan) string {
+	var borrows, borrowx uint
+	var borrowMask uint64
+	if s := c.side; s != nil {
+		borrows, borrowx, borrowMask = s.borrows, s.borrowx, s.borrowMask
+	}
+	n := c.dataqsiz
+	if n == 0 {
+		if c.qcount != 0 || c.sendx != 0 || c.recvx != 0 || borrows != 0 || borrowMask != 0 {
+			return "unbuffered channel has values or indices"
+		}
+	} else {
+		switch {
+		case c.sendx >= n || c.recvx >= n || borrowx >= n:
+			return "buffer index out of range"
+		case c.qcount+borrows > n:
+			return "more values and borrowed slots than the buffer holds"
+		case (c.recvx+c.qcount)%n != c.sendx:
+			return "sendx does not follow the values from recvx"
+		}
+	}
+	if c.dirty != 0 && (c.qcount == 0 || borrows != 0) {
+		return "received slots left to clear in an empty buffer or with borrowed slots"
+	}
+	if borrows == 0 {
+		if borrowMask != 0 {
+			return "borrow mask set without borrowed slots"
+		}
+	} else {
+		switch {
+		case (borrowx+borrows)%n != c.recvx:
+			return "borrowed slots do not end at recvx"
+		case borrowMask&1 == 0:
+			return "first borrowed slot is committed"
+		case borrows < 64 && borrowMask>>borrows != 0:
+			return "borrow mask covers slots that are not borrowed"
+		}
+	}
+
+	recv, send := c.recvq.first, c.sendq.first
+	switch {
+	case c.closed != 0 && (recv != nil || send != nil):
+		return "closed channel has waiters"
+	case c.qcount > 0 && recv != nil:
+		return "receivers wait while values are buffered"
+	case c.qcount+borrows < n && send != nil:
+		return "senders wait while the buffer has room"
+	}
+	if recv != nil && send != nil {
+		// Only a select both sending on and receiving from c
+		// can be on both queues.
+		for _, q := range [...]*waitq{&c.recvq, &c.sendq} {
+			for s := q.first; s != nil; s = s.next {
+				if s.g != recv.g || !s.isSelect() {
+					return "both wait queues hold sudogs, not all of one select"
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// printwaitq prints the sudogs on the wait queue q.
+func printwaitq(q *waitq) {
+	for n, s := 0, q.first; s != nil; s, n = s.next, n+1 {
+		if n == maxPrintSudogs {
+			print("\t...\n")
+			return
+		}
+		printsudog(s)
+	}
+}
+
+// checkwaitlist checks gp's waiting list for chancheckenqueue and
+// returns a description of the first problem, or "".
+func checkwaitlist(gp *g, c *hchan, sg *sudog) string {
+	if sg.g != gp || sg.c != c {
+		return "sudog does not match goroutine or channel"
+	}
+	if sg.next != nil || sg.prev != nil {
+		return "sudog is already queued"
+	}
+	found := false
+	n := 0
+	for s := gp.waiting; s != nil; s = s.waitlink {
+		if n == maxCheckSudogs {
+			return "waiting list too long or cyclic"
+		}
+		if s.g != gp || s.c == nil {
+			return "waiting list holds a foreign or unused sudog"
+		}
+		if s.isSelect() != sg.isSelect() {
+			return "waiting list mixes select and non-select sudogs"
+		}
+		if s == sg {
+			found = true
+		}
+		n++
+	}
+	if !found {
+		return "sudog is not on waiting list"
+	}
+	if !sg.isSelect() && n != 1 {
+		return "waiting list of a plain channel operation holds several sudogs"
+	}
+	return ""
+}
+
+// chancheckdrained checks, for GODEBUG=chaninvariants=1, that c, which
+// chanrecv has found closed and drained without locking it, is closed
+// and drained. A closed channel gains no values and no senders, so a
+// receive that saw it drained must not find a value left behind.
+func chancheckdrained(c *hchan) {
+	lock(&c.lock)
+	msg := ""
+	switch {
+	case c.closed == 0:
+		msg = "channel is not closed"
+	case c.qcount != 0:
+		msg = "closed channel still holds values"
+	case c.sendq.first != nil:
+		msg = "closed channel has senders"
+	}
+	if msg != "" {
+		print("runtime: goroutine ", getg().goid, " found chan ", c, " closed and drained: ", msg, "\n")
+		printhchan(c)
+		throw("chan invariant violated")
+	}
+	unlock(&c.lock)
+}
+
+// checkwaitq checks the wait queue q of c for chancheckenqueue and
+// chancheck. It returns the first bad sudog and a description of the
+// problem, or nil.
+func checkwaitq(c *hchan, q *waitq) (*sudog, string) {
+	var prev *sudog
+	n := 0
+	for s := q.first; s != nil; prev, s = s, s.next {
+		if n == maxCheckSudogs {
+			return s, "too long or cyclic"
+		}
+		if s.prev != prev {
+			return s, "has a bad prev link"
+		}
+		if s.c != c {
+			return s, "holds a sudog of another channel"
+		}
+		if s.g == nil {
+			return s, "holds a sudog without a goroutine"
+		}
+		if s.isSend() != (q == &c.sendq) {
+			return s, "holds a sudog of the wrong direction"
+		}
+		n++
+	}
+	if q.last != prev {
+		if prev == nil {
+			return q.last, "is empty but has a last element"
+		}
+		return prev, "does not end at its last element"
+	}
+	return nil, ""
+}
+
+// checkStackSudogs counts the sudogs checked by checkstacksudogs, for
+// tests.
+var checkStackSudogs uint64
+
+// checkstacksudogs checks, for GODEBUG=checkstackchans=1, that the
+// sudogs of gp, whose stack is being moved from old to new, have been
+// adjusted: that the elem of each points into new or outside of any
+// stack. The channels gp is parked on must be locked.
+func checkstacksudogs(gp *g, old, new stack) {
+	for sg := gp.waiting; sg != nil; sg = sg.waitlink {
+		atomic.Xadd64(&checkStackSudogs, 1)
+		p := uintptr(sg.elem)
+		if p == 0 {
+			continue
+		}
+		var msg string
+		switch {
+		case new.lo <= p && p < new.hi:
+			if p+uintptr(sg.c.elemsize) > new.hi {
+				msg = "runs past the top of the new stack"
+			}
+		case old.lo <= p && p < old.hi:
+			msg = "points into the old stack"
+		default:
+			if s := spanOf(p); s != nil && s.state.get() == mSpanManual {
+				msg = "points into another stack"
+			}
+		}
+		if msg == "" {
+			continue
+		}
+		print("runtime: moving stack of goroutine ", gp.goid, " from [", hex(old.lo), ", ", hex(old.hi),
+			") to [", hex(new.lo), ", ", hex(new.hi), "): sudog ", sg, " elem ", msg, "\n")
+		printhchan(sg.c)
+		print("\tgp.waiting list:\n")
+		printwaitlist(gp.waiting)
+		throw("sudog elem not adjusted by stack copy")
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Batched clearing of received buffer slots.
+//
+// A receive from a channel's buffer clears the slot it took the value
+// from, so that the garbage collector does not keep what the value
+// points to alive. For elements with pointers, clearing one slot at a
+// time costs a typedmemclr, with its write barrier check, per receive.
+// A consumer draining a long run of the buffer instead leaves its
+// slots to be cleared together, with a single memclrHasPointers.
+//
+// The c.dirty slots before c.recvx have been received from and not
+// yet cleared. A receive adds its slot to them, and clears them all
+// along with its own once there are chanDirtyBatch of them, when it
+// empties the buffer, or while the garbage collector is marking, which
+// would otherwise find the values they hold. Borrowing a slot for
+// reflect.Value.RecvZeroCopy, which moves c.recvx past a slot that is
+// still in use, clears them first, and receives while slots are
+// borrowed clear their own slot at once.
+//
+// Senders fill the free slots from c.sendx, the one furthest from
+// c.recvx, so they reuse slots in the order they were received from,
+// and never need to clear one first. Once senders have reused some of
+// the dirty slots, c.dirty is more than the number of free slots, and
+// only the free ones are cleared.
+//
+// The slots left dirty keep at most chanDirtyBatch-1 values per
+// channel alive past their receive, and only while its buffer is not
+// empty.
+
+import "unsafe"
+
+// chanDirtyBatch is the number of received slots that are cleared
+// together. hchan.dirty, which is less, must fit in a uint8.
+const chanDirtyBatch = 64
+
+// chanRecvClear clears the slot at c.recvx, qp, which a receive has
+// just taken its value from, or leaves it to be cleared later. It is
+// called before the receive advances c.recvx and c.qcount.
+// c.lock must be held.
+func chanRecvClear(c *hchan, qp unsafe.Pointer) {
+	if c.elemtype.ptrdata == 0 || c.borrows() != 0 {
+		chanclr(c, qp)
+		return
+	}
+	if c.qcount > 1 && c.dirty < chanDirtyBatch-1 && gcphase == _GCoff {
+		c.dirty++
+		return
+	}
+	n := uint(c.dirty)
+	if free := c.dataqsiz - c.qcount; n > free {
+		n = free
+	}
+	chanclrn(c, c.recvx+1, n+1)
+	c.dirty = 0
+}
+
+// chanClearDirty clears the slots before c.recvx left to be cleared by
+// receives. c.lock must be held.
+func chanClearDirty(c *hchan) {
+	if c.dirty == 0 {
+		return
+	}
+	n := uint(c.dirty)
+	if free := c.dataqsiz - c.qcount - c.borrows(); n > free {
+		n = free
+	}
+	chanclrn(c, c.recvx, n)
+	c.dirty = 0
+}
+
+// chanclrn zeroes the n slots of c's buffer before slot end, wrapping
+// around the start of the buffer.
+func chanclrn(c *hchan, end, n uint) {
+	size := uintptr(c.elemsize)
+	if n > end {
+		memclrHasPointers(chanbuf(c, c.dataqsiz-(n-end)), uintptr(n-end)*size)
+		n = end
+	}
+	memclrHasPointers(chanbuf(c, end-n), uintptr(n)*size)
+}
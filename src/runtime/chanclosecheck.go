@@ -0,0 +1,153 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Warnings about channels closed while goroutines may still send on
+// them.
+//
+// A send on a closed channel panics, and the panic shows the sender,
+// but not the close that it raced with. The race detector catches the
+// race, but is too expensive to run in production. With
+// GODEBUG=chanclosecheck=1, makechan gives each channel a ring of its
+// chanCloseRingLen most recent sends, kept in the channel's debugging
+// state (see chanside.go), in which chansend and selectgo
+// record the sending goroutine, the PC of the send and the time. A
+// close then prints a warning if goroutines are blocked sending on the
+// channel, which will panic, or if a goroutine other than the closing
+// one sent on it within chanCloseWindow before the close, and so may
+// still be sending:
+//
+//	runtime: close of chan 0xc000020060 (chan int, len 0, cap 0) while it may still be sent on
+//		goroutine 7 is blocked sending
+//		goroutine 8 sent 41µs before the close at main.produce (/tmp/x.go:12)
+//	goroutine 1 [running]:
+//	main.main()
+//		/tmp/x.go:20 +0x85
+//
+// The close then proceeds as usual. A send that completed just before
+// the close may have been ordered before it by other synchronization,
+// such as a sync.WaitGroup that the closing goroutine waited on, so a
+// warning about recent sends alone is only a hint.
+//
+// The close also records its PC and time in the side state, so that
+// the ClosedChannelError of a later send on the channel can tell where
+// and when the channel was closed.
+//
+// As for GODEBUG=chanblockwarn (see chanblockwarn.go), the ring is
+// written without synchronization, and a warning may pair the
+// goroutine of one send with the PC or time of another. When the
+// setting is off, the check of debug.chanclosecheck is all it costs a
+// send.
+
+import "runtime/internal/atomic"
+
+const (
+	chanCloseRingLen = 4
+	chanCloseWindow  = 10e6 // nanoseconds before a close in which sends are reported
+)
+
+// chanSendRing holds the most recent sends on a channel.
+type chanSendRing struct {
+	next  uint32
+	sends [chanCloseRingLen]chanSendRecord
+}
+
+// A chanSendRecord records a send on a channel.
+type chanSendRecord struct {
+	goid int64
+	pc   uintptr // return PC of the send
+	when int64   // nanotime of the send
+}
+
+// chanCloseCheckSend records a send on c by the call at pc.
+func chanCloseCheckSend(c *hchan, pc uintptr) {
+	d := c.side
+	if d == nil {
+		// c was made before GODEBUG was parsed.
+		return
+	}
+	r := &d.sendRing
+	s := &r.sends[atomic.Xadd(&r.next, 1)%chanCloseRingLen]
+	s.goid = getg().goid
+	s.pc = pc
+	s.when = nanotime()
+}
+
+// chanCloseWarning is what closechan found on a channel that it is
+// to warn about.
+type chanCloseWarning struct {
+	len, cap uint
+	blocked  int // number of blocked senders
+	goids    [chanCloseRingLen]int64
+	recent   [chanCloseRingLen]chanSendRecord
+	nrecent  int
+	now      int64
+}
+
+// chanCloseCheck reports whether the close of c, which is in progress,
+// should be warned about, and if so fills in w. c.lock must be held,
+// and c.sendq not dequeued yet.
+func chanCloseCheck(c *hchan, w *chanCloseWarning) bool {
+	for sg := c.sendq.first; sg != nil; sg = sg.next {
+		if w.blocked < len(w.goids) {
+			w.goids[w.blocked] = sg.g.goid
+		}
+		w.blocked++
+	}
+	now := nanotime()
+	if d := c.side; d != nil {
+		r := &d.sendRing
+		self := getg().goid
+	sends:
+		for i := range r.sends {
+			s := r.sends[i]
+			if s.goid == 0 || s.goid == self || now-s.when >= chanCloseWindow {
+				continue
+			}
+			// A blocked sender recorded its send before blocking.
+			for _, goid := range w.goids {
+				if goid == s.goid {
+					continue sends
+				}
+			}
+			w.recent[w.nrecent] = s
+			w.nrecent++
+		}
+	}
+	if w.blocked == 0 && w.nrecent == 0 {
+		return false
+	}
+	w.len, w.cap, w.now = c.qcount, c.dataqsiz, now
+	return true
+}
+
+// chanCloseWarn prints the warning w about the close of c, and the
+// stack of the closing goroutine, in which the runtime's own frames
+// are hidden as usual.
+func chanCloseWarn(c *hchan, w *chanCloseWarning) {
+	gp := getg()
+	print("runtime: close of chan ", c, " (chan ", c.elemtype.string(), ", len ", w.len, ", cap ", w.cap,
+		") while it may still be sent on\n")
+	n := w.blocked
+	if n > len(w.goids) {
+		n = len(w.goids)
+	}
+	for _, goid := range w.goids[:n] {
+		print("\tgoroutine ", goid, " is blocked sending\n")
+	}
+	if w.blocked > n {
+		print("\t... and ", w.blocked-n, " more blocked senders\n")
+	}
+	for _, s := range w.recent[:w.nrecent] {
+		print("\tgoroutine ", s.goid, " sent ", (w.now-s.when)/1e3, "µs before the close at ")
+		printchanpc(s.pc)
+	}
+	print("goroutine ", gp.goid, " [running]:\n")
+	callerpc, sp := getcallerpc(), getcallersp()
+	systemstack(func() {
+		traceback(callerpc, sp, 0, gp)
+	})
+	print("\n")
+}
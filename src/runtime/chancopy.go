@@ -0,0 +1,90 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Copying channel elements of common kinds.
+//
+// Most channels carry strings, slices, or interfaces. typedmemmove and
+// typedmemclr handle these like any other type, finding the pointers
+// to pass to the write barrier from the type's pointer bitmap. Since
+// the layout of these kinds is fixed, makechan instead records the
+// kind of such an element in the chanCopyMask bits of hchan.flags, and
+// the channel operations copy and clear the element with a plain typed
+// assignment, whose write barrier the compiler emits for the pointer
+// word.
+//
+// A compiler-emitted write barrier is correct whether the destination
+// is in the heap, on our stack, or on the stack of a goroutine blocked
+// on the channel (see sendDirect), so these also replace memmove and
+// typeBitsBulkBarrier in the direct copies between stacks.
+//
+// With GODEBUG=cgocheck=2, elements are always copied by typedmemmove,
+// which does the extra checks.
+
+import "unsafe"
+
+// Values of the chanCopyMask bits of hchan.flags.
+const (
+	chanCopyGeneric = iota // typedmemmove and typedmemclr
+	chanCopyString
+	chanCopySlice
+	chanCopyIface // interface, empty or not
+)
+
+// chanCopyKind returns the chanCopyMask bits for elements of type t.
+func chanCopyKind(t *_type) uint8 {
+	if debug.cgocheck > 1 {
+		return chanCopyGeneric
+	}
+	switch t.kind & kindMask {
+	case kindString:
+		return chanCopyString
+	case kindSlice:
+		return chanCopySlice
+	case kindInterface:
+		return chanCopyIface
+	}
+	return chanCopyGeneric
+}
+
+// chanmove copies an element of c from src to dst, as typedmemmove
+// would.
+//
+// It is nosplit so that it has no preemption points between the
+// callers reading a sudog's elem and using it.
+//
+//go:nosplit
+func chanmove(c *hchan, dst, src unsafe.Pointer) {
+	switch c.flags & chanCopyMask {
+	case chanCopyString:
+		*(*string)(dst) = *(*string)(src)
+	case chanCopySlice:
+		// All slices have the layout of []byte.
+		*(*[]byte)(dst) = *(*[]byte)(src)
+	case chanCopyIface:
+		// Both kinds of interface are a pair of pointers;
+		// copying the itab of a non-empty interface as the
+		// type of an empty one is harmless.
+		*(*interface{})(dst) = *(*interface{})(src)
+	default:
+		typedmemmove(c.elemtype, dst, src)
+	}
+}
+
+// chanclr zeroes an element of c at p, as typedmemclr would.
+//
+//go:nosplit
+func chanclr(c *hchan, p unsafe.Pointer) {
+	switch c.flags & chanCopyMask {
+	case chanCopyString:
+		*(*string)(p) = ""
+	case chanCopySlice:
+		*(*[]byte)(p) = nil
+	case chanCopyIface:
+		*(*interface{})(p) = nil
+	default:
+		typedmemclr(c.elemtype, p)
+	}
+}
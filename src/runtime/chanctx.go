@@ -0,0 +1,55 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Channel operations in forbidden contexts.
+//
+// A channel operation must run on a user goroutine. One that blocks
+// parks the current goroutine, which on the system stack or in a
+// signal handler is not a goroutine that can park; a signal handler
+// may also have interrupted the holder of the channel's lock; and with
+// the world stopped, no goroutine that the operation waits for or
+// wakes can run. The resulting crashes, and hangs, happen far from the
+// operation, so chansend, chanrecv, closechan and selectgo check their
+// context first and throw, naming the context and the operation.
+//
+// Timers run their functions on the system stack of the scheduler, and
+// those of package time send on channels without blocking, so only
+// blocking operations are forbidden there.
+//
+// The check is made on every channel operation. On a user goroutine
+// with the world running, it costs two comparisons.
+
+// chancheckctx throws if the current goroutine may not perform the
+// channel operation op, which may block if block is set.
+//
+//go:nosplit
+func chancheckctx(op string, block bool) {
+	gp := getg()
+	if gp.m.curg == gp && gp.m.chanForbid == "" {
+		return
+	}
+	chancheckctxslow(op, block)
+}
+
+// chancheckctxslow is the part of chancheckctx for goroutines other
+// than user goroutines, and for user goroutines that may not perform
+// channel operations at all.
+func chancheckctxslow(op string, block bool) {
+	gp := getg()
+	ctx := ""
+	switch {
+	case gp == gp.m.gsignal:
+		ctx = "in a signal handler"
+	case gp.m.chanForbid != "":
+		ctx = gp.m.chanForbid
+	case gp == gp.m.g0 && block:
+		ctx = "on the system stack"
+	}
+	if ctx != "" {
+		print("runtime: channel ", op, " ", ctx, "\n")
+		throw("channel operation in forbidden context")
+	}
+}
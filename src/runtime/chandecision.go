@@ -0,0 +1,364 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Recording and replay of channel decisions.
+//
+// Some bugs only show up under a particular order of channel wakeups
+// and select choices. While recording is on, the runtime logs each
+// such nondeterministic decision in a ring buffer:
+//
+//   - which goroutine a channel operation wakes from the channel's
+//     wait queue (waitq.dequeue);
+//   - the seed of a select statement's poll order, and the case the
+//     select chooses (selectgo).
+//
+// Each decision is keyed by the channel or select statement where it
+// was made and by the number of decisions made there before it.
+// Channels are numbered in the order they are created while
+// recording or replay is on. Select statements are identified by
+// their offset in the text segment. The ring is printed when the
+// program crashes, and runtime/debug can read it.
+//
+// While replay is on, the runtime looks up each decision's key in a
+// log captured by an earlier run and, where it can, makes the same
+// choice: a channel wakes the goroutine with the recorded ID if that
+// goroutine is waiting, and a select polls its cases in the recorded
+// order. Replay is best effort. It reproduces the recorded choices
+// only as far as the rest of the program, goroutine creation and
+// scheduling in particular, behaves as it did in the recorded run.
+//
+// Wakeups done by the network poller (chansendready) are neither
+// recorded nor replayed.
+
+import (
+	"runtime/internal/atomic"
+	_ "unsafe" // for go:linkname
+)
+
+// chanDecision is a runtime copy of runtime/debug.ChanDecision and
+// must be kept structurally identical to that type.
+type chanDecision struct {
+	isSelect bool
+	key      uint64 // channel number, or text offset of the select statement
+	seq      uint64 // number of earlier decisions with the same isSelect and key
+	goid     int64  // wakeup: goroutine woken
+	seed     uint32 // select: poll order seed
+	casi     int    // select: case chosen, or -1 for default
+}
+
+// less reports whether d sorts before the decision with the given key
+// in a replay log.
+func (d *chanDecision) less(isSelect bool, key, seq uint64) bool {
+	if d.isSelect != isSelect {
+		return !d.isSelect
+	}
+	if d.key != key {
+		return d.key < key
+	}
+	return d.seq < seq
+}
+
+// chanDecisionSites is the number of distinct select statements
+// whose decisions can be recorded or replayed.
+const chanDecisionSites = 1024
+
+var chanDecisions chanDecisionState
+
+type chanDecisionState struct {
+	// enabled is nonzero while recording or replay is on.
+	enabled uint32 // atomic
+
+	// nextChan is the number of the last channel created while
+	// recording or replay is on.
+	nextChan uint32 // atomic
+
+	lock mutex
+	ring []chanDecision // recorded decisions; nil if not recording
+	n    uint64         // number of decisions recorded into ring
+
+	// replay is the log being replayed, sorted by isSelect, key,
+	// and seq. It is nil if replay is off.
+	replay []chanDecision
+
+	// sites counts the decisions made at each select statement, in
+	// an open-addressed hash table keyed by text offset.
+	sites [chanDecisionSites]struct {
+		key uint64
+		n   uint64
+	}
+}
+
+// chanDecisionInit starts recording at startup if GODEBUG=chanrecord
+// is set.
+func chanDecisionInit() {
+	lockInit(&chanDecisions.lock, lockRankChanDecisions)
+	if debug.chanrecord > 0 {
+		setChanRecording(int(debug.chanrecord))
+	}
+}
+
+// setChanRecording starts recording the last n channel decisions, or
+// stops recording if n <= 0.
+//
+//go:linkname setChanRecording runtime/debug.setChanRecording
+func setChanRecording(n int) {
+	var ring []chanDecision
+	if n > 0 {
+		ring = make([]chanDecision, n)
+	}
+	cd := &chanDecisions
+	lock(&cd.lock)
+	cd.ring = ring
+	cd.n = 0
+	if ring != nil {
+		cd.restart()
+	}
+	cd.setEnabled()
+	unlock(&cd.lock)
+}
+
+// setChanReplay starts replaying log, which must be sorted by
+// isSelect, key, and seq, or stops replay if log is nil.
+//
+//go:linkname setChanReplay runtime/debug.setChanReplay
+func setChanReplay(log []chanDecision) {
+	cd := &chanDecisions
+	lock(&cd.lock)
+	cd.replay = log
+	if log != nil {
+		cd.restart()
+	}
+	cd.setEnabled()
+	unlock(&cd.lock)
+}
+
+// readChanDecisions copies the recorded decisions, oldest first, into
+// p if they fit. n is the number of recorded decisions in either case.
+//
+//go:linkname readChanDecisions runtime/debug.readChanDecisions
+func readChanDecisions(p []chanDecision) (n int) {
+	cd := &chanDecisions
+	lock(&cd.lock)
+	first := cd.first()
+	n = int(cd.n - first)
+	if n <= len(p) {
+		for i := range p[:n] {
+			p[i] = cd.ring[(first+uint64(i))%uint64(len(cd.ring))]
+		}
+	}
+	unlock(&cd.lock)
+	return n
+}
+
+// printChanDecisions prints the recorded decisions, if any, when the
+// program crashes. It does not lock chanDecisions.lock, which the
+// crashing goroutine may hold.
+func printChanDecisions() {
+	cd := &chanDecisions
+	if cd.ring == nil || cd.n == 0 {
+		return
+	}
+	print("\nchannel decisions (oldest first):\n")
+	for i := cd.first(); i < cd.n; i++ {
+		d := &cd.ring[i%uint64(len(cd.ring))]
+		if d.isSelect {
+			print("\tselect site=", hex(d.key), " seq=", d.seq, " seed=", d.seed, " case=", d.casi, "\n")
+		} else {
+			print("\twake chan=", d.key, " seq=", d.seq, " goroutine=", d.goid, "\n")
+		}
+	}
+}
+
+// first returns the index of the oldest decision still in the ring.
+func (cd *chanDecisionState) first() uint64 {
+	if cd.n > uint64(len(cd.ring)) {
+		return cd.n - uint64(len(cd.ring))
+	}
+	return 0
+}
+
+// restart restarts the numbering of channels and select decisions, so
+// that the keys of a recording and of its replay match if both start
+// at the same point in the program. Channels created earlier keep
+// their numbers. cd.lock must be held.
+func (cd *chanDecisionState) restart() {
+	atomic.Store(&cd.nextChan, 0)
+	for i := range cd.sites {
+		cd.sites[i].key = 0
+		cd.sites[i].n = 0
+	}
+}
+
+// setEnabled updates cd.enabled. cd.lock must be held.
+func (cd *chanDecisionState) setEnabled() {
+	if cd.ring != nil || cd.replay != nil {
+		atomic.Store(&cd.enabled, 1)
+	} else {
+		atomic.Store(&cd.enabled, 0)
+	}
+}
+
+// record adds d to the ring, if recording is on. cd.lock must be held.
+func (cd *chanDecisionState) record(d chanDecision) {
+	if cd.ring == nil {
+		return
+	}
+	cd.ring[cd.n%uint64(len(cd.ring))] = d
+	cd.n++
+}
+
+// lookup returns the decision with the given key in the replay log, or
+// nil if there is none. cd.lock must be held.
+func (cd *chanDecisionState) lookup(isSelect bool, key, seq uint64) *chanDecision {
+	log := cd.replay
+	i, j := 0, len(log)
+	for i < j {
+		h := int(uint(i+j) >> 1)
+		if log[h].less(isSelect, key, seq) {
+			i = h + 1
+		} else {
+			j = h
+		}
+	}
+	if i < len(log) && log[i].isSelect == isSelect && log[i].key == key && log[i].seq == seq {
+		return &log[i]
+	}
+	return nil
+}
+
+// siteSeq returns the number of earlier decisions of the select
+// statement at text offset key and counts a new one. ok is false if
+// the table of select statements is full. cd.lock must be held.
+func (cd *chanDecisionState) siteSeq(key uint64) (seq uint64, ok bool) {
+	h := uint(key*0x9e3779b97f4a7c15>>32) % chanDecisionSites
+	for i := uint(0); i < chanDecisionSites; i++ {
+		s := &cd.sites[(h+i)%chanDecisionSites]
+		if s.key == 0 {
+			s.key = key
+		}
+		if s.key == key {
+			seq = s.n
+			s.n++
+			return seq, true
+		}
+	}
+	return 0, false
+}
+
+// chanNumber numbers the newly created channel c if recording or
+// replay is on.
+func chanNumber(c *hchan) {
+	if d := c.side; d != nil && atomic.Load(&chanDecisions.enabled) != 0 {
+		d.decisionID = atomic.Xadd(&chanDecisions.nextChan, 1)
+	}
+}
+
+// dequeueReplayed removes the sudog of the goroutine that the replay
+// log says the next wakeup on q's channel woke from q, and returns it.
+// It returns nil if the log has no such wakeup or that goroutine is
+// not waiting on q. The channel's lock must be held.
+func (q *waitq) dequeueReplayed() *sudog {
+	if q.first == nil {
+		return nil
+	}
+	cs := q.first.c.side
+	if cs == nil || cs.decisionID == 0 {
+		return nil
+	}
+	cd := &chanDecisions
+	lock(&cd.lock)
+	d := cd.lookup(false, uint64(cs.decisionID), uint64(cs.decisionSeq))
+	var goid int64
+	if d != nil {
+		goid = d.goid
+	}
+	unlock(&cd.lock)
+	if d == nil {
+		return nil
+	}
+	for sgp := q.first; sgp != nil; sgp = sgp.next {
+		if sgp.g.goid != goid {
+			continue
+		}
+		// See dequeue.
+		if (sgp.isSelect() || sgp.cancelable()) && !atomic.Cas(&sgp.g.selectDone, 0, 1) {
+			return nil
+		}
+		q.dequeueSudoG(sgp)
+		chanWakeDecided(sgp)
+		return sgp
+	}
+	return nil
+}
+
+// chanWakeDecided records that sgp was dequeued to be woken. The
+// lock of sgp's channel must be held.
+func chanWakeDecided(sgp *sudog) {
+	cs := sgp.c.side
+	if cs == nil || cs.decisionID == 0 {
+		return
+	}
+	seq := cs.decisionSeq
+	cs.decisionSeq++
+	cd := &chanDecisions
+	lock(&cd.lock)
+	cd.record(chanDecision{key: uint64(cs.decisionID), seq: uint64(seq), goid: sgp.g.goid})
+	unlock(&cd.lock)
+}
+
+// selectDecision is the state of a select statement whose decision is
+// recorded or replayed.
+type selectDecision struct {
+	key  uint64
+	seq  uint64
+	seed uint32
+	rand uint32 // poll order generator state
+	ok   bool   // whether the select statement has a sequence number
+}
+
+// start assigns the select statement at pc a sequence number and
+// chooses its poll order seed: the recorded one if it is replayed, or
+// a random one.
+func (sd *selectDecision) start(pc uintptr) {
+	sd.key = uint64(pc)
+	if firstmoduledata.text <= pc && pc < firstmoduledata.etext {
+		sd.key = uint64(pc - firstmoduledata.text)
+	}
+	sd.seed = fastrand()
+	cd := &chanDecisions
+	lock(&cd.lock)
+	sd.seq, sd.ok = cd.siteSeq(sd.key)
+	if sd.ok {
+		if d := cd.lookup(true, sd.key, sd.seq); d != nil {
+			sd.seed = d.seed
+		}
+	}
+	unlock(&cd.lock)
+	sd.rand = sd.seed
+}
+
+// randn is fastrandn for the poll order of the select statement: it
+// draws from a generator seeded with sd.seed, so that the seed
+// determines the poll order.
+func (sd *selectDecision) randn(n uint32) uint32 {
+	x := sd.rand
+	x ^= x << 13
+	x ^= x >> 17
+	x ^= x << 5
+	sd.rand = x
+	return uint32(uint64(x) * uint64(n) >> 32)
+}
+
+// done records that the select statement chose case casi.
+func (sd *selectDecision) done(casi int) {
+	if !sd.ok {
+		return
+	}
+	cd := &chanDecisions
+	lock(&cd.lock)
+	cd.record(chanDecision{isSelect: true, key: sd.key, seq: sd.seq, seed: sd.seed, casi: casi})
+	unlock(&cd.lock)
+}
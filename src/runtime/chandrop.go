@@ -0,0 +1,43 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Reports of channels freed while still holding buffered values.
+//
+// With GODEBUG=chandropcheck=1, makechan adds each buffered channel to
+// the channel registry, whose special record tells the sweeper when
+// the channel is freed. If the channel's buffer is not empty at that
+// point, the values in it were sent but will never be received, which
+// is almost always a bug, so chanDropCheck reports it. Where the
+// channel was created is only known if the registry is enabled too
+// (GODEBUG=chanregistry=1).
+
+import (
+	"runtime/internal/atomic"
+	"unsafe"
+)
+
+// chanDropped counts the channels reported by chanDropCheck.
+var chanDropped uint64
+
+// chanDropCheck is called by the sweeper when the registered channel
+// of s is freed, and reports the channel if its buffer is not empty.
+// The channel is unreachable, so nothing else accesses it.
+func chanDropCheck(s *specialChan) {
+	c := (*hchan)(unsafe.Pointer(s.c))
+	if c.qcount == 0 {
+		return
+	}
+	atomic.Xadd64(&chanDropped, 1)
+	print("runtime: chan ", c, " (chan ", c.elemtype.string(), ", cap ", c.dataqsiz, ") freed with ", c.qcount, " buffered value")
+	if c.qcount > 1 {
+		print("s")
+	}
+	print("\n")
+	if s.pc != 0 {
+		print("\tcreated at ")
+		printchanpc(s.pc)
+	}
+}
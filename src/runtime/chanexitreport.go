@@ -0,0 +1,87 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Exit-time report of goroutines left blocked on channels.
+//
+// When main returns, or the program calls os.Exit(0), goroutines still
+// parked on channel operations are abandoned without a trace. Often
+// they are workers whose input was never closed, or whose results
+// were never collected. With GODEBUG=chanexitreport=1, the exit path
+// stops the world and prints those goroutines to standard error,
+// grouped as by GODEBUG=tracebackgroup (see tracebackgroup.go):
+//
+//	runtime: 3 goroutines blocked on channels at exit
+//
+//	2 goroutines [chan receive (sync)]:
+//		goroutine ids: 6 7
+//		on 2 distinct chans (chan int)
+//		waiting: min 0, median 0, max 0 minutes
+//	main.worker(...)
+//		/tmp/x.go:10 +0x25
+//	...
+//
+// Nothing is printed if there are no such goroutines. The report does
+// not change the exit status, and costs a stop-the-world and a walk of
+// the blocked goroutines' stacks. Exits through os.Exit with a nonzero
+// status, and crashes, are not reported on: they print tracebacks of
+// their own or are failures already.
+
+import (
+	"runtime/internal/atomic"
+	"unsafe"
+)
+
+// chanExitReport prints the goroutines blocked on channels, if
+// GODEBUG=chanexitreport is set. It is called as the program exits.
+func chanExitReport() {
+	if debug.chanexitreport == 0 {
+		return
+	}
+	stopTheWorld("channel exit report")
+	chanExitReportSTW()
+	startTheWorld()
+}
+
+// chanExitReportSTW prints the report. The world must be stopped.
+func chanExitReportSTW() {
+	max := int(atomic.Loaduintptr(&allglen))
+	size := uintptr(max) * (unsafe.Sizeof(gsnap{}) + unsafe.Sizeof(gsnapGroup{}))
+	if size == 0 {
+		return
+	}
+	size = alignUp(size, physPageSize)
+	mem := sysAlloc(size, &memstats.other_sys)
+	if mem == nil {
+		print("runtime: no memory for the channel exit report\n")
+		return
+	}
+	defer sysFree(mem, size, &memstats.other_sys)
+	snaps := unsafe.Slice((*gsnap)(mem), max)
+	groups := unsafe.Slice((*gsnapGroup)(add(mem, uintptr(max)*unsafe.Sizeof(gsnap{}))), max)
+
+	now := nanotime()
+	n := 0
+	forEachGRace(func(gp *g) {
+		if n == max || readgstatus(gp)&^_Gscan != _Gwaiting || !isChanWait(gp.waitreason) || isSystemGoroutine(gp, false) {
+			return
+		}
+		snapg(&snaps[n], gp, now)
+		n++
+	})
+	if n == 0 {
+		return
+	}
+	snaps = snaps[:n]
+
+	print("runtime: ", n, " goroutine")
+	if n > 1 {
+		print("s")
+	}
+	print(" blocked on channels at exit\n")
+	for _, grp := range groupgsnaps(snaps, groups) {
+		printgsnapgroup(snaps[grp.start : grp.start+grp.n])
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Sends to the head of a channel's buffer, for
+// reflect.Value.TrySendFront.
+//
+// A send normally stores its value at c.sendx, behind every value
+// already buffered. A front send instead steps c.recvx back one slot
+// and stores its value there, so that the next receive takes it; the
+// other values stay in the buffer in the order they were sent. The
+// slot it takes is the free slot received from most recently, which
+// is the last of the c.dirty slots left to be cleared, if any (see
+// chanclear.go).
+//
+// Only the non-blocking form exists. The value of a blocked sender is
+// moved into the buffer by the receive that makes room for it, always
+// at c.sendx, so a front send that waited would lose its place.
+//
+// Slots borrowed by reflect.Value.RecvZeroCopy end at c.recvx, so the
+// slot before c.recvx is never free while any are borrowed, and a
+// front send then fails as if the buffer were full.
+
+import (
+	"runtime/internal/atomic"
+	"unsafe"
+)
+
+//go:linkname reflect_chansendfront reflect.chansendfront
+func reflect_chansendfront(c *hchan, ep unsafe.Pointer) bool {
+	selected := chansendfront(c, ep, getcallerpc())
+	if debug.chanblockwarn > 0 && selected {
+		chanBlockWarnOp(c, true, getcallerpc())
+	}
+	return selected
+}
+
+// chansendfront sends the value ep points to on c, ahead of the
+// values in c's buffer, if it can do so without blocking, and reports
+// whether it did. If a receiver is waiting, it gets the value directly.
+func chansendfront(c *hchan, ep unsafe.Pointer, callerpc uintptr) bool {
+	chancheckctx("send", false)
+	if c == nil {
+		return false
+	}
+	if raceenabled {
+		racereadpc(c.raceaddr(), callerpc, funcPC(chansendfront))
+	}
+	// As in chansend.
+	if c.closed == 0 && (full(c) || chanNBFail(c)) {
+		return false
+	}
+	if atomic.Load(&c.closed) != 0 {
+		chanMisuseSendOnClosed()
+		panic(closedChannelError(c))
+	}
+
+	lockchan(c)
+	if c.closed != 0 {
+		unlockchan(c)
+		chanMisuseSendOnClosed()
+		panic(closedChannelError(c))
+	}
+	if sg := c.recvq.dequeue(); sg != nil {
+		// The buffer is empty, so the front is also the back.
+		send(c, sg, ep, func() { unlockchan(c) }, 3)
+		chanprofop(chanProfSend, 1)
+		return true
+	}
+	if c.borrows() != 0 || c.qcount == c.dataqsiz {
+		unlockchan(c)
+		return false
+	}
+
+	slot := c.recvx
+	if slot == 0 {
+		slot = c.dataqsiz
+	}
+	slot--
+	if raceenabled {
+		// The memory model orders the receive that last freed
+		// c.sendx before this send, as it would a send to c.sendx,
+		// but slot was freed by a later receive, which need not
+		// happen after it. So acquire from c.sendx as well before
+		// publishing through slot to the receive that takes the
+		// value.
+		if c.elemsize != 0 {
+			raceacquire(chanbuf(c, c.sendx))
+		}
+		racenotify(c, slot, nil)
+	}
+	chanmove(c, chanbuf(c, slot), ep)
+	c.recvx = slot
+	c.qcount++
+	if c.dirty != 0 {
+		c.dirty--
+	}
+	if trace.enabled {
+		traceChanBuf(c, c.qcount-1)
+	}
+	if raceenabled {
+		racechancount(c)
+	}
+	chanStatsRecordOp(c, 1, 0)
+	var wake gList
+	if c.sets() != nil {
+		chanSetNotify(c, &wake)
+	}
+	unlockchan(c)
+	chanSetWakeAll(&wake)
+	chanStatsImmediate(1, 0)
+	chanprofop(chanProfSend, 1)
+	return true
+}
@@ -0,0 +1,50 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Channel labels.
+//
+// reflect.Value.SetChanLabel attaches a string to a channel, so that
+// channels made at the same place, such as by a helper used all over
+// a program, can be told apart. The runtime shows the label in the
+// tracebacks of goroutines blocked on the channel, in the channel's
+// events in the execution trace, in runtime/debug.DumpChannels and in
+// the reports of the channel leak checker. Labels are set rarely and
+// read only by these, so the label is kept in the channel's side state
+// (see chanside.go), which labeling a channel attaches to it. The GC
+// finds the label through the side state, which keeps it alive.
+
+import _ "unsafe" // for go:linkname
+
+// chanSetLabel sets the label of c to label, or removes it if label
+// is empty.
+func chanSetLabel(c *hchan, label string) {
+	var p *string
+	if label != "" {
+		p = new(string)
+		*p = label
+	}
+	lock(&c.lock)
+	if p != nil || c.side != nil {
+		chanSide(c).label = p
+	}
+	if trace.enabled {
+		traceChanLabel(c)
+	}
+	unlock(&c.lock)
+}
+
+// chanLabel returns the label of c, or "" if it has none.
+func chanLabel(c *hchan) string {
+	if s := c.side; s != nil && s.label != nil {
+		return *s.label
+	}
+	return ""
+}
+
+//go:linkname reflect_chansetlabel reflect.chansetlabel
+func reflect_chansetlabel(c *hchan, label string) {
+	chanSetLabel(c, label)
+}
@@ -0,0 +1,108 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Channel leak checking for the testing package's -test.chanleak flag.
+//
+// The testing package gives each test a distinct nonzero leak scope
+// and stores it in the goroutine running the test. Goroutines inherit
+// the scope of the goroutine that starts them. While a goroutine's
+// scope is nonzero, makechan records the channels it creates in the
+// channel registry, stamped with the scope.
+//
+// When the test ends, testing_chanLeaks reports the goroutines that
+// are blocked only on channels stamped with the test's scope. Unless
+// the test handed those channels to something that outlives it,
+// nothing can unblock these goroutines any more.
+
+import "unsafe"
+
+// leakedGoroutine is a runtime copy of testing.leakedGoroutine and
+// must be kept structurally identical to that type.
+type leakedGoroutine struct {
+	goid    int64
+	wait    string
+	stack   [32]uintptr // stack of the goroutine, 0-terminated if short
+	created [4]uintptr  // creation PCs of its channels, 0-terminated if short
+	labels  [4]string   // labels of its channels, parallel to created
+}
+
+//go:linkname testing_setChanLeakScope testing.runtime_setChanLeakScope
+func testing_setChanLeakScope(scope uint64) {
+	getg().chanLeakScope = scope
+}
+
+// testing_chanLeaks describes the goroutines that are blocked only on
+// channels created in scope. If there are at most len(p) of them,
+// testing_chanLeaks fills in the first n elements of p; n is the
+// number of leaked goroutines in either case.
+//
+//go:linkname testing_chanLeaks testing.runtime_chanLeaks
+func testing_chanLeaks(scope uint64, p []leakedGoroutine) (n int) {
+	stopTheWorld("channel leaks")
+	lock(&chanRegistry.lock)
+	forEachGRace(func(gp *g) {
+		if !chanLeaked(gp, scope) {
+			return
+		}
+		if n < len(p) {
+			r := &p[n]
+			r.goid = gp.goid
+			r.wait = gp.waitreason.String()
+			k := gentraceback(^uintptr(0), ^uintptr(0), 0, gp, 0, &r.stack[0], len(r.stack), nil, nil, 0)
+			if k < len(r.stack) {
+				r.stack[k] = 0
+			}
+			k = 0
+			for sg := gp.waiting; sg != nil && k < len(r.created); sg = sg.waitlink {
+				r.created[k] = chanRegistryLookup(sg.c).pc
+				r.labels[k] = chanLabel(sg.c)
+				k++
+			}
+			if k < len(r.created) {
+				r.created[k] = 0
+			}
+		}
+		n++
+	})
+	unlock(&chanRegistry.lock)
+	startTheWorld()
+	return n
+}
+
+// chanLeaked reports whether gp is blocked in a channel operation on
+// channels that were all created in scope. The world must be stopped
+// and chanRegistry.lock held.
+func chanLeaked(gp *g, scope uint64) bool {
+	if readgstatus(gp)&^_Gscan != _Gwaiting {
+		return false
+	}
+	if w := gp.waitreason; !w.isChanSend() && !w.isChanRecv() && w != waitReasonSelect {
+		return false
+	}
+	// chansend, chanrecv, and selectgo link their sudogs from
+	// gp.waiting while the goroutine is parked.
+	if gp.waiting == nil {
+		return false
+	}
+	for sg := gp.waiting; sg != nil; sg = sg.waitlink {
+		s := chanRegistryLookup(sg.c)
+		if s == nil || s.scope != scope {
+			return false
+		}
+	}
+	return true
+}
+
+// chanRegistryLookup returns the registry entry for c, or nil if c is
+// not registered. chanRegistry.lock must be held.
+func chanRegistryLookup(c *hchan) *specialChan {
+	for s := chanRegistry.first; s != nil; s = s.next {
+		if s.c == uintptr(unsafe.Pointer(c)) {
+			return s
+		}
+	}
+	return nil
+}
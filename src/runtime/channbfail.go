@@ -0,0 +1,49 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Fault injection for non-blocking channel operations, for the
+// runtime's tests.
+//
+// A non-blocking send or receive may fail even though the channel was
+// ready at some point during the operation, if another goroutine
+// operates on the channel concurrently: the fast paths in chansend and
+// chanrecv decide from a single observation of the channel that may
+// already be out of date. Callers racing with other goroutines must
+// therefore handle the failure, but it is rare in tests, where the
+// channel is usually ready. SetChanNBFail (see export_test.go) makes
+// those fast paths report failure for a percentage of the operations
+// on an open channel regardless of its state, so that tests exercise
+// the retry paths.
+//
+// The failures are legal only on a channel whose users all race with
+// one another. An operation on a channel that is known to be ready,
+// because the goroutine filled the buffer itself or synchronized with
+// the goroutine that did, cannot fail otherwise, and code relying on
+// that, such as os/signal and testing, would break. So failures are
+// injected only on the channels that a test marks, which keep the
+// percentage in their side state (see chanside.go), and never on the
+// other channels of the program.
+//
+// Only operations that reach chansend or chanrecv with block false are
+// affected: selects with a default case and a single other case,
+// reflect.Value.TrySend and TryRecv, and TrySendFront. An operation on
+// a closed channel still panics or reports the channel closed.
+
+import (
+	"runtime/internal/atomic"
+	"unsafe"
+)
+
+// chanNBFail reports whether a non-blocking operation on c should fail
+// as if c were not ready.
+func chanNBFail(c *hchan) bool {
+	s := (*specialChanSide)(atomic.Loadp(unsafe.Pointer(&c.side)))
+	if s == nil {
+		return false
+	}
+	percent := atomic.Load(&s.nbFail)
+	return percent != 0 && fastrandn(100) < percent
+}
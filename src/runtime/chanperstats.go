@@ -0,0 +1,112 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Per-channel statistics.
+//
+// reflect.Value.EnableChanStats attaches an hchanStats to a channel,
+// which from then on counts the values sent and received through it
+// and records the most values its buffer held at once and the longest
+// time an operation blocked on it. The statistics are kept in the
+// channel's side state (see chanside.go), and the operations that
+// transfer values update the counts with c.lock held, so a channel
+// without statistics pays only for the nil check of c.side.
+//
+// Each transfer is counted once, by the goroutine that performs it: a
+// receive that takes the value of a blocked sender counts both the
+// send and the receive, and the sender, once woken, only records how
+// long it blocked. A select that blocked records its wait on the
+// channel of the case that woke it. Receives of the zero value from a
+// closed channel are not counted.
+
+import _ "unsafe" // for go:linkname
+
+// hchanStats holds the statistics of a channel. It is protected by
+// the channel's lock.
+type hchanStats struct {
+	maxLen  uint   // most values buffered at once
+	sends   uint64 // values sent
+	recvs   uint64 // values received
+	maxWait int64  // longest blocked operation, in nanoseconds
+}
+
+// chanEnableStats attaches statistics to c, unless it has them.
+func chanEnableStats(c *hchan) {
+	chanWaitTime()
+	s := new(hchanStats)
+	lock(&c.lock)
+	if d := chanSide(c); d.stats == nil {
+		s.maxLen = c.qcount
+		d.stats = s
+	}
+	unlock(&c.lock)
+}
+
+// stats returns the statistics of c, or nil if it has none.
+func (c *hchan) stats() *hchanStats {
+	if s := c.side; s != nil {
+		return s.stats
+	}
+	return nil
+}
+
+// chanStatsRecordOp records the transfer of sends and recvs values
+// through c in its statistics, if it has them. c.lock must be held,
+// and c.qcount up to date.
+//
+// It is called by chansendready, which may run without a P, so it must
+// not use write barriers.
+//
+//go:nowritebarrierrec
+func chanStatsRecordOp(c *hchan, sends, recvs uint64) {
+	if s := c.stats(); s != nil {
+		s.sends += sends
+		s.recvs += recvs
+		if c.qcount > s.maxLen {
+			s.maxLen = c.qcount
+		}
+	}
+}
+
+// chanStatsRecordWait records in the statistics of c, if it has them,
+// that an operation blocked on c from time t0 until now.
+func chanStatsRecordWait(c *hchan, t0 int64) {
+	if t0 == 0 {
+		return
+	}
+	// The statistics are set at most once and never reset, so
+	// looking for them unlocked can only miss statistics being enabled
+	// concurrently.
+	if c.stats() == nil {
+		return
+	}
+	lock(&c.lock)
+	chanStatsRecordWaitLocked(c, t0)
+	unlock(&c.lock)
+}
+
+// chanStatsRecordWaitLocked is chanStatsRecordWait with c.lock held.
+func chanStatsRecordWaitLocked(c *hchan, t0 int64) {
+	if s := c.stats(); s != nil && t0 != 0 {
+		if d := nanotime() - t0; d > s.maxWait {
+			s.maxWait = d
+		}
+	}
+}
+
+//go:linkname reflect_chanenablestats reflect.chanenablestats
+func reflect_chanenablestats(c *hchan) {
+	chanEnableStats(c)
+}
+
+//go:linkname reflect_chanstats reflect.chanstats
+func reflect_chanstats(c *hchan) (maxLen int, sends, recvs uint64, maxWait int64, ok bool) {
+	lock(&c.lock)
+	if s := c.stats(); s != nil {
+		maxLen, sends, recvs, maxWait, ok = int(s.maxLen), s.sends, s.recvs, s.maxWait, true
+	}
+	unlock(&c.lock)
+	return
+}
@@ -0,0 +1,119 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Sampled profile of channel operations.
+//
+// With GODEBUG=chanprofrate=N, every Nth channel operation completed on
+// each P records its call stack in the chanops profile of runtime/pprof,
+// along with the kind of the operation and whether it blocked. Unlike
+// the block profile, it includes the operations that complete at once,
+// so it shows which call sites perform the most channel operations.
+//
+// The operations counted are completed sends and receives, including
+// receives of the zero value from a closed channel, and selects that
+// chose a case other than default. A select left with a single channel
+// once its nil channels are omitted counts as the send or receive it
+// performs. Nonblocking operations that fail are not counted.
+//
+// The samples are kept in profile buckets of type chanProfile, like
+// block profile samples, with the kind of operation in the bucket's
+// size so that each stack has a bucket per kind.
+
+import "unsafe"
+
+// Kinds of channel operations, as recorded in the chanops profile. The
+// values are known to runtime/pprof.
+const (
+	chanProfSend   = 0
+	chanProfRecv   = 1
+	chanProfSelect = 2
+
+	// chanProfBlocked is or'ed into the kind of an operation that
+	// blocked.
+	chanProfBlocked = 4
+)
+
+// chanprofop records a channel operation of kind op, if the chanops
+// profile is enabled. The recorded stack starts skip frames above the
+// caller of chanprofop, which like the block profile is where the
+// compiled code called into the runtime. chanprofop is small enough to
+// be inlined, so that the cost with the profile off is one load and
+// branch.
+func chanprofop(op uint8, skip int) {
+	if debug.chanprofrate > 0 {
+		chanprofsample(op, skip)
+	}
+}
+
+// chanprofsample counts a channel operation of kind op against the
+// current P's sampling period and records it if it is the one sampled.
+//
+//go:noinline
+func chanprofsample(op uint8, skip int) {
+	mp := acquirem()
+	pp := mp.p.ptr()
+	rate := debug.chanprofrate
+	if pp.chanProfLeft > rate {
+		// The rate was lowered.
+		pp.chanProfLeft = rate
+	}
+	pp.chanProfLeft--
+	if pp.chanProfLeft > 0 {
+		releasem(mp)
+		return
+	}
+	pp.chanProfLeft = rate
+	releasem(mp)
+
+	var stk [maxStack]uintptr
+	// Skip chanprofsample and chanprofop.
+	nstk := callers(2+skip, stk[:])
+	lock(&proflock)
+	b := stkbucket(chanProfile, uintptr(op), stk[:nstk], true)
+	b.bp().count++
+	unlock(&proflock)
+}
+
+//go:linkname pprof_chanProfRate runtime/pprof.runtime_chanProfRate
+func pprof_chanProfRate() int {
+	return int(debug.chanprofrate)
+}
+
+// pprof_chanOpsProfile returns n, the number of records in the chanops
+// profile. If len(p) >= n, it copies the stacks into p, the kinds of
+// the operations into ops and the numbers of samples into counts, which
+// must be as long as p, and returns n, true. Otherwise it changes
+// nothing and returns n, false.
+//
+//go:linkname pprof_chanOpsProfile runtime/pprof.runtime_chanOpsProfile
+func pprof_chanOpsProfile(p []StackRecord, ops []uint8, counts []int64) (n int, ok bool) {
+	lock(&proflock)
+	for b := cbuckets; b != nil; b = b.allnext {
+		n++
+	}
+	if n <= len(p) && n <= len(ops) && n <= len(counts) {
+		ok = true
+		i := 0
+		for b := cbuckets; b != nil; b = b.allnext {
+			r := &p[i]
+			if raceenabled {
+				racewriterangepc(unsafe.Pointer(&r.Stack0[0]), unsafe.Sizeof(r.Stack0), getcallerpc(), funcPC(pprof_chanOpsProfile))
+			}
+			if msanenabled {
+				msanwrite(unsafe.Pointer(&r.Stack0[0]), unsafe.Sizeof(r.Stack0))
+			}
+			j := copy(r.Stack0[:], b.stk())
+			for ; j < len(r.Stack0); j++ {
+				r.Stack0[j] = 0
+			}
+			ops[i] = uint8(b.size)
+			counts[i] = int64(b.bp().count)
+			i++
+		}
+	}
+	unlock(&proflock)
+	return
+}
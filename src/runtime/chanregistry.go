@@ -0,0 +1,171 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Channel registry.
+//
+// With GODEBUG=chanregistry=1, makechan records each channel it
+// creates, together with its creation site and time, so that the
+// live channels can be listed by runtime/debug.DumpChannels. The
+// channel leak checker and GODEBUG=chandropcheck=1 also record
+// channels here; see chanleak.go and chandrop.go.
+//
+// The registry must not keep channels alive, so each entry is a
+// special record attached to its channel rather than a pointer from
+// a GC root. When the sweeper frees a registered channel, freeSpecial
+// removes the channel's entry from the registry.
+
+import "unsafe"
+
+var chanRegistry struct {
+	lock  mutex
+	first *specialChan
+	n     int
+}
+
+// specialChan is the special record of a registered channel. It
+// doubles as the channel's entry in chanRegistry.
+//
+//go:notinheap
+type specialChan struct {
+	special special
+
+	prev, next *specialChan // in chanRegistry, protected by chanRegistry.lock
+
+	c       uintptr // the channel; a uintptr so the registry doesn't retain it
+	pc      uintptr // return PC of the call that created the channel
+	created int64   // nanotime when the channel was created
+	scope   uint64  // chanLeakScope of the creating goroutine
+}
+
+// chanRegister adds the newly created channel c to the registry.
+func chanRegister(c *hchan) {
+	lock(&mheap_.speciallock)
+	s := (*specialChan)(mheap_.specialChanAlloc.alloc())
+	unlock(&mheap_.speciallock)
+	s.special.kind = _KindSpecialChan
+	s.c = uintptr(unsafe.Pointer(c))
+	s.created = nanotime()
+	s.scope = getg().chanLeakScope
+	s.pc = 0
+	if debug.chanregistry != 0 || s.scope != 0 {
+		s.pc = chanCreationPC()
+	}
+
+	lock(&chanRegistry.lock)
+	s.prev = nil
+	s.next = chanRegistry.first
+	if s.next != nil {
+		s.next.prev = s
+	}
+	chanRegistry.first = s
+	chanRegistry.n++
+	unlock(&chanRegistry.lock)
+
+	if !addspecial(unsafe.Pointer(c), &s.special) {
+		throw("chanRegister: channel already registered")
+	}
+}
+
+// chanUnregister removes the entry s of a channel that is being freed
+// from the registry.
+func chanUnregister(s *specialChan) {
+	lock(&chanRegistry.lock)
+	if s.prev != nil {
+		s.prev.next = s.next
+	} else {
+		chanRegistry.first = s.next
+	}
+	if s.next != nil {
+		s.next.prev = s.prev
+	}
+	chanRegistry.n--
+	unlock(&chanRegistry.lock)
+	s.prev, s.next = nil, nil
+}
+
+// chanCreationPC returns the return PC of the call that created a
+// channel: that of the first caller of makechan that is neither in
+// the runtime nor reflect.MakeChan.
+func chanCreationPC() uintptr {
+	var pcs [8]uintptr
+	n := callers(1, pcs[:])
+	for _, pc := range pcs[:n] {
+		f := findfunc(pc)
+		if !f.valid() {
+			continue
+		}
+		name := funcname(f)
+		if hasPrefix(name, "runtime.") || name == "reflect.makechan" || name == "reflect.MakeChan" {
+			continue
+		}
+		return pc
+	}
+	return 0
+}
+
+// chanInfoSnapshot is a runtime copy of runtime/debug.ChanInfo and
+// must be kept structurally identical to that type.
+type chanInfoSnapshot struct {
+	elem       string
+	cap        int
+	len        int
+	closed     bool
+	senders    int
+	receivers  int
+	age        int64
+	creationPC uintptr
+	label      string
+}
+
+// readChannels describes each channel in the registry. If the
+// registry holds at most len(p) channels, readChannels fills in the
+// first n elements of p; n is the number of registered channels in
+// either case. ok is false if the registry is disabled.
+//
+//go:linkname readChannels runtime/debug.readChannels
+func readChannels(p []chanInfoSnapshot) (n int, ok bool) {
+	if debug.chanregistry == 0 {
+		return 0, false
+	}
+
+	// Stop the world so that no channel is locked or being
+	// freed while we look at it. Channels that are unreachable
+	// but not yet swept are still listed.
+	stopTheWorld("channel registry")
+	lock(&chanRegistry.lock)
+	n = chanRegistry.n
+	if n <= len(p) {
+		now := nanotime()
+		i := 0
+		for s := chanRegistry.first; s != nil; s = s.next {
+			c := (*hchan)(unsafe.Pointer(s.c))
+			r := &p[i]
+			r.elem = c.elemtype.string()
+			r.cap = int(c.dataqsiz)
+			r.len = int(c.qcount)
+			r.closed = c.closed != 0
+			r.senders = c.sendq.len()
+			r.receivers = c.recvq.len()
+			r.age = now - s.created
+			r.creationPC = s.pc
+			r.label = chanLabel(c)
+			i++
+		}
+	}
+	unlock(&chanRegistry.lock)
+	startTheWorld()
+	return n, true
+}
+
+// len returns the number of sudogs in q. Selects that are done but
+// have not yet dequeued their other cases are included.
+func (q *waitq) len() int {
+	n := 0
+	for sg := q.first; sg != nil; sg = sg.next {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,353 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Channel sets.
+//
+// reflect.ChanSet is a set of channels that a goroutine waits on again
+// and again, each wait returning a channel that is ready to receive
+// from. A select queues a sudog on every one of its channels and
+// dequeues them all again each time it runs, so a loop selecting over
+// many channels costs time in proportion to their number. A set
+// instead registers with each of its channels once, when the channel
+// is added: the channel lists the entries of the sets it is in, in its
+// side state (see chanside.go), and the operations that can make a
+// channel ready to receive from, a send that buffers its value or
+// blocks and a close, queue the channel's entries on their sets' ready
+// lists and wake the goroutine waiting on each set. A wait then costs
+// the same however many channels the set has.
+//
+// Readiness is level-triggered. wait takes the first entry off the
+// ready list and checks, with the channel locked, that a receive from
+// it would not block, since another receiver may have got there first.
+// An entry that is still ready goes back to the end of the list, so
+// that its channel is reported again until it is drained and the ready
+// channels take turns; one that is not is dropped until its channel's
+// next send. Entries are queued with the channel locked too, so no
+// send can slip in between the check and the drop unnoticed. A closed
+// channel is reported once its buffer is empty, as closed, and then
+// leaves the set.
+//
+// The locks are ordered hchan, then chanSet. A goroutine must not be
+// readied with a channel locked, so chanSetNotify adds the waiters it
+// wakes to a list that the channel operation readies once it has
+// unlocked the channel, or, if the operation blocks, hands to its park
+// commit function in m.chanSetWake.
+
+import (
+	"runtime/internal/atomic"
+	"unsafe"
+)
+
+// A chanSet is the runtime side of a reflect.ChanSet.
+type chanSet struct {
+	lock mutex
+
+	// all is the list of the entries of the set, linked through
+	// allNext and allPrev.
+	all *chanSetEntry
+
+	// readyHead and readyTail are the list of the entries whose
+	// channels may be ready to receive from, linked through readyNext
+	// and readyPrev. The links are not pointers, so that the network
+	// poller, which may have no P, can queue entries without write
+	// barriers; all keeps the entries alive.
+	readyHead, readyTail chanSetEntryPtr
+
+	waiting bool     // a goroutine is in wait
+	waiter  guintptr // goroutine parked in wait, to be woken by a send
+}
+
+// A chanSetEntry records that a channel is in a set.
+type chanSetEntry struct {
+	set *chanSet
+	c   *hchan
+	id  int // reported by wait
+
+	// next links the entries of c, from c.sets(). It is protected by
+	// c.lock.
+	next *chanSetEntry
+
+	// The rest is protected by set.lock. removed is also written
+	// only with c.lock held.
+	allNext, allPrev     *chanSetEntry
+	readyNext, readyPrev chanSetEntryPtr
+	queued               bool // on the ready list
+	removed              bool
+}
+
+// A chanSetEntryPtr holds a *chanSetEntry without write barriers.
+type chanSetEntryPtr uintptr
+
+//go:nosplit
+func (p chanSetEntryPtr) ptr() *chanSetEntry { return (*chanSetEntry)(unsafe.Pointer(p)) }
+
+//go:nosplit
+func (p *chanSetEntryPtr) set(e *chanSetEntry) { *p = chanSetEntryPtr(unsafe.Pointer(e)) }
+
+func newChanSet() *chanSet {
+	s := new(chanSet)
+	lockInit(&s.lock, lockRankChanSet)
+	return s
+}
+
+// add adds c to s, with an entry that wait reports as id, and reports
+// whether it did; it does not if c is in s already.
+func (s *chanSet) add(c *hchan, id int) bool {
+	e := &chanSetEntry{set: s, c: c, id: id}
+	var wake gList
+	lock(&c.lock)
+	for x := c.sets(); x != nil; x = x.next {
+		if x.set == s {
+			unlock(&c.lock)
+			return false
+		}
+	}
+	d := chanSide(c)
+	e.next = d.sets
+	d.sets = e
+	lock(&s.lock)
+	e.allNext = s.all
+	if s.all != nil {
+		s.all.allPrev = e
+	}
+	s.all = e
+	if ready, _ := chanSetReady(c); ready {
+		s.queue(e, &wake)
+	}
+	unlock(&s.lock)
+	unlock(&c.lock)
+	chanSetWakeAll(&wake)
+	return true
+}
+
+// remove removes c from s, and reports whether it was in s.
+func (s *chanSet) remove(c *hchan) bool {
+	lock(&c.lock)
+	e := c.sets()
+	for e != nil && e.set != s {
+		e = e.next
+	}
+	if e == nil {
+		unlock(&c.lock)
+		return false
+	}
+	lock(&s.lock)
+	s.unlink(e)
+	unlock(&s.lock)
+	unlock(&c.lock)
+	return true
+}
+
+// clear removes all the channels from s.
+func (s *chanSet) clear() {
+	for {
+		lock(&s.lock)
+		e := s.all
+		unlock(&s.lock)
+		if e == nil {
+			return
+		}
+		lock(&e.c.lock)
+		lock(&s.lock)
+		if !e.removed {
+			s.unlink(e)
+		}
+		unlock(&s.lock)
+		unlock(&e.c.lock)
+	}
+}
+
+// unlink removes e from its channel and from s. Both e.c.lock and
+// s.lock must be held.
+func (s *chanSet) unlink(e *chanSetEntry) {
+	for p := &e.c.side.sets; *p != nil; p = &(*p).next {
+		if *p == e {
+			*p = e.next
+			break
+		}
+	}
+	e.next = nil
+	if e.queued {
+		s.dequeue(e)
+	}
+	if e.allPrev != nil {
+		e.allPrev.allNext = e.allNext
+	} else {
+		s.all = e.allNext
+	}
+	if e.allNext != nil {
+		e.allNext.allPrev = e.allPrev
+	}
+	e.allNext, e.allPrev = nil, nil
+	e.removed = true
+}
+
+// queue puts e at the end of the ready list, unless it is on it
+// already, and claims the goroutine waiting on s, if any, adding it
+// to wake. s.lock must be held.
+//
+//go:nowritebarrierrec
+func (s *chanSet) queue(e *chanSetEntry, wake *gList) {
+	if !e.queued {
+		e.queued = true
+		e.readyNext = 0
+		e.readyPrev = s.readyTail
+		if t := s.readyTail.ptr(); t != nil {
+			t.readyNext.set(e)
+		} else {
+			s.readyHead.set(e)
+		}
+		s.readyTail.set(e)
+	}
+	if gp := s.waiter.ptr(); gp != nil {
+		s.waiter = 0
+		wake.push(gp)
+	}
+}
+
+// dequeue takes e, which is queued, off the ready list. s.lock must
+// be held.
+func (s *chanSet) dequeue(e *chanSetEntry) {
+	if p := e.readyPrev.ptr(); p != nil {
+		p.readyNext = e.readyNext
+	} else {
+		s.readyHead = e.readyNext
+	}
+	if n := e.readyNext.ptr(); n != nil {
+		n.readyPrev = e.readyPrev
+	} else {
+		s.readyTail = e.readyPrev
+	}
+	e.readyNext, e.readyPrev = 0, 0
+	e.queued = false
+}
+
+// wait blocks until a channel in s is ready to receive from, and
+// returns the id of its entry and whether it is ready because it is
+// closed and empty, in which case the channel has left s. Only one
+// goroutine may wait on s at a time.
+func (s *chanSet) wait() (id int, closed bool) {
+	lock(&s.lock)
+	if s.waiting {
+		unlock(&s.lock)
+		panic(plainError("concurrent waits on a channel set"))
+	}
+	s.waiting = true
+	for {
+		e := s.readyHead.ptr()
+		if e == nil {
+			s.waiter.set(getg())
+			goparkunlock(&s.lock, waitReasonChanSetWait, traceEvGoBlock, 1)
+			lock(&s.lock)
+			continue
+		}
+		s.dequeue(e)
+		unlock(&s.lock)
+
+		// The channel may have been drained, or e removed, while
+		// s was unlocked; if it is sent on, e is queued again.
+		c := e.c
+		lock(&c.lock)
+		lock(&s.lock)
+		if e.removed {
+			unlock(&c.lock)
+			continue
+		}
+		ready, closed := chanSetReady(c)
+		if !ready {
+			unlock(&c.lock)
+			continue
+		}
+		if closed {
+			s.unlink(e)
+		} else if !e.queued {
+			var wake gList
+			s.queue(e, &wake) // wake is empty: s has no waiter
+		}
+		s.waiting = false
+		unlock(&s.lock)
+		unlock(&c.lock)
+		return e.id, closed
+	}
+}
+
+// chanSetReady reports whether a receive from c would not block, and
+// whether that is because c is closed and its buffer empty. c.lock
+// must be held.
+//
+//go:nowritebarrierrec
+func chanSetReady(c *hchan) (ready, closed bool) {
+	if c.qcount > 0 {
+		return true, false
+	}
+	if c.closed != 0 {
+		return true, true
+	}
+	// A select or cancelable send whose goroutine has been woken by
+	// another case stays on sendq until the goroutine dequeues it.
+	for sg := c.sendq.first; sg != nil; sg = sg.next {
+		if !sg.isSelect() && !sg.cancelable() || atomic.Load(&sg.g.selectDone) == 0 {
+			return true, false
+		}
+	}
+	return false, false
+}
+
+// chanSetNotify queues the set entries of c, which may have become
+// ready to receive from, and adds the goroutines it wakes to wake, to
+// be readied once c is unlocked. c.lock must be held.
+//
+// It may run without a P, from chansendready, so it must not allocate
+// or use write barriers.
+//
+//go:nowritebarrierrec
+func chanSetNotify(c *hchan, wake *gList) {
+	for e := c.sets(); e != nil; e = e.next {
+		s := e.set
+		lock(&s.lock)
+		s.queue(e, wake)
+		unlock(&s.lock)
+	}
+}
+
+// chanSetWakeAll readies the goroutines on wake.
+func chanSetWakeAll(wake *gList) {
+	for !wake.empty() {
+		goready(wake.pop(), 2)
+	}
+}
+
+// chanSetWakeParked readies the goroutines woken by the channel
+// operation parking on the current M, now that its channels are
+// unlocked. It is called by the park commit functions.
+func chanSetWakeParked() {
+	chanSetWakeAll(&getg().m.chanSetWake)
+}
+
+//go:linkname reflect_chansetnew reflect.chansetnew
+func reflect_chansetnew() unsafe.Pointer {
+	return unsafe.Pointer(newChanSet())
+}
+
+//go:linkname reflect_chansetadd reflect.chansetadd
+func reflect_chansetadd(s unsafe.Pointer, c *hchan, id int) bool {
+	return (*chanSet)(s).add(c, id)
+}
+
+//go:linkname reflect_chansetremove reflect.chansetremove
+func reflect_chansetremove(s unsafe.Pointer, c *hchan) bool {
+	return (*chanSet)(s).remove(c)
+}
+
+//go:linkname reflect_chansetclear reflect.chansetclear
+func reflect_chansetclear(s unsafe.Pointer) {
+	(*chanSet)(s).clear()
+}
+
+//go:linkname reflect_chansetwait reflect.chansetwait
+func reflect_chansetwait(s unsafe.Pointer) (id int, closed bool) {
+	return (*chanSet)(s).wait()
+}
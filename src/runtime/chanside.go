@@ -0,0 +1,154 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Per-channel side state.
+//
+// Some state is kept for a channel only while a debugging facility or
+// an opt-in feature uses it: recording and replay of channel decisions
+// (see chandecision.go), GODEBUG=chanwakeglobal (see chanwake.go),
+// GODEBUG=chanblockwarn (see chanblockwarn.go), GODEBUG=chanclosecheck
+// (see chanclosecheck.go), the zero-copy receives of
+// reflect.Value.RecvZeroCopy (see chan_borrow.go), channel labels (see
+// chanlabel.go), per-channel statistics (see chanperstats.go), channel
+// sets (see chanset.go), the execution tracer (see traceChan) and the
+// fault injection of the runtime's tests (see channbfail.go). So that
+// channels do not carry this state while it is unused, it is kept in a
+// record allocated outside the heap, and hchan.side points to it.
+// makechan attaches the record to the channels made while one of the
+// debugging facilities is on, and the opt-in features attach it to a
+// channel the first time they are used on it. Channel operations test only
+// c.side to learn that a channel has none of this state.
+//
+// The record is a special of its channel, so it is freed when the
+// channel is. Once attached, it stays until then. The GC does not
+// scan memory outside the heap, so markrootSpans scans the heap
+// pointers in the record, as it does the functions of finalizers.
+// Pointers stored in a record while the GC is marking are shaded by
+// the write barrier.
+
+import (
+	"runtime/internal/atomic"
+	"unsafe"
+)
+
+// specialChanSide is the special record holding the side state of a
+// channel. Unless noted otherwise, its fields are protected by the
+// channel's lock.
+//
+//go:notinheap
+type specialChanSide struct {
+	special special
+
+	// decisionID numbers the channel for recording and replaying its
+	// wakeups; it is 0 if recording and replay were both off when the
+	// channel was created. decisionSeq counts its recorded wakeups.
+	decisionID  uint32
+	decisionSeq uint32
+
+	// wakes counts the goroutines woken by operations on the channel
+	// since wakeStart, for GODEBUG=chanwakeglobal.
+	wakes     uint32
+	wakeStart int64
+
+	// lastSend and lastRecv record the goroutines that last completed
+	// a send and a receive on the channel, and blockWarned is when a
+	// goroutine blocked on it was last reported, for
+	// GODEBUG=chanblockwarn.
+	lastSend    chanOpSite
+	lastRecv    chanOpSite
+	blockWarned int64
+
+	// sendRing records the channel's most recent sends, and closedPC
+	// and closedAt the return PC of the call to close that closed it
+	// and the nanotime of the close, for GODEBUG=chanclosecheck.
+	sendRing chanSendRing
+	closedPC uintptr
+	closedAt int64
+
+	// borrows is the number of slots from borrowx on that have been
+	// received from but cannot be reused yet, because one of them is
+	// borrowed by reflect.Value.RecvZeroCopy. Bit i of borrowMask is
+	// set if slot borrowx+i is still borrowed. See chan_borrow.go.
+	borrows    uint
+	borrowx    uint
+	borrowMask uint64
+
+	// label is the label set with reflect.Value.SetChanLabel, or nil.
+	label *string
+
+	// stats is the channel's statistics, or nil if they are not
+	// enabled. It is set at most once.
+	stats *hchanStats
+
+	// sets lists the entries of the reflect.ChanSets the channel is
+	// in.
+	sets *chanSetEntry
+
+	// nbFail is the percentage of non-blocking operations on the
+	// channel that fail as if it were not ready; see channbfail.go.
+	// It is read without the lock.
+	nbFail uint32
+
+	// traceID identifies the channel in the execution trace. It is
+	// assigned by traceChan on the channel's first event in a trace,
+	// so an ID not greater than trace.chanSeqStart is left over from
+	// an earlier trace.
+	traceID uint64
+}
+
+// chanDebugInit attaches side state to the newly created channel c if
+// a debugging facility that keeps state for each channel is on.
+func chanDebugInit(c *hchan) {
+	if atomic.Load(&chanDecisions.enabled) == 0 && debug.chanwakeglobal <= 0 &&
+		debug.chanblockwarn <= 0 && debug.chanclosecheck == 0 {
+		return
+	}
+	c.side = chanSideAlloc(c)
+}
+
+// chanSide returns the side state of c, attaching it if c has none.
+// c.lock must be held.
+func chanSide(c *hchan) *specialChanSide {
+	if s := c.side; s != nil {
+		return s
+	}
+	s := chanSideAlloc(c)
+	// Channel operations may test c.side without holding c.lock.
+	atomic.StorepNoWB(unsafe.Pointer(&c.side), unsafe.Pointer(s))
+	return s
+}
+
+// chanSideAlloc allocates side state for c and attaches it to c as a
+// special. c must have none.
+func chanSideAlloc(c *hchan) *specialChanSide {
+	lock(&mheap_.speciallock)
+	s := (*specialChanSide)(mheap_.specialChanSideAlloc.alloc())
+	unlock(&mheap_.speciallock)
+	s.special.kind = _KindSpecialChanSide
+	if !addspecial(unsafe.Pointer(c), &s.special) {
+		throw("chanSideAlloc: channel already has side state")
+	}
+	return s
+}
+
+// borrows returns the number of slots of c that cannot be reused yet
+// because of zero-copy receives; see chan_borrow.go. c.lock must be
+// held.
+func (c *hchan) borrows() uint {
+	if s := c.side; s != nil {
+		return s.borrows
+	}
+	return 0
+}
+
+// sets returns the first of the entries of the channel sets that c is
+// in, or nil. c.lock must be held.
+func (c *hchan) sets() *chanSetEntry {
+	if s := c.side; s != nil {
+		return s.sets
+	}
+	return nil
+}
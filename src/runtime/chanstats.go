@@ -0,0 +1,372 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Channel activity statistics.
+//
+// Counters are sharded per-P to keep the channel hot paths free of
+// contended cache lines. Each P's counters are only ever written by
+// goroutines running on that P with preemption disabled, so they are
+// updated with plain loads and stores. Readers load them atomically
+// while they may be changing, and so may see some of an operation's
+// counts and not others, or, on 32-bit platforms, a 64-bit counter
+// halfway through an update. The statistics are approximate anyway.
+//
+// Parking on a channel does not read the clock unless something has
+// asked how long goroutines wait; see chanWaitTimed.
+//
+// When a P is destroyed its counters are folded into chanStatsGlobal,
+// so the sum of chanStatsGlobal and every P in allp is always the
+// total for the process.
+
+import (
+	"runtime/internal/atomic"
+	_ "unsafe" // for go:linkname
+)
+
+// chanStats is a set of channel counters.
+//
+// Fields must be 8-byte aligned for atomic access on 32-bit
+// platforms; the struct is embedded in p right after other 64-bit
+// atomic fields for that reason.
+type chanStats struct {
+	created uint64 // channels created
+	sends   uint64 // values transferred into a channel
+	recvs   uint64 // values transferred out of a channel
+	closes  uint64 // channels closed
+
+	// Of closes, the channels closed with values still in their
+	// buffer, and the number of those values.
+	closesBuffered uint64
+	closedElems    uint64
+
+	// Number of goroutines currently parked in each kind of channel
+	// operation. A goroutine may park on one P and be woken on
+	// another, so an individual P's gauge may be negative; only
+	// the sum is meaningful.
+	blockedSend   int64
+	blockedRecv   int64
+	blockedSelect int64
+
+	// Of blockedSend and blockedRecv, the goroutines parked on a
+	// buffered channel, waiting for room in its buffer or for a value.
+	blockedSendFull  int64
+	blockedRecvEmpty int64
+
+	// Channel operations, including selects, by how they completed:
+	// without taking the channel lock, with the lock but without
+	// parking, or after parking. Failed non-blocking operations are
+	// not counted.
+	opsFast   uint64
+	opsLocked uint64
+	opsParked uint64
+
+	// waitTime is the cumulative wall-clock time, in nanoseconds,
+	// that goroutines spent parked on channel operations.
+	waitTime int64
+
+	// capacities counts the channels created by their buffer size;
+	// see chanCapBucket.
+	capacities [chanCapBuckets]uint64
+
+	// Gauges of the sudogs acquired and not yet released, and of
+	// those held in the per-P and central caches. Semaphores use
+	// sudogs too, and theirs are counted as well. Like the blocked
+	// gauges, only the sums are meaningful.
+	sudogsLive   int64
+	sudogsCached int64
+}
+
+// chanCapBuckets is the number of buckets of chanStats.capacities.
+const chanCapBuckets = 6
+
+// chanCapBucket returns the bucket of chanStats.capacities that
+// counts channels with a buffer of size elements. The buckets are
+// 0, 1, 2-8, 9-64, 65-1024, and more than 1024 elements.
+func chanCapBucket(size int) int {
+	switch {
+	case size <= 1:
+		return size
+	case size <= 8:
+		return 2
+	case size <= 64:
+		return 3
+	case size <= 1024:
+		return 4
+	}
+	return 5
+}
+
+// chanStatsGlobal holds the counters of destroyed Ps.
+var chanStatsGlobal chanStats
+
+// chanStatsNoP counts the values transferred by chansendready while
+// its caller had no P. The fields are updated atomically, and
+// readChanStatsTotal adds them to the total.
+var chanStatsNoP struct {
+	sends uint64
+	recvs uint64
+}
+
+// chanWaitTimed is set once the time goroutines wait on channels has
+// been asked for: by ReadChanStats, the /sync/chan/wait/total:seconds
+// metric, per-channel statistics (see chanperstats.go), or a profile
+// of goroutines (see GoroutineProfileEx). From then on, goroutines
+// parking on channels record when they parked. Waits that started
+// before are not counted.
+var chanWaitTimed uint32
+
+// chanWaitTime starts timing channel waits, unless they are timed
+// already.
+func chanWaitTime() {
+	if atomic.Load(&chanWaitTimed) == 0 {
+		atomic.Store(&chanWaitTimed, 1)
+	}
+}
+
+// chanStatsAcquire returns the current P's channel statistics.
+// Preemption is disabled until chanStatsRelease is called, which
+// guarantees that the P is not destroyed while its counters are
+// being updated.
+//
+//go:nosplit
+func chanStatsAcquire() (*m, *chanStats) {
+	mp := acquirem()
+	return mp, &mp.p.ptr().chanStats
+}
+
+//go:nosplit
+func chanStatsRelease(mp *m) {
+	releasem(mp)
+}
+
+// chanStatsCreated records the creation of a channel with a buffer
+// of size elements.
+func chanStatsCreated(size int) {
+	mp, s := chanStatsAcquire()
+	s.created++
+	s.capacities[chanCapBucket(size)]++
+	chanStatsRelease(mp)
+}
+
+// chanStatsClosed records the closing of a channel with buffered
+// values still in its buffer.
+func chanStatsClosed(buffered uint) {
+	mp, s := chanStatsAcquire()
+	s.closes++
+	if buffered != 0 {
+		s.closesBuffered++
+		s.closedElems += uint64(buffered)
+	}
+	chanStatsRelease(mp)
+}
+
+// chanStatsOp records the transfer of a value through a channel.
+func chanStatsOp(sends, recvs uint64) {
+	mp, s := chanStatsAcquire()
+	s.sends += sends
+	s.recvs += recvs
+	chanStatsRelease(mp)
+}
+
+// chanStatsReady records the transfer of values by chansendready.
+// Unlike the other functions here, it may be called without a P.
+//
+//go:nowritebarrierrec
+func chanStatsReady(sends, recvs uint64) {
+	mp := acquirem()
+	if pp := mp.p.ptr(); pp != nil {
+		pp.chanStats.sends += sends
+		pp.chanStats.recvs += recvs
+	} else {
+		atomic.Xadd64(&chanStatsNoP.sends, int64(sends))
+		atomic.Xadd64(&chanStatsNoP.recvs, int64(recvs))
+	}
+	releasem(mp)
+}
+
+// chanStatsImmediate records a channel operation that completed
+// without parking, having taken the channel lock, and the values it
+// transferred.
+func chanStatsImmediate(sends, recvs uint64) {
+	mp, s := chanStatsAcquire()
+	s.opsLocked++
+	s.sends += sends
+	s.recvs += recvs
+	chanStatsRelease(mp)
+}
+
+// chanStatsFast records a channel operation that completed without
+// taking the channel lock.
+func chanStatsFast() {
+	mp, s := chanStatsAcquire()
+	s.opsFast++
+	chanStatsRelease(mp)
+}
+
+// block adds delta to the gauges in s counting goroutines parked for
+// the given wait reason.
+func (s *chanStats) block(reason waitReason, delta int64) {
+	switch reason {
+	case waitReasonChanSendSync:
+		s.blockedSend += delta
+	case waitReasonChanSendFull:
+		s.blockedSend += delta
+		s.blockedSendFull += delta
+	case waitReasonChanReceiveSync:
+		s.blockedRecv += delta
+	case waitReasonChanReceiveEmpty:
+		s.blockedRecv += delta
+		s.blockedRecvEmpty += delta
+	case waitReasonSelect:
+		s.blockedSelect += delta
+	default:
+		throw("chanStats: bad wait reason")
+	}
+}
+
+// chanStatsPark records that the current goroutine is about to park
+// on a channel operation for reason, and returns the time of parking
+// to pass to chanStatsUnpark, or 0 if channel waits are not timed.
+// t0 is the time the operation started, if it was read for the block
+// profile, and 0 otherwise; if set, it stands in for the time of
+// parking rather than reading the clock again.
+func chanStatsPark(reason waitReason, t0 int64) int64 {
+	mp, s := chanStatsAcquire()
+	s.block(reason, 1)
+	chanStatsRelease(mp)
+	if gp := getg(); gp.lockedm != 0 {
+		chanLockedPark(gp)
+	}
+	if t0 != 0 {
+		return t0
+	}
+	if atomic.Load(&chanWaitTimed) == 0 && debug.chanblockwarn == 0 {
+		return 0
+	}
+	return nanotime()
+}
+
+// chanStatsUnpark records that the current goroutine, parked at time
+// t0 for reason, has been woken.
+func chanStatsUnpark(reason waitReason, t0 int64) {
+	var d int64
+	if t0 != 0 {
+		d = nanotime() - t0
+	}
+	if getg().lockedm != 0 {
+		chanLockedUnpark()
+	}
+	mp, s := chanStatsAcquire()
+	s.block(reason, -1)
+	s.opsParked++
+	if d > 0 {
+		s.waitTime += d
+	}
+	chanStatsRelease(mp)
+}
+
+// addTo adds the counters in s, read atomically, to dst. s may be
+// the counters of a running P, which are updated without atomics, so
+// the sums are only approximate.
+//
+// dst must not be concurrently accessed.
+func (s *chanStats) addTo(dst *chanStats) {
+	dst.created += atomic.Load64(&s.created)
+	dst.sends += atomic.Load64(&s.sends)
+	dst.recvs += atomic.Load64(&s.recvs)
+	dst.closes += atomic.Load64(&s.closes)
+	dst.closesBuffered += atomic.Load64(&s.closesBuffered)
+	dst.closedElems += atomic.Load64(&s.closedElems)
+	dst.blockedSend += atomic.Loadint64(&s.blockedSend)
+	dst.blockedRecv += atomic.Loadint64(&s.blockedRecv)
+	dst.blockedSelect += atomic.Loadint64(&s.blockedSelect)
+	dst.blockedSendFull += atomic.Loadint64(&s.blockedSendFull)
+	dst.blockedRecvEmpty += atomic.Loadint64(&s.blockedRecvEmpty)
+	dst.opsFast += atomic.Load64(&s.opsFast)
+	dst.opsLocked += atomic.Load64(&s.opsLocked)
+	dst.opsParked += atomic.Load64(&s.opsParked)
+	dst.waitTime += atomic.Loadint64(&s.waitTime)
+	dst.sudogsLive += atomic.Loadint64(&s.sudogsLive)
+	dst.sudogsCached += atomic.Loadint64(&s.sudogsCached)
+	for i := range s.capacities {
+		dst.capacities[i] += atomic.Load64(&s.capacities[i])
+	}
+}
+
+// flush folds the counters in s into chanStatsGlobal and clears s.
+//
+// The world must be stopped, so there are no concurrent readers of
+// chanStatsGlobal (see readChanStatsTotal).
+func (s *chanStats) flush() {
+	assertWorldStopped()
+	s.addTo(&chanStatsGlobal)
+	*s = chanStats{}
+}
+
+// readChanStatsTotal returns the process-wide channel statistics.
+//
+// It does not allocate and may be called from any goroutine.
+func readChanStatsTotal() chanStats {
+	var total chanStats
+	// Disable preemption so that procresize, which needs to stop
+	// the world, can't move counters from a P into chanStatsGlobal
+	// while we're summing them.
+	mp := acquirem()
+	chanStatsGlobal.addTo(&total)
+	total.sends += atomic.Load64(&chanStatsNoP.sends)
+	total.recvs += atomic.Load64(&chanStatsNoP.recvs)
+	for _, pp := range allp {
+		pp.chanStats.addTo(&total)
+	}
+	releasem(mp)
+	return total
+}
+
+// chanMisuse counts channel operations that end in a panic. They are
+// only updated on panic paths, so unlike chanStats they are not
+// sharded per-P.
+var chanMisuse struct {
+	closeBlockedSenders uint64 // closes that found a non-empty sendq
+	sendOnClosed        uint64 // sends on a closed channel
+}
+
+// chanMisuseSendOnClosed records a send on a closed channel. The
+// caller is about to panic.
+func chanMisuseSendOnClosed() {
+	atomic.Xadd64(&chanMisuse.sendOnClosed, 1)
+}
+
+// chanStatsSnapshot is a runtime copy of runtime/debug.ChanStats and
+// must be kept structurally identical to that type.
+type chanStatsSnapshot struct {
+	created       uint64
+	sends         uint64
+	recvs         uint64
+	closes        uint64
+	blockedSend   int
+	blockedRecv   int
+	blockedSelect int
+	blockedTime   int64
+
+	blockedSendFull  int
+	blockedRecvEmpty int
+}
+
+//go:linkname readChanStats runtime/debug.readChanStats
+func readChanStats(out *chanStatsSnapshot) {
+	chanWaitTime()
+	s := readChanStatsTotal()
+	out.created = s.created
+	out.sends = s.sends
+	out.recvs = s.recvs
+	out.closes = s.closes
+	out.blockedSend = int(s.blockedSend)
+	out.blockedRecv = int(s.blockedRecv)
+	out.blockedSelect = int(s.blockedSelect)
+	out.blockedTime = s.waitTime
+	out.blockedSendFull = int(s.blockedSendFull)
+	out.blockedRecvEmpty = int(s.blockedRecvEmpty)
+}
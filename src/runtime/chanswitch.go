@@ -0,0 +1,72 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Direct switches to goroutines woken by channel operations.
+//
+// A goroutine woken by a channel operation is put in the runnext slot
+// of the waker's P (see chanwake.go). When the waker then blocks, as
+// each side of an exchange over an unbuffered channel does right after
+// handing off its value, the P goes through schedule to find it again:
+// schedule checks for a stop-the-world, runs timers, looks for trace
+// and GC work and, now and then, at the global run queue, before it
+// takes runnext.
+//
+// With GODEBUG=chandirectswitch=1, chanready also records the woken
+// goroutine in p.chanHandoff, and when the waker blocks in park_m or
+// yields in Gosched, the P takes the goroutine out of runnext and runs
+// it at once, with the rest of the waker's time slice. The switch
+// falls back to schedule whenever schedule may have something to do
+// first: the world is stopping, the P has been asked to preempt its
+// goroutine or run a safe-point function, GC or tracing is active, or
+// either goroutine is locked to its thread. Involuntary preemption
+// always goes through schedule, so a pair of goroutines passing their
+// time slice back and forth is preempted like any other goroutine
+// running for too long.
+//
+// The woken goroutine stays in runnext, and the waker still wakes an
+// idle P for it, so if the waker keeps running instead, or enters a
+// system call and loses its P, the goroutine is run or stolen just as
+// it would be without the setting. A stale p.chanHandoff is harmless:
+// the switch happens only if the goroutine it names is still in
+// runnext.
+
+// chanHandoff records gp, which has just been put in the runnext slot
+// of the current P by chanready, as the goroutine to switch to if the
+// current goroutine blocks or yields.
+//
+//go:systemstack
+func chanHandoff(gp *g) {
+	getg().m.p.ptr().chanHandoff.set(gp)
+}
+
+// chanSwitch returns the goroutine recorded by chanHandoff on the
+// current P, taken out of its runnext slot, if the P may run it
+// without going through schedule; otherwise it returns nil. It is
+// called on g0 once the current goroutine has stopped running.
+func chanSwitch() *g {
+	_g_ := getg()
+	pp := _g_.m.p.ptr()
+	gp := pp.chanHandoff.ptr()
+	if gp == nil {
+		return nil
+	}
+	pp.chanHandoff = 0
+	if pp.preempt || sched.gcwaiting != 0 || pp.runSafePointFn != 0 ||
+		gcBlackenEnabled != 0 || trace.enabled || trace.shutdown ||
+		sched.disable.user || _g_.m.lockedg != 0 || gp.lockedm != 0 {
+		return nil
+	}
+	// Timers due on this P still run first. One that readies a
+	// goroutine takes over runnext, and then schedule runs that
+	// goroutine first, as it would without the switch.
+	checkTimers(pp, 0)
+	var old guintptr
+	old.set(gp)
+	if !pp.runnext.cas(old, 0) {
+		return nil
+	}
+	return gp
+}
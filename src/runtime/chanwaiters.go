@@ -0,0 +1,150 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Listing the goroutines blocked on a channel.
+//
+// runtime/debug.ChanWaiters lists the goroutines on the wait queues of
+// a channel with their stacks. The queues are walked with the channel
+// locked, but only the identities of the waiters are taken then:
+// formatting a stack takes too long to do with the lock held. Each
+// stack is formatted afterwards with its goroutine frozen in
+// _Gscanwaiting, as for a stack scan, if the goroutine is still waiting
+// on the channel; the goroutines woken in between are left out.
+
+import (
+	"runtime/internal/atomic"
+	_ "unsafe" // for go:linkname
+)
+
+// chanWaiterSnapshot is a runtime copy of runtime/debug.ChanWaiter and
+// must be kept structurally identical to that type.
+type chanWaiterSnapshot struct {
+	goid  int64
+	send  bool
+	sel   bool
+	wait  int64
+	stack []byte
+}
+
+// debug_chanWaiters describes the goroutines blocked on the channel ch,
+// which must hold a channel.
+//
+//go:linkname debug_chanWaiters runtime/debug.chanWaiters
+func debug_chanWaiters(ch interface{}) []chanWaiterSnapshot {
+	e := efaceOf(&ch)
+	if e._type == nil || e._type.kind&kindMask != kindChan {
+		panic(plainError("debug.ChanWaiters of a non-channel"))
+	}
+	c := (*hchan)(e.data)
+	if c == nil {
+		return nil
+	}
+
+	type waiter struct {
+		gp        *g
+		goid      int64
+		send, sel bool
+		since     int64
+	}
+	var ws []waiter
+	for {
+		lock(&c.lock)
+		n := c.recvq.len() + c.sendq.len()
+		if n <= cap(ws) {
+			ws = ws[:0]
+			for _, q := range [...]*waitq{&c.recvq, &c.sendq} {
+				for sg := q.first; sg != nil; sg = sg.next {
+					gp := sg.g
+					if (sg.isSelect() || sg.cancelable()) && atomic.Load(&gp.selectDone) != 0 {
+						// Already being woken.
+						continue
+					}
+					ws = append(ws, waiter{gp, gp.goid, q == &c.sendq, sg.isSelect(), gp.waitsince})
+				}
+			}
+			unlock(&c.lock)
+			break
+		}
+		unlock(&c.lock)
+		// Don't allocate with the lock held.
+		ws = make([]waiter, 0, n+n/4+1)
+	}
+
+	now := nanotime()
+	waiters := make([]chanWaiterSnapshot, 0, len(ws))
+	for _, w := range ws {
+		buf := make([]byte, 1024)
+		n := 0
+		for {
+			n = chanWaiterStack(w.gp, w.goid, c, buf)
+			if n < len(buf) {
+				break
+			}
+			buf = make([]byte, 2*len(buf))
+		}
+		if n < 0 {
+			continue
+		}
+		s := chanWaiterSnapshot{goid: w.goid, send: w.send, sel: w.sel, stack: buf[:n]}
+		if w.since != 0 && now > w.since {
+			s.wait = now - w.since
+		}
+		waiters = append(waiters, s)
+	}
+	return waiters
+}
+
+// chanWaiterStack formats the header and traceback of gp into buf, as
+// Stack does, if gp still runs the goroutine goid and that goroutine is
+// still waiting on c. It returns the number of bytes written, or -1 if
+// the goroutine is no longer waiting on c. A traceback that does not
+// fit is truncated at len(buf) bytes.
+func chanWaiterStack(gp *g, goid int64, c *hchan, buf []byte) (n int) {
+	n = -1
+	systemstack(func() {
+		// Keep gp from being woken and its stack from moving while
+		// we look at it. If it is being scanned, wait.
+		for {
+			if gp.goid != goid || readgstatus(gp)&^_Gscan != _Gwaiting {
+				return
+			}
+			if castogscanstatus(gp, _Gwaiting, _Gscanwaiting) {
+				break
+			}
+			procyield(10)
+		}
+		// gp was woken if one of its sudogs was, or if it is in a
+		// select or cancelable operation that another goroutine won.
+		waiting := gp.goid == goid && atomic.Load(&gp.selectDone) == 0
+		onc := false
+		for sg, i := gp.waiting, 0; waiting && sg != nil && i < 1<<16; sg, i = sg.waitlink, i+1 {
+			if sg.woken {
+				waiting = false
+			}
+			if sg.c == c {
+				onc = true
+			}
+		}
+		if waiting && onc {
+			g0 := getg()
+			g0.m.traceback = 1
+			g0.writebuf = buf[0:0:len(buf)]
+			// Not goroutineheader, which would show the scan bit.
+			print("goroutine ", gp.goid, " [", gp.waitreason.String(), "]:\n")
+			if gp.waitreason == waitReasonSelect {
+				printselectcases(gp)
+			} else {
+				printchanlabel(gp)
+			}
+			traceback(^uintptr(0), ^uintptr(0), 0, gp)
+			g0.m.traceback = 0
+			n = len(g0.writebuf)
+			g0.writebuf = nil
+		}
+		casfrom_Gscanstatus(gp, _Gscanwaiting, _Gwaiting)
+	})
+	return n
+}
@@ -0,0 +1,79 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Routing channel wakeups under heavy fan-in.
+//
+// A goroutine woken by a channel operation is put in the runnext slot
+// of the waker's P, so that a consumer runs soon after its producer and
+// on the same P. When hundreds of producers on different Ps feed one
+// consumer, this moves the consumer to whichever P sent last, and it
+// migrates on almost every receive.
+//
+// With GODEBUG=chanwakeglobal=N, once a channel has woken more than N
+// goroutines within chanWakeWindow, its further wakeups in that window
+// put the woken goroutine on the global run queue instead. The
+// consumer is then picked up by the next P that looks for work, which
+// is usually an idle one, rather than following every producer.
+//
+// The count is kept in the channel's side state (see chanside.go)
+// and touched only on the wake path, under c.lock. Channels created
+// while the setting was off have none, and always wake to runnext.
+
+// chanWakeWindow is the period, in nanoseconds, over which a channel's
+// wakeups are counted.
+const chanWakeWindow = 1e6
+
+// chanWakeGlobal counts a wakeup on c and reports whether the woken
+// goroutine should be put on the global run queue.
+// c.lock must be held.
+func chanWakeGlobal(c *hchan) bool {
+	d := c.side
+	if d == nil {
+		return false
+	}
+	now := nanotime()
+	if now-d.wakeStart >= chanWakeWindow {
+		d.wakeStart = now
+		d.wakes = 0
+	}
+	if d.wakes <= uint32(debug.chanwakeglobal) {
+		d.wakes++
+	}
+	return d.wakes > uint32(debug.chanwakeglobal)
+}
+
+// chanready readies gp, which was woken by an operation on a channel
+// for cause, one of traceUnblock*. If global is set, gp is put on the
+// global run queue; otherwise it is put in the runnext slot of the
+// current P, as goready does, and with GODEBUG=chandirectswitch=1,
+// recorded for a direct switch; see chanswitch.go.
+func chanready(gp *g, global bool, cause uint8, traceskip int) {
+	systemstack(func() {
+		if global {
+			readyglobal(gp, traceskip, cause)
+		} else {
+			readyCause(gp, traceskip, true, cause)
+			if debug.chandirectswitch > 0 {
+				chanHandoff(gp)
+			}
+		}
+	})
+}
+
+// chanreadyq readies the goroutines on q, which were woken by an
+// operation on a channel for cause, in order. The first is put in the
+// runnext slot of the current P, as chanready does, and the others at
+// the end of its run queue, so that the P runs them in the order they
+// are on q, unless they overflow its run queue or other Ps steal them.
+func chanreadyq(q *gQueue, cause uint8, traceskip int) {
+	for next := true; !q.empty(); next = false {
+		gp := q.pop()
+		gp.schedlink = 0
+		systemstack(func() {
+			readyCause(gp, traceskip, next, cause)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Detection of loops receiving from closed channels.
+//
+// A receive from a closed channel returns at once, so a loop meant to
+// exit once a channel is closed, but that misses the closed indication,
+// as in
+//
+//	for {
+//		select {
+//		case <-done:
+//		case v := <-work:
+//			...
+//		}
+//	}
+//
+// keeps a CPU busy receiving from it. With GODEBUG=closedrecvwarn=N,
+// each P counts, per call site, the receives that return because their
+// channel is closed, and the runtime prints a warning and the
+// goroutine's stack when a site makes more than N of them within a
+// second. Each site is reported at most once.
+//
+// The counts are kept in a small table in each P, indexed by a hash of
+// the PC of the call site, as for GODEBUG=selectspindetect (see
+// selectspin.go), and the same caveats apply: sites that collide evict
+// each other, a goroutine that moves to another P starts counting
+// again, and all calls to reflect.Select count as a single site.
+
+import "runtime/internal/atomic"
+
+const (
+	closedRecvSlots      = 16
+	closedRecvWindow     = 1e9 // nanoseconds over which receives are counted
+	maxClosedRecvReports = 64
+)
+
+// A closedRecvSlot counts the receives from closed channels at one
+// site.
+type closedRecvSlot struct {
+	pc    uintptr
+	n     uint32
+	start int64 // nanotime of the first receive counted in n
+}
+
+// closedRecvReported holds the PCs of the sites reported so far.
+// Entries are claimed with atomic compare-and-swap and never cleared.
+var closedRecvReported [maxClosedRecvReports]uintptr
+
+// closedRecv records that the receive called at pc returned because
+// its channel is closed.
+func closedRecv(pc uintptr) {
+	mp := acquirem()
+	s := &mp.p.ptr().closedRecv[(pc^pc>>5)%closedRecvSlots]
+	if s.pc != pc || s.n == 0 {
+		s.pc, s.n, s.start = pc, 1, nanotime()
+		releasem(mp)
+		return
+	}
+	s.n++
+	if s.n <= uint32(debug.closedrecvwarn) {
+		releasem(mp)
+		return
+	}
+	// Only look at the clock once the count is over the limit: if the
+	// window has passed, the rate is below the limit, and counting
+	// starts again.
+	n := s.n
+	elapsed := nanotime() - s.start
+	s.n = 0
+	releasem(mp)
+	if elapsed > closedRecvWindow || !closedRecvReport(pc) {
+		return
+	}
+
+	gp := getg()
+	print("runtime: ", n, " receives from a closed channel in ", elapsed/1e6, "ms at ")
+	printchanpc(pc)
+	print("goroutine ", gp.goid, " [running]:\n")
+	callerpc, sp := getcallerpc(), getcallersp()
+	systemstack(func() {
+		traceback(callerpc, sp, 0, gp)
+	})
+	print("\n")
+}
+
+// closedRecvReport reports whether the site at pc should be reported,
+// that is, whether it has not been reported before and the limit on
+// reports has not been reached.
+func closedRecvReport(pc uintptr) bool {
+	for i := range closedRecvReported {
+		p := &closedRecvReported[i]
+		v := atomic.Loaduintptr(p)
+		if v == 0 && atomic.Casuintptr(p, 0, pc) {
+			return true
+		}
+		if atomic.Loaduintptr(p) == pc {
+			return false
+		}
+	}
+	return false
+}
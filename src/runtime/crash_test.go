@@ -12,8 +12,10 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"runtime"
+	"runtime/metrics"
 	"strconv"
 	"strings"
 	"sync"
@@ -217,6 +219,27 @@ func TestGoexitDeadlock(t *testing.T) {
 	}
 }
 
+func TestDeadlockSummary(t *testing.T) {
+	// External linking brings in cgo, causing deadlock detection not working.
+	testenv.MustInternalLink(t)
+
+	output := runTestProg(t, "testprog", "DeadlockSummary")
+	want := "fatal error: all goroutines are asleep - deadlock!\n" +
+		"\n" +
+		"goroutines asleep: 10\n" +
+		"\tchan send: 3\n" +
+		"\tchan receive: 2\n" +
+		"\tselect: 2\n" +
+		"\tnil chan: 2\n" +
+		"\tother: 1\n" +
+		"\tother sync.Cond.Wait: 1\n" +
+		"\n" +
+		"goroutine 1 [chan receive (sync)]:\n"
+	if !strings.HasPrefix(output, want) {
+		t.Fatalf("output does not start with %q:\n%s", want, output)
+	}
+}
+
 func TestStackOverflow(t *testing.T) {
 	output := runTestProg(t, "testprog", "StackOverflow")
 	want := []string{
@@ -796,6 +819,584 @@ func TestG0StackOverflow(t *testing.T) {
 	runtime.G0StackOverflow()
 }
 
+func TestChanWaitingCorrupted(t *testing.T) {
+	if os.Getenv("TEST_CHAN_WAITING_CORRUPTED") != "1" {
+		cmd := testenv.CleanCmdEnv(exec.Command(os.Args[0], "-test.run=^TestChanWaitingCorrupted$"))
+		cmd.Env = append(cmd.Env, "TEST_CHAN_WAITING_CORRUPTED=1")
+		out, _ := cmd.CombinedOutput()
+		// Don't check err since it's expected to crash.
+		for _, want := range []string{
+			`(?m)^runtime: goroutine \d+ woken from chan (0x[0-9a-f]+): mysg=0x[0-9a-f]+ gp.waiting=0x[0-9a-f]+ in select=false$`,
+			`(?m)^\thchan 0x[0-9a-f]+: qcount=0 dataqsiz=0 .* closed=0 `,
+			`(?m)^\tmysg:\n\tsudog 0x[0-9a-f]+: g=0x[0-9a-f]+ c=0x[0-9a-f]+ `,
+			`(?m)^\tgp.waiting list:\n\tsudog 0x[0-9a-f]+: .* waitlink=0x[0-9a-f]+ .*\n\tsudog 0x[0-9a-f]+: .* waitlink=0x0 .*\nfatal error: G waiting list is corrupted\n`,
+		} {
+			if !regexp.MustCompile(want).MatchString(string(out)) {
+				t.Fatalf("output does not match %q:\n%s", want, out)
+			}
+		}
+		return
+	}
+
+	c, other := make(chan int), make(chan int)
+	go func() { <-c }()
+	for !runtime.ChanCorruptWaiting(c, other) {
+		runtime.Gosched()
+	}
+	c <- 1
+	time.Sleep(10 * time.Second) // The receiver crashes.
+}
+
+func TestChanInvariants(t *testing.T) {
+	if os.Getenv("TEST_CHAN_INVARIANTS") != "1" {
+		cmd := testenv.CleanCmdEnv(exec.Command(os.Args[0], "-test.run=^TestChanInvariants$"))
+		cmd.Env = append(cmd.Env, "TEST_CHAN_INVARIANTS=1", "GODEBUG=chaninvariants=1")
+		out, _ := cmd.CombinedOutput()
+		// Don't check err since it's expected to crash.
+		for _, want := range []string{
+			`(?m)^runtime: goroutine \d+ blocking on chan 0x[0-9a-f]+: wait queue holds a sudog of another channel at sudog 0x[0-9a-f]+$`,
+			`(?m)^fatal error: chan wait queue is corrupted$`,
+		} {
+			if !regexp.MustCompile(want).MatchString(string(out)) {
+				t.Fatalf("output does not match %q:\n%s", want, out)
+			}
+		}
+		return
+	}
+
+	// A well-formed queue passes the checks.
+	c, other := make(chan int), make(chan int)
+	done := make(chan bool)
+	for i := 0; i < 3; i++ {
+		go func() {
+			select {
+			case <-c:
+			case <-other:
+			}
+			done <- true
+		}()
+	}
+	for i := 0; i < 3; i++ {
+		c <- 1
+		<-done
+	}
+
+	go func() { <-c }()
+	for !runtime.ChanCorruptRecvq(c, other) {
+		runtime.Gosched()
+	}
+	<-c // Crashes.
+	t.Fatal("blocked on a corrupted wait queue")
+}
+
+// TestChanInvariantsDrained runs TestChanTryRecvAfterClose with
+// GODEBUG=chaninvariants=1, under which every receive that finds a
+// channel closed and drained without locking it checks that it is.
+func TestChanInvariantsDrained(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	cmd := testenv.CleanCmdEnv(exec.Command(os.Args[0], "-test.run=^TestChanTryRecvAfterClose$", "-test.short"))
+	cmd.Env = append(cmd.Env, "GODEBUG=chaninvariants=1")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+}
+
+func TestChanInvariantsOps(t *testing.T) {
+	if os.Getenv("TEST_CHAN_INVARIANTS_OPS") != "1" {
+		cmd := testenv.CleanCmdEnv(exec.Command(os.Args[0], "-test.run=^TestChanInvariantsOps$"))
+		cmd.Env = append(cmd.Env, "TEST_CHAN_INVARIANTS_OPS=1", "GODEBUG=chaninvariants=1")
+		out, _ := cmd.CombinedOutput()
+		// Don't check err since it's expected to crash.
+		for _, want := range []string{
+			`(?m)^runtime: goroutine \d+ leaving chan 0x[0-9a-f]+: sendx does not follow the values from recvx$`,
+			`(?m)^\thchan 0x[0-9a-f]+: qcount=3 dataqsiz=4 .* sendx=1 recvx=0 `,
+			`(?m)^fatal error: chan invariant violated$`,
+			`(?m)^runtime_test.TestChanInvariantsOps\(`,
+		} {
+			if !regexp.MustCompile(want).MatchString(string(out)) {
+				t.Fatalf("output does not match %q:\n%s", want, out)
+			}
+		}
+		return
+	}
+
+	// Operations that keep the invariants pass the checks: sends and
+	// receives, buffered and not, a select on both ends of a channel,
+	// zero-copy receives and a close that wakes the waiters.
+	c := make(chan int, 4)
+	for i := 0; i < 10; i++ {
+		c <- i
+		c <- i
+		<-c
+		<-c
+	}
+	c <- 1
+	_, commit, _ := reflect.ValueOf(c).RecvZeroCopy()
+	c <- 2
+	<-c
+	commit()
+	u := make(chan int)
+	done := make(chan bool)
+	go func() {
+		select {
+		case u <- 1:
+		case <-u:
+		}
+		done <- true
+	}()
+	u <- 1
+	<-done
+	for i := 0; i < 3; i++ {
+		go func() {
+			<-u
+			done <- true
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(u)
+	for i := 0; i < 3; i++ {
+		<-done
+	}
+
+	bad := make(chan int, 4)
+	runtime.ChanCorruptCount(bad, 2)
+	bad <- 1 // Crashes.
+	t.Fatal("sent on a channel with a corrupted count")
+}
+
+func TestSendOnClosedChannel(t *testing.T) {
+	output := runTestProg(t, "testprog", "SendOnClosedChannel", "GODEBUG=chanclosecheck=1")
+	for _, want := range []string{
+		"closed: *runtime.ClosedChannelError send on closed channel elem=int closedBy=1 closer=main.closeIntChan recent=true\n",
+		"woken: *runtime.ClosedChannelError send on closed channel elem=string closedBy=1 closer=main.closeStringChan recent=true\n",
+		"panic: send on closed channel\n\ngoroutine 1 [running]:\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Fatalf("output does not contain %q:\n%s", want, output)
+		}
+	}
+}
+
+func TestChanEnqueueTwice(t *testing.T) {
+	if os.Getenv("TEST_CHAN_ENQUEUE_TWICE") != "1" {
+		cmd := testenv.CleanCmdEnv(exec.Command(os.Args[0], "-test.run=^TestChanEnqueueTwice$"))
+		cmd.Env = append(cmd.Env, "TEST_CHAN_ENQUEUE_TWICE=1")
+		out, _ := cmd.CombinedOutput()
+		// Don't check err since it's expected to crash.
+		for _, want := range []string{
+			`(?m)^runtime: sudog 0x[0-9a-f]+ of goroutine \d+ added to recvq of chan 0x[0-9a-f]+ while already queued$`,
+			`(?m)^\tsudog 0x[0-9a-f]+: .* queued=true `,
+			`(?m)^\thchan 0x[0-9a-f]+: qcount=0 dataqsiz=0 `,
+			`(?m)^fatal error: sudog enqueued twice$`,
+			`(?m)^runtime_test.TestChanEnqueueTwice\(`,
+		} {
+			if !regexp.MustCompile(want).MatchString(string(out)) {
+				t.Fatalf("output does not match %q:\n%s", want, out)
+			}
+		}
+		return
+	}
+
+	// A sudog taken off a wait queue may be queued again.
+	c := make(chan int)
+	if !runtime.ChanRequeue(c) {
+		t.Fatal("sudog still marked queued after leaving the wait queue")
+	}
+
+	runtime.ChanEnqueueTwice(c) // Crashes.
+	t.Fatal("sudog enqueued twice without crashing")
+}
+
+func TestChanBlockWarn(t *testing.T) {
+	if os.Getenv("TEST_CHAN_BLOCK_WARN") != "1" {
+		cmd := testenv.CleanCmdEnv(exec.Command(os.Args[0], "-test.run=^TestChanBlockWarn$"))
+		cmd.Env = append(cmd.Env, "TEST_CHAN_BLOCK_WARN=1", "GODEBUG=chanblockwarn=1,chanregistry=1")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%v\n%s", err, out)
+		}
+		for _, want := range []string{
+			`(?m)^runtime: goroutine (\d+) has been blocked sending on 1 channel for [12]s\n\tsend on chan (0x[0-9a-f]+) \(chan int, len 0, cap 0\)\n\t\tlast received from by goroutine \d+ at runtime_test.TestChanBlockWarn \(.*crash_test.go:\d+\)\ngoroutine \d+ \[chan send \(sync\)\]:\n`,
+			`(?m)^chan 0x[0-9a-f]+ created at runtime_test.TestChanBlockWarn \(.*crash_test.go:\d+\)$`,
+		} {
+			if !regexp.MustCompile(want).MatchString(string(out)) {
+				t.Fatalf("output does not match %q:\n%s", want, out)
+			}
+		}
+		// The senders block at the same time, but one is reported
+		// per channel per second.
+		if n := strings.Count(string(out), "has been blocked"); n > 3 {
+			t.Errorf("%d goroutines reported, want at most 3:\n%s", n, out)
+		}
+		return
+	}
+
+	const senders = 8
+	c := make(chan int)
+	go func() { c <- 0 }()
+	<-c
+	for i := 0; i < senders; i++ {
+		go func() { c <- 1 }()
+	}
+	time.Sleep(2500 * time.Millisecond)
+	for i := 0; i < senders; i++ {
+		<-c
+	}
+}
+
+func TestChanExitReport(t *testing.T) {
+	testenv.MustHaveGoBuild(t)
+	exe, err := buildTestProg(t, "testprog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"ChanExitReport", "ChanExitReportOSExit"} {
+		for _, report := range []bool{false, true} {
+			cmd := testenv.CleanCmdEnv(exec.Command(exe, name))
+			if report {
+				cmd.Env = append(cmd.Env, "GODEBUG=chanexitreport=1")
+			}
+			out, err := cmd.CombinedOutput()
+			if err != nil {
+				t.Fatalf("%s: %v\n%s", name, err, out)
+			}
+			if !report {
+				if string(out) != "done\n" {
+					t.Errorf("%s without chanexitreport printed:\n%s", name, out)
+				}
+				continue
+			}
+			for _, want := range []string{
+				`^done\nruntime: 4 goroutines blocked on channels at exit\n\n3 goroutines \[chan receive \(sync\)\]:\n`,
+				`(?m)^\ton 3 distinct chans \(chan int\)\n(.*\n)*main\.chanExitWorker\(`,
+				`(?m)^1 goroutine \[chan send \(full\)\]:\n(.*\n)*\ton 1 chan: 0x[0-9a-f]+ \(chan string, len 1, cap 1\)\n(.*\n)*main\.chanExitSender\(`,
+			} {
+				if !regexp.MustCompile(want).MatchString(string(out)) {
+					t.Errorf("%s output does not match %q:\n%s", name, want, out)
+				}
+			}
+		}
+	}
+}
+
+func TestChanDropCheck(t *testing.T) {
+	if os.Getenv("TEST_CHAN_DROP_CHECK") != "1" {
+		cmd := testenv.CleanCmdEnv(exec.Command(os.Args[0], "-test.run=^TestChanDropCheck$"))
+		cmd.Env = append(cmd.Env, "TEST_CHAN_DROP_CHECK=1", "GODEBUG=chandropcheck=1,chanregistry=1")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%v\n%s", err, out)
+		}
+		want := `(?m)^runtime: chan 0x[0-9a-f]+ \(chan \*int, cap 4\) freed with 2 buffered values\n\tcreated at runtime_test.TestChanDropCheck \(.*crash_test.go:\d+\)$`
+		if !regexp.MustCompile(want).MatchString(string(out)) {
+			t.Fatalf("output does not match %q:\n%s", want, out)
+		}
+		if n := strings.Count(string(out), "freed with"); n != 1 {
+			t.Errorf("%d channels reported, want 1:\n%s", n, out)
+		}
+		return
+	}
+
+	samples := []metrics.Sample{{Name: "/sync/chan/dropped-with-data:channels"}}
+	metrics.Read(samples)
+	before := samples[0].Value.Uint64()
+
+	func() {
+		dropped := make(chan *int, 4)
+		dropped <- new(int)
+		dropped <- new(int)
+		drained := make(chan *int, 4)
+		drained <- new(int)
+		<-drained
+		runtime.KeepAlive(dropped)
+		runtime.KeepAlive(drained)
+	}()
+	runtime.GC()
+	runtime.GC()
+
+	metrics.Read(samples)
+	if got := samples[0].Value.Uint64(); got != before+1 {
+		t.Errorf("%s = %d, want %d", samples[0].Name, got, before+1)
+	}
+}
+
+func TestSelectSpinDetect(t *testing.T) {
+	if os.Getenv("TEST_SELECT_SPIN_DETECT") != "1" {
+		cmd := testenv.CleanCmdEnv(exec.Command(os.Args[0], "-test.run=^TestSelectSpinDetect$"))
+		cmd.Env = append(cmd.Env, "TEST_SELECT_SPIN_DETECT=1", "GODEBUG=selectspindetect=1")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%v\n%s", err, out)
+		}
+		want := `(?m)^runtime: nonblocking select took its default case \d+ times in a row in \d+ms at runtime_test.TestSelectSpinDetect \(.*crash_test.go:\d+\)\ngoroutine \d+ \[running\]:\n(.*\n)*runtime_test\.TestSelectSpinDetect\(`
+		if !regexp.MustCompile(want).MatchString(string(out)) {
+			t.Fatalf("output does not match %q:\n%s", want, out)
+		}
+		if n := strings.Count(string(out), "took its default case"); n != 2 {
+			t.Errorf("%d selects reported, want 2:\n%s", n, out)
+		}
+		return
+	}
+
+	c := make(chan int)
+	d := make(chan int)
+	// Spin on a single-case and a two-case select, each of which
+	// should be reported once.
+	for i := 0; i < 1e6; i++ {
+		select {
+		case <-c:
+		default:
+		}
+	}
+	for i := 0; i < 1e6; i++ {
+		select {
+		case <-c:
+		case <-d:
+		default:
+		}
+	}
+	// A poll that usually succeeds is not a spin.
+	e := make(chan int, 1)
+	for i := 0; i < 1e6; i++ {
+		if i%2 == 0 {
+			e <- i
+		}
+		select {
+		case <-e:
+		default:
+		}
+	}
+}
+
+func TestChanForbiddenContext(t *testing.T) {
+	if mode := os.Getenv("TEST_CHAN_FORBIDDEN"); mode != "" {
+		c := make(chan int)
+		switch mode {
+		case "systemstack":
+			runtime.ChanSendOnSystemStack(c, true)
+		case "worldstopped":
+			runtime.ChanTryRecvWorldStopped(c)
+		}
+		t.Fatalf("%s: channel operation did not throw", mode)
+	}
+
+	// A send that does not block is allowed on the system stack, where
+	// timers send on channels.
+	c := make(chan int, 1)
+	if !runtime.ChanSendOnSystemStack(c, false) || <-c != 1 {
+		t.Fatal("nonblocking send on the system stack failed")
+	}
+
+	for mode, want := range map[string]string{
+		"systemstack":  "runtime: channel send on the system stack",
+		"worldstopped": "runtime: channel receive with the world stopped",
+	} {
+		cmd := testenv.CleanCmdEnv(exec.Command(os.Args[0], "-test.run=^TestChanForbiddenContext$"))
+		cmd.Env = append(cmd.Env, "TEST_CHAN_FORBIDDEN="+mode)
+		out, _ := cmd.CombinedOutput()
+		// Don't check err since it's expected to crash.
+		for _, want := range []string{
+			"(?m)^" + want + "$",
+			"(?m)^fatal error: channel operation in forbidden context$",
+		} {
+			if !regexp.MustCompile(want).MatchString(string(out)) {
+				t.Errorf("%s: output does not match %q:\n%s", mode, want, out)
+			}
+		}
+	}
+}
+
+func TestClosedRecvWarn(t *testing.T) {
+	if os.Getenv("TEST_CLOSED_RECV_WARN") != "1" {
+		cmd := testenv.CleanCmdEnv(exec.Command(os.Args[0], "-test.run=^TestClosedRecvWarn$"))
+		cmd.Env = append(cmd.Env, "TEST_CLOSED_RECV_WARN=1", "GODEBUG=closedrecvwarn=1000")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%v\n%s", err, out)
+		}
+		want := `(?m)^runtime: \d+ receives from a closed channel in \d+ms at runtime_test.TestClosedRecvWarn \(.*crash_test.go:\d+\)\ngoroutine \d+ \[running\]:\n(.*\n)*runtime_test\.TestClosedRecvWarn\(`
+		if !regexp.MustCompile(want).MatchString(string(out)) {
+			t.Fatalf("output does not match %q:\n%s", want, out)
+		}
+		if n := strings.Count(string(out), "receives from a closed channel"); n != 3 {
+			t.Errorf("%d receives reported, want 3:\n%s", n, out)
+		}
+		return
+	}
+
+	done := make(chan int)
+	close(done)
+	other := make(chan int)
+	// A receive, a select with a default case and a select with two
+	// cases that keep finding done closed should each be reported
+	// once.
+	for i := 0; i < 1e5; i++ {
+		<-done
+	}
+	for i := 0; i < 1e5; i++ {
+		select {
+		case <-done:
+		default:
+		}
+	}
+	for i := 0; i < 1e5; i++ {
+		select {
+		case <-done:
+		case <-other:
+		}
+	}
+	// Receives that find values, and a few receives from a closed
+	// channel, are not.
+	c := make(chan int, 1)
+	for i := 0; i < 1e5; i++ {
+		c <- i
+		<-c
+	}
+	for i := 0; i < 10; i++ {
+		_, ok := <-done
+		if ok {
+			t.Fatal("received a value from a closed channel")
+		}
+	}
+}
+
+func TestChanCloseCheck(t *testing.T) {
+	if os.Getenv("TEST_CHAN_CLOSE_CHECK") != "1" {
+		cmd := testenv.CleanCmdEnv(exec.Command(os.Args[0], "-test.run=^TestChanCloseCheck$"))
+		cmd.Env = append(cmd.Env, "TEST_CHAN_CLOSE_CHECK=1", "GODEBUG=chanclosecheck=1")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%v\n%s", err, out)
+		}
+		for _, want := range []string{
+			`(?m)^runtime: close of chan 0x[0-9a-f]+ \(chan int, len 0, cap 0\) while it may still be sent on\n\tgoroutine \d+ is blocked sending\ngoroutine \d+ \[running\]:\n(.*\n)*runtime_test\.TestChanCloseCheck\(`,
+			`(?m)^runtime: close of chan 0x[0-9a-f]+ \(chan int, len 1, cap 4\) while it may still be sent on\n\tgoroutine \d+ sent \d+µs before the close at runtime_test\.TestChanCloseCheck\.func\d+ \(.*crash_test.go:\d+\)\ngoroutine \d+ \[running\]:\n(.*\n)*runtime_test\.TestChanCloseCheck\(`,
+		} {
+			if !regexp.MustCompile(want).MatchString(string(out)) {
+				t.Errorf("output does not match %q:\n%s", want, out)
+			}
+		}
+		if n := strings.Count(string(out), "while it may still be sent on"); n != 2 {
+			t.Errorf("%d closes reported, want 2:\n%s", n, out)
+		}
+		return
+	}
+
+	// A close with a sender blocked on the channel, which panics.
+	blocked := make(chan int)
+	panicked := make(chan bool)
+	go func() {
+		defer func() {
+			panicked <- recover() != nil
+		}()
+		blocked <- 1
+	}()
+	waitForStack(t, []string{"[chan send (sync)]:\nruntime_test.TestChanCloseCheck.func"})
+	close(blocked)
+	if !<-panicked {
+		t.Fatal("send on closed channel did not panic")
+	}
+
+	// A close right after another goroutine sent.
+	recent := make(chan int, 4)
+	sent := make(chan bool)
+	go func() {
+		recent <- 1
+		sent <- true
+	}()
+	<-sent
+	close(recent)
+
+	// A close by the goroutine that sent, and of a channel never
+	// sent on, are not reported.
+	own := make(chan int, 1)
+	own <- 1
+	close(own)
+	close(make(chan int))
+}
+
+func TestSchedTraceChanBlocked(t *testing.T) {
+	if os.Getenv("TEST_SCHEDTRACE_CHAN") != "1" {
+		cmd := testenv.CleanCmdEnv(exec.Command(os.Args[0], "-test.run=^TestSchedTraceChanBlocked$"))
+		cmd.Env = append(cmd.Env, "TEST_SCHEDTRACE_CHAN=1", "GODEBUG=schedtrace=10")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%v\n%s", err, out)
+		}
+		re := regexp.MustCompile(`(?m)^SCHED .*\] blockedsend=(\d+) blockedrecv=(\d+) blockedselect=(\d+) idlelockedchan=(\d+)$`)
+		// The testing package has goroutines of its own blocked on
+		// channels, so only check for at least the ones we blocked.
+		for _, m := range re.FindAllStringSubmatch(string(out), -1) {
+			send, _ := strconv.Atoi(m[1])
+			recv, _ := strconv.Atoi(m[2])
+			sel, _ := strconv.Atoi(m[3])
+			locked, _ := strconv.Atoi(m[4])
+			if send >= 3 && recv >= 5 && sel >= 2 && locked >= 1 {
+				return
+			}
+		}
+		t.Fatalf("no schedtrace line with at least 3 blocked sends, 5 receives, 2 selects and 1 idle locked thread:\n%s", out)
+	}
+
+	c, d := make(chan int), make(chan int)
+	for i := 0; i < 3; i++ {
+		go func() { c <- 1 }()
+	}
+	for i := 0; i < 4; i++ {
+		go func() { <-d }()
+	}
+	for i := 0; i < 2; i++ {
+		go func() {
+			select {
+			case <-d:
+			case <-make(chan int):
+			}
+		}()
+	}
+	go func() {
+		runtime.LockOSThread()
+		<-d
+	}()
+	for runtime.ChanWaiters(c) < 3 || runtime.ChanWaiters(d) < 7 {
+		runtime.Gosched()
+	}
+	time.Sleep(100 * time.Millisecond)
+}
+
+func TestLockedChanWarn(t *testing.T) {
+	if os.Getenv("TEST_LOCKED_CHAN_WARN") != "1" {
+		cmd := testenv.CleanCmdEnv(exec.Command(os.Args[0], "-test.run=^TestLockedChanWarn$"))
+		cmd.Env = append(cmd.Env, "TEST_LOCKED_CHAN_WARN=1", "GODEBUG=lockedchanwarn=2")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("%v\n%s", err, out)
+		}
+		want := `(?m)^runtime: 3 goroutines locked to their threads are blocked on channels, idling as many threads\ngoroutine \d+ \[running\]:\n(.*\n)*runtime_test\.TestLockedChanWarn\.func1\(`
+		if !regexp.MustCompile(want).MatchString(string(out)) {
+			t.Fatalf("output does not match %q:\n%s", want, out)
+		}
+		// The next warning is for more than 6.
+		if n := strings.Count(string(out), "goroutines locked to their threads"); n != 1 {
+			t.Errorf("%d warnings, want 1:\n%s", n, out)
+		}
+		return
+	}
+
+	c := make(chan int)
+	for i := 0; i < 5; i++ {
+		go func() {
+			runtime.LockOSThread()
+			<-c
+		}()
+	}
+	for runtime.ChanWaiters(c) < 5 {
+		runtime.Gosched()
+	}
+	close(c)
+}
+
 // Test that panic message is not clobbered.
 // See issue 30150.
 func TestDoublePanic(t *testing.T) {
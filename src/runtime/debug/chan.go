@@ -0,0 +1,144 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debug
+
+import (
+	"sort"
+	"time"
+)
+
+// ChanStats describes channel activity in the program.
+//
+// The counters are cumulative since the program started, except for
+// the Blocked fields, which report the number of goroutines blocked
+// at the time of the call.
+type ChanStats struct {
+	Created uint64 // number of channels created
+	Sends   uint64 // number of values sent on channels
+	Recvs   uint64 // number of values received from channels
+	Closes  uint64 // number of channels closed
+
+	BlockedSend   int // goroutines blocked sending on a channel
+	BlockedRecv   int // goroutines blocked receiving from a channel
+	BlockedSelect int // goroutines blocked in a select statement
+
+	// BlockedTime is the cumulative wall-clock time goroutines
+	// have spent blocked on channel operations, including select.
+	// Operations are only timed once ReadChanStats, the
+	// /sync/chan/wait/total:seconds metric of runtime/metrics or
+	// another source of channel wait times has first been read.
+	// BlockedTime leaves out the time spent blocked before then, so
+	// it is zero until that first read.
+	BlockedTime time.Duration
+
+	// Of BlockedSend and BlockedRecv, the goroutines blocked on a
+	// buffered channel: sending because its buffer is full, or
+	// receiving because it is empty. The others are blocked on an
+	// unbuffered channel, waiting for a goroutine to rendezvous with.
+	BlockedSendFull  int
+	BlockedRecvEmpty int
+}
+
+// ReadChanStats reads statistics about channel activity into stats.
+//
+// Receives that observe a closed channel do not count as Recvs, so
+// Sends minus Recvs is the number of values that were sent but never
+// received, such as values still sitting in channel buffers.
+//
+// The statistics are collected from per-processor counters without
+// stopping the world, so the snapshot is not atomic: counters updated
+// concurrently with the call may or may not be reflected.
+// ReadChanStats does not allocate.
+func ReadChanStats(stats *ChanStats) {
+	readChanStats(stats)
+}
+
+// ChanInfo describes a channel recorded in the channel registry.
+type ChanInfo struct {
+	Elem   string // element type
+	Cap    int    // buffer capacity
+	Len    int    // number of buffered elements
+	Closed bool   // whether the channel has been closed
+
+	// Goroutines blocked sending to and receiving from the channel,
+	// including those blocked in select statements.
+	Senders   int
+	Receivers int
+
+	Age        time.Duration // time since the channel was created
+	CreationPC uintptr       // return PC of the call that created the channel
+	Label      string        // label set with reflect.Value.SetChanLabel, if any
+}
+
+// DumpChannels returns a snapshot of the channel registry, which
+// records the channels created while the program runs with
+// GODEBUG=chanregistry=1. The channels are ordered from oldest to
+// newest. Channels that have become unreachable may be listed until
+// the garbage collector frees them. ok is false if the registry is
+// disabled.
+//
+// DumpChannels stops the world while it takes the snapshot.
+func DumpChannels() (chans []ChanInfo, ok bool) {
+	n, ok := readChannels(nil)
+	if !ok {
+		return nil, false
+	}
+	for {
+		// Leave room for channels created since the last call.
+		chans = make([]ChanInfo, n+10)
+		if n, _ = readChannels(chans); n <= len(chans) {
+			chans = chans[:n]
+			break
+		}
+	}
+	sort.SliceStable(chans, func(i, j int) bool {
+		return chans[i].Age > chans[j].Age
+	})
+	return chans, true
+}
+
+// SetChanBlockProfileRate sets the rate at which goroutines blocked
+// sending on, receiving from, or selecting over channels are sampled
+// in the blocking profile, in place of the rate set by
+// runtime.SetBlockProfileRate, which continues to apply to other
+// blocking events such as waits on a sync.Mutex. The rate is
+// interpreted as by runtime.SetBlockProfileRate: the profiler aims
+// to sample an average of one channel blocking event per rate
+// nanoseconds spent blocked, and rate = 1 includes every event.
+//
+// Passing rate <= 0 removes the channel rate, so that channel
+// blocking events are sampled at the rate set by
+// runtime.SetBlockProfileRate again.
+func SetChanBlockProfileRate(rate int) {
+	setChanBlockProfileRate(rate)
+}
+
+// A ChanWaiter describes a goroutine blocked on a channel.
+type ChanWaiter struct {
+	Goid   int64 // goroutine ID
+	Send   bool  // whether the goroutine is sending, rather than receiving
+	Select bool  // whether the goroutine is blocked in a select
+
+	Wait time.Duration // time the goroutine has been blocked
+
+	// Stack is the traceback of the goroutine, formatted as by
+	// Stack(true): a "goroutine N [reason]:" header line followed by
+	// the goroutine's frames.
+	Stack []byte
+}
+
+// ChanWaiters returns the goroutines blocked sending on or receiving
+// from the channel ch, receivers first, each in the order in which it
+// blocked. A goroutine blocked in a select is listed once for each of
+// its cases on ch, with Select set. ChanWaiters returns nil if ch is a
+// nil channel, and panics if ch is not a channel.
+//
+// The list is a snapshot taken with ch locked, so it is consistent for
+// the waiters of ch, but the stacks are formatted afterwards, without
+// the lock: a goroutine woken in between is left out, and the others
+// may be woken by the time ChanWaiters returns.
+func ChanWaiters(ch interface{}) []ChanWaiter {
+	return chanWaiters(ch)
+}
@@ -0,0 +1,441 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debug_test
+
+import (
+	"bytes"
+	"fmt"
+	"internal/testenv"
+	"os"
+	"os/exec"
+	"reflect"
+	"runtime"
+	. "runtime/debug"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReadChanStats(t *testing.T) {
+	var before, after ChanStats
+	ReadChanStats(&before)
+
+	const n = 10
+	c := make(chan int, n)
+	for i := 0; i < n; i++ {
+		c <- i
+	}
+	<-c
+	close(c)
+
+	// Unbuffered handoff, with the receiver parked first.
+	u := make(chan int)
+	done := make(chan bool)
+	go func() {
+		<-u
+		done <- true
+	}()
+	waitForBlocked(t, func(s *ChanStats) bool { return s.BlockedRecv > before.BlockedRecv })
+	u <- 1
+	<-done
+
+	ReadChanStats(&after)
+	if got := after.Created - before.Created; got < 3 {
+		t.Errorf("Created grew by %d, want >= 3", got)
+	}
+	if got := after.Closes - before.Closes; got < 1 {
+		t.Errorf("Closes grew by %d, want >= 1", got)
+	}
+	if got := after.Sends - before.Sends; got < n+2 {
+		t.Errorf("Sends grew by %d, want >= %d", got, n+2)
+	}
+	if got := after.Recvs - before.Recvs; got < 3 {
+		t.Errorf("Recvs grew by %d, want >= 3", got)
+	}
+	if after.Sends < after.Recvs {
+		t.Errorf("Sends = %d < Recvs = %d", after.Sends, after.Recvs)
+	}
+	if after.BlockedTime <= before.BlockedTime {
+		t.Errorf("BlockedTime did not grow: before %v, after %v", before.BlockedTime, after.BlockedTime)
+	}
+}
+
+func TestReadChanStatsBlocked(t *testing.T) {
+	var before ChanStats
+	ReadChanStats(&before)
+
+	const n = 5
+	send, recv, sel1, sel2 := make(chan int), make(chan int), make(chan int), make(chan int)
+	for i := 0; i < n; i++ {
+		go func() { send <- 1 }()
+		go func() { <-recv }()
+		go func() {
+			select {
+			case <-sel1:
+			case <-sel2:
+			}
+		}()
+	}
+	waitForBlocked(t, func(s *ChanStats) bool {
+		return s.BlockedSend-before.BlockedSend >= n &&
+			s.BlockedRecv-before.BlockedRecv >= n &&
+			s.BlockedSelect-before.BlockedSelect >= n
+	})
+
+	// Release everyone and check that the gauges drop back.
+	for i := 0; i < n; i++ {
+		<-send
+		recv <- 1
+	}
+	close(sel1)
+	waitForBlocked(t, func(s *ChanStats) bool {
+		return s.BlockedSend <= before.BlockedSend &&
+			s.BlockedRecv <= before.BlockedRecv &&
+			s.BlockedSelect <= before.BlockedSelect
+	})
+}
+
+func TestReadChanStatsBlockedFullEmpty(t *testing.T) {
+	var before ChanStats
+	ReadChanStats(&before)
+
+	const n = 5
+	full, empty, sync := make(chan int, 1), make(chan int, 1), make(chan int)
+	full <- 0
+	for i := 0; i < n; i++ {
+		go func() { full <- 1 }()
+		go func() { <-empty }()
+		go func() { <-sync }()
+	}
+	waitForBlocked(t, func(s *ChanStats) bool {
+		return s.BlockedSend-before.BlockedSend >= n &&
+			s.BlockedRecv-before.BlockedRecv >= 2*n &&
+			s.BlockedSendFull-before.BlockedSendFull >= n &&
+			s.BlockedRecvEmpty-before.BlockedRecvEmpty >= n
+	})
+	var s ChanStats
+	ReadChanStats(&s)
+	if s.BlockedSendFull > s.BlockedSend || s.BlockedRecvEmpty > s.BlockedRecv {
+		t.Errorf("full and empty gauges exceed the totals: %+v", s)
+	}
+
+	for i := 0; i < n; i++ {
+		<-full
+		empty <- 1
+		sync <- 1
+	}
+	waitForBlocked(t, func(s *ChanStats) bool {
+		return s.BlockedSendFull <= before.BlockedSendFull &&
+			s.BlockedRecvEmpty <= before.BlockedRecvEmpty
+	})
+}
+
+func TestReadChanStatsBlockedTime(t *testing.T) {
+	var before, after ChanStats
+	ReadChanStats(&before)
+
+	const (
+		n = 4
+		d = 50 * time.Millisecond
+	)
+	c := make(chan bool)
+	done := make(chan bool)
+	for i := 0; i < n; i++ {
+		go func() {
+			<-c
+			done <- true
+		}()
+	}
+	start := time.Now()
+	time.Sleep(d)
+	close(c)
+	for i := 0; i < n; i++ {
+		<-done
+	}
+	elapsed := time.Since(start)
+
+	ReadChanStats(&after)
+	// The parked receivers alone contribute close to n*d. The main
+	// goroutine also blocks on done, so only check a lower bound and
+	// a generous upper bound.
+	got := after.BlockedTime - before.BlockedTime
+	if got < n*d/2 {
+		t.Errorf("BlockedTime grew by %v, want at least %v", got, n*d/2)
+	}
+	if max := 2 * (n + 1) * elapsed; got > max {
+		t.Errorf("BlockedTime grew by %v, want at most %v", got, max)
+	}
+}
+
+func TestReadChanStatsAllocs(t *testing.T) {
+	var stats ChanStats
+	if n := testing.AllocsPerRun(100, func() { ReadChanStats(&stats) }); n != 0 {
+		t.Errorf("ReadChanStats allocated %v times, want 0", n)
+	}
+}
+
+func TestDumpChannels(t *testing.T) {
+	if os.Getenv("GO_TEST_DUMP_CHANNELS") == "" {
+		// The registry can only be enabled at startup,
+		// so run the test again in a child process.
+		if _, ok := DumpChannels(); ok {
+			t.Fatalf("DumpChannels: registry enabled without GODEBUG=chanregistry=1")
+		}
+		testenv.MustHaveExec(t)
+		cmd := testenv.CleanCmdEnv(exec.Command(os.Args[0], "-test.run=^TestDumpChannels$"))
+		cmd.Env = append(cmd.Env, "GO_TEST_DUMP_CHANNELS=1", "GODEBUG=chanregistry=1")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%v: %v\n%s", cmd, err, out)
+		}
+		return
+	}
+
+	var before ChanStats
+	ReadChanStats(&before)
+
+	buf := make(chan string, 3)
+	buf <- "a"
+	reflect.ValueOf(buf).SetChanLabel("buffer")
+	closed := make(chan struct{})
+	close(closed)
+	// A label can be removed again.
+	reflect.ValueOf(closed).SetChanLabel("closed")
+	reflect.ValueOf(closed).SetChanLabel("")
+	const n = 2
+	wait := make(chan int)
+	for i := 0; i < n; i++ {
+		go func() { wait <- 1 }()
+	}
+	waitForBlocked(t, func(s *ChanStats) bool { return s.BlockedSend-before.BlockedSend >= n })
+
+	chans, ok := DumpChannels()
+	if !ok {
+		t.Fatalf("DumpChannels: registry disabled with GODEBUG=chanregistry=1")
+	}
+	// Look only at the channels created by this test; the testing
+	// package creates channels of its own.
+	mine := make(map[string]ChanInfo)
+	for _, c := range chans {
+		frame, _ := runtime.CallersFrames([]uintptr{c.CreationPC}).Next()
+		if frame.Function == "runtime/debug_test.TestDumpChannels" {
+			mine[c.Elem] = c
+		}
+	}
+	if c, ok := mine["string"]; !ok || c.Cap != 3 || c.Len != 1 || c.Closed || c.Label != "buffer" {
+		t.Errorf("buffered channel: got %+v (found %v), want cap 3, len 1, open, label \"buffer\"", c, ok)
+	}
+	if c, ok := mine["struct {}"]; !ok || !c.Closed || c.Label != "" {
+		t.Errorf("closed channel: got %+v (found %v), want closed, no label", c, ok)
+	}
+	if c, ok := mine["int"]; !ok || c.Senders != n || c.Receivers != 0 || c.Age <= 0 {
+		t.Errorf("channel with blocked senders: got %+v (found %v), want %d senders, 0 receivers", c, ok, n)
+	}
+	for i := 0; i < n; i++ {
+		<-wait
+	}
+}
+
+// waitForBlocked polls ReadChanStats until cond holds or the test
+// has waited too long.
+func waitForBlocked(t *testing.T, cond func(*ChanStats) bool) {
+	t.Helper()
+	var s ChanStats
+	for i := 0; i < 1000; i++ {
+		ReadChanStats(&s)
+		if cond(&s) {
+			return
+		}
+		runtime.Gosched()
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for blocked goroutines; stats: %+v", s)
+}
+
+func TestSetChanBlockProfileRate(t *testing.T) {
+	runtime.SetBlockProfileRate(0)
+	SetChanBlockProfileRate(1)
+	defer SetChanBlockProfileRate(0)
+
+	// A channel receive that blocks.
+	c := make(chan int)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		c <- 1
+	}()
+	<-c
+
+	// Mutex contention, which is sampled at the global rate.
+	var mu sync.Mutex
+	mu.Lock()
+	done := make(chan bool)
+	go func() {
+		mu.Lock()
+		mu.Unlock()
+		done <- true
+	}()
+	time.Sleep(10 * time.Millisecond)
+	mu.Unlock()
+	<-done
+
+	var chanEvents, mutexEvents int
+	p := make([]runtime.BlockProfileRecord, 100)
+	n, ok := runtime.BlockProfile(p)
+	if !ok {
+		t.Fatalf("BlockProfile needs %d records", n)
+	}
+	for _, r := range p[:n] {
+		frames := runtime.CallersFrames(r.Stack())
+		for {
+			f, more := frames.Next()
+			switch {
+			case f.Function == "runtime.chanrecv1":
+				chanEvents++
+			case strings.HasPrefix(f.Function, "sync."):
+				mutexEvents++
+			}
+			if !more {
+				break
+			}
+		}
+	}
+	if chanEvents == 0 {
+		t.Errorf("no channel blocking events in the block profile")
+	}
+	if mutexEvents != 0 {
+		t.Errorf("%d mutex blocking events in the block profile, want 0", mutexEvents)
+	}
+}
+
+func chanWaitersRecv(c chan int) {
+	<-c
+}
+
+func chanWaitersSelect(c, d chan int) {
+	select {
+	case <-c:
+	case <-d:
+	}
+}
+
+func chanWaitersSend(c chan int) {
+	c <- 1
+}
+
+// waitForWaiters polls the waiters of c until there are n of them.
+func waitForWaiters(t *testing.T, c chan int, n int) []ChanWaiter {
+	t.Helper()
+	for i := 0; ; i++ {
+		ws := ChanWaiters(c)
+		if len(ws) == n {
+			return ws
+		}
+		if i >= 1000 {
+			t.Fatalf("channel has %d waiters, want %d", len(ws), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestChanWaiters(t *testing.T) {
+	if ws := ChanWaiters((chan int)(nil)); ws != nil {
+		t.Errorf("ChanWaiters(nil chan) = %v, want nil", ws)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("ChanWaiters(1) did not panic")
+			}
+		}()
+		ChanWaiters(1)
+	}()
+
+	c, d := make(chan int), make(chan int)
+	if ws := ChanWaiters(c); len(ws) != 0 {
+		t.Errorf("ChanWaiters of an idle channel = %v, want none", ws)
+	}
+	go chanWaitersRecv(c)
+	waitForWaiters(t, c, 1)
+	go chanWaitersSelect(c, d)
+	time.Sleep(10 * time.Millisecond)
+	ws := waitForWaiters(t, c, 2)
+	for i, w := range ws {
+		name, reason := "chanWaitersRecv", "chan receive (sync)"
+		if i == 1 {
+			name, reason = "chanWaitersSelect", "select"
+		}
+		header := fmt.Sprintf("goroutine %d [%s", w.Goid, reason)
+		if !bytes.HasPrefix(w.Stack, []byte(header)) || !bytes.Contains(w.Stack, []byte(name)) {
+			t.Errorf("waiter %d stack does not start with %q or lacks %s:\n%s", i, header, name, w.Stack)
+		}
+		if w.Send || w.Select != (i == 1) {
+			t.Errorf("waiter %d has Send %v, Select %v, want a receive in %s", i, w.Send, w.Select, name)
+		}
+		if w.Wait <= 0 || w.Wait > time.Minute {
+			t.Errorf("waiter %d has been waiting for %v", i, w.Wait)
+		}
+	}
+	if ws[1].Wait >= ws[0].Wait {
+		t.Errorf("select waiter has waited %v, longer than the receiver that blocked first, %v", ws[1].Wait, ws[0].Wait)
+	}
+	if dws := ChanWaiters(d); len(dws) != 1 || !dws[0].Select || dws[0].Goid != ws[1].Goid {
+		t.Errorf("waiters of the select's other channel are %+v, want goroutine %d", dws, ws[1].Goid)
+	}
+	c <- 1
+	c <- 2
+	waitForWaiters(t, c, 0)
+	if ws := ChanWaiters(d); len(ws) != 0 {
+		t.Errorf("select woken on another channel is still listed: %+v", ws)
+	}
+
+	b := make(chan int, 1)
+	b <- 0
+	go chanWaitersSend(b)
+	ws = waitForWaiters(t, b, 1)
+	if !ws[0].Send || ws[0].Select || !bytes.Contains(ws[0].Stack, []byte("chanWaitersSend")) {
+		t.Errorf("sender on a full channel listed as %+v:\n%s", ws[0], ws[0].Stack)
+	}
+	<-b
+	<-b
+}
+
+func TestChanWaitersConcurrent(t *testing.T) {
+	// Wake the waiters while ChanWaiters formats their stacks.
+	c, d := make(chan int), make(chan int)
+	done := make(chan bool)
+	const n = 4
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			for {
+				if i%2 == 0 {
+					select {
+					case c <- i:
+					case <-d:
+					case <-done:
+						return
+					}
+				} else {
+					select {
+					case <-c:
+					case <-done:
+						return
+					}
+				}
+			}
+		}(i)
+	}
+	end := time.Now().Add(100 * time.Millisecond)
+	if testing.Short() {
+		end = time.Now().Add(20 * time.Millisecond)
+	}
+	for time.Now().Before(end) {
+		for _, w := range ChanWaiters(c) {
+			if !bytes.HasPrefix(w.Stack, []byte(fmt.Sprintf("goroutine %d [", w.Goid))) {
+				t.Fatalf("waiter %d has stack:\n%s", w.Goid, w.Stack)
+			}
+		}
+	}
+	close(done)
+}
@@ -0,0 +1,125 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debug
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ChanDecision is a nondeterministic decision made by a channel
+// operation: which goroutine it woke, or which case a select
+// statement chose.
+//
+// A decision is identified by the channel or select statement where
+// it was made and by Seq, the number of decisions made there before
+// it. Channels are numbered from 1, in the order they are created
+// after recording or replay starts. Select statements are identified
+// by their offset in the program's text segment. The cases of a
+// select statement are indexed with the send cases first, in the
+// order they appear in the statement, followed by the receive cases
+// in reverse order.
+type ChanDecision struct {
+	Select bool   // whether the decision is a select statement's choice, rather than a wakeup
+	Key    uint64 // number of the channel, or offset of the select statement
+	Seq    uint64 // number of earlier decisions with the same Select and Key
+
+	Goid int64  // wakeup: ID of the goroutine woken
+	Seed uint32 // select: seed of the order in which the cases were polled
+	Case int    // select: index of the chosen case, or -1 for default
+}
+
+// String formats d the way a crashing program prints it, as a line
+// that ParseChanDecisions accepts.
+func (d ChanDecision) String() string {
+	if d.Select {
+		return fmt.Sprintf("select site=%#x seq=%d seed=%d case=%d", d.Key, d.Seq, d.Seed, d.Case)
+	}
+	return fmt.Sprintf("wake chan=%d seq=%d goroutine=%d", d.Key, d.Seq, d.Goid)
+}
+
+// RecordChanDecisions starts recording the last n channel decisions
+// in a ring buffer, discarding any decisions recorded so far. If n is
+// not positive, RecordChanDecisions stops recording. The recorded
+// decisions are returned by ChanDecisions, and printed if the program
+// crashes. Setting GODEBUG=chanrecord=n starts recording at startup.
+//
+// Starting recording or replay restarts the numbering of channels and
+// select decisions. To replay a recording, start the replay at the
+// point in the program where the recording was started.
+func RecordChanDecisions(n int) {
+	setChanRecording(n)
+}
+
+// ChanDecisions returns the recorded channel decisions, oldest first.
+func ChanDecisions() []ChanDecision {
+	n := readChanDecisions(nil)
+	for {
+		// Leave room for decisions made since the last call.
+		log := make([]ChanDecision, n+10)
+		if n = readChanDecisions(log); n <= len(log) {
+			return log[:n]
+		}
+	}
+}
+
+// ReplayChanDecisions starts replaying log, a set of decisions
+// recorded by an earlier run of the program. While replay is on, a
+// channel operation that finds the goroutine woken by the recorded
+// decision waiting wakes that goroutine, and a select statement polls
+// its cases in the recorded order, so it chooses the recorded case if
+// that case is ready. Replay is best effort: the recorded choices are
+// only reproduced as far as the program otherwise behaves as it did
+// in the recorded run. In particular, goroutine IDs must be assigned
+// in the same order, which is most likely with GOMAXPROCS=1.
+//
+// If log is nil, ReplayChanDecisions stops replay.
+func ReplayChanDecisions(log []ChanDecision) {
+	if log == nil {
+		setChanReplay(nil)
+		return
+	}
+	sorted := make([]ChanDecision, len(log))
+	copy(sorted, log)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := &sorted[i], &sorted[j]
+		if a.Select != b.Select {
+			return !a.Select
+		}
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		return a.Seq < b.Seq
+	})
+	setChanReplay(sorted)
+}
+
+// ParseChanDecisions parses the channel decisions printed by a program
+// that crashed while recording them, or formatted by
+// ChanDecision.String, one per line. Lines that do not describe a
+// decision are ignored, so data can be the program's whole output.
+func ParseChanDecisions(data []byte) ([]ChanDecision, error) {
+	var log []ChanDecision
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		var d ChanDecision
+		var err error
+		switch {
+		case strings.HasPrefix(line, "wake chan="):
+			_, err = fmt.Sscanf(line, "wake chan=%d seq=%d goroutine=%d", &d.Key, &d.Seq, &d.Goid)
+		case strings.HasPrefix(line, "select site="):
+			d.Select = true
+			_, err = fmt.Sscanf(line, "select site=%v seq=%d seed=%d case=%d", &d.Key, &d.Seq, &d.Seed, &d.Case)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: malformed channel decision %q: %v", i+1, line, err)
+		}
+		log = append(log, d)
+	}
+	return log, nil
+}
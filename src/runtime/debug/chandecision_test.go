@@ -0,0 +1,201 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debug_test
+
+import (
+	"bytes"
+	"internal/testenv"
+	"os"
+	"os/exec"
+	"reflect"
+	"runtime"
+	. "runtime/debug"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestReplaySelect(t *testing.T) {
+	// Both cases are always ready, so the poll order alone decides.
+	run := func() []int {
+		const n = 100
+		a, b := make(chan int, n), make(chan int, n)
+		for i := 0; i < n; i++ {
+			a <- i
+			b <- i
+		}
+		var chosen []int
+		for i := 0; i < n; i++ {
+			select {
+			case <-a:
+				chosen = append(chosen, 0)
+			case <-b:
+				chosen = append(chosen, 1)
+			}
+		}
+		return chosen
+	}
+
+	RecordChanDecisions(1000)
+	defer RecordChanDecisions(0)
+	want := run()
+	log := ChanDecisions()
+	var recorded []int
+	for _, d := range log {
+		if d.Select {
+			// Receive cases are numbered in reverse.
+			recorded = append(recorded, 1-d.Case)
+		}
+	}
+	if !reflect.DeepEqual(recorded, want) {
+		t.Fatalf("recorded cases %v, want %v", recorded, want)
+	}
+
+	ReplayChanDecisions(log)
+	defer ReplayChanDecisions(nil)
+	for i := 0; i < 3; i++ {
+		RecordChanDecisions(1000)
+		ReplayChanDecisions(log)
+		if got := run(); !reflect.DeepEqual(got, want) {
+			t.Fatalf("replay %d chose %v, want %v", i, got, want)
+		}
+		if got := ChanDecisions(); !reflect.DeepEqual(got, log) {
+			t.Fatalf("replay %d recorded %v, want %v", i, got, log)
+		}
+	}
+}
+
+func TestReplayWakeups(t *testing.T) {
+	const n = 3
+	var before ChanStats
+	ReadChanStats(&before)
+
+	RecordChanDecisions(100)
+	defer RecordChanDecisions(0)
+	c := make(chan int) // channel number 1
+	ids := make(chan int64, n)
+	type result struct {
+		goid  int64
+		value int
+	}
+	results := make(chan result, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			goid := curGoroutineID()
+			ids <- goid
+			results <- result{goid, <-c}
+		}()
+	}
+	var goids []int64
+	for i := 0; i < n; i++ {
+		goids = append(goids, <-ids)
+	}
+	waitForBlocked(t, func(s *ChanStats) bool { return s.BlockedRecv-before.BlockedRecv >= n })
+
+	// Wake the receivers in the reverse of the order they started in.
+	// Starting replay does not renumber c.
+	var log []ChanDecision
+	for i := 0; i < n; i++ {
+		log = append(log, ChanDecision{Key: 1, Seq: uint64(i), Goid: goids[n-1-i]})
+	}
+	ReplayChanDecisions(log)
+	defer ReplayChanDecisions(nil)
+	for i := 0; i < n; i++ {
+		c <- i
+	}
+	got := make(map[int64]int)
+	for i := 0; i < n; i++ {
+		r := <-results
+		got[r.goid] = r.value
+	}
+	for i, goid := range goids {
+		if want := n - 1 - i; got[goid] != want {
+			t.Errorf("goroutine %d received %d, want %d", goid, got[goid], want)
+		}
+	}
+	var recorded []ChanDecision
+	for _, d := range ChanDecisions() {
+		if !d.Select && d.Key == 1 {
+			recorded = append(recorded, d)
+		}
+	}
+	if !reflect.DeepEqual(recorded, log) {
+		t.Errorf("recorded %v, want %v", recorded, log)
+	}
+}
+
+func TestParseChanDecisions(t *testing.T) {
+	log := []ChanDecision{
+		{Key: 3, Seq: 0, Goid: 18},
+		{Select: true, Key: 0x4a1f2, Seq: 7, Seed: 1234567, Case: 1},
+		{Select: true, Key: 0x4a1f2, Seq: 8, Seed: 42, Case: -1},
+	}
+	var b bytes.Buffer
+	b.WriteString("panic: boom\n\nchannel decisions (oldest first):\n")
+	for _, d := range log {
+		b.WriteString("\t" + d.String() + "\n")
+	}
+	got, err := ParseChanDecisions(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, log) {
+		t.Errorf("ParseChanDecisions(%q) = %v, want %v", b.String(), got, log)
+	}
+
+	if _, err := ParseChanDecisions([]byte("wake chan=x seq=1 goroutine=2\n")); err == nil {
+		t.Errorf("ParseChanDecisions of a malformed line succeeded")
+	}
+}
+
+func TestChanDecisionsCrash(t *testing.T) {
+	if os.Getenv("GO_TEST_CHAN_DECISIONS_CRASH") != "" {
+		c, d := make(chan int), make(chan int)
+		go func() { c <- 1 }()
+		select {
+		case <-c:
+		case <-d:
+		}
+		panic("boom")
+	}
+	testenv.MustHaveExec(t)
+	cmd := testenv.CleanCmdEnv(exec.Command(os.Args[0], "-test.run=^TestChanDecisionsCrash$"))
+	cmd.Env = append(cmd.Env, "GO_TEST_CHAN_DECISIONS_CRASH=1", "GODEBUG=chanrecord=100")
+	out, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("%v: unexpected success\n%s", cmd, out)
+	}
+	if !bytes.Contains(out, []byte("\nchannel decisions (oldest first):\n")) {
+		t.Fatalf("crash output does not list channel decisions:\n%s", out)
+	}
+	log, err := ParseChanDecisions(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wakes, selects int
+	for _, d := range log {
+		if d.Select {
+			selects++
+		} else {
+			wakes++
+		}
+	}
+	if wakes == 0 || selects == 0 {
+		t.Errorf("crash output lists %d wakeups and %d selects, want some of each:\n%s", wakes, selects, out)
+	}
+}
+
+// curGoroutineID returns the ID of the calling goroutine.
+func curGoroutineID() int64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	// The first line is "goroutine <id> [running]:".
+	f := strings.Fields(string(buf))
+	id, err := strconv.ParseInt(f[1], 10, 64)
+	if err != nil {
+		panic("cannot parse goroutine ID from " + strconv.Quote(string(buf)))
+	}
+	return id
+}
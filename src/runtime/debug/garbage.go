@@ -160,7 +160,9 @@ func SetPanicOnFault(enabled bool) bool {
 // connected to a pipe or socket whose other end is in the same Go
 // process; instead, use a temporary file or network socket.
 //
-// The heap dump format is defined at https://golang.org/s/go15heapdump.
+// The heap dump format is defined at https://golang.org/s/go15heapdump,
+// extended with a record for each channel that gives its element type,
+// capacity, length, closed flag, and buffer; see runtime/heapdump.go.
 func WriteHeapDump(fd uintptr)
 
 // SetTraceback sets the amount of detail printed by the runtime in
@@ -0,0 +1,92 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debug
+
+import (
+	"time"
+	"unsafe"
+)
+
+// A Goroutine is a handle to a goroutine, with which other goroutines
+// can look at what it is doing. A goroutine gets a handle to itself
+// from CurrentGoroutine, typically as it starts, and hands it to the
+// code that supervises it.
+//
+// A handle keeps referring to its goroutine, and to no other, after the
+// goroutine exits. The zero Goroutine refers to no goroutine.
+type Goroutine struct {
+	g  unsafe.Pointer
+	id int64
+}
+
+// CurrentGoroutine returns a handle to the calling goroutine.
+func CurrentGoroutine() Goroutine {
+	g, id := currentGoroutine()
+	return Goroutine{g, id}
+}
+
+// GoroutineState describes what a goroutine is doing.
+type GoroutineState struct {
+	// Status is the status of the goroutine, as shown in tracebacks
+	// when it is not blocked: "running", "runnable", "syscall",
+	// "waiting", "copystack" or "preempted". It is "exited" once the
+	// goroutine has exited, and empty for the zero Goroutine.
+	Status string
+
+	// The following fields are only set if Status is "waiting".
+
+	// WaitReason is why the goroutine is blocked, as shown in its
+	// traceback, such as "chan receive (empty)", "select" or "sleep".
+	WaitReason string
+
+	// WaitSince is when the goroutine blocked. It is exact for
+	// goroutines that blocked on channels after the first call to
+	// Goroutine.State, WriteGoroutineDump or
+	// runtime.GoroutineProfileEx. For goroutines blocked on channels
+	// before, it is when such a call first found the goroutine
+	// blocked. For other goroutines, it is when the garbage collector
+	// first found the goroutine blocked. It is zero until then.
+	WaitSince time.Time
+
+	// Chan is the address of the channel the goroutine is blocked
+	// sending to or receiving from, and ChanElem is the type of the
+	// channel's elements. Chan is zero if the goroutine is not
+	// blocked on a single channel, including in a select.
+	Chan     uintptr
+	ChanElem string
+}
+
+// State returns the state of g.
+//
+// The state is a snapshot, taken without synchronizing with g, which
+// may have changed state by the time State returns: a goroutine
+// reported waiting may already have been woken, and one reported
+// running may since have blocked. The fields describing a wait are
+// consistent with each other, though: they all belong to the same
+// wait of g.
+func (g Goroutine) State() GoroutineState {
+	var s goroutineState
+	readGoroutineState(g.g, g.id, &s)
+	state := GoroutineState{
+		Status:     s.status,
+		WaitReason: s.waitReason,
+		Chan:       s.c,
+		ChanElem:   s.chanElem,
+	}
+	if s.waitNs > 0 {
+		state.WaitSince = time.Now().Add(-time.Duration(s.waitNs))
+	}
+	return state
+}
+
+// goroutineState is a copy of runtime.goroutineStateSnapshot and must
+// be kept structurally identical to that type.
+type goroutineState struct {
+	status     string
+	waitReason string
+	waitNs     int64
+	c          uintptr
+	chanElem   string
+}
@@ -0,0 +1,168 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debug_test
+
+import (
+	"reflect"
+	"runtime"
+	. "runtime/debug"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitForState polls the state of g until ok accepts it.
+func waitForState(t *testing.T, g Goroutine, ok func(s GoroutineState) bool) GoroutineState {
+	t.Helper()
+	for i := 0; ; i++ {
+		s := g.State()
+		if ok(s) {
+			return s
+		}
+		if i >= 1000 {
+			t.Fatalf("goroutine did not reach the expected state; last state %+v", s)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestGoroutineState(t *testing.T) {
+	if s := CurrentGoroutine().State(); s.Status != "running" || s.WaitReason != "" {
+		t.Errorf("current goroutine state is %+v, want running", s)
+	}
+	if s := (Goroutine{}).State(); s != (GoroutineState{}) {
+		t.Errorf("zero Goroutine state is %+v, want zero", s)
+	}
+
+	c := make(chan int)
+	handle := make(chan Goroutine)
+	var spin uint32 = 1
+	go func() {
+		handle <- CurrentGoroutine()
+		<-c
+		for atomic.LoadUint32(&spin) != 0 {
+		}
+		<-c
+	}()
+	g := <-handle
+
+	start := time.Now()
+	s := waitForState(t, g, func(s GoroutineState) bool { return s.Status == "waiting" })
+	if s.WaitReason != "chan receive (sync)" || s.Chan != reflect.ValueOf(c).Pointer() || s.ChanElem != "int" {
+		t.Errorf("blocked goroutine state is %+v, want a receive from %#x of int", s, reflect.ValueOf(c).Pointer())
+	}
+	if s.WaitSince.IsZero() || s.WaitSince.After(time.Now()) || s.WaitSince.Before(start.Add(-time.Second)) {
+		t.Errorf("blocked goroutine has WaitSince %v, want about %v", s.WaitSince, start)
+	}
+
+	// Running, or runnable if it lost its P.
+	c <- 1
+	waitForState(t, g, func(s GoroutineState) bool {
+		return (s.Status == "running" || s.Status == "runnable") && s.WaitReason == "" && s.Chan == 0
+	})
+
+	atomic.StoreUint32(&spin, 0)
+	waitForState(t, g, func(s GoroutineState) bool { return s.Status == "waiting" })
+	c <- 2
+	waitForState(t, g, func(s GoroutineState) bool { return s.Status == "exited" })
+
+	// The handle does not follow the g to the goroutines that reuse it.
+	done := make(chan bool)
+	for i := 0; i < 10; i++ {
+		go func() {
+			<-c
+			done <- true
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		if s := g.State(); s.Status != "exited" {
+			t.Fatalf("exited goroutine state is %+v", s)
+		}
+		c <- 0
+		<-done
+	}
+}
+
+// spin keeps the goroutine running for a while.
+//
+//go:noinline
+func spin(n int) int {
+	x := 0
+	for i := 0; i < n; i++ {
+		x += i
+	}
+	return x
+}
+
+// Check that State can be called on a goroutine that keeps changing
+// state, and that a reported wait is one the goroutine can be in.
+func TestGoroutineStateTransitions(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	a, b := make(chan int), make(chan string)
+	handle := make(chan Goroutine)
+	stop := make(chan bool)
+	go func() {
+		handle <- CurrentGoroutine()
+		for {
+			select {
+			case <-stop:
+				return
+			case v := <-a:
+				b <- "x"
+				if v == 0 {
+					time.Sleep(time.Microsecond)
+				}
+				spin(v * 1000)
+			}
+		}
+	}()
+	g := <-handle
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case a <- i % 10:
+			case <-stop:
+				return
+			}
+			spin(i % 10 * 1000)
+			<-b
+		}
+	}()
+
+	d := 200 * time.Millisecond
+	if testing.Short() {
+		d = 20 * time.Millisecond
+	}
+	seen := make(map[string]bool)
+	for start := time.Now(); time.Since(start) < d; {
+		s := g.State()
+		seen[s.Status+" "+s.WaitReason] = true
+		switch s.Status {
+		case "running", "runnable", "syscall", "preempted", "copystack":
+			if s.WaitReason != "" || s.Chan != 0 || !s.WaitSince.IsZero() {
+				t.Fatalf("state %+v has wait details", s)
+			}
+		case "waiting":
+			switch s.WaitReason {
+			case "select":
+				// Goroutines in select have no single channel.
+				if s.Chan != 0 || s.WaitSince.IsZero() {
+					t.Fatalf("state %+v", s)
+				}
+			case "chan send (sync)":
+				if s.Chan != reflect.ValueOf(b).Pointer() || s.ChanElem != "string" || s.WaitSince.IsZero() {
+					t.Fatalf("state %+v is not a send on b", s)
+				}
+			case "sleep":
+			default:
+				t.Fatalf("state %+v has an unexpected wait reason", s)
+			}
+		default:
+			t.Fatalf("state %+v has an unexpected status", s)
+		}
+	}
+	close(stop)
+	t.Logf("states seen: %v", seen)
+}
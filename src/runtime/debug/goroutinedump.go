@@ -0,0 +1,233 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debug
+
+import (
+	"errors"
+	"io"
+	"runtime"
+	"strconv"
+	"unicode/utf8"
+)
+
+// A DumpFormat is a format of the goroutine dumps written by
+// WriteGoroutineDump.
+type DumpFormat int
+
+const (
+	// DumpText formats a dump as the tracebacks printed when a
+	// program crashes, as runtime.Stack does.
+	DumpText DumpFormat = iota
+
+	// DumpJSON formats a dump as a JSON object, described at
+	// WriteGoroutineDump.
+	DumpJSON
+)
+
+// WriteGoroutineDump writes a dump of all goroutines to w in the given
+// format.
+//
+// With DumpJSON, the dump is a JSON object whose "goroutines" member
+// is an array of objects, one for each goroutine, the calling goroutine
+// first. Each has the following members, leaving out those that do not
+// apply:
+//
+//	id           the goroutine ID
+//	status       the goroutine's status, as GoroutineState.Status reports it
+//	wait_reason  why a waiting goroutine is blocked, as shown in its traceback
+//	wait_ns      how long a waiting goroutine has been blocked, in
+//	             nanoseconds, measured as for GoroutineState.WaitSince
+//	chan         the channel a goroutine is blocked sending to or receiving
+//	             from: an object with its address "addr", a hexadecimal
+//	             string, its element type "elem", its "cap" and "len", and
+//	             "dir", which is "send" or "recv"; goroutines blocked in a
+//	             select have none
+//	frames       the goroutine's stack, innermost frame first, as objects
+//	             with members "func", "file", "line" and "pc", the program
+//	             counter as a hexadecimal string
+//	truncated    true if the stack was cut short after 100 frames
+//
+// The goroutines are described with the world stopped, so they all
+// describe the same point in time, and the dump is encoded as it is
+// written, so that writing it allocates little besides the snapshot.
+func WriteGoroutineDump(w io.Writer, format DumpFormat) error {
+	switch format {
+	case DumpText:
+		buf := make([]byte, 64<<10)
+		for {
+			n := runtime.Stack(buf, true)
+			if n < len(buf) {
+				_, err := w.Write(buf[:n])
+				return err
+			}
+			buf = make([]byte, 2*len(buf))
+		}
+	case DumpJSON:
+		return writeGoroutineDumpJSON(w)
+	}
+	return errors.New("debug: unknown goroutine dump format " + strconv.Itoa(int(format)))
+}
+
+// goroutineRecord is a copy of runtime.goroutineDumpSnapshot and must
+// be kept structurally identical to that type.
+type goroutineRecord struct {
+	goid       int64
+	status     string
+	waitReason string
+	waitNs     int64
+	c          uintptr
+	chanElem   string
+	chanCap    int
+	chanLen    int
+	chanSend   bool
+	stack      int // index of the first PC of the stack in pcs
+	nstack     int
+	truncated  bool
+}
+
+func writeGoroutineDumpJSON(w io.Writer) error {
+	p := make([]goroutineRecord, runtime.NumGoroutine()+10)
+	pcs := make([]uintptr, 32*len(p))
+	for {
+		n, npcs := readGoroutineDump(p, pcs)
+		if n <= len(p) && npcs <= len(pcs) {
+			p = p[:n]
+			break
+		}
+		if n > len(p) {
+			p = make([]goroutineRecord, n+n/4+10)
+		}
+		if npcs > len(pcs) {
+			pcs = make([]uintptr, npcs+npcs/4)
+		}
+	}
+
+	e := &dumpEncoder{w: w, buf: make([]byte, 0, 8<<10)}
+	e.str(`{"goroutines":[`)
+	for i := range p {
+		r := &p[i]
+		if i > 0 {
+			e.str(",")
+		}
+		e.str("\n{\"id\":")
+		e.buf = strconv.AppendInt(e.buf, r.goid, 10)
+		e.str(`,"status":`)
+		e.quote(r.status)
+		if r.waitReason != "" {
+			e.str(`,"wait_reason":`)
+			e.quote(r.waitReason)
+		}
+		if r.waitNs != 0 {
+			e.str(`,"wait_ns":`)
+			e.buf = strconv.AppendInt(e.buf, r.waitNs, 10)
+		}
+		if r.c != 0 {
+			e.str(`,"chan":{"addr":`)
+			e.hex(uint64(r.c))
+			e.str(`,"elem":`)
+			e.quote(r.chanElem)
+			e.str(`,"cap":`)
+			e.buf = strconv.AppendInt(e.buf, int64(r.chanCap), 10)
+			e.str(`,"len":`)
+			e.buf = strconv.AppendInt(e.buf, int64(r.chanLen), 10)
+			if r.chanSend {
+				e.str(`,"dir":"send"}`)
+			} else {
+				e.str(`,"dir":"recv"}`)
+			}
+		}
+		e.str(`,"frames":[`)
+		if r.nstack > 0 {
+			frames := runtime.CallersFrames(pcs[r.stack : r.stack+r.nstack])
+			for j := 0; ; j++ {
+				f, more := frames.Next()
+				if j > 0 {
+					e.str(",")
+				}
+				e.str(`{"func":`)
+				e.quote(f.Function)
+				e.str(`,"file":`)
+				e.quote(f.File)
+				e.str(`,"line":`)
+				e.buf = strconv.AppendInt(e.buf, int64(f.Line), 10)
+				e.str(`,"pc":`)
+				e.hex(uint64(f.PC))
+				e.str("}")
+				if !more {
+					break
+				}
+			}
+		}
+		e.str("]")
+		if r.truncated {
+			e.str(`,"truncated":true`)
+		}
+		e.str("}")
+		e.flush(false)
+	}
+	e.str("\n]}\n")
+	e.flush(true)
+	return e.err
+}
+
+// A dumpEncoder writes JSON through a buffer that it flushes to w
+// once it is large enough.
+type dumpEncoder struct {
+	w   io.Writer
+	buf []byte
+	err error
+}
+
+func (e *dumpEncoder) str(s string) {
+	e.buf = append(e.buf, s...)
+}
+
+// hex appends v as a JSON string holding its hexadecimal form.
+func (e *dumpEncoder) hex(v uint64) {
+	e.buf = append(e.buf, `"0x`...)
+	e.buf = strconv.AppendUint(e.buf, v, 16)
+	e.buf = append(e.buf, '"')
+}
+
+// quote appends s as a JSON string.
+func (e *dumpEncoder) quote(s string) {
+	const hexDigits = "0123456789abcdef"
+	e.buf = append(e.buf, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\':
+				e.buf = append(e.buf, '\\', c)
+			case c < 0x20:
+				e.buf = append(e.buf, '\\', 'u', '0', '0', hexDigits[c>>4], hexDigits[c&0xf])
+			default:
+				e.buf = append(e.buf, c)
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			e.buf = append(e.buf, `\ufffd`...)
+		} else {
+			e.buf = append(e.buf, s[i:i+size]...)
+		}
+		i += size
+	}
+	e.buf = append(e.buf, '"')
+}
+
+// flush writes the buffer to w if it is large enough, or if all is set.
+// After an error, it discards the buffer.
+func (e *dumpEncoder) flush(all bool) {
+	if !all && len(e.buf) < cap(e.buf)/2 {
+		return
+	}
+	if e.err == nil {
+		_, e.err = e.w.Write(e.buf)
+	}
+	e.buf = e.buf[:0]
+}
@@ -0,0 +1,215 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package debug_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	. "runtime/debug"
+	"strings"
+	"testing"
+	"time"
+)
+
+type jsonChan struct {
+	Addr string
+	Elem string
+	Cap  int
+	Len  int
+	Dir  string
+}
+
+type jsonFrame struct {
+	Func string
+	File string
+	Line int
+	PC   string
+}
+
+type jsonGoroutine struct {
+	ID         int64
+	Status     string
+	WaitReason string `json:"wait_reason"`
+	WaitNs     int64  `json:"wait_ns"`
+	Chan       *jsonChan
+	Frames     []jsonFrame
+	Truncated  bool
+}
+
+type jsonDump struct {
+	Goroutines []jsonGoroutine
+}
+
+type tagged struct {
+	X int
+}
+
+//go:noinline
+func dumpRecvWaiter(c chan tagged) {
+	<-c
+}
+
+//go:noinline
+func dumpSendWaiter(c chan int) {
+	c <- 3
+}
+
+//go:noinline
+func dumpSelectWaiter(c chan tagged, d chan int) {
+	select {
+	case <-c:
+	case <-d:
+	}
+}
+
+func readDump(t *testing.T) jsonDump {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteGoroutineDump(&buf, DumpJSON); err != nil {
+		t.Fatal(err)
+	}
+	var d jsonDump
+	dec := json.NewDecoder(&buf)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&d); err != nil {
+		t.Fatalf("decoding dump: %v\n%s", err, buf.Bytes())
+	}
+	return d
+}
+
+// findDump returns the goroutine in d whose stack includes the
+// function fn.
+func findDump(d jsonDump, fn string) *jsonGoroutine {
+	for i := range d.Goroutines {
+		for _, f := range d.Goroutines[i].Frames {
+			if f.Func == fn {
+				return &d.Goroutines[i]
+			}
+		}
+	}
+	return nil
+}
+
+func TestWriteGoroutineDumpJSON(t *testing.T) {
+	recv := make(chan tagged)
+	send := make(chan int, 2)
+	send <- 1
+	send <- 2
+	sel := make(chan int)
+	for _, f := range []func(){
+		func() { dumpRecvWaiter(recv) },
+		func() { dumpSendWaiter(send) },
+		func() { dumpSelectWaiter(recv, sel) },
+	} {
+		go f()
+	}
+	defer func() {
+		close(recv)
+		<-send
+	}()
+
+	const pkg = "runtime/debug_test."
+	var d jsonDump
+	for i := 0; ; i++ {
+		d = readDump(t)
+		ready := true
+		for _, fn := range []string{"dumpRecvWaiter", "dumpSendWaiter", "dumpSelectWaiter"} {
+			g := findDump(d, pkg+fn)
+			if g == nil || g.Status != "waiting" {
+				ready = false
+			}
+		}
+		if ready {
+			break
+		}
+		if i >= 1000 {
+			t.Fatalf("goroutines did not block")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if len(d.Goroutines) == 0 || findDump(d, pkg+"readDump") != &d.Goroutines[0] {
+		t.Errorf("calling goroutine is not first in the dump")
+	} else if g := d.Goroutines[0]; g.Status != "running" || g.WaitReason != "" || g.Chan != nil {
+		t.Errorf("calling goroutine is %+v, want running", g)
+	}
+	for _, g := range d.Goroutines {
+		if len(g.Frames) == 0 && g.Status != "running" {
+			t.Errorf("goroutine %d has no frames", g.ID)
+		}
+		for _, f := range g.Frames {
+			if f.Func == "" || f.File == "" || f.Line == 0 || !strings.HasPrefix(f.PC, "0x") {
+				t.Errorf("goroutine %d has frame %+v", g.ID, f)
+			}
+		}
+	}
+
+	addr := func(c interface{}) string {
+		return fmt.Sprintf("%#x", reflect.ValueOf(c).Pointer())
+	}
+	for _, tt := range []struct {
+		fn     string
+		reason string
+		c      *jsonChan
+	}{
+		{"dumpRecvWaiter", "chan receive (sync)", &jsonChan{addr(recv), "debug_test.tagged", 0, 0, "recv"}},
+		{"dumpSendWaiter", "chan send (full)", &jsonChan{addr(send), "int", 2, 2, "send"}},
+		{"dumpSelectWaiter", "select", nil},
+	} {
+		g := findDump(d, pkg+tt.fn)
+		if g.WaitReason != tt.reason || g.WaitNs < 0 {
+			t.Errorf("%s goroutine is %+v, want waiting with reason %q", tt.fn, g, tt.reason)
+		}
+		if !reflect.DeepEqual(g.Chan, tt.c) {
+			t.Errorf("%s goroutine has chan %+v, want %+v", tt.fn, g.Chan, tt.c)
+		}
+	}
+}
+
+func TestWriteGoroutineDumpElemEscape(t *testing.T) {
+	c := make(chan struct {
+		X int `note:"a \"quoted\"\tvalue\\"`
+	})
+	go func() { dumpAnonWaiter(c) }()
+	defer close(c)
+
+	const fn = "runtime/debug_test.dumpAnonWaiter"
+	want := reflect.TypeOf(c).Elem().String() // needs escaping in JSON
+	for i := 0; ; i++ {
+		g := findDump(readDump(t), fn)
+		if g != nil && g.Chan != nil {
+			if g.Chan.Elem != want {
+				t.Errorf("elem is %q, want %q", g.Chan.Elem, want)
+			}
+			break
+		}
+		if i >= 1000 {
+			t.Fatalf("goroutine did not block")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+//go:noinline
+func dumpAnonWaiter(c chan struct {
+	X int `note:"a \"quoted\"\tvalue\\"`
+}) {
+	<-c
+}
+
+func TestWriteGoroutineDumpText(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteGoroutineDump(&buf, DumpText); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "goroutine ") || !strings.Contains(buf.String(), "TestWriteGoroutineDumpText") {
+		t.Errorf("text dump does not hold the tracebacks:\n%s", buf.Bytes())
+	}
+	if err := WriteGoroutineDump(&buf, DumpFormat(-1)); err == nil {
+		t.Errorf("unknown format did not fail")
+	}
+}
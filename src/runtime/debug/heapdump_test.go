@@ -5,7 +5,12 @@
 package debug_test
 
 import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
 	"os"
+	"reflect"
 	"runtime"
 	. "runtime/debug"
 	"testing"
@@ -67,3 +72,193 @@ func TestWriteHeapDumpFinalizers(t *testing.T) {
 	WriteHeapDump(f.Fd())
 	println("done dump")
 }
+
+// A dumpChan is a channel record of a heap dump.
+type dumpChan struct {
+	elem     string
+	cap, len uint64
+	closed   bool
+	buf      uint64
+}
+
+// readHeapDumpChans parses a heap dump and returns its channel records
+// by channel address.
+func readHeapDumpChans(r io.Reader) (map[uint64]dumpChan, error) {
+	br := bufio.NewReader(r)
+	hdr, err := br.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if hdr != "go1.17 heap dump\n" {
+		return nil, fmt.Errorf("bad header %q", hdr)
+	}
+	var rerr error
+	num := func() uint64 {
+		v, err := binary.ReadUvarint(br)
+		if err != nil && rerr == nil {
+			rerr = err
+		}
+		return v
+	}
+	bytes := func() {
+		n := num()
+		if _, err := br.Discard(int(n)); err != nil && rerr == nil {
+			rerr = err
+		}
+	}
+	str := func() string {
+		b := make([]byte, num())
+		if _, err := io.ReadFull(br, b); err != nil && rerr == nil {
+			rerr = err
+		}
+		return string(b)
+	}
+	nums := func(n int) {
+		for i := 0; i < n; i++ {
+			num()
+		}
+	}
+	fields := func() {
+		for rerr == nil && num() != 0 {
+			num()
+		}
+	}
+
+	types := make(map[uint64]string)
+	chans := make(map[uint64]dumpChan)
+	elems := make(map[uint64]uint64)
+	for rerr == nil {
+		switch tag := num(); tag {
+		case 0: // EOF
+			for addr, c := range chans {
+				c.elem = types[elems[addr]]
+				chans[addr] = c
+			}
+			return chans, nil
+		case 1: // object
+			num()
+			bytes()
+			fields()
+		case 2: // other root
+			str()
+			num()
+		case 3: // type
+			addr := num()
+			num()
+			types[addr] = str()
+			num()
+		case 4: // goroutine
+			nums(8)
+			str()
+			nums(4)
+		case 5: // stack frame
+			nums(3)
+			bytes()
+			nums(3)
+			str()
+			fields()
+		case 6: // params
+			nums(4)
+			str()
+			str()
+			num()
+		case 7, 11: // finalizer, queued finalizer
+			nums(5)
+		case 8: // itab
+			nums(2)
+		case 9: // OS thread
+			nums(3)
+		case 10: // memstats
+			nums(24 + 256 + 1)
+		case 12, 13: // data, bss
+			num()
+			bytes()
+			fields()
+		case 14: // defer
+			nums(7)
+		case 15: // panic
+			nums(6)
+		case 16: // memprof
+			nums(2)
+			nstk := num()
+			for i := uint64(0); i < nstk && rerr == nil; i++ {
+				str()
+				str()
+				num()
+			}
+			nums(2)
+		case 17: // alloc sample
+			nums(2)
+		case 18: // chan
+			addr := num()
+			elems[addr] = num()
+			chans[addr] = dumpChan{cap: num(), len: num(), closed: num() != 0, buf: num()}
+		default:
+			if rerr == nil {
+				return nil, fmt.Errorf("unknown record tag %d", tag)
+			}
+		}
+	}
+	return nil, rerr
+}
+
+func TestWriteHeapDumpChans(t *testing.T) {
+	if runtime.GOOS == "js" {
+		t.Skipf("WriteHeapDump is not available on %s.", runtime.GOOS)
+	}
+	f, err := os.CreateTemp("", "heapdumptest")
+	if err != nil {
+		t.Fatalf("TempFile failed: %v", err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	ints := make(chan int, 10)
+	for i := 0; i < 3; i++ {
+		ints <- i
+	}
+	objs := make(chan *Obj, 4)
+	objs <- &Obj{}
+	close(objs)
+	unbuf := make(chan struct{})
+
+	WriteHeapDump(f.Fd())
+	runtime.KeepAlive(ints)
+	runtime.KeepAlive(objs)
+	runtime.KeepAlive(unbuf)
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	chans, err := readHeapDumpChans(f)
+	if err != nil {
+		t.Fatalf("reading heap dump: %v", err)
+	}
+
+	addr := func(c interface{}) uint64 { return uint64(reflect.ValueOf(c).Pointer()) }
+	for _, tc := range []struct {
+		name string
+		c    interface{}
+		want dumpChan
+	}{
+		{"ints", ints, dumpChan{elem: "int", cap: 10, len: 3, buf: addr(ints)}},
+		{"objs", objs, dumpChan{elem: "*debug_test.Obj", cap: 4, len: 1, closed: true}},
+		{"unbuf", unbuf, dumpChan{elem: "struct {}"}},
+	} {
+		got, ok := chans[addr(tc.c)]
+		if !ok {
+			t.Errorf("no channel record for %s", tc.name)
+			continue
+		}
+		if tc.name == "objs" {
+			// The buffer is allocated separately.
+			if got.buf == 0 || got.buf == addr(tc.c) {
+				t.Errorf("%s: buffer object %#x, want separate object", tc.name, got.buf)
+			}
+			got.buf = 0
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
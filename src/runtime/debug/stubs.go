@@ -6,6 +6,7 @@ package debug
 
 import (
 	"time"
+	"unsafe"
 )
 
 // Implemented in package runtime.
@@ -15,3 +16,13 @@ func setMaxStack(int) int
 func setGCPercent(int32) int32
 func setPanicOnFault(bool) bool
 func setMaxThreads(int) int
+func readChanStats(*ChanStats)
+func readChannels([]ChanInfo) (int, bool)
+func setChanRecording(int)
+func setChanReplay([]ChanDecision)
+func readChanDecisions([]ChanDecision) int
+func setChanBlockProfileRate(int)
+func currentGoroutine() (unsafe.Pointer, int64)
+func readGoroutineState(unsafe.Pointer, int64, *goroutineState)
+func chanWaiters(interface{}) []ChanWaiter
+func readGoroutineDump([]goroutineRecord, []uintptr) (int, int)
@@ -53,6 +53,51 @@ func (e *TypeAssertionError) Error() string {
 		": missing method " + e.missingMethod
 }
 
+// A ClosedChannelError is the panic value of a send on a closed
+// channel. Besides the usual message, it tells which goroutine closed
+// the channel, and, with GODEBUG=chanclosecheck=1, where and when.
+type ClosedChannelError struct {
+	elem      *_type
+	closedBy  int64
+	closePC   uintptr
+	closeTime int64 // Unix time in nanoseconds
+}
+
+func (*ClosedChannelError) RuntimeError() {}
+
+// Error returns "send on closed channel", the message that a send on a
+// closed channel has always panicked with.
+func (e *ClosedChannelError) Error() string {
+	return "send on closed channel"
+}
+
+// ElemType returns the element type of the channel, such as "int".
+func (e *ClosedChannelError) ElemType() string {
+	return e.elem.string()
+}
+
+// ClosedBy returns the ID of the goroutine that closed the channel, as
+// shown in tracebacks.
+func (e *ClosedChannelError) ClosedBy() int64 {
+	return e.closedBy
+}
+
+// CloseFrame returns the frame of the call to close that closed the
+// channel. For a channel closed with reflect.Value.Close, it is a frame
+// of package reflect. The call is only recorded for channels made
+// while GODEBUG=chanclosecheck=1 was set; for others, CloseFrame
+// returns the zero Frame.
+func (e *ClosedChannelError) CloseFrame() Frame {
+	frame, _ := CallersFrames([]uintptr{e.closePC}).Next()
+	return frame
+}
+
+// CloseTime returns when the channel was closed, in nanoseconds since
+// the Unix epoch, or 0 if that was not recorded, as for CloseFrame.
+func (e *ClosedChannelError) CloseTime() int64 {
+	return e.closeTime
+}
+
 //go:nosplit
 // itoa converts val to a decimal representation. The result is
 // written somewhere within buf and the location of the result is returned.
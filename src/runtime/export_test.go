@@ -530,6 +530,8 @@ type G = g
 
 type Sudog = sudog
 
+type Hchan = hchan
+
 func Getg() *G {
 	return getg()
 }
@@ -1258,3 +1260,426 @@ func GCTestPointerClass(p unsafe.Pointer) string {
 }
 
 const Raceenabled = raceenabled
+
+func SetChanNUMAPolicy(p int) (old int) {
+	old = int(debug.channuma)
+	debug.channuma = int32(p)
+	return old
+}
+
+func NUMANodes() int { return int(numaNodes) }
+
+// SetNUMANodes pretends that the machine has n NUMA nodes with the IDs
+// in mask, so that placement can be tested on a single-node machine.
+func SetNUMANodes(n int, mask uint64) (oldN int, oldMask uint64) {
+	oldN, oldMask = int(numaNodes), numaNodeMask
+	numaNodes, numaNodeMask = int32(n), mask
+	return oldN, oldMask
+}
+
+func ParseNodeList(s string) (uint64, bool) { return parseNodeList([]byte(s)) }
+
+// ChanBufPlaced reports whether the buffer of channel c has a NUMA
+// placement.
+func ChanBufPlaced(c interface{}) bool {
+	hc := (*hchan)(efaceOf(&c).data)
+	s := spanOfHeap(uintptr(hc.buf))
+	return s != nil && s.placed != 0
+}
+
+func SetChanHugePage(on bool) (old bool) {
+	old = debug.chanhugepage != 0
+	debug.chanhugepage = 0
+	if on {
+		debug.chanhugepage = 1
+	}
+	return old
+}
+
+func ChanBuf(c interface{}) unsafe.Pointer {
+	return (*hchan)(efaceOf(&c).data).buf
+}
+
+// A GSnap describes a goroutine blocked in a synthetic snapshot for
+// GroupGSnaps. Goroutines with equal Key and Wait have the same stack
+// and wait reason. Chan is 0 if the goroutine is not blocked on a
+// channel; otherwise equal values stand for the same channel.
+// Waitfor is the time blocked, or -1 if unknown.
+type GSnap struct {
+	Goid    int64
+	Key     uintptr
+	Wait    uint8
+	Waitfor int64
+	Chan    int
+}
+
+type GSnapGroup struct {
+	Goids         []int64
+	Chans         int
+	Known         int
+	Min, Med, Max int64
+}
+
+// GroupGSnaps groups a synthetic snapshot of goroutines as grouped
+// tracebacks do, and returns the groups in the order they are printed.
+func GroupGSnaps(in []GSnap) []GSnapGroup {
+	chans := make(map[int]*hchan)
+	snaps := make([]gsnap, len(in))
+	for i, s := range in {
+		snaps[i] = gsnap{
+			goid:       s.Goid,
+			key:        s.Key,
+			status:     _Gwaiting,
+			waitreason: waitReason(s.Wait),
+			waitfor:    s.Waitfor,
+		}
+		if s.Chan != 0 {
+			if chans[s.Chan] == nil {
+				chans[s.Chan] = new(hchan)
+			}
+			snaps[i].c = chans[s.Chan]
+		}
+	}
+	var out []GSnapGroup
+	for _, grp := range groupgsnaps(snaps, make([]gsnapGroup, len(snaps))) {
+		snaps := snaps[grp.start : grp.start+grp.n]
+		var g GSnapGroup
+		for _, s := range snaps {
+			g.Goids = append(g.Goids, s.goid)
+		}
+		st := groupstats(snaps)
+		g.Chans, g.Known, g.Min, g.Med, g.Max = st.chans, st.known, st.min, st.med, st.max
+		out = append(out, g)
+	}
+	return out
+}
+
+func StackGrouped(buf []byte) int { return pprof_stackGrouped(buf) }
+
+// ChanCorruptWaiting replaces the waiting list of the goroutine blocked
+// receiving from ch with two fake sudogs, the second for other. It
+// reports false if no goroutine is blocked receiving from ch.
+func ChanCorruptWaiting(ch, other chan int) bool {
+	c := *(**hchan)(unsafe.Pointer(&ch))
+	lock(&c.lock)
+	defer unlock(&c.lock)
+	sg := c.recvq.first
+	if sg == nil {
+		return false
+	}
+	fake := new([2]sudog)
+	fake[0].g = sg.g
+	fake[0].c = c
+	fake[0].waitlink = &fake[1]
+	fake[1].g = sg.g
+	fake[1].c = *(**hchan)(unsafe.Pointer(&other))
+	sg.g.waiting = &fake[0]
+	return true
+}
+
+// ChanCorruptRecvq makes the sudog of the goroutine blocked receiving
+// from ch claim to belong to other. It reports false if no goroutine
+// is blocked receiving from ch.
+func ChanCorruptRecvq(ch, other chan int) bool {
+	c := *(**hchan)(unsafe.Pointer(&ch))
+	lock(&c.lock)
+	defer unlock(&c.lock)
+	sg := c.recvq.first
+	if sg == nil {
+		return false
+	}
+	sg.c = *(**hchan)(unsafe.Pointer(&other))
+	return true
+}
+
+// ChanCorruptCount sets the number of values buffered in ch to n,
+// leaving its buffer indices alone.
+func ChanCorruptCount(ch chan int, n int) {
+	c := *(**hchan)(unsafe.Pointer(&ch))
+	lock(&c.lock)
+	c.qcount = uint(n)
+	unlock(&c.lock)
+}
+
+// ChanSendOnSystemStack sends 1 on ch from the system stack, as a timer
+// function does, and reports whether it did.
+func ChanSendOnSystemStack(ch chan int, block bool) (sent bool) {
+	c := *(**hchan)(unsafe.Pointer(&ch))
+	v := 1
+	p := noescape(unsafe.Pointer(&v))
+	systemstack(func() {
+		sent = chansend(c, p, block, getcallerpc(), nil)
+	})
+	return sent
+}
+
+// ChanTryRecvWorldStopped receives from ch without blocking, with the
+// world stopped.
+func ChanTryRecvWorldStopped(ch chan int) {
+	c := *(**hchan)(unsafe.Pointer(&ch))
+	stopTheWorld("ChanTryRecvWorldStopped")
+	chanrecv(c, nil, false)
+	startTheWorld()
+}
+
+// LockChanPair locks two channels one after the other, in increasing
+// address order if increasing is set and in decreasing order otherwise,
+// and unlocks them.
+func LockChanPair(increasing bool) {
+	ca, cb := make(chan int), make(chan int)
+	a, b := *(**hchan)(unsafe.Pointer(&ca)), *(**hchan)(unsafe.Pointer(&cb))
+	if (uintptr(unsafe.Pointer(a)) < uintptr(unsafe.Pointer(b))) != increasing {
+		a, b = b, a
+	}
+	lock(&a.lock)
+	lock(&b.lock)
+	unlock(&b.lock)
+	unlock(&a.lock)
+}
+
+// ChanRequeue queues a sudog of the current goroutine on the receive
+// queue of ch, which must have no waiters, and takes it off again with
+// each of the ways a sudog leaves a wait queue, queueing it anew after
+// each. It reports whether the sudog is marked unqueued at the end.
+func ChanRequeue(ch chan int) bool {
+	c := *(**hchan)(unsafe.Pointer(&ch))
+	sg := acquireSudog()
+	sg.g = getg()
+	sg.c = c
+	lock(&c.lock)
+	c.recvq.enqueue(sg)
+	c.recvq.dequeue()
+	c.recvq.enqueue(sg)
+	c.recvq.dequeueSudoG(sg)
+	c.recvq.enqueue(sg)
+	c.recvq.dequeueNoWB()
+	unlock(&c.lock)
+	queued := sg.queued
+	sg.g = nil
+	sg.c = nil
+	releaseSudog(sg)
+	return !queued
+}
+
+// ChanEnqueueTwice queues a sudog of the current goroutine on the
+// receive queue of ch twice, which throws.
+func ChanEnqueueTwice(ch chan int) {
+	c := *(**hchan)(unsafe.Pointer(&ch))
+	sg := acquireSudog()
+	sg.g = getg()
+	sg.c = c
+	lock(&c.lock)
+	c.recvq.enqueue(sg)
+	c.recvq.enqueue(sg)
+	unlock(&c.lock)
+}
+
+// SetChanParkStress turns on or off the stack shrink attempts in the
+// window where a goroutine parks on a channel, and returns the number
+// of attempts that the window's handshake has blocked so far.
+func SetChanParkStress(on bool) (blocked uint64) {
+	chanParkStress = on
+	return atomic.Load64(&chanParkStressBlocked)
+}
+
+// SetSelectAdaptive turns GODEBUG=selectadaptive on or off and
+// returns the previous setting.
+func SetSelectAdaptive(on bool) (old bool) {
+	old = debug.selectadaptive != 0
+	debug.selectadaptive = 0
+	if on {
+		debug.selectadaptive = 1
+	}
+	return old
+}
+
+// SetSudogCacheMax sets GODEBUG=sudogcachemax to max bytes and returns
+// the previous setting. Setting it counts the sudogs cached so far, so
+// those left over from an earlier setting count against the new limit.
+func SetSudogCacheMax(max int) (old int) {
+	stopTheWorld("SetSudogCacheMax")
+	old = int(debug.sudogcachemax)
+	debug.sudogcachemax = int32(max)
+	n := int64(0)
+	for _, pp := range allp {
+		n += int64(len(pp.sudogcache))
+	}
+	lock(&sched.sudoglock)
+	for s := sched.sudogcache; s != nil; s = s.next {
+		n++
+	}
+	unlock(&sched.sudoglock)
+	atomic.Storeint64(&sudogCacheCount, n)
+	startTheWorld()
+	return old
+}
+
+func SetChanWakeGlobal(threshold int) (old int) {
+	old = int(debug.chanwakeglobal)
+	debug.chanwakeglobal = int32(threshold)
+	return old
+}
+
+// SetChanNBFail makes percent percent of the non-blocking sends and
+// receives on channel c fail as if c were not ready; see channbfail.go.
+func SetChanNBFail(c interface{}, percent int) {
+	hc := (*hchan)(efaceOf(&c).data)
+	lock(&hc.lock)
+	atomic.Store(&chanSide(hc).nbFail, uint32(percent))
+	unlock(&hc.lock)
+}
+
+func SetChanDirectSwitch(enable bool) (old bool) {
+	old = debug.chandirectswitch > 0
+	debug.chandirectswitch = 0
+	if enable {
+		debug.chandirectswitch = 1
+	}
+	return old
+}
+
+// ChanDirty returns the number of slots of channel c that have been
+// received from and not cleared yet.
+func ChanDirty(c interface{}) int {
+	hc := (*hchan)(efaceOf(&c).data)
+	lock(&hc.lock)
+	n := hc.dirty
+	unlock(&hc.lock)
+	return int(n)
+}
+
+// ChanWakeGlobal counts n wakeups on channel c, as if they were made
+// in quick succession, and returns how many of them would put the
+// woken goroutine on the global run queue.
+func ChanWakeGlobal(c interface{}, n int) (global int) {
+	hc := (*hchan)(efaceOf(&c).data)
+	lock(&hc.lock)
+	for i := 0; i < n; i++ {
+		if chanWakeGlobal(hc) {
+			global++
+		}
+	}
+	unlock(&hc.lock)
+	return global
+}
+
+// ChanClosedBy returns the ID of the goroutine that closed channel c.
+func ChanClosedBy(c interface{}) int64 {
+	return (*hchan)(efaceOf(&c).data).closedBy
+}
+
+// ChanWaiters returns the number of goroutines blocked on channel c.
+func ChanWaiters(c interface{}) int {
+	hc := (*hchan)(efaceOf(&c).data)
+	lock(&hc.lock)
+	n := hc.recvq.len() + hc.sendq.len()
+	unlock(&hc.lock)
+	return n
+}
+
+// Goid returns the ID of the current goroutine.
+func Goid() int64 {
+	return getg().goid
+}
+
+// A ChanCancel is a handle to the cancelable channel operations of
+// the goroutine that created it.
+type ChanCancel struct {
+	h *chanCancel
+}
+
+func NewChanCancel() ChanCancel {
+	return ChanCancel{newChanCancel()}
+}
+
+func (h ChanCancel) Cancel() {
+	h.h.cancel()
+}
+
+// Send sends v on c and reports whether the send was cancelled.
+func (h ChanCancel) Send(c chan int, v int) (cancelled bool) {
+	p := new(int)
+	*p = v
+	return chansendCancelable(h.h, *(**hchan)(unsafe.Pointer(&c)), unsafe.Pointer(p))
+}
+
+// Recv receives from c.
+func (h ChanCancel) Recv(c chan int) (v int, received, cancelled bool) {
+	p := new(int)
+	received, cancelled = chanrecvCancelable(h.h, *(**hchan)(unsafe.Pointer(&c)), unsafe.Pointer(p))
+	return *p, received, cancelled
+}
+
+// SelectRecv receives from whichever of cs is ready first and returns
+// its index, or -1 if the select was cancelled.
+func (h ChanCancel) SelectRecv(cs []chan int) (i, v int, cancelled bool) {
+	n := len(cs)
+	vals := make([]int, n)
+	cases := make([]scase, n)
+	for i, c := range cs {
+		cases[i] = scase{c: *(**hchan)(unsafe.Pointer(&c)), elem: unsafe.Pointer(&vals[i])}
+	}
+	order := make([]uint16, 2*n)
+	i, _, cancelled = selectgoCancelable(h.h, &cases[0], &order[0], nil, 0, n)
+	if i >= 0 {
+		v = vals[i]
+	}
+	return i, v, cancelled
+}
+
+// TicksPerSecond returns the number of CPU ticks per second, the unit
+// of runtime.BlockProfileRecord.Cycles.
+func TicksPerSecond() int64 {
+	return tickspersecond()
+}
+
+// StackSize returns the size of the current goroutine's stack.
+func StackSize() uintptr {
+	gp := getg()
+	return gp.stack.hi - gp.stack.lo
+}
+
+// SetCheckStackChans turns GODEBUG=checkstackchans on or off, and
+// returns the number of sudogs it has checked so far.
+func SetCheckStackChans(on bool) (checked uint64) {
+	debug.checkstackchans = 0
+	if on {
+		debug.checkstackchans = 1
+	}
+	return atomic.Load64(&checkStackSudogs)
+}
+
+// SetChanProfRate sets GODEBUG=chanprofrate and returns the previous
+// rate.
+func SetChanProfRate(rate int) (old int) {
+	old = int(debug.chanprofrate)
+	debug.chanprofrate = int32(rate)
+	return old
+}
+
+// Kinds of channel operations in ChanOpsProfile.
+const (
+	ChanProfSend    = chanProfSend
+	ChanProfRecv    = chanProfRecv
+	ChanProfSelect  = chanProfSelect
+	ChanProfBlocked = chanProfBlocked
+)
+
+// ChanOpsProfile returns the records of the chanops profile.
+func ChanOpsProfile() (p []StackRecord, ops []uint8, counts []int64) {
+	n, _ := pprof_chanOpsProfile(nil, nil, nil)
+	for {
+		p = make([]StackRecord, n+10)
+		ops = make([]uint8, n+10)
+		counts = make([]int64, n+10)
+		var ok bool
+		if n, ok = pprof_chanOpsProfile(p, ops, counts); ok {
+			return p[:n], ops[:n], counts[:n]
+		}
+	}
+}
+
+// ChanLabel returns the label of the channel c.
+func ChanLabel(c interface{}) string {
+	return chanLabel((*hchan)(efaceOf(&c).data))
+}
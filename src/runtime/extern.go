@@ -27,10 +27,94 @@ It is a comma-separated list of name=val pairs setting these named variables:
 	allocfreetrace: setting allocfreetrace=1 causes every allocation to be
 	profiled and a stack trace printed on each object's allocation and free.
 
+	chanblockwarn: setting chanblockwarn=N causes the runtime to print a warning
+	for each goroutine that has been blocked on a channel operation for N seconds
+	or more. The warning describes the channels it is blocked on, names the
+	goroutine that last completed the opposite operation on each channel and
+	where, and ends with the goroutine's stack. With chanregistry=1, where each
+	channel was created is printed too. Each wait is reported once, and at most
+	one goroutine blocked on a given channel is reported every N seconds.
+
+	chanclosecheck: setting chanclosecheck=1 causes the runtime to record the most
+	recent sends on each channel, and to print a warning when a channel is closed
+	while goroutines are blocked sending on it, or shortly after another goroutine
+	sent on it. The warning names those goroutines, gives where the recent sends
+	were made, and ends with the stack of the closing goroutine. It helps find
+	the close that a "send on closed channel" panic raced with, and makes the
+	panic value of such a send tell where and when the channel was closed.
+
+	chandirectswitch: setting chandirectswitch=1 causes a goroutine that blocks or
+	calls Gosched right after waking another goroutine with a channel operation to
+	hand its processor, and the rest of its time slice, directly to the goroutine it
+	woke, instead of going through the scheduler. This shortens the round trip of
+	goroutines exchanging values over unbuffered channels.
+
+	chandropcheck: setting chandropcheck=1 causes the garbage collector to print a
+	warning when it frees a channel that still holds buffered values, which were
+	sent but will never be received. The warning gives the channel's element type
+	and the number of values lost, and, with chanregistry=1, where the channel was
+	created. The /sync/chan/dropped-with-data:channels metric counts these channels.
+
+	chanexitreport: setting chanexitreport=1 causes the runtime, when main returns or
+	the program calls os.Exit(0), to print the goroutines still blocked on channel
+	operations to standard error before exiting, grouped by stack as with
+	tracebackgroup. The exit status is not affected.
+
+	chanhugepage: setting chanhugepage=0 stops the runtime from asking the
+	operating system to back channel buffers of 16 MB or more with transparent
+	huge pages. The setting has an effect only on Linux.
+
+	chaninvariants: setting chaninvariants=1 causes a goroutine that blocks on a
+	channel to first check the channel's queue of waiting goroutines and its own list
+	of channel waits, and every send, receive and close to check the channel's
+	buffer and queues before unlocking it, and to crash the program with a
+	description of the problem if they are corrupted. This catches corruption
+	at the operation that caused it, at a cost proportional to the number of
+	goroutines waiting on the channel.
+
+	chanprofrate: setting chanprofrate=N causes the runtime to record the stack of
+	every Nth channel operation completed on each processor, whether it blocked or
+	not, in the "chanops" profile of runtime/pprof. Sends, receives and selects are
+	counted; nonblocking operations that fail are not.
+
+	chanrecord: setting chanrecord=N causes the runtime to record the last N
+	nondeterministic channel decisions, such as which goroutine a send wakes
+	and which case a select statement chooses, and to print them if the
+	program crashes. See runtime/debug.RecordChanDecisions.
+
+	chanregistry: setting chanregistry=1 causes the runtime to record every channel
+	created after startup, along with where and when it was created, in a registry
+	that runtime/debug.DumpChannels and the /debug/pprof/channels handler of
+	net/http/pprof report. The registry does not keep channels alive.
+
+	channuma: setting channuma=1 causes the runtime to interleave the pages of
+	channel buffers of 1 MB or more across the machine's NUMA nodes. Setting
+	channuma=2 instead moves such a buffer to the NUMA node of the thread that
+	first receives from the channel. The setting has an effect only on
+	linux/amd64 and linux/arm64 machines with more than one NUMA node.
+
+	chanwakeglobal: setting chanwakeglobal=N causes a channel that has woken more
+	than N goroutines within a millisecond to put the goroutines it wakes for the
+	rest of that millisecond on the global run queue, rather than running them next
+	on the processor of the goroutine that woke them. This keeps a consumer fed by
+	many producers from migrating to each producer's processor in turn.
+
+	checkstackchans: setting checkstackchans=1 causes the runtime, whenever it
+	moves the stack of a goroutine parked on channels, to check that the pointers
+	to the values being sent or received have been adjusted: that each points into
+	the new stack, or into memory that is not a stack. If one does not, the
+	program crashes with a description of the goroutine's channel waits.
+
 	clobberfree: setting clobberfree=1 causes the garbage collector to
 	clobber the memory content of an object with bad content when it frees
 	the object.
 
+	closedrecvwarn: setting closedrecvwarn=N causes the runtime to print a warning,
+	with the goroutine's stack, when a channel receive or select statement returns
+	more than N times within a second because its channel is closed, as a loop
+	that keeps receiving from a closed channel instead of exiting does. Each
+	receive or select statement is reported at most once.
+
 	cgocheck: setting cgocheck=0 disables all checks for packages
 	using cgo to incorrectly pass Go pointers to non-Go code.
 	Setting cgocheck=1 (the default) enables relatively cheap
@@ -103,9 +187,25 @@ It is a comma-separated list of name=val pairs setting these named variables:
 	invalidptr: invalidptr=1 (the default) causes the garbage collector and stack
 	copier to crash the program if an invalid pointer value (for example, 1)
 	is found in a pointer-typed location. Setting invalidptr=0 disables this check.
+
+	lockedchanwarn: setting lockedchanwarn=N causes the runtime to print a warning,
+	with the stack of the goroutine blocking, when more than N goroutines locked
+	to their threads with LockOSThread are blocked on channel operations at once,
+	each leaving its thread idle. The warning is repeated each time the number
+	exceeds twice the number last reported. The number is reported by the
+	/sched/threads/idle-locked-chan:threads metric and by schedtrace, as
+	idlelockedchan=.
 	This should only be used as a temporary workaround to diagnose buggy code.
 	The real fix is to not store integers in pointer-typed locations.
 
+	racechanlen: setting racechanlen=1 in a program built with -race causes
+	len(ch) on a channel to be reported as a data race when it is not
+	synchronized with a concurrent close of the channel or with a concurrent
+	send or receive that changes the number of buffered elements. This finds
+	code that tests len(ch) in one goroutine before acting on the channel
+	while other goroutines are using it. The default, racechanlen=0, does not
+	report len(ch), since unsynchronized uses such as monitoring are common.
+
 	sbrk: setting sbrk=1 replaces the memory allocator and garbage collector
 	with a trivial allocator that obtains memory from the operating system and
 	never reclaims any memory.
@@ -129,7 +229,30 @@ It is a comma-separated list of name=val pairs setting these named variables:
 	processors, threads and goroutines.
 
 	schedtrace: setting schedtrace=X causes the scheduler to emit a single line to standard
-	error every X milliseconds, summarizing the scheduler state.
+	error every X milliseconds, summarizing the scheduler state. The line ends with the
+	number of goroutines blocked sending on a channel, receiving from a channel, and in
+	a select statement, as blockedsend=, blockedrecv=, and blockedselect=, and the
+	number of threads left idle by goroutines locked to them that are blocked on
+	channels, as idlelockedchan=.
+
+	selectadaptive: setting selectadaptive=1 causes a select statement that keeps
+	choosing the same case to check that case first, which makes a select over many
+	channels where one carries most of the traffic cheaper. The choice among several
+	ready cases is still random but no longer uniform: it favors the case chosen
+	recently. Cases that are not ready are handled as without the setting.
+
+	selectspindetect: setting selectspindetect=1 causes the runtime to print a
+	warning, with the goroutine's stack, when a select statement with a default case
+	takes the default case many times in a row in quick succession, as a loop that
+	polls channels without ever blocking does. Such a loop keeps a CPU busy while
+	it waits. Each select statement is reported at most once.
+
+	sudogcachemax: setting sudogcachemax=N limits the memory held by the caches
+	of sudogs, the records of goroutines waiting on channels and semaphores, to N
+	bytes. A sudog released while the caches are full is left to the garbage
+	collector. Without the setting, the caches grow with the number of goroutines
+	blocked at once until the next garbage collection. The size of the caches is
+	reported by the /sched/sudogs/cached-bytes:bytes metric.
 
 	tracebackancestors: setting tracebackancestors=N extends tracebacks with the stacks at
 	which goroutines were created, where N limits the number of ancestor goroutines to
@@ -137,6 +260,14 @@ It is a comma-separated list of name=val pairs setting these named variables:
 	IDs will refer to the ID of the goroutine at the time of creation; it's possible for this
 	ID to be reused for another goroutine. Setting N to 0 will report no ancestry information.
 
+	tracebackgroup: setting tracebackgroup=N causes tracebacks of all goroutines,
+	both in crash output and from runtime.Stack, to print goroutines with the same
+	status, wait reason, and stack once as a group. The group's header gives the number
+	of goroutines, followed by a sample of their IDs, the channel they are blocked on
+	or the number of distinct channels, and the minimum, median, and maximum time they
+	have been blocked. Groups of fewer than N goroutines are printed one by one, as usual.
+	Setting N to 0 (the default) disables grouping.
+
 	asyncpreemptoff: asyncpreemptoff=1 disables signal-based
 	asynchronous goroutine preemption. This makes some loops
 	non-preemptible for long periods, which may delay GC and
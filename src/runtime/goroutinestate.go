@@ -0,0 +1,189 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+import "unsafe"
+
+// goroutineStateSnapshot is a runtime copy of runtime/debug.goroutineState
+// and must be kept structurally identical to that type.
+type goroutineStateSnapshot struct {
+	status     string
+	waitReason string
+	waitNs     int64
+	c          uintptr
+	chanElem   string
+}
+
+// debug_currentGoroutine returns a handle to the current goroutine: its
+// g and its goroutine ID, which tells the goroutine apart from the later
+// goroutines that reuse the g.
+//
+//go:linkname debug_currentGoroutine runtime/debug.currentGoroutine
+func debug_currentGoroutine() (unsafe.Pointer, int64) {
+	gp := getg()
+	return unsafe.Pointer(gp), gp.goid
+}
+
+// debug_readGoroutineState describes the goroutine with ID goid that
+// runs on gp into s, or reports it exited if gp now runs another
+// goroutine or none.
+//
+// The state of gp is read without stopping the world, as the traceback
+// of another goroutine reads it, so it may be out of date by the time
+// it is returned. But a waiting goroutine is kept from being woken while
+// its wait is described, so that the reason, the time and the channel
+// it reports all belong to the same wait.
+//
+//go:linkname debug_readGoroutineState runtime/debug.readGoroutineState
+func debug_readGoroutineState(gptr unsafe.Pointer, goid int64, s *goroutineStateSnapshot) {
+	*s = goroutineStateSnapshot{}
+	chanWaitTime()
+	gp := (*g)(gptr)
+	if gp == nil {
+		return
+	}
+	systemstack(func() {
+		for {
+			status := readgstatus(gp) &^ _Gscan
+			// gp.goid changes only once gp has exited and
+			// is reused, so if it matches, status is that of
+			// the goroutine we are looking for.
+			if gp.goid != goid || status == _Gdead || status == _Gidle {
+				s.status = "exited"
+				return
+			}
+			if status != _Gwaiting {
+				s.status = gStatusStrings[status]
+				return
+			}
+			// Keep gp from being woken while we look at its
+			// wait. If it is being scanned or woken, look again.
+			if !castogscanstatus(gp, _Gwaiting, _Gscanwaiting) {
+				procyield(10)
+				continue
+			}
+			now := nanotime()
+			s.status = gStatusStrings[_Gwaiting]
+			s.waitReason = gp.waitreason.String()
+			if since := chanWaitSince(gp, now); since != 0 && now > since {
+				s.waitNs = now - since
+			}
+			if c := goroutineWaitChan(gp); c != nil {
+				s.c = uintptr(unsafe.Pointer(c))
+				s.chanElem = c.elemtype.string()
+			}
+			casfrom_Gscanstatus(gp, _Gscanwaiting, _Gwaiting)
+			return
+		}
+	})
+}
+
+// goroutineDumpSnapshot is a runtime copy of runtime/debug.goroutineRecord
+// and must be kept structurally identical to that type.
+type goroutineDumpSnapshot struct {
+	goid       int64
+	status     string
+	waitReason string
+	waitNs     int64
+	c          uintptr
+	chanElem   string
+	chanCap    int
+	chanLen    int
+	chanSend   bool
+	stack      int // index of the first PC of the stack in pcs
+	nstack     int
+	truncated  bool
+}
+
+// debug_readGoroutineDump describes the goroutines, as
+// GoroutineProfileEx does, into p, and their stacks, of at most
+// _TracebackMaxFrames PCs each, into pcs. It returns the number of
+// goroutines and of PCs, and fills p and pcs only if they are large
+// enough for both; otherwise the caller retries with larger ones.
+//
+// The goroutines are described with the world stopped, so they all
+// describe the same point in time. Nothing is allocated.
+//
+//go:linkname debug_readGoroutineDump runtime/debug.readGoroutineDump
+func debug_readGoroutineDump(p []goroutineDumpSnapshot, pcs []uintptr) (n, npcs int) {
+	gp := getg()
+	sp := getcallersp()
+	pc := getcallerpc()
+	chanWaitTime()
+
+	stopTheWorld("goroutine dump")
+
+	// World is stopped, no locking required.
+	n = 1
+	forEachGRace(func(gp1 *g) {
+		if goroutineProfiled(gp, gp1) {
+			n++
+		}
+	})
+	if n > len(p) {
+		startTheWorld()
+		return n, 0
+	}
+
+	systemstack(func() {
+		var buf [_TracebackMaxFrames]uintptr
+		now := nanotime()
+		i := 0
+		save := func(gp1 *g, pc, sp uintptr) {
+			if i == len(p) {
+				return
+			}
+			k := gentraceback(pc, sp, 0, gp1, 0, &buf[0], len(buf), nil, nil, 0)
+			if npcs+k <= len(pcs) {
+				copy(pcs[npcs:], buf[:k])
+			}
+			p[i].saveDump(gp1, now, npcs, k)
+			npcs += k
+			i++
+		}
+		save(gp, pc, sp)
+		forEachGRace(func(gp1 *g) {
+			if goroutineProfiled(gp, gp1) {
+				save(gp1, ^uintptr(0), ^uintptr(0))
+			}
+		})
+	})
+
+	startTheWorld()
+	return n, npcs
+}
+
+// saveDump records in s the state of gp at time now, whose stack is
+// the nstack PCs from index stack.
+//
+// The world must be stopped.
+func (s *goroutineDumpSnapshot) saveDump(gp *g, now int64, stack, nstack int) {
+	*s = goroutineDumpSnapshot{
+		goid:      gp.goid,
+		stack:     stack,
+		nstack:    nstack,
+		truncated: nstack == _TracebackMaxFrames,
+	}
+	status := readgstatus(gp) &^ _Gscan
+	if status >= uint32(len(gStatusStrings)) {
+		s.status = "???"
+		return
+	}
+	s.status = gStatusStrings[status]
+	if status != _Gwaiting {
+		return
+	}
+	s.waitReason = gp.waitreason.String()
+	if since := chanWaitSince(gp, now); since != 0 && now > since {
+		s.waitNs = now - since
+	}
+	if c := goroutineWaitChan(gp); c != nil {
+		s.c = uintptr(unsafe.Pointer(c))
+		s.chanElem = c.elemtype.string()
+		s.chanCap = int(c.dataqsiz)
+		s.chanLen = int(c.qcount)
+		s.chanSend = gp.waitreason.isChanSend()
+	}
+}
@@ -7,7 +7,19 @@
 // finalizers, etc.) to a file.
 
 // The format of the dumped file is described at
-// https://golang.org/s/go15heapdump.
+// https://golang.org/s/go15heapdump. Since the "go1.17 heap dump"
+// header, the dump also contains channel records:
+//
+//	tagChan, address of the hchan, address of the element type
+//	(described by an earlier type record), buffer capacity
+//	(dataqsiz), number of buffered elements (qcount), closed flag,
+//	and address of the object holding the buffer
+//
+// A channel record follows the object record of its hchan, separated
+// from it only by the type record of the element type if that type has
+// not been described yet. The buffer object is the hchan itself if the buffer was
+// allocated with it, and 0 if the channel has no buffer or its
+// elements have size zero.
 
 package runtime
 
@@ -61,6 +73,7 @@ const (
 	tagPanic           = 15
 	tagMemProf         = 16
 	tagAllocSample     = 17
+	tagChan            = 18
 )
 
 var dumpfd uintptr // fd to write the dump to.
@@ -497,9 +510,132 @@ func dumpobjs() {
 				freemark[j] = false
 				continue
 			}
-			dumpobj(unsafe.Pointer(p), size, makeheapobjbv(p, size))
+			bv := makeheapobjbv(p, size)
+			dumpobj(unsafe.Pointer(p), size, bv)
+			if size >= hchanSize && isheapchan(p, size, s, bv) {
+				dumpchan((*hchan)(unsafe.Pointer(p)))
+			}
+		}
+	}
+}
+
+// hchanEface holds a *hchan, for the type information of hchan.
+var hchanEface interface{} = (*hchan)(nil)
+
+// isheapchan reports whether the object at p of span s, whose size is
+// size and whose pointer bitmap is bv, looks like an hchan.
+//
+// The heap keeps no type for an object beyond its pointer bitmap. An
+// hchan allocated with pointers has the pointer bitmap of hchan. One
+// allocated without pointers has its buffer, if any, right after it,
+// or c.buf pointing to itself if it has none; see makechan. Either
+// way, its element type must be a type whose size is its elemsize.
+//
+// Other objects may look the same, so the element type must also be
+// one that dumptype can describe without faulting: only types in the
+// type data of a module are accepted. A channel whose element type
+// was created by reflect is dumped as an ordinary object.
+func isheapchan(p, size uintptr, s *mspan, bv bitvector) bool {
+	c := (*hchan)(unsafe.Pointer(p))
+	if s.spanclass.noscan() {
+		switch {
+		case c.buf == unsafe.Pointer(&c.buf):
+		case uintptr(c.buf) == p+hchanSize && c.dataqsiz != 0 && c.elemsize != 0 &&
+			uintptr(c.dataqsiz) <= (size-hchanSize)/uintptr(c.elemsize):
+		default:
+			return false
+		}
+	} else {
+		typ := (*ptrtype)(unsafe.Pointer(efaceOf(&hchanEface)._type)).elem
+		nptr := typ.ptrdata / sys.PtrSize
+		for i := uintptr(0); i < nptr || i < uintptr(bv.n); i++ {
+			var want, got uint8
+			if i < nptr {
+				want = *addb(typ.gcdata, i/8) >> (i % 8) & 1
+			}
+			if i < uintptr(bv.n) {
+				got = bv.ptrbit(i)
+			}
+			if got != want {
+				return false
+			}
+		}
+	}
+	t := uintptr(unsafe.Pointer(c.elemtype))
+	if t == 0 || t%sys.PtrSize != 0 || !ismoduletype(t) {
+		return false
+	}
+	return uintptr(c.elemsize) == c.elemtype.size
+}
+
+// ismoduletype reports whether t may be the address of a type in the
+// type data of a module: the type, its kind and the names that
+// dumptype reads from it must all lie in that data.
+func ismoduletype(t uintptr) bool {
+	for md := &firstmoduledata; md != nil; md = md.next {
+		if t < md.types || t >= md.etypes {
+			continue
+		}
+		if t+unsafe.Sizeof(_type{}) > md.etypes {
+			return false
+		}
+		typ := (*_type)(unsafe.Pointer(t))
+		if k := typ.kind & kindMask; k == 0 || k > kindUnsafePointer {
+			return false
+		}
+		if typ.str == 0 || !ismodulename(md, typ.str) {
+			return false
+		}
+		if x := typ.uncommon(); x != nil {
+			if uintptr(unsafe.Pointer(x))+unsafe.Sizeof(*x) > md.etypes ||
+				!ismodulename(md, x.pkgpath) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// ismodulename reports whether the name at offset off in the type data
+// of md lies in that data and, unless off is 0, is not empty.
+func ismodulename(md *moduledata, off nameOff) bool {
+	if off == 0 {
+		return true
+	}
+	p := md.types + uintptr(off)
+	if p < md.types || p >= md.etypes {
+		return false
+	}
+	// The flags byte is followed by the length, a varint that must end
+	// in the type data too.
+	n := name{(*byte)(unsafe.Pointer(p))}
+	for i := 1; ; i++ {
+		if p+uintptr(i) >= md.etypes || i > 4 {
+			return false
 		}
+		if *n.data(i)&0x80 == 0 {
+			break
+		}
+	}
+	i, l := n.readvarint(1)
+	return l > 0 && p+uintptr(1+i+l) <= md.etypes
+}
+
+// dumpchan writes a channel record for c.
+func dumpchan(c *hchan) {
+	var bufobj uintptr
+	if c.dataqsiz != 0 && c.elemsize != 0 {
+		bufobj, _, _ = findObject(uintptr(c.buf), 0, 0)
 	}
+	dumptype(c.elemtype)
+	dumpint(tagChan)
+	dumpint(uint64(uintptr(unsafe.Pointer(c))))
+	dumpint(uint64(uintptr(unsafe.Pointer(c.elemtype))))
+	dumpint(uint64(c.dataqsiz))
+	dumpint(uint64(c.qcount))
+	dumpbool(c.closed != 0)
+	dumpint(uint64(bufobj))
 }
 
 func dumpparams() {
@@ -664,7 +800,7 @@ func dumpmemprof() {
 	}
 }
 
-var dumphdr = []byte("go1.7 heap dump\n")
+var dumphdr = []byte("go1.17 heap dump\n")
 
 func mdump(m *MemStats) {
 	assertWorldStopped()
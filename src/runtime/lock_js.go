@@ -171,12 +171,51 @@ type event struct {
 // The timeout event started by beforeIdle.
 var idleID int32
 
+// Idle-time GC marking runs when no goroutine is ready, which is when
+// Go would otherwise pause for JavaScript events, and does not stop
+// for them, since they can only be delivered once Go pauses. Marking a
+// large heap would hold up the events, and the goroutines they wake,
+// such as receivers on the channels that callbacks send on, until the
+// mark phase ends. So an idle mark worker marks for at most
+// idleMarkSlice at a time, and then beforeIdle pauses, with a timeout
+// to resume marking once the pending events have been handled.
+const idleMarkSlice = 1e6 // 1ms
+
+var (
+	idleMarkDeadline int64 // nanotime at which the running idle mark worker yields
+	idleMarkYielded  bool  // an idle mark worker yielded and Go has not paused since
+)
+
+// idleMarkAllowed reports whether findrunnable may start an idle mark
+// worker at time now. If so, the worker's time slice starts.
+func idleMarkAllowed(now int64) bool {
+	if idleMarkYielded {
+		return false
+	}
+	idleMarkDeadline = now + idleMarkSlice
+	return true
+}
+
+// idleMarkYield reports whether the running idle mark worker has used
+// up its time slice, and must stop so that Go can pause.
+func idleMarkYield() bool {
+	if nanotime() < idleMarkDeadline {
+		return false
+	}
+	idleMarkYielded = true
+	return true
+}
+
 // beforeIdle gets called by the scheduler if no goroutine is awake.
 // If we are not already handling an event, then we pause for an async event.
 // If an event handler returned, we resume it and it will pause the execution.
 // beforeIdle either returns the specific goroutine to schedule next or
 // indicates with otherReady that some goroutine became ready.
 func beforeIdle(now, pollUntil int64) (gp *g, otherReady bool) {
+	if idleMarkYielded && gcBlackenEnabled != 0 && (pollUntil == 0 || pollUntil > now+1) {
+		// Come back to marking once pending events are handled.
+		pollUntil = now + 1
+	}
 	delay := int64(-1)
 	if pollUntil != 0 {
 		delay = pollUntil - now
@@ -240,6 +279,7 @@ func handleEvent() {
 		returned: false,
 	}
 	events = append(events, e)
+	idleMarkYielded = false
 
 	eventHandler()
 
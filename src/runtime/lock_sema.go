@@ -302,4 +302,12 @@ func beforeIdle(int64, int64) (*g, bool) {
 	return nil, false
 }
 
+func idleMarkAllowed(int64) bool {
+	return true
+}
+
+func idleMarkYield() bool {
+	return false
+}
+
 func checkTimeouts() {}
@@ -0,0 +1,57 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Threads idled by locked goroutines blocked on channels.
+//
+// A goroutine locked to its thread with LockOSThread that blocks on a
+// channel leaves the thread idle, since no other goroutine may run on
+// it, until the goroutine is woken. A program with many of them uses
+// many threads to do nothing, and may run into the limit on threads
+// (see debug.SetMaxThreads) without any sign of where they went.
+//
+// chanLockedIdle counts those threads: it is the number of locked
+// goroutines parked in a channel send, receive or select. It is
+// reported by the /sched/threads/idle-locked-chan:threads metric and
+// by GODEBUG=schedtrace, as idlelockedchan=. Such goroutines are rare,
+// so a single counter will do.
+//
+// With GODEBUG=lockedchanwarn=N, the runtime prints a warning and the
+// stack of the goroutine blocking when the count first exceeds N, and
+// again each time it exceeds twice the count last reported, so that a
+// count that keeps growing is reported a logarithmic number of times.
+
+import "runtime/internal/atomic"
+
+var (
+	chanLockedIdle     uint32 // locked goroutines parked on channels
+	chanLockedReported uint32 // count at the last warning
+)
+
+// chanLockedPark records that gp, which is locked to its thread, is
+// about to park on a channel operation.
+func chanLockedPark(gp *g) {
+	n := atomic.Xadd(&chanLockedIdle, 1)
+	if debug.lockedchanwarn <= 0 || n <= uint32(debug.lockedchanwarn) {
+		return
+	}
+	last := atomic.Load(&chanLockedReported)
+	if n <= 2*last || !atomic.Cas(&chanLockedReported, last, n) {
+		return
+	}
+	print("runtime: ", n, " goroutines locked to their threads are blocked on channels, idling as many threads\n")
+	print("goroutine ", gp.goid, " [running]:\n")
+	pc, sp := getcallerpc(), getcallersp()
+	systemstack(func() {
+		traceback(pc, sp, 0, gp)
+	})
+	print("\n")
+}
+
+// chanLockedUnpark records that gp, which is locked to its thread, has
+// been woken from a channel operation.
+func chanLockedUnpark() {
+	atomic.Xadd(&chanLockedIdle, -1)
+}
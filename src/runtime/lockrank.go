@@ -21,6 +21,12 @@
 // an entry for A in arcs[B][]. We will currently fail not only if the total order
 // (the lock ranking) is violated, but also if there is a missing entry in the
 // partial order.
+//
+// A rank whose entry in the partial order lists the rank itself allows several
+// locks of that rank to be held at once. For some of these ranks, all the
+// goroutines that do so must acquire the locks in the same order to avoid
+// deadlock, which the ranks alone cannot express. The only such order used is
+// increasing lock address, which lockRankAddrOrdered records.
 
 package runtime
 
@@ -42,21 +48,28 @@ const (
 	lockRankSweep
 
 	lockRankPollDesc
+	lockRankSigChans
 	lockRankSched
 	lockRankDeadlock
 	lockRankAllg
 	lockRankAllp
 
 	lockRankTimers // Multiple timers locked simultaneously in destroy()
+	lockRankTimeGroup
 	lockRankItab
 	lockRankReflectOffs
 	lockRankHchan // Multiple hchans acquired in lock order in syncadjustsudogs()
 	lockRankFin
 	lockRankNotifyList
+	lockRankBcast
 	lockRankTraceBuf
 	lockRankTraceStrings
 	lockRankMspanSpecial
 	lockRankProf
+	lockRankChanRegistry
+	lockRankChanDecisions
+	lockRankChanCancel
+	lockRankChanSet
 	lockRankGcBitsArenas
 	lockRankRoot
 	lockRankTrace
@@ -121,22 +134,29 @@ var lockNames = []string{
 	lockRankSweep:        "sweep",
 
 	lockRankPollDesc: "pollDesc",
+	lockRankSigChans: "sigChans",
 	lockRankSched:    "sched",
 	lockRankDeadlock: "deadlock",
 	lockRankAllg:     "allg",
 	lockRankAllp:     "allp",
 
 	lockRankTimers:      "timers",
+	lockRankTimeGroup:   "timeGroup",
 	lockRankItab:        "itab",
 	lockRankReflectOffs: "reflectOffs",
 
 	lockRankHchan:         "hchan",
 	lockRankFin:           "fin",
 	lockRankNotifyList:    "notifyList",
+	lockRankBcast:         "bcast",
 	lockRankTraceBuf:      "traceBuf",
 	lockRankTraceStrings:  "traceStrings",
 	lockRankMspanSpecial:  "mspanSpecial",
 	lockRankProf:          "prof",
+	lockRankChanRegistry:  "chanRegistry",
+	lockRankChanDecisions: "chanDecisions",
+	lockRankChanCancel:    "chanCancel",
+	lockRankChanSet:       "chanSet",
 	lockRankGcBitsArenas:  "gcBitsArenas",
 	lockRankRoot:          "root",
 	lockRankTrace:         "trace",
@@ -200,38 +220,45 @@ var lockPartialOrder [][]lockRank = [][]lockRank{
 	lockRankCpuprof:       {},
 	lockRankSweep:         {},
 	lockRankPollDesc:      {},
-	lockRankSched:         {lockRankSysmon, lockRankScavenge, lockRankForcegc, lockRankSweepWaiters, lockRankAssistQueue, lockRankCpuprof, lockRankSweep, lockRankPollDesc},
+	lockRankSigChans:      {lockRankSysmon},
+	lockRankSched:         {lockRankSysmon, lockRankScavenge, lockRankForcegc, lockRankSweepWaiters, lockRankAssistQueue, lockRankCpuprof, lockRankSweep, lockRankPollDesc, lockRankSigChans},
 	lockRankDeadlock:      {lockRankDeadlock},
 	lockRankAllg:          {lockRankSysmon, lockRankSched},
 	lockRankAllp:          {lockRankSysmon, lockRankSched},
-	lockRankTimers:        {lockRankSysmon, lockRankScavenge, lockRankPollDesc, lockRankSched, lockRankAllp, lockRankTimers},
+	lockRankTimers:        {lockRankSysmon, lockRankScavenge, lockRankPollDesc, lockRankSigChans, lockRankSched, lockRankAllp, lockRankTimers},
+	lockRankTimeGroup:     {},
 	lockRankItab:          {},
 	lockRankReflectOffs:   {lockRankItab},
-	lockRankHchan:         {lockRankScavenge, lockRankSweep, lockRankHchan},
-	lockRankFin:           {lockRankSysmon, lockRankScavenge, lockRankSched, lockRankAllg, lockRankTimers, lockRankHchan},
+	lockRankHchan:         {lockRankScavenge, lockRankSweep, lockRankPollDesc, lockRankSigChans, lockRankTimeGroup, lockRankHchan},
+	lockRankFin:           {lockRankSysmon, lockRankScavenge, lockRankSched, lockRankAllg, lockRankTimers, lockRankTimeGroup, lockRankHchan},
 	lockRankNotifyList:    {},
-	lockRankTraceBuf:      {lockRankSysmon, lockRankScavenge},
-	lockRankTraceStrings:  {lockRankTraceBuf},
-	lockRankMspanSpecial:  {lockRankSysmon, lockRankScavenge, lockRankAssistQueue, lockRankCpuprof, lockRankSweep, lockRankSched, lockRankAllg, lockRankAllp, lockRankTimers, lockRankItab, lockRankReflectOffs, lockRankHchan, lockRankNotifyList, lockRankTraceBuf, lockRankTraceStrings},
-	lockRankProf:          {lockRankSysmon, lockRankScavenge, lockRankAssistQueue, lockRankCpuprof, lockRankSweep, lockRankSched, lockRankAllg, lockRankAllp, lockRankTimers, lockRankItab, lockRankReflectOffs, lockRankHchan, lockRankNotifyList, lockRankTraceBuf, lockRankTraceStrings},
-	lockRankGcBitsArenas:  {lockRankSysmon, lockRankScavenge, lockRankAssistQueue, lockRankCpuprof, lockRankSched, lockRankAllg, lockRankTimers, lockRankItab, lockRankReflectOffs, lockRankHchan, lockRankNotifyList, lockRankTraceBuf, lockRankTraceStrings},
+	lockRankBcast:         {},
+	lockRankTraceBuf:      {lockRankSysmon, lockRankScavenge, lockRankHchan},
+	lockRankTraceStrings:  {lockRankHchan, lockRankTraceBuf},
+	lockRankMspanSpecial:  {lockRankSysmon, lockRankScavenge, lockRankAssistQueue, lockRankCpuprof, lockRankSweep, lockRankSched, lockRankAllg, lockRankAllp, lockRankTimers, lockRankTimeGroup, lockRankItab, lockRankReflectOffs, lockRankHchan, lockRankNotifyList, lockRankBcast, lockRankTraceBuf, lockRankTraceStrings},
+	lockRankProf:          {lockRankSysmon, lockRankScavenge, lockRankAssistQueue, lockRankCpuprof, lockRankSweep, lockRankSched, lockRankAllg, lockRankAllp, lockRankTimers, lockRankTimeGroup, lockRankItab, lockRankReflectOffs, lockRankHchan, lockRankNotifyList, lockRankBcast, lockRankTraceBuf, lockRankTraceStrings},
+	lockRankChanRegistry:  {lockRankSysmon, lockRankScavenge, lockRankAssistQueue, lockRankCpuprof, lockRankSweep, lockRankSched, lockRankAllg, lockRankAllp, lockRankTimers, lockRankTimeGroup, lockRankItab, lockRankReflectOffs, lockRankHchan, lockRankNotifyList, lockRankBcast, lockRankTraceBuf, lockRankTraceStrings},
+	lockRankChanDecisions: {lockRankSysmon, lockRankScavenge, lockRankAssistQueue, lockRankCpuprof, lockRankSweep, lockRankSched, lockRankAllg, lockRankAllp, lockRankTimers, lockRankTimeGroup, lockRankItab, lockRankReflectOffs, lockRankHchan, lockRankNotifyList, lockRankBcast, lockRankTraceBuf, lockRankTraceStrings},
+	lockRankChanCancel:    {lockRankHchan},
+	lockRankChanSet:       {lockRankHchan},
+	lockRankGcBitsArenas:  {lockRankSysmon, lockRankScavenge, lockRankAssistQueue, lockRankCpuprof, lockRankSched, lockRankAllg, lockRankTimers, lockRankTimeGroup, lockRankItab, lockRankReflectOffs, lockRankHchan, lockRankNotifyList, lockRankBcast, lockRankTraceBuf, lockRankTraceStrings},
 	lockRankRoot:          {},
 	lockRankTrace:         {lockRankSysmon, lockRankScavenge, lockRankForcegc, lockRankAssistQueue, lockRankSweep, lockRankSched, lockRankHchan, lockRankTraceBuf, lockRankTraceStrings, lockRankRoot},
-	lockRankTraceStackTab: {lockRankScavenge, lockRankForcegc, lockRankSweepWaiters, lockRankAssistQueue, lockRankSweep, lockRankSched, lockRankAllg, lockRankTimers, lockRankHchan, lockRankFin, lockRankNotifyList, lockRankTraceBuf, lockRankTraceStrings, lockRankRoot, lockRankTrace},
+	lockRankTraceStackTab: {lockRankScavenge, lockRankForcegc, lockRankSweepWaiters, lockRankAssistQueue, lockRankSweep, lockRankSched, lockRankAllg, lockRankTimers, lockRankTimeGroup, lockRankHchan, lockRankFin, lockRankNotifyList, lockRankBcast, lockRankTraceBuf, lockRankTraceStrings, lockRankRoot, lockRankTrace},
 	lockRankNetpollInit:   {lockRankTimers},
 
 	lockRankRwmutexW: {},
 	lockRankRwmutexR: {lockRankSysmon, lockRankRwmutexW},
 
-	lockRankSpanSetSpine: {lockRankSysmon, lockRankScavenge, lockRankForcegc, lockRankAssistQueue, lockRankCpuprof, lockRankSweep, lockRankPollDesc, lockRankSched, lockRankAllg, lockRankAllp, lockRankTimers, lockRankItab, lockRankReflectOffs, lockRankHchan, lockRankNotifyList, lockRankTraceBuf, lockRankTraceStrings},
-	lockRankGscan:        {lockRankSysmon, lockRankScavenge, lockRankForcegc, lockRankSweepWaiters, lockRankAssistQueue, lockRankCpuprof, lockRankSweep, lockRankPollDesc, lockRankSched, lockRankTimers, lockRankItab, lockRankReflectOffs, lockRankHchan, lockRankFin, lockRankNotifyList, lockRankTraceBuf, lockRankTraceStrings, lockRankProf, lockRankGcBitsArenas, lockRankRoot, lockRankTrace, lockRankTraceStackTab, lockRankNetpollInit, lockRankSpanSetSpine},
-	lockRankStackpool:    {lockRankSysmon, lockRankScavenge, lockRankSweepWaiters, lockRankAssistQueue, lockRankCpuprof, lockRankSweep, lockRankPollDesc, lockRankSched, lockRankTimers, lockRankItab, lockRankReflectOffs, lockRankHchan, lockRankFin, lockRankNotifyList, lockRankTraceBuf, lockRankTraceStrings, lockRankProf, lockRankGcBitsArenas, lockRankRoot, lockRankTrace, lockRankTraceStackTab, lockRankNetpollInit, lockRankRwmutexR, lockRankSpanSetSpine, lockRankGscan},
+	lockRankSpanSetSpine: {lockRankSysmon, lockRankScavenge, lockRankForcegc, lockRankAssistQueue, lockRankCpuprof, lockRankSweep, lockRankPollDesc, lockRankSigChans, lockRankSched, lockRankAllg, lockRankAllp, lockRankTimers, lockRankTimeGroup, lockRankItab, lockRankReflectOffs, lockRankHchan, lockRankNotifyList, lockRankBcast, lockRankTraceBuf, lockRankTraceStrings},
+	lockRankGscan:        {lockRankSysmon, lockRankScavenge, lockRankForcegc, lockRankSweepWaiters, lockRankAssistQueue, lockRankCpuprof, lockRankSweep, lockRankPollDesc, lockRankSigChans, lockRankSched, lockRankTimers, lockRankTimeGroup, lockRankItab, lockRankReflectOffs, lockRankHchan, lockRankFin, lockRankNotifyList, lockRankBcast, lockRankTraceBuf, lockRankTraceStrings, lockRankProf, lockRankChanCancel, lockRankChanSet, lockRankGcBitsArenas, lockRankRoot, lockRankTrace, lockRankTraceStackTab, lockRankNetpollInit, lockRankSpanSetSpine},
+	lockRankStackpool:    {lockRankSysmon, lockRankScavenge, lockRankSweepWaiters, lockRankAssistQueue, lockRankCpuprof, lockRankSweep, lockRankPollDesc, lockRankSigChans, lockRankSched, lockRankTimers, lockRankTimeGroup, lockRankItab, lockRankReflectOffs, lockRankHchan, lockRankFin, lockRankNotifyList, lockRankBcast, lockRankTraceBuf, lockRankTraceStrings, lockRankProf, lockRankGcBitsArenas, lockRankRoot, lockRankTrace, lockRankTraceStackTab, lockRankNetpollInit, lockRankRwmutexR, lockRankSpanSetSpine, lockRankGscan},
 	lockRankStackLarge:   {lockRankSysmon, lockRankAssistQueue, lockRankSched, lockRankItab, lockRankHchan, lockRankProf, lockRankGcBitsArenas, lockRankRoot, lockRankSpanSetSpine, lockRankGscan},
 	lockRankDefer:        {},
-	lockRankSudog:        {lockRankHchan, lockRankNotifyList},
-	lockRankWbufSpans:    {lockRankSysmon, lockRankScavenge, lockRankSweepWaiters, lockRankAssistQueue, lockRankSweep, lockRankPollDesc, lockRankSched, lockRankAllg, lockRankTimers, lockRankItab, lockRankReflectOffs, lockRankHchan, lockRankFin, lockRankNotifyList, lockRankTraceStrings, lockRankMspanSpecial, lockRankProf, lockRankRoot, lockRankGscan, lockRankDefer, lockRankSudog},
-	lockRankMheap:        {lockRankSysmon, lockRankScavenge, lockRankSweepWaiters, lockRankAssistQueue, lockRankCpuprof, lockRankSweep, lockRankPollDesc, lockRankSched, lockRankAllg, lockRankAllp, lockRankTimers, lockRankItab, lockRankReflectOffs, lockRankHchan, lockRankFin, lockRankNotifyList, lockRankTraceBuf, lockRankTraceStrings, lockRankMspanSpecial, lockRankProf, lockRankGcBitsArenas, lockRankRoot, lockRankSpanSetSpine, lockRankGscan, lockRankStackpool, lockRankStackLarge, lockRankDefer, lockRankSudog, lockRankWbufSpans},
-	lockRankMheapSpecial: {lockRankSysmon, lockRankScavenge, lockRankAssistQueue, lockRankCpuprof, lockRankSweep, lockRankPollDesc, lockRankSched, lockRankAllg, lockRankAllp, lockRankTimers, lockRankItab, lockRankReflectOffs, lockRankHchan, lockRankNotifyList, lockRankTraceBuf, lockRankTraceStrings},
+	lockRankSudog:        {lockRankHchan, lockRankNotifyList, lockRankBcast},
+	lockRankWbufSpans:    {lockRankSysmon, lockRankScavenge, lockRankSweepWaiters, lockRankAssistQueue, lockRankSweep, lockRankPollDesc, lockRankSigChans, lockRankSched, lockRankAllg, lockRankTimers, lockRankTimeGroup, lockRankItab, lockRankReflectOffs, lockRankHchan, lockRankFin, lockRankNotifyList, lockRankBcast, lockRankTraceStrings, lockRankMspanSpecial, lockRankProf, lockRankRoot, lockRankGscan, lockRankDefer, lockRankSudog},
+	lockRankMheap:        {lockRankSysmon, lockRankScavenge, lockRankSweepWaiters, lockRankAssistQueue, lockRankCpuprof, lockRankSweep, lockRankPollDesc, lockRankSigChans, lockRankSched, lockRankAllg, lockRankAllp, lockRankTimers, lockRankTimeGroup, lockRankItab, lockRankReflectOffs, lockRankHchan, lockRankFin, lockRankNotifyList, lockRankBcast, lockRankTraceBuf, lockRankTraceStrings, lockRankMspanSpecial, lockRankProf, lockRankGcBitsArenas, lockRankRoot, lockRankSpanSetSpine, lockRankGscan, lockRankStackpool, lockRankStackLarge, lockRankDefer, lockRankSudog, lockRankWbufSpans},
+	lockRankMheapSpecial: {lockRankSysmon, lockRankScavenge, lockRankAssistQueue, lockRankCpuprof, lockRankSweep, lockRankPollDesc, lockRankSigChans, lockRankSched, lockRankAllg, lockRankAllp, lockRankTimers, lockRankTimeGroup, lockRankItab, lockRankReflectOffs, lockRankHchan, lockRankNotifyList, lockRankBcast, lockRankTraceBuf, lockRankTraceStrings},
 	lockRankGlobalAlloc:  {lockRankProf, lockRankSpanSetSpine, lockRankMheap, lockRankMheapSpecial},
 
 	lockRankGFree:     {lockRankSched},
@@ -246,3 +273,11 @@ var lockPartialOrder [][]lockRank = [][]lockRank{
 	lockRankPollCache:     {},
 	lockRankDebug:         {},
 }
+
+// lockRankAddrOrdered reports whether the locks of rank, when several are
+// held at once, must be acquired in increasing address order. That is the
+// order in which sellock locks the channels of a select, and in which
+// syncadjustsudogs locks the channels of a goroutine parked in one.
+func lockRankAddrOrdered(rank lockRank) bool {
+	return rank == lockRankHchan || rank == lockRankHchanLeaf
+}
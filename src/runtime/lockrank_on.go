@@ -77,6 +77,7 @@ func lockWithRank(l *mutex, rank lockRank) {
 		// i is the index of the lock being acquired
 		if i > 0 {
 			checkRanks(gp, gp.m.locksHeld[i-1].rank, rank)
+			checkAddrOrder(gp, i)
 		}
 		lock2(l)
 	})
@@ -153,6 +154,27 @@ func checkRanks(gp *g, prevRank, rank lockRank) {
 	}
 }
 
+// checkAddrOrder checks that goroutine g, which is acquiring the lock at
+// index i of its locksHeld, holds no lock of the same rank at the same or a
+// higher address, if locks of that rank must be acquired in address order.
+//
+//go:systemstack
+func checkAddrOrder(gp *g, i int) {
+	l := gp.m.locksHeld[i]
+	if !lockRankAddrOrdered(l.rank) {
+		return
+	}
+	for _, held := range gp.m.locksHeld[:i] {
+		if held.rank == l.rank && held.lockAddr >= l.lockAddr {
+			printlock()
+			println(gp.m.procid, " ======")
+			println("lock", unsafe.Pointer(l.lockAddr), "of rank", l.rank.String(), "acquired after", unsafe.Pointer(held.lockAddr), "of the same rank")
+			printHeldLocks(gp)
+			throw("lock ordering problem")
+		}
+	}
+}
+
 // See comment on lockWithRank regarding stack splitting.
 func unlockWithRank(l *mutex) {
 	if l == &debuglock || l == &paniclk {
@@ -222,6 +244,7 @@ func lockWithRankMayAcquire(l *mutex, rank lockRank) {
 		gp.m.locksHeld[i].lockAddr = uintptr(unsafe.Pointer(l))
 		gp.m.locksHeldLen++
 		checkRanks(gp, gp.m.locksHeld[i-1].rank, rank)
+		checkAddrOrder(gp, i)
 		gp.m.locksHeldLen--
 	})
 }
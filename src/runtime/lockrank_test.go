@@ -5,6 +5,11 @@
 package runtime_test
 
 import (
+	"internal/goexperiment"
+	"internal/testenv"
+	"os"
+	"os/exec"
+	"regexp"
 	. "runtime"
 	"testing"
 )
@@ -39,3 +44,29 @@ func TestLockRankPartialOrderSortedEntries(t *testing.T) {
 		}
 	}
 }
+
+// Check that the locks of channels, which may be held several at a time,
+// are required to be acquired in address order.
+func TestLockRankAddrOrder(t *testing.T) {
+	LockChanPair(true)
+	if !goexperiment.StaticLockRanking {
+		t.Skip("lock ranking is not enabled")
+	}
+	if os.Getenv("TEST_LOCK_RANK_ADDR_ORDER") != "1" {
+		cmd := testenv.CleanCmdEnv(exec.Command(os.Args[0], "-test.run=^TestLockRankAddrOrder$"))
+		cmd.Env = append(cmd.Env, "TEST_LOCK_RANK_ADDR_ORDER=1")
+		out, _ := cmd.CombinedOutput()
+		// Don't check err since it's expected to crash.
+		for _, want := range []string{
+			`(?m)^lock 0x[0-9a-f]+ of rank hchan acquired after 0x[0-9a-f]+ of the same rank$`,
+			`(?m)^fatal error: lock ordering problem$`,
+		} {
+			if !regexp.MustCompile(want).MatchString(string(out)) {
+				t.Fatalf("output does not match %q:\n%s", want, out)
+			}
+		}
+		return
+	}
+	LockChanPair(false) // Crashes.
+	t.Fatal("locked channels out of address order")
+}
@@ -0,0 +1,120 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// NUMA placement hints for heap pages.
+//
+// sysPlaceHeap sets the NUMA memory policy of pages of a heap span,
+// which decides on which node the kernel places them. Pages that are
+// already resident are migrated. The span is marked, and when it is
+// freed the sweeper resets its pages to the default policy, so that
+// the next user of the pages is not affected.
+//
+// Placement is only implemented on linux/amd64 and linux/arm64, using
+// mbind(2); see mem_numa_linux.go. Elsewhere, and on machines with a
+// single NUMA node, numaNodes is 1 and sysPlaceHeap does nothing.
+
+import "unsafe"
+
+// placement is a NUMA placement policy for a range of pages.
+type placement uint8
+
+const (
+	placeDefault    placement = iota // the node of the thread that first touches a page
+	placeInterleave                  // interleave the pages across all nodes
+	placeLocal                       // the node of the calling thread
+)
+
+// numaNodes is the number of online NUMA nodes, and numaNodeMask
+// is the set of their IDs. Nodes with IDs of 64 or more are not
+// supported; numaNodes is 1 on such machines.
+var (
+	numaNodes    int32 = 1
+	numaNodeMask uint64
+)
+
+// sysPlaceHeap applies placement p to the pages that lie entirely in
+// [v, v+n), which must be part of a single heap span.
+func sysPlaceHeap(v unsafe.Pointer, n uintptr, p placement) {
+	if numaNodes < 2 {
+		return
+	}
+	s := spanOfHeap(uintptr(v))
+	if s == nil {
+		return
+	}
+	start := alignUp(uintptr(v), physPageSize)
+	end := alignDown(uintptr(v)+n, physPageSize)
+	if end <= start {
+		return
+	}
+	s.placed = 1
+	sysPlace(unsafe.Pointer(start), end-start, p)
+}
+
+// resetPlacement resets the pages of s, which is being freed, to the
+// default placement.
+func (s *mspan) resetPlacement() {
+	s.placed = 0
+	start := alignUp(s.base(), physPageSize)
+	end := alignDown(s.base()+s.npages*pageSize, physPageSize)
+	if end > start {
+		sysPlace(unsafe.Pointer(start), end-start, placeDefault)
+	}
+}
+
+// parseNodeList parses a list of node IDs in the format of
+// /sys/devices/system/node/online, such as "0-1,3\n". ok is false if
+// the list is malformed or includes an ID of 64 or more.
+func parseNodeList(b []byte) (mask uint64, ok bool) {
+	for len(b) > 0 && (b[len(b)-1] == '\n' || b[len(b)-1] == 0) {
+		b = b[:len(b)-1]
+	}
+	if len(b) == 0 {
+		return 0, false
+	}
+	for len(b) > 0 {
+		i := 0
+		for i < len(b) && b[i] != ',' {
+			i++
+		}
+		r := b[:i]
+		if i < len(b) {
+			i++ // skip ','
+		}
+		b = b[i:]
+
+		j := 0
+		for j < len(r) && r[j] != '-' {
+			j++
+		}
+		lo, ok := atoiBytes(r[:j])
+		if !ok {
+			return 0, false
+		}
+		hi := lo
+		if j < len(r) {
+			if hi, ok = atoiBytes(r[j+1:]); !ok {
+				return 0, false
+			}
+		}
+		if lo < 0 || hi < lo || hi >= 64 {
+			return 0, false
+		}
+		for n := lo; n <= hi; n++ {
+			mask |= 1 << n
+		}
+	}
+	return mask, true
+}
+
+// atoiBytes is atoi for a byte slice. It does not allocate, so it can
+// be used before the heap is initialized.
+func atoiBytes(b []byte) (int, bool) {
+	if len(b) == 0 {
+		return 0, false
+	}
+	return atoi(slicebytetostringtmp(&b[0], len(b)))
+}
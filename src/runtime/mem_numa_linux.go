@@ -0,0 +1,74 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build linux && (amd64 || arm64)
+// +build linux
+// +build amd64 arm64
+
+package runtime
+
+import (
+	"runtime/internal/sys"
+	"unsafe"
+)
+
+const (
+	_MPOL_DEFAULT    = 0
+	_MPOL_PREFERRED  = 1
+	_MPOL_INTERLEAVE = 3
+
+	_MPOL_MF_MOVE = 1 << 1
+)
+
+//go:noescape
+func mbind(addr unsafe.Pointer, n uintptr, mode int32, nodemask *uint64, maxnode uintptr, flags uint32) int32
+
+//go:noescape
+func getcpu(cpu, node *uint32) int32
+
+var sysNodeOnlinePath = []byte("/sys/devices/system/node/online\x00")
+
+// numaInit reads the set of online NUMA nodes.
+func numaInit() {
+	var buf [64]byte
+	fd := open(&sysNodeOnlinePath[0], 0 /* O_RDONLY */, 0)
+	if fd < 0 {
+		return
+	}
+	ptr := (*[len(buf)]byte)(noescape(unsafe.Pointer(&buf)))
+	n := read(fd, unsafe.Pointer(ptr), int32(len(buf)))
+	closefd(fd)
+	if n <= 0 || int(n) == len(buf) {
+		return
+	}
+	mask, ok := parseNodeList(ptr[:n])
+	if !ok {
+		return
+	}
+	numaNodeMask = mask
+	numaNodes = int32(sys.OnesCount64(mask))
+}
+
+// sysPlace sets the memory policy of the pages in [v, v+n), which must
+// be page-aligned, and migrates those that are resident. Errors are
+// ignored: mbind may be forbidden, for example in containers, and the
+// placement is only an optimization.
+func sysPlace(v unsafe.Pointer, n uintptr, p placement) {
+	// The kernel reads maxnode-1 bits of the node mask.
+	const maxnode = 64 + 1
+	switch p {
+	case placeDefault:
+		mbind(v, n, _MPOL_DEFAULT, nil, 0, 0)
+	case placeInterleave:
+		mask := numaNodeMask
+		mbind(v, n, _MPOL_INTERLEAVE, &mask, maxnode, _MPOL_MF_MOVE)
+	case placeLocal:
+		var node uint32
+		if getcpu(nil, &node) < 0 || node >= 64 {
+			return
+		}
+		mask := uint64(1) << node
+		mbind(v, n, _MPOL_PREFERRED, &mask, maxnode, _MPOL_MF_MOVE)
+	}
+}
@@ -0,0 +1,14 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !linux || (!amd64 && !arm64)
+// +build !linux !amd64,!arm64
+
+package runtime
+
+import "unsafe"
+
+func numaInit() {}
+
+func sysPlace(v unsafe.Pointer, n uintptr, p placement) {}
@@ -24,6 +24,7 @@ var (
 
 	sizeClassBuckets []float64
 	timeHistBuckets  []float64
+	chanCapBounds    []float64
 )
 
 type metricData struct {
@@ -84,6 +85,8 @@ func initMetrics() {
 	sizeClassBuckets = append(sizeClassBuckets, float64Inf())
 
 	timeHistBuckets = timeHistogramMetricsBuckets()
+	// The lower bounds of the buckets of chanStats.capacities.
+	chanCapBounds = []float64{0, 1, 2, 9, 65, 1025, float64Inf()}
 	metrics = map[string]metricData{
 		"/gc/cycles/automatic:gc-cycles": {
 			deps: makeStatDepSet(sysStatsDep),
@@ -309,6 +312,87 @@ func initMetrics() {
 				}
 			},
 		},
+		"/sched/sudogs/cached-bytes:bytes": {
+			compute: func(_ *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = uint64(readChanStatsTotal().sudogsCached) * uint64(unsafe.Sizeof(sudog{}))
+			},
+		},
+		"/sched/sudogs/live:sudogs": {
+			compute: func(_ *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = uint64(readChanStatsTotal().sudogsLive)
+			},
+		},
+		"/sched/threads/idle-locked-chan:threads": {
+			compute: func(_ *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = uint64(atomic.Load(&chanLockedIdle))
+			},
+		},
+		"/sync/chan/capacities:channels": {
+			compute: func(_ *statAggregate, out *metricValue) {
+				hist := out.float64HistOrInit(chanCapBounds)
+				s := readChanStatsTotal()
+				copy(hist.counts, s.capacities[:])
+			},
+		},
+		"/sync/chan/close-with-blocked-senders:events": {
+			compute: func(_ *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = atomic.Load64(&chanMisuse.closeBlockedSenders)
+			},
+		},
+		"/sync/chan/closed-with-buffered:elements": {
+			compute: func(_ *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = readChanStatsTotal().closedElems
+			},
+		},
+		"/sync/chan/closed-with-buffered:events": {
+			compute: func(_ *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = readChanStatsTotal().closesBuffered
+			},
+		},
+		"/sync/chan/dropped-with-data:channels": {
+			compute: func(_ *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = atomic.Load64(&chanDropped)
+			},
+		},
+		"/sync/chan/ops-blocked:events": {
+			compute: func(_ *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = readChanStatsTotal().opsParked
+			},
+		},
+		"/sync/chan/ops-immediate:events": {
+			compute: func(_ *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				s := readChanStatsTotal()
+				out.scalar = s.opsFast + s.opsLocked
+			},
+		},
+		"/sync/chan/send-on-closed:events": {
+			compute: func(_ *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = atomic.Load64(&chanMisuse.sendOnClosed)
+			},
+		},
+		"/sync/chan/wait/negative:events": {
+			compute: func(_ *statAggregate, out *metricValue) {
+				out.kind = metricKindUint64
+				out.scalar = atomic.Load64(&chanBlockNegative)
+			},
+		},
+		"/sync/chan/wait/total:seconds": {
+			compute: func(_ *statAggregate, out *metricValue) {
+				out.kind = metricKindFloat64
+				chanWaitTime()
+				out.scalar = float64bits(float64(readChanStatsTotal().waitTime) / 1e9)
+			},
+		},
 	}
 	metricsInit = true
 }
@@ -224,6 +224,85 @@ var allDesc = []Description{
 		Description: "Distribution of the time goroutines have spent in the scheduler in a runnable state before actually running.",
 		Kind:        KindFloat64Histogram,
 	},
+	{
+		Name: "/sched/sudogs/cached-bytes:bytes",
+		Description: "Memory held by sudogs, the records of goroutines waiting on channels and semaphores, " +
+			"that are cached for reuse rather than in use.",
+		Kind: KindUint64,
+	},
+	{
+		Name: "/sched/sudogs/live:sudogs",
+		Description: "Count of sudogs, the records of goroutines waiting on channels and semaphores, in use. " +
+			"A goroutine blocked in a select holds one per case.",
+		Kind: KindUint64,
+	},
+	{
+		Name: "/sched/threads/idle-locked-chan:threads",
+		Description: "Count of threads left idle by goroutines locked to them with LockOSThread " +
+			"that are blocked on channel operations.",
+		Kind: KindUint64,
+	},
+	{
+		Name: "/sync/chan/capacities:channels",
+		Description: "Distribution of the buffer sizes of created channels, including those created by reflect.MakeChan. " +
+			"The buckets count channels with a buffer of 0, 1, 2-8, 9-64, 65-1024, and more than 1024 elements.",
+		Kind:       KindFloat64Histogram,
+		Cumulative: true,
+	},
+	{
+		Name:        "/sync/chan/close-with-blocked-senders:events",
+		Description: "Count of channel closes that found goroutines blocked sending on the channel. Each such sender panics when it is woken.",
+		Kind:        KindUint64,
+		Cumulative:  true,
+	},
+	{
+		Name:        "/sync/chan/closed-with-buffered:elements",
+		Description: "Count of the values left in the buffers of channels when they were closed, summed over the closes counted by /sync/chan/closed-with-buffered:events.",
+		Kind:        KindUint64,
+		Cumulative:  true,
+	},
+	{
+		Name:        "/sync/chan/closed-with-buffered:events",
+		Description: "Count of channel closes that found values sent on the channel still in its buffer. Receivers still get those values, but a close while values are buffered often means they will not be received.",
+		Kind:        KindUint64,
+		Cumulative:  true,
+	},
+	{
+		Name:        "/sync/chan/dropped-with-data:channels",
+		Description: "Count of channels freed by the garbage collector while values sent on them were still buffered. Only counted when GODEBUG=chandropcheck=1 is set.",
+		Kind:        KindUint64,
+		Cumulative:  true,
+	},
+	{
+		Name:        "/sync/chan/ops-blocked:events",
+		Description: "Count of channel sends, receives, and select statements that completed after the goroutine blocked.",
+		Kind:        KindUint64,
+		Cumulative:  true,
+	},
+	{
+		Name:        "/sync/chan/ops-immediate:events",
+		Description: "Count of channel sends, receives, and select statements that completed without blocking. Non-blocking operations that could not proceed are not counted.",
+		Kind:        KindUint64,
+		Cumulative:  true,
+	},
+	{
+		Name:        "/sync/chan/send-on-closed:events",
+		Description: "Count of sends on a closed channel, each of which panics.",
+		Kind:        KindUint64,
+		Cumulative:  true,
+	},
+	{
+		Name:        "/sync/chan/wait/negative:events",
+		Description: "Count of channel blocking events recorded in the block profile whose measured duration was negative and was counted as zero. The monotonic clock should make this impossible, so a nonzero count points at a broken clock.",
+		Kind:        KindUint64,
+		Cumulative:  true,
+	},
+	{
+		Name:        "/sync/chan/wait/total:seconds",
+		Description: "Approximate cumulative time goroutines have spent blocked in channel operations, including select statements. Channel operations are only timed once this metric, runtime/debug.ReadChanStats or another source of channel wait times has first been read, so the metric is zero until then and leaves out the time spent blocked before.",
+		Kind:        KindFloat64,
+		Cumulative:  true,
+	},
 }
 
 // All returns a slice of containing metric descriptions for all supported metrics.
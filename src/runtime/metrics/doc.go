@@ -170,5 +170,71 @@ Below is the full list of supported metrics, ordered lexicographically.
 	/sched/latencies:seconds
 		Distribution of the time goroutines have spent in the scheduler
 		in a runnable state before actually running.
+
+	/sched/sudogs/cached-bytes:bytes
+		Memory held by sudogs, the records of goroutines waiting on
+		channels and semaphores, that are cached for reuse rather than
+		in use.
+
+	/sched/sudogs/live:sudogs
+		Count of sudogs, the records of goroutines waiting on channels
+		and semaphores, in use. A goroutine blocked in a select holds
+		one per case.
+
+	/sched/threads/idle-locked-chan:threads
+		Count of threads left idle by goroutines locked to them with
+		LockOSThread that are blocked on channel operations.
+
+	/sync/chan/capacities:channels
+		Distribution of the buffer sizes of created channels, including
+		those created by reflect.MakeChan. The buckets count channels
+		with a buffer of 0, 1, 2-8, 9-64, 65-1024, and more than 1024
+		elements.
+
+	/sync/chan/close-with-blocked-senders:events
+		Count of channel closes that found goroutines blocked sending
+		on the channel. Each such sender panics when it is woken.
+
+	/sync/chan/closed-with-buffered:elements
+		Count of the values left in the buffers of channels when they
+		were closed, summed over the closes counted by
+		/sync/chan/closed-with-buffered:events.
+
+	/sync/chan/closed-with-buffered:events
+		Count of channel closes that found values sent on the channel
+		still in its buffer. Receivers still get those values, but a
+		close while values are buffered often means they will not be
+		received.
+
+	/sync/chan/dropped-with-data:channels
+		Count of channels freed by the garbage collector while values
+		sent on them were still buffered. Only counted when
+		GODEBUG=chandropcheck=1 is set.
+
+	/sync/chan/ops-blocked:events
+		Count of channel sends, receives, and select statements that
+		completed after the goroutine blocked.
+
+	/sync/chan/ops-immediate:events
+		Count of channel sends, receives, and select statements that
+		completed without blocking. Non-blocking operations that could
+		not proceed are not counted.
+
+	/sync/chan/send-on-closed:events
+		Count of sends on a closed channel, each of which panics.
+
+	/sync/chan/wait/negative:events
+		Count of channel blocking events recorded in the block profile
+		whose measured duration was negative and was counted as zero.
+		The monotonic clock should make this impossible, so a nonzero
+		count points at a broken clock.
+
+	/sync/chan/wait/total:seconds
+		Approximate cumulative time goroutines have spent blocked in
+		channel operations, including select statements. Channel
+		operations are only timed once this metric,
+		runtime/debug.ReadChanStats or another source of channel wait
+		times has first been read, so the metric is zero until then and
+		leaves out the time spent blocked before.
 */
 package metrics
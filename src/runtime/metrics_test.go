@@ -5,10 +5,14 @@
 package runtime_test
 
 import (
+	"math"
+	"reflect"
 	"runtime"
+	"runtime/debug"
 	"runtime/metrics"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 	"unsafe"
@@ -284,6 +288,451 @@ func TestReadMetricsConsistency(t *testing.T) {
 	}
 }
 
+func TestReadMetricsChanMisuse(t *testing.T) {
+	samples := []metrics.Sample{
+		{Name: "/sync/chan/close-with-blocked-senders:events"},
+		{Name: "/sync/chan/send-on-closed:events"},
+	}
+	metrics.Read(samples)
+	beforeClose, beforeSend := samples[0].Value.Uint64(), samples[1].Value.Uint64()
+
+	var stats debug.ChanStats
+	debug.ReadChanStats(&stats)
+	blocked := stats.BlockedSend
+
+	c := make(chan int)
+	done := make(chan interface{})
+	go func() {
+		defer func() {
+			done <- recover()
+		}()
+		c <- 1
+	}()
+	// Wait for the sender to park before closing the channel.
+	for {
+		debug.ReadChanStats(&stats)
+		if stats.BlockedSend > blocked {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(c)
+	if r := <-done; r == nil {
+		t.Fatal("send on closed channel did not panic")
+	}
+
+	metrics.Read(samples)
+	if got := samples[0].Value.Uint64(); got <= beforeClose {
+		t.Errorf("%s = %d, want > %d", samples[0].Name, got, beforeClose)
+	}
+	if got := samples[1].Value.Uint64(); got <= beforeSend {
+		t.Errorf("%s = %d, want > %d", samples[1].Name, got, beforeSend)
+	}
+}
+
+func TestReadMetricsChanClosedWithBuffered(t *testing.T) {
+	samples := []metrics.Sample{
+		{Name: "/sync/chan/closed-with-buffered:events"},
+		{Name: "/sync/chan/closed-with-buffered:elements"},
+	}
+	metrics.Read(samples)
+	beforeCloses, beforeElems := samples[0].Value.Uint64(), samples[1].Value.Uint64()
+
+	// Closes of empty channels are not counted.
+	close(make(chan int))
+	close(make(chan int, 4))
+	drained := make(chan int, 4)
+	drained <- 1
+	<-drained
+	close(drained)
+	metrics.Read(samples)
+	if got := samples[1].Value.Uint64(); got != beforeElems {
+		t.Errorf("%s = %d after closing empty channels, want %d", samples[1].Name, got, beforeElems)
+	}
+
+	c := make(chan int, 4)
+	c <- 1
+	c <- 2
+	c <- 3
+	close(c)
+	<-c
+	metrics.Read(samples)
+	if got := samples[0].Value.Uint64(); got != beforeCloses+1 {
+		t.Errorf("%s = %d, want %d", samples[0].Name, got, beforeCloses+1)
+	}
+	if got := samples[1].Value.Uint64(); got != beforeElems+3 {
+		t.Errorf("%s = %d, want %d", samples[1].Name, got, beforeElems+3)
+	}
+}
+
+func TestReadMetricsChanCapacities(t *testing.T) {
+	samples := []metrics.Sample{{Name: "/sync/chan/capacities:channels"}}
+	metrics.Read(samples)
+	before := samples[0].Value.Float64Histogram()
+	if want := []float64{0, 1, 2, 9, 65, 1025, math.Inf(1)}; !reflect.DeepEqual(before.Buckets, want) {
+		t.Fatalf("buckets = %v, want %v", before.Buckets, want)
+	}
+	beforeCounts := append([]uint64(nil), before.Counts...)
+
+	sizes := []int{0, 1, 2, 8, 9, 64, 65, 1024, 1025}
+	want := []uint64{1, 1, 2, 2, 2, 1}
+	for _, size := range sizes {
+		runtime.KeepAlive(make(chan int, size))
+	}
+	for _, size := range sizes {
+		runtime.KeepAlive(reflect.MakeChan(reflect.TypeOf((chan int)(nil)), size))
+	}
+
+	metrics.Read(samples)
+	after := samples[0].Value.Float64Histogram()
+	for i := range want {
+		// Other goroutines may be making channels too.
+		if got := after.Counts[i] - beforeCounts[i]; got < 2*want[i] {
+			t.Errorf("bucket [%v, %v) grew by %d, want at least %d", after.Buckets[i], after.Buckets[i+1], got, 2*want[i])
+		}
+	}
+}
+
+func TestReadMetricsChanOps(t *testing.T) {
+	const n = 100
+	samples := []metrics.Sample{
+		{Name: "/sync/chan/ops-immediate:events"},
+		{Name: "/sync/chan/ops-blocked:events"},
+	}
+	read := func() (immediate, blocked uint64) {
+		metrics.Read(samples)
+		return samples[0].Value.Uint64(), samples[1].Value.Uint64()
+	}
+
+	// A producer that always finds room in the buffer and a consumer
+	// that always finds a value in it never block.
+	immediate0, blocked0 := read()
+	c := make(chan int, n)
+	for i := 0; i < n; i++ {
+		c <- i
+	}
+	for i := 0; i < n; i++ {
+		select {
+		case <-c:
+		case <-time.After(time.Hour):
+		}
+	}
+	close(c)
+	<-c
+	immediate1, blocked1 := read()
+	if got := immediate1 - immediate0; got < 2*n+1 {
+		t.Errorf("buffered producer and consumer: %d immediate operations, want at least %d", got, 2*n+1)
+	}
+	if got := blocked1 - blocked0; got > n/10 {
+		t.Errorf("buffered producer and consumer: %d blocked operations, want at most %d", got, n/10)
+	}
+
+	// A consumer of an always-empty channel blocks on every receive.
+	var stats debug.ChanStats
+	debug.ReadChanStats(&stats)
+	blockedRecv := stats.BlockedRecv
+	c = make(chan int)
+	done := make(chan bool)
+	go func() {
+		for range c {
+		}
+		done <- true
+	}()
+	for i := 0; i < n; i++ {
+		// Wait for the consumer to park before each send.
+		for {
+			debug.ReadChanStats(&stats)
+			if stats.BlockedRecv > blockedRecv {
+				break
+			}
+			runtime.Gosched()
+		}
+		c <- i
+	}
+	close(c)
+	<-done
+	_, blocked2 := read()
+	if got := blocked2 - blocked1; got < n {
+		t.Errorf("consumer of an empty channel: %d blocked operations, want at least %d", got, n)
+	}
+}
+
+func TestReadMetricsSudogs(t *testing.T) {
+	const nrecv, nselect = 50, 50
+	// Keep the GC from dropping the central sudog cache, and the
+	// caches unbounded.
+	defer debug.SetGCPercent(debug.SetGCPercent(-1))
+	defer runtime.SetSudogCacheMax(runtime.SetSudogCacheMax(0))
+	samples := []metrics.Sample{
+		{Name: "/sched/sudogs/live:sudogs"},
+		{Name: "/sched/sudogs/cached-bytes:bytes"},
+	}
+	read := func() (live, cached uint64) {
+		metrics.Read(samples)
+		return samples[0].Value.Uint64(), samples[1].Value.Uint64()
+	}
+
+	// Park nrecv goroutines in a receive and nselect in a select
+	// with three cases, which hold a sudog per case.
+	live0, _ := read()
+	want := uint64(nrecv + 3*nselect)
+	c, d, e := make(chan int), make(chan int), make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < nrecv; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-c
+		}()
+	}
+	for i := 0; i < nselect; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case <-c:
+			case <-d:
+			case <-e:
+			}
+		}()
+	}
+	live1, cached1 := read()
+	for deadline := time.Now().Add(10 * time.Second); live1-live0 < want; live1, cached1 = read() {
+		if time.Now().After(deadline) {
+			t.Fatalf("live sudogs went from %d to %d with %d goroutines parked, want an increase of %d", live0, live1, nrecv+nselect, want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Waking them returns their sudogs to the caches.
+	close(c)
+	wg.Wait()
+	live2, cached2 := read()
+	if live2 > live1-want {
+		t.Errorf("live sudogs went from %d to %d after waking the goroutines, want a decrease of at least %d", live1, live2, want)
+	}
+	size := uint64(unsafe.Sizeof(runtime.Sudog{}))
+	if got := cached2 - cached1; cached2 < cached1 || got < want*size {
+		t.Errorf("cached sudog bytes went from %d to %d after waking the goroutines, want an increase of at least %d", cached1, cached2, want*size)
+	}
+}
+
+// TestSudogCacheRelease checks that the sudogs cached after a burst of
+// channel waits on many Ps are released once GOMAXPROCS is reduced and
+// a GC runs: the caches of the destroyed Ps are dropped by procresize,
+// and the central cache by the GC.
+func TestSudogCacheRelease(t *testing.T) {
+	const procs, perProc = 64, 200
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+	defer runtime.SetSudogCacheMax(runtime.SetSudogCacheMax(0))
+	gcPercent := debug.SetGCPercent(-1)
+	defer debug.SetGCPercent(gcPercent)
+	samples := []metrics.Sample{{Name: "/sched/sudogs/cached-bytes:bytes"}}
+	cached := func() uint64 {
+		metrics.Read(samples)
+		return samples[0].Value.Uint64()
+	}
+
+	// Park procs*perProc goroutines and wake them all, which leaves
+	// their sudogs in the per-P and central caches.
+	var stats debug.ChanStats
+	debug.ReadChanStats(&stats)
+	blocked := stats.BlockedRecv
+	c := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < procs*perProc; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-c
+		}()
+	}
+	for {
+		debug.ReadChanStats(&stats)
+		if stats.BlockedRecv >= blocked+procs*perProc {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(c)
+	wg.Wait()
+	size := uint64(unsafe.Sizeof(runtime.Sudog{}))
+	inflated := cached()
+	if inflated < procs*perProc*size {
+		t.Fatalf("%d bytes of sudogs cached after waking %d goroutines, want at least %d", inflated, procs*perProc, procs*perProc*size)
+	}
+
+	// What remains cached is at most the full per-P caches of the
+	// two remaining Ps.
+	runtime.GOMAXPROCS(2)
+	runtime.GC()
+	const perPCache = 128 // len(p.sudogbuf)
+	if got, max := cached(), 2*perPCache*size; got > max {
+		t.Errorf("%d bytes of sudogs cached after shrinking GOMAXPROCS to 2 and a GC (%d before), want at most %d", got, inflated, max)
+	}
+}
+
+// TestSudogCacheMax checks that GODEBUG=sudogcachemax bounds the sudogs
+// cached after a burst of channel waits, and that debug.FreeOSMemory
+// empties the caches.
+func TestSudogCacheMax(t *testing.T) {
+	const procs, waiters = 8, 2000
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+	defer runtime.SetSudogCacheMax(runtime.SetSudogCacheMax(0))
+	gcPercent := debug.SetGCPercent(-1)
+	defer debug.SetGCPercent(gcPercent)
+	samples := []metrics.Sample{{Name: "/sched/sudogs/cached-bytes:bytes"}}
+	cached := func() uint64 {
+		metrics.Read(samples)
+		return samples[0].Value.Uint64()
+	}
+	// burst parks waiters goroutines and wakes them all, which
+	// returns their sudogs to the caches.
+	burst := func() {
+		var stats debug.ChanStats
+		debug.ReadChanStats(&stats)
+		blocked := stats.BlockedRecv
+		c := make(chan int)
+		var wg sync.WaitGroup
+		for i := 0; i < waiters; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-c
+			}()
+		}
+		for {
+			debug.ReadChanStats(&stats)
+			if stats.BlockedRecv >= blocked+waiters {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		close(c)
+		wg.Wait()
+	}
+	size := uint64(unsafe.Sizeof(runtime.Sudog{}))
+
+	burst()
+	if got := cached(); got < waiters*size {
+		t.Fatalf("%d bytes of sudogs cached after waking %d goroutines, want at least %d", got, waiters, waiters*size)
+	}
+	// Other goroutines, such as the GC's, may have used sudogs since
+	// the caches were emptied.
+	debug.FreeOSMemory()
+	if got, max := cached(), procs*size; got > max {
+		t.Fatalf("%d bytes of sudogs cached after debug.FreeOSMemory, want at most %d", got, max)
+	}
+
+	const max = 16
+	defer runtime.SetSudogCacheMax(runtime.SetSudogCacheMax(max * int(size)))
+	burst()
+	if got := cached(); got > max*size || got == 0 {
+		t.Errorf("%d bytes of sudogs cached after waking %d goroutines with sudogcachemax=%d, want at most %d and more than 0", got, waiters, max*size, max*size)
+	}
+}
+
+func TestReadMetricsChanWaitTime(t *testing.T) {
+	const (
+		n = 4
+		d = 50 * time.Millisecond
+	)
+	samples := []metrics.Sample{{Name: "/sync/chan/wait/total:seconds"}}
+	metrics.Read(samples)
+	before := samples[0].Value.Float64()
+
+	var stats debug.ChanStats
+	debug.ReadChanStats(&stats)
+	blocked := stats.BlockedRecv
+
+	c := make(chan int)
+	done := make(chan bool)
+	for i := 0; i < n; i++ {
+		go func() {
+			<-c
+			done <- true
+		}()
+	}
+	// Wait for all receivers to park, so that each is blocked for at
+	// least d.
+	for {
+		debug.ReadChanStats(&stats)
+		if stats.BlockedRecv >= blocked+n {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(d)
+	close(c)
+	for i := 0; i < n; i++ {
+		<-done
+	}
+
+	metrics.Read(samples)
+	got := time.Duration((samples[0].Value.Float64() - before) * 1e9)
+	if want := n * d; got < want {
+		t.Errorf("channel wait time grew by %v, want at least %v", got, want)
+	}
+}
+
+func TestReadMetricsIdleLockedChan(t *testing.T) {
+	const n = 4
+	samples := []metrics.Sample{{Name: "/sched/threads/idle-locked-chan:threads"}}
+	read := func() uint64 {
+		metrics.Read(samples)
+		return samples[0].Value.Uint64()
+	}
+	before := read()
+
+	// Park n goroutines locked to their threads in a receive, a send
+	// and a select, and one that is not locked.
+	c, d := make(chan int), make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			runtime.LockOSThread()
+			defer runtime.UnlockOSThread()
+			switch i % 3 {
+			case 0:
+				<-c
+			case 1:
+				d <- 1
+			case 2:
+				select {
+				case <-c:
+				case <-make(chan int):
+				}
+			}
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-c
+	}()
+	for deadline := time.Now().Add(10 * time.Second); read()-before < n; {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d threads idled by locked goroutines blocked on channels, want %d", read()-before, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if got := read() - before; got != n {
+		t.Errorf("%d threads idled by locked goroutines blocked on channels, want %d", got, n)
+	}
+
+	close(c)
+	for i := 1; i < n; i += 3 {
+		<-d
+	}
+	wg.Wait()
+	if got := read(); got != before {
+		t.Errorf("%d threads idled by locked goroutines blocked on channels after waking them, want %d", got, before)
+	}
+}
+
 func BenchmarkReadMetricsLatency(b *testing.B) {
 	stop := applyGCLoad(b)
 
@@ -1551,17 +1551,27 @@ func clearpools() {
 	}
 
 	// Clear central sudog cache.
-	// Leave per-P caches alone, they have strictly bounded size.
+	// Leave per-P caches alone, they have strictly bounded size,
+	// unless debug.FreeOSMemory asked for all of them to go.
 	// Disconnect cached list before dropping it on the floor,
 	// so that a dangling ref to one entry does not pin all of them.
 	lock(&sched.sudoglock)
 	var sg, sgnext *sudog
+	n := int64(0)
 	for sg = sched.sudogcache; sg != nil; sg = sgnext {
 		sgnext = sg.next
 		sg.next = nil
+		n++
 	}
 	sched.sudogcache = nil
 	unlock(&sched.sudoglock)
+	chanStatsGlobal.sudogsCached -= n
+	if debug.sudogcachemax > 0 {
+		atomic.Xaddint64(&sudogCacheCount, -n)
+	}
+	if atomic.Cas(&sudogFlushAll, 1, 0) {
+		flushSudogCaches()
+	}
 
 	// Clear central defer pools.
 	// Leave per-P pools alone, they have strictly bounded size.
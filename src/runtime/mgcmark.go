@@ -357,6 +357,16 @@ func markrootSpans(gcw *gcWork, shard int) {
 			// removed from the list while we're traversing it.
 			lock(&s.speciallock)
 			for sp := s.specials; sp != nil; sp = sp.next {
+				if sp.kind == _KindSpecialChanSide {
+					// The side state of a channel is not in
+					// the heap, so scan its heap pointers
+					// here.
+					cs := (*specialChanSide)(unsafe.Pointer(sp))
+					scanblock(uintptr(unsafe.Pointer(&cs.label)), sys.PtrSize, &oneptrmask[0], gcw, nil)
+					scanblock(uintptr(unsafe.Pointer(&cs.stats)), sys.PtrSize, &oneptrmask[0], gcw, nil)
+					scanblock(uintptr(unsafe.Pointer(&cs.sets)), sys.PtrSize, &oneptrmask[0], gcw, nil)
+					continue
+				}
 				if sp.kind != _KindSpecialFinalizer {
 					continue
 				}
@@ -714,6 +724,15 @@ func scanstack(gp *g, gcw *gcWork) {
 		throw("can't scan our own stack")
 	}
 
+	if s := gp.selectScratch; s != nil && memstats.numgc-s.lastGC >= selectScratchIdleGCs {
+		// Drop select scratch buffers the goroutine hasn't used
+		// in a while. No write barrier is needed: the buffers
+		// are only referenced from gp itself, and if gp is still
+		// using them, the reference on its stack, which we're
+		// about to scan, keeps them alive.
+		*(*uintptr)(unsafe.Pointer(&gp.selectScratch)) = 0
+	}
+
 	if isShrinkStackSafe(gp) {
 		// Shrink the stack if not much of it is being used.
 		shrinkstack(gp)
@@ -621,6 +621,9 @@ func (sl *sweepLocked) sweep(preserve bool) bool {
 			// have mysterious crashes due to confused memory reuse.
 			// It should be possible to switch back to sysFree if we also
 			// implement and then call some kind of mheap.deleteSpan.
+			if s.placed != 0 {
+				s.resetPlacement()
+			}
 			if debug.efence > 0 {
 				s.limit = 0 // prevent mlookup from finding this span
 				sysFault(unsafe.Pointer(s.base()), size)
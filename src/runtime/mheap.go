@@ -214,6 +214,8 @@ type mheap struct {
 	specialfinalizeralloc fixalloc // allocator for specialfinalizer*
 	specialprofilealloc   fixalloc // allocator for specialprofile*
 	specialReachableAlloc fixalloc // allocator for specialReachable
+	specialChanAlloc      fixalloc // allocator for specialChan
+	specialChanSideAlloc  fixalloc // allocator for specialChanSide
 	speciallock           mutex    // lock for special record allocators.
 	arenaHintAlloc        fixalloc // allocator for arenaHints
 
@@ -458,6 +460,7 @@ type mspan struct {
 	spanclass   spanClass     // size class and noscan (uint8)
 	state       mSpanStateBox // mSpanInUse etc; accessed atomically (get/set methods)
 	needzero    uint8         // needs to be zeroed before allocation
+	placed      uint8         // pages have a NUMA placement; see mem_numa.go
 	elemsize    uintptr       // computed from sizeclass or from npages
 	limit       uintptr       // end of data in span
 	speciallock mutex         // guards specials list
@@ -706,6 +709,8 @@ func (h *mheap) init() {
 	h.specialfinalizeralloc.init(unsafe.Sizeof(specialfinalizer{}), nil, nil, &memstats.other_sys)
 	h.specialprofilealloc.init(unsafe.Sizeof(specialprofile{}), nil, nil, &memstats.other_sys)
 	h.specialReachableAlloc.init(unsafe.Sizeof(specialReachable{}), nil, nil, &memstats.other_sys)
+	h.specialChanAlloc.init(unsafe.Sizeof(specialChan{}), nil, nil, &memstats.other_sys)
+	h.specialChanSideAlloc.init(unsafe.Sizeof(specialChanSide{}), nil, nil, &memstats.other_sys)
 	h.arenaHintAlloc.init(unsafe.Sizeof(arenaHint{}), nil, nil, &memstats.other_sys)
 
 	// Don't zero mspan allocations. Background sweeping can
@@ -1535,6 +1540,8 @@ func (h *mheap) scavengeAll() {
 
 //go:linkname runtime_debug_freeOSMemory runtime/debug.freeOSMemory
 func runtime_debug_freeOSMemory() {
+	// Have the GC drop the per-P sudog caches too.
+	atomic.Store(&sudogFlushAll, 1)
 	GC()
 	systemstack(func() { mheap_.scavengeAll() })
 }
@@ -1553,6 +1560,7 @@ func (span *mspan) init(base uintptr, npages uintptr) {
 	span.speciallock.key = 0
 	span.specials = nil
 	span.needzero = 0
+	span.placed = 0
 	span.freeindex = 0
 	span.allocBits = nil
 	span.gcmarkBits = nil
@@ -1661,6 +1669,11 @@ const (
 	// _KindSpecialReachable is a special used for tracking
 	// reachability during testing.
 	_KindSpecialReachable = 3
+	// _KindSpecialChan is the channel registry entry of a channel.
+	_KindSpecialChan = 4
+	// _KindSpecialChanSide is the side state of a channel; see
+	// chanside.go.
+	_KindSpecialChanSide = 5
 	// Note: The finalizer special must be first because if we're freeing
 	// an object, a finalizer special will cause the freeing operation
 	// to abort, and we want to keep the other special records around
@@ -1922,6 +1935,19 @@ func freeSpecial(s *special, p unsafe.Pointer, size uintptr) {
 		sp := (*specialReachable)(unsafe.Pointer(s))
 		sp.done = true
 		// The creator frees these.
+	case _KindSpecialChan:
+		sc := (*specialChan)(unsafe.Pointer(s))
+		if debug.chandropcheck != 0 {
+			chanDropCheck(sc)
+		}
+		chanUnregister(sc)
+		lock(&mheap_.speciallock)
+		mheap_.specialChanAlloc.free(unsafe.Pointer(sc))
+		unlock(&mheap_.speciallock)
+	case _KindSpecialChanSide:
+		lock(&mheap_.speciallock)
+		mheap_.specialChanSideAlloc.free(unsafe.Pointer(s))
+		unlock(&mheap_.speciallock)
 	default:
 		throw("bad special kind")
 		panic("not reached")
@@ -23,6 +23,7 @@ const (
 	memProfile bucketType = 1 + iota
 	blockProfile
 	mutexProfile
+	chanProfile // see chanprof.go
 
 	// size of bucket hash table
 	buckHashSize = 179999
@@ -48,7 +49,7 @@ type bucketType int
 type bucket struct {
 	next    *bucket
 	allnext *bucket
-	typ     bucketType // memBucket or blockBucket (includes mutexProfile)
+	typ     bucketType // memBucket or blockBucket (includes mutexProfile and chanProfile)
 	hash    uintptr
 	size    uintptr
 	nstk    uintptr
@@ -131,7 +132,7 @@ func (a *memRecordCycle) add(b *memRecordCycle) {
 }
 
 // A blockRecord is the bucket data for a bucket of type blockProfile,
-// which is used in blocking and mutex profiles.
+// which is used in blocking, mutex and channel operation profiles.
 type blockRecord struct {
 	count  float64
 	cycles int64
@@ -141,6 +142,7 @@ var (
 	mbuckets  *bucket // memory profile buckets
 	bbuckets  *bucket // blocking profile buckets
 	xbuckets  *bucket // mutex profile buckets
+	cbuckets  *bucket // channel operation profile buckets
 	buckhash  *[179999]*bucket
 	bucketmem uintptr
 
@@ -166,7 +168,7 @@ func newBucket(typ bucketType, nstk int) *bucket {
 		throw("invalid profile bucket type")
 	case memProfile:
 		size += unsafe.Sizeof(memRecord{})
-	case blockProfile, mutexProfile:
+	case blockProfile, mutexProfile, chanProfile:
 		size += unsafe.Sizeof(blockRecord{})
 	}
 
@@ -194,7 +196,7 @@ func (b *bucket) mp() *memRecord {
 
 // bp returns the blockRecord associated with the blockProfile bucket b.
 func (b *bucket) bp() *blockRecord {
-	if b.typ != blockProfile && b.typ != mutexProfile {
+	if b.typ != blockProfile && b.typ != mutexProfile && b.typ != chanProfile {
 		throw("bad use of bucket.bp")
 	}
 	data := add(unsafe.Pointer(b), unsafe.Sizeof(*b)+b.nstk*unsafe.Sizeof(uintptr(0)))
@@ -249,6 +251,9 @@ func stkbucket(typ bucketType, size uintptr, stk []uintptr, alloc bool) *bucket
 	} else if typ == mutexProfile {
 		b.allnext = xbuckets
 		xbuckets = b
+	} else if typ == chanProfile {
+		b.allnext = cbuckets
+		cbuckets = b
 	} else {
 		b.allnext = bbuckets
 		bbuckets = b
@@ -371,6 +376,10 @@ func mProf_Free(b *bucket, size uintptr) {
 
 var blockprofilerate uint64 // in CPU ticks
 
+// chanblockprofilerate is the block profile rate for channel
+// operations, in CPU ticks, or 0 to use blockprofilerate.
+var chanblockprofilerate uint64
+
 // SetBlockProfileRate controls the fraction of goroutine blocking events
 // that are reported in the blocking profile. The profiler aims to sample
 // an average of one blocking event per rate nanoseconds spent blocked.
@@ -378,6 +387,20 @@ var blockprofilerate uint64 // in CPU ticks
 // To include every blocking event in the profile, pass rate = 1.
 // To turn off profiling entirely, pass rate <= 0.
 func SetBlockProfileRate(rate int) {
+	atomic.Store64(&blockprofilerate, uint64(blockProfileTicks(rate)))
+}
+
+// setChanBlockProfileRate sets the block profile rate for channel
+// operations, or removes it if rate <= 0.
+//
+//go:linkname setChanBlockProfileRate runtime/debug.setChanBlockProfileRate
+func setChanBlockProfileRate(rate int) {
+	atomic.Store64(&chanblockprofilerate, uint64(blockProfileTicks(rate)))
+}
+
+// blockProfileTicks converts a block profile rate in nanoseconds, as
+// passed to SetBlockProfileRate, to CPU ticks.
+func blockProfileTicks(rate int) int64 {
 	var r int64
 	if rate <= 0 {
 		r = 0 // disable profiling
@@ -390,8 +413,16 @@ func SetBlockProfileRate(rate int) {
 			r = 1
 		}
 	}
+	return r
+}
 
-	atomic.Store64(&blockprofilerate, uint64(r))
+// chanBlockProfileRate returns the block profile rate for channel
+// operations, in CPU ticks.
+func chanBlockProfileRate() int64 {
+	if r := atomic.Load64(&chanblockprofilerate); r != 0 {
+		return int64(r)
+	}
+	return int64(atomic.Load64(&blockprofilerate))
 }
 
 func blockevent(cycles int64, skip int) {
@@ -405,6 +436,36 @@ func blockevent(cycles int64, skip int) {
 	}
 }
 
+// chanBlockNegative counts the channel blocking events whose duration
+// came out negative and was clamped to zero.
+var chanBlockNegative uint64
+
+// chanblockevent is blockevent for a channel operation that blocked
+// for ns nanoseconds, which is sampled at the rate returned by
+// chanBlockProfileRate.
+//
+// Channel operations time their waits with nanotime rather than
+// cputicks, which on some virtualized and heterogeneous systems
+// drifts between CPUs and makes a goroutine woken on another CPU seem
+// to have blocked for a negative or a very long time. The duration is
+// converted to CPU ticks, the unit of the block profile.
+func chanblockevent(ns int64, skip int) {
+	if ns < 0 {
+		// nanotime is monotonic, so this means the clock is broken.
+		atomic.Xadd64(&chanBlockNegative, 1)
+		ns = 0
+	}
+	cycles := int64(float64(ns) * float64(tickspersecond()) / 1e9)
+	if cycles <= 0 {
+		cycles = 1
+	}
+
+	rate := chanBlockProfileRate()
+	if blocksampled(cycles, rate) {
+		saveblockevent(cycles, rate, skip+1, blockProfile)
+	}
+}
+
 // blocksampled returns true for all events where cycles >= rate. Shorter
 // events have a cycles/rate random chance of returning true.
 func blocksampled(cycles, rate int64) bool {
@@ -743,35 +804,44 @@ func ThreadCreateProfile(p []StackRecord) (n int, ok bool) {
 
 //go:linkname runtime_goroutineProfileWithLabels runtime/pprof.runtime_goroutineProfileWithLabels
 func runtime_goroutineProfileWithLabels(p []StackRecord, labels []unsafe.Pointer) (n int, ok bool) {
-	return goroutineProfileWithLabels(p, labels)
+	return goroutineProfileWithLabels(p, labels, nil)
+}
+
+//go:linkname runtime_goroutineProfileWithChans runtime/pprof.runtime_goroutineProfileWithChans
+func runtime_goroutineProfileWithChans(p []StackRecord, labels, chans []unsafe.Pointer) (n int, ok bool) {
+	return goroutineProfileWithLabels(p, labels, chans)
+}
+
+//go:linkname runtime_chanElemString runtime/pprof.runtime_chanElemString
+func runtime_chanElemString(c unsafe.Pointer) string {
+	return (*hchan)(c).elemtype.string()
 }
 
-// labels may be nil. If labels is non-nil, it must have the same length as p.
-func goroutineProfileWithLabels(p []StackRecord, labels []unsafe.Pointer) (n int, ok bool) {
+// labels and chans may be nil. If non-nil, they must have the same
+// length as p. chans receives the channel each goroutine is blocked
+// on, as reported by goroutineWaitChan.
+func goroutineProfileWithLabels(p []StackRecord, labels, chans []unsafe.Pointer) (n int, ok bool) {
 	if labels != nil && len(labels) != len(p) {
 		labels = nil
 	}
-	gp := getg()
-
-	isOK := func(gp1 *g) bool {
-		// Checking isSystemGoroutine here makes GoroutineProfile
-		// consistent with both NumGoroutine and Stack.
-		return gp1 != gp && readgstatus(gp1) != _Gdead && !isSystemGoroutine(gp1, false)
+	if chans != nil && len(chans) != len(p) {
+		chans = nil
 	}
+	gp := getg()
 
 	stopTheWorld("profile")
 
 	// World is stopped, no locking required.
 	n = 1
 	forEachGRace(func(gp1 *g) {
-		if isOK(gp1) {
+		if goroutineProfiled(gp, gp1) {
 			n++
 		}
 	})
 
 	if n <= len(p) {
 		ok = true
-		r, lbl := p, labels
+		r, lbl, ch := p, labels, chans
 
 		// Save current goroutine.
 		sp := getcallersp()
@@ -786,10 +856,14 @@ func goroutineProfileWithLabels(p []StackRecord, labels []unsafe.Pointer) (n int
 			lbl[0] = gp.labels
 			lbl = lbl[1:]
 		}
+		if chans != nil {
+			ch[0] = nil
+			ch = ch[1:]
+		}
 
 		// Save other goroutines.
 		forEachGRace(func(gp1 *g) {
-			if !isOK(gp1) {
+			if !goroutineProfiled(gp, gp1) {
 				return
 			}
 
@@ -803,6 +877,10 @@ func goroutineProfileWithLabels(p []StackRecord, labels []unsafe.Pointer) (n int
 				lbl[0] = gp1.labels
 				lbl = lbl[1:]
 			}
+			if chans != nil {
+				ch[0] = unsafe.Pointer(goroutineWaitChan(gp1))
+				ch = ch[1:]
+			}
 			r = r[1:]
 		})
 	}
@@ -819,7 +897,138 @@ func goroutineProfileWithLabels(p []StackRecord, labels []unsafe.Pointer) (n int
 // of calling GoroutineProfile directly.
 func GoroutineProfile(p []StackRecord) (n int, ok bool) {
 
-	return goroutineProfileWithLabels(p, nil)
+	return goroutineProfileWithLabels(p, nil, nil)
+}
+
+// goroutineProfiled reports whether the goroutine profile taken by gp
+// includes gp1 after gp itself.
+func goroutineProfiled(gp, gp1 *g) bool {
+	// Checking isSystemGoroutine here makes GoroutineProfile
+	// consistent with both NumGoroutine and Stack.
+	return gp1 != gp && readgstatus(gp1) != _Gdead && !isSystemGoroutine(gp1, false)
+}
+
+// GoroutineRecord describes a goroutine in the records returned by
+// GoroutineProfileEx: its stack, as in the records of GoroutineProfile,
+// and what it is waiting for.
+type GoroutineRecord struct {
+	StackRecord
+
+	// WaitReason is why the goroutine is blocked, as shown in its
+	// traceback, such as "chan receive (empty)" or "select". It is empty if
+	// the goroutine is not blocked.
+	WaitReason string
+
+	// WaitNs is how long the goroutine has been blocked, in
+	// nanoseconds. It is exact for goroutines that blocked on
+	// channels after the first call to GoroutineProfileEx. For
+	// goroutines blocked on channels before, it counts from the
+	// first call that found the goroutine blocked. For other
+	// goroutines, it counts from the first garbage collection that
+	// found the goroutine blocked. It is zero until then.
+	WaitNs int64
+
+	// Chan is the address of the channel the goroutine is blocked
+	// sending to or receiving from, and ChanElem is the type of the
+	// channel's elements. Chan is zero if the goroutine is not
+	// blocked on a single channel, including in a select.
+	Chan     uintptr
+	ChanElem string
+}
+
+// GoroutineProfileEx is like GoroutineProfile, but also reports what
+// each goroutine is waiting for. It returns n, the number of records in
+// the active goroutine stack profile. If len(p) >= n, GoroutineProfileEx
+// copies the profile into p and returns n, true. If len(p) < n,
+// GoroutineProfileEx does not change p and returns n, false.
+//
+// The records are taken with the world stopped, so they describe all
+// the goroutines at the same point in time.
+func GoroutineProfileEx(p []GoroutineRecord) (n int, ok bool) {
+	gp := getg()
+	chanWaitTime()
+
+	stopTheWorld("profile")
+
+	// World is stopped, no locking required.
+	n = 1
+	forEachGRace(func(gp1 *g) {
+		if goroutineProfiled(gp, gp1) {
+			n++
+		}
+	})
+
+	if n <= len(p) {
+		ok = true
+		r := p
+		now := nanotime()
+
+		// Save current goroutine.
+		sp := getcallersp()
+		pc := getcallerpc()
+		systemstack(func() {
+			saveg(pc, sp, gp, &r[0].StackRecord)
+		})
+		r[0].saveWait(gp, now)
+		r = r[1:]
+
+		// Save other goroutines.
+		forEachGRace(func(gp1 *g) {
+			if !goroutineProfiled(gp, gp1) {
+				return
+			}
+			if len(r) == 0 {
+				// Should be impossible, but better to return a
+				// truncated profile than to crash the entire process.
+				return
+			}
+			saveg(^uintptr(0), ^uintptr(0), gp1, &r[0].StackRecord)
+			r[0].saveWait(gp1, now)
+			r = r[1:]
+		})
+	}
+
+	startTheWorld()
+	return n, ok
+}
+
+// saveWait records in r what gp is waiting for at time now.
+//
+// The world must be stopped.
+func (r *GoroutineRecord) saveWait(gp *g, now int64) {
+	r.WaitReason, r.WaitNs, r.Chan, r.ChanElem = "", 0, 0, ""
+	if readgstatus(gp)&^_Gscan != _Gwaiting {
+		return
+	}
+	r.WaitReason = gp.waitreason.String()
+	if since := chanWaitSince(gp, now); since != 0 && now > since {
+		r.WaitNs = now - since
+	}
+	if c := goroutineWaitChan(gp); c != nil {
+		r.Chan = uintptr(unsafe.Pointer(c))
+		r.ChanElem = c.elemtype.string()
+	}
+}
+
+// goroutineWaitChan returns the channel gp is blocked sending to or
+// receiving from, or nil if gp is not blocked on a single channel.
+// Goroutines blocked in select are not attributed to any of their
+// cases.
+//
+// The world must be stopped, or gp held in _Gscanwaiting.
+func goroutineWaitChan(gp *g) *hchan {
+	if readgstatus(gp)&^_Gscan != _Gwaiting {
+		return nil
+	}
+	if !gp.waitreason.isChanSend() && !gp.waitreason.isChanRecv() {
+		return nil
+	}
+	// chansend and chanrecv set gp.waiting to their sudog before
+	// parking and clear it only after they resume running.
+	if sg := gp.waiting; sg != nil {
+		return sg.c
+	}
+	return nil
 }
 
 func saveg(pc, sp uintptr, gp *g, r *StackRecord) {
@@ -834,6 +1043,22 @@ func saveg(pc, sp uintptr, gp *g, r *StackRecord) {
 // If all is true, Stack formats stack traces of all other goroutines
 // into buf after the trace for the current goroutine.
 func Stack(buf []byte, all bool) int {
+	return stackdump(buf, all, getcallerpc(), getcallersp(), debug.tracebackgroup)
+}
+
+//go:linkname pprof_stackGrouped runtime/pprof.runtime_stackGrouped
+func pprof_stackGrouped(buf []byte) int {
+	min := debug.tracebackgroup
+	if min <= 0 {
+		min = defaultTracebackGroupMin
+	}
+	return stackdump(buf, true, getcallerpc(), getcallersp(), min)
+}
+
+// stackdump implements Stack for a caller at pc and sp, collapsing groups
+// of at least group goroutines with alike tracebacks if group > 0.
+// See tracebackgroup.go.
+func stackdump(buf []byte, all bool, pc, sp uintptr, group int32) int {
 	if all {
 		stopTheWorld("stack trace")
 	}
@@ -841,8 +1066,6 @@ func Stack(buf []byte, all bool) int {
 	n := 0
 	if len(buf) > 0 {
 		gp := getg()
-		sp := getcallersp()
-		pc := getcallerpc()
 		systemstack(func() {
 			g0 := getg()
 			// Force traceback=1 to override GOTRACEBACK setting,
@@ -853,7 +1076,7 @@ func Stack(buf []byte, all bool) int {
 			goroutineheader(gp)
 			traceback(pc, sp, 0, gp)
 			if all {
-				tracebackothers(gp)
+				tracebackothersgroup(gp, group)
 			}
 			g0.m.traceback = 0
 			n = len(g0.writebuf)
@@ -96,6 +96,15 @@ type pollDesc struct {
 	rg uintptr // pdReady, pdWait, G waiting for read or nil
 	wg uintptr // pdReady, pdWait, G waiting for write or nil
 
+	// rnotify and wnotify hold the *hchan on which readiness for
+	// reading and writing is announced (see poll_runtime_pollSetNotify),
+	// or 0. They are written atomically while holding lock, and read
+	// atomically by netpollready before it takes lock. The channels are
+	// kept alive by internal/poll, which clears them before the
+	// descriptor can become unreachable.
+	rnotify uintptr
+	wnotify uintptr
+
 	lock    mutex // protects the following fields
 	closing bool
 	user    uint32    // user settable cookie
@@ -233,6 +242,8 @@ func poll_runtime_pollOpen(fd uintptr) (*pollDesc, int) {
 	pd.wseq++
 	atomic.Storeuintptr(&pd.wg, 0)
 	pd.wd = 0
+	atomic.Storeuintptr(&pd.rnotify, 0)
+	atomic.Storeuintptr(&pd.wnotify, 0)
 	pd.self = pd
 	pd.publishInfo()
 	unlock(&pd.lock)
@@ -411,6 +422,8 @@ func poll_runtime_pollUnblock(pd *pollDesc) {
 	pd.closing = true
 	pd.rseq++
 	pd.wseq++
+	atomic.Storeuintptr(&pd.rnotify, 0)
+	atomic.Storeuintptr(&pd.wnotify, 0)
 	var rg, wg *g
 	pd.publishInfo()
 	rg = netpollunblock(pd, 'r', false)
@@ -432,6 +445,64 @@ func poll_runtime_pollUnblock(pd *pollDesc) {
 	}
 }
 
+// poll_runtime_pollSetNotify, which is internal/poll.runtime_pollSetNotify,
+// arranges for the network poller to perform a non-blocking send on c
+// whenever the descriptor becomes ready in mode, which is 'r' or 'w'.
+// A nil c cancels notification. c must have a zero-sized element type
+// and is usually buffered, so that a notification is kept until it is
+// received. Since the poller is edge-triggered, a notification is also
+// sent right away, to cover readiness that predates the call.
+// This returns an error code; the codes are defined above.
+//go:linkname poll_runtime_pollSetNotify internal/poll.runtime_pollSetNotify
+func poll_runtime_pollSetNotify(pd *pollDesc, mode int, c *hchan) int {
+	if c != nil && c.elemsize != 0 {
+		throw("runtime: poll notification channel has non-zero-sized elements")
+	}
+	lock(&pd.lock)
+	if pd.closing {
+		unlock(&pd.lock)
+		return pollErrClosing
+	}
+	switch mode {
+	case 'r':
+		atomic.Storeuintptr(&pd.rnotify, uintptr(unsafe.Pointer(c)))
+	case 'w':
+		atomic.Storeuintptr(&pd.wnotify, uintptr(unsafe.Pointer(c)))
+	default:
+		throw("runtime: bad poll notification mode")
+	}
+	unlock(&pd.lock)
+	if c != nil {
+		var toRun gList
+		chansendready(c, &toRun)
+		for !toRun.empty() {
+			goready(toRun.pop(), 0)
+		}
+	}
+	return pollNoError
+}
+
+// netpollnotify performs the readiness notification send for pd in
+// mode, which is 'r' or 'w', if one is registered.
+// Receivers woken by the send are added to toRun.
+//go:nowritebarrierrec
+func netpollnotify(toRun *gList, pd *pollDesc, mode int32) {
+	np := &pd.rnotify
+	if mode == 'w' {
+		np = &pd.wnotify
+	}
+	if atomic.Loaduintptr(np) == 0 {
+		return
+	}
+	// Hold pd.lock so that the channel can't be unregistered and
+	// collected while we send on it.
+	lock(&pd.lock)
+	if c := (*hchan)(unsafe.Pointer(atomic.Loaduintptr(np))); c != nil {
+		chansendready(c, toRun)
+	}
+	unlock(&pd.lock)
+}
+
 // netpollready is called by the platform-specific netpoll function.
 // It declares that the fd associated with pd is ready for I/O.
 // The toRun argument is used to build a list of goroutines to return
@@ -454,6 +525,12 @@ func netpollready(toRun *gList, pd *pollDesc, mode int32) {
 	if wg != nil {
 		toRun.push(wg)
 	}
+	if mode == 'r' || mode == 'r'+'w' {
+		netpollnotify(toRun, pd, 'r')
+	}
+	if mode == 'w' || mode == 'r'+'w' {
+		netpollnotify(toRun, pd, 'w')
+	}
 }
 
 func netpollcheckerr(pd *pollDesc, mode int32) int {
@@ -301,6 +301,7 @@ func getHugePageSize() uintptr {
 func osinit() {
 	ncpu = getproccount()
 	physHugePageSize = getHugePageSize()
+	numaInit()
 	if iscgo {
 		// #42494 glibc and musl reserve some signals for
 		// internal use and require they not be blocked by
@@ -1410,6 +1410,7 @@ func dopanic_m(gp *g, pc, sp uintptr) bool {
 	}
 
 	printDebugLog()
+	printChanDecisions()
 
 	return docrash
 }
@@ -105,6 +105,7 @@ import (
 //	threadcreate - stack traces that led to the creation of new OS threads
 //	block        - stack traces that led to blocking on synchronization primitives
 //	mutex        - stack traces of holders of contended mutexes
+//	chanops      - a sampling of channel operations, with GODEBUG=chanprofrate=N
 //
 // These predefined profiles maintain themselves and panic on an explicit
 // Add or Remove method call.
@@ -180,6 +181,12 @@ var mutexProfile = &Profile{
 	write: writeMutex,
 }
 
+var chanopsProfile = &Profile{
+	name:  "chanops",
+	count: countChanOps,
+	write: writeChanOps,
+}
+
 func lockProfiles() {
 	profiles.mu.Lock()
 	if profiles.m == nil {
@@ -191,6 +198,7 @@ func lockProfiles() {
 			"allocs":       allocsProfile,
 			"block":        blockProfile,
 			"mutex":        mutexProfile,
+			"chanops":      chanopsProfile,
 		}
 	}
 }
@@ -322,7 +330,13 @@ func (p *Profile) Remove(value interface{}) {
 // The predefined profiles may assign meaning to other debug values;
 // for example, when printing the "goroutine" profile, debug=2 means to
 // print the goroutine stacks in the same form that a Go program uses
-// when dying due to an unrecovered panic.
+// when dying due to an unrecovered panic, and debug=3 means to write
+// the debug=1 format with each group of goroutines annotated by the
+// number of distinct channels its goroutines are blocked sending to or
+// receiving from and the channel with the most of them waiting. debug=4
+// is like debug=2, but prints goroutines with the same state and stack
+// once, as a group, as described for GODEBUG=tracebackgroup in the
+// runtime package documentation.
 func (p *Profile) WriteTo(w io.Writer, debug int) error {
 	if p.name == "" {
 		panic("pprof: use of zero Profile")
@@ -369,6 +383,13 @@ type countProfile interface {
 	Label(i int) *labelMap
 }
 
+// A chanCountProfile is a countProfile that also knows the channel, if
+// any, that each goroutine in the profile is blocked on.
+type chanCountProfile interface {
+	countProfile
+	Chan(i int) unsafe.Pointer
+}
+
 // printCountCycleProfile outputs block profile records (for block or mutex profiles)
 // as the pprof-proto format output. Translations from cycle count to time duration
 // are done because The proto expects count and time (nanoseconds) instead of count
@@ -419,6 +440,9 @@ func printCountProfile(w io.Writer, debug int, name string, p countProfile) erro
 	count := map[string]int{}
 	index := map[string]int{}
 	var keys []string
+	// waiters counts the goroutines with each key blocked on each channel.
+	var waiters map[string]map[unsafe.Pointer]int
+	cp, _ := p.(chanCountProfile)
 	n := p.Len()
 	for i := 0; i < n; i++ {
 		k := key(p.Stack(i), p.Label(i))
@@ -427,6 +451,18 @@ func printCountProfile(w io.Writer, debug int, name string, p countProfile) erro
 			keys = append(keys, k)
 		}
 		count[k]++
+		if cp == nil {
+			continue
+		}
+		if c := cp.Chan(i); c != nil {
+			if waiters == nil {
+				waiters = make(map[string]map[unsafe.Pointer]int)
+			}
+			if waiters[k] == nil {
+				waiters[k] = make(map[unsafe.Pointer]int)
+			}
+			waiters[k][c]++
+		}
 	}
 
 	sort.Sort(&keysByCount{keys, count})
@@ -437,6 +473,9 @@ func printCountProfile(w io.Writer, debug int, name string, p countProfile) erro
 		fmt.Fprintf(tw, "%s profile: total %d\n", name, p.Len())
 		for _, k := range keys {
 			fmt.Fprintf(tw, "%d %s\n", count[k], k)
+			if waiters[k] != nil {
+				printChanWaiters(tw, waiters[k])
+			}
 			printStackRecord(tw, p.Stack(index[k]), false)
 		}
 		return tw.Flush()
@@ -470,6 +509,23 @@ func printCountProfile(w io.Writer, debug int, name string, p countProfile) erro
 	return nil
 }
 
+// printChanWaiters prints a comment line summarizing the channels a
+// group of goroutines is blocked on: how many distinct channels there
+// are, and which one has the most waiters. Many goroutines waiting on
+// one channel suggest a bottleneck; many goroutines each waiting on
+// its own channel are usually benign fan-out.
+func printChanWaiters(w io.Writer, waiters map[unsafe.Pointer]int) {
+	var top unsafe.Pointer
+	for c, n := range waiters {
+		// Break ties by address so the output is deterministic.
+		if top == nil || n > waiters[top] || n == waiters[top] && uintptr(c) < uintptr(top) {
+			top = c
+		}
+	}
+	fmt.Fprintf(w, "# chans: %d distinct, top %p (chan %s) with %d waiters\n",
+		len(waiters), top, runtime_chanElemString(top), waiters[top])
+}
+
 // keysByCount sorts keys with higher counts first, breaking ties by key string order.
 type keysByCount struct {
 	keys  []string
@@ -676,21 +732,67 @@ func countGoroutine() int {
 // runtime_goroutineProfileWithLabels is defined in runtime/mprof.go
 func runtime_goroutineProfileWithLabels(p []runtime.StackRecord, labels []unsafe.Pointer) (n int, ok bool)
 
+// runtime_goroutineProfileWithChans is defined in runtime/mprof.go
+func runtime_goroutineProfileWithChans(p []runtime.StackRecord, labels, chans []unsafe.Pointer) (n int, ok bool)
+
+// runtime_chanElemString is defined in runtime/mprof.go
+func runtime_chanElemString(c unsafe.Pointer) string
+
+// runtime_stackGrouped is defined in runtime/mprof.go
+func runtime_stackGrouped(buf []byte) int
+
 // writeGoroutine writes the current runtime GoroutineProfile to w.
 func writeGoroutine(w io.Writer, debug int) error {
-	if debug >= 2 {
+	switch {
+	case debug == 3:
+		return writeGoroutineChans(w)
+	case debug == 4:
+		return writeGoroutineStacksGrouped(w)
+	case debug >= 2:
 		return writeGoroutineStacks(w)
 	}
 	return writeRuntimeProfile(w, debug, "goroutine", runtime_goroutineProfileWithLabels)
 }
 
+// writeGoroutineChans writes the goroutine profile in the legacy text
+// format, annotating each group with the channels its goroutines are
+// blocked on.
+func writeGoroutineChans(w io.Writer) error {
+	// Same as writeRuntimeProfile.
+	var p []runtime.StackRecord
+	var labels, chans []unsafe.Pointer
+	n, ok := runtime_goroutineProfileWithChans(nil, nil, nil)
+	for {
+		p = make([]runtime.StackRecord, n+10)
+		labels = make([]unsafe.Pointer, n+10)
+		chans = make([]unsafe.Pointer, n+10)
+		n, ok = runtime_goroutineProfileWithChans(p, labels, chans)
+		if ok {
+			p = p[0:n]
+			break
+		}
+	}
+
+	return printCountProfile(w, 1, "goroutine", &runtimeProfile{stk: p, labels: labels, chans: chans})
+}
+
 func writeGoroutineStacks(w io.Writer) error {
+	return writeStacks(w, func(buf []byte) int { return runtime.Stack(buf, true) })
+}
+
+// writeGoroutineStacksGrouped is like writeGoroutineStacks, but
+// collapses groups of goroutines with the same stack.
+func writeGoroutineStacksGrouped(w io.Writer) error {
+	return writeStacks(w, runtime_stackGrouped)
+}
+
+func writeStacks(w io.Writer, stack func([]byte) int) error {
 	// We don't know how big the buffer needs to be to collect
 	// all the goroutines. Start with 1 MB and try a few times, doubling each time.
 	// Give up and use a truncated trace if 64 MB is not enough.
 	buf := make([]byte, 1<<20)
 	for i := 0; ; i++ {
-		n := runtime.Stack(buf, true)
+		n := stack(buf)
 		if n < len(buf) {
 			buf = buf[:n]
 			break
@@ -729,18 +831,26 @@ func writeRuntimeProfile(w io.Writer, debug int, name string, fetch func([]runti
 		// Profile grew; try again.
 	}
 
-	return printCountProfile(w, debug, name, &runtimeProfile{p, labels})
+	return printCountProfile(w, debug, name, &runtimeProfile{stk: p, labels: labels})
 }
 
 type runtimeProfile struct {
 	stk    []runtime.StackRecord
 	labels []unsafe.Pointer
+	chans  []unsafe.Pointer // may be nil
 }
 
 func (p *runtimeProfile) Len() int              { return len(p.stk) }
 func (p *runtimeProfile) Stack(i int) []uintptr { return p.stk[i].Stack() }
 func (p *runtimeProfile) Label(i int) *labelMap { return (*labelMap)(p.labels[i]) }
 
+func (p *runtimeProfile) Chan(i int) unsafe.Pointer {
+	if p.chans == nil {
+		return nil
+	}
+	return p.chans[i]
+}
+
 var cpu struct {
 	sync.Mutex
 	profiling bool
@@ -911,3 +1021,111 @@ func scaleMutexProfile(cnt int64, ns float64) (int64, float64) {
 }
 
 func runtime_cyclesPerSecond() int64
+
+// runtime_chanProfRate is defined in runtime/chanprof.go
+func runtime_chanProfRate() int
+
+// runtime_chanOpsProfile is defined in runtime/chanprof.go
+func runtime_chanOpsProfile(p []runtime.StackRecord, ops []uint8, counts []int64) (n int, ok bool)
+
+// Kinds of channel operations in the chanops profile, as defined in
+// runtime/chanprof.go.
+const (
+	chanOpSend    = 0
+	chanOpRecv    = 1
+	chanOpSelect  = 2
+	chanOpBlocked = 4
+)
+
+// chanOpLabels returns the values of the "op" and "blocked" labels of
+// a chanops profile sample of kind op.
+func chanOpLabels(op uint8) (kind, blocked string) {
+	switch op &^ chanOpBlocked {
+	case chanOpSend:
+		kind = "send"
+	case chanOpRecv:
+		kind = "recv"
+	case chanOpSelect:
+		kind = "select"
+	default:
+		kind = fmt.Sprint(op &^ chanOpBlocked)
+	}
+	blocked = "false"
+	if op&chanOpBlocked != 0 {
+		blocked = "true"
+	}
+	return kind, blocked
+}
+
+// countChanOps returns the number of records in the chanops profile.
+func countChanOps() int {
+	n, _ := runtime_chanOpsProfile(nil, nil, nil)
+	return n
+}
+
+// writeChanOps writes the current chanops profile to w. Each sample
+// stands for as many operations as the sampling period, so the counts
+// are scaled by it, and is labeled with the kind of the operation,
+// "send", "recv" or "select", as op, and with whether it blocked.
+func writeChanOps(w io.Writer, debug int) error {
+	var p []runtime.StackRecord
+	var ops []uint8
+	var counts []int64
+	n, ok := runtime_chanOpsProfile(nil, nil, nil)
+	for {
+		p = make([]runtime.StackRecord, n+50)
+		ops = make([]uint8, n+50)
+		counts = make([]int64, n+50)
+		n, ok = runtime_chanOpsProfile(p, ops, counts)
+		if ok {
+			p, ops, counts = p[:n], ops[:n], counts[:n]
+			break
+		}
+	}
+	index := make([]int, n)
+	for i := range index {
+		index[i] = i
+	}
+	sort.Slice(index, func(i, j int) bool { return counts[index[i]] > counts[index[j]] })
+
+	period := int64(runtime_chanProfRate())
+	if period <= 0 {
+		period = 1
+	}
+
+	if debug <= 0 {
+		b := newProfileBuilder(w)
+		b.pbValueType(tagProfile_PeriodType, "operations", "count")
+		b.pb.int64Opt(tagProfile_Period, period)
+		b.pbValueType(tagProfile_SampleType, "samples", "count")
+		b.pbValueType(tagProfile_SampleType, "operations", "count")
+		values := []int64{0, 0}
+		var locs []uint64
+		for _, i := range index {
+			values[0] = counts[i]
+			values[1] = counts[i] * period
+			locs = b.appendLocsForStack(locs[:0], p[i].Stack())
+			kind, blocked := chanOpLabels(ops[i])
+			b.pbSample(values, locs, func() {
+				b.pbLabel(tagSample_Label, "op", kind, 0)
+				b.pbLabel(tagSample_Label, "blocked", blocked, 0)
+			})
+		}
+		b.build()
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 1, 8, 1, '\t', 0)
+	fmt.Fprintf(tw, "--- chanops:\n")
+	fmt.Fprintf(tw, "sampling period=%d\n", period)
+	for _, i := range index {
+		fmt.Fprintf(tw, "%v %v @", counts[i]*period, counts[i])
+		for _, pc := range p[i].Stack() {
+			fmt.Fprintf(tw, " %#x", pc)
+		}
+		kind, blocked := chanOpLabels(ops[i])
+		fmt.Fprintf(tw, "\n# labels: {\"blocked\":\"%s\", \"op\":\"%s\"}\n", blocked, kind)
+		printStackRecord(tw, p[i].Stack(), true)
+	}
+	return tw.Flush()
+}
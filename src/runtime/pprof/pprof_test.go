@@ -1041,6 +1041,146 @@ func TestMutexProfile(t *testing.T) {
 	})
 }
 
+// chanOpsSend sends on c, which must have room for a value.
+//
+//go:noinline
+func chanOpsSend(c chan int) {
+	c <- 1
+}
+
+// chanOpsBlockedRecv receives from c, which must be empty, blocking
+// until another goroutine sends.
+//
+//go:noinline
+func chanOpsBlockedRecv(c chan int) {
+	<-c
+}
+
+func TestChanOpsProfile(t *testing.T) {
+	if os.Getenv("TEST_CHANOPS_PROFILE") != "1" {
+		testenv.MustHaveExec(t)
+		cmd := exec.Command(os.Args[0], "-test.run=^TestChanOpsProfile$")
+		cmd.Env = append(os.Environ(), "TEST_CHANOPS_PROFILE=1", "GODEBUG=chanprofrate=1")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("%v\n%s", err, out)
+		}
+		return
+	}
+
+	c := make(chan int, 1)
+	chanOpsSend(c)
+	<-c
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		c <- 1
+	}()
+	chanOpsBlockedRecv(c)
+
+	t.Run("debug=1", func(t *testing.T) {
+		var w bytes.Buffer
+		Lookup("chanops").WriteTo(&w, 1)
+		prof := w.String()
+		if !strings.HasPrefix(prof, "--- chanops:\nsampling period=1\n") {
+			t.Errorf("bad profile header:\n%v", prof)
+		}
+		for _, re := range []string{
+			`(?m)^\d+ \d+ @( 0x[[:xdigit:]]+)+\n# labels: {"blocked":"false", "op":"send"}\n#\t0x[[:xdigit:]]+\truntime.chansend1\+0x[[:xdigit:]]+\t.*\n#\t0x[[:xdigit:]]+\truntime/pprof.chanOpsSend\+`,
+			`(?m)^\d+ \d+ @( 0x[[:xdigit:]]+)+\n# labels: {"blocked":"true", "op":"recv"}\n#\t0x[[:xdigit:]]+\truntime.chanrecv1\+0x[[:xdigit:]]+\t.*\n#\t0x[[:xdigit:]]+\truntime/pprof.chanOpsBlockedRecv\+`,
+		} {
+			if !regexp.MustCompile(re).MatchString(prof) {
+				t.Errorf("profile does not match %q:\n%s", re, prof)
+			}
+		}
+	})
+	t.Run("proto", func(t *testing.T) {
+		var w bytes.Buffer
+		Lookup("chanops").WriteTo(&w, 0)
+		p, err := profile.Parse(&w)
+		if err != nil {
+			t.Fatalf("failed to parse profile: %v", err)
+		}
+		if err := p.CheckValid(); err != nil {
+			t.Fatalf("invalid profile: %v", err)
+		}
+		found := false
+		for _, s := range p.Sample {
+			if len(s.Location) < 2 || s.Location[1].Line[0].Function.Name != "runtime/pprof.chanOpsBlockedRecv" {
+				continue
+			}
+			found = true
+			if op, blocked := s.Label["op"], s.Label["blocked"]; len(op) != 1 || op[0] != "recv" || len(blocked) != 1 || blocked[0] != "true" {
+				t.Errorf("blocked receive labeled op=%v blocked=%v", op, blocked)
+			}
+			if s.Value[0] < 1 || s.Value[1] != s.Value[0] {
+				t.Errorf("blocked receive has values %v, want equal sample and operation counts", s.Value)
+			}
+		}
+		if !found {
+			t.Errorf("no sample of the blocked receive:\n%s", p)
+		}
+	})
+}
+
+func TestMutexProfileChan(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	old := runtime.SetMutexProfileFraction(1)
+	defer runtime.SetMutexProfileFraction(old)
+
+	// Contention on the channel lock is timing dependent, so keep
+	// hammering the channel until a sample shows up.
+	want := []string{"runtime.chansend", "runtime.chansend1", "runtime/pprof.contendChanSend.func2"}
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		contendChanSend()
+
+		var w bytes.Buffer
+		Lookup("mutex").WriteTo(&w, 0)
+		p, err := profile.Parse(&w)
+		if err != nil {
+			t.Fatalf("failed to parse profile: %v", err)
+		}
+		if err := p.CheckValid(); err != nil {
+			t.Fatalf("invalid profile: %v", err)
+		}
+		if containsStack(stacks(p), want) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("No matching stack entry for %+v in profile:\n%s", want, p)
+		}
+	}
+}
+
+// contendChanSend sends on a buffered channel from several goroutines
+// at once so that they contend on the channel lock.
+func contendChanSend() {
+	const (
+		senders = 4
+		sends   = 10000
+	)
+	c := make(chan int, 100)
+	done := make(chan bool)
+	go func() {
+		for range c {
+		}
+		close(done)
+	}()
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < sends; j++ {
+				c <- j
+			}
+		}()
+	}
+	wg.Wait()
+	close(c)
+	<-done
+}
+
 func func1(c chan int) { <-c }
 func func2(c chan int) { <-c }
 func func3(c chan int) { <-c }
@@ -1130,6 +1270,88 @@ func TestGoroutineCounts(t *testing.T) {
 	time.Sleep(10 * time.Millisecond) // let goroutines exit
 }
 
+func chanPileUp(c chan int) { <-c }
+func chanFanOut(c chan int) { <-c }
+
+func TestGoroutineProfileChans(t *testing.T) {
+	// Setting GOMAXPROCS to 1 ensures we can force all goroutines to the
+	// desired blocking point.
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+
+	c := make(chan int)
+	var fanOut []chan int
+	for i := 0; i < 20; i++ {
+		go chanPileUp(c)
+		if i%2 == 0 {
+			fc := make(chan int)
+			fanOut = append(fanOut, fc)
+			go chanFanOut(fc)
+		}
+		// Let goroutines block on channel
+		for j := 0; j < 5; j++ {
+			runtime.Gosched()
+		}
+	}
+
+	var w bytes.Buffer
+	if err := Lookup("goroutine").WriteTo(&w, 3); err != nil {
+		t.Fatal(err)
+	}
+	prof := w.String()
+	top := fmt.Sprintf("top %p (chan int)", c)
+	if !containsInOrder(prof, "\n20 @ ", "\n# chans: 1 distinct, "+top+" with 20 waiters\n", "chanPileUp",
+		"\n10 @ ", "\n# chans: 10 distinct, ", " with 1 waiters\n", "chanFanOut") {
+		t.Errorf("expected goroutine groups annotated with channel waiters:\n%s", prof)
+	}
+
+	close(c)
+	for _, fc := range fanOut {
+		close(fc)
+	}
+	time.Sleep(10 * time.Millisecond) // let goroutines exit
+}
+
+func TestGoroutineStacksGrouped(t *testing.T) {
+	// Setting GOMAXPROCS to 1 ensures we can force all goroutines to the
+	// desired blocking point.
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+
+	c := make(chan int)
+	var fanOut []chan int
+	for i := 0; i < 20; i++ {
+		go chanPileUp(c)
+		if i < 2 {
+			fc := make(chan int)
+			fanOut = append(fanOut, fc)
+			go chanFanOut(fc)
+		}
+		// Let goroutines block on channel
+		for j := 0; j < 5; j++ {
+			runtime.Gosched()
+		}
+	}
+
+	var w bytes.Buffer
+	if err := Lookup("goroutine").WriteTo(&w, 4); err != nil {
+		t.Fatal(err)
+	}
+	prof := w.String()
+	on := fmt.Sprintf("\ton 1 chan: %p (chan int, len 0, cap 0)\n", c)
+	if !containsInOrder(prof, "\n20 goroutines [chan receive (sync)]:\n", " ... (+10 more)\n", on, "chanPileUp") {
+		t.Errorf("expected chanPileUp goroutines collapsed into one group:\n%s", prof)
+	}
+	// Groups smaller than the default threshold are printed in full.
+	if n := strings.Count(prof, "chanFanOut"); n != 2 {
+		t.Errorf("found %d chanFanOut stacks, want 2:\n%s", n, prof)
+	}
+
+	close(c)
+	for _, fc := range fanOut {
+		close(fc)
+	}
+	time.Sleep(10 * time.Millisecond) // let goroutines exit
+}
+
 func containsInOrder(s string, all ...string) bool {
 	for _, t := range all {
 		i := strings.Index(s, t)
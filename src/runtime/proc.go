@@ -274,6 +274,7 @@ func main() {
 		gopark(nil, nil, waitReasonPanicWait, traceEvGoStop, 1)
 	}
 
+	chanExitReport()
 	exit(0)
 	for {
 		var x *int32
@@ -287,6 +288,7 @@ func os_beforeExit() {
 	if raceenabled {
 		racefini()
 	}
+	chanExitReport()
 }
 
 // start forcegc helper goroutine
@@ -404,6 +406,10 @@ func acquireSudog() *sudog {
 		// If the central cache is empty, allocate a new one.
 		if len(pp.sudogcache) == 0 {
 			pp.sudogcache = append(pp.sudogcache, new(sudog))
+			pp.chanStats.sudogsCached++
+			if debug.sudogcachemax > 0 {
+				atomic.Xaddint64(&sudogCacheCount, 1)
+			}
 		}
 	}
 	n := len(pp.sudogcache)
@@ -413,18 +419,66 @@ func acquireSudog() *sudog {
 	if s.elem != nil {
 		throw("acquireSudog: found s.elem != nil in cache")
 	}
+	pp.chanStats.sudogsLive++
+	pp.chanStats.sudogsCached--
+	if debug.sudogcachemax > 0 {
+		atomic.Xaddint64(&sudogCacheCount, -1)
+	}
 	releasem(mp)
 	return s
 }
 
+// sudogCacheCount is the number of sudogs in the per-P and central
+// caches, like the sum of the chanStats.sudogsCached counters, while
+// GODEBUG=sudogcachemax is set. It is not maintained otherwise.
+// Accessed atomically.
+var sudogCacheCount int64
+
+// sudogFlushAll is set by debug.FreeOSMemory to have the next GC drop
+// the per-P sudog caches along with the central one. Accessed
+// atomically.
+var sudogFlushAll uint32
+
+// sudogCacheAdmit reserves room for one more sudog in the caches, which
+// hold at most max bytes of sudogs, and reports whether there was any.
+//
+//go:nosplit
+func sudogCacheAdmit(max int32) bool {
+	limit := int64(max) / int64(unsafe.Sizeof(sudog{}))
+	if atomic.Xaddint64(&sudogCacheCount, 1) > limit {
+		atomic.Xaddint64(&sudogCacheCount, -1)
+		return false
+	}
+	return true
+}
+
+// flushSudogCaches drops the sudogs in all the per-P caches. The world
+// must be stopped.
+func flushSudogCaches() {
+	for _, pp := range allp {
+		n := int64(len(pp.sudogcache))
+		pp.chanStats.sudogsCached -= n
+		if debug.sudogcachemax > 0 {
+			atomic.Xaddint64(&sudogCacheCount, -n)
+		}
+		for i := range pp.sudogbuf {
+			pp.sudogbuf[i] = nil
+		}
+		pp.sudogcache = pp.sudogbuf[:0]
+	}
+}
+
 //go:nosplit
 func releaseSudog(s *sudog) {
 	if s.elem != nil {
 		throw("runtime: sudog with non-nil elem")
 	}
-	if s.isSelect {
+	if s.isSelect() {
 		throw("runtime: sudog with non-false isSelect")
 	}
+	if s.cancelable() {
+		throw("runtime: sudog with non-false cancelable")
+	}
 	if s.next != nil {
 		throw("runtime: sudog with non-nil next")
 	}
@@ -437,12 +491,24 @@ func releaseSudog(s *sudog) {
 	if s.c != nil {
 		throw("runtime: sudog with non-nil c")
 	}
+	if s.woken {
+		throw("runtime: sudog with woken set")
+	}
+	if s.queued {
+		throw("runtime: sudog with queued set")
+	}
 	gp := getg()
 	if gp.param != nil {
 		throw("runtime: releaseSudog with non-nil gp.param")
 	}
 	mp := acquirem() // avoid rescheduling to another P
 	pp := mp.p.ptr()
+	if max := debug.sudogcachemax; max > 0 && !sudogCacheAdmit(max) {
+		// The caches are full. Leave s to the garbage collector.
+		pp.chanStats.sudogsLive--
+		releasem(mp)
+		return
+	}
 	if len(pp.sudogcache) == cap(pp.sudogcache) {
 		// Transfer half of local cache to the central cache.
 		var first, last *sudog
@@ -464,6 +530,8 @@ func releaseSudog(s *sudog) {
 		unlock(&sched.sudoglock)
 	}
 	pp.sudogcache = append(pp.sudogcache, s)
+	pp.chanStats.sudogsLive--
+	pp.chanStats.sudogsCached++
 	releasem(mp)
 }
 
@@ -667,6 +735,7 @@ func schedinit() {
 	lockInit(&trace.stringsLock, lockRankTraceStrings)
 	lockInit(&trace.lock, lockRankTrace)
 	lockInit(&cpuprof.lock, lockRankCpuprof)
+	lockInit(&chanRegistry.lock, lockRankChanRegistry)
 	lockInit(&trace.stackTab.lock, lockRankTraceStackTab)
 	// Enforce that this lock is always a leaf lock.
 	// All of this lock's critical sections should be
@@ -708,6 +777,7 @@ func schedinit() {
 	goenvs()
 	parsedebugvars()
 	gcinit()
+	chanDecisionInit()
 
 	lock(&sched.lock)
 	sched.lastpoll = uint64(nanotime())
@@ -830,8 +900,14 @@ func fastrandinit() {
 
 // Mark gp ready to run.
 func ready(gp *g, traceskip int, next bool) {
+	readyCause(gp, traceskip, next, traceUnblockOther)
+}
+
+// readyCause is like ready, but records cause (one of traceUnblock*)
+// as the reason gp was woken in the execution trace.
+func readyCause(gp *g, traceskip int, next bool, cause uint8) {
 	if trace.enabled {
-		traceGoUnpark(gp, traceskip)
+		traceGoUnparkCause(gp, traceskip, cause)
 	}
 
 	status := readgstatus(gp)
@@ -844,6 +920,10 @@ func ready(gp *g, traceskip int, next bool) {
 		throw("bad g->status in ready")
 	}
 
+	if gp.timeGroupBlocked {
+		timeGroupReady(gp)
+	}
+
 	// status is Gwaiting or Gscanwaiting, make Grunnable and put on runq
 	casgstatus(gp, _Gwaiting, _Grunnable)
 	runqput(_g_.m.p.ptr(), gp, next)
@@ -851,6 +931,33 @@ func ready(gp *g, traceskip int, next bool) {
 	releasem(mp)
 }
 
+// readyglobal is like readyCause, but puts gp on the global run queue
+// rather than on the current P's run queue.
+func readyglobal(gp *g, traceskip int, cause uint8) {
+	if trace.enabled {
+		traceGoUnparkCause(gp, traceskip, cause)
+	}
+
+	status := readgstatus(gp)
+
+	mp := acquirem()
+	if status&^_Gscan != _Gwaiting {
+		dumpgstatus(gp)
+		throw("bad g->status in ready")
+	}
+
+	if gp.timeGroupBlocked {
+		timeGroupReady(gp)
+	}
+
+	casgstatus(gp, _Gwaiting, _Grunnable)
+	lock(&sched.lock)
+	globrunqput(gp)
+	unlock(&sched.lock)
+	wakep()
+	releasem(mp)
+}
+
 // freezeStopWait is a large value that freezetheworld sets
 // sched.stopwait to in order to request that all Gs permanently stop.
 const freezeStopWait = 0x7fffffff
@@ -1237,6 +1344,7 @@ func stopTheWorldWithSema() {
 	}
 
 	worldStopped()
+	_g_.m.chanForbid = "with the world stopped"
 }
 
 func startTheWorldWithSema(emitTraceEvent bool) int64 {
@@ -1263,6 +1371,7 @@ func startTheWorldWithSema(emitTraceEvent bool) int64 {
 	unlock(&sched.lock)
 
 	worldStarted()
+	mp.chanForbid = ""
 
 	for p1 != nil {
 		p := p1
@@ -2754,7 +2863,9 @@ top:
 	// not set lastpoll yet), this thread will do blocking netpoll below
 	// anyway.
 	if netpollinited() && atomic.Load(&netpollWaiters) > 0 && atomic.Load64(&sched.lastpoll) != 0 {
-		if list := netpoll(0); !list.empty() { // non-blocking
+		list := netpoll(0) // non-blocking
+		sigChanDeliver(&list)
+		if !list.empty() {
 			gp := list.pop()
 			injectglist(&list)
 			casgstatus(gp, _Gwaiting, _Grunnable)
@@ -2799,7 +2910,7 @@ top:
 	// If we're in the GC mark phase, can safely scan and blacken objects,
 	// and have work to do, run idle-time marking rather than give up the
 	// P.
-	if gcBlackenEnabled != 0 && gcMarkWorkAvailable(_p_) {
+	if gcBlackenEnabled != 0 && gcMarkWorkAvailable(_p_) && idleMarkAllowed(now) {
 		node := (*gcBgMarkWorkerNode)(gcBgMarkWorkerPool.pop())
 		if node != nil {
 			_p_.gcMarkWorkerMode = gcMarkWorkerIdleMode
@@ -2946,6 +3057,7 @@ top:
 			delay = 0
 		}
 		list := netpoll(delay) // block until new work is available
+		sigChanDeliver(&list)
 		atomic.Store64(&sched.pollUntil, 0)
 		atomic.Store64(&sched.lastpoll, uint64(nanotime()))
 		if faketime != 0 && list.empty() {
@@ -2991,6 +3103,9 @@ top:
 // background work loops, like idle GC. It checks a subset of the
 // conditions checked by the actual scheduler.
 func pollWork() bool {
+	if idleMarkYield() {
+		return true
+	}
 	if sched.runqsize != 0 {
 		return true
 	}
@@ -3502,6 +3617,11 @@ func park_m(gp *g) {
 	casgstatus(gp, _Grunning, _Gwaiting)
 	dropg()
 
+	// Once the unlock function runs, gp may be readied, run, and
+	// even exit on another M, so save its group now.
+	grp := gp.timeGroup
+	groupIdle := grp != nil && timeGroupPark(gp)
+
 	if fn := _g_.m.waitunlockf; fn != nil {
 		ok := fn(gp, _g_.m.waitlock)
 		_g_.m.waitunlockf = nil
@@ -3510,14 +3630,29 @@ func park_m(gp *g) {
 			if trace.enabled {
 				traceGoUnpark(gp, 2)
 			}
+			if gp.timeGroupBlocked {
+				timeGroupReady(gp)
+			}
 			casgstatus(gp, _Gwaiting, _Grunnable)
 			execute(gp, true) // Schedule it back, never returns.
 		}
 	}
+	if groupIdle {
+		grp.wakeDriver()
+	}
+	if debug.chandirectswitch > 0 {
+		if next := chanSwitch(); next != nil {
+			execute(next, true) // Never returns.
+		}
+	}
 	schedule()
 }
 
-func goschedImpl(gp *g) {
+// goschedImpl puts gp on the global run queue and schedules another
+// goroutine. If yield is set, gp called Gosched, rather than being
+// preempted, and may switch directly to a goroutine it woke with a
+// channel operation; see chanswitch.go.
+func goschedImpl(gp *g, yield bool) {
 	status := readgstatus(gp)
 	if status&^_Gscan != _Grunning {
 		dumpgstatus(gp)
@@ -3529,6 +3664,11 @@ func goschedImpl(gp *g) {
 	globrunqput(gp)
 	unlock(&sched.lock)
 
+	if yield && debug.chandirectswitch > 0 {
+		if next := chanSwitch(); next != nil {
+			execute(next, true) // Never returns.
+		}
+	}
 	schedule()
 }
 
@@ -3537,7 +3677,7 @@ func gosched_m(gp *g) {
 	if trace.enabled {
 		traceGoSched()
 	}
-	goschedImpl(gp)
+	goschedImpl(gp, true)
 }
 
 // goschedguarded is a forbidden-states-avoided version of gosched_m
@@ -3550,14 +3690,14 @@ func goschedguarded_m(gp *g) {
 	if trace.enabled {
 		traceGoSched()
 	}
-	goschedImpl(gp)
+	goschedImpl(gp, false)
 }
 
 func gopreempt_m(gp *g) {
 	if trace.enabled {
 		traceGoPreempt()
 	}
-	goschedImpl(gp)
+	goschedImpl(gp, false)
 }
 
 // preemptPark parks gp and puts it in _Gpreempted.
@@ -3622,6 +3762,10 @@ func goyield_m(gp *g) {
 // Finishes execution of the current goroutine.
 func goexit1() {
 	if raceenabled {
+		if grp := getg().timeGroup; grp != nil {
+			// Paired with the raceacquire in testing_runTimeGroup.
+			racereleasemerge(unsafe.Pointer(grp))
+		}
 		racegoend()
 	}
 	if trace.enabled {
@@ -3638,6 +3782,9 @@ func goexit0(gp *g) {
 	if isSystemGoroutine(gp, false) {
 		atomic.Xadd(&sched.ngsys, -1)
 	}
+	if gp.timeGroup != nil {
+		timeGroupExit(gp)
+	}
 	gp.m = nil
 	locked := gp.lockedm != 0
 	gp.lockedm = 0
@@ -3650,7 +3797,11 @@ func goexit0(gp *g) {
 	gp.waitreason = 0
 	gp.param = nil
 	gp.labels = nil
+	gp.traceTasks = nil
+	gp.chanLeakScope = 0
 	gp.timer = nil
+	gp.selectLocks = nil
+	gp.selectScratch = nil
 
 	if gcBlackenEnabled != 0 && gp.gcAssistBytes > 0 {
 		// Flush assist credit to the global pool. This gives
@@ -4355,6 +4506,10 @@ func newproc1(fn *funcval, argp unsafe.Pointer, narg int32, callergp *g, callerp
 	newg.startpc = fn.fn
 	if _g_.m.curg != nil {
 		newg.labels = _g_.m.curg.labels
+		newg.chanLeakScope = _g_.m.curg.chanLeakScope
+		if grp := _g_.m.curg.timeGroup; grp != nil {
+			timeGroupStart(grp, newg)
+		}
 	}
 	if isSystemGoroutine(newg, false) {
 		atomic.Xadd(&sched.ngsys, +1)
@@ -4937,6 +5092,13 @@ func (pp *p) destroy() {
 		wbBufFlush1(pp)
 		pp.gcw.dispose()
 	}
+	// Drop pp's cached sudogs rather than moving them to the central
+	// cache, so that shrinking GOMAXPROCS releases them, as clearpools
+	// does the central cache at each GC.
+	pp.chanStats.sudogsCached -= int64(len(pp.sudogcache))
+	if debug.sudogcachemax > 0 {
+		atomic.Xaddint64(&sudogCacheCount, -int64(len(pp.sudogcache)))
+	}
 	for i := range pp.sudogbuf {
 		pp.sudogbuf[i] = nil
 	}
@@ -4959,6 +5121,7 @@ func (pp *p) destroy() {
 	})
 	freemcache(pp.mcache)
 	pp.mcache = nil
+	pp.chanStats.flush()
 	gfpurge(pp)
 	traceProcFree(pp)
 	if raceenabled {
@@ -5246,6 +5409,7 @@ func checkdead() {
 	}
 
 	grunning := 0
+	var waiting [len(waitReasonStrings)]int32
 	forEachG(func(gp *g) {
 		if isSystemGoroutine(gp, false) {
 			return
@@ -5255,6 +5419,9 @@ func checkdead() {
 		case _Gwaiting,
 			_Gpreempted:
 			grunning++
+			if int(gp.waitreason) < len(waiting) {
+				waiting[gp.waitreason]++
+			}
 		case _Grunnable,
 			_Grunning,
 			_Gsyscall:
@@ -5299,7 +5466,57 @@ func checkdead() {
 
 	getg().m.throwing = -1 // do not dump full stacks
 	unlock(&sched.lock)    // unlock so that GODEBUG=scheddetail=1 doesn't hang
-	throw("all goroutines are asleep - deadlock!")
+	systemstack(func() {
+		print("fatal error: all goroutines are asleep - deadlock!\n")
+		printDeadlockSummary(grunning, &waiting)
+	})
+	fatalthrow()
+}
+
+// printDeadlockSummary prints how many of the n blocked goroutines
+// wait for each reason, as counted by checkdead. The format is stable
+// so that tools can parse it:
+//
+//	goroutines asleep: n
+//		chan send: n
+//		chan receive: n
+//		select: n
+//		nil chan: n
+//		other: n
+//		other <wait reason>: n
+//
+// The channel lines are always printed. "select" includes select
+// statements with no cases, and "nil chan" counts sends and receives
+// on nil channels. One "other" line follows for each other wait
+// reason with a nonzero count, in a fixed order.
+func printDeadlockSummary(n int, waiting *[len(waitReasonStrings)]int32) {
+	send := waiting[waitReasonChanSendSync] + waiting[waitReasonChanSendFull]
+	recv := waiting[waitReasonChanReceiveSync] + waiting[waitReasonChanReceiveEmpty]
+	sel := waiting[waitReasonSelect] + waiting[waitReasonSelectNoCases]
+	nilchan := waiting[waitReasonChanSendNilChan] + waiting[waitReasonChanReceiveNilChan]
+	print("\ngoroutines asleep: ", n, "\n")
+	print("\tchan send: ", send, "\n")
+	print("\tchan receive: ", recv, "\n")
+	print("\tselect: ", sel, "\n")
+	print("\tnil chan: ", nilchan, "\n")
+	print("\tother: ", int32(n)-send-recv-sel-nilchan, "\n")
+	for w, k := range waiting {
+		switch waitReason(w) {
+		case waitReasonChanSendSync, waitReasonChanSendFull,
+			waitReasonChanReceiveSync, waitReasonChanReceiveEmpty,
+			waitReasonSelect, waitReasonSelectNoCases,
+			waitReasonChanSendNilChan, waitReasonChanReceiveNilChan:
+			continue
+		}
+		if k == 0 {
+			continue
+		}
+		reason := waitReason(w).String()
+		if reason == "" {
+			reason = "unknown"
+		}
+		print("\tother ", reason, ": ", k, "\n")
+	}
 }
 
 // forcegcperiod is the maximum time in nanoseconds between garbage
@@ -5323,6 +5540,7 @@ func sysmon() {
 	atomic.Store(&sched.sysmonStarting, 0)
 
 	lasttrace := int64(0)
+	lastblockwarn := int64(0)
 	idle := 0 // how many cycles in succession we had not wokeup somebody
 	delay := uint32(0)
 
@@ -5338,8 +5556,8 @@ func sysmon() {
 		usleep(delay)
 		mDoFixup()
 
-		// sysmon should not enter deep sleep if schedtrace is enabled so that
-		// it can print that information at the right time.
+		// sysmon should not enter deep sleep if schedtrace or chanblockwarn
+		// is enabled so that it can print that information at the right time.
 		//
 		// It should also not enter deep sleep if there are any active P's so
 		// that it can retake P's from syscalls, preempt long running G's, and
@@ -5354,7 +5572,7 @@ func sysmon() {
 		// from a timer to avoid adding system load to applications that spend
 		// most of their time sleeping.
 		now := nanotime()
-		if debug.schedtrace <= 0 && (sched.gcwaiting != 0 || atomic.Load(&sched.npidle) == uint32(gomaxprocs)) {
+		if debug.schedtrace <= 0 && debug.chanblockwarn <= 0 && (sched.gcwaiting != 0 || atomic.Load(&sched.npidle) == uint32(gomaxprocs)) {
 			lock(&sched.lock)
 			if atomic.Load(&sched.gcwaiting) != 0 || atomic.Load(&sched.npidle) == uint32(gomaxprocs) {
 				syscallWake := false
@@ -5403,6 +5621,7 @@ func sysmon() {
 		if netpollinited() && lastpoll != 0 && lastpoll+10*1000*1000 < now {
 			atomic.Cas64(&sched.lastpoll, uint64(lastpoll), uint64(now))
 			list := netpoll(0) // non-blocking - returns list of goroutines
+			sigChanDeliver(&list)
 			if !list.empty() {
 				// Need to decrement number of idle locked M's
 				// (pretending that one more is running) before injectglist.
@@ -5461,6 +5680,10 @@ func sysmon() {
 			lasttrace = now
 			schedtrace(debug.scheddetail > 0)
 		}
+		if debug.chanblockwarn > 0 && lastblockwarn+1e9 <= now {
+			lastblockwarn = now
+			chanBlockWarn(now)
+		}
 		unlock(&sched.sysmonlock)
 	}
 }
@@ -5609,9 +5832,12 @@ func schedtrace(detailed bool) {
 	}
 
 	lock(&sched.lock)
+	// Goroutines blocked on channels, appended to the summary line.
+	cs := readChanStatsTotal()
 	print("SCHED ", (now-starttime)/1e6, "ms: gomaxprocs=", gomaxprocs, " idleprocs=", sched.npidle, " threads=", mcount(), " spinningthreads=", sched.nmspinning, " idlethreads=", sched.nmidle, " runqueue=", sched.runqsize)
 	if detailed {
-		print(" gcwaiting=", sched.gcwaiting, " nmidlelocked=", sched.nmidlelocked, " stopwait=", sched.stopwait, " sysmonwait=", sched.sysmonwait, "\n")
+		print(" gcwaiting=", sched.gcwaiting, " nmidlelocked=", sched.nmidlelocked, " stopwait=", sched.stopwait, " sysmonwait=", sched.sysmonwait)
+		print(" blockedsend=", cs.blockedSend, " blockedrecv=", cs.blockedRecv, " blockedselect=", cs.blockedSelect, " idlelockedchan=", atomic.Load(&chanLockedIdle), "\n")
 	}
 	// We must be careful while reading data from P's, M's and G's.
 	// Even if we hold schedlock, most data can be changed concurrently.
@@ -5635,7 +5861,8 @@ func schedtrace(detailed bool) {
 			}
 			print(t - h)
 			if i == len(allp)-1 {
-				print("]\n")
+				print("]")
+				print(" blockedsend=", cs.blockedSend, " blockedrecv=", cs.blockedRecv, " blockedselect=", cs.blockedSelect, " idlelockedchan=", atomic.Load(&chanLockedIdle), "\n")
 			}
 		}
 	}
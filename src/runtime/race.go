@@ -8,6 +8,7 @@
 package runtime
 
 import (
+	"runtime/internal/atomic"
 	"unsafe"
 )
 
@@ -506,6 +507,21 @@ func racereadrangepc(addr unsafe.Pointer, sz, callpc, pc uintptr) {
 	}
 }
 
+// raceatomicstore64 performs an atomic store of val to *addr and
+// records it as such for the race detector: plain accesses of *addr
+// that are not synchronized with it are reported as races, but other
+// atomic accesses are not.
+func raceatomicstore64(addr *uint64, val uint64) {
+	_g_ := getg()
+	if _g_ != _g_.m.curg {
+		// The call is coming from manual instrumentation of Go code running on g0/gsignal.
+		// Not interesting.
+		atomic.Store64(addr, val)
+		return
+	}
+	abigen_sync_atomic_StoreUint64(addr, val)
+}
+
 //go:nosplit
 func raceacquire(addr unsafe.Pointer) {
 	raceacquireg(getg(), addr)
@@ -45,7 +45,25 @@ const (
 )
 
 func TestRace(t *testing.T) {
-	testOutput, err := runTests(t)
+	testRace(t, "./testdata/*_test.go")
+}
+
+// TestRaceChanLen runs the tests that depend on len(ch) being
+// reported, which is only done with GODEBUG=racechanlen=1.
+func TestRaceChanLen(t *testing.T) {
+	testRace(t, "./testdata/chanlen/*_test.go", "GODEBUG=racechanlen=1")
+}
+
+// testRace runs the tests in the files matching pattern, with the
+// additional environment variables env, and checks that the races are
+// reported as expected.
+func testRace(t *testing.T, pattern string, env ...string) {
+	passedTests, totalTests = 0, 0
+	falsePos, falseNeg = 0, 0
+	failingPos, failingNeg = 0, 0
+	failed = false
+
+	testOutput, err := runTests(t, pattern, env...)
 	if err != nil {
 		t.Fatalf("Failed to run tests: %v\n%v", err, string(testOutput))
 	}
@@ -142,9 +160,11 @@ func processLog(testName string, tsanLog []string) string {
 
 // runTests assures that the package and its dependencies is
 // built with instrumentation enabled and returns the output of 'go test'
-// which includes possible data race reports from ThreadSanitizer.
-func runTests(t *testing.T) ([]byte, error) {
-	tests, err := filepath.Glob("./testdata/*_test.go")
+// on the files matching pattern, run with the additional environment
+// variables extraEnv, which includes possible data race reports from
+// ThreadSanitizer.
+func runTests(t *testing.T, pattern string, extraEnv ...string) ([]byte, error) {
+	tests, err := filepath.Glob(pattern)
 	if err != nil {
 		return nil, err
 	}
@@ -176,6 +196,7 @@ func runTests(t *testing.T) ([]byte, error) {
 		"GOMAXPROCS=1",
 		"GORACE=suppress_equal_stacks=0 suppress_equal_addresses=0",
 	)
+	cmd.Env = append(cmd.Env, extraEnv...)
 	// There are races: we expect tests to fail and the exit code to be non-zero.
 	out, _ := cmd.CombinedOutput()
 	if bytes.Contains(out, []byte("fatal error:")) {
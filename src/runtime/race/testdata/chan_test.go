@@ -5,6 +5,7 @@
 package main_test
 
 import (
+	"reflect"
 	"runtime"
 	"testing"
 	"time"
@@ -785,3 +786,301 @@ func TestNoRaceElemetSize0(t *testing.T) {
 	x += 1
 	y += 1
 }
+
+func TestNoRaceChanAsyncCloseDrain(t *testing.T) {
+	v := 0
+	_ = v
+	c := make(chan int, 10)
+	go func() {
+		for i := 0; i < 5; i++ {
+			c <- i
+		}
+		v = 1
+		close(c)
+	}()
+	for range c {
+	}
+	v = 2
+}
+
+func TestNoRaceChanAsyncCloseDrainSelect(t *testing.T) {
+	v := 0
+	_ = v
+	c := make(chan int, 10)
+	go func() {
+		for i := 0; i < 5; i++ {
+			c <- i
+		}
+		v = 1
+		close(c)
+	}()
+	for {
+		select {
+		case _, ok := <-c:
+			if ok {
+				continue
+			}
+		}
+		break
+	}
+	v = 2
+}
+
+func TestNoRaceChanAsyncCloseDrainNonBlocking(t *testing.T) {
+	v := 0
+	_ = v
+	c := make(chan int, 10)
+	for i := 0; i < 5; i++ {
+		c <- i
+	}
+	done := make(chan bool)
+	go func() {
+		for {
+			select {
+			case _, ok := <-c:
+				if !ok {
+					v = 2
+					done <- true
+					return
+				}
+			default:
+			}
+		}
+	}()
+	v = 1
+	close(c)
+	<-done
+}
+
+func TestNoRaceChanAsyncCloseDrainMulti(t *testing.T) {
+	v := 0
+	_ = v
+	c := make(chan int, 10)
+	done := make(chan int)
+	for i := 0; i < 3; i++ {
+		go func() {
+			for range c {
+			}
+			done <- v
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		c <- i
+	}
+	v = 1
+	close(c)
+	for i := 0; i < 3; i++ {
+		<-done
+	}
+}
+
+func TestRaceChanAsyncCloseDrainPartial(t *testing.T) {
+	v := 0
+	_ = v
+	c := make(chan int, 10)
+	go func() {
+		for i := 0; i < 5; i++ {
+			c <- i
+		}
+		v = 1
+		close(c)
+	}()
+	// Receiving every buffered value does not synchronize with
+	// the close, only with the sends.
+	for i := 0; i < 5; i++ {
+		<-c
+	}
+	v = 2
+}
+
+func TestRaceChanAsyncCloseDrainAfterClose(t *testing.T) {
+	v := 0
+	_ = v
+	c := make(chan int, 10)
+	done := make(chan bool)
+	go func() {
+		for range c {
+		}
+		v = 2
+		done <- true
+	}()
+	for i := 0; i < 5; i++ {
+		c <- i
+	}
+	close(c)
+	v = 1
+	<-done
+}
+
+func TestRaceChanAsyncCloseDrainSelectPartial(t *testing.T) {
+	v := 0
+	_ = v
+	c := make(chan int, 10)
+	go func() {
+		c <- 0
+		v = 1
+		close(c)
+	}()
+	select {
+	case <-c:
+	}
+	v = 2
+}
+
+func TestRaceChanAsyncCloseDrainPartialZero(t *testing.T) {
+	v := 0
+	_ = v
+	c := make(chan struct{}, 10)
+	go func() {
+		c <- struct{}{}
+		v = 1
+		close(c)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	<-c
+	v = 2
+}
+
+func TestNoRaceChanAsyncCloseDrainZero(t *testing.T) {
+	v := 0
+	_ = v
+	c := make(chan struct{}, 10)
+	go func() {
+		for i := 0; i < 5; i++ {
+			c <- struct{}{}
+		}
+		v = 1
+		close(c)
+	}()
+	for range c {
+	}
+	v = 2
+}
+
+// A nonblocking receive that finds nothing to receive does not
+// synchronize with anything, so code that concludes from it that
+// another goroutine has not done something yet is racy.
+func TestRaceChanTryRecvEmpty(t *testing.T) {
+	v := 0
+	_ = v
+	c := make(chan int, 1)
+	start := make(chan bool)
+	done := make(chan bool)
+	go func() {
+		v = 1
+		<-start
+		c <- 0
+		done <- true
+	}()
+	select {
+	case <-c:
+	default:
+		v = 2
+	}
+	start <- true
+	<-done
+}
+
+func TestRaceChanTryRecvEmptyUnbuffered(t *testing.T) {
+	v := 0
+	_ = v
+	c := make(chan int)
+	start := make(chan bool)
+	done := make(chan bool)
+	go func() {
+		v = 1
+		<-start
+		close(c)
+		done <- true
+	}()
+	select {
+	case _, ok := <-c:
+		_ = ok
+	default:
+		v = 2
+	}
+	start <- true
+	<-done
+}
+
+func TestNoRaceChanTryRecv(t *testing.T) {
+	v := 0
+	_ = v
+	c := make(chan int, 1)
+	go func() {
+		v = 1
+		c <- 0
+	}()
+	for {
+		select {
+		case <-c:
+			v = 2
+			return
+		default:
+			runtime.Gosched()
+		}
+	}
+}
+
+func TestNoRaceChanSendFront(t *testing.T) {
+	v := 0
+	_ = v
+	c := make(chan int, 2)
+	c <- 0
+	go func() {
+		v = 1
+		reflect.ValueOf(c).TrySendFront(reflect.ValueOf(1))
+	}()
+	for len(c) != 2 {
+		runtime.Gosched()
+	}
+	if <-c != 1 {
+		t.Error("front send did not go first")
+	}
+	v = 2
+}
+
+func TestNoRaceChanSendFrontAfterRecv(t *testing.T) {
+	v := 0
+	_ = v
+	c := make(chan int, 2)
+	c <- 0
+	c <- 0
+	go func() {
+		v = 1
+		<-c
+	}()
+	for len(c) != 1 {
+		runtime.Gosched()
+	}
+	<-c
+	// The goroutine's receive is the first, and the front send the
+	// third, so it happens before the front send completes, even
+	// though the front send reuses the slot freed by the second.
+	if !reflect.ValueOf(c).TrySendFront(reflect.ValueOf(0)) {
+		t.Fatal("front send to an empty channel failed")
+	}
+	v = 2
+}
+
+func TestNoRaceChanTryRecvClosed(t *testing.T) {
+	v := 0
+	_ = v
+	c := make(chan int)
+	go func() {
+		v = 1
+		close(c)
+	}()
+	for {
+		select {
+		case _, ok := <-c:
+			if ok {
+				t.Error("received a value from a channel nobody sends on")
+			}
+			v = 2
+			return
+		default:
+			runtime.Gosched()
+		}
+	}
+}
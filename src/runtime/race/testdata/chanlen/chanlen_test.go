@@ -0,0 +1,137 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// These tests are run with GODEBUG=racechanlen=1.
+
+package main_test
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestRaceChanLenSend(t *testing.T) {
+	c := make(chan int, 10)
+	go func() {
+		c <- 1
+	}()
+	for len(c) == 0 {
+		runtime.Gosched()
+	}
+	<-c
+}
+
+func TestRaceChanLenRecv(t *testing.T) {
+	c := make(chan int, 10)
+	c <- 1
+	done := make(chan bool)
+	go func() {
+		if len(c) > 0 {
+			<-c
+		}
+		done <- true
+	}()
+	if len(c) > 0 {
+		<-c
+	}
+	<-done
+}
+
+func TestRaceChanLenZeroSize(t *testing.T) {
+	c := make(chan struct{}, 10)
+	go func() {
+		c <- struct{}{}
+	}()
+	for len(c) == 0 {
+		runtime.Gosched()
+	}
+	<-c
+}
+
+func TestRaceChanLenClose(t *testing.T) {
+	c := make(chan int, 10)
+	done := make(chan bool)
+	go func() {
+		_ = len(c)
+		done <- true
+	}()
+	close(c)
+	<-done
+}
+
+func TestRaceChanLenReflect(t *testing.T) {
+	c := make(chan int, 10)
+	go func() {
+		c <- 1
+	}()
+	for reflect.ValueOf(c).Len() == 0 {
+		runtime.Gosched()
+	}
+	<-c
+}
+
+func TestNoRaceChanLenSingle(t *testing.T) {
+	c := make(chan int, 10)
+	c <- 1
+	c <- 2
+	if len(c) != 2 {
+		t.Fatalf("len(c) = %d, want 2", len(c))
+	}
+	<-c
+	if len(c) != 1 {
+		t.Fatalf("len(c) = %d, want 1", len(c))
+	}
+	close(c)
+	if len(c) != 1 {
+		t.Fatalf("len(c) = %d, want 1", len(c))
+	}
+}
+
+func TestNoRaceChanLenSync(t *testing.T) {
+	c := make(chan int, 10)
+	done := make(chan bool)
+	go func() {
+		c <- 1
+		done <- true
+	}()
+	<-done
+	if len(c) != 1 {
+		t.Fatalf("len(c) = %d, want 1", len(c))
+	}
+	<-c
+}
+
+func TestNoRaceChanLenSendRecv(t *testing.T) {
+	const n = 10
+	c := make(chan int, 2)
+	done := make(chan bool)
+	for i := 0; i < n; i++ {
+		go func() {
+			c <- 1
+			done <- true
+		}()
+	}
+	for i := 0; i < n; i++ {
+		go func() {
+			<-c
+			done <- true
+		}()
+	}
+	for i := 0; i < 2*n; i++ {
+		<-done
+	}
+	if len(c) != 0 {
+		t.Fatalf("len(c) = %d, want 0", len(c))
+	}
+}
+
+func TestNoRaceChanLenUnbuffered(t *testing.T) {
+	c := make(chan int)
+	go func() {
+		c <- 1
+	}()
+	_ = len(c)
+	<-c
+}
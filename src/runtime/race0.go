@@ -28,6 +28,7 @@ func racewritepc(addr unsafe.Pointer, callerpc, pc uintptr)                 { th
 func racereadpc(addr unsafe.Pointer, callerpc, pc uintptr)                  { throw("race") }
 func racereadrangepc(addr unsafe.Pointer, sz, callerpc, pc uintptr)         { throw("race") }
 func racewriterangepc(addr unsafe.Pointer, sz, callerpc, pc uintptr)        { throw("race") }
+func raceatomicstore64(addr *uint64, val uint64)                            { throw("race") }
 func raceacquire(addr unsafe.Pointer)                                       { throw("race") }
 func raceacquireg(gp *g, addr unsafe.Pointer)                               { throw("race") }
 func raceacquirectx(racectx uintptr, addr unsafe.Pointer)                   { throw("race") }
@@ -16,8 +16,10 @@ path to this file based on the path to the runtime package.
 
 
 from __future__ import print_function
+import os
 import re
 import sys
+import time
 import gdb
 
 print("Loading Go Runtime support.", file=sys.stderr)
@@ -208,6 +210,8 @@ class ChanTypePrinter:
 		return 'array'
 
 	def to_string(self):
+		if self.val['closed'] != 0:
+			return '{0} (closed by goroutine {1})'.format(self.val.type, self.val['closedBy'])
 		return str(self.val.type)
 
 	def children(self):
@@ -219,6 +223,57 @@ class ChanTypePrinter:
 			yield ('[{0}]'.format(i), (ptr + j).dereference())
 
 
+class HchanTypePrinter:
+	"""Pretty print the runtime state of a channel.
+
+	This is what a chan[T] points to: print *c to see it.
+	"""
+
+	pattern = re.compile(r'^(runtime\.)?hchan(<.*>)?$')
+
+	def __init__(self, val):
+		self.val = val
+
+	def to_string(self):
+		return chan_summary(self.val)
+
+	def children(self):
+		yield ('recvq', self.val['recvq'])
+		yield ('sendq', self.val['sendq'])
+
+
+class WaitqTypePrinter:
+	"""Pretty print the goroutines waiting on a channel."""
+
+	pattern = re.compile(r'^(runtime\.)?waitq(<.*>)?$')
+
+	def __init__(self, val):
+		self.val = val
+
+	def display_hint(self):
+		return 'array'
+
+	def to_string(self):
+		n = len(list(linked_list(self.val['first'], 'next')))
+		return '{0} waiting'.format(n)
+
+	def children(self):
+		for i, sg in enumerate(linked_list(self.val['first'], 'next')):
+			yield ('[{0}]'.format(i), sg.dereference())
+
+
+class SudogTypePrinter:
+	"""Pretty print a goroutine waiting on a channel."""
+
+	pattern = re.compile(r'^(runtime\.)?sudog(<.*>)?$')
+
+	def __init__(self, val):
+		self.val = val
+
+	def to_string(self):
+		return sudog_summary(self.val)
+
+
 #
 #  Register all the *Printer classes above.
 #
@@ -432,6 +487,88 @@ def linked_list(ptr, linkfield):
 		ptr = ptr[linkfield]
 
 
+def nanotime():
+	"""Return the inferior's runtime.nanotime, or None if it is unknown.
+
+	On Linux, nanotime reads CLOCK_MONOTONIC, which a live process on
+	this machine shares with gdb. There is no way to tell the time a
+	core file was written.
+	"""
+	pid = gdb.selected_inferior().pid
+	if not sys.platform.startswith('linux') or not hasattr(time, 'clock_gettime'):
+		return None
+	if not pid or not os.path.exists('/proc/{0}'.format(pid)):
+		return None
+	return int(time.clock_gettime(time.CLOCK_MONOTONIC) * 1e9)
+
+
+def chan_summary(hc):
+	"""Describe the channel whose hchan is hc."""
+	m = re.match(r'^hchan<(.*)>$', str(hc.type))
+	s = 'chan'
+	if m:
+		s += ' ' + m.group(1)
+	s += ': len {0}, cap {1}, sendx {2}, recvx {3}'.format(
+		int(hc['qcount']), int(hc['dataqsiz']), int(hc['sendx']), int(hc['recvx']))
+	if hc['closed'] != 0:
+		s += ', closed by goroutine {0}'.format(int(hc['closedBy']))
+	return s
+
+
+def sudog_summary(sg):
+	"""Describe the goroutine waiting in sg.
+
+	How long the goroutine has waited is only known once the runtime
+	records when it parked: with GODEBUG=chanblockwarn set, or once a
+	garbage collection has seen it waiting.
+	"""
+	gp = sg['g']
+	if not gp:
+		return 'no goroutine'
+	s = 'goroutine {0}'.format(int(gp['goid']))
+	if sg['isSelect']:
+		s += ' (select)'
+	since = int(gp['waitsince'])
+	now = nanotime()
+	if since != 0 and now is not None and now >= since:
+		s += ', waiting {0:.1f}s'.format((now - since) / 1e9)
+	return s
+
+
+class ChanWaitersCmd(gdb.Command):
+	"""List the goroutines waiting on a channel.
+
+	Usage: (gdb) chan-waiters <chan>
+
+	<chan> is a channel variable or the address of a channel.
+	"""
+
+	def __init__(self):
+		gdb.Command.__init__(self, "chan-waiters", gdb.COMMAND_DATA, gdb.COMPLETE_SYMBOL)
+
+	def invoke(self, arg, _from_tty):
+		try:
+			hc = gdb.parse_and_eval(arg)
+		except Exception as e:
+			print("Can't parse ", arg, ": ", e)
+			return
+		if hc.type.strip_typedefs().code != gdb.TYPE_CODE_PTR:
+			hc = hc.cast(lookup_type('runtime.hchan').pointer())
+		if not hc:
+			print("nil channel")
+			return
+		hc = hc.dereference()
+		print(chan_summary(hc))
+		for name in ('recvq', 'sendq'):
+			sgs = list(linked_list(hc[name]['first'], 'next'))
+			if not sgs:
+				print("{0}: none".format(name))
+				continue
+			print("{0}:".format(name))
+			for sg in sgs:
+				print("\t" + sudog_summary(sg.dereference()))
+
+
 class GoroutinesCmd(gdb.Command):
 	"List all goroutines."
 
@@ -604,3 +741,4 @@ DTypeFunc()
 GoroutinesCmd()
 GoroutineCmd()
 GoIfaceCmd()
+ChanWaitersCmd()
@@ -111,7 +111,14 @@ func checkCleanBacktrace(t *testing.T, backtrace string) {
 const helloSource = `
 import "fmt"
 import "runtime"
+import "strings"
 var gslice []string
+// parked reports whether n goroutines are blocked with the given status.
+func parked(status string, n int) bool {
+	buf := make([]byte, 1<<16)
+	buf = buf[:runtime.Stack(buf, true)]
+	return strings.Count(string(buf), "["+status+"]") >= n
+}
 func main() {
 	mapvar := make(map[string]string, 13)
 	slicemap := make(map[string][]string,11)
@@ -121,6 +128,17 @@ func main() {
 	chanint <- 11
     chanstr <- "spongepants"
     chanstr <- "squarebob"
+	chanclosed := make(chan int)
+	close(chanclosed)
+	chanrecv := make(chan int)
+	chansend := make(chan int, 1)
+	chansend <- 1
+	go func() { <-chanrecv }()
+	go func() { <-chanrecv }()
+	go func() { chansend <- 2 }()
+	for !parked("chan receive (sync)", 2) || !parked("chan send (full)", 1) {
+		runtime.Gosched()
+	}
 	mapvar["abc"] = "def"
 	mapvar["ghi"] = "jkl"
 	slicemap["a"] = []string{"b","c","d"}
@@ -135,6 +153,9 @@ func main() {
 	gslice = slicevar
 	fmt.Printf("%v, %v, %v\n", slicemap, <-chanint, <-chanstr)
 	runtime.KeepAlive(mapvar)
+	runtime.KeepAlive(chanclosed)
+	runtime.KeepAlive(chanrecv)
+	runtime.KeepAlive(chansend)
 }  // END_OF_PROGRAM
 `
 
@@ -244,6 +265,15 @@ func testGdbPython(t *testing.T, cgo bool) {
 		"-ex", "echo BEGIN print chanstr\n",
 		"-ex", "print chanstr",
 		"-ex", "echo END\n",
+		"-ex", "echo BEGIN print chanclosed\n",
+		"-ex", "print chanclosed",
+		"-ex", "echo END\n",
+		"-ex", "echo BEGIN print *chanrecv\n",
+		"-ex", "print *chanrecv",
+		"-ex", "echo END\n",
+		"-ex", "echo BEGIN chan-waiters chansend\n",
+		"-ex", "chan-waiters chansend",
+		"-ex", "echo END\n",
 		"-ex", "echo BEGIN info locals\n",
 		"-ex", "info locals",
 		"-ex", "echo END\n",
@@ -321,6 +351,20 @@ func testGdbPython(t *testing.T, cgo bool) {
 		t.Fatalf("print chanstr failed: %s", bl)
 	}
 
+	if bl := blocks["print chanclosed"]; !strings.Contains(bl, "chan int (closed by goroutine 1)") {
+		t.Fatalf("print chanclosed failed: %s", bl)
+	}
+
+	chanRecvRe := regexp.MustCompile(`chan int: len 0, cap 0, sendx 0, recvx 0.*recvq = 2 waiting = {goroutine \d+, goroutine \d+}, sendq = 0 waiting`)
+	if bl := strings.ReplaceAll(blocks["print *chanrecv"], "\n", " "); !chanRecvRe.MatchString(bl) {
+		t.Fatalf("print *chanrecv failed: %s", bl)
+	}
+
+	chanWaitersRe := regexp.MustCompile(`^chan: len 1, cap 1, sendx 0, recvx 0\nrecvq: none\nsendq:\n\tgoroutine \d+(, waiting [0-9.]+s)?$`)
+	if bl := blocks["chan-waiters chansend"]; !chanWaitersRe.MatchString(bl) {
+		t.Fatalf("chan-waiters chansend failed: %s", bl)
+	}
+
 	strVarRe := regexp.MustCompile(`^\$[0-9]+ = (0x[0-9a-f]+\s+)?"abc"$`)
 	if bl := blocks["print strvar"]; !strVarRe.MatchString(bl) {
 		t.Fatalf("print strvar failed: %s", bl)
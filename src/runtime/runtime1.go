@@ -301,7 +301,21 @@ type dbgVar struct {
 // already have an initial value.
 var debug struct {
 	cgocheck           int32
+	chanblockwarn      int32
+	chanclosecheck     int32
+	chandirectswitch   int32
+	chandropcheck      int32
+	chanexitreport     int32
+	chanhugepage       int32
+	chaninvariants     int32
+	chanprofrate       int32
+	chanrecord         int32
+	chanregistry       int32
+	channuma           int32
+	chanwakeglobal     int32
+	checkstackchans    int32
 	clobberfree        int32
+	closedrecvwarn     int32
 	efence             int32
 	gccheckmark        int32
 	gcpacertrace       int32
@@ -309,11 +323,17 @@ var debug struct {
 	gcstoptheworld     int32
 	gctrace            int32
 	invalidptr         int32
+	lockedchanwarn     int32
 	madvdontneed       int32 // for Linux; issue 28466
+	racechanlen        int32
 	scavtrace          int32
 	scheddetail        int32
 	schedtrace         int32
+	selectadaptive     int32
+	selectspindetect   int32
+	sudogcachemax      int32
 	tracebackancestors int32
+	tracebackgroup     int32
 	asyncpreemptoff    int32
 
 	// debug.malloc is used as a combined debug check
@@ -328,7 +348,21 @@ var debug struct {
 var dbgvars = []dbgVar{
 	{"allocfreetrace", &debug.allocfreetrace},
 	{"clobberfree", &debug.clobberfree},
+	{"closedrecvwarn", &debug.closedrecvwarn},
 	{"cgocheck", &debug.cgocheck},
+	{"chanblockwarn", &debug.chanblockwarn},
+	{"chanclosecheck", &debug.chanclosecheck},
+	{"chandirectswitch", &debug.chandirectswitch},
+	{"chandropcheck", &debug.chandropcheck},
+	{"chanexitreport", &debug.chanexitreport},
+	{"chanhugepage", &debug.chanhugepage},
+	{"chaninvariants", &debug.chaninvariants},
+	{"chanprofrate", &debug.chanprofrate},
+	{"chanrecord", &debug.chanrecord},
+	{"chanregistry", &debug.chanregistry},
+	{"channuma", &debug.channuma},
+	{"chanwakeglobal", &debug.chanwakeglobal},
+	{"checkstackchans", &debug.checkstackchans},
 	{"efence", &debug.efence},
 	{"gccheckmark", &debug.gccheckmark},
 	{"gcpacertrace", &debug.gcpacertrace},
@@ -336,12 +370,18 @@ var dbgvars = []dbgVar{
 	{"gcstoptheworld", &debug.gcstoptheworld},
 	{"gctrace", &debug.gctrace},
 	{"invalidptr", &debug.invalidptr},
+	{"lockedchanwarn", &debug.lockedchanwarn},
 	{"madvdontneed", &debug.madvdontneed},
+	{"racechanlen", &debug.racechanlen},
 	{"sbrk", &debug.sbrk},
 	{"scavtrace", &debug.scavtrace},
 	{"scheddetail", &debug.scheddetail},
 	{"schedtrace", &debug.schedtrace},
+	{"selectadaptive", &debug.selectadaptive},
+	{"selectspindetect", &debug.selectspindetect},
+	{"sudogcachemax", &debug.sudogcachemax},
 	{"tracebackancestors", &debug.tracebackancestors},
+	{"tracebackgroup", &debug.tracebackgroup},
 	{"asyncpreemptoff", &debug.asyncpreemptoff},
 	{"inittrace", &debug.inittrace},
 }
@@ -349,6 +389,7 @@ var dbgvars = []dbgVar{
 func parsedebugvars() {
 	// defaults
 	debug.cgocheck = 1
+	debug.chanhugepage = 1
 	debug.invalidptr = 1
 	if GOOS == "linux" {
 		// On Linux, MADV_FREE is faster than MADV_DONTNEED,
@@ -343,6 +343,12 @@ type gobuf struct {
 //
 // sudogs are allocated from a special pool. Use acquireSudog and
 // releaseSudog to allocate and free them.
+//
+// A program may have very many blocked goroutines, and a blocked
+// select holds a sudog per case, so sudog is kept within the 80-byte
+// size class on 64-bit systems. To that end, a semaphore's waittail
+// shares the word of c, which only channels use. TestSizeof checks
+// the size.
 // sudog 表示等待列表中的 G，例如在通道上的发送或接收列表。SUDOG 是必需的，因为 G ↔ 同步对象关系是多对多的。
 // 一个g可以在许多等待列表中，所以一个g可能有很多sudog;并且许多 GS 可能正在等待同一个同步对象，因此一个对象可能有很多 sudogs。
 // SUDOGS是从特殊的池中分配的。使用获取Sudog和释放Sudog来分配和释放它们。
@@ -367,14 +373,15 @@ type sudog struct {
 	// 以下字段永远不会同时访问。
 	// 对于 chain，waitlink只能由 g 访问。
 	// 对于信号量，所有字段（包括上述字段）仅在持有 semaRoot 锁时访问。
-	acquiretime int64
-	releasetime int64
-	ticket      uint32
+	acquiretime int64  // semaphores only, for the mutex profile
+	releasetime int64  // for the block profile
+	ticket      uint32 // semaRoot treap priority or notifyList ticket
 
-	// isSelect indicates g is participating in a select, so
-	// g.selectDone must be CAS'd to win the wake-up race.
-	// isSelect 表示 g 正在参与选择，因此 g.selectDone 必须是 CAS 才能赢得唤醒竞赛，乐观锁
-	isSelect bool
+	// flags holds the sudogSelect, sudogCancelable and sudogSend bits.
+	// They are set by g before it queues the sudog and are otherwise
+	// only cleared by g, so unlike success, woken and queued, which
+	// the goroutine dequeuing the sudog writes, they can share a byte.
+	flags uint8
 
 	// success indicates whether communication over channel c
 	// succeeded. It is true if the goroutine was awoken because a
@@ -386,12 +393,50 @@ type sudog struct {
 	// 如果因为 c 被关闭而唤醒，则为 false。
 	success bool
 
+	// woken indicates that g was woken by the goroutine that
+	// dequeued this sudog to complete its operation or because c
+	// was closed, rather than by a cancellation. In a select, it
+	// identifies the case that completed. It is the only way the
+	// goroutine learns which of its sudogs was dequeued; channel
+	// wakeups leave g.param alone.
+	woken bool
+
+	// queued is set while the sudog is on the wait queue of a
+	// channel. It is set by waitq.enqueue and cleared when the sudog
+	// is unlinked, so that enqueue can catch a sudog queued twice
+	// before the queue is corrupted. It is protected by c.lock.
+	queued bool
+
 	parent   *sudog // semaRoot binary tree
 	waitlink *sudog // g.waiting list or semaRoot
-	waittail *sudog // semaRoot
-	c        *hchan // channel
+
+	// c is the channel of a sudog used by a channel operation. A
+	// sudog used by a semaphore has no channel, and keeps its
+	// semaRoot waittail here instead; see (*sudog).waittail.
+	c *hchan
 }
 
+// Bits in sudog.flags.
+const (
+	// sudogSelect indicates g is participating in a select, so
+	// g.selectDone must be CAS'd to win the wake-up race.
+	sudogSelect = 1 << iota
+
+	// sudogCancelable indicates g is in a cancelable send or receive,
+	// so g.selectDone must be CAS'd to win the wake-up race like for
+	// sudogSelect. See chancancel.go.
+	sudogCancelable
+
+	// sudogSend indicates that the sudog is queued on c.sendq rather
+	// than c.recvq. It is only used for diagnostics, such as listing
+	// the cases of a blocked select in tracebacks.
+	sudogSend
+)
+
+func (s *sudog) isSelect() bool   { return s.flags&sudogSelect != 0 }
+func (s *sudog) cancelable() bool { return s.flags&sudogCancelable != 0 }
+func (s *sudog) isSend() bool     { return s.flags&sudogSend != 0 }
+
 type libcall struct {
 	fn   uintptr
 	n    uintptr // number of parameters
@@ -475,30 +520,38 @@ type g struct {
 	// for stack shrinking. It's a boolean value, but is updated atomically.
 	parkingOnChan uint8
 
-	raceignore     int8     // ignore race detection events
-	sysblocktraced bool     // StartTrace has emitted EvGoInSyscall about this goroutine
-	tracking       bool     // whether we're tracking this G for sched latency statistics
-	trackingSeq    uint8    // used to decide whether to track this G
-	runnableStamp  int64    // timestamp of when the G last became runnable, only used when tracking
-	runnableTime   int64    // the amount of time spent runnable, cleared when running, only used when tracking
-	sysexitticks   int64    // cputicks when syscall has returned (for tracing)
-	traceseq       uint64   // trace event sequencer
-	tracelastp     puintptr // last P emitted an event for this goroutine
-	lockedm        muintptr
-	sig            uint32
-	writebuf       []byte
-	sigcode0       uintptr
-	sigcode1       uintptr
-	sigpc          uintptr
-	gopc           uintptr         // pc of go statement that created this goroutine
-	ancestors      *[]ancestorInfo // ancestor information goroutine(s) that created this goroutine (only used if debug.tracebackancestors)
-	startpc        uintptr         // pc of goroutine function
-	racectx        uintptr
-	waiting        *sudog         // sudog structures this g is waiting on (that have a valid elem ptr); in lock order
-	cgoCtxt        []uintptr      // cgo traceback context
-	labels         unsafe.Pointer // profiler labels
-	timer          *timer         // cached timer for time.Sleep
-	selectDone     uint32         // are we participating in a select and did someone win the race?
+	raceignore       int8     // ignore race detection events
+	sysblocktraced   bool     // StartTrace has emitted EvGoInSyscall about this goroutine
+	tracking         bool     // whether we're tracking this G for sched latency statistics
+	trackingSeq      uint8    // used to decide whether to track this G
+	timeGroupBlocked bool     // durably blocked member of timeGroup; see timegroup.go
+	chanBlockWarned  bool     // wait reported by GODEBUG=chanblockwarn; see chanblockwarn.go
+	runnableStamp    int64    // timestamp of when the G last became runnable, only used when tracking
+	runnableTime     int64    // the amount of time spent runnable, cleared when running, only used when tracking
+	sysexitticks     int64    // cputicks when syscall has returned (for tracing)
+	traceseq         uint64   // trace event sequencer
+	tracelastp       puintptr // last P emitted an event for this goroutine
+	traceTasks       []uint64 // tasks of the runtime/trace regions the goroutine is in, innermost last
+	lockedm          muintptr
+	sig              uint32
+	writebuf         []byte
+	sigcode0         uintptr
+	sigcode1         uintptr
+	sigpc            uintptr
+	gopc             uintptr         // pc of go statement that created this goroutine
+	ancestors        *[]ancestorInfo // ancestor information goroutine(s) that created this goroutine (only used if debug.tracebackancestors)
+	startpc          uintptr         // pc of goroutine function
+	racectx          uintptr
+	waiting          *sudog           // sudog structures this g is waiting on (that have a valid elem ptr); in lock order
+	cgoCtxt          []uintptr        // cgo traceback context
+	labels           unsafe.Pointer   // profiler labels
+	chanLeakScope    uint64           // test whose channels this g's makechans belong to; see chanleak.go
+	timeGroup        *timeGroup       // virtual time group; see timegroup.go
+	timer            *timer           // cached timer for time.Sleep
+	selectDone       uint32           // are we participating in a select and did someone win the race?
+	selectLocks      *selectLockCache // lock order of the last wide select; see selectgo
+	selectScratch    *selectScratch   // reusable case and order arrays for reflect selects
+	chanCancel       *chanCancel      // handle of the cancelable channel operation in progress; see chancancel.go
 
 	// Per-G GC state
 
@@ -577,6 +630,16 @@ type m struct {
 	syscalltick   uint32
 	freelink      *m // on sched.freem
 
+	// chanSetWake holds the channel set waiters woken by the channel
+	// operation parking on this M, for its park commit function to
+	// ready; see chanset.go.
+	chanSetWake gList
+
+	// chanForbid, if not empty, is the context in which the goroutine
+	// running on this M may not perform channel operations, for the
+	// message of the throw if it does; see chanctx.go.
+	chanForbid string
+
 	// mFixup is used to synchronize OS related m state
 	// (credentials etc) use mutex to access. To avoid deadlocks
 	// an atomic.Load() of used being zero in mDoFixupFn()
@@ -699,6 +762,31 @@ type p struct {
 	// This is 0 if there are no timerModifiedEarlier timers.
 	timerModifiedEarliest uint64
 
+	// Channel operation counters. See chanstats.go.
+	// These are updated using atomic functions and must stay
+	// 8-byte aligned.
+	chanStats chanStats
+
+	// Goroutine last woken by a channel operation on this P, for
+	// GODEBUG=chandirectswitch. See chanswitch.go.
+	chanHandoff guintptr
+
+	// Runs of default cases taken by nonblocking selects, for
+	// GODEBUG=selectspindetect. See selectspin.go.
+	selectSpin [selectSpinSlots]selectSpinSlot
+
+	// Receives from closed channels, for GODEBUG=closedrecvwarn.
+	// See closedrecv.go.
+	closedRecv [closedRecvSlots]closedRecvSlot
+
+	// Recently chosen cases of selects, for GODEBUG=selectadaptive.
+	// See selectadapt.go.
+	selectAdapt [selectAdaptSlots]selectAdaptSlot
+
+	// Channel operations left until the next chanops profile sample,
+	// for GODEBUG=chanprofrate. See chanprof.go.
+	chanProfLeft int32
+
 	// Per-P GC state
 	gcAssistTime         int64 // Nanoseconds in assistAlloc
 	gcFractionalMarkTime int64 // Nanoseconds in fractional mark worker (atomic)
@@ -1050,8 +1138,8 @@ const (
 	waitReasonGCAssistWait                            // "GC assist wait"
 	waitReasonGCSweepWait                             // "GC sweep wait"
 	waitReasonGCScavengeWait                          // "GC scavenge wait"
-	waitReasonChanReceive                             // "chan receive"
-	waitReasonChanSend                                // "chan send"
+	waitReasonChanReceiveSync                         // "chan receive (sync)"
+	waitReasonChanSendSync                            // "chan send (sync)"
 	waitReasonFinalizerWait                           // "finalizer wait"
 	waitReasonForceGCIdle                             // "force gc (idle)"
 	waitReasonSemacquire                              // "semacquire"
@@ -1063,6 +1151,12 @@ const (
 	waitReasonGCWorkerIdle                            // "GC worker (idle)"
 	waitReasonPreempted                               // "preempted"
 	waitReasonDebugCall                               // "debug call"
+	waitReasonTimeGroup                               // "virtual time group"
+	waitReasonChanSetWait                             // "chan set wait"
+	waitReasonBcastSend                               // "broadcast send"
+	waitReasonBcastReceive                            // "broadcast receive"
+	waitReasonChanReceiveEmpty                        // "chan receive (empty)"
+	waitReasonChanSendFull                            // "chan send (full)"
 )
 
 var waitReasonStrings = [...]string{
@@ -1080,8 +1174,8 @@ var waitReasonStrings = [...]string{
 	waitReasonGCAssistWait:          "GC assist wait",
 	waitReasonGCSweepWait:           "GC sweep wait",
 	waitReasonGCScavengeWait:        "GC scavenge wait",
-	waitReasonChanReceive:           "chan receive",
-	waitReasonChanSend:              "chan send",
+	waitReasonChanReceiveSync:       "chan receive (sync)",
+	waitReasonChanSendSync:          "chan send (sync)",
 	waitReasonFinalizerWait:         "finalizer wait",
 	waitReasonForceGCIdle:           "force gc (idle)",
 	waitReasonSemacquire:            "semacquire",
@@ -1093,6 +1187,12 @@ var waitReasonStrings = [...]string{
 	waitReasonGCWorkerIdle:          "GC worker (idle)",
 	waitReasonPreempted:             "preempted",
 	waitReasonDebugCall:             "debug call",
+	waitReasonTimeGroup:             "virtual time group",
+	waitReasonChanSetWait:           "chan set wait",
+	waitReasonBcastSend:             "broadcast send",
+	waitReasonBcastReceive:          "broadcast receive",
+	waitReasonChanReceiveEmpty:      "chan receive (empty)",
+	waitReasonChanSendFull:          "chan send (full)",
 }
 
 func (w waitReason) String() string {
@@ -1102,6 +1202,42 @@ func (w waitReason) String() string {
 	return waitReasonStrings[w]
 }
 
+// A goroutine blocked sending on a non-nil channel waits either for a
+// receiver to rendezvous with, on an unbuffered channel, or for room
+// in the buffer, on a buffered one, which is usually a sign that the
+// receivers can't keep up; and likewise for a receive. The wait
+// reasons tell them apart, and their strings share the prefix "chan
+// send" or "chan receive" with the reasons for nil channels.
+
+// chanSendWaitReason returns the wait reason of a send that blocks on c.
+func chanSendWaitReason(c *hchan) waitReason {
+	if c.dataqsiz == 0 {
+		return waitReasonChanSendSync
+	}
+	return waitReasonChanSendFull
+}
+
+// chanRecvWaitReason returns the wait reason of a receive that blocks
+// on c.
+func chanRecvWaitReason(c *hchan) waitReason {
+	if c.dataqsiz == 0 {
+		return waitReasonChanReceiveSync
+	}
+	return waitReasonChanReceiveEmpty
+}
+
+// isChanSend reports whether w is the wait reason of a send on a
+// non-nil channel.
+func (w waitReason) isChanSend() bool {
+	return w == waitReasonChanSendSync || w == waitReasonChanSendFull
+}
+
+// isChanRecv reports whether w is the wait reason of a receive from a
+// non-nil channel.
+func (w waitReason) isChanRecv() bool {
+	return w == waitReasonChanReceiveSync || w == waitReasonChanReceiveEmpty
+}
+
 var (
 	allm       *m
 	gomaxprocs int32
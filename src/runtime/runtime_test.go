@@ -7,10 +7,12 @@ package runtime_test
 import (
 	"flag"
 	"io"
+	"reflect"
 	. "runtime"
 	"runtime/debug"
 	"strings"
 	"testing"
+	"time"
 	"unsafe"
 )
 
@@ -355,6 +357,94 @@ func TestGoroutineProfileTrivial(t *testing.T) {
 	}
 }
 
+//go:noinline
+func goroutineProfileSelect(a, b chan int) {
+	select {
+	case <-a:
+	case <-b:
+	}
+}
+
+func TestGoroutineProfileEx(t *testing.T) {
+	recv := make(chan string)
+	a, b := make(chan int), make(chan int)
+	done := make(chan bool)
+	go func() {
+		<-recv
+		done <- true
+	}()
+	go func() {
+		goroutineProfileSelect(a, b)
+		done <- true
+	}()
+	defer func() {
+		recv <- ""
+		a <- 0
+		<-done
+		<-done
+	}()
+
+	recvChan := reflect.ValueOf(recv).Pointer()
+	var p []GoroutineRecord
+	var recvRec, selectRec *GoroutineRecord
+	for i := 0; ; i++ {
+		n, _ := GoroutineProfileEx(nil)
+		p = make([]GoroutineRecord, n+10)
+		n, ok := GoroutineProfileEx(p)
+		if !ok {
+			t.Fatalf("GoroutineProfileEx(%d records) = %d, false", len(p), n)
+		}
+		p = p[:n]
+		if p[0].WaitReason != "" || p[0].Chan != 0 {
+			t.Errorf("record of the running goroutine has WaitReason %q, Chan %#x, want none", p[0].WaitReason, p[0].Chan)
+		}
+		recvRec, selectRec = nil, nil
+		for j := range p {
+			r := &p[j]
+			if r.Chan == recvChan {
+				recvRec = r
+			}
+			frames := CallersFrames(r.Stack())
+			for {
+				f, more := frames.Next()
+				if strings.HasSuffix(f.Function, ".goroutineProfileSelect") && r.WaitReason == "select" {
+					selectRec = r
+				}
+				if !more {
+					break
+				}
+			}
+		}
+		if recvRec != nil && selectRec != nil && recvRec.WaitNs > 0 && selectRec.WaitNs > 0 {
+			break
+		}
+		if i >= 100 {
+			t.Fatalf("blocked goroutines not found in profile: %+v", p)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if recvRec.WaitReason != "chan receive (sync)" || recvRec.ChanElem != "string" {
+		t.Errorf("receiving goroutine has WaitReason %q, ChanElem %q, want %q, %q", recvRec.WaitReason, recvRec.ChanElem, "chan receive (sync)", "string")
+	}
+	if selectRec.Chan != 0 || selectRec.ChanElem != "" {
+		t.Errorf("goroutine in select has Chan %#x, ChanElem %q, want none", selectRec.Chan, selectRec.ChanElem)
+	}
+
+	// The wait time keeps growing while the goroutine is blocked.
+	wait := recvRec.WaitNs
+	time.Sleep(10 * time.Millisecond)
+	n, ok := GoroutineProfileEx(p[:cap(p)])
+	if !ok {
+		t.Fatalf("GoroutineProfileEx(%d records) = %d, false", cap(p), n)
+	}
+	for _, r := range p[:n] {
+		if r.Chan == recvChan && r.WaitNs < wait+int64(10*time.Millisecond) {
+			t.Errorf("WaitNs went from %d to %d over 10ms", wait, r.WaitNs)
+		}
+	}
+}
+
 func TestVersion(t *testing.T) {
 	// Test that version does not contain \r or \n.
 	vers := Version()
@@ -7,6 +7,7 @@ package runtime
 // This file contains the implementation of Go select statements.
 
 import (
+	"internal/goexperiment"
 	"runtime/internal/atomic"
 	"unsafe"
 )
@@ -36,7 +37,7 @@ func sellock(scases []scase, lockorder []uint16) {
 		c0 := scases[o].c
 		if c0 != c {
 			c = c0
-			lock(&c.lock)
+			lockchan(c)
 		}
 	}
 }
@@ -60,6 +61,17 @@ func selunlock(scases []scase, lockorder []uint16) {
 }
 
 func selparkcommit(gp *g, _ unsafe.Pointer) bool {
+	selparkunlock(gp)
+	chanSetWakeParked()
+	return true
+}
+
+// selparkunlock is selparkcommit without readying the channel set
+// waiters woken by gp's send cases.
+func selparkunlock(gp *g) {
+	if chanParkStress {
+		chanparkstress(gp)
+	}
 	// There are unlocked sudogs that point into gp's stack. Stack
 	// copying must lock the channels of those sudogs.
 	// Set activeStackChans here instead of before we try parking
@@ -96,7 +108,6 @@ func selparkcommit(gp *g, _ unsafe.Pointer) bool {
 	if lastc != nil {
 		unlock(&lastc.lock)
 	}
-	return true
 }
 
 func block() {
@@ -114,6 +125,11 @@ func block() {
 // [ncases]uintptr (also on the stack); for other builds, it's set to
 // nil.
 //
+// If ordered is set, the first half of order0 holds the indexes of the
+// scases in the order the cases are listed in the select statement,
+// and the ready cases are polled in that order instead of a random one.
+// This is only done with GOEXPERIMENT=orderedselect.
+//
 // selectgo returns the index of the chosen scase, which matches the
 // ordinal position of its respective select{recv,send,default} call.
 // Also, if the chosen scase was a receive operation, it reports whether
@@ -129,10 +145,11 @@ func block() {
 //4. 唤醒后返回channel对应的case index
 //   4.1 如果是读操作，解锁所有的channel，然后返回(case index, true)
 //   4.2 如果是写操作，解锁所有的channel，然后返回(case index, false)
-func selectgo(cas0 *scase, order0 *uint16, pc0 *uintptr, nsends, nrecvs int, block bool) (int, bool) {
+func selectgo(cas0 *scase, order0 *uint16, pc0 *uintptr, nsends, nrecvs int, block, ordered bool) (int, bool) {
 	if debugSelect {
 		print("select: cas0=", cas0, "\n")
 	}
+	chancheckctx("select", block)
 
 	// NOTE: In order to maintain a lean stack size, the number of scases
 	// is capped at 65536.
@@ -161,71 +178,114 @@ func selectgo(cas0 *scase, order0 *uint16, pc0 *uintptr, nsends, nrecvs int, blo
 	}
 
 	var t0 int64
-	if blockprofilerate > 0 {
-		t0 = cputicks()
+	if chanBlockProfileRate() > 0 {
+		t0 = nanotime()
 	}
 
 	// The compiler rewrites selects that statically have
 	// only 0 or 1 cases plus default into simpler constructs.
 	// The only way we can end up with such small sel.ncase
 	// values here is for a larger select in which most channels
-	// have been nilled out. The general code handles those
-	// cases correctly, and they are rare enough not to bother
-	// optimizing (and needing to test).
+	// have been nilled out. Once the nil channels are omitted
+	// below, a select left with a single channel is done as a
+	// plain send or receive.
+
+	// With channel decisions being recorded or replayed, the poll
+	// order is drawn from a seed that can be replayed.
+	var decision selectDecision
+	decide := atomic.Load(&chanDecisions.enabled) != 0
+	if decide {
+		decision.start(getcallerpc())
+	}
+
+	if debug.channuma == chanNUMAConsumer {
+		for i := nsends; i < ncases; i++ {
+			if c := scases[i].c; c != nil && c.flags&chanNUMAPending != 0 {
+				chanNUMAConsume(c)
+			}
+		}
+	}
 
 	// generate permuted order
 	norder := 0
-	for i := range scases {
-		cas := &scases[i]
+	if ordered {
+		// Keep the order given by the caller.
+		for _, i := range pollorder {
+			cas := &scases[i]
 
-		// Omit cases without channels from the poll and lock orders.
-		if cas.c == nil {
-			cas.elem = nil // allow GC
-			continue
+			// Omit cases without channels from the poll and lock orders.
+			if cas.c == nil {
+				cas.elem = nil // allow GC
+				continue
+			}
+
+			pollorder[norder] = i
+			norder++
 		}
+	} else {
+		for i := range scases {
+			cas := &scases[i]
 
-		j := fastrandn(uint32(norder + 1))
-		pollorder[norder] = pollorder[j]
-		pollorder[j] = uint16(i)
-		norder++
+			// Omit cases without channels from the poll and lock orders.
+			if cas.c == nil {
+				cas.elem = nil // allow GC
+				continue
+			}
+
+			var j uint32
+			if decide {
+				j = decision.randn(uint32(norder + 1))
+			} else {
+				j = fastrandn(uint32(norder + 1))
+			}
+			pollorder[norder] = pollorder[j]
+			pollorder[j] = uint16(i)
+			norder++
+		}
 	}
 	pollorder = pollorder[:norder]
 	lockorder = lockorder[:norder]
+	adapt := debug.selectadaptive != 0 && !ordered && !decide
+	hotOnly := adapt && selectAdaptOrder(getcallerpc(), ncases, pollorder)
 
-	// sort the cases by Hchan address to get the locking order.
-	// simple heap sort, to guarantee n log n time and constant stack footprint.
-	for i := range lockorder {
-		j := i
-		// Start with the pollorder to permute cases on the same channel.
-		c := scases[pollorder[i]].c
-		for j > 0 && scases[lockorder[(j-1)/2]].c.sortkey() < c.sortkey() {
-			k := (j - 1) / 2
-			lockorder[j] = lockorder[k]
-			j = k
+	// A select with a single channel is a send or receive on it,
+	// which doesn't need the sudog per case or the locking of the
+	// general path. A goroutine blocked in it is reported as blocked
+	// in that send or receive rather than in a select. Recorded and
+	// replayed selects, and the race and msan annotations of the
+	// cases, are left to the general path.
+	if norder == 1 && !decide && !raceenabled && !msanenabled {
+		casi, recvOK := selectone(scases, int(pollorder[0]), nsends, block, getcallerpc())
+		if !block && debug.selectspindetect != 0 {
+			selectSpin(getcallerpc(), casi < 0)
 		}
-		lockorder[j] = pollorder[i]
+		if debug.closedrecvwarn > 0 && casi >= nsends && !recvOK {
+			closedRecv(getcallerpc())
+		}
+		if trace.enabled {
+			traceSelect(casi, nsends, nrecvs)
+		}
+		return casi, recvOK
 	}
-	for i := len(lockorder) - 1; i >= 0; i-- {
-		o := lockorder[i]
-		c := scases[o].c
-		lockorder[i] = lockorder[0]
-		j := 0
-		for {
-			k := j*2 + 1
-			if k >= i {
-				break
-			}
-			if k+1 < i && scases[lockorder[k]].c.sortkey() < scases[lockorder[k+1]].c.sortkey() {
-				k++
-			}
-			if c.sortkey() < scases[lockorder[k]].c.sortkey() {
-				lockorder[j] = lockorder[k]
-				j = k
-				continue
-			}
-			break
+
+	gp := getg()
+	allpoll, alllock := pollorder, lockorder
+lockall:
+	if hotOnly {
+		// The hot case is polled first, so if it is ready, it is
+		// chosen whatever the other cases are. Poll it alone, with
+		// only its channel locked, and lock all the channels only
+		// if it isn't ready. See selectadapt.go.
+		pollorder, lockorder = allpoll[:1], alllock[:1]
+		lockorder[0] = pollorder[0]
+	} else if !gp.selectLocks.lookup(cas0, scases, lockorder) {
+		// sort the cases by Hchan address to get the locking order.
+		// Wide selects executed repeatedly over the same channels reuse
+		// the order computed last time, if the goroutine has it cached.
+		sortlockorder(scases, pollorder, lockorder)
+		if len(scases) >= selectLockCacheMin {
+			gp.selectLocks = gp.selectLocks.store(cas0, scases, lockorder)
 		}
-		lockorder[j] = o
 	}
 
 	if debugSelect {
@@ -241,7 +301,6 @@ func selectgo(cas0 *scase, order0 *uint16, pc0 *uintptr, nsends, nrecvs int, blo
 	sellock(scases, lockorder)
 
 	var (
-		gp     *g
 		sg     *sudog
 		c      *hchan
 		k      *scase
@@ -256,16 +315,22 @@ func selectgo(cas0 *scase, order0 *uint16, pc0 *uintptr, nsends, nrecvs int, blo
 	var cas *scase
 	var caseSuccess bool
 	var caseReleaseTime int64 = -1
+	var parkTime int64
 	var recvOK bool
+	var blocked bool
+	// All channels are locked, so a closed receive case is reported
+	// only once every value sent before the close has been received.
 	for _, casei := range pollorder {
 		casi = int(casei)
 		cas = &scases[casi]
 		c = cas.c
 
 		if casi >= nsends {
-			sg = c.sendq.dequeue()
-			if sg != nil {
-				goto recv
+			if c.borrows() == 0 || c.qcount == 0 {
+				sg = c.sendq.dequeue()
+				if sg != nil {
+					goto recv
+				}
 			}
 			if c.qcount > 0 {
 				goto bufrecv
@@ -284,20 +349,33 @@ func selectgo(cas0 *scase, order0 *uint16, pc0 *uintptr, nsends, nrecvs int, blo
 			if sg != nil {
 				goto send
 			}
-			if c.qcount < c.dataqsiz {
+			if c.qcount+c.borrows() < c.dataqsiz {
 				goto bufsend
 			}
 		}
 	}
 
-	if !block {
+	if hotOnly {
+		selunlock(scases, lockorder)
+		hotOnly = false
+		pollorder, lockorder = allpoll, alllock
+		goto lockall
+	}
+
+	if !block || chanCancelled(gp) {
 		selunlock(scases, lockorder)
 		casi = -1
 		goto retc
 	}
 
 	// pass 2 - enqueue on all chans
-	gp = getg()
+	// Every channel has stayed locked since pass 1, so none of the
+	// cases can have become ready since, and there is no need to
+	// check again before enqueuing. A case that becomes ready after
+	// we unlock in selparkcommit finds our sudog and wakes us.
+	blocked = true
+	parkTime = chanStatsPark(waitReasonSelect, t0)
+	chanWaitStart(gp, parkTime)
 	if gp.waiting != nil {
 		throw("gp.waiting != nil")
 	}
@@ -308,7 +386,10 @@ func selectgo(cas0 *scase, order0 *uint16, pc0 *uintptr, nsends, nrecvs int, blo
 		c = cas.c
 		sg := acquireSudog()
 		sg.g = gp
-		sg.isSelect = true
+		sg.flags = sudogSelect
+		if casi < nsends {
+			sg.flags |= sudogSend
+		}
 		// No stack splits between assigning elem and enqueuing
 		// sg on gp.waiting where copystack can find it.
 		sg.elem = cas.elem
@@ -322,27 +403,44 @@ func selectgo(cas0 *scase, order0 *uint16, pc0 *uintptr, nsends, nrecvs int, blo
 		nextp = &sg.waitlink
 
 		if casi < nsends {
+			if debug.chaninvariants != 0 {
+				chancheckenqueue(c, &c.sendq, sg)
+			}
 			c.sendq.enqueue(sg)
+			if c.sets() != nil {
+				chanSetNotify(c, &gp.m.chanSetWake)
+			}
 		} else {
+			if debug.chaninvariants != 0 {
+				chancheckenqueue(c, &c.recvq, sg)
+			}
 			c.recvq.enqueue(sg)
 		}
 	}
 
 	// wait for someone to wake us up
-	gp.param = nil
 	// Signal to anyone trying to shrink our stack that we're about
 	// to park on a channel. The window between when this G's status
 	// changes and when we set gp.activeStackChans is not safe for
 	// stack shrinking.
 	atomic.Store8(&gp.parkingOnChan, 1)
-	gopark(selparkcommit, nil, waitReasonSelect, traceEvGoBlockSelect, 1)
+	if gp.chanCancel != nil {
+		gopark(selcancelparkcommit, nil, waitReasonSelect, traceEvGoBlockSelect, 1)
+	} else {
+		gopark(selparkcommit, nil, waitReasonSelect, traceEvGoBlockSelect, 1)
+	}
 	gp.activeStackChans = false
+	chanStatsUnpark(waitReasonSelect, parkTime)
 
 	sellock(scases, lockorder)
 
+	if gp.chanCancel != nil {
+		chanCancelUnpark(gp)
+	}
 	gp.selectDone = 0
-	sg = (*sudog)(gp.param)
-	gp.param = nil
+	if gp.param != nil {
+		throw("selectgo: wakeup with non-nil gp.param")
+	}
 
 	// pass 3 - dequeue from unsuccessful chans
 	// otherwise they stack up on quiet channels
@@ -352,9 +450,15 @@ func selectgo(cas0 *scase, order0 *uint16, pc0 *uintptr, nsends, nrecvs int, blo
 	cas = nil
 	caseSuccess = false
 	sglist = gp.waiting
-	// Clear all elem before unlinking from gp.waiting.
+	// Find the sudog of the case that woke us, if any, and clear
+	// all elem before unlinking from gp.waiting.
+	sg = nil
 	for sg1 := gp.waiting; sg1 != nil; sg1 = sg1.waitlink {
-		sg1.isSelect = false
+		if sg1.woken {
+			sg = sg1
+			sg1.woken = false
+		}
+		sg1.flags = 0
 		sg1.elem = nil
 		sg1.c = nil
 	}
@@ -385,10 +489,16 @@ func selectgo(cas0 *scase, order0 *uint16, pc0 *uintptr, nsends, nrecvs int, blo
 	}
 
 	if cas == nil {
+		if sg == nil && gp.chanCancel != nil {
+			// Cancelled; see chancancel.go.
+			selunlock(scases, lockorder)
+			goto retc
+		}
 		throw("selectgo: bad wakeup")
 	}
 
 	c = cas.c
+	chanStatsRecordWaitLocked(c, parkTime)
 
 	if debugSelect {
 		print("wait-return: cas0=", cas0, " c=", c, " cas=", cas, " send=", casi < nsends, "\n")
@@ -434,15 +544,26 @@ bufrecv:
 	recvOK = true
 	qp = chanbuf(c, c.recvx)
 	if cas.elem != nil {
-		typedmemmove(c.elemtype, cas.elem, qp)
+		chanmove(c, cas.elem, qp)
 	}
-	typedmemclr(c.elemtype, qp)
+	chanRecvClear(c, qp)
 	c.recvx++
 	if c.recvx == c.dataqsiz {
 		c.recvx = 0
 	}
 	c.qcount--
+	if s := c.side; s != nil && s.borrows != 0 {
+		s.borrows++
+	}
+	if trace.enabled {
+		traceChanBuf(c, c.qcount+1)
+	}
+	if raceenabled {
+		racechancount(c)
+	}
+	chanStatsRecordOp(c, 0, 1)
 	selunlock(scases, lockorder)
+	chanStatsImmediate(0, 1)
 	goto retc
 
 bufsend:
@@ -454,13 +575,28 @@ bufsend:
 	if msanenabled {
 		msanread(cas.elem, c.elemtype.size)
 	}
-	typedmemmove(c.elemtype, chanbuf(c, c.sendx), cas.elem)
+	chanmove(c, chanbuf(c, c.sendx), cas.elem)
 	c.sendx++
 	if c.sendx == c.dataqsiz {
 		c.sendx = 0
 	}
 	c.qcount++
-	selunlock(scases, lockorder)
+	if trace.enabled {
+		traceChanBuf(c, c.qcount-1)
+	}
+	if raceenabled {
+		racechancount(c)
+	}
+	chanStatsRecordOp(c, 1, 0)
+	if c.sets() != nil {
+		var wake gList
+		chanSetNotify(c, &wake)
+		selunlock(scases, lockorder)
+		chanSetWakeAll(&wake)
+	} else {
+		selunlock(scases, lockorder)
+	}
+	chanStatsImmediate(1, 0)
 	goto retc
 
 recv:
@@ -475,9 +611,10 @@ recv:
 rclose:
 	// read at end of closed channel
 	selunlock(scases, lockorder)
+	chanStatsImmediate(0, 0)
 	recvOK = false
 	if cas.elem != nil {
-		typedmemclr(c.elemtype, cas.elem)
+		chanclr(c, cas.elem)
 	}
 	if raceenabled {
 		raceacquire(c.raceaddr())
@@ -500,20 +637,185 @@ send:
 
 retc:
 	if caseReleaseTime > 0 {
-		blockevent(caseReleaseTime-t0, 1)
+		chanblockevent(caseReleaseTime-t0, 1)
+	}
+	if decide {
+		decision.done(casi)
+	}
+	if debug.chanblockwarn > 0 && casi >= 0 && (casi < nsends || recvOK) {
+		chanBlockWarnOp(scases[casi].c, casi < nsends, getcallerpc())
+	}
+	if debug.chanclosecheck != 0 && casi >= 0 && casi < nsends {
+		chanCloseCheckSend(scases[casi].c, getcallerpc())
+	}
+	if !block && debug.selectspindetect != 0 {
+		selectSpin(getcallerpc(), casi < 0)
+	}
+	if debug.closedrecvwarn > 0 && casi >= nsends && !recvOK {
+		closedRecv(getcallerpc())
+	}
+	if adapt && casi >= 0 {
+		selectAdaptDone(getcallerpc(), ncases, casi)
+	}
+	if casi >= 0 {
+		op := uint8(chanProfSelect)
+		if blocked {
+			op |= chanProfBlocked
+		}
+		chanprofop(op, 0)
+	}
+	if trace.enabled && (casi >= 0 || !block) {
+		traceSelect(casi, nsends, nrecvs)
 	}
 	return casi, recvOK
 
 sclose:
 	// send on closed channel
 	selunlock(scases, lockorder)
-	panic(plainError("send on closed channel"))
+	chanMisuseSendOnClosed()
+	panic(closedChannelError(c))
+}
+
+// selectone performs case casi of a select whose other cases all have
+// nil channels, as selectgo would, and returns selectgo's results.
+// callerpc is the PC of selectgo's caller.
+func selectone(scases []scase, casi, nsends int, block bool, callerpc uintptr) (int, bool) {
+	cas := &scases[casi]
+	var recvOK bool
+	if casi < nsends {
+		if !chansend(cas.c, cas.elem, block, callerpc, nil) {
+			return -1, false
+		}
+	} else {
+		var selected bool
+		selected, recvOK = chanrecv(cas.c, cas.elem, block)
+		if !selected {
+			return -1, false
+		}
+	}
+	if debug.chanblockwarn > 0 && (casi < nsends || recvOK) {
+		chanBlockWarnOp(cas.c, casi < nsends, callerpc)
+	}
+	return casi, recvOK
 }
 
 func (c *hchan) sortkey() uintptr {
 	return uintptr(unsafe.Pointer(c))
 }
 
+// sortlockorder sorts the cases in pollorder by Hchan address into
+// lockorder, which must have the same length.
+// It is a simple heap sort, to guarantee n log n time and constant
+// stack footprint.
+func sortlockorder(scases []scase, pollorder, lockorder []uint16) {
+	for i := range lockorder {
+		j := i
+		// Start with the pollorder to permute cases on the same channel.
+		c := scases[pollorder[i]].c
+		for j > 0 && scases[lockorder[(j-1)/2]].c.sortkey() < c.sortkey() {
+			k := (j - 1) / 2
+			lockorder[j] = lockorder[k]
+			j = k
+		}
+		lockorder[j] = pollorder[i]
+	}
+	for i := len(lockorder) - 1; i >= 0; i-- {
+		o := lockorder[i]
+		c := scases[o].c
+		lockorder[i] = lockorder[0]
+		j := 0
+		for {
+			k := j*2 + 1
+			if k >= i {
+				break
+			}
+			if k+1 < i && scases[lockorder[k]].c.sortkey() < scases[lockorder[k+1]].c.sortkey() {
+				k++
+			}
+			if c.sortkey() < scases[lockorder[k]].c.sortkey() {
+				lockorder[j] = lockorder[k]
+				j = k
+				continue
+			}
+			break
+		}
+		lockorder[j] = o
+	}
+}
+
+// selectLockCacheMin is the minimum number of cases for which
+// selectgo caches a select's lock order on its goroutine.
+const selectLockCacheMin = 16
+
+// A selectLockCache remembers the lock order computed by the most
+// recent wide select executed by a goroutine, so that a select run
+// repeatedly over the same channels (typically in a loop) doesn't
+// have to sort its cases on every execution.
+//
+// The cache is keyed by the address of the scases array, which for
+// a compiled select is a fixed slot in the caller's frame, and by the
+// address of every case's channel. Addresses are kept as uintptrs so
+// the cache neither keeps channels alive nor points into the stack.
+// That is safe because the lock order depends only on the addresses:
+// if a channel is freed and another one allocated at the same
+// address, the cached order is still a valid lock order.
+//
+// Selects that use the same channel in more than one case are never
+// cached, because sortlockorder deliberately permutes such cases
+// according to pollorder.
+type selectLockCache struct {
+	cas0  uintptr
+	chans []uintptr // channel of each case, 0 for nil channels
+	order []uint16  // lock order, omitting cases with nil channels
+}
+
+// lookup copies the cached lock order for the select described by
+// cas0 and scases into lockorder and reports whether it did so.
+// sc may be nil.
+func (sc *selectLockCache) lookup(cas0 *scase, scases []scase, lockorder []uint16) bool {
+	if sc == nil || sc.cas0 != uintptr(unsafe.Pointer(cas0)) ||
+		len(sc.chans) != len(scases) || len(sc.order) != len(lockorder) {
+		return false
+	}
+	for i := range scases {
+		if sc.chans[i] != uintptr(unsafe.Pointer(scases[i].c)) {
+			return false
+		}
+	}
+	copy(lockorder, sc.order)
+	return true
+}
+
+// store records lockorder as the lock order of the select described
+// by cas0 and scases, reusing sc's storage if possible, and returns
+// the cache to use from now on. sc may be nil.
+//
+// store must not be called with any channel locks held, because it
+// may allocate.
+func (sc *selectLockCache) store(cas0 *scase, scases []scase, lockorder []uint16) *selectLockCache {
+	for i := 0; i+1 < len(lockorder); i++ {
+		if scases[lockorder[i]].c == scases[lockorder[i+1]].c {
+			// Duplicate channel. Keep whatever was cached.
+			return sc
+		}
+	}
+	if sc == nil {
+		sc = new(selectLockCache)
+	}
+	if cap(sc.chans) < len(scases) {
+		sc.chans = make([]uintptr, len(scases))
+		sc.order = make([]uint16, len(scases))
+	}
+	sc.cas0 = uintptr(unsafe.Pointer(cas0))
+	sc.chans = sc.chans[:len(scases)]
+	for i := range scases {
+		sc.chans[i] = uintptr(unsafe.Pointer(scases[i].c))
+	}
+	sc.order = sc.order[:len(lockorder)]
+	copy(sc.order, lockorder)
+	return sc
+}
+
 // A runtimeSelect is a single case passed to rselect.
 // This must match ../reflect/value.go:/runtimeSelect
 type runtimeSelect struct {
@@ -538,8 +840,9 @@ func reflect_rselect(cases []runtimeSelect) (int, bool) {
 	if len(cases) == 0 {
 		block()
 	}
-	sel := make([]scase, len(cases))
-	orig := make([]int, len(cases))
+	scratch := getSelectScratch(len(cases))
+	sel := scratch.sel[:len(cases)]
+	orig := scratch.orig[:len(cases)]
 	nsends, nrecvs := 0, 0
 	dflt := -1
 	for i, rc := range cases {
@@ -571,17 +874,26 @@ func reflect_rselect(cases []runtimeSelect) (int, bool) {
 		copy(orig[nsends:], orig[len(cases)-nrecvs:])
 	}
 
-	order := make([]uint16, 2*(nsends+nrecvs))
+	order := scratch.order[:2*(nsends+nrecvs)]
 	var pc0 *uintptr
 	if raceenabled {
-		pcs := make([]uintptr, nsends+nrecvs)
+		pcs := scratch.pcs[:nsends+nrecvs]
 		for i := range pcs {
 			selectsetpc(&pcs[i])
 		}
 		pc0 = &pcs[0]
 	}
 
-	chosen, recvOK := selectgo(&sel[0], &order[0], pc0, nsends, nrecvs, dflt == -1)
+	if goexperiment.OrderedSelect {
+		selectListOrder(order, orig, nsends, nsends+nrecvs)
+	}
+
+	chosen, recvOK := selectgo(&sel[0], &order[0], pc0, nsends, nrecvs, dflt == -1, goexperiment.OrderedSelect)
+
+	// Don't let the scratch buffer keep the channels and values alive.
+	for i := range sel {
+		sel[i] = scase{}
+	}
 
 	// Translate chosen back to caller's ordering.
 	if chosen < 0 {
@@ -592,7 +904,110 @@ func reflect_rselect(cases []runtimeSelect) (int, bool) {
 	return chosen, recvOK
 }
 
+// selectListOrder sets the first n entries of order to the indexes of
+// the n cases of a reflect select, as passed to selectgo, in the order
+// they are listed. orig holds the index in the list of each case. The
+// sends are listed in order at the start of the cases, followed by the
+// receives in reverse order.
+func selectListOrder(order []uint16, orig []int, nsends, n int) {
+	s, r := 0, n-1
+	for k := 0; k < n; k++ {
+		if r < nsends || (s < nsends && orig[s] < orig[r]) {
+			order[k] = uint16(s)
+			s++
+		} else {
+			order[k] = uint16(r)
+			r--
+		}
+	}
+}
+
+// reflect_rselectcompiled runs a select prepared by
+// reflect.CompileSelect. Unlike reflect_rselect, it is given the cases
+// in the form selectgo takes, the sends followed by the receives in
+// reverse order, with nil channels for the cases to ignore, and the
+// array for selectgo's poll and lock orders, of length 2*len(sel). The
+// cases stay in place, so package reflect can reuse them. orig holds
+// the index in the caller's list of each case. It returns the index in
+// sel of the chosen case, or -1 for the default case, which the select
+// has if blocking is false.
+//
+//go:linkname reflect_rselectcompiled reflect.rselectcompiled
+func reflect_rselectcompiled(sel []scase, orig []int, order []uint16, nsends, nrecvs int, blocking bool) (int, bool) {
+	n := nsends + nrecvs
+	if n == 0 {
+		if blocking {
+			block()
+		}
+		return -1, false
+	}
+	var pc0 *uintptr
+	if raceenabled {
+		pcs := getSelectScratch(n).pcs[:n]
+		for i := range pcs {
+			selectsetpc(&pcs[i])
+		}
+		pc0 = &pcs[0]
+	}
+	if goexperiment.OrderedSelect {
+		selectListOrder(order, orig, nsends, n)
+	}
+	return selectgo(&sel[0], &order[0], pc0, nsends, nrecvs, blocking, goexperiment.OrderedSelect)
+}
+
+// selectScratchIdleGCs is the number of GC cycles after which the GC
+// drops the select scratch buffers of a goroutine that hasn't used
+// them.
+const selectScratchIdleGCs = 4
+
+// A selectScratch holds the arrays reflect_rselect passes to
+// selectgo. Compiled selects keep these arrays in the caller's frame,
+// but reflect selects have a dynamic number of cases, so they borrow
+// them from the goroutine instead of allocating them on every call.
+//
+// The buffers live in the heap, so stack copying doesn't need to know
+// about them. They are never borrowed twice at the same time: case
+// values are evaluated by package reflect before it calls
+// reflect_rselect, and selectgo never calls back into user code, so a
+// goroutine can't start another reflect select while one is using the
+// buffers.
+type selectScratch struct {
+	sel    []scase
+	orig   []int
+	order  []uint16  // 2*len(sel)
+	pcs    []uintptr // only with the race detector
+	lastGC uint32    // memstats.numgc at last use
+}
+
+// getSelectScratch returns the current goroutine's select scratch
+// buffers, grown to hold at least n cases.
+func getSelectScratch(n int) *selectScratch {
+	gp := getg()
+	s := gp.selectScratch
+	if s == nil {
+		s = new(selectScratch)
+		gp.selectScratch = s
+	}
+	if cap(s.sel) < n {
+		// Grow geometrically so that a goroutine running selects of
+		// increasing width doesn't reallocate every time.
+		c := 2 * cap(s.sel)
+		if c < n {
+			c = n
+		}
+		s.sel = make([]scase, c)
+		s.orig = make([]int, c)
+		s.order = make([]uint16, 2*c)
+		if raceenabled {
+			s.pcs = make([]uintptr, c)
+		}
+	}
+	s.lastGC = atomic.Load(&memstats.numgc)
+	return s
+}
+
 func (q *waitq) dequeueSudoG(sgp *sudog) {
+	sgp.queued = false
 	x := sgp.prev
 	y := sgp.next
 	if x != nil {
@@ -0,0 +1,195 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build goexperiment.orderedselect
+// +build goexperiment.orderedselect
+
+package runtime_test
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const orderedSelectTrials = 100
+
+func TestOrderedSelect(t *testing.T) {
+	c1 := make(chan int, 1)
+	c2 := make(chan int, 1)
+	s := make(chan int, 1)
+	for i := 0; i < orderedSelectTrials; i++ {
+		c1 <- 1
+		c2 <- 2
+		select {
+		case v := <-c2:
+			if v != 2 {
+				t.Fatalf("received %d from c2, want 2", v)
+			}
+		case s <- 0:
+			t.Fatalf("chose send, want first case")
+		case <-c1:
+			t.Fatalf("chose c1, want first case")
+		}
+		select {
+		case s <- 3:
+		case <-c1:
+			t.Fatalf("chose c1, want first case")
+		default:
+			t.Fatalf("chose default, want first case")
+		}
+		if v := <-s; v != 3 {
+			t.Fatalf("received %d from s, want 3", v)
+		}
+		<-c1
+	}
+}
+
+func TestOrderedSelectSkipsNotReady(t *testing.T) {
+	var nilc chan int
+	empty := make(chan int)
+	full := make(chan int)
+	c1 := make(chan int, 1)
+	c2 := make(chan int, 1)
+	for i := 0; i < orderedSelectTrials; i++ {
+		c1 <- 1
+		c2 <- 2
+		select {
+		case <-nilc:
+			t.Fatalf("chose nil channel")
+		case <-empty:
+			t.Fatalf("chose empty channel")
+		case full <- 0:
+			t.Fatalf("chose send without receiver")
+		case <-c1:
+		case <-c2:
+			t.Fatalf("chose c2, want c1")
+		}
+		<-c2
+
+		select {
+		case <-empty:
+			t.Fatalf("chose empty channel")
+		default:
+		}
+	}
+}
+
+func TestOrderedSelectDuplicateChannel(t *testing.T) {
+	c := make(chan int, 1)
+	d := make(chan int, 1)
+	for i := 0; i < orderedSelectTrials; i++ {
+		c <- 1
+		d <- 2
+		chosen := 0
+		select {
+		case <-d:
+			chosen = 1
+		case <-c:
+			chosen = 2
+		case <-d:
+			chosen = 3
+		case <-c:
+			chosen = 4
+		}
+		if chosen != 1 {
+			t.Fatalf("chose case %d, want 1", chosen)
+		}
+		<-c
+	}
+}
+
+// TestOrderedSelectWide checks a select with enough cases to use the
+// per-goroutine lock order cache.
+func TestOrderedSelectWide(t *testing.T) {
+	var cs [16]chan int
+	for i := range cs {
+		cs[i] = make(chan int, 1)
+	}
+	for i := 0; i < orderedSelectTrials; i++ {
+		first := i % len(cs)
+		for j := first; j < len(cs); j++ {
+			cs[j] <- j
+		}
+		got := -1
+		select {
+		case got = <-cs[0]:
+		case got = <-cs[1]:
+		case got = <-cs[2]:
+		case got = <-cs[3]:
+		case got = <-cs[4]:
+		case got = <-cs[5]:
+		case got = <-cs[6]:
+		case got = <-cs[7]:
+		case got = <-cs[8]:
+		case got = <-cs[9]:
+		case got = <-cs[10]:
+		case got = <-cs[11]:
+		case got = <-cs[12]:
+		case got = <-cs[13]:
+		case got = <-cs[14]:
+		case got = <-cs[15]:
+		}
+		if got != first {
+			t.Fatalf("received from cs[%d], want cs[%d]", got, first)
+		}
+		for j := first + 1; j < len(cs); j++ {
+			<-cs[j]
+		}
+	}
+}
+
+func TestOrderedSelectBlocking(t *testing.T) {
+	c1 := make(chan int)
+	c2 := make(chan int)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		c2 <- 2
+	}()
+	select {
+	case <-c1:
+		t.Fatalf("chose c1, want c2")
+	case v := <-c2:
+		if v != 2 {
+			t.Fatalf("received %d from c2, want 2", v)
+		}
+	}
+}
+
+func TestOrderedSelectReflect(t *testing.T) {
+	const n = 20
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < orderedSelectTrials; i++ {
+		// Pick a random direction for each case and make a random
+		// subset of the cases ready.
+		cases := make([]reflect.SelectCase, n)
+		first := -1
+		for j := range cases {
+			c := make(chan int, 1)
+			ready := r.Intn(3) == 0
+			if r.Intn(2) == 0 {
+				if !ready {
+					c <- 0
+				}
+				cases[j] = reflect.SelectCase{Dir: reflect.SelectSend, Chan: reflect.ValueOf(c), Send: reflect.ValueOf(j)}
+			} else {
+				if ready {
+					c <- j
+				}
+				cases[j] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c)}
+			}
+			if ready && first < 0 {
+				first = j
+			}
+		}
+		if first < 0 {
+			first = n
+		}
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+		if chosen, _, _ := reflect.Select(cases); chosen != first {
+			t.Fatalf("reflect.Select chose case %d, want %d", chosen, first)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Adaptive poll order for selects.
+//
+// With GODEBUG=selectadaptive=1, each P remembers, per select call
+// site, which case the select chose recently, and a select that keeps
+// choosing the same case polls that case first. Since the hot case is
+// then chosen if it is ready, whatever the other cases are, selectgo
+// polls it with only its own channel locked, and locks the channels of
+// all the cases only if it is not ready. A fan-in select where one of
+// many channels carries almost all the traffic then usually costs
+// about as much as a receive from its hot channel, instead of locking
+// every channel and probing half of them on average.
+//
+// The order of the other cases stays random, every case is still
+// polled, and a select with no ready case blocks exactly as it would
+// otherwise. But the choice among several ready cases is no longer
+// uniform: the hot case wins more often than its share. To keep that
+// choice random, and to notice when another case becomes the hot one,
+// one execution in selectAdaptExplore keeps the plain random order.
+// Each ready case is so chosen with a probability of at least
+// 1/(selectAdaptExplore*n), where n is the number of ready cases.
+//
+// The state is kept in a small table in each P, indexed by a hash of
+// the PC of the call site, like the one of selectspin.go. Selects are
+// told apart by their PC and number of cases, so all the calls to
+// reflect.Select with the same number of cases share an entry. Sites
+// that collide evict each other, and a goroutine that moves to another
+// P starts from that P's entry; both only cost some probes.
+
+const (
+	selectAdaptSlots   = 16 // size of each P's table
+	selectAdaptMin     = 2  // streak from which the hot case is polled first
+	selectAdaptMax     = 16 // streak limit
+	selectAdaptExplore = 8  // one execution in this many is not biased
+)
+
+// A selectAdaptSlot records the case a select site chose recently.
+type selectAdaptSlot struct {
+	pc     uintptr
+	ncases uint32
+	hot    uint16 // index of the scase chosen recently
+	streak uint8  // net number of recent choices of hot
+}
+
+// selectAdaptOrder moves the hot case of the select called at pc with
+// ncases cases, if it has one, to the front of pollorder, and reports
+// whether it did.
+func selectAdaptOrder(pc uintptr, ncases int, pollorder []uint16) bool {
+	mp := acquirem()
+	s := &mp.p.ptr().selectAdapt[(pc^pc>>5)%selectAdaptSlots]
+	if s.pc != pc || int(s.ncases) != ncases || s.streak < selectAdaptMin {
+		releasem(mp)
+		return false
+	}
+	hot := s.hot
+	releasem(mp)
+	if fastrandn(selectAdaptExplore) == 0 {
+		return false
+	}
+	// The hot case is missing if its channel is nil this time.
+	for i, casi := range pollorder {
+		if casi == hot {
+			pollorder[0], pollorder[i] = hot, pollorder[0]
+			return true
+		}
+	}
+	return false
+}
+
+// selectAdaptDone records that the select called at pc with ncases
+// cases chose case casi.
+func selectAdaptDone(pc uintptr, ncases, casi int) {
+	mp := acquirem()
+	s := &mp.p.ptr().selectAdapt[(pc^pc>>5)%selectAdaptSlots]
+	switch {
+	case s.pc != pc || int(s.ncases) != ncases:
+		s.pc, s.ncases, s.hot, s.streak = pc, uint32(ncases), uint16(casi), 1
+	case int(s.hot) == casi:
+		if s.streak < selectAdaptMax {
+			s.streak++
+		}
+	default:
+		s.streak--
+		if s.streak == 0 {
+			s.hot, s.streak = uint16(casi), 1
+		}
+	}
+	releasem(mp)
+}
@@ -0,0 +1,106 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Detection of busy-wait loops around nonblocking selects.
+//
+// With GODEBUG=selectspindetect=1, each P counts, per call site, how
+// many times in a row a nonblocking select has taken its default case.
+// A site that takes it selectSpinCount times in a row within
+// selectSpinWindow is most likely a loop like
+//
+//	for {
+//		select {
+//		case v := <-c:
+//			...
+//		default:
+//		}
+//	}
+//
+// that keeps a CPU busy while it waits for the channel, and the runtime
+// prints a warning and the goroutine's stack. Each site is reported at
+// most once.
+//
+// The counts are kept in a small table in each P, indexed by a hash of
+// the PC of the call site. Sites that collide evict each other, and a
+// goroutine that moves to another P starts counting again; both only
+// delay a report. All calls to reflect.Select count as a single site.
+
+import "runtime/internal/atomic"
+
+const (
+	selectSpinSlots      = 16     // size of each P's table
+	selectSpinCount      = 100000 // defaults in a row that make a spin
+	selectSpinWindow     = 100e6  // within this many nanoseconds
+	maxSelectSpinReports = 64
+)
+
+// A selectSpinSlot counts the defaults taken in a row at one site.
+type selectSpinSlot struct {
+	pc    uintptr
+	n     uint32
+	start int64 // nanotime of the first default counted in n
+}
+
+// selectSpinReported holds the PCs of the sites reported so far.
+// Entries are claimed with atomic compare-and-swap and never cleared.
+var selectSpinReported [maxSelectSpinReports]uintptr
+
+// selectSpin records that the nonblocking select called at pc took its
+// default case, if dflt is set, or another case.
+func selectSpin(pc uintptr, dflt bool) {
+	mp := acquirem()
+	s := &mp.p.ptr().selectSpin[(pc^pc>>5)%selectSpinSlots]
+	if !dflt {
+		if s.pc == pc {
+			s.n = 0
+		}
+		releasem(mp)
+		return
+	}
+	if s.pc != pc || s.n == 0 {
+		s.pc, s.n, s.start = pc, 1, nanotime()
+		releasem(mp)
+		return
+	}
+	s.n++
+	if s.n < selectSpinCount {
+		releasem(mp)
+		return
+	}
+	elapsed := nanotime() - s.start
+	s.n = 0
+	releasem(mp)
+	if elapsed > selectSpinWindow || !selectSpinReport(pc) {
+		return
+	}
+
+	gp := getg()
+	print("runtime: nonblocking select took its default case ", selectSpinCount, " times in a row in ", elapsed/1e6, "ms at ")
+	printchanpc(pc)
+	print("goroutine ", gp.goid, " [running]:\n")
+	callerpc, sp := getcallerpc(), getcallersp()
+	systemstack(func() {
+		traceback(callerpc, sp, 0, gp)
+	})
+	print("\n")
+}
+
+// selectSpinReport reports whether the site at pc should be reported,
+// that is, whether it has not been reported before and the limit on
+// reports has not been reached.
+func selectSpinReport(pc uintptr) bool {
+	for i := range selectSpinReported {
+		p := &selectSpinReported[i]
+		v := atomic.Loaduintptr(p)
+		if v == 0 && atomic.Casuintptr(p, 0, pc) {
+			return true
+		}
+		if atomic.Loaduintptr(p) == pc {
+			return false
+		}
+	}
+	return false
+}
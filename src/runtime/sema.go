@@ -230,6 +230,18 @@ func cansemacquire(addr *uint32) bool {
 	}
 }
 
+// waittail returns the last sudog in the list of sudogs waiting on the
+// same address as s, which is at the top of that list in the treap.
+// It is kept in s.c, which semaphores don't otherwise use.
+func (s *sudog) waittail() *sudog {
+	return (*sudog)(unsafe.Pointer(s.c))
+}
+
+// setWaittail sets the last sudog in the list headed by s to t.
+func (s *sudog) setWaittail(t *sudog) {
+	s.c = (*hchan)(unsafe.Pointer(t))
+}
+
 // queue adds s to the blocked goroutines in semaRoot.
 func (root *semaRoot) queue(addr *uint32, s *sudog, lifo bool) {
 	s.g = getg()
@@ -258,22 +270,22 @@ func (root *semaRoot) queue(addr *uint32, s *sudog, lifo bool) {
 				}
 				// Add t first in s's wait list.
 				s.waitlink = t
-				s.waittail = t.waittail
-				if s.waittail == nil {
-					s.waittail = t
+				s.setWaittail(t.waittail())
+				if s.waittail() == nil {
+					s.setWaittail(t)
 				}
 				t.parent = nil
 				t.prev = nil
 				t.next = nil
-				t.waittail = nil
+				t.setWaittail(nil)
 			} else {
 				// Add s to end of t's wait list.
-				if t.waittail == nil {
+				if t.waittail() == nil {
 					t.waitlink = s
 				} else {
-					t.waittail.waitlink = s
+					t.waittail().waitlink = s
 				}
-				t.waittail = s
+				t.setWaittail(s)
 				s.waitlink = nil
 			}
 			return
@@ -352,13 +364,13 @@ Found:
 			t.next.parent = t
 		}
 		if t.waitlink != nil {
-			t.waittail = s.waittail
+			t.setWaittail(s.waittail())
 		} else {
-			t.waittail = nil
+			t.setWaittail(nil)
 		}
 		t.acquiretime = now
 		s.waitlink = nil
-		s.waittail = nil
+		s.setWaittail(nil)
 	} else {
 		// Rotate s down to be leaf of tree for removal, respecting priorities.
 		for s.next != nil || s.prev != nil {
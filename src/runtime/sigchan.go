@@ -0,0 +1,244 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !plan9
+// +build !plan9
+
+package runtime
+
+// Signal sets, for os/signal.NewSet.
+//
+// A signal set is a channel with zero-sized elements and a mask of
+// signals. When one of the signals arrives, the runtime itself does a
+// non-blocking send on the channel, so that a goroutine can wait for
+// the signal in a select, alongside other channels, without the
+// os/signal goroutine relaying it (see sigqueue.go).
+//
+// The send cannot be done by sigsend: it runs in the signal handler,
+// which cannot take the channel's lock. Instead sigsend records the
+// signal in sigChans.pending and wakes the network poller with
+// netpollBreak, which is async-signal-safe. The scheduler calls
+// sigChanDeliver after each netpoll, and it does the sends there with
+// chansendready, as the poller does for I/O readiness notifications.
+// Each registered set counts as a netpoll waiter, so that an idle M
+// blocks in netpoll rather than sleeping while a set is registered.
+//
+// Which signals arrived is kept in each set's recv mask, which
+// os/signal drains with signal_setRecv. Signals wanted only by sets
+// are not queued in sig.mask: nothing may be receiving from it.
+
+import (
+	"runtime/internal/atomic"
+	"runtime/internal/sys"
+	_ "unsafe" // for go:linkname
+)
+
+// sigChans holds the registered signal sets.
+//
+// The wanted, pending and any fields are accessed atomically: they are
+// read and written by sigsend. The rest are protected by lock. Sets are
+// registered and updated by one goroutine at a time, holding the
+// handlers Mutex in os/signal.
+var sigChans struct {
+	lock    mutex
+	wanted  [(_NSIG + 31) / 32]uint32 // signals in the mask of some set
+	pending [(_NSIG + 31) / 32]uint32 // caught but not yet delivered to the sets
+	any     uint32                    // nonzero if some pending bit may be set
+	sets    *sigChanSet
+	inited  bool
+}
+
+// A sigChanSet is a signal set registered by os/signal.
+type sigChanSet struct {
+	c    *hchan
+	mask [(_NSIG + 31) / 32]uint32
+	recv [(_NSIG + 31) / 32]uint32 // delivered but not yet received; accessed atomically
+	next *sigChanSet
+
+	stopping bool // being unregistered; wants no more signals
+}
+
+// sigChanSend records the signal s for delivery to the signal sets
+// that want it, and reports whether there are any.
+// It runs from the signal handler, so it's limited in what it can do.
+func sigChanSend(s uint32) bool {
+	bit := uint32(1) << (s & 31)
+	if atomic.Load(&sigChans.wanted[s/32])&bit == 0 {
+		return false
+	}
+	atomic.Or(&sigChans.pending[s/32], bit)
+	atomic.Store(&sigChans.any, 1)
+	netpollBreak()
+	return true
+}
+
+// sigChanWanted reports whether a signal set wants the signal s.
+func sigChanWanted(s uint32) bool {
+	return atomic.Load(&sigChans.wanted[s/32])&(1<<(s&31)) != 0
+}
+
+// sigChanDeliver sends on the channels of the signal sets that want
+// the signals caught since it last ran. Receivers woken by the sends
+// are added to toRun.
+//
+// This may run without a P, from findrunnable and sysmon after they
+// poll the network, so write barriers are not allowed.
+//go:nowritebarrierrec
+func sigChanDeliver(toRun *gList) {
+	if atomic.Load(&sigChans.any) == 0 {
+		return
+	}
+	atomic.Store(&sigChans.any, 0)
+	var pending [len(sigChans.pending)]uint32
+	for i := range pending {
+		pending[i] = atomic.Xchg(&sigChans.pending[i], 0)
+	}
+	lock(&sigChans.lock)
+	for s := sigChans.sets; s != nil; s = s.next {
+		hit := false
+		for i := range pending {
+			if bits := pending[i] & s.mask[i]; bits != 0 {
+				atomic.Or(&s.recv[i], bits)
+				hit = true
+			}
+		}
+		if hit {
+			chansendready(s.c, toRun)
+		}
+	}
+	unlock(&sigChans.lock)
+}
+
+// sigChanUpdateWanted recomputes sigChans.wanted from the masks of the
+// sets that are not stopping. sigChans.lock must be held.
+func sigChanUpdateWanted() {
+	var wanted [len(sigChans.wanted)]uint32
+	for s := sigChans.sets; s != nil; s = s.next {
+		if s.stopping {
+			continue
+		}
+		for i := range wanted {
+			wanted[i] |= s.mask[i]
+		}
+	}
+	for i := range wanted {
+		atomic.Store(&sigChans.wanted[i], wanted[i])
+	}
+}
+
+// sigChanDisable resets the handler of the signal n if neither a signal
+// set nor os/signal's channels want it.
+func sigChanDisable(n uint32) {
+	if !sigChanWanted(n) && atomic.Load(&sig.wanted[n/32])&(1<<(n&31)) == 0 {
+		sigdisable(n)
+	}
+}
+
+// signal_setNew registers a signal set that sends on c, which must have
+// a zero-sized element type, and wants no signals yet.
+// Must only be called from a single goroutine at a time.
+//go:linkname signal_setNew os/signal.signal_setNew
+func signal_setNew(c *hchan) *sigChanSet {
+	if c.elemsize != 0 {
+		throw("runtime: signal set channel has non-zero-sized elements")
+	}
+	if !sigChans.inited {
+		lockInit(&sigChans.lock, lockRankSigChans)
+		sigChans.inited = true
+	}
+	netpollGenericInit()
+	s := &sigChanSet{c: c}
+	lock(&sigChans.lock)
+	s.next = sigChans.sets
+	sigChans.sets = s
+	unlock(&sigChans.lock)
+	atomic.Xadd(&netpollWaiters, 1)
+	return s
+}
+
+// signal_setEnable adds the signal n to the set s.
+// Must only be called from a single goroutine at a time.
+//go:linkname signal_setEnable os/signal.signal_setEnable
+func signal_setEnable(s *sigChanSet, n uint32) {
+	if n >= uint32(len(sigChans.wanted)*32) {
+		return
+	}
+	lock(&sigChans.lock)
+	s.mask[n/32] |= 1 << (n & 31)
+	sigChanUpdateWanted()
+	unlock(&sigChans.lock)
+
+	i := sig.ignored[n/32]
+	i &^= 1 << (n & 31)
+	atomic.Store(&sig.ignored[n/32], i)
+
+	sigenable(n)
+}
+
+// signal_setDisable removes the signal n from the set s.
+// Must only be called from a single goroutine at a time.
+//go:linkname signal_setDisable os/signal.signal_setDisable
+func signal_setDisable(s *sigChanSet, n uint32) {
+	if n >= uint32(len(sigChans.wanted)*32) {
+		return
+	}
+	lock(&sigChans.lock)
+	s.mask[n/32] &^= 1 << (n & 31)
+	sigChanUpdateWanted()
+	unlock(&sigChans.lock)
+	sigChanDisable(n)
+}
+
+// signal_setStop unregisters the set s. Once it returns, the runtime
+// does not send on s's channel again.
+// Must only be called from a single goroutine at a time.
+//go:linkname signal_setStop os/signal.signal_setStop
+func signal_setStop(s *sigChanSet) {
+	// Stop wanting the signals before delivering those already
+	// caught: a signal that sigsend finds unwanted gets the default
+	// action, so none is lost in between.
+	lock(&sigChans.lock)
+	s.stopping = true
+	sigChanUpdateWanted()
+	unlock(&sigChans.lock)
+	for i := range s.mask {
+		for w := s.mask[i]; w != 0; w &= w - 1 {
+			sigChanDisable(uint32(i*32 + sys.Ctz32(w)))
+		}
+	}
+
+	var toRun gList
+	sigChanDeliver(&toRun)
+	lock(&sigChans.lock)
+	for p := &sigChans.sets; *p != nil; p = &(*p).next {
+		if *p == s {
+			*p = s.next
+			break
+		}
+	}
+	unlock(&sigChans.lock)
+	for !toRun.empty() {
+		goready(toRun.pop(), 0)
+	}
+	atomic.Xadd(&netpollWaiters, -1)
+}
+
+// signal_setRecv removes the lowest-numbered signal from the signals
+// delivered to s and returns it. It returns false if there are none.
+//go:linkname signal_setRecv os/signal.signal_setRecv
+func signal_setRecv(s *sigChanSet) (uint32, bool) {
+	for i := range s.recv {
+		for {
+			w := atomic.Load(&s.recv[i])
+			if w == 0 {
+				break
+			}
+			b := uint32(sys.Ctz32(w))
+			if atomic.Cas(&s.recv[i], w, w&^(1<<b)) {
+				return uint32(i)*32 + b, true
+			}
+		}
+	}
+	return 0, false
+}
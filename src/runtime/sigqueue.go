@@ -79,9 +79,13 @@ func sigsend(s uint32) bool {
 	atomic.Xadd(&sig.delivering, 1)
 	// We are running in the signal handler; defer is not available.
 
+	// Signal sets (see sigchan.go) get the signal whether or not
+	// os/signal's channels want it too.
+	sets := sigChanSend(s)
+
 	if w := atomic.Load(&sig.wanted[s/32]); w&bit == 0 {
 		atomic.Xadd(&sig.delivering, -1)
-		return false
+		return sets
 	}
 
 	// Add signal to outgoing queue.
@@ -253,7 +257,9 @@ func signal_disable(s uint32) {
 	if s >= uint32(len(sig.wanted)*32) {
 		return
 	}
-	sigdisable(s)
+	if !sigChanWanted(s) {
+		sigdisable(s)
+	}
 
 	w := sig.wanted[s/32]
 	w &^= 1 << (s & 31)
@@ -161,3 +161,9 @@ func signal_ignore(s uint32) {
 func signal_ignored(s uint32) bool {
 	return false
 }
+
+// sigChanDeliver delivers signals to os/signal's signal sets. On Plan 9
+// the notes are relayed to sets by the os/signal goroutine instead, so
+// there is nothing to do.
+func sigChanDeliver(toRun *gList) {
+}
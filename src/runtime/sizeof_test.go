@@ -5,6 +5,7 @@
 package runtime_test
 
 import (
+	"internal/goexperiment"
 	"reflect"
 	"runtime"
 	"testing"
@@ -21,8 +22,8 @@ func TestSizeof(t *testing.T) {
 		_32bit uintptr     // size on 32bit platforms
 		_64bit uintptr     // size on 64bit platforms
 	}{
-		{runtime.G{}, 236, 392},   // g, but exported for testing
-		{runtime.Sudog{}, 56, 88}, // sudog, but exported for testing
+		{runtime.G{}, 272, 456},   // g, but exported for testing
+		{runtime.Sudog{}, 52, 80}, // sudog, but exported for testing
 	}
 
 	for _, tt := range tests {
@@ -35,4 +36,16 @@ func TestSizeof(t *testing.T) {
 			t.Errorf("unsafe.Sizeof(%T) = %d, want %d", tt.val, got, want)
 		}
 	}
+
+	// hchan, but exported for testing. It holds a mutex, which static
+	// lock ranking makes larger.
+	if !goexperiment.StaticLockRanking {
+		want := uintptr(64)
+		if _64bit {
+			want = 112
+		}
+		if got := unsafe.Sizeof(runtime.Hchan{}); got != want {
+			t.Errorf("unsafe.Sizeof(runtime.Hchan{}) = %d, want %d", got, want)
+		}
+	}
 }
@@ -799,10 +799,18 @@ func syncadjustsudogs(gp *g, used uintptr, adjinfo *adjustinfo) uintptr {
 		return 0
 	}
 
-	// Lock channels to prevent concurrent send/receive.
+	// Lock channels to prevent concurrent send/receive. gp.waiting
+	// lists the channels gp is parked on in the order gp locked them,
+	// which for a select is sellock's address order, with the cases
+	// on the same channel next to each other. So these are exactly
+	// the locks gp held while it enqueued its sudogs, taken once each
+	// and in the same order as any other goroutine takes them.
 	var lastc *hchan
 	for sg := gp.waiting; sg != nil; sg = sg.waitlink {
 		if sg.c != lastc {
+			if uintptr(unsafe.Pointer(sg.c)) < uintptr(unsafe.Pointer(lastc)) {
+				throw("sudog channels out of lock order")
+			}
 			// There is a ranking cycle here between gscan bit and
 			// hchan locks. Normally, we only allow acquiring hchan
 			// locks and then getting a gscan bit. In this case, we
@@ -831,6 +839,10 @@ func syncadjustsudogs(gp *g, used uintptr, adjinfo *adjustinfo) uintptr {
 		memmove(unsafe.Pointer(newBot), unsafe.Pointer(oldBot), sgsize)
 	}
 
+	if debug.checkstackchans != 0 {
+		checkstacksudogs(gp, adjinfo.old, stack{adjinfo.old.lo + adjinfo.delta, adjinfo.old.hi + adjinfo.delta})
+	}
+
 	// Unlock channels.
 	lastc = nil
 	for sg := gp.waiting; sg != nil; sg = sg.waitlink {
@@ -42,6 +42,7 @@
 #define SYS_sched_getaffinity	204
 #define SYS_epoll_create	213
 #define SYS_clock_gettime	228
+#define SYS_mbind		237
 #define SYS_exit_group		231
 #define SYS_epoll_ctl		233
 #define SYS_tgkill		234
@@ -50,6 +51,7 @@
 #define SYS_epoll_pwait		281
 #define SYS_epoll_create1	291
 #define SYS_pipe2		293
+#define SYS_getcpu		309
 
 TEXT runtime·exit(SB),NOSPLIT,$0-4
 	MOVL	code+0(FP), DI
@@ -506,6 +508,30 @@ TEXT runtime·madvise(SB),NOSPLIT,$0
 	MOVL	AX, ret+24(FP)
 	RET
 
+// int32 mbind(void *addr, uintptr len, int32 mode, uint64 *nodemask,
+//	uintptr maxnode, uint32 flags);
+TEXT runtime·mbind(SB),NOSPLIT,$0-52
+	MOVQ	addr+0(FP), DI
+	MOVQ	n+8(FP), SI
+	MOVL	mode+16(FP), DX
+	MOVQ	nodemask+24(FP), R10
+	MOVQ	maxnode+32(FP), R8
+	MOVL	flags+40(FP), R9
+	MOVL	$SYS_mbind, AX
+	SYSCALL
+	MOVL	AX, ret+48(FP)
+	RET
+
+// int32 getcpu(uint32 *cpu, uint32 *node);
+TEXT runtime·getcpu(SB),NOSPLIT,$0-20
+	MOVQ	cpu+0(FP), DI
+	MOVQ	node+8(FP), SI
+	MOVQ	$0, DX
+	MOVL	$SYS_getcpu, AX
+	SYSCALL
+	MOVL	AX, ret+16(FP)
+	RET
+
 // int64 futex(int32 *uaddr, int32 op, int32 val,
 //	struct timespec *timeout, int32 *uaddr2, int32 val2);
 TEXT runtime·futex(SB),NOSPLIT,$0
@@ -33,6 +33,8 @@
 #define SYS_rt_sigprocmask	135
 #define SYS_sigaltstack		132
 #define SYS_madvise		233
+#define SYS_mbind		235
+#define SYS_getcpu		168
 #define SYS_mincore		232
 #define SYS_getpid		172
 #define SYS_gettid		178
@@ -554,6 +556,30 @@ TEXT runtime·madvise(SB),NOSPLIT|NOFRAME,$0
 	MOVW	R0, ret+24(FP)
 	RET
 
+// int32 mbind(void *addr, uintptr len, int32 mode, uint64 *nodemask,
+//	uintptr maxnode, uint32 flags);
+TEXT runtime·mbind(SB),NOSPLIT|NOFRAME,$0-52
+	MOVD	addr+0(FP), R0
+	MOVD	n+8(FP), R1
+	MOVW	mode+16(FP), R2
+	MOVD	nodemask+24(FP), R3
+	MOVD	maxnode+32(FP), R4
+	MOVWU	flags+40(FP), R5
+	MOVD	$SYS_mbind, R8
+	SVC
+	MOVW	R0, ret+48(FP)
+	RET
+
+// int32 getcpu(uint32 *cpu, uint32 *node);
+TEXT runtime·getcpu(SB),NOSPLIT|NOFRAME,$0-20
+	MOVD	cpu+0(FP), R0
+	MOVD	node+8(FP), R1
+	MOVD	$0, R2
+	MOVD	$SYS_getcpu, R8
+	SVC
+	MOVW	R0, ret+16(FP)
+	RET
+
 // int64 futex(int32 *uaddr, int32 op, int32 val,
 //	struct timespec *timeout, int32 *uaddr2, int32 val2);
 TEXT runtime·futex(SB),NOSPLIT|NOFRAME,$0
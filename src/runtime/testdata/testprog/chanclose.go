@@ -0,0 +1,68 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+)
+
+func init() {
+	register("SendOnClosedChannel", SendOnClosedChannel)
+}
+
+//go:noinline
+func closeIntChan(c chan int) {
+	close(c)
+}
+
+//go:noinline
+func closeStringChan(c chan string) {
+	close(c)
+}
+
+// describeSendOnClosed runs send, which panics for a send on a closed
+// channel, and describes the panic value.
+func describeSendOnClosed(name string, send func()) (s string) {
+	start := time.Now()
+	defer func() {
+		e := recover()
+		s = fmt.Sprintf("%s: %T %v", name, e, e)
+		if e, ok := e.(*runtime.ClosedChannelError); ok {
+			ct := time.Unix(0, e.CloseTime())
+			s += fmt.Sprintf(" elem=%s closedBy=%d closer=%s recent=%v",
+				e.ElemType(), e.ClosedBy(), e.CloseFrame().Function,
+				ct.After(start.Add(-time.Minute)) && ct.Before(time.Now().Add(time.Second)))
+		}
+		s += "\n"
+	}()
+	send()
+	return "no panic\n"
+}
+
+func SendOnClosedChannel() {
+	// A send that finds the channel closed.
+	c := make(chan int)
+	closeIntChan(c)
+	fmt.Print(describeSendOnClosed("closed", func() { c <- 1 }))
+
+	// A send woken by the close.
+	d := make(chan string)
+	res := make(chan string)
+	go func() {
+		res <- describeSendOnClosed("woken", func() { d <- "x" })
+	}()
+	buf := make([]byte, 1<<16)
+	for !strings.Contains(string(buf[:runtime.Stack(buf, true)]), "[chan send") {
+		time.Sleep(time.Millisecond)
+	}
+	closeStringChan(d)
+	fmt.Print(<-res)
+
+	// Not recovering prints the message as it always has.
+	d <- "y"
+}
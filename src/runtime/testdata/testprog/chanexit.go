@@ -0,0 +1,49 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+func init() {
+	register("ChanExitReport", ChanExitReport)
+	register("ChanExitReportOSExit", ChanExitReportOSExit)
+}
+
+//go:noinline
+func chanExitWorker(c chan int) {
+	for range c {
+	}
+}
+
+//go:noinline
+func chanExitSender(c chan string) {
+	c <- "lost"
+}
+
+// chanExitAbandon starts goroutines that block on channels nobody
+// will use again.
+func chanExitAbandon() {
+	for i := 0; i < 3; i++ {
+		go chanExitWorker(make(chan int))
+	}
+	c := make(chan string, 1)
+	c <- "queued"
+	go chanExitSender(c)
+	time.Sleep(100 * time.Millisecond)
+	fmt.Println("done")
+}
+
+func ChanExitReport() {
+	chanExitAbandon()
+}
+
+func ChanExitReportOSExit() {
+	chanExitAbandon()
+	os.Exit(0)
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"runtime"
 	"runtime/debug"
+	"sync"
 	"time"
 )
 
@@ -19,6 +20,7 @@ func init() {
 	register("LockedDeadlock", LockedDeadlock)
 	register("LockedDeadlock2", LockedDeadlock2)
 	register("GoexitDeadlock", GoexitDeadlock)
+	register("DeadlockSummary", DeadlockSummary)
 	register("StackOverflow", StackOverflow)
 	register("ThreadExhaustion", ThreadExhaustion)
 	register("RecursivePanic", RecursivePanic)
@@ -76,6 +78,38 @@ func GoexitDeadlock() {
 	runtime.Goexit()
 }
 
+func DeadlockSummary() {
+	send := make(chan int)
+	recv := make(chan int)
+	for i := 0; i < 3; i++ {
+		go func() { send <- 1 }()
+	}
+	go func() { <-recv }()
+	go func() {
+		sel := make(chan int)
+		select {
+		case <-sel:
+		case sel <- 1:
+		}
+	}()
+	go func() { select {} }()
+	go func() {
+		var nc chan int
+		nc <- 1
+	}()
+	go func() {
+		var nc chan int
+		<-nc
+	}()
+	var mu sync.Mutex
+	cond := sync.NewCond(&mu)
+	go func() {
+		mu.Lock()
+		cond.Wait()
+	}()
+	<-recv
+}
+
 func StackOverflow() {
 	var f func() byte
 	f = func() byte {
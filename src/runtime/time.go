@@ -36,6 +36,20 @@ type timer struct {
 
 	// The status field holds one of the values below.
 	status uint32
+
+	// Number of calls to f in progress for a channel timer.
+	sending uint32
+
+	// Whether f sends on the buffered channel in arg, as for
+	// time.NewTimer and time.NewTicker. Resetting such a timer
+	// discards any value it has already sent (see timerchandrain).
+	isChan bool
+
+	// The virtual time group the timer belongs to, if any. Such
+	// timers are kept on the group's heap rather than a P's, and
+	// the package time APIs below hand them to the group.
+	// See timegroup.go.
+	group *timeGroup
 }
 
 // Code outside this file has to be careful in using a timer value.
@@ -169,7 +183,7 @@ const verifyTimers = false
 // Package time APIs.
 // Godoc uses the comments in package time, not these.
 
-// time.now is implemented in assembly.
+// time.now is implemented by timeNow in timegroup.go.
 
 // timeSleep puts the current goroutine to sleep for at least ns nanoseconds.
 //go:linkname timeSleep time.Sleep
@@ -186,7 +200,12 @@ func timeSleep(ns int64) {
 	}
 	t.f = goroutineReady
 	t.arg = gp
-	t.nextwhen = nanotime() + ns
+	t.group = gp.timeGroup
+	if t.group != nil {
+		t.nextwhen = t.group.nanotime() + ns
+	} else {
+		t.nextwhen = nanotime() + ns
+	}
 	if t.nextwhen < 0 { // check for overflow.
 		t.nextwhen = maxWhen
 	}
@@ -199,6 +218,10 @@ func timeSleep(ns int64) {
 // timer function, goroutineReady, before the goroutine has been parked.
 func resetForSleep(gp *g, ut unsafe.Pointer) bool {
 	t := (*timer)(ut)
+	if t.group != nil {
+		t.group.modTimer(t, t.nextwhen, t.period, t.f, t.arg, t.seq)
+		return true
+	}
 	resettimer(t, t.nextwhen)
 	return true
 }
@@ -209,6 +232,10 @@ func startTimer(t *timer) {
 	if raceenabled {
 		racerelease(unsafe.Pointer(t))
 	}
+	if grp := getg().timeGroup; grp != nil {
+		grp.addTimer(t)
+		return
+	}
 	addtimer(t)
 }
 
@@ -216,6 +243,9 @@ func startTimer(t *timer) {
 // It reports whether t was stopped before being run.
 //go:linkname stopTimer time.stopTimer
 func stopTimer(t *timer) bool {
+	if t.group != nil {
+		return t.group.delTimer(t)
+	}
 	return deltimer(t)
 }
 
@@ -226,12 +256,19 @@ func resetTimer(t *timer, when int64) bool {
 	if raceenabled {
 		racerelease(unsafe.Pointer(t))
 	}
+	if t.group != nil {
+		return t.group.modTimer(t, when, t.period, t.f, t.arg, t.seq)
+	}
 	return resettimer(t, when)
 }
 
 // modTimer modifies an existing timer.
 //go:linkname modTimer time.modTimer
 func modTimer(t *timer, when, period int64, f func(interface{}, uintptr), arg interface{}, seq uintptr) {
+	if t.group != nil {
+		t.group.modTimer(t, when, period, f, arg, seq)
+		return
+	}
 	modtimer(t, when, period, f, arg, seq)
 }
 
@@ -488,6 +525,10 @@ loop:
 		}
 	}
 
+	if t.isChan {
+		timerchandrain(t)
+	}
+
 	t.period = period
 	t.f = f
 	t.arg = arg
@@ -538,6 +579,19 @@ loop:
 	return pending
 }
 
+// timerchandrain discards any values a channel timer has sent but that
+// have not been received yet, including those from sends that were
+// already in progress. After this, a receive on the timer's channel
+// can only observe an expiration that happens after the call.
+// The caller must hold t in timerModifying, so that no new send can
+// start until the timer is rescheduled.
+func timerchandrain(t *timer) {
+	for atomic.Load(&t.sending) != 0 {
+		osyield()
+	}
+	chandrain((*hchan)(efaceOf(&t.arg).data))
+}
+
 // resettimer resets the time when a timer should fire.
 // If used for an inactive timer, the timer will become active.
 // This should be called instead of addtimer if the timer value has been,
@@ -840,6 +894,13 @@ func runOneTimer(pp *p, t *timer, now int64) {
 	arg := t.arg
 	seq := t.seq
 
+	// Publish the send before leaving timerRunning, so that a
+	// concurrent modtimer waits for it in timerchandrain.
+	isChan := t.isChan
+	if isChan {
+		atomic.Xadd(&t.sending, 1)
+	}
+
 	if t.period > 0 {
 		// Leave in heap but adjust next time to fire.
 		delta := t.when - now
@@ -873,6 +934,10 @@ func runOneTimer(pp *p, t *timer, now int64) {
 
 	f(arg, seq)
 
+	if isChan {
+		atomic.Xadd(&t.sending, -1)
+	}
+
 	lock(&pp.timersLock)
 
 	if raceenabled {
@@ -37,7 +37,6 @@ func nanotime() int64 {
 	return faketime
 }
 
-//go:linkname time_now time.now
 func time_now() (sec int64, nsec int32, mono int64) {
 	return faketime / 1e9, int32(faketime % 1e9), faketime
 }
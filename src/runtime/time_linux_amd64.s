@@ -11,8 +11,8 @@
 
 #define SYS_clock_gettime	228
 
-// func time.now() (sec int64, nsec int32, mono int64)
-TEXT time·now(SB),NOSPLIT,$16-24
+// func time_now() (sec int64, nsec int32, mono int64)
+TEXT ·time_now(SB),NOSPLIT,$16-24
 	MOVQ	SP, R12 // Save old SP; R12 unchanged by C code.
 
 #ifdef GOEXPERIMENT_regabig
@@ -9,7 +9,7 @@
 #include "textflag.h"
 #include "time_windows.h"
 
-TEXT time·now(SB),NOSPLIT,$0-20
+TEXT ·time_now(SB),NOSPLIT,$0-20
 	CMPB	runtime·useQPCTime(SB), $0
 	JNE	useQPC
 loop:
@@ -26,8 +26,8 @@ loop:
 	IMULL	$100, DI
 	ADDL	DI, DX
 	// w*100 = DX:AX
-	MOVL	AX, mono+12(FP)
-	MOVL	DX, mono+16(FP)
+	MOVL	AX, mono_lo+12(FP)
+	MOVL	DX, mono_hi+16(FP)
 
 wall:
 	MOVL	(_SYSTEM_TIME+time_hi1), CX
@@ -77,8 +77,8 @@ wall:
 	MULL	DI
 	ADDL	BX, AX
 	ADCL	$0, DX
-	MOVL	AX, sec+0(FP)
-	MOVL	DX, sec+4(FP)
+	MOVL	AX, sec_lo+0(FP)
+	MOVL	DX, sec_hi+4(FP)
 	RET
 useQPC:
 	JMP	runtime·nowQPC(SB)
@@ -9,7 +9,7 @@
 #include "textflag.h"
 #include "time_windows.h"
 
-TEXT time·now(SB),NOSPLIT|NOFRAME,$0-20
+TEXT ·time_now(SB),NOSPLIT|NOFRAME,$0-20
 	MOVW    $0, R0
 	MOVB    runtime·useQPCTime(SB), R0
 	CMP	$0, R0
@@ -30,8 +30,8 @@ loop:
 	MULA	R1, R2, R4, R4
 
 	// wintime*100 = R4:R3
-	MOVW	R3, mono+12(FP)
-	MOVW	R4, mono+16(FP)
+	MOVW	R3, mono_lo+12(FP)
+	MOVW	R4, mono_hi+16(FP)
 
 	MOVW	$_SYSTEM_TIME, R3
 wall:
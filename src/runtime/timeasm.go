@@ -2,7 +2,7 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// Declarations for operating systems implementing time.now directly in assembly.
+// Declarations for operating systems implementing time_now directly in assembly.
 
 //go:build !faketime && (windows || (linux && amd64))
 // +build !faketime
@@ -10,7 +10,4 @@
 
 package runtime
 
-import _ "unsafe"
-
-//go:linkname time_now time.now
 func time_now() (sec int64, nsec int32, mono int64)
@@ -0,0 +1,312 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Virtual time groups, used by testing.T.VirtualTime.
+//
+// A time group is a set of goroutines that share a virtual clock.
+// Goroutines started by a member of a group join the group. For
+// members, time.Now, time.Sleep, and the timers of package time use
+// the group's clock and the group's own timer heap instead of the
+// real clock and the P timer heaps.
+//
+// The goroutine that creates a group, the driver, does not count as a
+// member, but it joins the group so that the goroutines started by
+// AfterFunc timers it fires join too. It waits until every member is
+// durably blocked: parked on a channel operation, a select, a sync
+// primitive, or time.Sleep. Only another member can wake such a
+// goroutine, so the group can make no progress until the clock moves.
+// The driver then advances the clock to the earliest timer in the
+// group and runs it. The group ends when all its members have exited.
+//
+// Members must not communicate with goroutines outside the group;
+// the driver cannot tell a goroutine that an outside goroutine is
+// about to wake from one that will never be woken.
+
+import (
+	"runtime/internal/atomic"
+	"unsafe"
+)
+
+type timeGroup struct {
+	// now is the group's nanotime. It is only advanced by the driver,
+	// and it starts at the real nanotime when the group is created.
+	now   uint64 // atomic
+	start int64  // initial value of now
+
+	// active is the number of members that are not durably
+	// blocked, and total is the number of members.
+	active uint32 // atomic
+	total  uint32 // atomic
+
+	driver       *g
+	driverParked uint32 // atomic; driver is parked waiting for active to reach 0
+
+	lock   mutex
+	timers []*timer // heap of the group's pending timers, ordered by when
+}
+
+// timeGroupEpoch is the wall clock time, in nanoseconds since the
+// Unix epoch, at which virtual time starts: midnight UTC on
+// January 1, 2000.
+const timeGroupEpoch = 946684800e9
+
+//go:linkname testing_runTimeGroup testing.runtime_runTimeGroup
+func testing_runTimeGroup(f func()) (deadlock bool) {
+	gp := getg()
+	if gp.timeGroup != nil {
+		panic(plainError("testing: VirtualTime called inside a virtual time group"))
+	}
+	grp := &timeGroup{driver: gp}
+	grp.start = nanotime()
+	grp.now = uint64(grp.start)
+	lockInit(&grp.lock, lockRankTimeGroup)
+
+	gp.timeGroup = grp
+	go f()
+	for {
+		gopark(timeGroupDriverPark, unsafe.Pointer(grp), waitReasonTimeGroup, traceEvGoBlock, 1)
+		if atomic.Load(&grp.active) != 0 {
+			// A member was woken since the driver was.
+			continue
+		}
+		if atomic.Load(&grp.total) == 0 {
+			break
+		}
+		if !grp.runTimer() {
+			deadlock = true
+			break
+		}
+	}
+	gp.timeGroup = nil
+	if raceenabled {
+		// Like WaitGroup.Wait, returning happens after the members exit.
+		raceacquire(unsafe.Pointer(grp))
+	}
+	return deadlock
+}
+
+// timeGroupDriverPark is the gopark unlock function of the driver. It
+// keeps the driver running if the group is already idle.
+func timeGroupDriverPark(gp *g, p unsafe.Pointer) bool {
+	grp := (*timeGroup)(p)
+	atomic.Store(&grp.driverParked, 1)
+	if atomic.Load(&grp.active) == 0 && atomic.Cas(&grp.driverParked, 1, 0) {
+		return false
+	}
+	return true
+}
+
+// wakeDriver readies the driver of grp if it is parked.
+func (grp *timeGroup) wakeDriver() {
+	if atomic.Cas(&grp.driverParked, 1, 0) {
+		ready(grp.driver, 0, true)
+	}
+}
+
+// timeGroupStart adds newg, which is being started by a member or
+// the driver of grp, to grp.
+func timeGroupStart(grp *timeGroup, newg *g) {
+	newg.timeGroup = grp
+	atomic.Xadd(&grp.total, 1)
+	atomic.Xadd(&grp.active, 1)
+}
+
+// timeGroupExit removes the exiting goroutine gp from its group.
+func timeGroupExit(gp *g) {
+	grp := gp.timeGroup
+	gp.timeGroup = nil
+	atomic.Xadd(&grp.total, -1)
+	if atomic.Xadd(&grp.active, -1) == 0 {
+		grp.wakeDriver()
+	}
+}
+
+// timeGroupPark is called by park_m for a member gp of a group before
+// the gopark unlock function runs, so that nothing can wake gp yet.
+// If gp blocks durably, timeGroupPark marks it blocked and reports
+// whether it was the last active member; the caller must then call
+// wakeDriver after the unlock function has run.
+func timeGroupPark(gp *g) bool {
+	grp := gp.timeGroup
+	if gp == grp.driver {
+		return false
+	}
+	switch gp.waitreason {
+	case waitReasonChanReceiveSync, waitReasonChanSendSync,
+		waitReasonChanReceiveEmpty, waitReasonChanSendFull,
+		waitReasonChanReceiveNilChan, waitReasonChanSendNilChan,
+		waitReasonSelect, waitReasonSelectNoCases,
+		waitReasonSemacquire, waitReasonSyncCondWait, waitReasonSleep:
+	default:
+		return false
+	}
+	gp.timeGroupBlocked = true
+	return atomic.Xadd(&grp.active, -1) == 0
+}
+
+// timeGroupReady marks the durably blocked member gp active again. It
+// is called before gp becomes runnable.
+func timeGroupReady(gp *g) {
+	gp.timeGroupBlocked = false
+	atomic.Xadd(&gp.timeGroup.active, 1)
+}
+
+// nanotime returns the group's clock.
+func (grp *timeGroup) nanotime() int64 {
+	return int64(atomic.Load64(&grp.now))
+}
+
+// timeNow returns the group's clock as time.now does.
+func (grp *timeGroup) timeNow() (sec int64, nsec int32, mono int64) {
+	mono = grp.nanotime()
+	wall := timeGroupEpoch + mono - grp.start
+	return wall / 1e9, int32(wall % 1e9), mono
+}
+
+//go:linkname time_runtimeNano time.runtimeNano
+func time_runtimeNano() int64 {
+	if grp := getg().timeGroup; grp != nil {
+		return grp.nanotime()
+	}
+	return nanotime()
+}
+
+//go:linkname timeNow time.now
+func timeNow() (sec int64, nsec int32, mono int64) {
+	if grp := getg().timeGroup; grp != nil {
+		return grp.timeNow()
+	}
+	return time_now()
+}
+
+// addTimer adds the new timer t to grp.
+func (grp *timeGroup) addTimer(t *timer) {
+	if t.when <= 0 {
+		throw("timer when must be positive")
+	}
+	if t.period < 0 {
+		throw("timer period must be non-negative")
+	}
+	if t.status != timerNoStatus {
+		throw("addtimer called with initialized timer")
+	}
+	t.group = grp
+	lock(&grp.lock)
+	grp.pushTimer(t)
+	unlock(&grp.lock)
+}
+
+// delTimer is deltimer for a timer of grp.
+//
+// Write barriers are allowed even though stopTimer is called without
+// them, from sysmon by wakeScavenger: the runtime's own timers never
+// belong to a group, only the time package's, which are stopped by
+// ordinary goroutines.
+//
+//go:yeswritebarrierrec
+func (grp *timeGroup) delTimer(t *timer) bool {
+	lock(&grp.lock)
+	pending := grp.removeTimer(t)
+	unlock(&grp.lock)
+	return pending
+}
+
+// modTimer is modtimer for a timer of grp.
+func (grp *timeGroup) modTimer(t *timer, when, period int64, f func(interface{}, uintptr), arg interface{}, seq uintptr) bool {
+	if when <= 0 {
+		throw("timer when must be positive")
+	}
+	if period < 0 {
+		throw("timer period must be non-negative")
+	}
+	lock(&grp.lock)
+	pending := grp.removeTimer(t)
+	if t.isChan {
+		timerchandrain(t)
+	}
+	t.when = when
+	t.period = period
+	t.f = f
+	t.arg = arg
+	t.seq = seq
+	grp.pushTimer(t)
+	unlock(&grp.lock)
+	return pending
+}
+
+// pushTimer adds t to the heap. grp.lock must be held.
+func (grp *timeGroup) pushTimer(t *timer) {
+	i := len(grp.timers)
+	grp.timers = append(grp.timers, t)
+	siftupTimer(grp.timers, i)
+	t.status = timerWaiting
+}
+
+// removeTimer removes t from the heap and reports whether it was
+// there. grp.lock must be held.
+func (grp *timeGroup) removeTimer(t *timer) bool {
+	if t.status != timerWaiting {
+		return false
+	}
+	for i, t1 := range grp.timers {
+		if t1 != t {
+			continue
+		}
+		last := len(grp.timers) - 1
+		if i != last {
+			grp.timers[i] = grp.timers[last]
+		}
+		grp.timers[last] = nil
+		grp.timers = grp.timers[:last]
+		if i != last {
+			siftupTimer(grp.timers, i)
+			siftdownTimer(grp.timers, i)
+		}
+		t.status = timerNoStatus
+		return true
+	}
+	throw("timeGroup.removeTimer: timer not in heap")
+	return false
+}
+
+// runTimer advances the clock of grp to its earliest timer and runs
+// the timer. It reports false if grp has no timers.
+func (grp *timeGroup) runTimer() bool {
+	lock(&grp.lock)
+	if len(grp.timers) == 0 {
+		unlock(&grp.lock)
+		return false
+	}
+	t := grp.timers[0]
+	if t.when > grp.nanotime() {
+		atomic.Store64(&grp.now, uint64(t.when))
+	}
+	f, arg, seq := t.f, t.arg, t.seq
+	isChan := t.isChan
+	if isChan {
+		// Publish the send, as runOneTimer does.
+		atomic.Xadd(&t.sending, 1)
+	}
+	if t.period > 0 {
+		t.when += t.period
+		if t.when < 0 { // check for overflow.
+			t.when = maxWhen
+		}
+		siftdownTimer(grp.timers, 0)
+	} else {
+		grp.removeTimer(t)
+	}
+	unlock(&grp.lock)
+
+	if raceenabled {
+		raceacquire(unsafe.Pointer(t))
+	}
+	f(arg, seq)
+	if isChan {
+		atomic.Xadd(&t.sending, -1)
+	}
+	return true
+}
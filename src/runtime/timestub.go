@@ -2,7 +2,7 @@
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// Declarations for operating systems implementing time.now
+// Declarations for operating systems implementing time_now
 // indirectly, in terms of walltime and nanotime assembly.
 
 //go:build !faketime && !windows && !(linux && amd64)
@@ -12,9 +12,6 @@
 
 package runtime
 
-import _ "unsafe" // for go:linkname
-
-//go:linkname time_now time.now
 func time_now() (sec int64, nsec int32, mono int64) {
 	sec, nsec = walltime()
 	return sec, nsec, nanotime()
@@ -41,10 +41,10 @@ const (
 	traceEvGoPreempt         = 18 // goroutine is preempted [timestamp, stack]
 	traceEvGoSleep           = 19 // goroutine calls Sleep [timestamp, stack]
 	traceEvGoBlock           = 20 // goroutine blocks [timestamp, stack]
-	traceEvGoUnblock         = 21 // goroutine is unblocked [timestamp, goroutine id, seq, stack]
-	traceEvGoBlockSend       = 22 // goroutine blocks on chan send [timestamp, stack]
-	traceEvGoBlockRecv       = 23 // goroutine blocks on chan recv [timestamp, stack]
-	traceEvGoBlockSelect     = 24 // goroutine blocks on select [timestamp, stack]
+	traceEvGoUnblock         = 21 // goroutine is unblocked [timestamp, goroutine id, seq, cause, stack]
+	traceEvGoBlockSend       = 22 // goroutine blocks on chan send [timestamp, chan id, task id, stack]
+	traceEvGoBlockRecv       = 23 // goroutine blocks on chan recv [timestamp, chan id, task id, stack]
+	traceEvGoBlockSelect     = 24 // goroutine blocks on select [timestamp, task id, stack]
 	traceEvGoBlockSync       = 25 // goroutine blocks on Mutex/RWMutex [timestamp, stack]
 	traceEvGoBlockCond       = 26 // goroutine blocks on Cond [timestamp, stack]
 	traceEvGoBlockNet        = 27 // goroutine blocks on network [timestamp, stack]
@@ -59,7 +59,7 @@ const (
 	traceEvFutileWakeup      = 36 // denotes that the previous wakeup of this goroutine was futile [timestamp]
 	traceEvString            = 37 // string dictionary entry [ID, length, string]
 	traceEvGoStartLocal      = 38 // goroutine starts running on the same P as the last event [timestamp, goroutine id]
-	traceEvGoUnblockLocal    = 39 // goroutine is unblocked on the same P as the last event [timestamp, goroutine id, stack]
+	traceEvGoUnblockLocal    = 39 // goroutine is unblocked on the same P as the last event [timestamp, goroutine id, cause, stack]
 	traceEvGoSysExitLocal    = 40 // syscall exit on the same P as the last event [timestamp, goroutine id, real timestamp]
 	traceEvGoStartLabel      = 41 // goroutine starts running with label [timestamp, goroutine id, seq, label string id]
 	traceEvGoBlockGC         = 42 // goroutine blocks on GC assist [timestamp, stack]
@@ -69,7 +69,12 @@ const (
 	traceEvUserTaskEnd       = 46 // end of a task [timestamp, internal task id, stack]
 	traceEvUserRegion        = 47 // trace.WithRegion [timestamp, internal task id, mode(0:start, 1:end), stack, name string]
 	traceEvUserLog           = 48 // trace.Log [timestamp, internal task id, key string id, stack, value string]
-	traceEvCount             = 49
+	traceEvChan              = 49 // channel description [timestamp, chan id, capacity, element type string id]
+	traceEvChanClose         = 50 // channel is closed [timestamp, chan id, waiters, stack]
+	traceEvSelect            = 51 // select statement completes [timestamp, case, number of sends, number of receives, stack]
+	traceEvChanLabel         = 52 // channel label is set [timestamp, chan id, label string id]
+	traceEvChanBuf           = 53 // channel buffer becomes full or empty, or stops being so [timestamp, chan id, old state, new state]
+	traceEvCount             = 54
 	// Byte is used but only 6 bits are available for event type.
 	// The remaining 2 bits are used to specify the number of arguments.
 	// That means, the max event type value is 63.
@@ -120,6 +125,7 @@ var trace struct {
 	timeStart     int64       // nanotime when tracing was started
 	timeEnd       int64       // nanotime when tracing was stopped
 	seqGC         uint64      // GC start/done sequencer
+	chanSeqStart  uint64      // traceChanSeq when tracing was started
 	reading       traceBufPtr // buffer currently handed off to user
 	empty         traceBufPtr // stack of empty buffers
 	fullHead      traceBufPtr // queue of full buffers
@@ -261,6 +267,7 @@ func StartTrace() error {
 	trace.strings = make(map[string]uint64)
 
 	trace.seqGC = 0
+	trace.chanSeqStart = atomic.Load64(&traceChanSeq)
 	_g_.m.startingtrace = false
 	trace.enabled = true
 
@@ -404,7 +411,7 @@ func ReadTrace() []byte {
 		trace.headerWritten = true
 		trace.lockOwner = nil
 		unlock(&trace.lock)
-		return []byte("go 1.11 trace\x00\x00\x00")
+		return []byte("go 1.17 trace\x00\x00\x00")
 	}
 	// Wait for new data.
 	if trace.fullHead == 0 && !trace.shutdown {
@@ -1095,18 +1102,179 @@ func traceGoPark(traceEv byte, skip int) {
 	if traceEv&traceFutileWakeup != 0 {
 		traceEvent(traceEvFutileWakeup, -1)
 	}
-	traceEvent(traceEv & ^traceFutileWakeup, skip)
+	traceEv &^= traceFutileWakeup
+	switch traceEv {
+	case traceEvGoBlockSend, traceEvGoBlockRecv:
+		// chansend and chanrecv park with gp.waiting set to their
+		// sudog and the channel still locked, after describing the
+		// channel with traceChanDescribe. We're on g0 here, where
+		// traceString can't be used.
+		gp := getg().m.curg
+		traceEvent(traceEv, skip, traceChanID(gp.waiting.c), traceTask(gp))
+		return
+	case traceEvGoBlockSelect:
+		traceEvent(traceEv, skip, traceTask(getg().m.curg))
+		return
+	}
+	traceEvent(traceEv, skip)
 }
 
+// Causes of traceEvGoUnblock and traceEvGoUnblockLocal events.
+const (
+	traceUnblockOther = iota // not woken by a channel operation
+	traceUnblockSend         // woken by a send on a channel
+	traceUnblockRecv         // woken by a receive on a channel
+	traceUnblockClose        // woken by a channel being closed
+)
+
 func traceGoUnpark(gp *g, skip int) {
+	traceGoUnparkCause(gp, skip, traceUnblockOther)
+}
+
+// traceGoUnparkCause is traceGoUnpark for a goroutine woken for the
+// given cause.
+func traceGoUnparkCause(gp *g, skip int, cause uint8) {
 	_p_ := getg().m.p
 	gp.traceseq++
 	if gp.tracelastp == _p_ {
-		traceEvent(traceEvGoUnblockLocal, skip, uint64(gp.goid))
+		traceEvent(traceEvGoUnblockLocal, skip, uint64(gp.goid), uint64(cause))
 	} else {
 		gp.tracelastp = _p_
-		traceEvent(traceEvGoUnblock, skip, uint64(gp.goid), gp.traceseq)
+		traceEvent(traceEvGoUnblock, skip, uint64(gp.goid), gp.traceseq, uint64(cause))
+	}
+}
+
+// traceChanSeq is the last channel trace ID handed out by traceChan.
+// IDs are never reused, so a channel whose ID predates the current
+// trace must be described again.
+var traceChanSeq uint64
+
+// traceChan returns the trace ID of c. If c has no ID in the current
+// trace, it assigns one and emits a traceEvChan event describing c,
+// so later events need only carry the ID. The ID is kept in c's side
+// state (see chanside.go), so only the channels that appear in a trace
+// carry one.
+//
+// c.lock must be held.
+func traceChan(mp *m, pid int32, bufp *traceBufPtr, c *hchan) (uint64, *traceBufPtr) {
+	if id := traceChanID(c); id != 0 {
+		return id, bufp
+	}
+	s := chanSide(c)
+	s.traceID = atomic.Xadd64(&traceChanSeq, 1)
+	elemStringID, bufp := traceString(bufp, pid, c.elemtype.string())
+	traceEventLocked(0, mp, pid, bufp, traceEvChan, -1, s.traceID, uint64(c.dataqsiz), elemStringID)
+	if l := chanLabel(c); l != "" {
+		var labelStringID uint64
+		labelStringID, bufp = traceString(bufp, pid, l)
+		traceEventLocked(0, mp, pid, bufp, traceEvChanLabel, -1, s.traceID, labelStringID)
+	}
+	return s.traceID, bufp
+}
+
+// traceChanID returns the trace ID of c, or 0 if c has not been
+// described in the current trace. c.lock must be held.
+func traceChanID(c *hchan) uint64 {
+	if s := c.side; s != nil && s.traceID > trace.chanSeqStart {
+		return s.traceID
+	}
+	return 0
+}
+
+// traceChanDescribe emits a traceEvChan event for c if it has not been
+// described in the current trace yet. c.lock must be held.
+func traceChanDescribe(c *hchan) {
+	// Same as in traceEvent.
+	mp, pid, bufp := traceAcquireBuffer()
+	if !trace.enabled && !mp.startingtrace {
+		traceReleaseBuffer(pid)
+		return
 	}
+	traceChan(mp, pid, bufp, c)
+	traceReleaseBuffer(pid)
+}
+
+// traceChanLabel records that the label of c was set or removed, if c
+// has been described in the current trace; otherwise its description
+// will carry the label. c.lock must be held.
+func traceChanLabel(c *hchan) {
+	// Same as in traceEvent.
+	mp, pid, bufp := traceAcquireBuffer()
+	if !trace.enabled && !mp.startingtrace {
+		traceReleaseBuffer(pid)
+		return
+	}
+	if id := traceChanID(c); id != 0 {
+		labelStringID, bufp := traceString(bufp, pid, chanLabel(c))
+		traceEventLocked(0, mp, pid, bufp, traceEvChanLabel, -1, id, labelStringID)
+	}
+	traceReleaseBuffer(pid)
+}
+
+// States of a channel's buffer in traceEvChanBuf events.
+const (
+	traceChanBufPartial = 0 // some values buffered, with room for more
+	traceChanBufFull    = 1
+	traceChanBufEmpty   = 2
+)
+
+// traceChanBufState returns the state of c's buffer when it holds n
+// values. A buffer of one value is never partial.
+func traceChanBufState(c *hchan, n uint) uint64 {
+	switch n {
+	case 0:
+		return traceChanBufEmpty
+	case c.dataqsiz:
+		return traceChanBufFull
+	}
+	return traceChanBufPartial
+}
+
+// traceChanBuf records that c's buffer became full or empty, or
+// stopped being so, as the number of values in it changed from old to
+// c.qcount. It records nothing if neither the old nor the new count is
+// 0 or the capacity. c.lock must be held, unless no other goroutine
+// can see c yet.
+func traceChanBuf(c *hchan, old uint) {
+	from, to := traceChanBufState(c, old), traceChanBufState(c, c.qcount)
+	if from == to {
+		return
+	}
+	// Same as in traceEvent.
+	mp, pid, bufp := traceAcquireBuffer()
+	if !trace.enabled && !mp.startingtrace {
+		traceReleaseBuffer(pid)
+		return
+	}
+	id, bufp := traceChan(mp, pid, bufp, c)
+	traceEventLocked(0, mp, pid, bufp, traceEvChanBuf, -1, id, from, to)
+	traceReleaseBuffer(pid)
+}
+
+// traceChanClose records the closing of c, which wakes the goroutines
+// waiting on it. c.lock must be held.
+func traceChanClose(c *hchan) {
+	// Same as in traceEvent.
+	mp, pid, bufp := traceAcquireBuffer()
+	if !trace.enabled && !mp.startingtrace {
+		traceReleaseBuffer(pid)
+		return
+	}
+	id, bufp := traceChan(mp, pid, bufp, c)
+	// Skip traceChanClose and closechanReport, so the stack starts
+	// at closechan.
+	waiters := c.recvq.len() + c.sendq.len()
+	traceEventLocked(0, mp, pid, bufp, traceEvChanClose, 3, id, uint64(waiters))
+	traceReleaseBuffer(pid)
+}
+
+// traceSelect records that a select statement with nsends send cases
+// and nrecvs receive cases chose case casi, in selectgo's numbering,
+// or its default case if casi is -1.
+func traceSelect(casi, nsends, nrecvs int) {
+	// Skip traceSelect and the runtime function that called it, so
+	// the stack starts at the select statement.
+	traceEvent(traceEvSelect, 3, uint64(casi+1), uint64(nsends), uint64(nrecvs))
 }
 
 func traceGoSysCall() {
@@ -1184,6 +1352,9 @@ func trace_userTaskEnd(id uint64) {
 
 //go:linkname trace_userRegion runtime/trace.userRegion
 func trace_userRegion(id, mode uint64, name string) {
+	// Keep track of the regions even when not tracing, so that the
+	// regions a goroutine is in when tracing starts are known.
+	traceRegionTask(getg(), id, mode)
 	if !trace.enabled {
 		return
 	}
@@ -1199,6 +1370,36 @@ func trace_userRegion(id, mode uint64, name string) {
 	traceReleaseBuffer(pid)
 }
 
+// traceRegionTask records on gp, the current goroutine, that it
+// started (mode 0) or ended (mode 1) a runtime/trace region of task id.
+// Regions must nest, but a region that is ended out of order ends the
+// regions started in it, and ending a region that was not started does
+// nothing.
+func traceRegionTask(gp *g, id, mode uint64) {
+	switch mode {
+	case 0:
+		gp.traceTasks = append(gp.traceTasks, id)
+	case 1:
+		for i := len(gp.traceTasks) - 1; i >= 0; i-- {
+			if gp.traceTasks[i] == id {
+				gp.traceTasks = gp.traceTasks[:i]
+				break
+			}
+		}
+	}
+}
+
+// traceTask returns the task of the innermost runtime/trace region gp
+// is in, or 0 if it is in none or that region belongs to no task.
+// Events that record it let the trace tools charge a blocked goroutine
+// to the task it was working for.
+func traceTask(gp *g) uint64 {
+	if n := len(gp.traceTasks); n > 0 {
+		return gp.traceTasks[n-1]
+	}
+	return 0
+}
+
 //go:linkname trace_userLog runtime/trace.userLog
 func trace_userLog(id uint64, category, message string) {
 	if !trace.enabled {
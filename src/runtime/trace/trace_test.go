@@ -12,7 +12,9 @@ import (
 	"io"
 	"net"
 	"os"
+	"reflect"
 	"runtime"
+	"runtime/debug"
 	. "runtime/trace"
 	"strconv"
 	"sync"
@@ -581,6 +583,306 @@ func TestTraceFutileWakeup(t *testing.T) {
 	}
 }
 
+func TestTraceChan(t *testing.T) {
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	buf := new(bytes.Buffer)
+	if err := Start(buf); err != nil {
+		t.Fatalf("failed to start tracing: %v", err)
+	}
+
+	c := make(chan *bytes.Buffer, 2)
+	done := make(chan struct{})
+	go func() {
+		for range c {
+		}
+		close(done)
+	}()
+	for i := 0; i < 10; i++ {
+		time.Sleep(time.Millisecond) // let the receiver block
+		c <- nil
+	}
+	close(c)
+	<-done
+
+	Stop()
+	saveTrace(t, buf, "TestTraceChan")
+	events, _ := parseTrace(t, buf)
+
+	const want = "chan *bytes.Buffer (cap 2)"
+	var id uint64
+	var descs, blocks, closes int
+	for _, ev := range events {
+		switch ev.Type {
+		case trace.EvChan:
+			if ev.SArgs[0] == "*bytes.Buffer" {
+				id = ev.Args[0]
+				descs++
+			}
+		case trace.EvGoBlockRecv:
+			if id != 0 && ev.Args[0] == id {
+				if ev.SArgs[0] != want {
+					t.Errorf("GoBlockRecv channel = %q, want %q", ev.SArgs[0], want)
+				}
+				blocks++
+			}
+		case trace.EvChanClose:
+			if id != 0 && ev.Args[0] == id {
+				if ev.SArgs[0] != want {
+					t.Errorf("ChanClose channel = %q, want %q", ev.SArgs[0], want)
+				}
+				if len(ev.Stk) == 0 || ev.Stk[0].Fn != "runtime.closechan" {
+					t.Errorf("ChanClose stack does not start at runtime.closechan: %v", ev.Stk)
+				}
+				closes++
+			}
+		}
+	}
+	if descs != 1 {
+		t.Errorf("got %d Chan events for the channel, want 1", descs)
+	}
+	if blocks == 0 {
+		t.Errorf("no GoBlockRecv events for the channel")
+	}
+	if closes != 1 {
+		t.Errorf("got %d ChanClose events for the channel, want 1", closes)
+	}
+}
+
+func TestTraceChanUnblockCause(t *testing.T) {
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	// waitBlocked waits until n more goroutines are blocked in
+	// channel operations than at the start of the test.
+	var stats debug.ChanStats
+	debug.ReadChanStats(&stats)
+	base := stats.BlockedSend + stats.BlockedRecv
+	waitBlocked := func(n int) {
+		for {
+			debug.ReadChanStats(&stats)
+			if stats.BlockedSend+stats.BlockedRecv >= base+n {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := Start(buf); err != nil {
+		t.Fatalf("failed to start tracing: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	sendWakes := make(chan int8)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-sendWakes
+	}()
+	waitBlocked(1)
+	sendWakes <- 1
+	wg.Wait()
+
+	recvWakes := make(chan int16)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		recvWakes <- 1
+	}()
+	waitBlocked(1)
+	<-recvWakes
+	wg.Wait()
+
+	closeWakes := make(chan int32)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-closeWakes
+		}()
+	}
+	waitBlocked(2)
+	close(closeWakes)
+	wg.Wait()
+
+	Stop()
+	saveTrace(t, buf, "TestTraceChanUnblockCause")
+	events, _ := parseTrace(t, buf)
+
+	want := map[string]string{"int8": "send", "int16": "receive", "int32": "close"}
+	elems := make(map[uint64]string)
+	wakes := make(map[string][]string)
+	for _, ev := range events {
+		switch ev.Type {
+		case trace.EvChan:
+			elems[ev.Args[0]] = ev.SArgs[0]
+		case trace.EvGoBlockSend, trace.EvGoBlockRecv:
+			elem := elems[ev.Args[0]]
+			if _, ok := want[elem]; !ok || ev.Link == nil {
+				continue
+			}
+			if ev.Link.Type != trace.EvGoUnblock {
+				t.Errorf("chan %s: blocking event linked to %v, want GoUnblock", elem, ev.Link)
+				continue
+			}
+			wakes[elem] = append(wakes[elem], ev.Link.SArgs[0])
+		case trace.EvChanClose:
+			if elem := elems[ev.Args[0]]; elem == "int32" && ev.Args[1] != 2 {
+				t.Errorf("ChanClose waiters = %d, want 2", ev.Args[1])
+			}
+		}
+	}
+	for elem, cause := range want {
+		n := 1
+		if cause == "close" {
+			n = 2
+		}
+		got := wakes[elem]
+		if len(got) != n {
+			t.Errorf("chan %s: %d goroutines unblocked, want %d", elem, len(got), n)
+		}
+		for _, c := range got {
+			if c != cause {
+				t.Errorf("chan %s: goroutine unblocked by %q, want %q", elem, c, cause)
+			}
+		}
+	}
+}
+
+func TestTraceChanLabel(t *testing.T) {
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	var stats debug.ChanStats
+	debug.ReadChanStats(&stats)
+	base := stats.BlockedRecv
+	waitBlocked := func() {
+		for {
+			debug.ReadChanStats(&stats)
+			if stats.BlockedRecv > base {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := Start(buf); err != nil {
+		t.Fatalf("failed to start tracing: %v", err)
+	}
+
+	// The first label is in the channel's description, the second
+	// is set after the channel has been described.
+	c := make(chan uint16, 1)
+	done := make(chan bool)
+	recv := func() {
+		<-c
+		done <- true
+	}
+	reflect.ValueOf(c).SetChanLabel("before")
+	go recv()
+	waitBlocked()
+	c <- 1
+	<-done
+	reflect.ValueOf(c).SetChanLabel("after")
+	go recv()
+	waitBlocked()
+	c <- 2
+	<-done
+
+	Stop()
+	saveTrace(t, buf, "TestTraceChanLabel")
+	events, _ := parseTrace(t, buf)
+
+	var id uint64
+	var got []string
+	for _, ev := range events {
+		switch ev.Type {
+		case trace.EvChan:
+			if ev.SArgs[0] == "uint16" {
+				id = ev.Args[0]
+			}
+		case trace.EvGoBlockRecv:
+			if id != 0 && ev.Args[0] == id {
+				got = append(got, ev.SArgs[0])
+			}
+		}
+	}
+	want := []string{`chan uint16 (cap 1, label "before")`, `chan uint16 (cap 1, label "after")`}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GoBlockRecv channels = %q, want %q", got, want)
+	}
+}
+
+type bufElem int
+
+func TestTraceChanBuf(t *testing.T) {
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	buf := new(bytes.Buffer)
+	if err := Start(buf); err != nil {
+		t.Fatalf("failed to start tracing: %v", err)
+	}
+	c := make(chan bufElem, 2)
+	c <- 1
+	c <- 2
+	<-c
+	<-c
+	select {
+	case c <- 3:
+	default:
+	}
+	select {
+	case <-c:
+	default:
+	}
+	Stop()
+	saveTrace(t, buf, "TestTraceChanBuf")
+	events, _ := parseTrace(t, buf)
+
+	const desc = "chan trace_test.bufElem (cap 2)"
+	var got [][2]uint64
+	for _, ev := range events {
+		if ev.Type == trace.EvChanBuf && ev.SArgs[0] == desc {
+			got = append(got, [2]uint64{ev.Args[1], ev.Args[2]})
+		}
+	}
+	const (
+		partial = trace.ChanBufPartial
+		full    = trace.ChanBufFull
+		empty   = trace.ChanBufEmpty
+	)
+	want := [][2]uint64{
+		{empty, partial}, {partial, full}, {full, partial}, {partial, empty},
+		{empty, partial}, {partial, empty},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ChanBuf transitions = %v, want %v", got, want)
+	}
+
+	var states []int
+	var starts, ends []bool
+	for _, r := range trace.ChanBufRanges(events) {
+		if r.Desc == desc {
+			states = append(states, r.State)
+			starts = append(starts, r.Start != nil)
+			ends = append(ends, r.End != nil)
+		}
+	}
+	if want := []int{empty, full, empty, empty}; !reflect.DeepEqual(states, want) {
+		t.Errorf("range states = %v, want %v", states, want)
+	}
+	if want := []bool{false, true, true, true}; !reflect.DeepEqual(starts, want) {
+		t.Errorf("ranges with a start event = %v, want %v", starts, want)
+	}
+	if want := []bool{true, true, true, false}; !reflect.DeepEqual(ends, want) {
+		t.Errorf("ranges with an end event = %v, want %v", ends, want)
+	}
+}
+
 func saveTrace(t *testing.T, buf *bytes.Buffer, name string) {
 	if !*saveTraces {
 		return
@@ -589,3 +891,53 @@ func saveTrace(t *testing.T, buf *bytes.Buffer, name string) {
 		t.Errorf("failed to write trace file: %s", err)
 	}
 }
+
+func TestTraceSelectCase(t *testing.T) {
+	if IsEnabled() {
+		t.Skip("skipping because -test.trace is set")
+	}
+	buf := new(bytes.Buffer)
+	if err := Start(buf); err != nil {
+		t.Fatalf("failed to start tracing: %v", err)
+	}
+
+	ready := make(chan int, 1)
+	never := make(chan int)
+	ready <- 1
+	// The second receive is numbered 1 by selectgo.
+	select {
+	case never <- 1:
+	case <-never:
+	case <-ready:
+	}
+	// A select with one case and a default, taking the default.
+	select {
+	case <-never:
+	default:
+	}
+	// A blocking select, woken by a send.
+	go func() {
+		ready <- 1
+	}()
+	select {
+	case <-never:
+	case <-ready:
+	}
+
+	Stop()
+	saveTrace(t, buf, "TestTraceSelectCase")
+	events, _ := parseTrace(t, buf)
+
+	type sel struct{ casi, nsends, nrecvs uint64 }
+	want := []sel{{2, 1, 2}, {0, 0, 1}, {1, 0, 2}}
+	var got []sel
+	for _, ev := range events {
+		if ev.Type != trace.EvSelect || len(ev.Stk) == 0 || ev.Stk[0].Fn != "runtime/trace_test.TestTraceSelectCase" {
+			continue
+		}
+		got = append(got, sel{ev.Args[0], ev.Args[1], ev.Args[2]})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("select events: got %v, want %v", got, want)
+	}
+}
@@ -1013,9 +1013,78 @@ func goroutineheader(gp *g) {
 		print(", locked to thread")
 	}
 	print("]:\n")
+	if gpstatus == _Gwaiting && gp.waitreason == waitReasonSelect {
+		printselectcases(gp)
+	}
+	if gpstatus == _Gwaiting && (gp.waitreason.isChanSend() || gp.waitreason.isChanRecv()) {
+		printchanlabel(gp)
+	}
+}
+
+// printchanlabel prints the label of the channel gp is blocked on in a
+// send or receive, if the channel has one.
+func printchanlabel(gp *g) {
+	sg := gp.waiting
+	if sg == nil || sg.c == nil {
+		return
+	}
+	if l := chanLabel(sg.c); l != "" {
+		print("	chan ", sg.c, " label \"", l, "\"\n")
+	}
+}
+
+// maxPrintSelectCases is the maximum number of cases printselectcases
+// prints before summarizing the rest.
+const maxPrintSelectCases = 32
+
+// printselectcases prints one line for each case of the select gp is
+// blocked in, as found on gp.waiting.
+//
+// This runs without holding any channel locks, possibly while gp is
+// being woken up and is tearing down its wait list, so it only reads
+// the list and is prepared for it to change underfoot: the walk stops
+// at the first nil link or channel and is bounded by the maximum
+// number of select cases, so a list that is being relinked can't make
+// it loop forever. The output is best effort in that case.
+func printselectcases(gp *g) {
+	n := 0
+	for sg := gp.waiting; sg != nil && n < 1<<16; sg = sg.waitlink {
+		c := sg.c
+		if c == nil {
+			break
+		}
+		if n < maxPrintSelectCases {
+			dir := "recv"
+			if sg.isSend() {
+				dir = "send"
+			}
+			print("\tselect ", dir, " on ", c, " (chan ")
+			if t := c.elemtype; t != nil {
+				print(t.string())
+			} else {
+				print("?")
+			}
+			print(", len ", c.qcount, ", cap ", c.dataqsiz)
+			if l := chanLabel(c); l != "" {
+				print(", label \"", l, "\"")
+			}
+			print(")\n")
+		}
+		n++
+	}
+	if n > maxPrintSelectCases {
+		print("\t+", n-maxPrintSelectCases, " more select cases\n")
+	}
 }
 
 func tracebackothers(me *g) {
+	tracebackothersgroup(me, debug.tracebackgroup)
+}
+
+// tracebackothersgroup is tracebackothers, collapsing groups of at
+// least min goroutines with alike tracebacks if min > 0.
+// See tracebackgroup.go.
+func tracebackothersgroup(me *g, min int32) {
 	level, _, _ := gotraceback()
 
 	// Show the current goroutine first, if we haven't already.
@@ -1026,6 +1095,10 @@ func tracebackothers(me *g) {
 		traceback(^uintptr(0), ^uintptr(0), 0, curgp)
 	}
 
+	if min > 0 && tracebackothersgrouped(me, curgp, level, min) {
+		return
+	}
+
 	// We can't call locking forEachG here because this may be during fatal
 	// throw/panic, where locking could be out-of-order or a direct
 	// deadlock.
@@ -1038,17 +1111,7 @@ func tracebackothers(me *g) {
 			return
 		}
 		print("\n")
-		goroutineheader(gp)
-		// Note: gp.m == g.m occurs when tracebackothers is
-		// called from a signal handler initiated during a
-		// systemstack call. The original G is still in the
-		// running state, and we want to print its stack.
-		if gp.m != getg().m && readgstatus(gp)&^_Gscan == _Grunning {
-			print("\tgoroutine running on other thread; stack unavailable\n")
-			printcreatedby(gp)
-		} else {
-			traceback(^uintptr(0), ^uintptr(0), 0, gp)
-		}
+		tracebackg(gp)
 	})
 }
 
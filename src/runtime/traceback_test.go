@@ -6,8 +6,13 @@ package runtime_test
 
 import (
 	"bytes"
+	"fmt"
+	"reflect"
 	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 var testTracebackArgsBuf [1000]byte
@@ -290,3 +295,191 @@ func testTracebackArgs8d(a testArgsType8d) int {
 	}
 	return n
 }
+
+func TestTracebackSelectCases(t *testing.T) {
+	send := make(chan string, 2)
+	send <- "full"
+	send <- "full"
+	recv := make(chan int)
+	done := make(chan bool)
+	go func() {
+		select {
+		case send <- "x":
+		case <-recv:
+		case <-done:
+		}
+	}()
+	defer close(done)
+
+	want := []string{
+		fmt.Sprintf("\tselect send on %p (chan string, len 2, cap 2)\n", send),
+		fmt.Sprintf("\tselect recv on %p (chan int, len 0, cap 0)\n", recv),
+		fmt.Sprintf("\tselect recv on %p (chan bool, len 0, cap 0)\n", done),
+	}
+	waitForStack(t, want)
+}
+
+func TestTracebackSelectCasesWide(t *testing.T) {
+	// More cases than the traceback prints.
+	const n = 40
+	done := make(chan bool)
+	cases := []reflect.SelectCase{{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(done)}}
+	for i := 1; i < n; i++ {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(make(chan int))})
+	}
+	go reflect.Select(cases)
+	defer close(done)
+
+	// The cases are listed in lock order, so which of them are left
+	// out depends on the addresses of their channels.
+	waitForStack(t, []string{
+		fmt.Sprintf(", len 0, cap 0)\n\t+%d more select cases\n", n-32),
+	})
+}
+
+func TestTracebackChanLabel(t *testing.T) {
+	send := make(chan string)
+	recv := make(chan int, 1)
+	reflect.ValueOf(send).SetChanLabel("requests")
+	reflect.ValueOf(recv).SetChanLabel("replies")
+	done := make(chan bool)
+	go func() { send <- "x" }()
+	go func() {
+		select {
+		case <-recv:
+		case <-done:
+		}
+	}()
+	defer close(done)
+	defer func() { <-send }()
+
+	waitForStack(t, []string{
+		fmt.Sprintf("\tchan %p label \"requests\"\n", send),
+		fmt.Sprintf("\tselect recv on %p (chan int, len 0, cap 1, label \"replies\")\n", recv),
+		fmt.Sprintf("\tselect recv on %p (chan bool, len 0, cap 0)\n", done),
+	})
+}
+
+// waitForStack waits until a dump of all goroutine stacks contains
+// every string in want.
+func waitForStack(t *testing.T, want []string) {
+	t.Helper()
+	buf := make([]byte, 1<<20)
+	var stk string
+	for i := 0; i < 1000; i++ {
+		stk = string(buf[:runtime.Stack(buf, true)])
+		ok := true
+		for _, w := range want {
+			if !strings.Contains(stk, w) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("goroutine dump missing %q:\n%s", want, stk)
+}
+
+func TestGroupGSnaps(t *testing.T) {
+	const min = 60e9
+	snaps := []runtime.GSnap{
+		{Goid: 9, Key: 2, Wait: 1, Waitfor: 5 * min, Chan: 1},
+		{Goid: 3, Key: 1, Wait: 1, Waitfor: -1},
+		{Goid: 7, Key: 2, Wait: 1, Waitfor: 1 * min, Chan: 1},
+		{Goid: 4, Key: 2, Wait: 1, Waitfor: -1, Chan: 2},
+		{Goid: 5, Key: 2, Wait: 2, Waitfor: 2 * min, Chan: 1},
+		{Goid: 8, Key: 2, Wait: 1, Waitfor: 9 * min, Chan: 1},
+		{Goid: 6, Key: 1, Wait: 1, Waitfor: 3 * min},
+		{Goid: 2, Key: 3, Wait: 1, Waitfor: 4 * min},
+	}
+	want := []runtime.GSnapGroup{
+		{Goids: []int64{4, 7, 8, 9}, Chans: 2, Known: 3, Min: 1 * min, Med: 5 * min, Max: 9 * min},
+		{Goids: []int64{3, 6}, Chans: 0, Known: 1, Min: 3 * min, Med: 3 * min, Max: 3 * min},
+		{Goids: []int64{2}, Chans: 0, Known: 1, Min: 4 * min, Med: 4 * min, Max: 4 * min},
+		{Goids: []int64{5}, Chans: 1, Known: 1, Min: 2 * min, Med: 2 * min, Max: 2 * min},
+	}
+	got := runtime.GroupGSnaps(snaps)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GroupGSnaps:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestGroupGSnapsLarge(t *testing.T) {
+	// Many goroutines in a few groups, in scrambled order.
+	const n = 10000
+	snaps := make([]runtime.GSnap, n)
+	for i := range snaps {
+		id := int64(i*7919%n + 1)
+		snaps[i] = runtime.GSnap{Goid: id, Key: uintptr(id % 3), Wait: 1, Waitfor: id, Chan: int(id%3) + 1}
+	}
+	got := runtime.GroupGSnaps(snaps)
+	if len(got) != 3 {
+		t.Fatalf("got %d groups, want 3", len(got))
+	}
+	total := 0
+	for i, g := range got {
+		total += len(g.Goids)
+		if i > 0 && len(g.Goids) > len(got[i-1].Goids) {
+			t.Errorf("group %d larger than group %d", i, i-1)
+		}
+		for j, id := range g.Goids {
+			if j > 0 && id <= g.Goids[j-1] {
+				t.Fatalf("group %d goroutine IDs not sorted", i)
+			}
+		}
+		if g.Chans != 1 || g.Known != len(g.Goids) {
+			t.Errorf("group %d: %d chans, %d known wait times", i, g.Chans, g.Known)
+		}
+		if g.Min != g.Goids[0] || g.Max != g.Goids[len(g.Goids)-1] || g.Med != g.Goids[len(g.Goids)/2] {
+			t.Errorf("group %d: wait times %d/%d/%d", i, g.Min, g.Med, g.Max)
+		}
+	}
+	if total != n {
+		t.Errorf("groups hold %d goroutines, want %d", total, n)
+	}
+}
+
+func TestStackGrouped(t *testing.T) {
+	const n = 50
+	c := make(chan int)
+	var ready sync.WaitGroup
+	ready.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			ready.Done()
+			<-c
+		}()
+	}
+	defer close(c)
+	ready.Wait()
+
+	want := []string{
+		fmt.Sprintf("\n%d goroutines [chan receive (sync)]:\n", n),
+		fmt.Sprintf("\ton 1 chan: %p (chan int, len 0, cap 0)\n", c),
+		" ... (+40 more)\n",
+		"runtime_test.TestStackGrouped.func1()",
+	}
+	buf := make([]byte, 1<<20)
+	var stk string
+	for i := 0; i < 1000; i++ {
+		stk = string(buf[:runtime.StackGrouped(buf)])
+		ok := true
+		for _, w := range want {
+			if !strings.Contains(stk, w) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			if k := strings.Count(stk, "runtime_test.TestStackGrouped.func1()"); k != 1 {
+				t.Fatalf("grouped dump prints the stack %d times, want 1:\n%s", k, stk)
+			}
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("grouped goroutine dump missing %q:\n%s", want, stk)
+}
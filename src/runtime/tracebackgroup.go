@@ -0,0 +1,399 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package runtime
+
+// Grouped tracebacks of all goroutines.
+//
+// A program with hundreds of thousands of goroutines parked on
+// channels produces a traceback dump of tens of megabytes, mostly
+// copies of a few stacks. With GODEBUG=tracebackgroup=N, and for the
+// debug=4 goroutine profile, tracebackothers instead prints the
+// goroutines that have the same status, wait reason, and stack once,
+// as a group:
+//
+//	200000 goroutines [chan receive (sync), 3 minutes]:
+//		goroutine ids: 21 22 23 24 25 26 27 28 29 30 ... (+199990 more)
+//		on 1 chan: 0xc000020060 (chan int, len 0, cap 0)
+//		waiting: min 1, median 3, max 7 minutes
+//	main.worker(...)
+//		/tmp/x.go:10 +0x25
+//	...
+//
+// The channel line names the channel if all goroutines in the group
+// are blocked on the same one, and otherwise counts the distinct
+// channels, with their type if they share one. The waiting line
+// summarizes the approximate time the goroutines have been blocked, as
+// far as it is known. The header shows the median. Groups with fewer
+// than N goroutines are printed goroutine by goroutine, as usual.
+// Groups are printed largest first.
+//
+// The dump runs during fatal errors as well as from runtime.Stack, so
+// it must not allocate from the heap. The snapshot of the goroutines
+// and the groups live in memory obtained directly from the OS, and
+// are sorted with a heap sort. If that memory can't be had, the
+// goroutines are printed one by one.
+
+import (
+	"runtime/internal/atomic"
+	"unsafe"
+)
+
+// defaultTracebackGroupMin is the smallest group the debug=4
+// goroutine profile collapses if GODEBUG=tracebackgroup is not set.
+const defaultTracebackGroupMin = 5
+
+// tracebackGroupIDs is the number of goroutine IDs printed for a
+// collapsed group.
+const tracebackGroupIDs = 10
+
+// tracebackGroupFrames is the number of stack frames compared to
+// decide whether two goroutines have the same stack.
+const tracebackGroupFrames = 64
+
+// A gsnap is a snapshot of a goroutine, for grouping tracebacks.
+type gsnap struct {
+	gp         *g // nil in tests
+	goid       int64
+	key        uintptr // hash of the stack and creation site
+	status     uint32  // without _Gscan
+	waitreason waitReason
+	locked     bool
+	waitfor    int64  // nanoseconds blocked, or -1 if unknown
+	c          *hchan // channel blocked on, or nil
+}
+
+// sameGroup reports whether the tracebacks of a and b look alike.
+func (a *gsnap) sameGroup(b *gsnap) bool {
+	return a.key == b.key && a.status == b.status && a.waitreason == b.waitreason && a.locked == b.locked
+}
+
+// A gsnapGroup is a run of snapshots that sameGroup considers alike.
+type gsnapGroup struct {
+	start, n int
+}
+
+// snapg records gp in s. The traceback of gp must be stable, as it is
+// in tracebackothers.
+func snapg(s *gsnap, gp *g, now int64) {
+	status := readgstatus(gp) &^ _Gscan
+	*s = gsnap{
+		gp:         gp,
+		goid:       gp.goid,
+		status:     status,
+		waitreason: gp.waitreason,
+		locked:     gp.lockedm != 0,
+		waitfor:    -1,
+		c:          goroutineWaitChan(gp),
+	}
+	if status != _Gwaiting {
+		s.waitreason = waitReasonZero
+	}
+	if (status == _Gwaiting || status == _Gsyscall) && gp.waitsince != 0 {
+		s.waitfor = now - gp.waitsince
+	}
+
+	var pcbuf [tracebackGroupFrames]uintptr
+	n := 0
+	if gp.m != getg().m && status == _Grunning {
+		// Stack unavailable; see tracebackothers.
+	} else if status == _Gsyscall {
+		n = gentraceback(gp.syscallpc, gp.syscallsp, 0, gp, 0, &pcbuf[0], len(pcbuf), nil, nil, 0)
+	} else {
+		n = gentraceback(^uintptr(0), ^uintptr(0), 0, gp, 0, &pcbuf[0], len(pcbuf), nil, nil, 0)
+	}
+	h := memhash(noescape(unsafe.Pointer(&pcbuf[0])), gp.gopc, uintptr(n)*unsafe.Sizeof(pcbuf[0]))
+	if gp.ancestors != nil {
+		// Ancestors are printed too, and differ per goroutine.
+		h ^= uintptr(unsafe.Pointer(gp.ancestors))
+	}
+	s.key = h
+}
+
+// groupgsnaps sorts snaps into groups of goroutines with alike
+// tracebacks, ordered by goroutine ID within each group. It records
+// the groups in groups, which must be at least as long as snaps, and
+// returns them, largest first, with ties broken by the smallest
+// goroutine ID.
+func groupgsnaps(snaps []gsnap, groups []gsnapGroup) []gsnapGroup {
+	heapsortFunc(len(snaps), func(i, j int) bool {
+		a, b := &snaps[i], &snaps[j]
+		switch {
+		case a.key != b.key:
+			return a.key < b.key
+		case a.status != b.status:
+			return a.status < b.status
+		case a.waitreason != b.waitreason:
+			return a.waitreason < b.waitreason
+		case a.locked != b.locked:
+			return !a.locked
+		}
+		return a.goid < b.goid
+	}, func(i, j int) {
+		snaps[i], snaps[j] = snaps[j], snaps[i]
+	})
+
+	ng := 0
+	for i := 0; i < len(snaps); {
+		j := i + 1
+		for j < len(snaps) && snaps[j].sameGroup(&snaps[i]) {
+			j++
+		}
+		groups[ng] = gsnapGroup{start: i, n: j - i}
+		ng++
+		i = j
+	}
+	groups = groups[:ng]
+
+	heapsortFunc(len(groups), func(i, j int) bool {
+		a, b := groups[i], groups[j]
+		if a.n != b.n {
+			return a.n > b.n
+		}
+		return snaps[a.start].goid < snaps[b.start].goid
+	}, func(i, j int) {
+		groups[i], groups[j] = groups[j], groups[i]
+	})
+	return groups
+}
+
+// gsnapStats summarizes the channels and wait times of a group.
+type gsnapStats struct {
+	chans int    // distinct channels blocked on
+	c     *hchan // the channel, if chans == 1
+	elem  *_type // the channels' element type, if they all have the same one
+	known int    // goroutines with a known wait time
+	min   int64  // wait times, valid if known > 0
+	med   int64
+	max   int64
+}
+
+// groupstats computes the statistics of a group. It reorders snaps.
+func groupstats(snaps []gsnap) gsnapStats {
+	var st gsnapStats
+
+	less := func(i, j int) bool {
+		a, b := &snaps[i], &snaps[j]
+		if a.c != b.c {
+			return uintptr(unsafe.Pointer(a.c)) < uintptr(unsafe.Pointer(b.c))
+		}
+		return a.waitfor < b.waitfor
+	}
+	swap := func(i, j int) {
+		snaps[i], snaps[j] = snaps[j], snaps[i]
+	}
+	heapsortFunc(len(snaps), less, swap)
+	sameElem := true
+	for i := range snaps {
+		if c := snaps[i].c; c != nil && (i == 0 || c != snaps[i-1].c) {
+			if st.chans == 0 {
+				st.elem = c.elemtype
+			} else if c.elemtype != st.elem {
+				sameElem = false
+			}
+			st.chans++
+			st.c = c
+		}
+	}
+	if st.chans != 1 {
+		st.c = nil
+	}
+	if !sameElem {
+		st.elem = nil
+	}
+
+	// Now by wait time alone; unknown wait times sort first.
+	less = func(i, j int) bool {
+		return snaps[i].waitfor < snaps[j].waitfor
+	}
+	heapsortFunc(len(snaps), less, swap)
+	first := 0
+	for first < len(snaps) && snaps[first].waitfor < 0 {
+		first++
+	}
+	if known := snaps[first:]; len(known) > 0 {
+		st.known = len(known)
+		st.min = known[0].waitfor
+		st.med = known[len(known)/2].waitfor
+		st.max = known[len(known)-1].waitfor
+	}
+	return st
+}
+
+// heapsortFunc sorts the n elements of a collection with a heap sort.
+// It does not allocate or recurse, so it is safe to use while
+// printing tracebacks.
+func heapsortFunc(n int, less func(i, j int) bool, swap func(i, j int)) {
+	siftDown := func(root, hi int) {
+		for {
+			child := 2*root + 1
+			if child >= hi {
+				return
+			}
+			if child+1 < hi && less(child, child+1) {
+				child++
+			}
+			if !less(root, child) {
+				return
+			}
+			swap(root, child)
+			root = child
+		}
+	}
+	for i := n/2 - 1; i >= 0; i-- {
+		siftDown(i, n)
+	}
+	for i := n - 1; i > 0; i-- {
+		swap(0, i)
+		siftDown(0, i)
+	}
+}
+
+// tracebackothersgrouped prints the tracebacks of all goroutines except
+// me and curgp, as tracebackothers does, but collapses groups of at
+// least min goroutines with alike tracebacks. It reports false, having
+// printed nothing, if it can't get memory for the snapshot.
+func tracebackothersgrouped(me, curgp *g, level int32, min int32) bool {
+	// Snapshot the goroutines. See tracebackothers for why this
+	// doesn't lock allglock; goroutines created after allglen is
+	// read are not printed.
+	max := int(atomic.Loaduintptr(&allglen))
+	size := uintptr(max) * (unsafe.Sizeof(gsnap{}) + unsafe.Sizeof(gsnapGroup{}))
+	if size == 0 {
+		return true
+	}
+	size = alignUp(size, physPageSize)
+	mem := sysAlloc(size, &memstats.other_sys)
+	if mem == nil {
+		return false
+	}
+	defer sysFree(mem, size, &memstats.other_sys)
+	snaps := unsafe.Slice((*gsnap)(mem), max)
+	groups := unsafe.Slice((*gsnapGroup)(add(mem, uintptr(max)*unsafe.Sizeof(gsnap{}))), max)
+
+	now := nanotime()
+	n := 0
+	forEachGRace(func(gp *g) {
+		if n == max || gp == me || gp == curgp || readgstatus(gp) == _Gdead || isSystemGoroutine(gp, false) && level < 2 {
+			return
+		}
+		snapg(&snaps[n], gp, now)
+		n++
+	})
+	snaps = snaps[:n]
+
+	for _, grp := range groupgsnaps(snaps, groups) {
+		snaps := snaps[grp.start : grp.start+grp.n]
+		if int32(grp.n) < min {
+			for i := range snaps {
+				print("\n")
+				tracebackg(snaps[i].gp)
+			}
+			continue
+		}
+		printgsnapgroup(snaps)
+	}
+	return true
+}
+
+// tracebackg prints the header and traceback of gp, which is not
+// running on the current thread.
+func tracebackg(gp *g) {
+	goroutineheader(gp)
+	// Note: gp.m == g.m occurs when tracebackothers is
+	// called from a signal handler initiated during a
+	// systemstack call. The original G is still in the
+	// running state, and we want to print its stack.
+	if gp.m != getg().m && readgstatus(gp)&^_Gscan == _Grunning {
+		print("\tgoroutine running on other thread; stack unavailable\n")
+		printcreatedby(gp)
+	} else {
+		traceback(^uintptr(0), ^uintptr(0), 0, gp)
+	}
+}
+
+// printgsnapgroup prints a collapsed group of goroutines, sorted by
+// goroutine ID, followed by the traceback of the first of them.
+func printgsnapgroup(snaps []gsnap) {
+	first := snaps[0].gp
+	print("\n", len(snaps), " goroutine")
+	if len(snaps) > 1 {
+		print("s")
+	}
+	print(" [")
+	s := &snaps[0]
+	if s.status == _Gwaiting && s.waitreason != waitReasonZero {
+		print(s.waitreason.String())
+	} else if s.status < uint32(len(gStatusStrings)) {
+		print(gStatusStrings[s.status])
+	} else {
+		print("???")
+	}
+
+	// Print the IDs before groupstats reorders snaps.
+	ids := len(snaps)
+	if ids > tracebackGroupIDs {
+		ids = tracebackGroupIDs
+	}
+	var idbuf [tracebackGroupIDs]int64
+	for i := 0; i < ids; i++ {
+		idbuf[i] = snaps[i].goid
+	}
+
+	st := groupstats(snaps)
+	if st.known > 0 && st.med >= 60e9 {
+		print(", ", st.med/60e9, " minutes")
+	}
+	if s.locked {
+		print(", locked to thread")
+	}
+	print("]:\n")
+
+	print("\tgoroutine ids:")
+	for _, id := range idbuf[:ids] {
+		print(" ", id)
+	}
+	if len(snaps) > ids {
+		print(" ... (+", len(snaps)-ids, " more)")
+	}
+	print("\n")
+	switch {
+	case st.chans == 1:
+		c := st.c
+		print("\ton 1 chan: ", c, " (chan ")
+		if t := c.elemtype; t != nil {
+			print(t.string())
+		} else {
+			print("?")
+		}
+		print(", len ", c.qcount, ", cap ", c.dataqsiz)
+		if l := chanLabel(c); l != "" {
+			print(", label \"", l, "\"")
+		}
+		print(")\n")
+	case st.chans > 1:
+		print("\ton ", st.chans, " distinct chans")
+		if st.elem != nil {
+			print(" (chan ", st.elem.string(), ")")
+		}
+		print("\n")
+	}
+	if st.known > 0 {
+		print("\twaiting: min ", st.min/60e9, ", median ", st.med/60e9, ", max ", st.max/60e9, " minutes")
+		if st.known < len(snaps) {
+			print(" (", st.known, " of ", len(snaps), " known)")
+		}
+		print("\n")
+	}
+
+	if s.status == _Gwaiting && s.waitreason == waitReasonSelect {
+		printselectcases(first)
+	}
+	if first.m != getg().m && s.status == _Grunning {
+		print("\tgoroutine running on other thread; stack unavailable\n")
+		printcreatedby(first)
+	} else {
+		traceback(^uintptr(0), ^uintptr(0), 0, first)
+	}
+}
@@ -438,6 +438,46 @@ func TestInterleavedFunctions(t *testing.T) {
 	f.Invoke()
 }
 
+type gcNode struct {
+	next *gcNode
+	pad  [2]*int
+}
+
+// TestFuncOfDuringGC checks that idle-time GC marking does not hold up
+// the delivery of a callback until the mark phase ends.
+func TestFuncOfDuringGC(t *testing.T) {
+	// Enough pointers that marking them takes much longer than an
+	// idle mark slice.
+	var heap *gcNode
+	for i := 0; i < 1e6; i++ {
+		heap = &gcNode{next: heap}
+	}
+	defer runtime.KeepAlive(heap)
+
+	gcDone := make(chan struct{})
+	c := make(chan bool)
+	cb := js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		select {
+		case <-gcDone:
+			c <- false
+		default:
+			c <- true
+		}
+		return nil
+	})
+	defer cb.Release()
+
+	go func() {
+		runtime.GC()
+		close(gcDone)
+	}()
+	js.Global().Call("setTimeout", cb, 0)
+	if !<-c {
+		t.Errorf("callback was not called until the GC finished")
+	}
+	<-gcDone
+}
+
 func ExampleFuncOf() {
 	var cb js.Func
 	cb = js.FuncOf(func(this js.Value, args []js.Value) interface{} {
@@ -0,0 +1,116 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testing
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// leakedGoroutine describes a goroutine that is blocked only on
+// channels created by a test. It must be kept structurally identical
+// to runtime.leakedGoroutine.
+type leakedGoroutine struct {
+	goid    int64
+	wait    string
+	stack   [32]uintptr // stack of the goroutine, 0-terminated if short
+	created [4]uintptr  // creation PCs of its channels, 0-terminated if short
+	labels  [4]string   // labels of its channels, parallel to created
+}
+
+// Provided by package runtime.
+func runtime_setChanLeakScope(scope uint64)
+func runtime_chanLeaks(scope uint64, p []leakedGoroutine) int
+
+// chanLeakScopes is the last leak scope handed out by
+// startChanLeakCheck. Scope 0 disables the check.
+var chanLeakScopes uint64
+
+// startChanLeakCheck gives t a new leak scope and stamps it into the
+// goroutine running t, so that the runtime attributes the channels
+// created by t's body and by the goroutines it starts to t rather
+// than to its parent or to tests running in parallel with it.
+func (t *T) startChanLeakCheck() {
+	t.chanLeakScope = atomic.AddUint64(&chanLeakScopes, 1)
+	runtime_setChanLeakScope(t.chanLeakScope)
+}
+
+// checkChanLeaks fails t if goroutines are left blocked on channels
+// created in t's leak scope. Goroutines that are about to be
+// unblocked by others that are still running are not leaks, so the
+// check is repeated for a short while before giving up.
+func (t *T) checkChanLeaks() {
+	var leaks []leakedGoroutine
+	for delay := time.Millisecond; ; delay *= 2 {
+		runtime.Gosched()
+		leaks = readChanLeaks(t.chanLeakScope)
+		if len(leaks) == 0 {
+			return
+		}
+		if delay > 100*time.Millisecond {
+			break
+		}
+		time.Sleep(delay)
+	}
+
+	if !t.Failed() {
+		atomic.AddUint32(&numFailed, 1)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d goroutine(s) leaked, blocked on channels created by the test:", len(leaks))
+	for _, l := range leaks {
+		fmt.Fprintf(&b, "\n\ngoroutine %d [%s]:", l.goid, l.wait)
+		frames := runtime.CallersFrames(trimPCs(l.stack[:]))
+		for {
+			f, more := frames.Next()
+			// Like goroutine tracebacks, leave out the runtime's frames.
+			if !strings.HasPrefix(f.Function, "runtime.") {
+				fmt.Fprintf(&b, "\n%s(...)\n\t%s:%d", f.Function, f.File, f.Line)
+			}
+			if !more {
+				break
+			}
+		}
+		created := trimPCs(l.created[:])
+		for i, pc := range created {
+			label := l.labels[i]
+			if i > 0 && pc == created[i-1] && label == l.labels[i-1] {
+				continue // select with several cases on one channel
+			}
+			f, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+			if label != "" {
+				fmt.Fprintf(&b, "\nchannel %q created at %s\n\t%s:%d", label, f.Function, f.File, f.Line)
+			} else {
+				fmt.Fprintf(&b, "\nchannel created at %s\n\t%s:%d", f.Function, f.File, f.Line)
+			}
+		}
+	}
+	t.Error(b.String())
+}
+
+// readChanLeaks returns the goroutines leaked in scope.
+func readChanLeaks(scope uint64) []leakedGoroutine {
+	n := runtime_chanLeaks(scope, nil)
+	for {
+		// Leave room for goroutines blocking since the last call.
+		leaks := make([]leakedGoroutine, n+10)
+		if n = runtime_chanLeaks(scope, leaks); n <= len(leaks) {
+			return leaks[:n]
+		}
+	}
+}
+
+// trimPCs returns the PCs in pcs up to the first 0.
+func trimPCs(pcs []uintptr) []uintptr {
+	for i, pc := range pcs {
+		if pc == 0 {
+			return pcs[:i]
+		}
+	}
+	return pcs
+}
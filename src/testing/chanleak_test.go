@@ -0,0 +1,104 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testing_test
+
+import (
+	"internal/testenv"
+	"os"
+	"os/exec"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestChanLeak(t *testing.T) {
+	testenv.MustHaveExec(t)
+
+	cmd := exec.Command(os.Args[0], "-test.run=TestChanLeakHelper", "-test.v", "-test.chanleak")
+	cmd.Env = append(os.Environ(), "GO_WANT_HELPER_PROCESS=1")
+	b, _ := cmd.CombinedOutput()
+	got := string(b)
+
+	for _, want := range []string{
+		`--- FAIL: TestChanLeakHelper/leak \(`,
+		`goroutine \d+ \[chan send \(sync\)\]:\n\s+testing_test.TestChanLeakHelper.func[\d.]+\(...\)\n\s+\S+chanleak_test.go:\d+\n\s+channel created at testing_test.TestChanLeakHelper.func[\d.]+\n`,
+		`goroutine \d+ \[select\]:\n\s+testing_test.TestChanLeakHelper.func[\d.]+\(...\)\n\s+\S+chanleak_test.go:\d+\n\s+channel created at testing_test.TestChanLeakHelper.func[\d.]+\n\s+\S+chanleak_test.go:\d+\n\S`,
+		`--- FAIL: TestChanLeakHelper/labeled-leak \(`,
+		`goroutine \d+ \[chan receive \(sync\)\]:\n\s+testing_test.TestChanLeakHelper.func[\d.]+\(...\)\n\s+\S+chanleak_test.go:\d+\n\s+channel "jobs" created at testing_test.TestChanLeakHelper.func[\d.]+\n`,
+		`--- FAIL: TestChanLeakHelper/group/parallel-leak \(`,
+		`--- PASS: TestChanLeakHelper/group/parallel \(`,
+		`--- PASS: TestChanLeakHelper/no-leak \(`,
+		`--- PASS: TestChanLeakHelper/handoff \(`,
+		`--- PASS: TestChanLeakHelper/cleanup \(`,
+		`--- PASS: TestChanLeakHelper/parent-chan \(`,
+	} {
+		if !regexp.MustCompile(want).MatchString(got) {
+			t.Errorf("output does not match %#q:\n%s", want, got)
+		}
+	}
+}
+
+func TestChanLeakHelper(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	t.Run("leak", func(t *testing.T) {
+		c := make(chan int)
+		go func() { c <- 1 }()
+	})
+	t.Run("labeled-leak", func(t *testing.T) {
+		c := make(chan string)
+		reflect.ValueOf(c).SetChanLabel("jobs")
+		go func() { <-c }()
+	})
+	t.Run("no-leak", func(t *testing.T) {
+		c := make(chan int)
+		go func() { c <- 1 }()
+		<-c
+	})
+	t.Run("handoff", func(t *testing.T) {
+		// The sender is only briefly blocked.
+		c := make(chan int)
+		go func() { c <- 1 }()
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			<-c
+		}()
+	})
+	t.Run("cleanup", func(t *testing.T) {
+		c := make(chan int)
+		go func() { <-c }()
+		t.Cleanup(func() { close(c) })
+	})
+
+	// Goroutines blocked on channels created by the parent test
+	// belong to the parent, which unblocks them when its subtests
+	// are done.
+	parent := make(chan int)
+	t.Run("parent-chan", func(t *testing.T) {
+		go func() { <-parent }()
+	})
+	close(parent)
+
+	t.Run("group", func(t *testing.T) {
+		t.Run("parallel-leak", func(t *testing.T) {
+			t.Parallel()
+			c := make(chan int)
+			go func() {
+				select {
+				case <-c:
+				case c <- 1:
+				}
+			}()
+		})
+		t.Run("parallel", func(t *testing.T) {
+			t.Parallel()
+			c := make(chan int)
+			go func() { c <- 1 }()
+			<-c
+		})
+	})
+}
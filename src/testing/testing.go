@@ -280,6 +280,10 @@ func Init() {
 	// The failfast flag requests that test execution stop after the first test failure.
 	failFast = flag.Bool("test.failfast", false, "do not start new tests after the first test failure")
 
+	// The chanleak flag requests that tests fail if they leave goroutines
+	// blocked on channels they created.
+	chanLeak = flag.Bool("test.chanleak", false, "fail tests that leave goroutines blocked on channels they created")
+
 	// The directory in which to create profile files and the like. When run from
 	// "go test", the binary always runs in the source directory for the package;
 	// this flag lets "go test" tell the binary to write the files in the directory where
@@ -313,6 +317,7 @@ var (
 	// Flags, registered during Init.
 	short                *bool
 	failFast             *bool
+	chanLeak             *bool
 	outputDir            *string
 	chatty               *bool
 	count                *uint
@@ -676,6 +681,8 @@ type T struct {
 	isParallel bool
 	isEnvSet   bool
 	context    *testContext // For running tests and subtests.
+
+	chanLeakScope uint64 // scope of the channels created by the test; see startChanLeakCheck
 }
 
 func (c *common) private() {}
@@ -1239,6 +1246,9 @@ func tRunner(t *T, fn func(t *T)) {
 			// test. See comment in Run method.
 			t.context.release()
 		}
+		if t.chanLeakScope != 0 {
+			t.checkChanLeaks()
+		}
 		t.report() // Report after all subtests have finished.
 
 		// Do not lock t.done to allow race detector to detect race in case
@@ -1254,6 +1264,9 @@ func tRunner(t *T, fn func(t *T)) {
 		}
 	}()
 
+	if *chanLeak {
+		t.startChanLeakCheck()
+	}
 	t.start = time.Now()
 	t.raceErrors = -race.Errors()
 	fn(t)
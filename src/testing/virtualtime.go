@@ -0,0 +1,39 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testing
+
+// Provided by package runtime.
+func runtime_runTimeGroup(f func()) (deadlock bool)
+
+// VirtualTime runs f in a new goroutine and waits until f, and every
+// goroutine started by f directly or indirectly, has returned. These
+// goroutines form a group that runs on a virtual clock, which starts
+// at midnight UTC on January 1, 2000.
+//
+// Inside the group, time.Now, time.Since, time.Sleep, and the timers
+// and tickers of package time use the virtual clock. The clock only
+// moves when every goroutine in the group is blocked on a channel
+// operation, a select statement, a sync primitive, or time.Sleep. It
+// then jumps to the time of the earliest pending timer in the group,
+// which fires. Code that waits for timeouts or sleeps for minutes
+// therefore runs in milliseconds, and always sees the same times.
+//
+// Goroutines in the group must not communicate with goroutines
+// outside it, including through the network or the file system.
+// A goroutine waiting for one outside the group looks blocked, so
+// the clock may jump before the outside goroutine wakes it.
+//
+// If every goroutine in the group is blocked and no timer is pending,
+// the group is deadlocked: VirtualTime calls t.Fatal, leaving the
+// blocked goroutines behind. VirtualTime panics if it is called from
+// within a group.
+//
+// This is an experimental API.
+func (t *T) VirtualTime(f func()) {
+	t.Helper()
+	if runtime_runTimeGroup(f) {
+		t.Fatal("VirtualTime: all goroutines are blocked and no timers are pending: deadlock")
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package testing_test
+
+import (
+	"context"
+	"internal/testenv"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestVirtualTimeSleep(t *testing.T) {
+	realStart := time.Now()
+	t.VirtualTime(func() {
+		start := time.Now()
+		if want := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC); !start.Equal(want) {
+			t.Errorf("virtual time starts at %v, want %v", start, want)
+		}
+		time.Sleep(time.Hour)
+		if d := time.Since(start); d != time.Hour {
+			t.Errorf("time.Since after sleeping for an hour = %v", d)
+		}
+	})
+	if d := time.Since(realStart); d > time.Minute {
+		t.Errorf("VirtualTime took %v of real time", d)
+	}
+}
+
+func TestVirtualTimeTimers(t *testing.T) {
+	t.VirtualTime(func() {
+		start := time.Now()
+
+		timer := time.NewTimer(time.Minute)
+		if now := <-timer.C; now.Sub(start) != time.Minute {
+			t.Errorf("timer fired after %v, want 1m", now.Sub(start))
+		}
+
+		stopped := time.NewTimer(time.Second)
+		if !stopped.Stop() {
+			t.Errorf("Stop of a pending timer returned false")
+		}
+		stopped.Reset(2 * time.Second)
+		<-stopped.C
+		if d := time.Since(start); d != time.Minute+2*time.Second {
+			t.Errorf("reset timer fired at %v, want 1m2s", d)
+		}
+
+		ticker := time.NewTicker(time.Second)
+		for i := 0; i < 3; i++ {
+			<-ticker.C
+		}
+		ticker.Stop()
+		if d := time.Since(start); d != time.Minute+5*time.Second {
+			t.Errorf("after 3 ticks time is %v, want 1m5s", d)
+		}
+
+		done := make(chan time.Duration)
+		time.AfterFunc(time.Hour, func() { done <- time.Since(start) })
+		if d := <-done; d != time.Hour+time.Minute+5*time.Second {
+			t.Errorf("AfterFunc ran at %v, want 1h1m5s", d)
+		}
+	})
+}
+
+func TestVirtualTimeContext(t *testing.T) {
+	t.VirtualTime(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		start := time.Now()
+		<-ctx.Done()
+		if ctx.Err() != context.DeadlineExceeded {
+			t.Errorf("ctx.Err() = %v, want DeadlineExceeded", ctx.Err())
+		}
+		if d := time.Since(start); d != 30*time.Second {
+			t.Errorf("context expired after %v, want 30s", d)
+		}
+	})
+}
+
+func TestVirtualTimeOrder(t *testing.T) {
+	var got []int
+	t.VirtualTime(func() {
+		var (
+			mu sync.Mutex
+			wg sync.WaitGroup
+		)
+		for _, i := range []int{3, 1, 4, 2, 5} {
+			i := i
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				time.Sleep(time.Duration(i) * time.Second)
+				mu.Lock()
+				got = append(got, i)
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+	})
+	for i, v := range got {
+		if v != i+1 {
+			t.Fatalf("goroutines woke in order %v, want [1 2 3 4 5]", got)
+		}
+	}
+	if len(got) != 5 {
+		t.Fatalf("%d goroutines woke, want 5", len(got))
+	}
+}
+
+func TestVirtualTimeDeadlock(t *testing.T) {
+	testenv.MustHaveExec(t)
+
+	cmd := exec.Command(os.Args[0], "-test.run=TestVirtualTimeDeadlockHelper", "-test.v")
+	cmd.Env = append(os.Environ(), "GO_WANT_HELPER_PROCESS=1")
+	b, _ := cmd.CombinedOutput()
+	got := string(b)
+	for _, want := range []string{
+		"--- FAIL: TestVirtualTimeDeadlockHelper/deadlock (",
+		"all goroutines are blocked and no timers are pending: deadlock",
+		"--- PASS: TestVirtualTimeDeadlockHelper/after (",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestVirtualTimeDeadlockHelper(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	t.Run("deadlock", func(t *testing.T) {
+		t.VirtualTime(func() {
+			<-make(chan int)
+		})
+	})
+	t.Run("after", func(t *testing.T) {
+		t.VirtualTime(func() {
+			time.Sleep(time.Second)
+		})
+	})
+}
@@ -4,6 +4,8 @@
 
 package time
 
+import "unsafe"
+
 // Sleep pauses the current goroutine for at least the duration d.
 // A negative or zero duration causes Sleep to return immediately.
 func Sleep(d Duration)
@@ -19,6 +21,9 @@ type runtimeTimer struct {
 	seq      uintptr                    // 定时器触发时执行函数传递的参数二(该参数只在网络收发场景下使用)
 	nextwhen int64
 	status   uint32
+	sending  uint32
+	isChan   bool
+	group    unsafe.Pointer
 }
 
 // when is a helper function for setting the 'when' field of a runtimeTimer.
@@ -88,9 +93,10 @@ func NewTimer(d Duration) *Timer {
 	t := &Timer{
 		C: c,
 		r: runtimeTimer{
-			when: when(d),
-			f:    sendTime,
-			arg:  c,
+			when:   when(d),
+			f:      sendTime,
+			arg:    c,
+			isChan: true,
 		},
 	}
 	startTimer(&t.r)
@@ -101,27 +107,16 @@ func NewTimer(d Duration) *Timer {
 // It returns true if the timer had been active, false if the timer had
 // expired or been stopped.
 //
-// For a Timer created with NewTimer, Reset should be invoked only on
-// stopped or expired timers with drained channels.
-//
-// If a program has already received a value from t.C, the timer is known
-// to have expired and the channel drained, so t.Reset can be used directly.
-// If a program has not yet received a value from t.C, however,
-// the timer must be stopped and—if Stop reports that the timer expired
-// before being stopped—the channel explicitly drained:
-//
-// 	if !t.Stop() {
-// 		<-t.C
-// 	}
-// 	t.Reset(d)
-//
-// This should not be done concurrent to other receives from the Timer's
-// channel.
+// For a Timer created with NewTimer, Reset discards any expiration
+// time that was sent on t.C but not yet received, so once Reset
+// returns, a receive from t.C reports only the new expiration.
+// There is no need to stop the timer and drain the channel first,
+// although programs that do so continue to work.
 //
 // Note that it is not possible to use Reset's return value correctly, as there
-// is a race condition between draining the channel and the new timer expiring.
-// Reset should always be invoked on stopped or expired channels, as described above.
-// The return value exists to preserve compatibility with existing programs.
+// is a race condition between observing the return value and the new timer
+// expiring. The return value exists to preserve compatibility with existing
+// programs.
 //
 // For a Timer created with AfterFunc(d, f), Reset either reschedules
 // when f will run, in which case Reset returns true, or schedules f
@@ -161,6 +156,48 @@ func After(d Duration) <-chan Time {
 	return NewTimer(d).C
 }
 
+// AfterReuse is like After, but it arms the timer t instead of
+// allocating a new one, and returns t's channel. t must be a zero
+// Timer or a Timer previously passed to AfterReuse or created by
+// NewTimer. A select loop that waits with AfterReuse on the same t
+// allocates only once:
+//
+//	var timeout time.Timer
+//	for {
+//		select {
+//		case m := <-c:
+//			handle(m)
+//		case <-time.AfterReuse(&timeout, 5*time.Second):
+//			return
+//		}
+//	}
+//
+// Since the channel is the same each time, arming t again discards an
+// expiration from the previous arming that was not received, as
+// Reset does, so that a receive only reports the new expiration.
+// As with Reset, this must not be done concurrently with other
+// receives from t's channel. Call t.Stop once t is no longer needed
+// to release it before it fires.
+func AfterReuse(t *Timer, d Duration) <-chan Time {
+	if t.r.f == nil {
+		c := make(chan Time, 1)
+		t.C = c
+		t.r = runtimeTimer{
+			when:   when(d),
+			f:      sendTime,
+			arg:    c,
+			isChan: true,
+		}
+		startTimer(&t.r)
+		return t.C
+	}
+	if !t.r.isChan {
+		panic("time: AfterReuse called on Timer created by AfterFunc")
+	}
+	resetTimer(&t.r, when(d))
+	return t.C
+}
+
 // AfterFunc waits for the duration to elapse and then calls f
 // in its own goroutine. It returns a Timer that can
 // be used to cancel the call using its Stop method.
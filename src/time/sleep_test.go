@@ -142,6 +142,15 @@ func BenchmarkAfter(b *testing.B) {
 	})
 }
 
+func BenchmarkAfterReuse(b *testing.B) {
+	benchmark(b, func(n int) {
+		var timer Timer
+		for i := 0; i < n; i++ {
+			<-AfterReuse(&timer, 1)
+		}
+	})
+}
+
 func BenchmarkStop(b *testing.B) {
 	benchmark(b, func(n int) {
 		for i := 0; i < n; i++ {
@@ -814,3 +823,111 @@ func doWork(dur Duration) {
 	for Since(start) < dur {
 	}
 }
+
+// Test that Reset discards an expiration time that was sent on the
+// channel but not received, including one sent concurrently with the
+// Reset itself.
+func TestResetDiscardsStaleValue(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	n := 10000
+	if testing.Short() {
+		n = 1000
+	}
+	timer := NewTimer(Hour)
+	defer timer.Stop()
+	for i := 0; i < n; i++ {
+		// Let the timer expire somewhere around the next Reset.
+		timer.Reset(Duration(i%8) * Microsecond)
+		for j := 0; j < i%64; j++ {
+			runtime.Gosched()
+		}
+		before := Now()
+		timer.Reset(Duration(i%3) * Microsecond)
+		if v := <-timer.C; v.Before(before) {
+			t.Fatalf("iteration %d: received %v, sent before Reset at %v", i, v, before)
+		}
+	}
+
+	// A Reset to a long duration leaves nothing to receive,
+	// even once any sends in flight have had time to land.
+	timer.Reset(0)
+	Sleep(Millisecond)
+	timer.Reset(Hour)
+	Sleep(10 * Millisecond)
+	select {
+	case v := <-timer.C:
+		t.Fatalf("received %v after Reset", v)
+	default:
+	}
+}
+
+// Test that the documented Stop-and-drain idiom still works now that
+// Reset discards stale values itself.
+func TestResetAfterStopDrain(t *testing.T) {
+	timer := NewTimer(0)
+	Sleep(10 * Millisecond)
+	if timer.Stop() {
+		t.Fatal("Stop of expired timer returned true")
+	}
+	select {
+	case <-timer.C:
+	case <-After(10 * Second):
+		t.Fatal("expired timer sent no value")
+	}
+	timer.Reset(Millisecond)
+	select {
+	case <-timer.C:
+	case <-After(10 * Second):
+		t.Fatal("reset timer did not fire")
+	}
+}
+
+func TestAfterReuse(t *testing.T) {
+	var timer Timer
+	c := AfterReuse(&timer, Millisecond)
+	if c != timer.C {
+		t.Fatalf("AfterReuse returned a channel other than the timer's")
+	}
+	<-c
+
+	// Rearming discards an expiration that was not received.
+	AfterReuse(&timer, 0)
+	Sleep(10 * Millisecond)
+	before := Now()
+	if c := AfterReuse(&timer, Millisecond); c != timer.C {
+		t.Fatalf("AfterReuse returned a new channel")
+	}
+	if v := <-timer.C; v.Before(before) {
+		t.Fatalf("received %v, sent before AfterReuse at %v", v, before)
+	}
+
+	// A select loop that times out with AfterReuse does not allocate.
+	ch := make(chan int, 1)
+	allocs := testing.AllocsPerRun(100, func() {
+		ch <- 1
+		select {
+		case <-ch:
+		case <-AfterReuse(&timer, Hour):
+			t.Fatal("timer fired")
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("select loop with AfterReuse made %v allocations per iteration, want 0", allocs)
+	}
+	if !timer.Stop() {
+		t.Errorf("Stop of armed timer returned false")
+	}
+
+	// AfterReuse takes a Timer from NewTimer, but not AfterFunc.
+	nt := NewTimer(Hour)
+	if c := AfterReuse(nt, 0); c != nt.C {
+		t.Fatalf("AfterReuse returned a channel other than the timer's")
+	}
+	<-nt.C
+	defer func() {
+		if recover() == nil {
+			t.Errorf("AfterReuse of AfterFunc timer did not panic")
+		}
+	}()
+	AfterReuse(AfterFunc(Hour, func() {}), 0)
+}
@@ -34,6 +34,7 @@ func NewTicker(d Duration) *Ticker {
 			period: int64(d),
 			f:      sendTime,
 			arg:    c,
+			isChan: true,
 		},
 	}
 	startTimer(&t.r)
@@ -49,6 +50,7 @@ func (t *Ticker) Stop() {
 
 // Reset stops a ticker and resets its period to the specified duration.
 // The next tick will arrive after the new period elapses.
+// Any tick that was sent on t.C but not yet received is discarded.
 func (t *Ticker) Reset(d Duration) {
 	if t.r.f == nil {
 		panic("time: Reset called on uninitialized Ticker")
@@ -85,6 +85,27 @@ func TestTicker(t *testing.T) {
 	logErrs()
 }
 
+// Test that Reset discards a tick that was sent but not received.
+func TestTickerResetDiscardsStaleValue(t *testing.T) {
+	n := 200
+	if testing.Short() {
+		n = 50
+	}
+	ticker := NewTicker(Microsecond)
+	defer ticker.Stop()
+	for i := 0; i < n; i++ {
+		for j := 0; j < i%16; j++ {
+			runtime.Gosched()
+		}
+		before := Now()
+		ticker.Reset(Millisecond)
+		if v := <-ticker.C; v.Before(before) {
+			t.Fatalf("iteration %d: received %v, sent before Reset at %v", i, v, before)
+		}
+		ticker.Reset(Microsecond)
+	}
+}
+
 // Issue 21874
 func TestTickerStopWithDirectInitialization(t *testing.T) {
 	c := make(chan Time)
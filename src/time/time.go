@@ -75,10 +75,7 @@
 //
 package time
 
-import (
-	"errors"
-	_ "unsafe" // for go:linkname
-)
+import "errors"
 
 // A Time represents an instant in time with nanosecond precision.
 //
@@ -1058,7 +1055,7 @@ func daysSinceEpoch(year int) uint64 {
 func now() (sec int64, nsec int32, mono int64)
 
 // runtimeNano returns the current value of the runtime clock in nanoseconds.
-//go:linkname runtimeNano runtime.nanotime
+// Provided by package runtime.
 func runtimeNano() int64
 
 // Monotonic times are reported as offsets from startNano.
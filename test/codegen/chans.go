@@ -0,0 +1,63 @@
+// asmcheck
+
+// Copyright 2021 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package codegen
+
+// The channel operations call the runtime with the register ABI: the
+// channel and the pointer to the element are passed in registers, and
+// results come back in registers, with nothing going through the
+// outgoing argument area.
+
+func chanSend(c chan int, v int) {
+	// amd64:"LEAQ\t.*autotmp.*, BX","CALL\truntime.chansend1",-"MOV[BLQ]\t[A-Z0-9]+, [0-9]*\\(SP\\)"
+	c <- v
+}
+
+func chanRecv(c chan int) int {
+	// amd64:"LEAQ\t.*autotmp.*, BX","CALL\truntime.chanrecv1",-"MOV[BLQ]\t[A-Z0-9]+, [0-9]*\\(SP\\)"
+	return <-c
+}
+
+func chanRecv2(c chan int) (int, bool) {
+	// amd64:"CALL\truntime.chanrecv2",-"MOV[BLQ]\t[A-Z0-9]+, [0-9]*\\(SP\\)",-"MOV[BLQ]\t[0-9]*\\(SP\\), "
+	v, ok := <-c
+	return v, ok
+}
+
+func chanTrySend(c chan int, v int) bool {
+	select {
+	// amd64:"CALL\truntime.selectnbsend",-"MOV[BLQ]\t[A-Z0-9]+, [0-9]*\\(SP\\)",-"MOV[BLQ]\t[0-9]*\\(SP\\), "
+	case c <- v:
+		return true
+	default:
+		return false
+	}
+}
+
+func chanTryRecv(c chan int) (int, bool) {
+	select {
+	// amd64:"CALL\truntime.selectnbrecv",-"MOV[BLQ]\t[A-Z0-9]+, [0-9]*\\(SP\\)",-"MOV[BLQ]\t[0-9]*\\(SP\\), "
+	case v, ok := <-c:
+		return v, ok
+	default:
+		return 0, false
+	}
+}
+
+func chanSelect(a, b chan int) int {
+	// amd64:"CALL\truntime.selectgo",-"MOV[BLQ]\t[A-Z0-9]+, [0-9]*\\(SP\\)"
+	select {
+	case v := <-a:
+		return v
+	case b <- 1:
+		return 0
+	}
+}
+
+func chanClose(c chan int) {
+	// amd64:"CALL\truntime.closechan",-"MOV[BLQ]\t[A-Z0-9]+, [0-9]*\\(SP\\)"
+	close(c)
+}